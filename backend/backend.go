@@ -1,15 +1,81 @@
 package backend
 
-import "github.com/twitchscience/scoop_protocol/scoop_protocol"
+import (
+	"context"
+	"time"
 
-//Backend is an interface that represents what operations on a DB must be available
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// CopyStats is the size of a finished COPY, and how long each of its stages took
+type CopyStats struct {
+	RowsLoaded   int64
+	BytesScanned int64
+	// LinesScanned is the number of lines the COPY read; it's 0 if unknown
+	LinesScanned int64
+	// LockWait is how long the COPY waited for other work on the table
+	LockWait time.Duration
+	// CopyDuration is how long the COPY statements ran
+	CopyDuration time.Duration
+	// CommitDuration is how long committing the COPY took
+	CommitDuration time.Duration
+	// CommitQueueWait and CommitExecution split the commit into the time it waited in Redshift's commit
+	// queue and the time it ran, from STL_COMMIT_STATS; they're 0 if Redshift hadn't recorded it
+	CommitQueueWait time.Duration
+	CommitExecution time.Duration
+	// DuplicatesDropped is the number of rows a deduplicated COPY read but didn't insert
+	DuplicatesDropped int64
+}
+
+//Backend is an interface that represents what operations on a DB must be available. The COPYs run in
+//transactions bound to their context, which are rolled back if it's done before they're committed.
 type Backend interface {
 	HealthCheck() error
 	LoadCheck(*scoop_protocol.LoadCheckRequest) (*scoop_protocol.LoadCheckResponse, error)
-	ManifestCopy(*scoop_protocol.ManifestRowCopyRequest) error
+	ManifestCopy(ctx context.Context, table string, manifestURLs []string, opts redshift.CopyOptions) (*CopyStats, error)
+	// ChunkedManifestCopy COPYs each manifest in its own transaction, up to parallelism at once, calling
+	// done with each one's stats or error
+	ChunkedManifestCopy(ctx context.Context, table string, manifestURLs []string, opts redshift.CopyOptions,
+		parallelism int, done func(chunk int, stats *CopyStats, err error))
 	TableVersions() (map[string]int, error)
 	ApplyOperations(string, []scoop_protocol.Operation, []scoop_protocol.ColumnDefinition, int, int) error
 	CreateTable(string, []scoop_protocol.Operation, []scoop_protocol.ColumnDefinition, int) error
 	TableExists(string) (bool, error)
 	TableLocked(string) (bool, error)
+	// LiveColumns returns the table's columns as they are in Redshift
+	LiveColumns(table string) ([]LiveColumn, error)
+	// UnloadTable unloads the table's rows under an S3 prefix, timing out after the milliseconds if positive
+	UnloadTable(table, s3Prefix string, timeoutMs int) error
+	// DropTable drops the table and its views, and stops versioning it
+	DropTable(string) error
+	// RenameTable renames the table and its views, carrying its versions over to the new name at the
+	// version
+	RenameTable(table, newName string, version int, cols []scoop_protocol.ColumnDefinition) error
+	// CreateVersionedTable creates the table's versioned table for the version with the version's columns
+	CreateVersionedTable(table string, version int, cols []scoop_protocol.ColumnDefinition) error
+	// VersionedManifestCopy is ManifestCopy into the table's versioned table for the version
+	VersionedManifestCopy(ctx context.Context, table string, version int, manifestURLs []string,
+		opts redshift.CopyOptions) (*CopyStats, error)
+	// UnionVersionedTables makes the table's view include its versioned tables for the versions
+	UnionVersionedTables(table string, versions []int) error
+	// MergeVersionedTable moves the versioned table's rows into the table, which has been migrated to
+	// the version, leaving the view unioned with the others
+	MergeVersionedTable(table string, version int, unioned []int) error
+	// StragglerCopy loads files of an outdated version of the table, with the version's columns, into
+	// the table or its stragglers table, padded or truncated to its columns
+	StragglerCopy(ctx context.Context, table, into string, cols []scoop_protocol.ColumnDefinition,
+		manifestURLs []string, opts redshift.CopyOptions) (*CopyStats, error)
+	// PIICopy loads files of the table, with the version's columns, with its PII columns hashed or
+	// NULLed by their policies, keeping restricted columns' raw rows in the restricted schema
+	PIICopy(ctx context.Context, table string, cols []scoop_protocol.ColumnDefinition, manifestURLs []string,
+		opts redshift.CopyOptions, pii PIIColumns) (*CopyStats, error)
+	// DedupCopy loads files of the table, inserting only the first row of each value of the key columns
+	// the table doesn't have yet
+	DedupCopy(ctx context.Context, table string, key []string, manifestURLs []string,
+		opts redshift.CopyOptions) (*CopyStats, error)
+	// TypeChangeProgress returns how far the migrations changing column types are through copying their
+	// tables
+	TypeChangeProgress() []TypeChangeProgress
+	WaitUntilAvailable()
 }
@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+const defaultCanarySchema = "canary"
+
+// CanaryConfig has some of a table's manifests also loaded into a canary copy of the table, so
+// migrations and COPY options can be tried against live data without risking the table.
+type CanaryConfig struct {
+	// Schema holds the canary copies, created like their tables; defaults to canary
+	Schema string `json:"schema"`
+	// Tables is the percent of each canaried table's manifests also loaded into its copy
+	Tables map[string]int `json:"tables"`
+	// CompUpdate and StatUpdate, if set, override the COPY options of the loads into the copies
+	CompUpdate string `json:"compUpdate"`
+	StatUpdate string `json:"statUpdate"`
+}
+
+func (c CanaryConfig) schema() string {
+	if c.Schema == "" {
+		return defaultCanarySchema
+	}
+	return c.Schema
+}
+
+// excludedSchema returns the schema whose COPYs aren't loads, or "" if no table is canaried.
+func (c CanaryConfig) excludedSchema() string {
+	if len(c.Tables) == 0 {
+		return ""
+	}
+	return c.schema()
+}
+
+// canaried returns whether the table has a canary copy.
+func (c CanaryConfig) canaried(table string) bool {
+	return c.Tables[table] > 0
+}
+
+// loadsCanary returns whether the load of the manifest is also loaded into the table's canary copy.
+// The choice is a hash of the manifest, so a retried load makes the same one.
+func (c CanaryConfig) loadsCanary(table, manifestURL string) bool {
+	return c.canaried(table) && metadata.KeepsFile(manifestURL, c.Tables[table])
+}
+
+// canaryCopy COPYs the manifests of a load that was just committed into the table's canary copy,
+// if the table is canaried and this load chosen, creating the copy like the table if needed. The
+// table's lock must be held. A failure is logged and counted, as it mustn't fail the load.
+func (r *RedshiftBackend) canaryCopy(ctx context.Context, table string, manifestURLs []string,
+	opts redshift.CopyOptions) {
+	if len(manifestURLs) == 0 || !r.canary.loadsCanary(table, manifestURLs[0]) {
+		return
+	}
+	if r.canary.CompUpdate != "" {
+		opts.CompUpdate = r.canary.CompUpdate
+	}
+	if r.canary.StatUpdate != "" {
+		opts.StatUpdate = r.canary.StatUpdate
+	}
+	start := time.Now()
+	stats := &CopyStats{}
+	err := r.createCanaryTable(table)
+	if err == nil {
+		_, err = r.copyManifests(ctx, r.canary.schema(), table, manifestURLs, opts, stats)
+	}
+	if err != nil {
+		logger.WithError(err).WithField("table", table).WithField("schema", r.canary.schema()).
+			Warn("Error loading manifest into canary copy")
+		lib.TableInc(r.stats, "canary.failed", table, 1)
+		r.stats.SafeInc("canary.total.failed", 1, 1.0)
+		return
+	}
+	lib.TableInc(r.stats, "canary.loaded", table, 1)
+	lib.TableInc(r.stats, "canary.rows_loaded", table, stats.RowsLoaded)
+	lib.TableTiming(r.stats, "canary.copy", table, time.Since(start))
+}
+
+// createCanaryTable creates the canary schema and the table's canary copy, with the table's columns,
+// keys and encodings, unless they exist.
+func (r *RedshiftBackend) createCanaryTable(table string) error {
+	_, err := r.connection.Conn.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pq.QuoteIdentifier(r.canary.schema())))
+	if err != nil {
+		return fmt.Errorf("creating canary schema: %v", err)
+	}
+	_, err = r.connection.Conn.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (LIKE %s.%s)",
+		pq.QuoteIdentifier(r.canary.schema()), pq.QuoteIdentifier(table),
+		pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table)))
+	if err != nil {
+		return fmt.Errorf("creating canary copy: %v", err)
+	}
+	return nil
+}
+
+// hasCanaryCopy returns whether the table has a canary copy for a migration to be applied to.
+func (r *RedshiftBackend) hasCanaryCopy(table string) (bool, error) {
+	if !r.canary.canaried(table) {
+		return false, nil
+	}
+	return r.tableExistsIn(r.canary.schema(), table)
+}
+
+// migrateCanary applies the operations to the table's canary copy in the table's migration transaction,
+// before they're applied to the table, so a migration the copy fails isn't applied to the table, and
+// one the table fails is rolled back from the copy too. Type changes and rebuilds aren't tried; the copy
+// is dropped instead, and created again like the migrated table on its next load.
+func (r *RedshiftBackend) migrateCanary(tx *sql.Tx, table string, ops []scoop_protocol.Operation) error {
+	quotedSchema, quotedTable := pq.QuoteIdentifier(r.canary.schema()), pq.QuoteIdentifier(table)
+	if HasTypeChange(ops) || HasRebuild(ops) {
+		_, err := tx.Exec(fmt.Sprintf("DROP TABLE %s.%s", quotedSchema, quotedTable))
+		if err != nil {
+			return fmt.Errorf("dropping canary copy: %v", err)
+		}
+		logger.WithField("table", table).Info("Dropping canary copy instead of changing column types or rebuilding it")
+		return nil
+	}
+	for _, op := range ops {
+		err := applyOperation(op, quotedSchema, quotedTable, tx)
+		if err != nil {
+			lib.TableInc(r.stats, "canary.migration_failed", table, 1)
+			return fmt.Errorf("migrating canary copy %s.%s, which must be fixed or dropped before the table is migrated: %v",
+				r.canary.schema(), table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package backend
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMigrateCanary(t *testing.T) {
+	r, mock := mockBackend(t)
+	r.viewSchema, r.fullViewSchema = "views", "full_views"
+	r.canary = CanaryConfig{Tables: map[string]int{"chat": 10}}
+	ops := []scoop_protocol.Operation{scoop_protocol.NewDeleteOperation("login")}
+	cols := []scoop_protocol.ColumnDefinition{{OutboundName: "time", Transformer: "f@timestamp@unix"}}
+	expectMigration := func() {
+		mock.ExpectQuery("FROM pg_catalog.pg_class").WithArgs("canary", "chat").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT MAX\\(version\\) FROM infra.table_version").WithArgs("chat").
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+		mock.ExpectExec("SET LOCAL statement_timeout TO 1000").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	// A migration the table fails is rolled back from the canary copy too, so retrying it applies to both
+	for i := 0; i < 2; i++ {
+		expectMigration()
+		mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "canary"."chat" DROP COLUMN "login" CASCADE`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`DROP VIEW "views"."chat" CASCADE`)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`DROP VIEW IF EXISTS "full_views"."chat" CASCADE`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		drop := mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "logs"."chat" DROP COLUMN "login" CASCADE`))
+		if i == 0 {
+			drop.WillReturnError(errors.New("connection reset by peer"))
+			mock.ExpectRollback()
+			continue
+		}
+		drop.WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`CREATE VIEW "views"."chat"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO infra.table_version").WithArgs("chat", 3).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+	assert.Error(t, r.ApplyOperations("chat", ops, cols, 3, 1000))
+	assert.NoError(t, r.ApplyOperations("chat", ops, cols, 3, 1000))
+
+	// A migration the canary copy fails isn't applied to the table
+	expectMigration()
+	mock.ExpectExec(`ALTER TABLE "canary"."chat"`).WillReturnError(errors.New("column \"login\" does not exist"))
+	mock.ExpectRollback()
+	err := r.ApplyOperations("chat", ops, cols, 3, 1000)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "migrating canary copy canary.chat")
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// Column attributes blueprint can set in an operation's ActionMetadata, besides column_type and
+// column_options.
+const (
+	// columnDefault is the DEFAULT: a number, true, false, one of defaultFunctions, or else a string
+	columnDefault = "default"
+	// columnIdentity makes the column an IDENTITY column, given as "seed,step"
+	columnIdentity = "identity"
+	// columnNotNull is "true" for a NOT NULL column
+	columnNotNull = "not_null"
+	// columnEncode is the column's compression ENCODE
+	columnEncode = "encode"
+	// columnDistKey is "true" for the table's DISTKEY
+	columnDistKey = "distkey"
+	// columnSortKey is "true" for the table's SORTKEY
+	columnSortKey = "sortkey"
+)
+
+var (
+	// defaultFunctions are the DEFAULTs that are used as expressions rather than strings.
+	defaultFunctions = map[string]bool{
+		"getdate()":         true,
+		"sysdate":           true,
+		"current_date":      true,
+		"current_timestamp": true,
+	}
+
+	encodings = map[string]bool{
+		"raw": true, "az64": true, "bytedict": true, "delta": true, "delta32k": true, "lzo": true,
+		"mostly8": true, "mostly16": true, "mostly32": true, "runlength": true, "text255": true,
+		"text32k": true, "zstd": true,
+	}
+
+	numberPattern   = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	identityPattern = regexp.MustCompile(`^\s*(-?[0-9]+)\s*,\s*(-?[0-9]+)\s*$`)
+)
+
+// isSet returns whether a boolean attribute is "true".
+func (m *migrationStep) isSet(attribute string) bool {
+	set, _ := strconv.ParseBool(m.ActionMetadata[attribute])
+	return set
+}
+
+// getColumnAttributes returns the DDL for the column's attributes, e.g. " DEFAULT 0 NOT NULL".
+// IDENTITY, DISTKEY and SORTKEY can only be given when creating the table, not when adding a column.
+func (m *migrationStep) getColumnAttributes(creatingTable bool) (string, error) {
+	_, isIdentity := m.ActionMetadata[columnIdentity]
+	if !creatingTable && (isIdentity || m.isSet(columnDistKey) || m.isSet(columnSortKey)) {
+		return "", fmt.Errorf("column %s can only be an identity, distkey or sortkey when its table is created", m.Name)
+	}
+
+	var attrs []string
+	if def, ok := m.ActionMetadata[columnDefault]; ok {
+		if isIdentity {
+			return "", fmt.Errorf("column %s can't have both a default and an identity", m.Name)
+		}
+		attrs = append(attrs, "DEFAULT "+defaultExpression(def))
+	}
+	if isIdentity {
+		identity := m.ActionMetadata[columnIdentity]
+		match := identityPattern.FindStringSubmatch(identity)
+		if match == nil {
+			return "", fmt.Errorf("column %s has identity %q; must be \"seed,step\"", m.Name, identity)
+		}
+		attrs = append(attrs, fmt.Sprintf("IDENTITY(%s, %s)", match[1], match[2]))
+	}
+	if encode, ok := m.ActionMetadata[columnEncode]; ok {
+		encode = strings.ToLower(strings.TrimSpace(encode))
+		if !encodings[encode] {
+			return "", fmt.Errorf("column %s has unknown encoding %q", m.Name, encode)
+		}
+		attrs = append(attrs, "ENCODE "+encode)
+	}
+	if m.isSet(columnDistKey) {
+		attrs = append(attrs, "DISTKEY")
+	}
+	if m.isSet(columnSortKey) {
+		attrs = append(attrs, "SORTKEY")
+	}
+	if m.isSet(columnNotNull) {
+		attrs = append(attrs, "NOT NULL")
+	}
+	if len(attrs) == 0 {
+		return "", nil
+	}
+	return " " + strings.Join(attrs, " "), nil
+}
+
+// defaultExpression returns the SQL for a DEFAULT value, quoting it as a string unless it's a
+// number, boolean or known function.
+func defaultExpression(def string) string {
+	trimmed := strings.TrimSpace(def)
+	lower := strings.ToLower(trimmed)
+	switch {
+	case numberPattern.MatchString(trimmed):
+		return trimmed
+	case lower == "true" || lower == "false" || defaultFunctions[lower]:
+		return strings.ToUpper(lower)
+	}
+	return redshift.EscapePGString(def)
+}
@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+func addColumn(name string, metadata map[string]string) scoop_protocol.Operation {
+	actionMetadata := map[string]string{"column_type": "bigint", "column_options": ""}
+	for k, v := range metadata {
+		actionMetadata[k] = v
+	}
+	return scoop_protocol.Operation{Action: scoop_protocol.ADD, Name: name, ActionMetadata: actionMetadata}
+}
+
+func TestGetCreationForm(t *testing.T) {
+	step := migrationStep(addColumn("id", map[string]string{
+		"identity": "1, 1", "encode": "AZ64", "distkey": "true", "not_null": "true"}))
+	column, err := step.getCreationForm(true)
+	assert.Nil(t, err)
+	assert.Equal(t, `"id" bigint IDENTITY(1, 1) ENCODE az64 DISTKEY NOT NULL`, column)
+	_, err = step.getCreationForm(false)
+	assert.NotNil(t, err, "identity columns can't be added to existing tables")
+
+	step = migrationStep(addColumn("region", map[string]string{"default": "it's"}))
+	column, err = step.getCreationForm(false)
+	assert.Nil(t, err)
+	assert.Equal(t, `"region" bigint DEFAULT 'it''s'`, column)
+
+	step = migrationStep(addColumn("loaded", map[string]string{"default": "getdate()", "sortkey": "true"}))
+	column, err = step.getCreationForm(true)
+	assert.Nil(t, err)
+	assert.Equal(t, `"loaded" bigint DEFAULT GETDATE() SORTKEY`, column)
+
+	step = migrationStep(addColumn("bad", map[string]string{"encode": "lz4; DROP TABLE x"}))
+	_, err = step.getCreationForm(true)
+	assert.NotNil(t, err)
+}
+
+func TestBuildNewTableRejectsTwoDistKeys(t *testing.T) {
+	_, err := buildNewTable([]scoop_protocol.Operation{
+		addColumn("a", map[string]string{"distkey": "true"}),
+		addColumn("b", map[string]string{"distkey": "true"}),
+	})
+	assert.NotNil(t, err)
+}
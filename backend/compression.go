@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// CompressionRecommendation is the column encoding ANALYZE COMPRESSION recommends.
+type CompressionRecommendation struct {
+	Table                 string
+	Column                string
+	Encoding              string
+	EstimatedReductionPct float64
+	Analyzed              time.Time
+}
+
+type compressionTracker struct {
+	lock            sync.RWMutex
+	recommendations map[string][]CompressionRecommendation
+}
+
+func (t *compressionTracker) set(table string, recs []CompressionRecommendation) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.recommendations == nil {
+		t.recommendations = make(map[string][]CompressionRecommendation)
+	}
+	t.recommendations[table] = recs
+}
+
+func (t *compressionTracker) list() []CompressionRecommendation {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	tables := make([]string, 0, len(t.recommendations))
+	for table := range t.recommendations {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	list := []CompressionRecommendation{}
+	for _, table := range tables {
+		list = append(list, t.recommendations[table]...)
+	}
+	return list
+}
+
+// CompressionRecommendations returns the latest recommendations of AnalyzeCompression.
+func (r *RedshiftBackend) CompressionRecommendations() []CompressionRecommendation {
+	return r.compression.list()
+}
+
+// AnalyzeCompression runs ANALYZE COMPRESSION on every table with at least minRows rows, keeping
+// the recommendations for CompressionRecommendations. The analysis locks the table, so loads into
+// it wait until it's done.
+func (r *RedshiftBackend) AnalyzeCompression(minRows int64) error {
+	tables, err := r.largeTables(minRows)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		recs, err := r.analyzeTableCompression(table)
+		if err != nil {
+			logger.WithError(err).WithField("table", table).Error("Error analyzing table compression")
+			continue
+		}
+		r.compression.set(table, recs)
+		logger.WithField("table", table).WithField("columns", len(recs)).Info("Analyzed table compression")
+	}
+	return nil
+}
+
+// largeTables returns the tables with at least minRows rows, in their own schema.
+func (r *RedshiftBackend) largeTables(minRows int64) ([]string, error) {
+	rows, err := r.connection.Conn.Query(
+		`SELECT "schema", "table" FROM SVV_TABLE_INFO WHERE tbl_rows >= $1 ORDER BY "table"`, minRows)
+	if err != nil {
+		return nil, fmt.Errorf("querying large tables: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows")
+		}
+	}()
+	var tables []string
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, fmt.Errorf("scanning large tables: %v", err)
+		}
+		// Skips copies in other schemas, e.g. views' or other environments'.
+		if schema == r.tableSchema(table) {
+			tables = append(tables, table)
+		}
+	}
+	return tables, nil
+}
+
+func (r *RedshiftBackend) analyzeTableCompression(table string) ([]CompressionRecommendation, error) {
+	lock := r.getTableLock(table)
+	lock.Lock()
+	defer lock.Unlock()
+
+	rows, err := r.connection.Conn.Query(fmt.Sprintf("ANALYZE COMPRESSION %s.%s",
+		pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows")
+		}
+	}()
+	now := time.Now().In(time.UTC)
+	var recs []CompressionRecommendation
+	for rows.Next() {
+		rec := CompressionRecommendation{Analyzed: now}
+		var reduction string
+		if err := rows.Scan(&rec.Table, &rec.Column, &rec.Encoding, &reduction); err != nil {
+			return nil, fmt.Errorf("scanning compression analysis: %v", err)
+		}
+		rec.EstimatedReductionPct, err = strconv.ParseFloat(reduction, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing estimated reduction of %s: %v", rec.Column, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
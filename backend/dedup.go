@@ -0,0 +1,133 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// dedupRow is the column numbering the staged rows of each key.
+const dedupRow = "rs_ingester_dedup_row"
+
+// dedupInsert returns the INSERT of the rows staged in the temporary table into the table's columns,
+// keeping the first row of each key and only the keys the table doesn't have yet. A key with a NULL
+// column never matches the table's rows, so is always inserted.
+func dedupInsert(schema, table, staging string, cols, key []string) string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = pq.QuoteIdentifier(col)
+	}
+	partition := make([]string, len(key))
+	matches := make([]string, len(key))
+	for i, col := range key {
+		name := pq.QuoteIdentifier(col)
+		partition[i] = name
+		matches[i] = fmt.Sprintf("t.%s = st.%s", name, name)
+	}
+	return fmt.Sprintf(`INSERT INTO %[1]s.%[2]s (%[3]s)
+		SELECT %[3]s FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY %[4]s) AS %[5]s FROM %[6]s) st
+		WHERE st.%[5]s = 1 AND NOT EXISTS (SELECT 1 FROM %[1]s.%[2]s t WHERE %[7]s)`,
+		pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table), strings.Join(names, ", "),
+		strings.Join(partition, ", "), dedupRow, pq.QuoteIdentifier(staging), strings.Join(matches, " AND "))
+}
+
+// DedupCopy loads files of the table, dropping rows whose key columns match another's. The manifests
+// are COPYed into a temporary staging table like the table, the first staged row of each key the table
+// doesn't have yet is inserted into it, and the staging table is dropped, all in one transaction. RowsLoaded
+// is the number of rows COPYed, and DuplicatesDropped those that weren't inserted.
+func (r *RedshiftBackend) DedupCopy(ctx context.Context, table string, key []string, manifestURLs []string,
+	opts redshift.CopyOptions) (*CopyStats, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("no dedup key given for %s", table)
+	}
+	start := time.Now()
+	unlock, err := r.lockTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stats := &CopyStats{LockWait: time.Since(start)}
+	schema := r.tableSchema(table)
+	// Temporary, so only this load's session sees it, and it's gone with the session even if it isn't dropped
+	staging := table + "_dedup_staging"
+	var queryIDs []int64
+	var copied time.Time
+	err = r.connection.ExecFnInTransactionContext(ctx, func(tx *sql.Tx) error {
+		copyStart := time.Now()
+		stats.RowsLoaded = 0
+		queryIDs = queryIDs[:0]
+		if opts.StatementTimeoutMs > 0 {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", opts.StatementTimeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+		}
+		intoCols, err := liveColumns(tx, schema, table)
+		if err != nil {
+			return err
+		}
+		if len(intoCols) == 0 {
+			return fmt.Errorf("table %s doesn't exist", table)
+		}
+		for _, col := range key {
+			if liveType(intoCols, col) == "" {
+				return fmt.Errorf("dedup key column %s isn't a column of %s", col, table)
+			}
+		}
+		_, err = tx.Exec(fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s.%s)", pq.QuoteIdentifier(staging),
+			pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table)))
+		if err != nil {
+			return fmt.Errorf("creating staging table %s: %v", staging, err)
+		}
+		for _, manifestURL := range manifestURLs {
+			req := redshift.ManifestRowCopyRequest{
+				BuiltOn:     time.Now(),
+				Name:        staging,
+				ManifestURL: manifestURL,
+				Credentials: redshift.CopyCredentials(r.credentials),
+				Options:     opts,
+			}
+			if err = req.TxExecContext(ctx, tx); err != nil {
+				return err
+			}
+			result, err := redshift.LastCopyResult(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("getting copy result: %v", err)
+			}
+			stats.RowsLoaded += result.RowsLoaded
+			queryIDs = append(queryIDs, result.QueryID)
+		}
+		cols := make([]string, len(intoCols))
+		for i, col := range intoCols {
+			cols[i] = col.Name
+		}
+		result, err := tx.Exec(dedupInsert(schema, table, staging, cols, key))
+		if err != nil {
+			return fmt.Errorf("inserting deduplicated rows into %s: %v", table, err)
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("getting rows inserted into %s: %v", table, err)
+		}
+		stats.DuplicatesDropped = stats.RowsLoaded - inserted
+		_, err = tx.Exec(fmt.Sprintf("DROP TABLE %s", pq.QuoteIdentifier(staging)))
+		if err != nil {
+			return fmt.Errorf("dropping staging table %s: %v", staging, err)
+		}
+		copied = time.Now()
+		stats.CopyDuration = copied.Sub(copyStart)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.CommitDuration = time.Since(copied)
+	r.addScanned(table, queryIDs, stats)
+	return stats, nil
+}
@@ -0,0 +1,59 @@
+package backend
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestDedupInsert(t *testing.T) {
+	query := dedupInsert("logs", "minute_watched", "minute_watched_dedup_staging",
+		[]string{"time", "user_id", "session_id"}, []string{"user_id", "session_id"})
+	assert.Equal(t, `INSERT INTO "logs"."minute_watched" ("time", "user_id", "session_id")
+		SELECT "time", "user_id", "session_id" FROM (SELECT *, ROW_NUMBER() OVER (PARTITION BY "user_id", "session_id") AS rs_ingester_dedup_row FROM "minute_watched_dedup_staging") st
+		WHERE st.rs_ingester_dedup_row = 1 AND NOT EXISTS (SELECT 1 FROM "logs"."minute_watched" t WHERE t."user_id" = st."user_id" AND t."session_id" = st."session_id")`,
+		query)
+}
+
+func TestDedupCopy(t *testing.T) {
+	r, mock := mockBackend(t)
+	manifests := []string{"s3://bucket/a.json", "s3://bucket/b.json"}
+
+	_, err := r.DedupCopy(context.Background(), "chat", nil, manifests, redshift.CopyOptions{})
+	assert.EqualError(t, err, "no dedup key given for chat")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout TO 60000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLiveColumns(mock, "logs", "chat", "time", "timestamp without time zone", "user_id", "bigint")
+	// The staging table is temporary, so it's private to the load's session and never left in the schema
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TEMP TABLE "chat_dedup_staging" (LIKE "logs"."chat")`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for i, manifest := range manifests {
+		mock.ExpectExec(regexp.QuoteMeta(`COPY "chat_dedup_staging" FROM '` + manifest + `'`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT pg_last_copy_id").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "count"}).AddRow(i+1, 10))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "logs"."chat" ("time", "user_id")`) +
+		`.* FROM "chat_dedup_staging"\) st`).WillReturnResult(sqlmock.NewResult(0, 15))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE "chat_dedup_staging"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	stats, err := r.DedupCopy(context.Background(), "chat", []string{"user_id"}, manifests,
+		redshift.CopyOptions{StatementTimeoutMs: 60000})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(20), stats.RowsLoaded)
+		assert.Equal(t, int64(5), stats.DuplicatesDropped)
+	}
+
+	mock.ExpectBegin()
+	expectLiveColumns(mock, "logs", "chat", "time", "timestamp without time zone")
+	mock.ExpectRollback()
+	_, err = r.DedupCopy(context.Background(), "chat", []string{"user_id"}, manifests, redshift.CopyOptions{})
+	assert.EqualError(t, err, "dedup key column user_id isn't a column of chat")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// HasDropEvent returns whether the operations drop the table's event.
+func HasDropEvent(ops []scoop_protocol.Operation) bool {
+	for _, op := range ops {
+		if op.Action == scoop_protocol.DROP_EVENT {
+			return true
+		}
+	}
+	return false
+}
+
+// UnloadTable unloads the table's rows to gzipped files under the S3 prefix, with a manifest
+// listing them. It times out after timeoutMs if positive.
+func (r *RedshiftBackend) UnloadTable(table, s3Prefix string, timeoutMs int) error {
+	query := fmt.Sprintf("SELECT * FROM %s.%s", pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table))
+	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		if timeoutMs > 0 {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", timeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+		}
+		_, err := tx.Exec(fmt.Sprintf("UNLOAD (%s) TO %s WITH CREDENTIALS '%s' MANIFEST GZIP ALLOWOVERWRITE",
+			redshift.EscapePGString(query), redshift.EscapePGString(s3Prefix), redshift.CopyCredentials(r.credentials)))
+		if err != nil {
+			return fmt.Errorf("unloading %s: %v", table, err)
+		}
+		return nil
+	})
+}
+
+// DropTable drops the table and its views, and removes it from infra.table_version, so it's no
+// longer versioned. Dropping a table that's already dropped does nothing.
+func (r *RedshiftBackend) DropTable(table string) error {
+	lock := r.getTableLock(table)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(fmt.Sprintf("DROP VIEW IF EXISTS %s.%s CASCADE",
+			pq.QuoteIdentifier(r.viewSchema), pq.QuoteIdentifier(table)))
+		if err != nil {
+			return fmt.Errorf("dropping view: %v", err)
+		}
+		_, err = tx.Exec(fmt.Sprintf("DROP VIEW IF EXISTS %s.%s CASCADE",
+			pq.QuoteIdentifier(r.fullViewSchema), pq.QuoteIdentifier(table)))
+		if err != nil {
+			return fmt.Errorf("dropping full view: %v", err)
+		}
+		_, err = tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s CASCADE",
+			pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table)))
+		if err != nil {
+			return fmt.Errorf("dropping table: %v", err)
+		}
+		_, err = tx.Exec("DELETE FROM infra.table_version WHERE name = $1", table)
+		if err != nil {
+			return fmt.Errorf("removing from table_version in ace: %v", err)
+		}
+		return nil
+	})
+}
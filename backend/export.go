@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// exportWLMPoll is how often an export waiting for a free WLM slot checks again.
+const exportWLMPoll = 10 * time.Second
+
+// ErrNoExportPrefix is returned by exports when no export prefix is configured.
+var ErrNoExportPrefix = errors.New("no export prefix is configured")
+
+// ExportRequest asks for a snapshot of a table, or of its rows in a time range, as Parquet files.
+type ExportRequest struct {
+	// From and To, if set, bound TimeColumn: rows from From up to but excluding To are exported
+	From, To *time.Time
+	// TimeColumn is the column From and To bound; "time" if empty
+	TimeColumn string
+	// Name is the export's folder under the table's in the export prefix
+	Name string
+	// TimeoutMs times out the UNLOAD if positive
+	TimeoutMs int
+}
+
+// ExportStats is what an export unloaded.
+type ExportStats struct {
+	URL          string
+	RowsUnloaded int64
+	WLMWait      time.Duration
+	Duration     time.Duration
+}
+
+// exportURL returns where the request's export of the table goes.
+func (r *RedshiftBackend) exportURL(table string, req ExportRequest) string {
+	return fmt.Sprintf("%s/%s/%s/", strings.TrimSuffix(r.exportPrefix, "/"), table, req.Name)
+}
+
+// exportQuery returns the query selecting the request's rows of the table.
+func exportQuery(schema, table string, req ExportRequest) string {
+	query := fmt.Sprintf("SELECT * FROM %s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table))
+	column := req.TimeColumn
+	if column == "" {
+		column = "time"
+	}
+	var conditions []string
+	if req.From != nil {
+		conditions = append(conditions, fmt.Sprintf("%s >= %s", pq.QuoteIdentifier(column),
+			redshift.EscapePGString(req.From.UTC().Format("2006-01-02 15:04:05.999999"))))
+	}
+	if req.To != nil {
+		conditions = append(conditions, fmt.Sprintf("%s < %s", pq.QuoteIdentifier(column),
+			redshift.EscapePGString(req.To.UTC().Format("2006-01-02 15:04:05.999999"))))
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return query
+}
+
+// Export UNLOADs the request's rows of the table as Parquet files under the export prefix, in the
+// folder named by the request. Exports run one at a time, each waiting for a free slot in the
+// cluster's WLM queues before it starts, so they don't crowd out the loads' COPYs. The UNLOAD reads
+// a snapshot of the table, so loads into it carry on meanwhile.
+func (r *RedshiftBackend) Export(table string, req ExportRequest) (*ExportStats, error) {
+	if r.exportPrefix == "" {
+		return nil, ErrNoExportPrefix
+	}
+	r.exportLock.Lock()
+	defer r.exportLock.Unlock()
+
+	start := time.Now()
+	for {
+		state, err := r.WLMState()
+		if err != nil {
+			return nil, err
+		}
+		if !state.Saturated() {
+			break
+		}
+		logger.WithField("table", table).WithField("running", state.Running).WithField("queued", state.Queued).
+			Info("Export waiting for a free WLM slot")
+		time.Sleep(exportWLMPoll)
+	}
+	stats := &ExportStats{URL: r.exportURL(table, req), WLMWait: time.Since(start)}
+	unloadStart := time.Now()
+	err := r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		if req.TimeoutMs > 0 {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", req.TimeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+		}
+		_, err := tx.Exec(fmt.Sprintf("UNLOAD (%s) TO %s CREDENTIALS %s FORMAT AS PARQUET",
+			redshift.EscapePGString(exportQuery(r.tableSchema(table), table, req)),
+			redshift.EscapePGString(stats.URL), redshift.EscapePGString(redshift.CopyCredentials(r.credentials))))
+		if err != nil {
+			return fmt.Errorf("unloading %s: %v", table, err)
+		}
+		err = tx.QueryRow("SELECT pg_last_unload_count()").Scan(&stats.RowsUnloaded)
+		if err != nil {
+			return fmt.Errorf("getting unload count: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.Duration = time.Since(unloadStart)
+	return stats, nil
+}
@@ -0,0 +1,24 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportQuery(t *testing.T) {
+	assert.Equal(t, `SELECT * FROM "logs"."booking"`, exportQuery("logs", "booking", ExportRequest{}))
+
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(36 * time.Hour)
+	assert.Equal(t, `SELECT * FROM "logs"."booking" WHERE "time" >= '2026-10-01 00:00:00' AND "time" < '2026-10-02 12:00:00'`,
+		exportQuery("logs", "booking", ExportRequest{From: &from, To: &to}))
+	assert.Equal(t, `SELECT * FROM "logs"."booking" WHERE "server_time" < '2026-10-02 12:00:00'`,
+		exportQuery("logs", "booking", ExportRequest{To: &to, TimeColumn: "server_time"}))
+}
+
+func TestExportURL(t *testing.T) {
+	r := &RedshiftBackend{exportPrefix: "s3://bucket/exports/"}
+	assert.Equal(t, "s3://bucket/exports/booking/job/", r.exportURL("booking", ExportRequest{Name: "job"}))
+}
@@ -0,0 +1,250 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// PIIPolicy is how the values of a column flagged as personally identifiable information are loaded.
+type PIIPolicy string
+
+// The PII policies.
+const (
+	// PIIHash loads the SHA-256 of the value salted with the backend's PII hash key, as 64 hex
+	// characters, so rows can still be joined on it; the column must be a varchar of at least 64
+	PIIHash PIIPolicy = "hash"
+	// PIIRedact loads NULL in place of the value
+	PIIRedact PIIPolicy = "redact"
+	// PIIRestrict loads NULL in place of the value, and the raw rows into the table of the same name
+	// in the restricted schema, which only privileged users can read
+	PIIRestrict PIIPolicy = "restrict"
+)
+
+// PIIColumns maps a table's PII columns to their policies.
+type PIIColumns map[string]PIIPolicy
+
+// ParsePIIColumns parses the policies of a table's PII columns, as named in blueprint's metadata.
+func ParsePIIColumns(policies map[string]string) (PIIColumns, error) {
+	columns := make(PIIColumns, len(policies))
+	for column, policy := range policies {
+		switch p := PIIPolicy(strings.ToLower(strings.TrimSpace(policy))); p {
+		case PIIHash, PIIRedact, PIIRestrict:
+			columns[column] = p
+		default:
+			return nil, fmt.Errorf("column %s has unknown PII policy %q", column, policy)
+		}
+	}
+	return columns, nil
+}
+
+// restricted returns whether any of the columns are restricted.
+func (c PIIColumns) restricted() bool {
+	for _, policy := range c {
+		if policy == PIIRestrict {
+			return true
+		}
+	}
+	return false
+}
+
+// varcharLength matches the live type of a varchar column, capturing its length.
+var varcharLength = regexp.MustCompile(`^character varying\((\d+)\)$`)
+
+// piiExpression returns the expression selecting the staged column's value into a column of the live
+// type under the policy. Hashes are salted with the first argument of the INSERT.
+func piiExpression(column, liveType string, policy PIIPolicy) (string, error) {
+	name := pq.QuoteIdentifier(column)
+	switch policy {
+	case PIIHash:
+		m := varcharLength.FindStringSubmatch(liveType)
+		if m == nil {
+			return "", fmt.Errorf("hashed PII column %s is %s, not a varchar", column, liveType)
+		}
+		if n, _ := strconv.Atoi(m[1]); n < 64 {
+			return "", fmt.Errorf("hashed PII column %s is %s, too short for a hash of 64 characters", column, liveType)
+		}
+		return fmt.Sprintf("SHA2($1 || CAST(%s AS VARCHAR(65535)), 256)", name), nil
+	case PIIRedact, PIIRestrict:
+		return fmt.Sprintf("CAST(NULL AS %s)", liveType), nil
+	}
+	return "", fmt.Errorf("column %s has unknown PII policy %q", column, policy)
+}
+
+// PIICopy loads files of the table, whose columns are cols, with its PII columns transformed by their
+// policies. The manifests are COPYed into a staging table with cols, whose rows are then inserted into
+// the table with the PII columns hashed or NULLed, and the other columns cast to the table's types as
+// needed. If any column is restricted, the staged rows are first inserted as they are into the table
+// of the same name in the restricted schema, which is created with cols if it's missing. It's all one
+// transaction, which drops the staging table, so the raw values are never visible outside it.
+func (r *RedshiftBackend) PIICopy(ctx context.Context, table string, cols []scoop_protocol.ColumnDefinition,
+	manifestURLs []string, opts redshift.CopyOptions, pii PIIColumns) (*CopyStats, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("PII files of %s have no columns", table)
+	}
+	if pii.restricted() && r.piiRestrictedSchema == "" {
+		return nil, fmt.Errorf("%s has restricted PII columns, but no restricted schema is configured", table)
+	}
+	for column, policy := range pii {
+		if policy == PIIHash && r.piiHashKey == "" {
+			return nil, fmt.Errorf("%s hashes PII column %s, but no PII hash key is configured", table, column)
+		}
+	}
+	start := time.Now()
+	unlock, err := r.lockTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stats := &CopyStats{LockWait: time.Since(start)}
+	schema := r.tableSchema(table)
+	// The table lock keeps other loads from staging into the same table
+	staging := table + "_pii_staging"
+	defs := columnDefinitions(cols)
+	var queryIDs []int64
+	var copied time.Time
+	err = r.connection.ExecFnInTransactionContext(ctx, func(tx *sql.Tx) error {
+		copyStart := time.Now()
+		stats.RowsLoaded = 0
+		queryIDs = queryIDs[:0]
+		if opts.StatementTimeoutMs > 0 {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", opts.StatementTimeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+		}
+		_, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s.%s (%s)", pq.QuoteIdentifier(schema),
+			pq.QuoteIdentifier(staging), defs))
+		if err != nil {
+			return fmt.Errorf("creating staging table %s: %v", staging, err)
+		}
+		for _, manifestURL := range manifestURLs {
+			req := redshift.ManifestRowCopyRequest{
+				BuiltOn:     time.Now(),
+				Schema:      schema,
+				Name:        staging,
+				ManifestURL: manifestURL,
+				Credentials: redshift.CopyCredentials(r.credentials),
+				Options:     opts,
+			}
+			if err = req.TxExecContext(ctx, tx); err != nil {
+				return err
+			}
+			result, err := redshift.LastCopyResult(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("getting copy result: %v", err)
+			}
+			stats.RowsLoaded += result.RowsLoaded
+			queryIDs = append(queryIDs, result.QueryID)
+		}
+		if pii.restricted() {
+			if err = r.insertRestricted(tx, schema, staging, table, defs); err != nil {
+				return err
+			}
+		}
+		if err = r.insertTransformed(tx, schema, staging, table, pii); err != nil {
+			return err
+		}
+		_, err = tx.Exec(fmt.Sprintf("DROP TABLE %s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(staging)))
+		if err != nil {
+			return fmt.Errorf("dropping staging table %s: %v", staging, err)
+		}
+		copied = time.Now()
+		stats.CopyDuration = copied.Sub(copyStart)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.CommitDuration = time.Since(copied)
+	r.addScanned(table, queryIDs, stats)
+	return stats, nil
+}
+
+// insertRestricted inserts the staged rows as they are into the table of the same name in the restricted
+// schema, creating it with defs if it's missing. Columns it lacks are dropped.
+func (r *RedshiftBackend) insertRestricted(tx *sql.Tx, schema, staging, table, defs string) error {
+	_, err := tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s)", pq.QuoteIdentifier(r.piiRestrictedSchema),
+		pq.QuoteIdentifier(table), defs))
+	if err != nil {
+		return fmt.Errorf("creating restricted table %s: %v", table, err)
+	}
+	restrictedCols, err := liveColumns(tx, r.piiRestrictedSchema, table)
+	if err != nil {
+		return err
+	}
+	stagedCols, err := liveColumns(tx, schema, staging)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, col := range restrictedCols {
+		if liveType(stagedCols, col.Name) != "" {
+			names = append(names, pq.QuoteIdentifier(col.Name))
+		}
+	}
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s",
+		pq.QuoteIdentifier(r.piiRestrictedSchema), pq.QuoteIdentifier(table), strings.Join(names, ", "),
+		strings.Join(names, ", "), pq.QuoteIdentifier(schema), pq.QuoteIdentifier(staging)))
+	if err != nil {
+		return fmt.Errorf("inserting raw rows into restricted %s: %v", table, err)
+	}
+	return nil
+}
+
+// insertTransformed inserts the staged rows into the table's columns, with its PII columns transformed
+// by their policies and the others cast to the table's types as needed. Columns the table lacks are
+// dropped.
+func (r *RedshiftBackend) insertTransformed(tx *sql.Tx, schema, staging, table string, pii PIIColumns) error {
+	intoCols, err := liveColumns(tx, schema, table)
+	if err != nil {
+		return err
+	}
+	if len(intoCols) == 0 {
+		return fmt.Errorf("table %s doesn't exist", table)
+	}
+	stagedCols, err := liveColumns(tx, schema, staging)
+	if err != nil {
+		return err
+	}
+	var names, exprs []string
+	var args []interface{}
+	for _, col := range intoCols {
+		name := pq.QuoteIdentifier(col.Name)
+		stagedType := liveType(stagedCols, col.Name)
+		if stagedType == "" {
+			continue
+		}
+		if policy, ok := pii[col.Name]; ok {
+			expr, err := piiExpression(col.Name, col.Type, policy)
+			if err != nil {
+				return err
+			}
+			exprs = append(exprs, expr)
+			if policy == PIIHash && len(args) == 0 {
+				args = append(args, r.piiHashKey)
+			}
+		} else if stagedType == col.Type {
+			exprs = append(exprs, name)
+		} else {
+			exprs = append(exprs, fmt.Sprintf("CAST(%s AS %s)", name, col.Type))
+		}
+		names = append(names, name)
+	}
+	query := fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s", pq.QuoteIdentifier(schema),
+		pq.QuoteIdentifier(table), strings.Join(names, ", "), strings.Join(exprs, ", "),
+		pq.QuoteIdentifier(schema), pq.QuoteIdentifier(staging))
+	if _, err = tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("inserting transformed rows into %s: %v", table, err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestParsePIIColumns(t *testing.T) {
+	pii, err := ParsePIIColumns(map[string]string{"login": "Hash", "ip": " redact", "email": "restrict"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, PIIColumns{"login": PIIHash, "ip": PIIRedact, "email": PIIRestrict}, pii)
+		assert.True(t, pii.restricted())
+	}
+	assert.False(t, PIIColumns{"login": PIIHash}.restricted())
+
+	_, err = ParsePIIColumns(map[string]string{"login": "tokenize"})
+	assert.Error(t, err, "unknown policies fail the load")
+	_, err = ParsePIIColumns(map[string]string{"login": ""})
+	assert.Error(t, err)
+}
+
+func TestPIIExpression(t *testing.T) {
+	expr, err := piiExpression("login", "character varying(64)", PIIHash)
+	assert.NoError(t, err)
+	assert.Equal(t, `SHA2($1 || CAST("login" AS VARCHAR(65535)), 256)`, expr)
+
+	_, err = piiExpression("login", "character varying(32)", PIIHash)
+	assert.Error(t, err, "too short for the hash")
+	_, err = piiExpression("user_id", "bigint", PIIHash)
+	assert.Error(t, err)
+
+	expr, err = piiExpression("ip", "character varying(15)", PIIRedact)
+	assert.NoError(t, err)
+	assert.Equal(t, "CAST(NULL AS character varying(15))", expr)
+	expr, err = piiExpression("user_id", "bigint", PIIRestrict)
+	assert.NoError(t, err)
+	assert.Equal(t, "CAST(NULL AS bigint)", expr)
+}
+
+// mockBackend returns a RedshiftBackend of the logs schema on a stub database, and the stub's mock.
+func mockBackend(t *testing.T) (*RedshiftBackend, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	t.Cleanup(func() { _ = db.Close() })
+	return &RedshiftBackend{
+		connection:     &redshift.RSConnection{Conn: db, Breaker: &redshift.CircuitBreaker{}},
+		credentials:    credentials.NewStaticCredentials("id", "secret", ""),
+		tableLocks:     map[string]*sync.Mutex{},
+		lockLock:       &sync.Mutex{},
+		physicalSchema: "logs",
+		stats:          monitoring.NewMockStatter(),
+	}, mock
+}
+
+// expectLiveColumns expects liveColumns to read the table's columns, name then type.
+func expectLiveColumns(mock sqlmock.Sqlmock, schema, table string, cols ...string) {
+	rows := sqlmock.NewRows([]string{"column", "type"})
+	for i := 0; i < len(cols); i += 2 {
+		rows.AddRow(cols[i], cols[i+1])
+	}
+	mock.ExpectExec("SET LOCAL search_path").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM pg_table_def").WithArgs(schema, table).WillReturnRows(rows)
+}
+
+func TestInsertTransformed(t *testing.T) {
+	r, mock := mockBackend(t)
+	r.piiHashKey = "salt"
+	db := r.connection.Conn
+
+	mock.ExpectBegin()
+	expectLiveColumns(mock, "logs", "chat", "time", "timestamp without time zone", "login", "character varying(64)",
+		"ip", "character varying(15)", "user_id", "bigint", "added", "integer")
+	// The staged user_id is an int of an older version, and the table's added column wasn't staged
+	expectLiveColumns(mock, "logs", "chat_pii_staging", "time", "timestamp without time zone",
+		"login", "character varying(32)", "ip", "character varying(15)", "user_id", "integer")
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "logs"."chat" ("time", "login", "ip", "user_id") ` +
+		`SELECT "time", SHA2($1 || CAST("login" AS VARCHAR(65535)), 256), CAST(NULL AS character varying(15)), ` +
+		`CAST("user_id" AS bigint) FROM "logs"."chat_pii_staging"`)).
+		WithArgs("salt").WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectCommit()
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, r.insertTransformed(tx, "logs", "chat_pii_staging", "chat",
+		PIIColumns{"login": PIIHash, "ip": PIIRedact}))
+	assert.NoError(t, tx.Commit())
+
+	mock.ExpectBegin()
+	expectLiveColumns(mock, "logs", "chat")
+	mock.ExpectRollback()
+	tx, err = db.Begin()
+	assert.NoError(t, err)
+	assert.EqualError(t, r.insertTransformed(tx, "logs", "chat_pii_staging", "chat", nil), "table chat doesn't exist")
+	assert.NoError(t, tx.Rollback())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertRestricted(t *testing.T) {
+	r, mock := mockBackend(t)
+	r.piiRestrictedSchema = "restricted"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "restricted"."chat" ("login" varchar(64))`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// The restricted table has a column the staged version dropped
+	expectLiveColumns(mock, "restricted", "chat", "login", "character varying(64)", "dropped", "integer")
+	expectLiveColumns(mock, "logs", "chat_pii_staging", "login", "character varying(64)")
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "restricted"."chat" ("login") SELECT "login" FROM "logs"."chat_pii_staging"`)).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectCommit()
+	tx, err := r.connection.Conn.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, r.insertRestricted(tx, "logs", "chat_pii_staging", "chat", `"login" varchar(64)`))
+	assert.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPIICopy(t *testing.T) {
+	r, mock := mockBackend(t)
+	cols := []scoop_protocol.ColumnDefinition{{OutboundName: "login", Transformer: "varchar", ColumnCreationOptions: "(64)"}}
+	pii := PIIColumns{"login": PIIRestrict}
+	manifests := []string{"s3://bucket/a.json"}
+
+	_, err := r.PIICopy(context.Background(), "chat", cols, manifests, redshift.CopyOptions{}, pii)
+	assert.Error(t, err, "restricted columns need a restricted schema")
+	_, err = r.PIICopy(context.Background(), "chat", cols, manifests, redshift.CopyOptions{}, PIIColumns{"login": PIIHash})
+	assert.Error(t, err, "hashed columns need a hash key")
+
+	r.piiRestrictedSchema = "restricted"
+	mock.ExpectBegin()
+	// LOCAL, so the timeout ends with the transaction instead of staying on the pooled connection
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout TO 60000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE "logs"."chat_pii_staging" ("login" varchar(64))`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY "logs"."chat_pii_staging" FROM 's3://bucket/a.json'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT pg_last_copy_id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "count"}).AddRow(7, 10))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "restricted"."chat"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLiveColumns(mock, "restricted", "chat", "login", "character varying(64)")
+	expectLiveColumns(mock, "logs", "chat_pii_staging", "login", "character varying(64)")
+	mock.ExpectExec(`INSERT INTO "restricted"."chat"`).WillReturnResult(sqlmock.NewResult(0, 10))
+	expectLiveColumns(mock, "logs", "chat", "login", "character varying(64)")
+	expectLiveColumns(mock, "logs", "chat_pii_staging", "login", "character varying(64)")
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "logs"."chat" ("login") SELECT CAST(NULL AS character varying(64))`)).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE "logs"."chat_pii_staging"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	stats, err := r.PIICopy(context.Background(), "chat", cols, manifests,
+		redshift.CopyOptions{StatementTimeoutMs: 60000}, pii)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(10), stats.RowsLoaded)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE "logs"."chat_pii_staging"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`COPY "logs"."chat_pii_staging"`).WillReturnError(errors.New("S3ServiceException"))
+	mock.ExpectRollback()
+	_, err = r.PIICopy(context.Background(), "chat", cols, manifests, redshift.CopyOptions{}, pii)
+	assert.Error(t, err, "a failed COPY rolls back the staging table with it")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,257 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// Rebuild is the action of an operation changing a column's encode, distkey or sortkey, which
+// Redshift can't ALTER. The table is deep copied into a new table with the changes, which is
+// swapped in for the old one.
+//
+// Its action metadata can set `encode` to the column's new encoding, `distkey` to "true" to make
+// the column the table's DISTKEY or "false" to distribute the table evenly instead, and `sortkey`
+// to "true" to append the column to the table's compound SORTKEY or "false" to remove it.
+const Rebuild scoop_protocol.Action = "rebuild"
+
+const rebuildSuffix = "_rebuild"
+
+// HasRebuild returns whether any of the operations is a Rebuild.
+func HasRebuild(ops []scoop_protocol.Operation) bool {
+	for _, op := range ops {
+		if op.Action == Rebuild {
+			return true
+		}
+	}
+	return false
+}
+
+func rebuildTable(table string) string {
+	return table + rebuildSuffix
+}
+
+// tableColumn is a column of an existing table, as needed to recreate it.
+type tableColumn struct {
+	name     string
+	colType  string
+	encoding string
+	distKey  bool
+	// sortKey is the column's position in the compound sort key, or 0 if it's not in it
+	sortKey int
+	notNull bool
+}
+
+// tableColumns returns the table's columns in order. Tables with column defaults or an interleaved
+// sort key can't be recreated from their columns, so they're errors.
+func tableColumns(tx *sql.Tx, schema, table string) ([]tableColumn, error) {
+	// pg_table_def only shows tables in the search path.
+	_, err := tx.Exec(fmt.Sprintf("SET LOCAL search_path TO %s", pq.QuoteIdentifier(schema)))
+	if err != nil {
+		return nil, fmt.Errorf("setting search path: %v", err)
+	}
+	rows, err := tx.Query(`
+		SELECT c.column_name, d.type, d.encoding, d.distkey, d.sortkey, d.notnull, c.column_default IS NOT NULL
+		FROM information_schema.columns c
+		JOIN pg_table_def d
+		ON d.schemaname = c.table_schema AND d.tablename = c.table_name AND d."column" = c.column_name
+		WHERE c.table_schema = $1 AND c.table_name = $2
+		ORDER BY c.ordinal_position`, schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("querying columns: %v", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.WithError(err).Error("Error closing rows")
+		}
+	}()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var c tableColumn
+		var hasDefault bool
+		if err := rows.Scan(&c.name, &c.colType, &c.encoding, &c.distKey, &c.sortKey, &c.notNull, &hasDefault); err != nil {
+			return nil, fmt.Errorf("scanning columns: %v", err)
+		}
+		if hasDefault {
+			return nil, fmt.Errorf("column %s has a default, which a rebuild can't copy", c.name)
+		}
+		if c.sortKey < 0 {
+			return nil, fmt.Errorf("column %s is in an interleaved sort key, which a rebuild can't copy", c.name)
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading columns: %v", err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("found no columns")
+	}
+	return columns, nil
+}
+
+// applyRebuildOps returns the columns changed by the Rebuild operations.
+func applyRebuildOps(columns []tableColumn, ops []scoop_protocol.Operation) ([]tableColumn, error) {
+	changed := make([]tableColumn, len(columns))
+	copy(changed, columns)
+	index := make(map[string]int)
+	for i, c := range changed {
+		index[c.name] = i
+	}
+
+	for _, op := range ops {
+		if op.Action != Rebuild {
+			return nil, fmt.Errorf("rebuild operations can't be combined with action=%s operations", op.Action)
+		}
+		i, ok := index[op.Name]
+		if !ok {
+			return nil, fmt.Errorf("column %s doesn't exist", op.Name)
+		}
+		if encode, ok := op.ActionMetadata[columnEncode]; ok {
+			encode = strings.ToLower(strings.TrimSpace(encode))
+			if !encodings[encode] {
+				return nil, fmt.Errorf("column %s has unknown encoding %q", op.Name, encode)
+			}
+			changed[i].encoding = encode
+		}
+		if distKey, ok := op.ActionMetadata[columnDistKey]; ok {
+			set, err := strconv.ParseBool(distKey)
+			if err != nil {
+				return nil, fmt.Errorf("column %s has distkey %q; must be true or false", op.Name, distKey)
+			}
+			if set {
+				for j := range changed {
+					changed[j].distKey = false
+				}
+			}
+			changed[i].distKey = set
+		}
+		if sortKey, ok := op.ActionMetadata[columnSortKey]; ok {
+			set, err := strconv.ParseBool(sortKey)
+			if err != nil {
+				return nil, fmt.Errorf("column %s has sortkey %q; must be true or false", op.Name, sortKey)
+			}
+			if !set {
+				changed[i].sortKey = 0
+			} else if changed[i].sortKey == 0 {
+				last := 0
+				for _, c := range changed {
+					if c.sortKey > last {
+						last = c.sortKey
+					}
+				}
+				changed[i].sortKey = last + 1
+			}
+		}
+	}
+	return changed, nil
+}
+
+// rebuildDDL returns the CREATE TABLE statement for the columns.
+func rebuildDDL(quotedSchema, quotedTable string, columns []tableColumn) string {
+	var defs, sortKeys []string
+	var sorted []tableColumn
+	distKey := ""
+	for _, c := range columns {
+		def := fmt.Sprintf("%s %s ENCODE %s", pq.QuoteIdentifier(c.name), c.colType, c.encoding)
+		if c.notNull {
+			def += " NOT NULL"
+		}
+		defs = append(defs, def)
+		if c.distKey {
+			distKey = pq.QuoteIdentifier(c.name)
+		}
+		if c.sortKey > 0 {
+			sorted = append(sorted, c)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].sortKey < sorted[j].sortKey })
+	for _, c := range sorted {
+		sortKeys = append(sortKeys, pq.QuoteIdentifier(c.name))
+	}
+
+	ddl := fmt.Sprintf("CREATE TABLE %s.%s (%s)", quotedSchema, quotedTable, strings.Join(defs, ", "))
+	if distKey != "" {
+		ddl += fmt.Sprintf(" DISTSTYLE KEY DISTKEY (%s)", distKey)
+	} else {
+		ddl += " DISTSTYLE EVEN"
+	}
+	if len(sortKeys) > 0 {
+		ddl += fmt.Sprintf(" COMPOUND SORTKEY (%s)", strings.Join(sortKeys, ", "))
+	}
+	return ddl
+}
+
+// prepareRebuild deep copies the table into its rebuild table with the Rebuild operations' changes,
+// to be swapped in by swapRebuiltTable. The caller must hold the table lock so no loads happen while
+// copying.
+func (r *RedshiftBackend) prepareRebuild(table string, ops []scoop_protocol.Operation, timeoutMs int) error {
+	if !HasRebuild(ops) {
+		return nil
+	}
+	schema := pq.QuoteIdentifier(r.tableSchema(table))
+	rebuilt := pq.QuoteIdentifier(rebuildTable(table))
+	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", timeoutMs))
+		if err != nil {
+			return fmt.Errorf("setting timeout: %v", err)
+		}
+		columns, err := tableColumns(tx, r.tableSchema(table), table)
+		if err != nil {
+			return fmt.Errorf("reading columns of %s: %v", table, err)
+		}
+		columns, err = applyRebuildOps(columns, ops)
+		if err != nil {
+			return err
+		}
+
+		// A rebuild table is left behind by a copy that failed to commit.
+		_, err = tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", schema, rebuilt))
+		if err != nil {
+			return fmt.Errorf("dropping leftover rebuild table: %v", err)
+		}
+		_, err = tx.Exec(rebuildDDL(schema, rebuilt, columns))
+		if err != nil {
+			return fmt.Errorf("creating rebuild table: %v", err)
+		}
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = pq.QuoteIdentifier(c.name)
+		}
+		list := strings.Join(names, ", ")
+		logger.WithField("table", table).Info("Deep copying table to rebuild it")
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s",
+			schema, rebuilt, list, list, schema, pq.QuoteIdentifier(table)))
+		if err != nil {
+			return fmt.Errorf("copying rows into rebuild table: %v", err)
+		}
+		return nil
+	})
+}
+
+// swapRebuiltTable replaces the table with its rebuild table. Runs inside the migration's
+// transaction, after the table's views are dropped.
+func swapRebuiltTable(quotedSchema string, table string, tx *sql.Tx) error {
+	quotedTable := pq.QuoteIdentifier(table)
+	old := table + "_old"
+	_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s.%s RENAME TO %s", quotedSchema, quotedTable, pq.QuoteIdentifier(old)))
+	if err != nil {
+		return fmt.Errorf("renaming old table: %v", err)
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s.%s RENAME TO %s",
+		quotedSchema, pq.QuoteIdentifier(rebuildTable(table)), quotedTable))
+	if err != nil {
+		return fmt.Errorf("renaming rebuild table: %v", err)
+	}
+	_, err = tx.Exec(fmt.Sprintf("DROP TABLE %s.%s CASCADE", quotedSchema, pq.QuoteIdentifier(old)))
+	if err != nil {
+		return fmt.Errorf("dropping old table: %v", err)
+	}
+	return nil
+}
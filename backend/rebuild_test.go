@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func rebuildColumn(name string, metadata map[string]string) scoop_protocol.Operation {
+	return scoop_protocol.Operation{Action: Rebuild, Name: name, ActionMetadata: metadata}
+}
+
+func TestApplyRebuildOps(t *testing.T) {
+	columns := []tableColumn{
+		{name: "time", colType: "timestamp without time zone", encoding: "az64", sortKey: 1},
+		{name: "user_id", colType: "bigint", encoding: "az64", distKey: true},
+		{name: "channel", colType: "character varying(64)", encoding: "lzo", notNull: true},
+	}
+	changed, err := applyRebuildOps(columns, []scoop_protocol.Operation{
+		rebuildColumn("channel", map[string]string{"encode": "ZSTD", "distkey": "true", "sortkey": "true"}),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []tableColumn{
+		{name: "time", colType: "timestamp without time zone", encoding: "az64", sortKey: 1},
+		{name: "user_id", colType: "bigint", encoding: "az64"},
+		{name: "channel", colType: "character varying(64)", encoding: "zstd", distKey: true, sortKey: 2, notNull: true},
+	}, changed)
+	assert.True(t, columns[1].distKey, "the original columns are unchanged")
+
+	assert.Equal(t, `CREATE TABLE "logs"."chat_rebuild" (`+
+		`"time" timestamp without time zone ENCODE az64, "user_id" bigint ENCODE az64, `+
+		`"channel" character varying(64) ENCODE zstd NOT NULL) `+
+		`DISTSTYLE KEY DISTKEY ("channel") COMPOUND SORTKEY ("time", "channel")`,
+		rebuildDDL(`"logs"`, `"chat_rebuild"`, changed))
+
+	changed, err = applyRebuildOps(columns, []scoop_protocol.Operation{
+		rebuildColumn("user_id", map[string]string{"distkey": "false"}),
+		rebuildColumn("time", map[string]string{"sortkey": "false"}),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `CREATE TABLE "logs"."chat_rebuild" (`+
+		`"time" timestamp without time zone ENCODE az64, "user_id" bigint ENCODE az64, `+
+		`"channel" character varying(64) ENCODE lzo NOT NULL) DISTSTYLE EVEN`,
+		rebuildDDL(`"logs"`, `"chat_rebuild"`, changed))
+
+	_, err = applyRebuildOps(columns, []scoop_protocol.Operation{rebuildColumn("missing", nil)})
+	assert.NotNil(t, err)
+	_, err = applyRebuildOps(columns, []scoop_protocol.Operation{
+		rebuildColumn("time", map[string]string{"encode": "lz4; DROP TABLE x"})})
+	assert.NotNil(t, err)
+	_, err = applyRebuildOps(columns, []scoop_protocol.Operation{
+		rebuildColumn("time", nil), addColumn("new", nil)})
+	assert.NotNil(t, err, "rebuilds can't be combined with other operations")
+}
+
+func TestTableColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	// LOCAL, so later transactions on the connection keep the default search path
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL search_path TO "logs"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM information_schema.columns").WithArgs("logs", "chat").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "type", "encoding", "distkey", "sortkey", "notnull", "default"}).
+			AddRow("time", "timestamp without time zone", "none", false, 1, true, false).
+			AddRow("login", "character varying(64)", "lzo", true, 0, false, false))
+	mock.ExpectRollback()
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	cols, err := tableColumns(tx, "logs", "chat")
+	assert.NoError(t, err)
+	assert.Equal(t, []tableColumn{
+		{name: "time", colType: "timestamp without time zone", encoding: "none", sortKey: 1, notNull: true},
+		{name: "login", colType: "character varying(64)", encoding: "lzo", distKey: true},
+	}, cols)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
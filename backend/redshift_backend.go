@@ -2,6 +2,7 @@ package backend
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/lib/pq"
 	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
 	"github.com/twitchscience/rs_ingester/redshift"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
@@ -39,6 +41,21 @@ type RedshiftBackend struct {
 	viewFilter           string
 	fullViewSchema       string
 	fullViewReplacements map[string]string
+	typeChanges          typeChangeTracker
+	compression          compressionTracker
+	schemaOverrides      SchemaOverrides
+	canary               CanaryConfig
+	piiRestrictedSchema  string
+	piiHashKey           string
+	exportPrefix         string
+	exportLock           sync.Mutex
+	stats                monitoring.SafeStatter
+}
+
+// SchemaOverrides gives the schema of tables that don't live in the physical schema
+type SchemaOverrides interface {
+	// TargetSchema returns the table's schema, or "" for the physical schema
+	TargetSchema(table string) string
 }
 
 // Config is used to configure the behavior of the RedshiftBackend
@@ -50,11 +67,26 @@ type Config struct {
 	FullViewSchema       string            `json:"fullViewSchema"`
 	FullViewReplacements map[string]string `json:"fullViewReplacements"`
 	URL                  string            `json:"url"`
+	// Transport is how Redshift is reached: redshift.PostgresTransport (the default), with URL a
+	// postgres URL, or redshift.DataAPITransport, with URL a Data API DSN
+	Transport string `json:"transport"`
+	// Canary has some manifests of the given tables also loaded into canary copies of them
+	Canary CanaryConfig `json:"canary"`
+	// PIIRestrictedSchema is where the raw rows of tables with restricted PII columns are loaded
+	PIIRestrictedSchema string `json:"piiRestrictedSchema"`
+	// PIIHashKey salts the hashes of hashed PII columns
+	PIIHashKey string `json:"piiHashKey"`
+	// ExportPrefix is the S3 URL, e.g. s3://bucket/exports, under which tables are exported
+	ExportPrefix string `json:"exportPrefix"`
 }
 
-//BuildRedshiftBackend builds a new redshift backend by also creating a new rsConnection
-func BuildRedshiftBackend(credentials *credentials.Credentials, poolSize int, config *Config) (*RedshiftBackend, error) {
-	conn, err := redshift.BuildRSConnection(config.URL, poolSize)
+//BuildRedshiftBackend builds a new redshift backend by also creating a new rsConnection.
+//schemaOverrides may be nil, in which case all tables are in the configured physical schema.
+func BuildRedshiftBackend(credentials *credentials.Credentials, poolSize int, config *Config,
+	breakerConfig redshift.BreakerConfig, keepaliveConfig redshift.KeepaliveConfig, stats monitoring.SafeStatter,
+	schemaOverrides SchemaOverrides) (*RedshiftBackend, error) {
+	conn, err := redshift.BuildRSConnection(config.Transport, config.URL, poolSize, breakerConfig, keepaliveConfig,
+		stats)
 	if err != nil {
 		return nil, err
 	}
@@ -72,40 +104,260 @@ func BuildRedshiftBackend(credentials *credentials.Credentials, poolSize int, co
 		viewFilter:           config.ViewFilter,
 		fullViewSchema:       config.FullViewSchema,
 		fullViewReplacements: config.FullViewReplacements,
+		schemaOverrides:      schemaOverrides,
+		canary:               config.Canary,
+		piiRestrictedSchema:  config.PIIRestrictedSchema,
+		piiHashKey:           config.PIIHashKey,
+		exportPrefix:         config.ExportPrefix,
+		stats:                stats,
 	}, nil
 }
 
+// tableSchema returns the schema the table lives in.
+func (r *RedshiftBackend) tableSchema(table string) string {
+	if r.schemaOverrides != nil {
+		if schema := r.schemaOverrides.TargetSchema(table); schema != "" {
+			return schema
+		}
+	}
+	return r.physicalSchema
+}
+
 //HealthCheck makes sure that redshift is reachable
 func (r *RedshiftBackend) HealthCheck() error {
 	err := r.connection.Conn.Ping()
 	return err
 }
 
-//ManifestCopy makes a ManifestRowCopyRequest and returns the function that executes the request
-func (r *RedshiftBackend) ManifestCopy(rc *scoop_protocol.ManifestRowCopyRequest) error {
-	lock := r.getTableLock(rc.TableName)
-	lock.Lock()
-	defer lock.Unlock()
+//WaitUntilAvailable blocks while the redshift circuit breaker is open
+func (r *RedshiftBackend) WaitUntilAvailable() {
+	r.connection.Breaker.Wait()
+}
+
+//ManifestCopy COPYs each of the manifests into the table in one transaction, and returns how much was loaded.
+//The manifests of a canaried table may then also be COPYed into its canary copy.
+func (r *RedshiftBackend) ManifestCopy(ctx context.Context, table string, manifestURLs []string,
+	opts redshift.CopyOptions) (*CopyStats, error) {
+	stats, err := r.lockedManifestCopy(ctx, r.tableSchema(table), table, manifestURLs, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.canaryCopy(ctx, table, manifestURLs, opts)
+	return stats, nil
+}
+
+// ChunkedManifestCopy COPYs each of the manifests into the table in a transaction of its own, up to
+// parallelism at once, holding the table's lock throughout. done is called with each manifest's index and
+// stats once it's committed, or its error if it failed, from the goroutine that COPYed it; the other
+// manifests are COPYed regardless. Committed manifests of a canaried table may also be COPYed into its
+// canary copy.
+func (r *RedshiftBackend) ChunkedManifestCopy(ctx context.Context, table string, manifestURLs []string,
+	opts redshift.CopyOptions, parallelism int, done func(chunk int, stats *CopyStats, err error)) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	start := time.Now()
+	unlock, err := r.lockTable(ctx, table)
+	if err != nil {
+		for i := range manifestURLs {
+			done(i, nil, err)
+		}
+		return
+	}
+	defer unlock()
+	lockWait := time.Since(start)
+
+	schema := r.tableSchema(table)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for i, manifestURL := range manifestURLs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk int, manifestURL string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stats := &CopyStats{LockWait: lockWait}
+			queryIDs, err := r.copyManifests(ctx, schema, table, []string{manifestURL}, opts, stats)
+			if err != nil {
+				done(chunk, nil, err)
+				return
+			}
+			r.addScanned(table, queryIDs, stats)
+			r.canaryCopy(ctx, table, []string{manifestURL}, opts)
+			done(chunk, stats, nil)
+		}(i, manifestURL)
+	}
+	wg.Wait()
+}
+
+// lockedManifestCopy COPYs the manifests into the table in the schema while holding the table's lock,
+// and returns how much was loaded.
+func (r *RedshiftBackend) lockedManifestCopy(ctx context.Context, schema, table string, manifestURLs []string,
+	opts redshift.CopyOptions) (*CopyStats, error) {
+	start := time.Now()
+	unlock, err := r.lockTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stats := &CopyStats{LockWait: time.Since(start)}
+	queryIDs, err := r.copyManifests(ctx, schema, table, manifestURLs, opts, stats)
+	if err != nil {
+		return nil, err
+	}
+	r.addScanned(table, queryIDs, stats)
+	return stats, nil
+}
+
+// addScanned adds the bytes and lines scanned by the committed COPYs, and how their transaction's commit
+// went, to stats. The COPYs are committed, so failing to get these is only logged.
+func (r *RedshiftBackend) addScanned(table string, queryIDs []int64, stats *CopyStats) {
+	for _, queryID := range queryIDs {
+		bytes, err := redshift.CopyBytesScanned(r.connection.Conn, queryID)
+		if err != nil {
+			logger.WithError(err).WithField("table", table).WithField("queryID", queryID).
+				Warn("Error getting bytes scanned by COPY")
+			continue
+		}
+		stats.BytesScanned += bytes
+
+		lines, err := redshift.CopyLinesScanned(r.connection.Conn, queryID)
+		if err != nil {
+			logger.WithError(err).WithField("table", table).WithField("queryID", queryID).
+				Warn("Error getting lines scanned by COPY")
+			continue
+		}
+		stats.LinesScanned += lines
+	}
+	if len(queryIDs) == 0 {
+		return
+	}
+	// The COPYs share a transaction, so any of them finds its commit
+	commit, err := redshift.CopyCommitStats(r.connection.Conn, queryIDs[0])
+	if err == sql.ErrNoRows {
+		// Redshift records commits a moment after they're made, so it often hasn't yet
+		return
+	}
+	if err != nil {
+		logger.WithError(err).WithField("table", table).WithField("queryID", queryIDs[0]).
+			Warn("Error getting commit stats of COPY")
+		return
+	}
+	stats.CommitQueueWait = commit.QueueWait
+	stats.CommitExecution = commit.Execution
+}
 
-	return r.connection.ExecFnInTransaction(redshift.ManifestRowCopyRequest{
-		BuiltOn:     time.Now(),
-		Schema:      r.physicalSchema,
-		Name:        rc.TableName,
-		ManifestURL: rc.ManifestURL,
-		Credentials: redshift.CopyCredentials(r.credentials),
-	}.TxExec)
+// copyManifests COPYs each of the manifests into the table in the schema in one transaction, recording
+// the rows loaded and how long the COPYs and commit took in stats, and returns the COPYs' query IDs.
+func (r *RedshiftBackend) copyManifests(ctx context.Context, schema, table string, manifestURLs []string,
+	opts redshift.CopyOptions, stats *CopyStats) ([]int64, error) {
+	var queryIDs []int64
+	var copied time.Time
+	err := r.connection.ExecFnInTransactionContext(ctx, func(tx *sql.Tx) error {
+		copyStart := time.Now()
+		stats.RowsLoaded = 0
+		queryIDs = queryIDs[:0]
+		if opts.StatementTimeoutMs > 0 {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", opts.StatementTimeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+		}
+		for _, manifestURL := range manifestURLs {
+			req := redshift.ManifestRowCopyRequest{
+				BuiltOn:     time.Now(),
+				Schema:      schema,
+				Name:        table,
+				ManifestURL: manifestURL,
+				Credentials: redshift.CopyCredentials(r.credentials),
+				Options:     opts,
+			}
+			err := req.TxExecContext(ctx, tx)
+			if err != nil {
+				return err
+			}
+			result, err := redshift.LastCopyResult(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("getting copy result: %v", err)
+			}
+			stats.RowsLoaded += result.RowsLoaded
+			queryIDs = append(queryIDs, result.QueryID)
+		}
+		copied = time.Now()
+		stats.CopyDuration = copied.Sub(copyStart)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.CommitDuration = time.Since(copied)
+	return queryIDs, nil
 }
 
 //LoadCheck makes a LoadCheckRequest and returns the response of the load check
 func (r *RedshiftBackend) LoadCheck(req *scoop_protocol.LoadCheckRequest) (*scoop_protocol.LoadCheckResponse, error) {
 	resp := &scoop_protocol.LoadCheckResponse{ManifestURL: req.ManifestURL}
 	err := r.connection.ExecFnInTransaction(func(t *sql.Tx) (err error) {
-		resp.LoadStatus, err = redshift.CheckLoadStatus(t, req.ManifestURL)
+		resp.LoadStatus, err = redshift.CheckLoadStatus(t, req.ManifestURL, r.canary.excludedSchema())
 		return
 	})
 	return resp, err
 }
 
+// CancelLoad cancels the running COPYs of the load, returning how many it canceled.
+func (r *RedshiftBackend) CancelLoad(manifestUUID string) (int, error) {
+	var canceled int
+	err := r.connection.ExecFnInTransaction(func(t *sql.Tx) (err error) {
+		canceled, err = redshift.CancelCopies(t, manifestUUID)
+		return
+	})
+	return canceled, err
+}
+
+// Query queues a read-only query of a system table for the connection's listeners and waits up to
+// timeout for its rows.
+func (r *RedshiftBackend) Query(q redshift.Query, timeout time.Duration) (*redshift.Table, error) {
+	if _, _, err := q.SQL(); err != nil {
+		return nil, err
+	}
+	// Buffered so the listener never blocks responding to a request we gave up on.
+	req := &redshift.QueryRequest{Query: q, Start: time.Now(), Response: make(chan *redshift.Table, 1)}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r.connection.InboundRequests <- req:
+	case <-timer.C:
+		return nil, fmt.Errorf("waiting for a redshift listener to accept query")
+	}
+	select {
+	case table := <-req.Response:
+		return table, table.Err
+	case <-timer.C:
+		return nil, fmt.Errorf("waiting for redshift to answer query")
+	}
+}
+
+// PoolStats returns the stats of the Redshift connection pool.
+func (r *RedshiftBackend) PoolStats() sql.DBStats {
+	return r.connection.Conn.Stats()
+}
+
+// SetMaxConnections changes the size of the Redshift connection pool, e.g. when workers are added.
+func (r *RedshiftBackend) SetMaxConnections(maxConnections int) {
+	r.connection.SetMaxConnections(maxConnections)
+}
+
+// RunningCopies returns how many COPYs are running in Redshift, including those of other loaders.
+func (r *RedshiftBackend) RunningCopies() (int, error) {
+	return redshift.RunningCopies(r.connection.Conn)
+}
+
+// WLMState returns how busy the cluster's WLM queues are, including with other loaders' queries.
+func (r *RedshiftBackend) WLMState() (redshift.WLMState, error) {
+	return redshift.QueryWLMState(r.connection.Conn)
+}
+
 // TableVersions returns the event tables with version numbers
 func (r *RedshiftBackend) TableVersions() (map[string]int, error) {
 	versions := make(map[string]int)
@@ -140,25 +392,31 @@ func parseFunctionalType(s string) (string, bool) {
 	return "", false
 }
 
-func (m *migrationStep) getCreationForm() string {
+// getColumnType returns the redshift type of the column, translating transformer types.
+func (m *migrationStep) getColumnType() string {
 	tranType, isTranslated := transformerTypeMap[m.ActionMetadata["column_type"]]
 	funcType, isFunc := parseFunctionalType(m.ActionMetadata["column_type"])
 
-	var colType string
 	if isTranslated {
-		colType = tranType
+		return tranType
 	} else if isFunc {
-		colType = funcType
-	} else {
-		colType = m.ActionMetadata["column_type"]
+		return funcType
 	}
+	return m.ActionMetadata["column_type"]
+}
 
+// getCreationForm returns the column's definition, for either CREATE TABLE or ADD COLUMN.
+func (m *migrationStep) getCreationForm(creatingTable bool) (string, error) {
 	maybeColOpts := ""
 	if len(m.ActionMetadata["column_options"]) > 1 {
 		maybeColOpts = m.ActionMetadata["column_options"]
 	}
+	attrs, err := m.getColumnAttributes(creatingTable)
+	if err != nil {
+		return "", err
+	}
 
-	return fmt.Sprintf("%s %s%s", pq.QuoteIdentifier(m.Name), colType, maybeColOpts)
+	return fmt.Sprintf("%s %s%s%s", pq.QuoteIdentifier(m.Name), m.getColumnType(), maybeColOpts, attrs), nil
 }
 
 // expectVersion checks to see if the version in infra.table_version is what was
@@ -189,8 +447,12 @@ func applyOperation(op scoop_protocol.Operation, quotedSchema string, quotedTabl
 	switch op.Action {
 	case scoop_protocol.ADD:
 		mStep := migrationStep(op)
-		query := fmt.Sprintf("ALTER TABLE %s.%s ADD COLUMN %s",
-			quotedSchema, quotedTable, mStep.getCreationForm())
+		var column string
+		column, err = mStep.getCreationForm(false)
+		if err != nil {
+			return err
+		}
+		query := fmt.Sprintf("ALTER TABLE %s.%s ADD COLUMN %s", quotedSchema, quotedTable, column)
 		_, err = tx.Exec(query)
 	case scoop_protocol.DELETE:
 		query := fmt.Sprintf("ALTER TABLE %s.%s DROP COLUMN %s CASCADE",
@@ -202,6 +464,8 @@ func applyOperation(op scoop_protocol.Operation, quotedSchema string, quotedTabl
 			pq.QuoteIdentifier(op.ActionMetadata["new_outbound"]),
 		)
 		_, err = tx.Exec(query)
+	case ChangeType: // swapped in once for all of a migration's type change operations
+	case Rebuild: // swapped in once for all of a migration's rebuild operations
 	case scoop_protocol.REQUEST_DROP_EVENT:
 	case scoop_protocol.DROP_EVENT:
 	case scoop_protocol.CANCEL_DROP_EVENT:
@@ -218,6 +482,19 @@ func (r *RedshiftBackend) ApplyOperations(table string, ops []scoop_protocol.Ope
 	lock.Lock()
 	defer lock.Unlock()
 
+	canary, err := r.hasCanaryCopy(table)
+	if err != nil {
+		return err
+	}
+	err = r.prepareRebuild(table, ops, timeoutMs)
+	if err != nil {
+		return fmt.Errorf("rebuilding %s: %v", table, err)
+	}
+	err = r.prepareTypeChange(table, ops, timeoutMs)
+	if err != nil {
+		return fmt.Errorf("changing column types of %s: %v", table, err)
+	}
+
 	cvs := r.buildCreateViewString(table, cols)
 	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
 		err := expectVersion(tx, table, targetVersion-1)
@@ -225,11 +502,17 @@ func (r *RedshiftBackend) ApplyOperations(table string, ops []scoop_protocol.Ope
 			return err
 		}
 		// set time out for the migration
-		query := fmt.Sprintf("SET statement_timeout TO %d", timeoutMs)
+		query := fmt.Sprintf("SET LOCAL statement_timeout TO %d", timeoutMs)
 		_, err = tx.Exec(query)
 		if err != nil {
 			return fmt.Errorf("setting timeout: %v", err)
 		}
+		if canary && len(ops) > 0 {
+			err = r.migrateCanary(tx, table, ops)
+			if err != nil {
+				return err
+			}
+		}
 		if ops != nil {
 			_, err = tx.Exec(fmt.Sprintf(`DROP VIEW %s.%s CASCADE`,
 				pq.QuoteIdentifier(r.viewSchema), pq.QuoteIdentifier(table)))
@@ -241,8 +524,21 @@ func (r *RedshiftBackend) ApplyOperations(table string, ops []scoop_protocol.Ope
 			if err != nil {
 				return fmt.Errorf("dropping full view: %v", err)
 			}
+			// The other operations apply to the table with its types changed
+			if HasTypeChange(ops) {
+				err = swapRebuiltTable(pq.QuoteIdentifier(r.tableSchema(table)), table, tx)
+				if err != nil {
+					return err
+				}
+			}
 			for _, op := range ops {
-				err = applyOperation(op, pq.QuoteIdentifier(r.physicalSchema), pq.QuoteIdentifier(table), tx)
+				err = applyOperation(op, pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table), tx)
+				if err != nil {
+					return err
+				}
+			}
+			if HasRebuild(ops) {
+				err = swapRebuiltTable(pq.QuoteIdentifier(r.tableSchema(table)), table, tx)
 				if err != nil {
 					return err
 				}
@@ -264,10 +560,11 @@ func (r *RedshiftBackend) ApplyOperations(table string, ops []scoop_protocol.Ope
 type newTable []scoop_protocol.Operation
 
 //buildNewTable creates a newTable from a list of Operations and checks that all the operations
-//are add column operations
+//are add column operations, with valid column attributes and at most one distkey and sortkey
 func buildNewTable(ops []scoop_protocol.Operation) (newTable, error) {
+	var distKeys, sortKeys int
 	for _, op := range ops {
-		// If we have a DROP_EVENT, treat it as a no-op.
+		// A DROP_EVENT tears the table down rather than creating it; see the migrator.
 		if op.Action == scoop_protocol.DROP_EVENT {
 			return nil, nil
 		}
@@ -279,22 +576,40 @@ func buildNewTable(ops []scoop_protocol.Operation) (newTable, error) {
 		if !cOptions || !cType {
 			return nil, fmt.Errorf("newTable must have actionmetadata including 'column_options' and 'column_type'")
 		}
+		step := migrationStep(op)
+		if _, err := step.getColumnAttributes(true); err != nil {
+			return nil, err
+		}
+		if step.isSet(columnDistKey) {
+			distKeys++
+		}
+		if step.isSet(columnSortKey) {
+			sortKeys++
+		}
+	}
+	if distKeys > 1 || sortKeys > 1 {
+		return nil, fmt.Errorf("newTable can have at most one distkey and one sortkey column, got %d and %d",
+			distKeys, sortKeys)
 	}
 	return newTable(ops), nil
 }
 
-func (n *newTable) getColumnCreationString() string {
+func (n *newTable) getColumnCreationString() (string, error) {
 	out := bytes.NewBuffer(make([]byte, 0, 256))
 	_, _ = out.WriteRune('(') // WriteRune and WriteString error always nil
 	for i, op := range *n {
 		step := migrationStep(op)
-		_, _ = out.WriteString(step.getCreationForm())
+		column, err := step.getCreationForm(true)
+		if err != nil {
+			return "", err
+		}
+		_, _ = out.WriteString(column)
 		if i+1 != len(*n) {
 			_, _ = out.WriteRune(',')
 		}
 	}
 	_, _ = out.WriteRune(')')
-	return out.String()
+	return out.String(), nil
 }
 
 func (r *RedshiftBackend) buildCreateViewString(table string, tableCols []scoop_protocol.ColumnDefinition) string {
@@ -313,14 +628,14 @@ func (r *RedshiftBackend) buildCreateViewString(table string, tableCols []scoop_
 		fullCVS = fmt.Sprintf(`CREATE VIEW %s.%s AS SELECT %s FROM %s.%s`,
 			pq.QuoteIdentifier(r.fullViewSchema), pq.QuoteIdentifier(table),
 			strings.Join(fullViewCols, ", "),
-			pq.QuoteIdentifier(r.physicalSchema), pq.QuoteIdentifier(table))
+			pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table))
 	}
 	return fmt.Sprintf(`CREATE VIEW %s.%s AS SELECT * FROM %s.%s %s; %s`,
 		pq.QuoteIdentifier(r.viewSchema), pq.QuoteIdentifier(table),
-		pq.QuoteIdentifier(r.physicalSchema), pq.QuoteIdentifier(table), viewFilter, fullCVS)
+		pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table), viewFilter, fullCVS)
 }
 
-//CreateTable creates a table at logs.`table` with the columns in ops unless the ops have DROP_EVENT.
+//CreateTable creates a table at <table schema>.`table` with the columns in ops unless the ops have DROP_EVENT.
 func (r *RedshiftBackend) CreateTable(table string, ops []scoop_protocol.Operation,
 	cols []scoop_protocol.ColumnDefinition, version int) error {
 	newTable, err := buildNewTable(ops)
@@ -328,10 +643,14 @@ func (r *RedshiftBackend) CreateTable(table string, ops []scoop_protocol.Operati
 	if err != nil || newTable == nil {
 		return err
 	}
+	columns, err := newTable.getColumnCreationString()
+	if err != nil {
+		return err
+	}
 	cvs := r.buildCreateViewString(table, cols)
 	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
-		query := fmt.Sprintf(`CREATE TABLE %s.%s%s;`, pq.QuoteIdentifier(r.physicalSchema),
-			pq.QuoteIdentifier(table), newTable.getColumnCreationString())
+		query := fmt.Sprintf(`CREATE TABLE %s.%s%s;`, pq.QuoteIdentifier(r.tableSchema(table)),
+			pq.QuoteIdentifier(table), columns)
 		_, err = tx.Exec(query)
 		if err != nil {
 			return fmt.Errorf("CREATEing TABLE %s: %v", table, err)
@@ -349,8 +668,13 @@ func (r *RedshiftBackend) CreateTable(table string, ops []scoop_protocol.Operati
 	})
 }
 
-// TableExists returns whether the given table exists in the physical schema.
+// TableExists returns whether the given table exists in its schema.
 func (r *RedshiftBackend) TableExists(table string) (bool, error) {
+	return r.tableExistsIn(r.tableSchema(table), table)
+}
+
+// tableExistsIn returns whether the given table exists in the given schema.
+func (r *RedshiftBackend) tableExistsIn(schema, table string) (bool, error) {
 	query := `SELECT EXISTS (
 		SELECT 1
 		FROM pg_catalog.pg_class
@@ -361,7 +685,7 @@ func (r *RedshiftBackend) TableExists(table string) (bool, error) {
 			AND pg_class.relkind = 'r'    -- ordinary table
 	)`
 	var exists bool
-	err := r.connection.Conn.QueryRow(query, r.physicalSchema, table).Scan(&exists)
+	err := r.connection.Conn.QueryRow(query, schema, table).Scan(&exists)
 	switch {
 	case err != nil:
 		return false, fmt.Errorf("querying whether table exists: %v", err)
@@ -370,6 +694,30 @@ func (r *RedshiftBackend) TableExists(table string) (bool, error) {
 	}
 }
 
+// lockTable locks the given table's lock, returning a function that unlocks it, or ctx's error if it's
+// done before the lock is acquired. A lock acquired after that is released right away.
+func (r *RedshiftBackend) lockTable(ctx context.Context, table string) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	lock := r.getTableLock(table)
+	locked := make(chan struct{})
+	go func() {
+		lock.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return lock.Unlock, nil
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			lock.Unlock()
+		}()
+		return nil, ctx.Err()
+	}
+}
+
 // getTableLock returns a lock for the given table, creating it if necessary.
 func (r *RedshiftBackend) getTableLock(table string) *sync.Mutex {
 	r.lockLock.Lock()
@@ -392,7 +740,7 @@ func (r *RedshiftBackend) TableLocked(table string) (bool, error) {
 		AND t.relname = $2
 	)`
 	var exists bool
-	err := r.connection.Conn.QueryRow(query, r.physicalSchema, table).Scan(&exists)
+	err := r.connection.Conn.QueryRow(query, r.tableSchema(table), table).Scan(&exists)
 	switch {
 	case err != nil:
 		return false, fmt.Errorf("querying whether %s table is locked: %v", table, err)
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestLockTable(t *testing.T) {
+	r, mock := mockBackend(t)
+	unlock, err := r.lockTable(context.Background(), "chat")
+	assert.NoError(t, err)
+
+	// A load waiting on the lock gives up when its COPY's deadline passes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = r.ManifestCopy(ctx, "chat", []string{"s3://bucket/a.json"}, redshift.CopyOptions{})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, err = r.lockTable(ctx, "other")
+	assert.Equal(t, context.DeadlineExceeded, err, "a done context doesn't lock even a free table")
+
+	// The abandoned wait releases the lock as soon as it gets it
+	unlock()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err = r.lockTable(ctx, "chat")
+	if assert.NoError(t, err) {
+		unlock()
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddScanned(t *testing.T) {
+	r, mock := mockBackend(t)
+	expectScanned := func(queryID int64, bytes, lines int64) {
+		mock.ExpectQuery("FROM STL_S3CLIENT").WithArgs(queryID).
+			WillReturnRows(sqlmock.NewRows([]string{"bytes"}).AddRow(bytes))
+		mock.ExpectQuery("FROM STL_LOAD_COMMITS").WithArgs(queryID).
+			WillReturnRows(sqlmock.NewRows([]string{"lines"}).AddRow(lines))
+	}
+	commitStats := regexp.QuoteMeta("FROM STL_COMMIT_STATS")
+
+	expectScanned(1, 100, 10)
+	expectScanned(2, 50, 5)
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(commitStats).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"startqueue", "startwork", "endtime"}).
+		AddRow(start, start.Add(time.Second), start.Add(3*time.Second)))
+	stats := &CopyStats{}
+	r.addScanned("chat", []int64{1, 2}, stats)
+	assert.Equal(t, &CopyStats{BytesScanned: 150, LinesScanned: 15, CommitQueueWait: time.Second,
+		CommitExecution: 2 * time.Second}, stats)
+
+	// Redshift hasn't recorded the commit yet
+	expectScanned(3, 100, 10)
+	mock.ExpectQuery(commitStats).WithArgs(3).WillReturnError(sql.ErrNoRows)
+	stats = &CopyStats{}
+	r.addScanned("chat", []int64{3}, stats)
+	assert.Equal(t, &CopyStats{BytesScanned: 100, LinesScanned: 10}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// RenameTable is the action of the operation that renames an event's table: the migration of the new
+// name to the version it's renamed at has only it, with the old name in ActionMetadata["old_name"].
+const RenameTable scoop_protocol.Action = "rename_table"
+
+// TableRename returns the old name of the table the operations rename, if they rename one.
+func TableRename(ops []scoop_protocol.Operation) (string, bool) {
+	for _, op := range ops {
+		if op.Action == RenameTable {
+			return op.ActionMetadata["old_name"], true
+		}
+	}
+	return "", false
+}
+
+// RenameTable renames the table to newName, at the version, with the version's columns: its views are
+// recreated under the new name, and its history in infra.table_version is carried over to the new name
+// followed by the version. Both names must be in the same schema. The table's versioned and stragglers
+// tables keep its old name.
+func (r *RedshiftBackend) RenameTable(table, newName string, version int, cols []scoop_protocol.ColumnDefinition) error {
+	schema := r.tableSchema(table)
+	if newSchema := r.tableSchema(newName); newSchema != schema {
+		return fmt.Errorf("can't rename %s in schema %s to %s in schema %s", table, schema, newName, newSchema)
+	}
+	// Locked in name order, so two renames can't deadlock
+	first, second := table, newName
+	if second < first {
+		first, second = second, first
+	}
+	firstLock := r.getTableLock(first)
+	firstLock.Lock()
+	defer firstLock.Unlock()
+	secondLock := r.getTableLock(second)
+	secondLock.Lock()
+	defer secondLock.Unlock()
+
+	cvs := r.buildCreateViewString(newName, cols)
+	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		var current int
+		err := tx.QueryRow("SELECT MAX(version) FROM infra.table_version WHERE name = $1 GROUP BY name", table).
+			Scan(&current)
+		switch {
+		case err == sql.ErrNoRows:
+			return fmt.Errorf("table %s doesn't exist in infra.table_version", table)
+		case err != nil:
+			return fmt.Errorf("finding table version from ace: %v", err)
+		case current >= version:
+			return fmt.Errorf("can't rename %s at version %d to %s at version %d", table, current, newName, version)
+		}
+		_, err = tx.Exec(fmt.Sprintf("DROP VIEW IF EXISTS %s.%s CASCADE",
+			pq.QuoteIdentifier(r.viewSchema), pq.QuoteIdentifier(table)))
+		if err != nil {
+			return fmt.Errorf("dropping view: %v", err)
+		}
+		_, err = tx.Exec(fmt.Sprintf("DROP VIEW IF EXISTS %s.%s CASCADE",
+			pq.QuoteIdentifier(r.fullViewSchema), pq.QuoteIdentifier(table)))
+		if err != nil {
+			return fmt.Errorf("dropping full view: %v", err)
+		}
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s.%s RENAME TO %s", pq.QuoteIdentifier(schema),
+			pq.QuoteIdentifier(table), pq.QuoteIdentifier(newName)))
+		if err != nil {
+			return fmt.Errorf("renaming table: %v", err)
+		}
+		_, err = tx.Exec("UPDATE infra.table_version SET name = $2 WHERE name = $1", table, newName)
+		if err != nil {
+			return fmt.Errorf("carrying over table_version in ace: %v", err)
+		}
+		_, err = tx.Exec("INSERT INTO infra.table_version (name, version, ts) VALUES ($1, $2, GETDATE())",
+			newName, version)
+		if err != nil {
+			return fmt.Errorf("updating table_version in ace: %v", err)
+		}
+		_, err = tx.Exec(cvs)
+		if err != nil {
+			return fmt.Errorf("CREATEing VIEW %s: %v", newName, err)
+		}
+		return nil
+	})
+}
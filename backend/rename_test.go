@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestTableRename(t *testing.T) {
+	old, ok := TableRename([]scoop_protocol.Operation{
+		{Action: RenameTable, Name: "chat", ActionMetadata: map[string]string{"old_name": "old_chat"}},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "old_chat", old)
+
+	_, ok = TableRename([]scoop_protocol.Operation{{Action: scoop_protocol.ADD, Name: "login"}})
+	assert.False(t, ok)
+}
+
+func TestRenameTable(t *testing.T) {
+	r, mock := mockBackend(t)
+	r.viewSchema, r.fullViewSchema = "views", "full_views"
+	cols := []scoop_protocol.ColumnDefinition{{OutboundName: "login", Transformer: "varchar", ColumnCreationOptions: "(64)"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT MAX\\(version\\) FROM infra.table_version").WithArgs("old_chat").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(4))
+	mock.ExpectRollback()
+	assert.EqualError(t, r.RenameTable("old_chat", "chat", 4, cols),
+		"can't rename old_chat at version 4 to chat at version 4")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT MAX\\(version\\) FROM infra.table_version").WithArgs("old_chat").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(3))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP VIEW IF EXISTS "views"."old_chat" CASCADE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP VIEW IF EXISTS "full_views"."old_chat" CASCADE`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "logs"."old_chat" RENAME TO "chat"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE infra.table_version SET name").WithArgs("old_chat", "chat").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("INSERT INTO infra.table_version").WithArgs("chat", 4).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE VIEW "views"."chat" AS SELECT * FROM "logs"."chat"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	assert.NoError(t, r.RenameTable("old_chat", "chat", 4, cols))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ExpiredRows counts the table's rows whose column is before the cutoff.
+func (r *RedshiftBackend) ExpiredRows(table, column string, before time.Time) (int64, error) {
+	var count int64
+	err := r.connection.Conn.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s.%s WHERE %s < $1",
+		pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table), pq.QuoteIdentifier(column)),
+		before).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting expired rows: %v", err)
+	}
+	return count, nil
+}
+
+// DeleteExpiredRows deletes the table's rows whose column is before the cutoff, then reclaims their
+// space with VACUUM DELETE ONLY, returning how many rows were deleted. The DELETE holds the table's
+// lock, so loads into it wait, and times out after timeoutMs if positive; the VACUUM runs after
+// the lock is released, as it can't run in a transaction and doesn't block COPYs.
+func (r *RedshiftBackend) DeleteExpiredRows(table, column string, before time.Time, timeoutMs int) (int64, error) {
+	name := fmt.Sprintf("%s.%s", pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(table))
+	deleted, err := r.deleteExpiredRows(table, name, column, before, timeoutMs)
+	if err != nil || deleted == 0 {
+		return deleted, err
+	}
+	_, err = r.connection.Conn.Exec(fmt.Sprintf("VACUUM DELETE ONLY %s", name))
+	if err != nil {
+		return deleted, fmt.Errorf("vacuuming: %v", err)
+	}
+	return deleted, nil
+}
+
+func (r *RedshiftBackend) deleteExpiredRows(table, name, column string, before time.Time, timeoutMs int) (int64, error) {
+	lock := r.getTableLock(table)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var deleted int64
+	err := r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		if timeoutMs > 0 {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", timeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+		}
+		result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s < $1", name, pq.QuoteIdentifier(column)), before)
+		if err != nil {
+			return fmt.Errorf("deleting expired rows: %v", err)
+		}
+		deleted, err = result.RowsAffected()
+		return err
+	})
+	return deleted, err
+}
@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// LiveColumn is a column of a table as it is in Redshift, per pg_table_def.
+type LiveColumn struct {
+	Name string
+	Type string
+}
+
+// ColumnDrift is a column whose live type differs from blueprint's. Expected is empty if the
+// column isn't in blueprint's schema, and Live if it's missing from the table.
+type ColumnDrift struct {
+	Column   string
+	Expected string `json:",omitempty"`
+	Live     string `json:",omitempty"`
+}
+
+// SchemaDiff compares a table's live columns with blueprint's schema at a version.
+type SchemaDiff struct {
+	Table   string
+	Version int
+	Drift   []ColumnDrift
+}
+
+// HasDrift returns whether the live table differs from blueprint's schema.
+func (d SchemaDiff) HasDrift() bool {
+	return len(d.Drift) > 0
+}
+
+// typeAliases maps the names pg_table_def gives types to the ones blueprint uses.
+var typeAliases = map[string]string{
+	"character varying":           "varchar",
+	"character":                   "char",
+	"integer":                     "int",
+	"int4":                        "int",
+	"int8":                        "bigint",
+	"int2":                        "smallint",
+	"double precision":            "float8",
+	"float":                       "float8",
+	"real":                        "float4",
+	"boolean":                     "bool",
+	"timestamp without time zone": "timestamp",
+	"datetime":                    "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"decimal":                     "numeric",
+}
+
+var typeArgsPattern = regexp.MustCompile(`^([a-z0-9 ]+?)\s*(\(.*\))?$`)
+
+// normalizeType returns the canonical spelling of a Redshift type, e.g. "varchar(64)" for
+// "character varying(64)".
+func normalizeType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	match := typeArgsPattern.FindStringSubmatch(t)
+	if match == nil {
+		return t
+	}
+	name, args := match[1], strings.Replace(match[2], " ", "", -1)
+	if alias, ok := typeAliases[name]; ok {
+		name = alias
+	}
+	return name + args
+}
+
+// columnType returns the Redshift type blueprint's column is created with.
+func columnType(col scoop_protocol.ColumnDefinition) string {
+	step := migrationStep{ActionMetadata: map[string]string{"column_type": col.Transformer}}
+	options := ""
+	// Like getCreationForm, only options longer than a character are part of the type
+	if len(col.ColumnCreationOptions) > 1 {
+		options = col.ColumnCreationOptions
+	}
+	return step.getColumnType() + options
+}
+
+// ExpectedType returns the normalized Redshift type blueprint's column is created with, e.g. "bigint" or
+// "varchar(64)".
+func ExpectedType(col scoop_protocol.ColumnDefinition) string {
+	return normalizeType(columnType(col))
+}
+
+// DiffSchema compares the table's live columns with blueprint's columns at the version, in blueprint's
+// order followed by the live columns blueprint doesn't have.
+func DiffSchema(table string, version int, expected []scoop_protocol.ColumnDefinition, live []LiveColumn) SchemaDiff {
+	diff := SchemaDiff{Table: table, Version: version, Drift: []ColumnDrift{}}
+	liveTypes := make(map[string]string, len(live))
+	for _, col := range live {
+		liveTypes[strings.ToLower(col.Name)] = normalizeType(col.Type)
+	}
+	inBlueprint := make(map[string]bool, len(expected))
+	for _, col := range expected {
+		name := strings.ToLower(col.OutboundName)
+		inBlueprint[name] = true
+		want := ExpectedType(col)
+		if got := liveTypes[name]; got != want {
+			diff.Drift = append(diff.Drift, ColumnDrift{Column: name, Expected: want, Live: got})
+		}
+	}
+	for _, col := range live {
+		name := strings.ToLower(col.Name)
+		if !inBlueprint[name] {
+			diff.Drift = append(diff.Drift, ColumnDrift{Column: name, Live: liveTypes[name]})
+		}
+	}
+	return diff
+}
+
+// LiveColumns returns the table's columns as they are in Redshift, in order.
+func (r *RedshiftBackend) LiveColumns(table string) ([]LiveColumn, error) {
+	var cols []LiveColumn
+	err := r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		var err error
+		cols, err = liveColumns(tx, r.tableSchema(table), table)
+		return err
+	})
+	return cols, err
+}
+
+// liveColumns returns the columns of the table in the schema, in order, or none if it doesn't exist.
+func liveColumns(tx *sql.Tx, schema, table string) ([]LiveColumn, error) {
+	// pg_table_def only has the tables of schemas in the search path
+	_, err := tx.Exec("SET LOCAL search_path TO " + pq.QuoteIdentifier(schema))
+	if err != nil {
+		return nil, fmt.Errorf("setting search path: %v", err)
+	}
+	rows, err := tx.Query(`SELECT "column", type FROM pg_table_def
+		WHERE schemaname = $1 AND tablename = $2`, schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("querying pg_table_def: %v", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var cols []LiveColumn
+	for rows.Next() {
+		var col LiveColumn
+		if err = rows.Scan(&col.Name, &col.Type); err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+	}
+	return cols, rows.Err()
+}
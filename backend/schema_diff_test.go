@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestNormalizeType(t *testing.T) {
+	assert.Equal(t, "varchar(64)", normalizeType("character varying(64)"))
+	assert.Equal(t, "varchar(64)", normalizeType("varchar(64)"))
+	assert.Equal(t, "numeric(10,2)", normalizeType("NUMERIC(10, 2)"))
+	assert.Equal(t, "timestamp", normalizeType("timestamp without time zone"))
+	assert.Equal(t, "float8", normalizeType("double precision"))
+	assert.Equal(t, "bigint", normalizeType("bigint"))
+	assert.Equal(t, "int", normalizeType("integer"))
+}
+
+func TestDiffSchema(t *testing.T) {
+	expected := []scoop_protocol.ColumnDefinition{
+		{OutboundName: "time", Transformer: "f@timestamp@unix"},
+		{OutboundName: "channel", Transformer: "varchar", ColumnCreationOptions: "(64)"},
+		{OutboundName: "user_id", Transformer: "bigint"},
+	}
+	live := []LiveColumn{
+		{Name: "time", Type: "timestamp without time zone"},
+		{Name: "channel", Type: "character varying(64)"},
+		{Name: "user_id", Type: "bigint"},
+	}
+	diff := DiffSchema("minute_watched", 3, expected, live)
+	assert.False(t, diff.HasDrift())
+
+	live = []LiveColumn{
+		{Name: "time", Type: "timestamp without time zone"},
+		{Name: "channel", Type: "character varying(128)"},
+		{Name: "manual_col", Type: "integer"},
+	}
+	diff = DiffSchema("minute_watched", 3, expected, live)
+	assert.True(t, diff.HasDrift())
+	assert.Equal(t, []ColumnDrift{
+		{Column: "channel", Expected: "varchar(64)", Live: "varchar(128)"},
+		{Column: "user_id", Expected: "bigint"},
+		{Column: "manual_col", Live: "int"},
+	}, diff.Drift)
+}
+
+func TestLiveColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	// LOCAL, so later transactions on the connection keep the default search path
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL search_path TO "logs"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM pg_table_def").WithArgs("logs", "chat").
+		WillReturnRows(sqlmock.NewRows([]string{"column", "type"}).
+			AddRow("time", "timestamp without time zone").AddRow("login", "character varying(64)"))
+	mock.ExpectCommit()
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	cols, err := liveColumns(tx, "logs", "chat")
+	assert.NoError(t, err)
+	assert.Equal(t, []LiveColumn{{"time", "timestamp without time zone"}, {"login", "character varying(64)"}}, cols)
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,142 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// StragglersTableName returns the name of the table a table's files of outdated versions are loaded
+// into under the stragglers policy.
+func StragglersTableName(table string) string {
+	return table + "_stragglers"
+}
+
+// StragglerCopy loads files of an outdated version of the table, whose columns are cols, into the
+// table named into: the table itself, or its stragglers table, which is created with cols if it's
+// missing. The manifests are COPYed into a staging table with cols, whose rows are then inserted into
+// into's columns: those the files lack are left to their defaults, and those of another type are cast.
+// Columns into lacks are dropped. It's all one transaction, which drops the staging table.
+func (r *RedshiftBackend) StragglerCopy(ctx context.Context, table, into string, cols []scoop_protocol.ColumnDefinition,
+	manifestURLs []string, opts redshift.CopyOptions) (*CopyStats, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("straggling files of %s have no columns", table)
+	}
+	start := time.Now()
+	unlock, err := r.lockTable(ctx, into)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	stats := &CopyStats{LockWait: time.Since(start)}
+	schema := r.tableSchema(table)
+	// The lock on into keeps other loads from staging into the same table
+	staging := into + "_staging"
+	defs := columnDefinitions(cols)
+	var queryIDs []int64
+	var copied time.Time
+	err = r.connection.ExecFnInTransactionContext(ctx, func(tx *sql.Tx) error {
+		copyStart := time.Now()
+		stats.RowsLoaded = 0
+		queryIDs = queryIDs[:0]
+		if opts.StatementTimeoutMs > 0 {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", opts.StatementTimeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+		}
+		_, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s.%s (%s)", pq.QuoteIdentifier(schema),
+			pq.QuoteIdentifier(staging), defs))
+		if err != nil {
+			return fmt.Errorf("creating staging table %s: %v", staging, err)
+		}
+		if into != table {
+			_, err = tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s)", pq.QuoteIdentifier(schema),
+				pq.QuoteIdentifier(into), defs))
+			if err != nil {
+				return fmt.Errorf("creating %s: %v", into, err)
+			}
+		}
+		for _, manifestURL := range manifestURLs {
+			req := redshift.ManifestRowCopyRequest{
+				BuiltOn:     time.Now(),
+				Schema:      schema,
+				Name:        staging,
+				ManifestURL: manifestURL,
+				Credentials: redshift.CopyCredentials(r.credentials),
+				Options:     opts,
+			}
+			if err = req.TxExecContext(ctx, tx); err != nil {
+				return err
+			}
+			result, err := redshift.LastCopyResult(ctx, tx)
+			if err != nil {
+				return fmt.Errorf("getting copy result: %v", err)
+			}
+			stats.RowsLoaded += result.RowsLoaded
+			queryIDs = append(queryIDs, result.QueryID)
+		}
+		if err = insertStaged(tx, schema, staging, into); err != nil {
+			return err
+		}
+		_, err = tx.Exec(fmt.Sprintf("DROP TABLE %s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(staging)))
+		if err != nil {
+			return fmt.Errorf("dropping staging table %s: %v", staging, err)
+		}
+		copied = time.Now()
+		stats.CopyDuration = copied.Sub(copyStart)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.CommitDuration = time.Since(copied)
+	r.addScanned(into, queryIDs, stats)
+	return stats, nil
+}
+
+// insertStaged inserts the rows of the staging table into the columns of into it shares with it, cast
+// to into's types.
+func insertStaged(tx *sql.Tx, schema, staging, into string) error {
+	intoCols, err := liveColumns(tx, schema, into)
+	if err != nil {
+		return err
+	}
+	if len(intoCols) == 0 {
+		return fmt.Errorf("table %s doesn't exist", into)
+	}
+	stagedCols, err := liveColumns(tx, schema, staging)
+	if err != nil {
+		return err
+	}
+	var names, exprs []string
+	for _, col := range intoCols {
+		name := pq.QuoteIdentifier(col.Name)
+		switch liveType(stagedCols, col.Name) {
+		case "":
+			continue
+		case col.Type:
+			exprs = append(exprs, name)
+		default:
+			exprs = append(exprs, fmt.Sprintf("CAST(%s AS %s)", name, col.Type))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("straggling files share no columns with %s", into)
+	}
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s", pq.QuoteIdentifier(schema),
+		pq.QuoteIdentifier(into), strings.Join(names, ", "), strings.Join(exprs, ", "),
+		pq.QuoteIdentifier(schema), pq.QuoteIdentifier(staging)))
+	if err != nil {
+		return fmt.Errorf("inserting straggling rows into %s: %v", into, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// stragglerCols are the columns of chat's outdated version: user_id was since widened to a bigint,
+// and login dropped.
+var stragglerCols = []scoop_protocol.ColumnDefinition{
+	{OutboundName: "time", Transformer: "f@timestamp@unix"},
+	{OutboundName: "user_id", Transformer: "int"},
+	{OutboundName: "login", Transformer: "varchar", ColumnCreationOptions: "(32)"},
+}
+
+func TestStragglerCopy(t *testing.T) {
+	r, mock := mockBackend(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL statement_timeout TO 60000").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE "logs"."chat_staging" ` +
+		`("time" datetime, "user_id" int, "login" varchar(32))`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY "logs"."chat_staging" FROM 's3://bucket/a.json'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT pg_last_copy_id").WillReturnRows(sqlmock.NewRows([]string{"id", "count"}).AddRow(1, 10))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY "logs"."chat_staging" FROM 's3://bucket/b.json'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT pg_last_copy_id").WillReturnRows(sqlmock.NewRows([]string{"id", "count"}).AddRow(2, 5))
+	expectLiveColumns(mock, "logs", "chat", "time", "timestamp without time zone", "user_id", "bigint",
+		"added", "integer")
+	expectLiveColumns(mock, "logs", "chat_staging", "time", "timestamp without time zone", "user_id", "integer",
+		"login", "character varying(32)")
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "logs"."chat" ("time", "user_id") ` +
+		`SELECT "time", CAST("user_id" AS bigint) FROM "logs"."chat_staging"`)).
+		WillReturnResult(sqlmock.NewResult(0, 15))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE "logs"."chat_staging"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	stats, err := r.StragglerCopy(context.Background(), "chat", "chat", stragglerCols,
+		[]string{"s3://bucket/a.json", "s3://bucket/b.json"}, redshift.CopyOptions{StatementTimeoutMs: 60000})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(15), stats.RowsLoaded)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStragglerCopyIntoStragglersTable(t *testing.T) {
+	r, mock := mockBackend(t)
+	into := StragglersTableName("chat")
+	defs := `("time" datetime, "user_id" int, "login" varchar(32))`
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE "logs"."chat_stragglers_staging" ` + defs)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "logs"."chat_stragglers" ` + defs)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY "logs"."chat_stragglers_staging" FROM 's3://bucket/a.json'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT pg_last_copy_id").WillReturnRows(sqlmock.NewRows([]string{"id", "count"}).AddRow(1, 10))
+	expectLiveColumns(mock, "logs", into, "time", "timestamp without time zone", "user_id", "integer",
+		"login", "character varying(32)")
+	expectLiveColumns(mock, "logs", "chat_stragglers_staging", "time", "timestamp without time zone",
+		"user_id", "integer", "login", "character varying(32)")
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "logs"."chat_stragglers" ("time", "user_id", "login") ` +
+		`SELECT "time", "user_id", "login" FROM "logs"."chat_stragglers_staging"`)).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE "logs"."chat_stragglers_staging"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	_, err := r.StragglerCopy(context.Background(), "chat", into, stragglerCols, []string{"s3://bucket/a.json"},
+		redshift.CopyOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStragglerCopyFails(t *testing.T) {
+	r, mock := mockBackend(t)
+	_, err := r.StragglerCopy(context.Background(), "chat", "chat", nil, []string{"s3://bucket/a.json"},
+		redshift.CopyOptions{})
+	assert.EqualError(t, err, "straggling files of chat have no columns")
+
+	// The files share no columns with the table, so nothing is loaded
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE "logs"."chat_staging"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COPY "logs"."chat_staging" FROM 's3://bucket/a.json'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT pg_last_copy_id").WillReturnRows(sqlmock.NewRows([]string{"id", "count"}).AddRow(1, 10))
+	expectLiveColumns(mock, "logs", "chat", "added", "integer")
+	expectLiveColumns(mock, "logs", "chat_staging", "time", "timestamp without time zone")
+	mock.ExpectRollback()
+	_, err = r.StragglerCopy(context.Background(), "chat", "chat", stragglerCols[:1], []string{"s3://bucket/a.json"},
+		redshift.CopyOptions{})
+	assert.EqualError(t, err, "straggling files share no columns with chat")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
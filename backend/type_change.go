@@ -0,0 +1,245 @@
+package backend
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// ChangeType is the action of an operation changing a column's type. Redshift can't ALTER COLUMN TYPE
+// for most types, so the table is deep copied into a new table with the column's new type in its
+// place, casting its values in batches, and the copy is swapped in. COPY matches columns by position,
+// so the column must keep its place rather than be re-added at the end.
+//
+// Its action metadata sets `column_type` and `column_options` like an ADD's, and can set `encode` to
+// the column's new encoding, which is otherwise kept.
+const ChangeType scoop_protocol.Action = "change_type"
+
+const (
+	// typeChangeBatchColumn is the column the copy is batched on, when the table has it.
+	typeChangeBatchColumn = "time"
+	// typeChangeBatchInterval is the range of typeChangeBatchColumn each copy batch covers.
+	typeChangeBatchInterval = 24 * time.Hour
+)
+
+// TypeChangeProgress is the progress of copying a table to change its columns' types.
+type TypeChangeProgress struct {
+	Table        string
+	Columns      []string
+	BatchesDone  int
+	BatchesTotal int
+	Started      time.Time
+}
+
+type typeChangeTracker struct {
+	lock     sync.RWMutex
+	progress map[string]*TypeChangeProgress
+}
+
+func (t *typeChangeTracker) set(p TypeChangeProgress) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.progress == nil {
+		t.progress = make(map[string]*TypeChangeProgress)
+	}
+	t.progress[p.Table] = &p
+}
+
+func (t *typeChangeTracker) done(table string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.progress, table)
+}
+
+func (t *typeChangeTracker) list() []TypeChangeProgress {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	list := make([]TypeChangeProgress, 0, len(t.progress))
+	for _, p := range t.progress {
+		list = append(list, *p)
+	}
+	return list
+}
+
+// HasTypeChange returns whether any of the operations is a ChangeType.
+func HasTypeChange(ops []scoop_protocol.Operation) bool {
+	for _, op := range ops {
+		if op.Action == ChangeType {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeChangeProgress returns the progress of the type changes copying their tables.
+func (r *RedshiftBackend) TypeChangeProgress() []TypeChangeProgress {
+	return r.typeChanges.list()
+}
+
+// castType returns the type to cast the old column's values to, e.g. varchar(64).
+func (m *migrationStep) castType() string {
+	colType := m.getColumnType()
+	if opts := m.ActionMetadata["column_options"]; strings.HasPrefix(strings.TrimSpace(opts), "(") {
+		colType += strings.TrimSpace(opts)
+	}
+	return colType
+}
+
+// applyTypeChanges returns the columns with the ChangeType operations' types, in their places, and the
+// expressions selecting each column's values from the old table.
+func applyTypeChanges(columns []tableColumn, ops []scoop_protocol.Operation) ([]tableColumn, []string, error) {
+	changed := make([]tableColumn, len(columns))
+	copy(changed, columns)
+	selects := make([]string, len(columns))
+	index := make(map[string]int)
+	for i, c := range changed {
+		index[c.name] = i
+		selects[i] = pq.QuoteIdentifier(c.name)
+	}
+
+	for _, op := range ops {
+		if op.Action != ChangeType {
+			continue
+		}
+		i, ok := index[op.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("column %s doesn't exist", op.Name)
+		}
+		step := migrationStep(op)
+		if step.getColumnType() == "" {
+			return nil, nil, fmt.Errorf("column %s has no new type", op.Name)
+		}
+		changed[i].colType = step.castType()
+		if encode, ok := op.ActionMetadata[columnEncode]; ok {
+			encode = strings.ToLower(strings.TrimSpace(encode))
+			if !encodings[encode] {
+				return nil, nil, fmt.Errorf("column %s has unknown encoding %q", op.Name, encode)
+			}
+			changed[i].encoding = encode
+		}
+		name := pq.QuoteIdentifier(op.Name)
+		selects[i] = fmt.Sprintf("CAST(%s AS %s) AS %s", name, changed[i].colType, name)
+	}
+	return changed, selects, nil
+}
+
+// prepareTypeChange deep copies the table into its rebuild table with the ChangeType operations' types,
+// to be swapped in by swapRebuiltTable in the migration's transaction. All of a migration's type changes
+// are done by one copy. The rows are cast over in batches, each committed in its own transaction, so the
+// copy doesn't hold one long transaction open and its progress is visible. The caller must hold the
+// table lock so no loads happen while copying.
+func (r *RedshiftBackend) prepareTypeChange(table string, ops []scoop_protocol.Operation, timeoutMs int) error {
+	if !HasTypeChange(ops) {
+		return nil
+	}
+	schema := pq.QuoteIdentifier(r.tableSchema(table))
+	quotedTable := pq.QuoteIdentifier(table)
+	rebuilt := pq.QuoteIdentifier(rebuildTable(table))
+	var insert string
+	var batched bool
+	var batches [][]time.Time
+	err := r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", timeoutMs))
+		if err != nil {
+			return fmt.Errorf("setting timeout: %v", err)
+		}
+		columns, err := tableColumns(tx, r.tableSchema(table), table)
+		if err != nil {
+			return fmt.Errorf("reading columns of %s: %v", table, err)
+		}
+		changed, selects, err := applyTypeChanges(columns, ops)
+		if err != nil {
+			return err
+		}
+
+		// A rebuild table is left behind by a copy that failed partway.
+		_, err = tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", schema, rebuilt))
+		if err != nil {
+			return fmt.Errorf("dropping leftover rebuild table: %v", err)
+		}
+		_, err = tx.Exec(rebuildDDL(schema, rebuilt, changed))
+		if err != nil {
+			return fmt.Errorf("creating rebuild table: %v", err)
+		}
+
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = pq.QuoteIdentifier(c.name)
+			batched = batched || c.name == typeChangeBatchColumn
+		}
+		insert = fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s", schema, rebuilt,
+			strings.Join(names, ", "), strings.Join(selects, ", "), schema, quotedTable)
+		batches = [][]time.Time{nil}
+		if batched {
+			batches, err = typeChangeBatches(tx, schema, quotedTable)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	progress := TypeChangeProgress{Table: table, BatchesTotal: len(batches), Started: time.Now()}
+	for _, op := range ops {
+		if op.Action == ChangeType {
+			progress.Columns = append(progress.Columns, op.Name)
+		}
+	}
+	r.typeChanges.set(progress)
+	defer r.typeChanges.done(table)
+
+	batchColumn := pq.QuoteIdentifier(typeChangeBatchColumn)
+	for _, batch := range batches {
+		err = r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+			_, err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", timeoutMs))
+			if err != nil {
+				return fmt.Errorf("setting timeout: %v", err)
+			}
+			switch {
+			case !batched:
+				_, err = tx.Exec(insert)
+			case batch == nil:
+				// Rows without a batch column value aren't in any batch
+				_, err = tx.Exec(fmt.Sprintf("%s WHERE %s IS NULL", insert, batchColumn))
+			default:
+				_, err = tx.Exec(fmt.Sprintf("%s WHERE %s >= $1 AND %s < $2", insert, batchColumn, batchColumn),
+					batch[0], batch[1])
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("copying rows into rebuild table: %v", err)
+		}
+		progress.BatchesDone++
+		r.typeChanges.set(progress)
+		logger.WithField("table", table).WithField("columns", progress.Columns).
+			WithField("batchesDone", progress.BatchesDone).WithField("batchesTotal", progress.BatchesTotal).
+			Info("Copied batch of rows to change column types")
+	}
+	return nil
+}
+
+// typeChangeBatches splits the table into ranges of typeChangeBatchColumn to copy one at a time,
+// followed by a nil range for the rows without a value.
+func typeChangeBatches(tx *sql.Tx, quotedSchema, quotedTable string) ([][]time.Time, error) {
+	var min, max pq.NullTime
+	batchColumn := pq.QuoteIdentifier(typeChangeBatchColumn)
+	err := tx.QueryRow(fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s.%s",
+		batchColumn, batchColumn, quotedSchema, quotedTable)).Scan(&min, &max)
+	if err != nil {
+		return nil, fmt.Errorf("finding copy range: %v", err)
+	}
+	var batches [][]time.Time
+	if min.Valid && max.Valid {
+		for start := min.Time; !start.After(max.Time); start = start.Add(typeChangeBatchInterval) {
+			batches = append(batches, []time.Time{start, start.Add(typeChangeBatchInterval)})
+		}
+	}
+	return append(batches, nil), nil
+}
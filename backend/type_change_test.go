@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func changeType(name, colType, options string, metadata map[string]string) scoop_protocol.Operation {
+	op := scoop_protocol.Operation{Action: ChangeType, Name: name,
+		ActionMetadata: map[string]string{"column_type": colType, "column_options": options}}
+	for k, v := range metadata {
+		op.ActionMetadata[k] = v
+	}
+	return op
+}
+
+func TestApplyTypeChanges(t *testing.T) {
+	columns := []tableColumn{
+		{name: "time", colType: "timestamp without time zone", encoding: "az64", sortKey: 1},
+		{name: "user_id", colType: "integer", encoding: "az64", distKey: true},
+		{name: "channel", colType: "character varying(64)", encoding: "lzo"},
+	}
+	changed, selects, err := applyTypeChanges(columns, []scoop_protocol.Operation{
+		addColumn("game", nil),
+		changeType("user_id", "bigint", "", nil),
+		changeType("channel", "varchar", "(256)", map[string]string{"encode": "ZSTD"}),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []tableColumn{
+		{name: "time", colType: "timestamp without time zone", encoding: "az64", sortKey: 1},
+		{name: "user_id", colType: "bigint", encoding: "az64", distKey: true},
+		{name: "channel", colType: "varchar(256)", encoding: "zstd"},
+	}, changed, "changed columns keep their places and keys")
+	assert.Equal(t, []string{`"time"`, `CAST("user_id" AS bigint) AS "user_id"`,
+		`CAST("channel" AS varchar(256)) AS "channel"`}, selects)
+	assert.Equal(t, "integer", columns[1].colType, "the original columns are unchanged")
+
+	_, _, err = applyTypeChanges(columns, []scoop_protocol.Operation{changeType("missing", "bigint", "", nil)})
+	assert.NotNil(t, err)
+	_, _, err = applyTypeChanges(columns, []scoop_protocol.Operation{changeType("user_id", "", "", nil)})
+	assert.NotNil(t, err)
+	_, _, err = applyTypeChanges(columns, []scoop_protocol.Operation{
+		changeType("user_id", "bigint", "", map[string]string{"encode": "lz4; DROP TABLE x"})})
+	assert.NotNil(t, err)
+}
+
+func TestPrepareTypeChange(t *testing.T) {
+	r, mock := mockBackend(t)
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	insert := `INSERT INTO "logs"."chat_rebuild" ("time", "user_id") ` +
+		`SELECT "time", CAST("user_id" AS bigint) AS "user_id" FROM "logs"."chat"`
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout TO 1000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL search_path TO "logs"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM information_schema.columns").WithArgs("logs", "chat").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name", "type", "encoding", "distkey", "sortkey", "notnull", "default"}).
+			AddRow("time", "timestamp without time zone", "az64", false, 1, false, false).
+			AddRow("user_id", "integer", "az64", true, 0, false, false))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "logs"."chat_rebuild"`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE "logs"."chat_rebuild" ("time" timestamp without time zone ENCODE az64, ` +
+		`"user_id" bigint ENCODE az64) DISTSTYLE KEY DISTKEY ("user_id") COMPOUND SORTKEY ("time")`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT MIN("time"), MAX("time") FROM "logs"."chat"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max"}).AddRow(day, day.Add(time.Hour)))
+	mock.ExpectCommit()
+	// Each batch is committed on its own
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout TO 1000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(insert+` WHERE "time" >= $1 AND "time" < $2`)).
+		WithArgs(day, day.Add(typeChangeBatchInterval)).WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout TO 1000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(insert + ` WHERE "time" IS NULL`)).WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	err := r.prepareTypeChange("chat", []scoop_protocol.Operation{changeType("user_id", "bigint", "", nil)}, 1000)
+	assert.EqualError(t, err, "copying rows into rebuild table: disk full")
+	assert.Empty(t, r.TypeChangeProgress(), "the progress of a finished copy is dropped")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.NoError(t, r.prepareTypeChange("chat", []scoop_protocol.Operation{addColumn("game", nil)}, 1000),
+		"migrations without type changes copy nothing")
+}
+
+func TestTypeChangeTracker(t *testing.T) {
+	var tracker typeChangeTracker
+	tracker.set(TypeChangeProgress{Table: "chat", Columns: []string{"user_id"}, BatchesTotal: 3})
+	tracker.set(TypeChangeProgress{Table: "chat", Columns: []string{"user_id"}, BatchesDone: 1, BatchesTotal: 3})
+	assert.Equal(t, []TypeChangeProgress{{Table: "chat", Columns: []string{"user_id"}, BatchesDone: 1, BatchesTotal: 3}},
+		tracker.list())
+	tracker.done("chat")
+	assert.Empty(t, tracker.list())
+}
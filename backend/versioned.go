@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// VersionedTableName returns the name of the table a table's files of the version are loaded into
+// while the table waits to be migrated to the version.
+func VersionedTableName(table string, version int) string {
+	return fmt.Sprintf("%s_v%d", table, version)
+}
+
+// CreateVersionedTable creates the table's versioned table for the version, with blueprint's columns
+// at the version, unless it already exists.
+func (r *RedshiftBackend) CreateVersionedTable(table string, version int, cols []scoop_protocol.ColumnDefinition) error {
+	if len(cols) == 0 {
+		return fmt.Errorf("version %d of %s has no columns", version, table)
+	}
+	_, err := r.connection.Conn.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s)",
+		pq.QuoteIdentifier(r.tableSchema(table)), pq.QuoteIdentifier(VersionedTableName(table, version)),
+		columnDefinitions(cols)))
+	if err != nil {
+		return fmt.Errorf("CREATEing versioned TABLE %s: %v", VersionedTableName(table, version), err)
+	}
+	return nil
+}
+
+// columnDefinitions returns the definitions of the columns in a CREATE TABLE, with only their types.
+func columnDefinitions(cols []scoop_protocol.ColumnDefinition) string {
+	defs := make([]string, len(cols))
+	for i, col := range cols {
+		defs[i] = pq.QuoteIdentifier(col.OutboundName) + " " + columnType(col)
+	}
+	return strings.Join(defs, ", ")
+}
+
+// VersionedManifestCopy COPYs each of the manifests into the table's versioned table for the version in
+// one transaction, and returns how much was loaded. Canary copies only get the table's own loads.
+func (r *RedshiftBackend) VersionedManifestCopy(ctx context.Context, table string, version int,
+	manifestURLs []string, opts redshift.CopyOptions) (*CopyStats, error) {
+	return r.lockedManifestCopy(ctx, r.tableSchema(table), VersionedTableName(table, version), manifestURLs, opts)
+}
+
+// UnionVersionedTables replaces the table's view with one that also selects the rows of its versioned
+// tables for the versions, or with the table alone if there are none.
+func (r *RedshiftBackend) UnionVersionedTables(table string, versions []int) error {
+	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		return r.replaceUnionView(tx, table, versions)
+	})
+}
+
+// MergeVersionedTable moves the rows of the table's versioned table for the version, which the table has
+// been migrated to, into the table and drops the versioned table. The table's view is replaced first
+// with one of the table and its versioned tables for the unioned versions. Merging a versioned table
+// that's already gone only replaces the view.
+func (r *RedshiftBackend) MergeVersionedTable(table string, version int, unioned []int) error {
+	versioned := VersionedTableName(table, version)
+	for _, name := range []string{table, versioned} {
+		lock := r.getTableLock(name)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	schema := r.tableSchema(table)
+	exists, err := r.tableExistsIn(schema, versioned)
+	if err != nil {
+		return err
+	}
+	return r.connection.ExecFnInTransaction(func(tx *sql.Tx) error {
+		err := r.replaceUnionView(tx, table, unioned)
+		if err != nil || !exists {
+			return err
+		}
+		tableCols, err := liveColumns(tx, schema, table)
+		if err != nil {
+			return err
+		}
+		versionedCols, err := liveColumns(tx, schema, versioned)
+		if err != nil {
+			return err
+		}
+		// Columns migrated away are dropped with the versioned table, like they are from the table
+		var cols []string
+		for _, col := range tableCols {
+			if liveType(versionedCols, col.Name) != "" {
+				cols = append(cols, pq.QuoteIdentifier(col.Name))
+			}
+		}
+		if len(cols) > 0 {
+			_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM %s.%s",
+				pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table), strings.Join(cols, ", "),
+				strings.Join(cols, ", "), pq.QuoteIdentifier(schema), pq.QuoteIdentifier(versioned)))
+			if err != nil {
+				return fmt.Errorf("merging %s: %v", versioned, err)
+			}
+		}
+		_, err = tx.Exec(fmt.Sprintf("DROP TABLE %s.%s", pq.QuoteIdentifier(schema), pq.QuoteIdentifier(versioned)))
+		if err != nil {
+			return fmt.Errorf("dropping %s: %v", versioned, err)
+		}
+		return nil
+	})
+}
+
+// replaceUnionView replaces the table's view with one that selects the table's columns from it and from
+// its versioned tables for the versions, with the view filter applied to each. A versioned table's
+// columns missing from it are null, and those of another type are cast. Versioned tables that don't
+// exist, or that lack the view column the table is filtered by, are left out.
+func (r *RedshiftBackend) replaceUnionView(tx *sql.Tx, table string, versions []int) error {
+	schema := r.tableSchema(table)
+	tableCols, err := liveColumns(tx, schema, table)
+	if err != nil {
+		return err
+	}
+	if len(tableCols) == 0 {
+		return fmt.Errorf("table %s doesn't exist", table)
+	}
+	viewFilter := ""
+	if liveType(tableCols, r.viewColumn) != "" {
+		viewFilter = r.viewFilter
+	}
+	names := make([]string, len(tableCols))
+	for i, col := range tableCols {
+		names[i] = pq.QuoteIdentifier(col.Name)
+	}
+	selects := []string{fmt.Sprintf("SELECT %s FROM %s.%s %s", strings.Join(names, ", "),
+		pq.QuoteIdentifier(schema), pq.QuoteIdentifier(table), viewFilter)}
+
+	for _, version := range versions {
+		versioned := VersionedTableName(table, version)
+		versionedCols, err := liveColumns(tx, schema, versioned)
+		if err != nil {
+			return err
+		}
+		if len(versionedCols) == 0 || viewFilter != "" && liveType(versionedCols, r.viewColumn) == "" {
+			logger.WithField("table", table).WithField("version", version).
+				Warn("Leaving versioned table out of view; it's missing or has no view column")
+			continue
+		}
+		exprs := make([]string, len(tableCols))
+		for i, col := range tableCols {
+			switch liveType(versionedCols, col.Name) {
+			case col.Type:
+				exprs[i] = names[i]
+			case "":
+				exprs[i] = fmt.Sprintf("CAST(NULL AS %s) AS %s", col.Type, names[i])
+			default:
+				exprs[i] = fmt.Sprintf("CAST(%s AS %s) AS %s", names[i], col.Type, names[i])
+			}
+		}
+		selects = append(selects, fmt.Sprintf("SELECT %s FROM %s.%s %s", strings.Join(exprs, ", "),
+			pq.QuoteIdentifier(schema), pq.QuoteIdentifier(versioned), viewFilter))
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("CREATE OR REPLACE VIEW %s.%s AS %s", pq.QuoteIdentifier(r.viewSchema),
+		pq.QuoteIdentifier(table), strings.Join(selects, " UNION ALL ")))
+	if err != nil {
+		return fmt.Errorf("replacing view of %s: %v", table, err)
+	}
+	return nil
+}
+
+// liveType returns the type of the named column, or an empty string if there's no such column.
+func liveType(cols []LiveColumn, name string) string {
+	for _, col := range cols {
+		if col.Name == name {
+			return col.Type
+		}
+	}
+	return ""
+}
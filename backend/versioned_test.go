@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestCreateVersionedTable(t *testing.T) {
+	r, mock := mockBackend(t)
+	cols := []scoop_protocol.ColumnDefinition{
+		{OutboundName: "time", Transformer: "f@timestamp@unix"},
+		{OutboundName: "login", Transformer: "varchar", ColumnCreationOptions: "(64)"},
+	}
+
+	assert.EqualError(t, r.CreateVersionedTable("chat", 3, nil), "version 3 of chat has no columns")
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "logs"."chat_v3" ` +
+		`("time" datetime, "login" varchar(64))`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.NoError(t, r.CreateVersionedTable("chat", 3, cols))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVersionedManifestCopy(t *testing.T) {
+	r, mock := mockBackend(t)
+	// The table's lock is held by its migration; the versioned table's loads go ahead regardless
+	lock := r.getTableLock("chat")
+	lock.Lock()
+	defer lock.Unlock()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`COPY "logs"."chat_v3" FROM 's3://bucket/a.json'`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT pg_last_copy_id").WillReturnRows(sqlmock.NewRows([]string{"id", "count"}).AddRow(1, 10))
+	mock.ExpectCommit()
+	stats, err := r.VersionedManifestCopy(context.Background(), "chat", 3, []string{"s3://bucket/a.json"},
+		redshift.CopyOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(10), stats.RowsLoaded)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet(), "the table's canary copy isn't loaded")
+}
@@ -1,25 +1,75 @@
 package blueprint
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/versions"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
 
-// Client is an client for the http interface of blueprint
+// errNotModified is returned by get when blueprint says the cached response is still current.
+var errNotModified = errors.New("not modified")
+
+// Client is an client for the http interface of blueprint. Copies share a response cache.
 type Client struct {
-	host string
+	host   string
+	cache  *responseCache
+	stats  monitoring.SafeStatter
+	shards ShardResolver
+}
+
+// ShardResolver maps the tables of sharded events to their events, whose schemas they share.
+type ShardResolver interface {
+	// LogicalTable returns the event a shard table belongs to, or the table if it isn't a shard
+	LogicalTable(table string) string
+	// ShardTables returns the tables a sharded event is loaded into besides its own, or nil if it
+	// isn't sharded
+	ShardTables(event string) []string
 }
 
-// New returns a new Blueprint Client
-func New(host string) Client {
-	return Client{host: host}
+// New returns a new Blueprint Client, which serves responses younger than cacheTTL from its cache.
+func New(host string, cacheTTL time.Duration, stats monitoring.SafeStatter) Client {
+	return Client{host: host, cache: newResponseCache(cacheTTL), stats: stats}
+}
+
+// WithShards returns a copy of the client that gets the migrations and schemas of shard tables from
+// their events.
+func (c *Client) WithShards(shards ShardResolver) Client {
+	sharded := *c
+	sharded.shards = shards
+	return sharded
+}
+
+// logicalTable returns the event whose schema the table has.
+func (c *Client) logicalTable(table string) string {
+	if c.shards == nil {
+		return table
+	}
+	return c.shards.LogicalTable(table)
+}
+
+// ShardSiblings returns every table of the sharded event the table belongs to, the event's own
+// first, or nil if the table isn't one of a sharded event's.
+func (c *Client) ShardSiblings(table string) []string {
+	if c.shards == nil {
+		return nil
+	}
+	event := c.shards.LogicalTable(table)
+	shards := c.shards.ShardTables(event)
+	if len(shards) == 0 {
+		return nil
+	}
+	return append([]string{event}, shards...)
 }
 
 func (c *Client) queryBlueprint(path string, values url.Values, allow404 bool) ([]byte, error) {
@@ -29,7 +79,43 @@ func (c *Client) queryBlueprint(path string, values url.Values, allow404 bool) (
 		Path:     path,
 		RawQuery: values.Encode(),
 	}
-	resp, err := http.Get(u.String())
+	key := u.String()
+	cached, fresh, found := c.cache.get(key)
+	if fresh {
+		c.stats.SafeInc("blueprint.cache.hit", 1, 1.0)
+		return cached.body, nil
+	}
+
+	body, etag, err := c.get(key, path, cached.etag, allow404)
+	switch {
+	case err == errNotModified:
+		c.cache.touch(key)
+		c.stats.SafeInc("blueprint.cache.revalidated", 1, 1.0)
+		return cached.body, nil
+	case err != nil && found:
+		logger.WithError(err).WithField("url", key).Warn("Serving stale response after error querying blueprint")
+		c.stats.SafeInc("blueprint.cache.stale", 1, 1.0)
+		return cached.body, nil
+	case err != nil:
+		return nil, err
+	}
+	c.stats.SafeInc("blueprint.cache.miss", 1, 1.0)
+	// An allowed 404 is cached as a nil body too.
+	c.cache.put(key, body, etag)
+	return body, nil
+}
+
+// get GETs the url from blueprint, returning the body and its ETag. If etag is given, it's sent as
+// If-None-Match, and errNotModified is returned if the response hasn't changed.
+func (c *Client) get(u string, path string, etag string, allow404 bool) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("building request for %s on blueprint: %v", path, err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	defer func() {
 		if resp != nil {
 			if err = resp.Body.Close(); err != nil {
@@ -38,19 +124,22 @@ func (c *Client) queryBlueprint(path string, values url.Values, allow404 bool) (
 		}
 	}()
 	if err != nil {
-		return nil, fmt.Errorf("GETing %s from blueprint: %v", path, err)
+		return nil, "", fmt.Errorf("GETing %s from blueprint: %v", path, err)
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", errNotModified
 	}
 	if resp.StatusCode >= 400 {
 		if allow404 && resp.StatusCode == 404 {
-			return nil, nil
+			return nil, "", nil
 		}
-		return nil, fmt.Errorf("received %v from blueprint when GETing at %s", resp.Status, u.String())
+		return nil, "", fmt.Errorf("received %v from blueprint when GETing at %s", resp.Status, u)
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading body from %s on blueprint: %v", path, err)
+		return nil, "", fmt.Errorf("reading body from %s on blueprint: %v", path, err)
 	}
-	return body, nil
+	return body, resp.Header.Get("ETag"), nil
 }
 
 type bpSchema struct {
@@ -58,12 +147,12 @@ type bpSchema struct {
 }
 
 // GetMigration hits blueprint's migration endpoint for finding how to migrate
-// to `toVersion` for table `table`
+// to `toVersion` for table `table`; a shard table migrates as its event does
 func (c *Client) GetMigration(table string, toVersion int) (
 	[]scoop_protocol.Operation, []scoop_protocol.ColumnDefinition, error) {
 	v := url.Values{}
 	v.Set("to_version", strconv.Itoa(toVersion))
-	body, err := c.queryBlueprint(fmt.Sprintf("migration/%s", table), v, false)
+	body, err := c.queryBlueprint(fmt.Sprintf("migration/%s", c.logicalTable(table)), v, false)
 	if err != nil {
 		return nil, nil, fmt.Errorf("querying migration for %s version %d: %v", table, toVersion, err)
 	}
@@ -72,23 +161,70 @@ func (c *Client) GetMigration(table string, toVersion int) (
 	if err != nil {
 		return nil, nil, fmt.Errorf("parsing migration response for %s version %d: %v", table, toVersion, err)
 	}
-	v = url.Values{}
-	v.Set("version", strconv.Itoa(toVersion))
-	body, err = c.queryBlueprint(fmt.Sprintf("schema/%s", table), v, true)
+	cols, err := c.GetSchema(table, toVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ops, cols, nil
+}
+
+// GetSchema hits blueprint's schema endpoint for the columns of `table` at `version`.
+// Returns nil columns if the schema doesn't exist.
+func (c *Client) GetSchema(table string, version int) ([]scoop_protocol.ColumnDefinition, error) {
+	v := url.Values{}
+	v.Set("version", strconv.Itoa(version))
+	body, err := c.queryBlueprint(fmt.Sprintf("schema/%s", c.logicalTable(table)), v, true)
 	if err != nil {
-		return nil, nil, fmt.Errorf("querying schema for %s version %d: %v", table, toVersion, err)
+		return nil, fmt.Errorf("querying schema for %s version %d: %v", table, version, err)
 	}
 	// We 404'd because the schema didn't exist (it was dropped and is now being recreated).
 	if body == nil {
-		return ops, nil, nil
+		return nil, nil
 	}
 	var schemas []bpSchema
 	err = json.Unmarshal(body, &schemas)
 	if err != nil {
-		return nil, nil, fmt.Errorf("parsing schema response for %s version %d: %v", table, toVersion, err)
+		return nil, fmt.Errorf("parsing schema response for %s version %d: %v", table, version, err)
 	}
 	if len(schemas) != 1 {
-		return nil, nil, fmt.Errorf("expected exactly one schema when getting %s version %d", table, toVersion)
+		return nil, fmt.Errorf("expected exactly one schema when getting %s version %d", table, version)
+	}
+	return schemas[0].Columns, nil
+}
+
+// HealthCheck returns an error if blueprint isn't serving, bypassing the response cache.
+func (c *Client) HealthCheck() error {
+	u := url.URL{Scheme: "http", Host: c.host, Path: "health"}
+	_, _, err := c.get(u.String(), "health", "", false)
+	return err
+}
+
+// ingestionState is what PublishVersions POSTs to blueprint.
+type ingestionState struct {
+	Published time.Time
+	Tables    []versions.TableVersion
+}
+
+// PublishVersions POSTs the tables' versions in the ingester's cache and in Ace to blueprint's
+// ingestion_state endpoint, so it can show which version of each table is being loaded.
+func (c *Client) PublishVersions(tables []versions.TableVersion) error {
+	body, err := json.Marshal(ingestionState{Published: time.Now().In(time.UTC), Tables: tables})
+	if err != nil {
+		return fmt.Errorf("encoding ingestion state: %v", err)
+	}
+	u := url.URL{Scheme: "http", Host: c.host, Path: "ingestion_state"}
+	resp, err := http.Post(u.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("POSTing ingestion state to blueprint: %v", err)
+	}
+	defer func() {
+		if err = resp.Body.Close(); err != nil {
+			logger.WithError(err).Error("Error closing response body from blueprint")
+		}
+	}()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("received %v from blueprint when POSTing ingestion state", resp.Status)
 	}
-	return ops, schemas[0].Columns, nil
+	c.stats.SafeInc("blueprint.versions_published", 1, 1.0)
+	return nil
 }
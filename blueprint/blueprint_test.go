@@ -0,0 +1,64 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/versions"
+)
+
+func TestQueryBlueprintRevalidatesWithETag(t *testing.T) {
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`[{"Columns": []}]`))
+	}))
+	u, _ := url.Parse(server.URL)
+
+	// A zero TTL revalidates on every query.
+	client := New(u.Host, 0, monitoring.NewMockStatter())
+	for i := 0; i < 3; i++ {
+		body, err := client.queryBlueprint("schema/table", nil, false)
+		assert.Nil(t, err)
+		assert.Equal(t, `[{"Columns": []}]`, string(body))
+	}
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 2, notModified)
+
+	// Serve stale responses when blueprint is down.
+	server.Close()
+	body, err := client.queryBlueprint("schema/table", nil, false)
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"Columns": []}]`, string(body))
+	_, err = client.queryBlueprint("schema/other", nil, false)
+	assert.NotNil(t, err)
+}
+
+func TestPublishVersions(t *testing.T) {
+	var state ingestionState
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/ingestion_state", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&state))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	client := New(u.Host, 0, monitoring.NewMockStatter())
+
+	tables := versions.Compare(map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1, "b": 3})
+	assert.NoError(t, client.PublishVersions(tables))
+	assert.Equal(t, tables, state.Tables)
+	assert.True(t, state.Tables[1].Drift)
+}
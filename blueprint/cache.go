@@ -0,0 +1,52 @@
+package blueprint
+
+import (
+	"sync"
+	"time"
+)
+
+// cachedResponse is a successful response from blueprint, with the ETag to revalidate it.
+type cachedResponse struct {
+	body    []byte
+	etag    string
+	fetched time.Time
+}
+
+// responseCache holds blueprint responses by URL. Responses younger than ttl are served without
+// asking blueprint; older ones are revalidated with If-None-Match, and served stale if blueprint
+// can't be reached.
+type responseCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, entries: make(map[string]*cachedResponse)}
+}
+
+// get returns a copy of the cached response for the url, and whether it's still fresh.
+func (c *responseCache) get(url string) (cachedResponse, bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[url]
+	if !ok {
+		return cachedResponse{}, false, false
+	}
+	return *entry, time.Since(entry.fetched) < c.ttl, true
+}
+
+func (c *responseCache) put(url string, body []byte, etag string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[url] = &cachedResponse{body: body, etag: etag, fetched: time.Now()}
+}
+
+// touch marks the cached response for the url as fresh again after blueprint said it's unchanged.
+func (c *responseCache) touch(url string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, ok := c.entries[url]; ok {
+		entry.fetched = time.Now()
+	}
+}
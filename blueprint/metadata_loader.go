@@ -3,6 +3,8 @@ package blueprint
 import (
 	"encoding/json"
 	"io/ioutil"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,16 +14,63 @@ import (
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
 
+// TargetSchemaMetadataType is the event metadata type naming the Redshift schema an event's
+// table lives in, overriding the default physical schema.
+const TargetSchemaMetadataType = "target_schema"
+
+// Event metadata types describing how an event's data is handled.
+const (
+	// RetentionClassMetadataType names how long an event's data is kept, like "standard" or "short"
+	RetentionClassMetadataType = "retention_class"
+	// PIIMetadataType is "true" if an event has personally identifiable information
+	PIIMetadataType = "pii"
+	// PIIColumnsMetadataType lists an event's PII columns with how each is loaded, as
+	// "<column>:<policy>,..."
+	PIIColumnsMetadataType = "pii_columns"
+	// LoadStatusMetadataType holds back an event's loads; see LoadStatus
+	LoadStatusMetadataType = "load_status"
+	// ShardColumnMetadataType names the column an event is sharded by, e.g. "region". The processor
+	// writes each file's value of it into the file's key as a "<column>=<value>" path segment.
+	ShardColumnMetadataType = "shard_column"
+	// ShardsMetadataType maps values of an event's shard column to the tables files with them are
+	// loaded into, as "<value>:<table>,..."
+	ShardsMetadataType = "shards"
+)
+
+// LoadStatus is an event's load_status metadata.
+type LoadStatus string
+
+const (
+	// LoadActive events are loaded as usual. It's the status of events without load_status.
+	LoadActive LoadStatus = ""
+	// LoadPaused events aren't loaded until they're unpaused; their files are sent to the
+	// dead-letter queue to be redriven.
+	LoadPaused LoadStatus = "paused"
+	// LoadQuarantined events have bad data, so their files are sent to the dead-letter queue to
+	// be inspected.
+	LoadQuarantined LoadStatus = "quarantine"
+)
+
+// Diverted returns whether files of events with the status go to the dead-letter queue instead of
+// being loaded.
+func (s LoadStatus) Diverted() bool {
+	return s == LoadPaused || s == LoadQuarantined
+}
+
 // MetadataLoader fetches configs on an interval, with stats on the fetching process
 type MetadataLoader struct {
 	fetcher    ConfigFetcher
 	reloadTime time.Duration
 	retryDelay time.Duration
 	configs    scoop_protocol.EventMetadataConfig
+	// shardOf is the event each shard table belongs to
+	shardOf  map[string]string
+	loadedAt time.Time
 
-	closer chan bool
-	stats  monitoring.SafeStatter
-	lock   *sync.RWMutex
+	closer  chan bool
+	reloads chan bool
+	stats   monitoring.SafeStatter
+	lock    *sync.RWMutex
 }
 
 // NewMetadataLoader returns a new MetadataLoader, performing the first fetch
@@ -37,6 +86,7 @@ func NewMetadataLoader(
 		retryDelay: retryDelay,
 		configs:    scoop_protocol.EventMetadataConfig{},
 		closer:     make(chan bool),
+		reloads:    make(chan bool, 1),
 		stats:      stats,
 		lock:       &sync.RWMutex{},
 	}
@@ -46,13 +96,19 @@ func NewMetadataLoader(
 		return nil, err
 	}
 	d.configs = config
+	d.shardOf = shardEvents(config)
+	d.loadedAt = time.Now()
 	return &d, nil
 }
 
-// GetMetadataValueByType returns the metadata value given an eventName and metadataType
+// GetMetadataValueByType returns the metadata value given an eventName and metadataType. A shard
+// table has the metadata of its event.
 func (d *MetadataLoader) GetMetadataValueByType(eventName string, metadataType string) string {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	if _, found := d.configs.Metadata[eventName]; !found && d.shardOf[eventName] != "" {
+		eventName = d.shardOf[eventName]
+	}
 	if eventMetadata, found := d.configs.Metadata[eventName]; found {
 		if metadataRow, exists := eventMetadata[metadataType]; exists {
 			return metadataRow.MetadataValue
@@ -76,8 +132,7 @@ func (d *MetadataLoader) TableExists(eventName string) bool {
 
 // LoadIntoAce returns whether an event is to be loaded into Ace based on the metadata
 func (d *MetadataLoader) LoadIntoAce(eventName string) bool {
-	datastores := strings.Split(d.GetMetadataValueByType(eventName, string(scoop_protocol.DATASTORES)), ",")
-	for _, datastore := range datastores {
+	for _, datastore := range d.TargetDatastores(eventName) {
 		if datastore == "ace" {
 			return true
 		}
@@ -85,6 +140,160 @@ func (d *MetadataLoader) LoadIntoAce(eventName string) bool {
 	return false
 }
 
+// TargetDatastores returns the datastores an event is loaded into
+func (d *MetadataLoader) TargetDatastores(eventName string) []string {
+	var datastores []string
+	for _, datastore := range strings.Split(d.GetMetadataValueByType(eventName, string(scoop_protocol.DATASTORES)), ",") {
+		if datastore = strings.TrimSpace(datastore); datastore != "" {
+			datastores = append(datastores, datastore)
+		}
+	}
+	return datastores
+}
+
+// RetentionClass returns an event's retention class, or "" if it has none
+func (d *MetadataLoader) RetentionClass(eventName string) string {
+	return strings.TrimSpace(d.GetMetadataValueByType(eventName, RetentionClassMetadataType))
+}
+
+// ContainsPII returns whether an event is flagged as having personally identifiable information
+func (d *MetadataLoader) ContainsPII(eventName string) bool {
+	pii, err := strconv.ParseBool(strings.TrimSpace(d.GetMetadataValueByType(eventName, PIIMetadataType)))
+	return err == nil && pii
+}
+
+// PIIColumns returns the policies of an event's PII columns by column, or nil if it has none. An
+// entry without a policy has an empty one, for the loader to reject.
+func (d *MetadataLoader) PIIColumns(eventName string) map[string]string {
+	var columns map[string]string
+	for _, entry := range strings.Split(d.GetMetadataValueByType(eventName, PIIColumnsMetadataType), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if columns == nil {
+			columns = make(map[string]string)
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		column := strings.TrimSpace(parts[0])
+		if len(parts) < 2 {
+			columns[column] = ""
+			continue
+		}
+		columns[column] = strings.TrimSpace(parts[1])
+	}
+	return columns
+}
+
+// LoadStatus returns whether an event's loads are held back
+func (d *MetadataLoader) LoadStatus(eventName string) LoadStatus {
+	return LoadStatus(strings.ToLower(strings.TrimSpace(d.GetMetadataValueByType(eventName, LoadStatusMetadataType))))
+}
+
+// ShardColumn returns the column an event is sharded by, or "" if it isn't sharded
+func (d *MetadataLoader) ShardColumn(eventName string) string {
+	return strings.TrimSpace(d.GetMetadataValueByType(eventName, ShardColumnMetadataType))
+}
+
+// Shards returns the tables a sharded event's files are loaded into by their value of its shard
+// column, or nil if it isn't sharded.
+func (d *MetadataLoader) Shards(eventName string) map[string]string {
+	if d.ShardColumn(eventName) == "" {
+		return nil
+	}
+	return parseShards(d.GetMetadataValueByType(eventName, ShardsMetadataType))
+}
+
+// ShardTable returns the table a file of the event is loaded into, by the value of the event's shard
+// column in the file's key, and whether the value has a shard. Files of events that aren't sharded,
+// and those whose value has no shard, are loaded into the event's own table.
+func (d *MetadataLoader) ShardTable(eventName, key string) (string, bool) {
+	value, ok := keyShardValue(key, d.ShardColumn(eventName))
+	if !ok {
+		return eventName, false
+	}
+	table, ok := d.Shards(eventName)[value]
+	if !ok {
+		return eventName, false
+	}
+	return table, true
+}
+
+// ShardTables returns the tables a sharded event is loaded into besides its own, sorted, or nil if it
+// isn't sharded.
+func (d *MetadataLoader) ShardTables(eventName string) []string {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, table := range d.Shards(eventName) {
+		if !seen[table] && table != eventName {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// LogicalTable returns the event a shard table belongs to, or the table if it isn't a shard.
+func (d *MetadataLoader) LogicalTable(table string) string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if event, ok := d.shardOf[table]; ok {
+		return event
+	}
+	return table
+}
+
+// parseShards parses a shards metadata value; entries without a table are skipped.
+func parseShards(value string) map[string]string {
+	var shards map[string]string
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+		if shards == nil {
+			shards = make(map[string]string)
+		}
+		shards[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return shards
+}
+
+// keyShardValue returns the value of the column in the key's "<column>=<value>" path segment, and
+// whether the key has one.
+func keyShardValue(key, column string) (string, bool) {
+	if column == "" {
+		return "", false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, column+"=") {
+			return strings.TrimPrefix(segment, column+"="), true
+		}
+	}
+	return "", false
+}
+
+// shardEvents returns the event each shard table of the config's sharded events belongs to.
+func shardEvents(config scoop_protocol.EventMetadataConfig) map[string]string {
+	shardOf := make(map[string]string)
+	for event, metadata := range config.Metadata {
+		if strings.TrimSpace(metadata[ShardColumnMetadataType].MetadataValue) == "" {
+			continue
+		}
+		for _, table := range parseShards(metadata[ShardsMetadataType].MetadataValue) {
+			if table != event {
+				shardOf[table] = event
+			}
+		}
+	}
+	return shardOf
+}
+
+// TargetSchema returns the Redshift schema override for an event, or "" if it has none
+func (d *MetadataLoader) TargetSchema(eventName string) string {
+	return d.GetMetadataValueByType(eventName, TargetSchemaMetadataType)
+}
+
 func (d *MetadataLoader) retryPull(n int, waitTime time.Duration) (scoop_protocol.EventMetadataConfig, error) {
 	var err error
 	var config scoop_protocol.EventMetadataConfig
@@ -123,20 +332,41 @@ func (d *MetadataLoader) refresh() error {
 	if err != nil {
 		return err
 	}
+	shardOf := shardEvents(newConfig)
 	d.lock.Lock()
 	d.configs = newConfig
+	d.shardOf = shardOf
+	d.loadedAt = time.Now()
 	d.lock.Unlock()
 	return nil
 }
 
-// ForceReload forces the metadata loader to load metadata right away
-func (d *MetadataLoader) ForceReload() {
+// LoadedAt returns when metadata was last loaded successfully.
+func (d *MetadataLoader) LoadedAt() time.Time {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.loadedAt
+}
+
+// ForceReload forces the metadata loader to load metadata right away, returning the error if it
+// couldn't, in which case the metadata it had is kept.
+func (d *MetadataLoader) ForceReload() error {
 	err := d.refresh()
 	if err != nil {
 		logger.WithError(err).Error("Failed to force a refresh of Blueprint metadata")
-		return
+		return err
 	}
 	logger.Info("Successfully forced a refresh of Blueprint metadata")
+	return nil
+}
+
+// Reload has Crank load metadata right away, e.g. because Blueprint notified us it changed. A
+// reload already pending absorbs the request, so a burst of notifications causes one reload.
+func (d *MetadataLoader) Reload() {
+	select {
+	case d.reloads <- true:
+	default:
+	}
 }
 
 // Close stops the MetadataLoader's fetching process.
@@ -157,6 +387,14 @@ func (d *MetadataLoader) Crank() {
 				continue
 			}
 			logger.Info("Successfully refreshed Blueprint metadata")
+		case <-d.reloads:
+			d.stats.SafeInc("bp_metadata.notified_reload", 1, 1.0)
+			err := d.refresh()
+			if err != nil {
+				logger.WithError(err).Error("Failed to reload Blueprint metadata on notification")
+				continue
+			}
+			logger.Info("Reloaded Blueprint metadata on notification")
 		case <-d.closer:
 			tick.Stop()
 			return
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/twitchscience/aws_utils/logger"
 	"github.com/twitchscience/aws_utils/monitoring"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
@@ -85,6 +87,34 @@ func TestRetryPull(t *testing.T) {
 	}
 }
 
+func TestForceReload(t *testing.T) {
+	loader, err := NewMetadataLoader(
+		&mockFetcher{
+			failFetch: []bool{false, false, false},
+			configs: []scoop_protocol.EventMetadataConfig{
+				knownEventMetadataTwo,
+				knownEventMetadataOne,
+			},
+		},
+		time.Hour,
+		time.Microsecond,
+		monitoring.NewMockStatter(),
+	)
+	assert.NoError(t, err)
+	firstLoad := loader.LoadedAt()
+	assert.False(t, firstLoad.IsZero())
+
+	assert.Empty(t, loader.GetAllMetadata().Metadata["Metadata"])
+	assert.NoError(t, loader.ForceReload())
+	assert.NotEmpty(t, loader.GetAllMetadata().Metadata["Metadata"])
+	reloaded := loader.LoadedAt()
+	assert.False(t, reloaded.Before(firstLoad))
+
+	assert.Error(t, loader.ForceReload(), "the fetcher has no configs left")
+	assert.NotEmpty(t, loader.GetAllMetadata().Metadata["Metadata"], "a failed reload keeps the metadata")
+	assert.Equal(t, reloaded, loader.LoadedAt())
+}
+
 type mockFetcher struct {
 	failFetch []bool
 	configs   []scoop_protocol.EventMetadataConfig
@@ -134,3 +164,90 @@ func (t *mockFetcher) ConfigDestination(d string) (io.WriteCloser, error) {
 		config: knownEventMetadataOne,
 	}, nil
 }
+
+func TestTypedMetadata(t *testing.T) {
+	row := func(value string) scoop_protocol.EventMetadataRow {
+		return scoop_protocol.EventMetadataRow{MetadataValue: value}
+	}
+	loader := &MetadataLoader{
+		configs: scoop_protocol.EventMetadataConfig{
+			Metadata: map[string](map[string]scoop_protocol.EventMetadataRow){
+				"flagged": {
+					"datastores":      row("ace, mirror,"),
+					"retention_class": row(" short "),
+					"pii":             row("true"),
+					"load_status":     row("Quarantine"),
+					"pii_columns":     row("login:hash, ip : Redact,email,"),
+				},
+				"plain": {
+					"pii":         row("sometimes"),
+					"load_status": row("paused"),
+				},
+			},
+		},
+		lock: &sync.RWMutex{},
+	}
+
+	assert.Equal(t, []string{"ace", "mirror"}, loader.TargetDatastores("flagged"))
+	assert.True(t, loader.LoadIntoAce("flagged"))
+	assert.Equal(t, "short", loader.RetentionClass("flagged"))
+	assert.True(t, loader.ContainsPII("flagged"))
+	assert.Equal(t, LoadQuarantined, loader.LoadStatus("flagged"))
+	assert.Equal(t, map[string]string{"login": "hash", "ip": "Redact", "email": ""}, loader.PIIColumns("flagged"),
+		"columns without a policy are left for the loader to reject")
+
+	assert.Nil(t, loader.TargetDatastores("plain"))
+	assert.False(t, loader.LoadIntoAce("plain"))
+	assert.False(t, loader.ContainsPII("plain"), "unparseable flags are false")
+	assert.True(t, loader.LoadStatus("plain").Diverted())
+	assert.Nil(t, loader.PIIColumns("plain"))
+
+	assert.Equal(t, LoadActive, loader.LoadStatus("missing"))
+	assert.False(t, loader.LoadStatus("missing").Diverted())
+}
+
+func TestShards(t *testing.T) {
+	row := func(value string) scoop_protocol.EventMetadataRow {
+		return scoop_protocol.EventMetadataRow{MetadataValue: value}
+	}
+	config := scoop_protocol.EventMetadataConfig{
+		Metadata: map[string](map[string]scoop_protocol.EventMetadataRow){
+			"minute_watched": {
+				"shard_column":  row("region"),
+				"shards":        row("eu:minute_watched_eu, na:minute_watched_na,ap:"),
+				"target_schema": row("video"),
+			},
+			"unsharded": {
+				"shards": row("eu:unsharded_eu"),
+			},
+		},
+	}
+	loader := &MetadataLoader{configs: config, shardOf: shardEvents(config), lock: &sync.RWMutex{}}
+
+	table, ok := loader.ShardTable("minute_watched", "processed/20180101/minute_watched/v3/region=eu/a.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "minute_watched_eu", table)
+	table, ok = loader.ShardTable("minute_watched", "processed/20180101/minute_watched/v3/region=ap/a.gz")
+	assert.False(t, ok, "values without a table have no shard")
+	assert.Equal(t, "minute_watched", table)
+	table, ok = loader.ShardTable("minute_watched", "processed/20180101/minute_watched/v3/a.gz")
+	assert.False(t, ok, "files without a value have no shard")
+	assert.Equal(t, "minute_watched", table)
+	table, ok = loader.ShardTable("unsharded", "processed/20180101/unsharded/v3/region=eu/a.gz")
+	assert.False(t, ok, "events without a shard column aren't sharded")
+	assert.Equal(t, "unsharded", table)
+
+	assert.Equal(t, []string{"minute_watched_eu", "minute_watched_na"}, loader.ShardTables("minute_watched"))
+	assert.Nil(t, loader.ShardTables("unsharded"))
+	assert.Equal(t, "minute_watched", loader.LogicalTable("minute_watched_na"))
+	assert.Equal(t, "unsharded_eu", loader.LogicalTable("unsharded_eu"))
+	assert.Equal(t, "video", loader.TargetSchema("minute_watched_eu"), "shards have their event's metadata")
+
+	client := New("blueprint", time.Minute, monitoring.NewMockStatter())
+	sharded := client.WithShards(loader)
+	assert.Equal(t, []string{"minute_watched", "minute_watched_eu", "minute_watched_na"},
+		sharded.ShardSiblings("minute_watched_eu"))
+	assert.Equal(t, "minute_watched", sharded.logicalTable("minute_watched_na"))
+	assert.Nil(t, sharded.ShardSiblings("unsharded"))
+	assert.Nil(t, client.ShardSiblings("minute_watched_eu"))
+}
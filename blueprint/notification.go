@@ -0,0 +1,109 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// snsMessageTypeHeader is the header SNS sends the type of an HTTP(S) delivery in.
+const snsMessageTypeHeader = "X-Amz-Sns-Message-Type"
+
+// SNS message types handled by the NotificationHandler.
+const (
+	snsSubscriptionConfirmation = "SubscriptionConfirmation"
+	snsNotification             = "Notification"
+)
+
+// maxNotificationBytes bounds the body of a notification; SNS messages are at most 256KB.
+const maxNotificationBytes = 512 * 1024
+
+// Reloader reloads Blueprint metadata right away.
+type Reloader interface {
+	Reload()
+}
+
+type snsMessage struct {
+	Type         string
+	TopicArn     string
+	SubscribeURL string
+}
+
+// NotificationHandler returns a handler reloading metadata when Blueprint publishes it, so new
+// metadata applies without waiting for the next reload. It takes a POST from Blueprint directly,
+// or from an SNS topic it publishes to: SNS subscription confirmations are confirmed, and if topicArn
+// is set, messages from other topics are rejected.
+func NotificationHandler(reloader Reloader, topicArn string) http.Handler {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		messageType := r.Header.Get(snsMessageTypeHeader)
+		if messageType == "" {
+			logger.WithField("remote_address", r.RemoteAddr).Info("Blueprint metadata updated; reloading")
+			reloader.Reload()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var message snsMessage
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNotificationBytes)).Decode(&message)
+		if err != nil {
+			http.Error(w, "Problem decoding SNS message.", http.StatusBadRequest)
+			return
+		}
+		if topicArn != "" && message.TopicArn != topicArn {
+			logger.WithField("topicArn", message.TopicArn).Warn("Rejected SNS message from unexpected topic")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		switch messageType {
+		case snsSubscriptionConfirmation:
+			err = confirmSubscription(client, message.SubscribeURL)
+			if err != nil {
+				logger.WithError(err).WithField("topicArn", message.TopicArn).Error("Error confirming SNS subscription")
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.WithField("topicArn", message.TopicArn).Info("Confirmed SNS subscription to Blueprint metadata updates")
+		case snsNotification:
+			logger.WithField("topicArn", message.TopicArn).Info("Blueprint metadata updated; reloading")
+			reloader.Reload()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// confirmSubscription visits an SNS subscription's SubscribeURL, which must be an HTTPS URL of
+// an AWS host so the handler can't be used to make arbitrary requests.
+func confirmSubscription(client *http.Client, subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil {
+		return fmt.Errorf("parsing SubscribeURL: %v", err)
+	}
+	if u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("SubscribeURL %q isn't an AWS HTTPS URL", subscribeURL)
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("visiting SubscribeURL: %v", err)
+	}
+	defer func() {
+		_, _ = ioutil.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			logger.WithError(err).Error("Error closing SNS subscription confirmation body")
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("visiting SubscribeURL: status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package blueprint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingReloader struct {
+	reloads int
+}
+
+func (r *countingReloader) Reload() {
+	r.reloads++
+}
+
+func TestNotificationHandler(t *testing.T) {
+	reloader := &countingReloader{}
+	h := NotificationHandler(reloader, "arn:aws:sns:us-west-2:123:blueprint")
+	call := func(method, messageType, body string) int {
+		r := httptest.NewRequest(method, "/control/bp_metadata_updated", strings.NewReader(body))
+		if messageType != "" {
+			r.Header.Set(snsMessageTypeHeader, messageType)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, call("GET", "", ""))
+	assert.Equal(t, http.StatusNoContent, call("POST", "", ""))
+	assert.Equal(t, 1, reloader.reloads)
+
+	assert.Equal(t, http.StatusNoContent, call("POST", snsNotification,
+		`{"Type": "Notification", "TopicArn": "arn:aws:sns:us-west-2:123:blueprint"}`))
+	assert.Equal(t, 2, reloader.reloads)
+	assert.Equal(t, http.StatusForbidden, call("POST", snsNotification,
+		`{"Type": "Notification", "TopicArn": "arn:aws:sns:us-west-2:123:other"}`))
+	assert.Equal(t, http.StatusBadRequest, call("POST", snsNotification, "not json"))
+	assert.Equal(t, http.StatusBadRequest, call("POST", snsSubscriptionConfirmation,
+		`{"TopicArn": "arn:aws:sns:us-west-2:123:blueprint", "SubscribeURL": "http://169.254.169.254/latest"}`))
+	assert.Equal(t, 2, reloader.reloads)
+}
@@ -0,0 +1,153 @@
+// Package chaos injects faults into the load path, so how files and loads recover from lost messages,
+// failed COPYs, slow uploads and dead workers can be tested in staging. The faults are only compiled
+// in with the chaos build tag, which also serves them at /debug/chaos on the pprof address; without
+// it, every hook is a no-op.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// Enabled is whether faults can be injected, i.e. the binary was built with the chaos tag.
+const Enabled = enabled
+
+// ErrInjectedCopy is the error of COPYs failed by fault injection.
+var ErrInjectedCopy = errors.New("COPY failed by fault injection")
+
+// Faults are the faults being injected. Zero values inject none.
+type Faults struct {
+	// DropSQSPercent is the percentage of SQS messages the metadatastorer deletes without storing
+	// their file
+	DropSQSPercent int
+	// FailCopyPercent is the percentage of loads whose COPY fails with ErrInjectedCopy
+	FailCopyPercent int
+	// UploadDelay is how long each manifest upload is held before it starts
+	UploadDelay time.Duration
+	// KillWorkers is how many more workers die with the load they're next assigned, leaving it
+	// assigned as if the process had crashed
+	KillWorkers int
+}
+
+// Validate returns an error if the faults aren't possible.
+func (f Faults) Validate() error {
+	if f.DropSQSPercent < 0 || f.DropSQSPercent > 100 || f.FailCopyPercent < 0 || f.FailCopyPercent > 100 {
+		return errors.New("percentages must be between 0 and 100")
+	}
+	if f.UploadDelay < 0 || f.KillWorkers < 0 {
+		return errors.New("the upload delay and workers to kill can't be negative")
+	}
+	return nil
+}
+
+// State is the faults being injected, and how many of each kind have been so far.
+type State struct {
+	Faults   Faults
+	Injected map[string]int64
+}
+
+var (
+	lock     sync.Mutex
+	faults   Faults
+	injected = make(map[string]int64)
+)
+
+// Set replaces the faults being injected.
+func Set(f Faults) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	faults = f
+	return nil
+}
+
+// Current returns the faults being injected and the counts of those injected.
+func Current() State {
+	lock.Lock()
+	defer lock.Unlock()
+	counts := make(map[string]int64, len(injected))
+	for kind, count := range injected {
+		counts[kind] = count
+	}
+	return State{Faults: faults, Injected: counts}
+}
+
+// record counts and logs an injected fault of the kind. The lock must be held.
+func record(kind string) {
+	injected[kind]++
+	logger.WithField("fault", kind).Warn("Injecting fault")
+}
+
+// roll returns whether to inject the kind of fault, which is injected percent of the time, recording
+// it if so.
+func roll(kind string, percent func(Faults) int) bool {
+	if !enabled {
+		return false
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if p := percent(faults); p <= 0 || rand.Intn(100) >= p {
+		return false
+	}
+	record(kind)
+	return true
+}
+
+// DropMessage returns whether to drop an SQS message without storing its file.
+func DropMessage() bool {
+	return roll("drop_sqs", func(f Faults) int { return f.DropSQSPercent })
+}
+
+// FailCopy returns ErrInjectedCopy if a load's COPY should fail.
+func FailCopy() error {
+	if roll("fail_copy", func(f Faults) int { return f.FailCopyPercent }) {
+		return ErrInjectedCopy
+	}
+	return nil
+}
+
+// KillWorker returns whether a worker should die with the load it was just assigned.
+func KillWorker() bool {
+	if !enabled {
+		return false
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if faults.KillWorkers <= 0 {
+		return false
+	}
+	faults.KillWorkers--
+	record("kill_worker")
+	return true
+}
+
+// DelayUpload holds a manifest upload for the upload delay, returning ctx's error if it's done first.
+func DelayUpload(ctx context.Context) error {
+	if !enabled {
+		return nil
+	}
+	lock.Lock()
+	delay := faults.UploadDelay
+	if delay > 0 {
+		record("delay_upload")
+	}
+	lock.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,34 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaults(t *testing.T) {
+	assert.Error(t, Set(Faults{FailCopyPercent: 101}))
+	assert.Error(t, Set(Faults{KillWorkers: -1}))
+	defer func() { assert.NoError(t, Set(Faults{})) }()
+
+	assert.NoError(t, Set(Faults{DropSQSPercent: 100, FailCopyPercent: 100, KillWorkers: 1}))
+	assert.Equal(t, Enabled, DropMessage())
+	if Enabled {
+		assert.Equal(t, ErrInjectedCopy, FailCopy())
+	} else {
+		assert.NoError(t, FailCopy(), "faults aren't injected without the chaos tag")
+	}
+	assert.Equal(t, Enabled, KillWorker())
+	assert.False(t, KillWorker(), "only KillWorkers workers die")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, Set(Faults{UploadDelay: time.Hour}))
+	if Enabled {
+		assert.Equal(t, context.Canceled, DelayUpload(ctx))
+	} else {
+		assert.NoError(t, DelayUpload(ctx))
+	}
+}
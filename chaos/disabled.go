@@ -0,0 +1,6 @@
+//go:build !chaos
+// +build !chaos
+
+package chaos
+
+const enabled = false
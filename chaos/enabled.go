@@ -0,0 +1,43 @@
+//go:build chaos
+// +build chaos
+
+package chaos
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+const enabled = true
+
+func init() {
+	http.HandleFunc("/debug/chaos", serveFaults)
+}
+
+// serveFaults responds to GETs with the faults being injected and the counts of those injected, and
+// replaces the faults with those POSTed.
+func serveFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var f Faults
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+			return
+		}
+		if err := Set(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.WithField("faults", f).Warn("Set faults to inject")
+	default:
+		http.Error(w, "GET or POST only.", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Current()); err != nil {
+		logger.WithError(err).Error("Error writing injected faults")
+	}
+}
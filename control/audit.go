@@ -0,0 +1,103 @@
+package control
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// maxAuditedBody is the most of a request's body, or of an error response, kept in its audit entry.
+const maxAuditedBody = 4096
+
+const defaultAuditLimit = 100
+
+// auditLog returns a middleware recording every request but GETs in the audit log, with its
+// caller, body and outcome. It must come after lib.AccessLogger, which sets the request ID.
+func (ch *Handler) auditLog(c *web.C, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "HEAD" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(r.Body)
+			if err != nil {
+				respondWithJSONError(w, "Problem reading request body.", http.StatusBadRequest)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		lw := mutil.WrapWriter(w)
+		var response bytes.Buffer
+		lw.Tee(&response)
+
+		h.ServeHTTP(lw, r)
+
+		entry := metadata.AuditEntry{
+			Time:      time.Now(),
+			RequestID: w.Header().Get(lib.RequestIDHeader),
+			Caller:    lib.Caller(r),
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Params:    truncate(string(body), maxAuditedBody),
+			Status:    lw.Status(),
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		if entry.Status >= 400 {
+			entry.Error = truncate(response.String(), maxAuditedBody)
+		}
+		if err := ch.cb.AddAuditEntry(entry); err != nil {
+			logger.WithError(err).WithField("request_id", entry.RequestID).Error("Error recording audit entry")
+		}
+	})
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// Audit returns the most recent audit entries, optionally filtered by the "caller" parameter
+// and by the "since" parameter, an RFC 3339 time. At most "limit" entries are returned,
+// default 100.
+func (ch *Handler) Audit(c web.C, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := metadata.AuditFilter{Caller: query.Get("caller"), Limit: defaultAuditLimit}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			respondWithJSONError(w, "limit must be a positive integer.", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if s := query.Get("since"); s != "" {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondWithJSONError(w, "since must be an RFC 3339 time.", http.StatusBadRequest)
+			return
+		}
+		filter.Since = &since
+	}
+	entries, err := ch.cb.AuditEntries(filter)
+	if err != nil {
+		logger.WithError(err).Error("Error getting audit entries")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, entries, http.StatusOK)
+}
@@ -0,0 +1,92 @@
+package control
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// BackfillRequest asks to queue the processed files under an S3 prefix.
+type BackfillRequest struct {
+	Bucket string
+	Prefix string
+	// Table, if set, only queues the files of this table
+	Table     string
+	Requester string
+}
+
+// backfillCounts counts what a backfill did with the files it listed.
+type backfillCounts struct {
+	queued, duplicate, skipped int
+}
+
+func (c backfillCounts) String() string {
+	return fmt.Sprintf("queued %d files, %d already queued, %d skipped", c.queued, c.duplicate, c.skipped)
+}
+
+// Backfill lists the request's prefix and queues its processed files at low priority, in the
+// background. Files whose table isn't known, or isn't the request's table, are skipped. Returns
+// the ID of the job tracking it.
+func (cBackend *Backend) Backfill(req BackfillRequest) string {
+	id := cBackend.jobs.start("backfill", req.Table)
+	logger.Go(func() {
+		counts, err := cBackend.backfill(id, req)
+		cBackend.jobs.setDetail(id, counts.String())
+		fields := logger.WithField("jobID", id).WithField("bucket", req.Bucket).WithField("prefix", req.Prefix).
+			WithField("requester", req.Requester).WithField("queued", counts.queued).
+			WithField("duplicate", counts.duplicate).WithField("skipped", counts.skipped)
+		if err != nil {
+			fields.WithError(err).Error("Error backfilling")
+		} else {
+			fields.Info("Finished backfill")
+		}
+		cBackend.jobs.finish(id, err)
+	})
+	return id
+}
+
+func (cBackend *Backend) backfill(id string, req BackfillRequest) (backfillCounts, error) {
+	var counts backfillCounts
+	var insertErr error
+	err := cBackend.s3.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(req.Bucket),
+		Prefix: aws.String(req.Prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			table, version, ok := metadata.ParseProcessedKey(key)
+			if !ok || strings.HasSuffix(key, "/") || (req.Table != "" && table != req.Table) {
+				counts.skipped++
+				continue
+			}
+			if _, known := cBackend.versions.Get(table); !known {
+				counts.skipped++
+				continue
+			}
+			err := cBackend.metaReader.InsertBackfillLoad(&metadata.Load{
+				KeyName:      req.Bucket + "/" + key,
+				TableName:    table,
+				TableVersion: version,
+			})
+			switch err {
+			case nil:
+				counts.queued++
+			case metadata.ErrDuplicateLoad:
+				counts.duplicate++
+			default:
+				insertErr = fmt.Errorf("queuing %s: %v", key, err)
+				return false
+			}
+		}
+		cBackend.jobs.setDetail(id, counts.String())
+		return true
+	})
+	if err != nil {
+		return counts, fmt.Errorf("listing s3://%s/%s: %v", req.Bucket, req.Prefix, err)
+	}
+	return counts, insertErr
+}
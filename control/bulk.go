@@ -0,0 +1,157 @@
+package control
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// BulkOp is an operation a bulk control request applies to many tables.
+type BulkOp string
+
+const (
+	// BulkPause stops the tables' loads from starting until they're resumed.
+	BulkPause BulkOp = "pause"
+
+	// BulkResume lets paused tables' loads start again.
+	BulkResume BulkOp = "resume"
+
+	// BulkForceLoad makes the tables the highest priority to load next.
+	BulkForceLoad BulkOp = "force_load"
+
+	// BulkRefresh re-reads the tables' versions from Ace, correcting those changed out-of-band.
+	BulkRefresh BulkOp = "refresh"
+)
+
+// BulkOperation applies Op to every table matching the Tables glob, where `*` matches any run of
+// characters and `?` any one character.
+type BulkOperation struct {
+	Op     BulkOp
+	Tables string
+	// Reason is recorded for pauses
+	Reason string
+}
+
+// BulkRequest asks to apply operations to many tables at once, in order.
+type BulkRequest struct {
+	Operations []BulkOperation
+	Requester  string
+}
+
+// BulkResult is the outcome of an operation on one table.
+type BulkResult struct {
+	Op    BulkOp
+	Table string
+	Error string `json:",omitempty"`
+	// Detail is what a refresh changed, if anything
+	Detail string `json:",omitempty"`
+}
+
+// bulkOperation is a validated BulkOperation with the tables it matched.
+type bulkOperation struct {
+	BulkOperation
+	tables []string
+}
+
+// Bulk applies the request's operations in order to the tables their globs match. Every operation
+// is checked and expanded before any runs, so a bad glob or an operation matching no tables fails
+// the whole request; after that, an error on one table doesn't stop the others. Returns the result
+// for each table of each operation.
+func (cBackend *Backend) Bulk(req BulkRequest) ([]BulkResult, error) {
+	known, err := cBackend.knownTables()
+	if err != nil {
+		return nil, err
+	}
+	var ops []bulkOperation
+	for _, op := range req.Operations {
+		switch op.Op {
+		case BulkPause, BulkResume, BulkForceLoad, BulkRefresh:
+		default:
+			return nil, fmt.Errorf("unknown operation %q", op.Op)
+		}
+		filter, err := metadata.NewTableFilter(metadata.TablePatterns{Include: []string{op.Tables}})
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s tables: %v", op.Op, err)
+		}
+		expanded := bulkOperation{BulkOperation: op}
+		for _, table := range known {
+			if filter.Allows(table) {
+				expanded.tables = append(expanded.tables, table)
+			}
+		}
+		if len(expanded.tables) == 0 {
+			return nil, fmt.Errorf("%s tables %q match no known table", op.Op, op.Tables)
+		}
+		ops = append(ops, expanded)
+	}
+
+	results := []BulkResult{}
+	for _, op := range ops {
+		if op.Op == BulkRefresh {
+			results = append(results, cBackend.bulkRefresh(op.tables)...)
+			continue
+		}
+		for _, table := range op.tables {
+			result := BulkResult{Op: op.Op, Table: table}
+			var err error
+			switch op.Op {
+			case BulkPause:
+				err = cBackend.PauseTable(metadata.PausedTable{Table: table, Reason: op.Reason, Requester: req.Requester})
+			case BulkResume:
+				err = cBackend.ResumeTable(table)
+			case BulkForceLoad:
+				err = cBackend.ForceLoad(table, req.Requester)
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// bulkRefresh re-reads every table version from Ace once, reporting the changes to the tables.
+func (cBackend *Backend) bulkRefresh(tables []string) []BulkResult {
+	changes, err := cBackend.RefreshVersions()
+	changed := make(map[string]string, len(changes))
+	for _, change := range changes {
+		cached := "none"
+		if change.Cached != nil {
+			cached = fmt.Sprint(*change.Cached)
+		}
+		changed[change.Table] = fmt.Sprintf("version %s corrected to %d", cached, change.Ace)
+	}
+	var results []BulkResult
+	for _, table := range tables {
+		result := BulkResult{Op: BulkRefresh, Table: table, Detail: changed[table]}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// knownTables returns the tables with a cached version or a pause, which bulk globs are matched
+// against, in order.
+func (cBackend *Backend) knownTables() ([]string, error) {
+	paused, err := cBackend.metaReader.PausedTables()
+	if err != nil {
+		return nil, fmt.Errorf("listing paused tables: %v", err)
+	}
+	seen := make(map[string]bool)
+	var tables []string
+	for _, table := range cBackend.versions.Tables() {
+		seen[table] = true
+		tables = append(tables, table)
+	}
+	for _, p := range paused {
+		if !seen[p.Table] {
+			tables = append(tables, p.Table)
+		}
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
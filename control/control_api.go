@@ -9,20 +9,120 @@ import (
 	"github.com/zenazn/goji/web/middleware"
 )
 
+// AuthConfig configures how callers of the control routes are authenticated
+type AuthConfig struct {
+	// Token, if set, may be given as "Authorization: Bearer <Token>"
+	Token string
+	// HMACKeys, if set, may sign requests; see lib.ControlAuth. They're named to identify callers.
+	HMACKeys map[string][]byte
+	// RequireClientCert requires a client certificate verified by the server's client CAs
+	RequireClientCert bool
+}
+
+// dashboardPath is the dashboard page, which is served without auth: it holds no data, and sends the
+// token it's given with its requests of the control routes.
+const dashboardPath = "/control/dashboard"
+
 // NewControlRouter instantiates an http.Handler with the control routes
-func NewControlRouter(cHandler *Handler) http.Handler {
+func NewControlRouter(cHandler *Handler, auth AuthConfig) http.Handler {
 	control := web.New()
 
 	control.Use(middleware.EnvInit)
 	control.Use(middleware.RequestID)
 	control.Use(middleware.RealIP)
-	control.Use(lib.SimpleLogger)
+	control.Use(lib.AccessLogger)
 	control.Use(context.ClearHandler)
+	if auth.RequireClientCert {
+		control.Use(lib.RequireClientCert)
+	}
+	if auth.Token != "" || len(auth.HMACKeys) > 0 {
+		control.Use(exceptDashboard(lib.ControlAuth(auth.Token, auth.HMACKeys)))
+	}
+	control.Use(cHandler.auditLog)
 
 	control.Post("/control/force_load", cHandler.ForceLoad)
+	control.Get("/control/force_load", cHandler.PendingForceLoads)
+	control.Delete("/control/force_load/:id", cHandler.CancelForceLoad)
 	control.Get("/control/table_exists/:id", cHandler.TableExists)
 	control.Post("/control/increment_version/:id", cHandler.IncrementVersion)
+	control.Post("/control/migrate/:id", cHandler.Migrate)
+	control.Post("/control/refresh_versions", cHandler.RefreshVersions)
+	control.Get("/control/versions", cHandler.Versions)
+	control.Get("/control/pin_version", cHandler.PinnedVersions)
+	control.Post("/control/pin_version/:id", cHandler.PinVersion)
+	control.Delete("/control/pin_version/:id", cHandler.UnpinVersion)
+	control.Post("/control/bp_metadata_updated", cHandler.BlueprintMetadataUpdated)
+	control.Get("/control/migrator", cHandler.MigratorState)
+	control.Get("/control/migrator/waits", cHandler.MigratorWaits)
+	control.Get("/control/offpeak", cHandler.OffpeakSchedule)
+	control.Post("/control/offpeak", cHandler.SetOffpeakSchedule)
+	control.Post("/control/backfill", cHandler.Backfill)
+	control.Post("/control/backfill/inventory", cHandler.InventoryBackfill)
+	control.Get("/control/backfill/inventory", cHandler.InventoryBackfillReport)
+	control.Post("/control/export/:table", cHandler.Export)
 	control.Get("/control/last_load", cHandler.LastLoad)
+	control.Get("/control/jobs/:id", cHandler.JobStatus)
+	control.Get("/control/load_trigger", cHandler.LoadTriggers)
+	control.Post("/control/load_trigger/:id", cHandler.SetLoadTrigger)
+	control.Delete("/control/load_trigger/:id", cHandler.DeleteLoadTrigger)
+	control.Get("/control/table_filter", cHandler.TableFilter)
+	control.Post("/control/table_filter", cHandler.SetTableFilter)
+	control.Delete("/control/table_filter", cHandler.ResetTableFilter)
+	control.Get("/control/copy_settings", cHandler.CopySettings)
+	control.Post("/control/copy_settings/:id", cHandler.SetCopySettings)
+	control.Delete("/control/copy_settings/:id", cHandler.DeleteCopySettings)
+	control.Get("/control/sampling", cHandler.SamplingRules)
+	control.Post("/control/sampling/:id", cHandler.SetSamplingRule)
+	control.Delete("/control/sampling/:id", cHandler.DeleteSamplingRule)
+	control.Get("/control/stragglers", cHandler.StragglerRules)
+	control.Post("/control/stragglers/:id", cHandler.SetStragglerRule)
+	control.Delete("/control/stragglers/:id", cHandler.DeleteStragglerRule)
+	control.Get("/control/compression", cHandler.CompressionRecommendations)
+	control.Get("/control/retention", cHandler.PlannedDeletions)
+	control.Post("/control/reload_config", cHandler.ReloadConfig)
+	control.Get("/control/slo", cHandler.SLOCompliance)
+	control.Get("/control/gaps", cHandler.Gaps)
+	control.Get("/control/paused", cHandler.PausedTables)
+	control.Post("/control/bulk", cHandler.Bulk)
+	control.Post("/control/query", cHandler.Query)
+	control.Get("/control/schema_diff/:table", cHandler.SchemaDiff)
+	control.Get("/control/force_load_schedule", cHandler.ForceLoadSchedules)
+	control.Post("/control/force_load_schedule/:id", cHandler.SetForceLoadSchedule)
+	control.Delete("/control/force_load_schedule/:id", cHandler.DeleteForceLoadSchedule)
+	control.Post("/control/slo/:id", cHandler.SetFreshnessSLO)
+	control.Delete("/control/slo/:id", cHandler.DeleteFreshnessSLO)
+	control.Get("/control/annotations/:id", cHandler.Annotations)
+	control.Post("/control/annotations/:id", cHandler.AddAnnotation)
+	control.Delete("/control/annotations/:id/:annotation", cHandler.DeleteAnnotation)
+	control.Get("/control/queue", cHandler.QueueStats)
+	control.Get("/control/loads/in_flight", cHandler.InFlightLoads)
+	control.Get("/control/loads/failed", cHandler.FailedLoads)
+	control.Get("/control/loads/events", cHandler.LoadEvents)
+	control.Get("/control/stats/loads", cHandler.LoadThroughput)
+	control.Get("/control/errors/:table", cHandler.TableErrors)
+	control.Get("/control/loads/:uuid", cHandler.LoadStatus)
+	control.Post("/control/cancel_load/:uuid", cHandler.CancelLoad)
+	control.Get("/control/toggles", cHandler.ComponentToggles)
+	control.Post("/control/toggles/:component", cHandler.SetComponentToggle)
+	control.Get("/control/maintenance", cHandler.MaintenanceWindows)
+	control.Post("/control/maintenance", cHandler.AddMaintenanceWindow)
+	control.Delete("/control/maintenance/:id", cHandler.DeleteMaintenanceWindow)
+	control.Get("/control/audit", cHandler.Audit)
+	control.Get(dashboardPath, cHandler.Dashboard)
 
 	return control
 }
+
+// exceptDashboard returns the middleware, skipped for GETs of the dashboard page.
+func exceptDashboard(m func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		checked := m(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && r.URL.Path == dashboardPath {
+				h.ServeHTTP(w, r)
+				return
+			}
+			checked.ServeHTTP(w, r)
+		})
+	}
+}
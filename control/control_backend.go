@@ -1,12 +1,121 @@
 package control
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pborman/uuid"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/blueprint"
+	"github.com/twitchscience/rs_ingester/gaps"
+	"github.com/twitchscience/rs_ingester/lib"
 	"github.com/twitchscience/rs_ingester/metadata"
 	"github.com/twitchscience/rs_ingester/migrator"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/rs_ingester/reporter"
+	"github.com/twitchscience/rs_ingester/retention"
+	"github.com/twitchscience/rs_ingester/schedule"
+	"github.com/twitchscience/rs_ingester/slo"
 	"github.com/twitchscience/rs_ingester/versions"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// CompressionReporter reports the latest column encoding recommendations
+type CompressionReporter interface {
+	CompressionRecommendations() []backend.CompressionRecommendation
+}
+
+// RetentionReporter reports the latest planned deletions of expired rows
+type RetentionReporter interface {
+	PlannedDeletions() []retention.Plan
+}
+
+// SLOReporter reports tables' compliance with their freshness SLOs
+type SLOReporter interface {
+	Compliance() []slo.Compliance
+}
+
+// ScheduleReporter reports tables' force load schedules and their last runs
+type ScheduleReporter interface {
+	Statuses() []schedule.Status
+}
+
+// MigratorReporter reports what the migrator is doing, and changes when it's offpeak
+type MigratorReporter interface {
+	State() migrator.State
+	Waits(minAge time.Duration) []migrator.TableWait
+	OffpeakSchedule() lib.OffpeakSchedule
+	SetOffpeakSchedule(lib.OffpeakSchedule)
+	PinVersion(table string, version int) error
+	UnpinVersion(table string) bool
+	Pins() map[string]int
+}
+
+// GapReporter reports the processed files last found missing from ingesterdb
+type GapReporter interface {
+	LastReport() (gaps.Report, bool)
+}
+
+// ConfigReloader re-reads the config file and applies its tunables
+type ConfigReloader interface {
+	ReloadConfig() (lib.Tunables, error)
+}
+
+// LoadCanceler cancels the COPYs of a running load
+type LoadCanceler interface {
+	CancelLoad(manifestUUID string) (int, error)
+}
+
+// Querier runs read-only queries of Redshift system tables
+type Querier interface {
+	Query(q redshift.Query, timeout time.Duration) (*redshift.Table, error)
+}
+
+// SchemaDiffer compares tables' live columns with blueprint's schemas
+type SchemaDiffer interface {
+	SchemaDiff(table string, version int) (backend.SchemaDiff, error)
+}
+
+// VersionSource reads the tables' versions from Ace
+type VersionSource interface {
+	TableVersions() (map[string]int, error)
+}
+
+// Exporter UNLOADs snapshots of tables to S3
+type Exporter interface {
+	Export(table string, req backend.ExportRequest) (*backend.ExportStats, error)
+}
+
+// LoadInspector finds a load's manifest and checks its transaction in Redshift
+type LoadInspector interface {
+	ManifestURL(manifest *metadata.LoadManifest) (string, error)
+	CheckLoad(manifestUUID string) (scoop_protocol.LoadStatus, error)
+}
+
+// LoadStatus is the state of a load in ingesterdb and in Redshift. RedshiftStatus is CheckLoadStatus's
+// disposition of the load's COPY transaction, which reads as failed while the COPY is still running.
+type LoadStatus struct {
+	metadata.LoadDetail
+	ManifestURL    string                    `json:",omitempty"`
+	RedshiftStatus scoop_protocol.LoadStatus `json:",omitempty"`
+	RedshiftError  string                    `json:",omitempty"`
+}
+
+var (
+	errLoadNotInFlight = errors.New("load is not in flight")
+	errNoRunningCopy   = errors.New("load has no running COPY")
+	errNoLoader        = errors.New("ingester isn't running loads")
+	errNoRetention     = errors.New("retention isn't enabled")
+	errNoBpMetadata    = errors.New("blueprint metadata isn't loaded")
+	errNoSLOs          = errors.New("SLO evaluation isn't enabled")
+	errNoGapCheck      = errors.New("gap checking isn't enabled")
+	errNoSchedules     = errors.New("force load scheduling isn't enabled")
+	errNoGapReport     = errors.New("no gap check has finished yet")
 )
 
 // Backend is the backend for control, which operates on the ingester
@@ -14,13 +123,71 @@ type Backend struct {
 	metaReader       metadata.Reader
 	metaBackend      metadata.Backend
 	versions         versions.Getter
-	versionIncrement chan migrator.VersionIncrement
+	versionIncrement chan bool
+	migrations       chan migrator.MigrationRequest
+	versionRefreshes chan migrator.VersionRefresh
+	compression      CompressionReporter
+	retention        RetentionReporter
+	canceler         LoadCanceler
+	inspector        LoadInspector
+	s3               s3iface.S3API
+	migratorTimeout  time.Duration
+	migratorState    MigratorReporter
+	bpMetadata       blueprint.Reloader
+	slos             SLOReporter
+	configReloader   ConfigReloader
+	gaps             GapReporter
+	schedules        ScheduleReporter
+	querier          Querier
+	schemaDiffer     SchemaDiffer
+	aceVersions      VersionSource
+	exporter         Exporter
+	jobs             *jobTracker
+	// inventoryRunning are the manifests of the S3 Inventory reports being backfilled
+	inventoryRunning map[string]bool
+	inventoryLock    sync.Mutex
 }
 
-// NewControlBackend instantiates the control backend with a db connection
+// NewControlBackend instantiates the control backend with a db connection. Requests handed
+// to the migrator are abandoned if they don't complete within migratorTimeout. Backfills list
+// buckets with s3Client. retention is nil if expired rows aren't deleted, inspector nil if the
+// ingester doesn't run loads, slos nil if SLOs aren't evaluated, gapReporter nil if gaps aren't
+// checked, and schedules nil if force loads aren't scheduled. querier runs ad-hoc queries of system tables,
+// schemaDiffer compares live tables with blueprint, aceVersions reads the tables' versions in Ace, and
+// exporter exports tables.
 func NewControlBackend(metaReader metadata.Reader, metaBackend metadata.Backend, tableVersions versions.Getter,
-	versionIncrement chan migrator.VersionIncrement) *Backend {
-	return &Backend{metaReader, metaBackend, tableVersions, versionIncrement}
+	versionIncrement chan bool, migrations chan migrator.MigrationRequest,
+	versionRefreshes chan migrator.VersionRefresh, compression CompressionReporter, retention RetentionReporter,
+	canceler LoadCanceler, inspector LoadInspector, s3Client s3iface.S3API, migratorTimeout time.Duration,
+	migratorState MigratorReporter, bpMetadata blueprint.Reloader, slos SLOReporter,
+	configReloader ConfigReloader, gapReporter GapReporter, schedules ScheduleReporter, querier Querier,
+	schemaDiffer SchemaDiffer, aceVersions VersionSource, exporter Exporter) *Backend {
+	return &Backend{
+		metaReader:       metaReader,
+		metaBackend:      metaBackend,
+		versions:         tableVersions,
+		versionIncrement: versionIncrement,
+		migrations:       migrations,
+		versionRefreshes: versionRefreshes,
+		compression:      compression,
+		retention:        retention,
+		canceler:         canceler,
+		inspector:        inspector,
+		s3:               s3Client,
+		migratorTimeout:  migratorTimeout,
+		migratorState:    migratorState,
+		bpMetadata:       bpMetadata,
+		slos:             slos,
+		configReloader:   configReloader,
+		gaps:             gapReporter,
+		schedules:        schedules,
+		querier:          querier,
+		schemaDiffer:     schemaDiffer,
+		aceVersions:      aceVersions,
+		exporter:         exporter,
+		jobs:             newJobTracker(),
+		inventoryRunning: make(map[string]bool),
+	}
 }
 
 // ForceLoad makes the given table the highest priority to load next
@@ -32,27 +199,545 @@ func (cBackend *Backend) ForceLoad(tableName string, requester string) error {
 	return nil
 }
 
+// PendingForceLoads returns the force loads that haven't started, with the requests merged into each.
+func (cBackend *Backend) PendingForceLoads() ([]metadata.PendingForceLoad, error) {
+	return cBackend.metaReader.PendingForceLoads()
+}
+
+// CancelForceLoad cancels the table's pending force load, returning whether it had one.
+func (cBackend *Backend) CancelForceLoad(tableName string) (bool, error) {
+	return cBackend.metaReader.CancelForceLoad(tableName)
+}
+
 // TableExists returns whether the given table name exists in our version dictionary.
 func (cBackend *Backend) TableExists(tableName string) bool {
 	_, exists := cBackend.versions.Get(tableName)
 	return exists
 }
 
-// IncrementVersion increments the given table's version in the migrator goroutine.
-func (cBackend *Backend) IncrementVersion(tableName string) error {
-	errChan := make(chan error)
+// IncrementVersion queues an increment of the given table's version in ingesterdb, notifying the
+// migrator, and returns the ID of the job tracking it. The increment is kept until the migrator
+// finishes it, even across restarts.
+func (cBackend *Backend) IncrementVersion(tableName string) (string, error) {
 	curVersion, ok := cBackend.versions.Get(tableName)
 	if !ok {
 		curVersion = -1
 	}
-	version := curVersion + 1
-	cBackend.versionIncrement <- migrator.VersionIncrement{
-		Table: tableName, Version: version, Response: errChan,
+	id := uuid.NewRandom().String()
+	err := cBackend.metaReader.QueueVersionIncrement(id, tableName, curVersion+1)
+	if err != nil {
+		return "", err
+	}
+	// The migrator also finds the increment on its next poll, so it needn't be told twice
+	select {
+	case cBackend.versionIncrement <- true:
+	default:
 	}
-	err := <-errChan
+	return id, nil
+}
+
+// Migrate has the migrator migrate the table to the given version now, outside of offpeak hours,
+// and waits for the result.
+func (cBackend *Backend) Migrate(tableName string, version int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cBackend.migratorTimeout)
+	defer cancel()
+	// Buffered so the migrator never blocks responding to a request we gave up on.
+	errChan := make(chan error, 1)
+	select {
+	case cBackend.migrations <- migrator.MigrationRequest{Table: tableName, Version: version, Response: errChan}:
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for migrator to accept request: %v", ctx.Err())
+	}
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for migrator to respond: %v", ctx.Err())
+	}
+}
+
+// RefreshVersions has the migrator re-read the table versions from Ace and waits for the tables
+// whose cached versions it corrected.
+func (cBackend *Backend) RefreshVersions() ([]migrator.VersionChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cBackend.migratorTimeout)
+	defer cancel()
+	// Buffered so the migrator never blocks responding to a request we gave up on.
+	resultChan := make(chan migrator.VersionRefreshResult, 1)
+	select {
+	case cBackend.versionRefreshes <- migrator.VersionRefresh{Response: resultChan}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for migrator to accept request: %v", ctx.Err())
+	}
+	select {
+	case result := <-resultChan:
+		return result.Changes, result.Err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for migrator to respond: %v", ctx.Err())
+	}
+}
+
+// JobStatus returns the status of an asynchronous control job, whether it's tracked in memory or
+// is a version increment queued in ingesterdb.
+func (cBackend *Backend) JobStatus(id string) (JobStatus, bool, error) {
+	if status, ok := cBackend.jobs.get(id); ok {
+		return status, true, nil
+	}
+	if uuid.Parse(id) == nil {
+		return JobStatus{}, false, nil
+	}
+	increment, err := cBackend.metaReader.VersionIncrement(id)
+	if err == metadata.ErrUnknownVersionIncrement {
+		return JobStatus{}, false, nil
+	}
+	if err != nil {
+		return JobStatus{}, false, err
+	}
+	status := JobStatus{
+		ID:        increment.ID,
+		Kind:      "increment_version",
+		Table:     increment.Table,
+		State:     JobPending,
+		Error:     increment.Error,
+		Detail:    fmt.Sprintf("version %d", increment.Version),
+		Submitted: increment.Requested,
+		Finished:  increment.Finished,
+	}
+	if increment.Finished != nil {
+		status.State = JobSucceeded
+		if increment.Error != "" {
+			status.State = JobFailed
+		}
+	}
+	return status, true, nil
+}
+
+// LoadTriggers returns the per-table load trigger overrides.
+func (cBackend *Backend) LoadTriggers() ([]metadata.LoadTrigger, error) {
+	return cBackend.metaReader.LoadTriggers()
+}
+
+// SetLoadTrigger overrides the load triggers for a table.
+func (cBackend *Backend) SetLoadTrigger(trigger metadata.LoadTrigger) error {
+	return cBackend.metaReader.SetLoadTrigger(trigger)
+}
+
+// DeleteLoadTrigger reverts a table to the global load triggers.
+func (cBackend *Backend) DeleteLoadTrigger(tableName string) error {
+	return cBackend.metaReader.DeleteLoadTrigger(tableName)
+}
+
+// CopySettings returns the per-table COPY setting overrides.
+func (cBackend *Backend) CopySettings() ([]metadata.CopySettings, error) {
+	return cBackend.metaReader.CopySettings()
+}
+
+// SetCopySettings overrides the COPY settings for a table.
+func (cBackend *Backend) SetCopySettings(settings metadata.CopySettings) error {
+	return cBackend.metaReader.SetCopySettings(settings)
+}
+
+// SamplingRules returns the per-table sampling rules.
+func (cBackend *Backend) SamplingRules() ([]metadata.SamplingRule, error) {
+	return cBackend.metaReader.SamplingRules()
+}
+
+// SetSamplingRule samples a table's files.
+func (cBackend *Backend) SetSamplingRule(rule metadata.SamplingRule) error {
+	return cBackend.metaReader.SetSamplingRule(rule)
+}
+
+// DeleteSamplingRule stops sampling a table's files.
+func (cBackend *Backend) DeleteSamplingRule(tableName string) error {
+	return cBackend.metaReader.DeleteSamplingRule(tableName)
+}
+
+// StragglerRules returns the per-table straggler rules.
+func (cBackend *Backend) StragglerRules() ([]metadata.StragglerRule, error) {
+	return cBackend.metaReader.StragglerRules()
+}
+
+// SetStragglerRule sets how a table's files of outdated versions are handled.
+func (cBackend *Backend) SetStragglerRule(rule metadata.StragglerRule) error {
+	return cBackend.metaReader.SetStragglerRule(rule)
+}
+
+// DeleteStragglerRule leaves a table's files of outdated versions queued.
+func (cBackend *Backend) DeleteStragglerRule(tableName string) error {
+	return cBackend.metaReader.DeleteStragglerRule(tableName)
+}
+
+// DeleteCopySettings reverts a table to the default COPY settings.
+func (cBackend *Backend) DeleteCopySettings(tableName string) error {
+	return cBackend.metaReader.DeleteCopySettings(tableName)
+}
+
+// CompressionRecommendations returns the latest column encoding recommendations.
+func (cBackend *Backend) CompressionRecommendations() []backend.CompressionRecommendation {
+	return cBackend.compression.CompressionRecommendations()
+}
+
+// PlannedDeletions returns the latest planned deletions of expired rows.
+func (cBackend *Backend) PlannedDeletions() ([]retention.Plan, error) {
+	if cBackend.retention == nil {
+		return nil, errNoRetention
+	}
+	return cBackend.retention.PlannedDeletions(), nil
+}
+
+// SLOCompliance returns tables' compliance with their freshness SLOs as of the last evaluation.
+func (cBackend *Backend) SLOCompliance() ([]slo.Compliance, error) {
+	if cBackend.slos == nil {
+		return nil, errNoSLOs
+	}
+	return cBackend.slos.Compliance(), nil
+}
+
+// SetFreshnessSLO sets a table's freshness SLO, overriding the config's.
+func (cBackend *Backend) SetFreshnessSLO(freshnessSLO metadata.FreshnessSLO) error {
+	return cBackend.metaReader.SetFreshnessSLO(freshnessSLO)
+}
+
+// DeleteFreshnessSLO removes a table's freshness SLO set through the control API.
+func (cBackend *Backend) DeleteFreshnessSLO(tableName string) error {
+	return cBackend.metaReader.DeleteFreshnessSLO(tableName)
+}
+
+// ForceLoadSchedules returns tables' force load schedules as of the last check.
+func (cBackend *Backend) ForceLoadSchedules() ([]schedule.Status, error) {
+	if cBackend.schedules == nil {
+		return nil, errNoSchedules
+	}
+	return cBackend.schedules.Statuses(), nil
+}
+
+// SetForceLoadSchedule sets when a table is force loaded, overriding the config's schedule.
+func (cBackend *Backend) SetForceLoadSchedule(forceLoadSchedule metadata.ForceLoadSchedule) error {
+	return cBackend.metaReader.SetForceLoadSchedule(forceLoadSchedule)
+}
+
+// DeleteForceLoadSchedule removes a table's force load schedule set through the control API.
+func (cBackend *Backend) DeleteForceLoadSchedule(tableName string) error {
+	return cBackend.metaReader.DeleteForceLoadSchedule(tableName)
+}
+
+// Query runs a read-only query of a Redshift system table, waiting a little longer than its
+// statement timeout for its rows.
+func (cBackend *Backend) Query(q redshift.Query) (*redshift.Table, error) {
+	return cBackend.querier.Query(q, redshift.QueryTimeout+10*time.Second)
+}
+
+// SchemaDiff compares the table's live columns with blueprint's schema at the version, or at the
+// table's current version if the version is negative.
+func (cBackend *Backend) SchemaDiff(table string, version int) (backend.SchemaDiff, error) {
+	return cBackend.schemaDiffer.SchemaDiff(table, version)
+}
+
+// PausedTables returns the tables whose loads are paused.
+func (cBackend *Backend) PausedTables() ([]metadata.PausedTable, error) {
+	return cBackend.metaReader.PausedTables()
+}
+
+// PauseTable stops a table's loads from starting until it's resumed.
+func (cBackend *Backend) PauseTable(paused metadata.PausedTable) error {
+	return cBackend.metaReader.PauseTable(paused)
+}
+
+// ResumeTable lets a paused table's loads start again.
+func (cBackend *Backend) ResumeTable(tableName string) error {
+	return cBackend.metaReader.ResumeTable(tableName)
+}
+
+// ComponentToggles returns whether each component is switched off.
+func (cBackend *Backend) ComponentToggles() ([]metadata.ComponentToggle, error) {
+	return cBackend.metaReader.ComponentToggles()
+}
+
+// SetComponentToggle switches the component off or on. The components check their toggles as they
+// work, so it applies without restarting them, and survives restarts.
+func (cBackend *Backend) SetComponentToggle(toggle metadata.ComponentToggle) error {
+	return cBackend.metaReader.SetComponentToggle(toggle)
+}
+
+// Gaps returns the report of the last check for processed files missing from ingesterdb.
+func (cBackend *Backend) Gaps() (gaps.Report, error) {
+	if cBackend.gaps == nil {
+		return gaps.Report{}, errNoGapCheck
+	}
+	report, ok := cBackend.gaps.LastReport()
+	if !ok {
+		return gaps.Report{}, errNoGapReport
+	}
+	return report, nil
+}
+
+// ReloadConfig re-reads the config file and applies its tunables, returning them.
+func (cBackend *Backend) ReloadConfig() (lib.Tunables, error) {
+	return cBackend.configReloader.ReloadConfig()
+}
+
+// MigratorState returns what the migrator is doing.
+func (cBackend *Backend) MigratorState() migrator.State {
+	return cBackend.migratorState.State()
+}
+
+// MigratorWaits returns the tables waiting to be migrated that have been in their wait state for longer
+// than minAge.
+func (cBackend *Backend) MigratorWaits(minAge time.Duration) []migrator.TableWait {
+	return cBackend.migratorState.Waits(minAge)
+}
+
+// OffpeakSchedule returns the migrator's offpeak schedule.
+func (cBackend *Backend) OffpeakSchedule() lib.OffpeakSchedule {
+	return cBackend.migratorState.OffpeakSchedule()
+}
+
+// SetOffpeakSchedule overrides the migrator's offpeak schedule until a config reload changes the
+// tunables' schedule, or the ingester restarts.
+func (cBackend *Backend) SetOffpeakSchedule(schedule lib.OffpeakSchedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	cBackend.migratorState.SetOffpeakSchedule(schedule)
+	return nil
+}
+
+// PinVersion has loads treat the table as being at the version, which mustn't be newer than its own,
+// and blocks its migrations until it's unpinned or the ingester restarts.
+func (cBackend *Backend) PinVersion(table string, version int) error {
+	return cBackend.migratorState.PinVersion(table, version)
+}
+
+// UnpinVersion removes the table's pin, returning whether it had one.
+func (cBackend *Backend) UnpinVersion(table string) bool {
+	return cBackend.migratorState.UnpinVersion(table)
+}
+
+// PinnedVersions returns the version each pinned table is pinned to.
+func (cBackend *Backend) PinnedVersions() map[string]int {
+	return cBackend.migratorState.Pins()
+}
+
+// VersionsReport is the tables' versions in the ingester's cache and in Ace, and how many of them
+// drifted apart.
+type VersionsReport struct {
+	Tables  []versions.TableVersion
+	Drifted int
+}
+
+// Versions compares the tables' versions in the ingester's cache with their versions in Ace.
+func (cBackend *Backend) Versions() (VersionsReport, error) {
+	ace, err := cBackend.aceVersions.TableVersions()
+	if err != nil {
+		return VersionsReport{}, fmt.Errorf("getting table versions from ace: %v", err)
+	}
+	report := VersionsReport{Tables: versions.Compare(cBackend.versions.Snapshot(), ace)}
+	for _, tv := range report.Tables {
+		if tv.Drift {
+			report.Drifted++
+		}
+	}
+	return report, nil
+}
+
+// ReloadBlueprintMetadata has the Blueprint metadata reloaded right away.
+func (cBackend *Backend) ReloadBlueprintMetadata() error {
+	if cBackend.bpMetadata == nil {
+		return errNoBpMetadata
+	}
+	cBackend.bpMetadata.Reload()
+	return nil
+}
+
+// AddAnnotation attaches an operator's note to a table or one of its loads.
+func (cBackend *Backend) AddAnnotation(annotation metadata.Annotation) (int64, error) {
+	return cBackend.metaReader.AddAnnotation(annotation)
+}
+
+// Annotations returns the notes attached to a table and its loads.
+func (cBackend *Backend) Annotations(tableName string) ([]metadata.Annotation, error) {
+	return cBackend.metaReader.Annotations(tableName)
+}
+
+// DeleteAnnotation removes an operator's note from the table, returning whether the table had it.
+func (cBackend *Backend) DeleteAnnotation(tableName string, id int64) (bool, error) {
+	return cBackend.metaReader.DeleteAnnotation(tableName, id)
+}
+
+// QueueStats returns the queued TSVs per event, grouped by why they're pending.
+func (cBackend *Backend) QueueStats() ([]*metadata.PendingLoadStats, error) {
+	return cBackend.metaReader.StatsForPendingLoads()
+}
+
+// InFlightLoads returns the loads currently being run by workers.
+func (cBackend *Backend) InFlightLoads() ([]metadata.LoadSummary, error) {
+	return cBackend.metaReader.InFlightLoads()
+}
+
+// LoadStatus returns the state of a load, or metadata.ErrUnknownLoad. Errors looking the load up in
+// Redshift are reported in the status rather than failing it.
+func (cBackend *Backend) LoadStatus(manifestUUID string) (*LoadStatus, error) {
+	detail, err := cBackend.metaReader.LoadDetail(manifestUUID)
+	if err != nil {
+		return nil, err
+	}
+	status := &LoadStatus{LoadDetail: *detail}
+	if cBackend.inspector == nil {
+		return status, nil
+	}
+
+	manifest := &metadata.LoadManifest{UUID: manifestUUID, TableName: detail.Table}
+	for _, file := range detail.Files {
+		manifest.Loads = append(manifest.Loads, metadata.Load{KeyName: file.KeyName, TableName: detail.Table})
+	}
+	status.ManifestURL, err = cBackend.inspector.ManifestURL(manifest)
+	if err != nil {
+		logger.WithError(err).WithField("manifestUUID", manifestUUID).Warn("Error finding manifest URL")
+	}
+	status.RedshiftStatus, err = cBackend.inspector.CheckLoad(manifestUUID)
+	if err != nil {
+		status.RedshiftError = err.Error()
+	}
+	return status, nil
+}
+
+// CancelLoad cancels the running COPY of an in-flight load. The COPY fails in its worker, which
+// marks the load for retry.
+func (cBackend *Backend) CancelLoad(manifestUUID string) error {
+	loads, err := cBackend.metaReader.InFlightLoads()
+	if err != nil {
+		return fmt.Errorf("getting in-flight loads: %v", err)
+	}
+	inFlight := false
+	for _, load := range loads {
+		if load.UUID == manifestUUID {
+			inFlight = true
+			break
+		}
+	}
+	if !inFlight {
+		return errLoadNotInFlight
+	}
+
+	canceled, err := cBackend.canceler.CancelLoad(manifestUUID)
+	if err != nil {
+		return fmt.Errorf("canceling load: %v", err)
+	}
+	if canceled == 0 {
+		return errNoRunningCopy
+	}
+	return nil
+}
+
+// FailedLoads returns the most recent loads waiting to be retried.
+func (cBackend *Backend) FailedLoads(limit int) ([]metadata.LoadSummary, error) {
+	return cBackend.metaReader.FailedLoads(limit)
+}
+
+// LoadEvents returns the most recent load events matching the filter.
+func (cBackend *Backend) LoadEvents(filter metadata.LoadEventFilter) ([]metadata.LoadEvent, error) {
+	return cBackend.metaReader.LoadEvents(filter)
+}
+
+// TableErrors is a table's recent load failures, and how often its loads failed per hour over the
+// last day.
+type TableErrors struct {
+	Table  string
+	Recent []metadata.TableLoadError
+	Trend  reporter.ErrorTrend
+}
+
+// TableErrors returns up to limit of the table's load failures over the last day, newest first, and
+// their trend.
+func (cBackend *Backend) TableErrors(table string, limit int) (*TableErrors, error) {
+	now := time.Now().In(time.UTC)
+	recent, err := cBackend.metaReader.TableLoadErrors(table, now.Add(-reporter.ErrorTrendWindow), limit)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := cBackend.metaReader.LoadErrorCounts(table, now, reporter.ErrorTrendWindow)
+	if err != nil {
+		return nil, err
+	}
+	errs := &TableErrors{Table: table, Recent: recent}
+	if trend, ok := reporter.NewErrorTrends(counts)[table]; ok {
+		errs.Trend = *trend
+	} else {
+		errs.Trend = reporter.ErrorTrend{
+			Hourly:  make([]int64, int(reporter.ErrorTrendWindow/time.Hour)),
+			ByClass: map[string]int64{},
+		}
+	}
+	return errs, nil
+}
+
+// LoadThroughput returns what was loaded into each table since the time, and how often loads failed.
+func (cBackend *Backend) LoadThroughput(since time.Time) ([]metadata.TableThroughput, error) {
+	return cBackend.metaReader.LoadThroughput(since)
+}
+
+// AddAuditEntry records a control request in the audit log.
+func (cBackend *Backend) AddAuditEntry(entry metadata.AuditEntry) error {
+	return cBackend.metaReader.AddAuditEntry(entry)
+}
+
+// AuditEntries returns the most recent audit entries matching the filter.
+func (cBackend *Backend) AuditEntries(filter metadata.AuditFilter) ([]metadata.AuditEntry, error) {
+	return cBackend.metaReader.AuditEntries(filter)
+}
+
+// MaintenanceWindows returns the current and upcoming maintenance windows.
+func (cBackend *Backend) MaintenanceWindows() ([]metadata.MaintenanceWindow, error) {
+	return cBackend.metaReader.MaintenanceWindows()
+}
+
+// AddMaintenanceWindow schedules a maintenance window, pausing loads and migrations during it.
+func (cBackend *Backend) AddMaintenanceWindow(window metadata.MaintenanceWindow) (int64, error) {
+	return cBackend.metaReader.AddMaintenanceWindow(window)
+}
+
+// DeleteMaintenanceWindow removes a maintenance window, resuming loads if it was in progress.
+func (cBackend *Backend) DeleteMaintenanceWindow(id int64) error {
+	return cBackend.metaReader.DeleteMaintenanceWindow(id)
+}
+
+// InMaintenance returns whether a maintenance window is in progress.
+func (cBackend *Backend) InMaintenance() (bool, error) {
+	return cBackend.metaReader.InMaintenance()
+}
+
+// tableFilter returns the filter of the tables this ingester loads.
+func (cBackend *Backend) tableFilter() (*metadata.TableFilter, error) {
+	if cBackend.metaBackend == nil || cBackend.metaBackend.TableFilter() == nil {
+		return nil, errNoLoader
+	}
+	return cBackend.metaBackend.TableFilter(), nil
+}
+
+// TableFilter returns the patterns of the tables this ingester loads.
+func (cBackend *Backend) TableFilter() (metadata.TablePatterns, error) {
+	filter, err := cBackend.tableFilter()
+	if err != nil {
+		return metadata.TablePatterns{}, err
+	}
+	return filter.Patterns(), nil
+}
+
+// SetTableFilter overrides the patterns of the tables this ingester loads until it restarts.
+func (cBackend *Backend) SetTableFilter(patterns metadata.TablePatterns) error {
+	filter, err := cBackend.tableFilter()
+	if err != nil {
+		return err
+	}
+	return filter.Set(patterns)
+}
+
+// ResetTableFilter restores the table patterns given by flags.
+func (cBackend *Backend) ResetTableFilter() error {
+	filter, err := cBackend.tableFilter()
 	if err != nil {
-		return fmt.Errorf("error setting table '%s' to version '%d': %v", tableName, version, err)
+		return err
 	}
+	filter.Reset()
 	return nil
 }
 
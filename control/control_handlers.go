@@ -2,13 +2,29 @@ package control
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/pborman/uuid"
 	"github.com/twitchscience/aws_utils/logger"
 	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/rs_ingester/schedule"
 	"github.com/zenazn/goji/web"
 )
 
+const (
+	defaultFailedLoadsLimit = 50
+	defaultLoadEventsLimit  = 100
+	defaultStatsWindow      = 24 * time.Hour
+)
+
 // Handler is a handler for control
 type Handler struct {
 	cb    *Backend
@@ -21,11 +37,14 @@ func NewControlHandler(ch *Backend, stats monitoring.SafeStatter) *Handler {
 }
 
 // respondWithJSONError responds with a JSON error with the given error code. The format of the
-// JSON error is {"Error": text}
+// JSON error is {"Error": text, "RequestID": id}, with the request ID set by lib.AccessLogger.
 //	It's very likely that you want to return from the handler after calling
 //	this.
 func respondWithJSONError(w http.ResponseWriter, text string, responseCode int) {
-	js, err := json.Marshal(struct{ Error string }{text})
+	js, err := json.Marshal(struct {
+		Error     string
+		RequestID string `json:",omitempty"`
+	}{text, w.Header().Get(lib.RequestIDHeader)})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -39,6 +58,21 @@ func respondWithJSONError(w http.ResponseWriter, text string, responseCode int)
 	}
 }
 
+// respondWithJSON responds with the JSON encoding of v and the given response code.
+func respondWithJSON(w http.ResponseWriter, v interface{}, responseCode int) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(responseCode)
+	_, err = w.Write(js)
+	if err != nil {
+		logger.WithError(err).Error("Error writing JSON response")
+	}
+}
+
 // ForceLoad forces ingest of a particular table. Takes a JSON POST containing the
 // Table and Requester fields, representing the what to force load and who wants it.
 func (ch *Handler) ForceLoad(c web.C, w http.ResponseWriter, r *http.Request) {
@@ -65,7 +99,37 @@ func (ch *Handler) ForceLoad(c web.C, w http.ResponseWriter, r *http.Request) {
 		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	ch.stats.SafeInc("force_load."+tableArg.Table, 1, 1.0)
+	lib.TableInc(ch.stats, "force_load", tableArg.Table, 1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PendingForceLoads returns a JSON list of the force loads that haven't started, oldest first. Each
+// counts the later requests for its table merged into it.
+func (ch *Handler) PendingForceLoads(c web.C, w http.ResponseWriter, r *http.Request) {
+	pending, err := ch.cb.PendingForceLoads()
+	if err != nil {
+		logger.WithError(err).Error("Error listing pending force loads")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, pending, http.StatusOK)
+}
+
+// CancelForceLoad cancels the table's pending force load. Responds with 204, or 404 if the table has
+// no pending force load.
+func (ch *Handler) CancelForceLoad(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	canceled, err := ch.cb.CancelForceLoad(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error canceling force load")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !canceled {
+		respondWithJSONError(w, "Table has no pending force load.", http.StatusNotFound)
+		return
+	}
+	lib.TableInc(ch.stats, "force_load.canceled", table, 1)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -88,18 +152,219 @@ func (ch *Handler) TableExists(c web.C, w http.ResponseWriter, r *http.Request)
 	}
 }
 
-// IncrementVersion sets the table's version in infra.table_version to the given version.
+// IncrementVersion queues setting the table's version in infra.table_version to the next version.
+// Responds with 202 and the URL of the job's status, since the migrator may be busy for a while.
 func (ch *Handler) IncrementVersion(c web.C, w http.ResponseWriter, r *http.Request) {
 	table := c.URLParams["id"]
 
-	err := ch.cb.IncrementVersion(table)
+	id, err := ch.cb.IncrementVersion(table)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.WithError(err).WithField("table", table).Error("Error queuing version increment")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	statusURL := "/control/jobs/" + id
+	w.Header().Set("Location", statusURL)
+	respondWithJSON(w, struct {
+		ID        string
+		StatusURL string
+	}{id, statusURL}, http.StatusAccepted)
+}
+
+// Backfill queues the processed files under an S3 prefix at low priority, in the background.
+// Takes a JSON POST containing the Bucket, Prefix, optional Table and Requester fields, and
+// responds with 202 and the ID of the job tracking it.
+func (ch *Handler) Backfill(c web.C, w http.ResponseWriter, r *http.Request) {
+	var req BackfillRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	if req.Bucket == "" || req.Requester == "" {
+		respondWithJSONError(w, "Bucket and Requester are required.", http.StatusBadRequest)
+		return
+	}
+
+	id := ch.cb.Backfill(req)
+	statusURL := "/control/jobs/" + id
+	w.Header().Set("Location", statusURL)
+	respondWithJSON(w, struct {
+		ID        string
+		StatusURL string
+	}{id, statusURL}, http.StatusAccepted)
+}
+
+// InventoryBackfill queues the processed files an S3 Inventory report lists at low priority, in the
+// background, resuming the last backfill of the report if it didn't finish. Takes a JSON POST
+// containing the Manifest, optional Prefix and Table, and Requester fields, and responds with 202 and
+// the ID of the job tracking it, or 409 if the report is already being backfilled.
+func (ch *Handler) InventoryBackfill(c web.C, w http.ResponseWriter, r *http.Request) {
+	var req InventoryBackfillRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	if req.Manifest == "" || req.Requester == "" {
+		respondWithJSONError(w, "Manifest and Requester are required.", http.StatusBadRequest)
+		return
+	}
+	if _, _, err = splitS3URL(req.Manifest); err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := ch.cb.InventoryBackfill(req)
+	if err == errInventoryRunning {
+		respondWithJSONError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	statusURL := "/control/jobs/" + id
+	w.Header().Set("Location", statusURL)
+	respondWithJSON(w, struct {
+		ID        string
+		StatusURL string
+	}{id, statusURL}, http.StatusAccepted)
+}
+
+// InventoryBackfillReport returns a JSON report of how far backfills of the S3 Inventory report named
+// by the manifest query parameter got: its data files done, the files queued, and each data file's
+// progress.
+func (ch *Handler) InventoryBackfillReport(c web.C, w http.ResponseWriter, r *http.Request) {
+	manifest := r.URL.Query().Get("manifest")
+	if _, _, err := splitS3URL(manifest); err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := ch.cb.InventoryBackfillReport(manifest)
+	if err != nil {
+		logger.WithError(err).WithField("manifest", manifest).Error("Error reporting inventory backfill")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, report, http.StatusOK)
+}
+
+// Export exports the table, or its rows in the From and To range of the JSON POST data, as Parquet
+// files under the export prefix, in the background. Responds with 202 and the ID of the job tracking
+// the export; poll its StatusURL for its state.
+func (ch *Handler) Export(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["table"]
+	var req ExportRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+
+	id, err := ch.cb.Export(table, req)
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lib.TableInc(ch.stats, "export", table, 1)
+	statusURL := "/control/jobs/" + id
+	w.Header().Set("Location", statusURL)
+	respondWithJSON(w, struct {
+		ID        string
+		StatusURL string
+	}{id, statusURL}, http.StatusAccepted)
+}
+
+// Migrate migrates a table to the version given in the "version" parameter right away, without
+// waiting for offpeak hours. Responds once the migration is done, with 204 on success.
+func (ch *Handler) Migrate(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	version, err := strconv.Atoi(r.FormValue("version"))
+	if err != nil {
+		respondWithJSONError(w, "version must be an integer.", http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.Migrate(table, version)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).WithField("version", version).
+			Error("Error migrating table on request")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lib.TableInc(ch.stats, "migrate", table, 1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RefreshVersions re-reads the table versions from Ace, correcting the cached versions that were
+// changed out-of-band. Responds with a JSON list of the corrected tables.
+func (ch *Handler) RefreshVersions(c web.C, w http.ResponseWriter, r *http.Request) {
+	changes, err := ch.cb.RefreshVersions()
+	if err != nil {
+		logger.WithError(err).Error("Error refreshing table versions")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, changes, http.StatusOK)
+}
+
+// PinVersion pins a table to the version given in the "version" parameter, which mustn't be newer than
+// the table's: loads treat the table as being at the version, holding files of newer versions, and
+// the table isn't migrated until it's unpinned. It's an emergency brake on a bad migration; pins last
+// until the ingester restarts.
+func (ch *Handler) PinVersion(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	version, err := strconv.Atoi(r.FormValue("version"))
+	if err != nil {
+		respondWithJSONError(w, "version must be an integer.", http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.PinVersion(table, version)
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lib.TableInc(ch.stats, "pin_version", table, 1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnpinVersion removes a table's pin, letting loads and migrations see its own version again.
+func (ch *Handler) UnpinVersion(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	if !ch.cb.UnpinVersion(table) {
+		respondWithJSONError(w, "Table isn't pinned.", http.StatusNotFound)
 		return
 	}
+	lib.TableInc(ch.stats, "pin_version.removed", table, 1)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// PinnedVersions returns a JSON object of the version each pinned table is pinned to.
+func (ch *Handler) PinnedVersions(c web.C, w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, ch.cb.PinnedVersions(), http.StatusOK)
+}
+
+// JobStatus returns the status of an asynchronous control job, along with the annotations on
+// its table.
+func (ch *Handler) JobStatus(c web.C, w http.ResponseWriter, r *http.Request) {
+	status, ok, err := ch.cb.JobStatus(c.URLParams["id"])
+	if err != nil {
+		logger.WithError(err).WithField("jobID", c.URLParams["id"]).Error("Error fetching job status")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		respondWithJSONError(w, "Job not found.", http.StatusNotFound)
+		return
+	}
+	annotations, err := ch.cb.Annotations(status.Table)
+	if err != nil {
+		logger.WithError(err).WithField("table", status.Table).Error("Error fetching annotations")
+	}
+	respondWithJSON(w, struct {
+		JobStatus
+		Annotations []metadata.Annotation
+	}{status, annotations}, http.StatusOK)
+}
+
 // LastLoad returns a JSON map of known last load times for each table
 func (ch *Handler) LastLoad(c web.C, w http.ResponseWriter, r *http.Request) {
 	lastloads := ch.cb.LastLoads()
@@ -123,3 +388,965 @@ func (ch *Handler) LastLoad(c web.C, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// LoadTriggers returns a JSON list of the per-table load trigger overrides.
+func (ch *Handler) LoadTriggers(c web.C, w http.ResponseWriter, r *http.Request) {
+	triggers, err := ch.cb.LoadTriggers()
+	if err != nil {
+		logger.WithError(err).Error("Error listing load triggers")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, triggers, http.StatusOK)
+}
+
+// SetLoadTrigger overrides the load triggers of a table. Takes a JSON POST containing the
+// CountTrigger and AgeTriggerSeconds fields; an omitted field falls back to the global trigger.
+// DelaySeconds, if given, holds the table's files back from loads until they've been queued that long.
+func (ch *Handler) SetLoadTrigger(c web.C, w http.ResponseWriter, r *http.Request) {
+	var trigger metadata.LoadTrigger
+	err := json.NewDecoder(r.Body).Decode(&trigger)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	trigger.Table = c.URLParams["id"]
+	if (trigger.CountTrigger != nil && *trigger.CountTrigger < 0) ||
+		(trigger.AgeTriggerSeconds != nil && *trigger.AgeTriggerSeconds <= 0) ||
+		(trigger.DelaySeconds != nil && *trigger.DelaySeconds < 0) {
+		respondWithJSONError(w, "CountTrigger and DelaySeconds must be non-negative and AgeTriggerSeconds positive.",
+			http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.SetLoadTrigger(trigger)
+	if err != nil {
+		logger.WithError(err).WithField("table", trigger.Table).Error("Error setting load trigger")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteLoadTrigger reverts a table to the global load triggers.
+func (ch *Handler) DeleteLoadTrigger(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	err := ch.cb.DeleteLoadTrigger(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error deleting load trigger")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TableFilter returns the JSON Include and Exclude patterns of the tables this ingester loads.
+func (ch *Handler) TableFilter(c web.C, w http.ResponseWriter, r *http.Request) {
+	patterns, err := ch.cb.TableFilter()
+	if err == errNoLoader {
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, patterns, http.StatusOK)
+}
+
+// SetTableFilter overrides the tables this ingester loads until it restarts. Takes a JSON POST
+// containing the Include and Exclude lists of patterns, which replace those given by flags.
+func (ch *Handler) SetTableFilter(c web.C, w http.ResponseWriter, r *http.Request) {
+	var patterns metadata.TablePatterns
+	err := json.NewDecoder(r.Body).Decode(&patterns)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.SetTableFilter(patterns)
+	switch err {
+	case nil:
+		logger.WithField("include", patterns.Include).WithField("exclude", patterns.Exclude).
+			Info("Overrode table filter")
+		w.WriteHeader(http.StatusNoContent)
+	case errNoLoader:
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+	default:
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// ResetTableFilter reverts the tables this ingester loads to those given by flags.
+func (ch *Handler) ResetTableFilter(c web.C, w http.ResponseWriter, r *http.Request) {
+	err := ch.cb.ResetTableFilter()
+	if err == errNoLoader {
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Reset table filter")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CopySettings returns a JSON list of the per-table COPY setting overrides.
+func (ch *Handler) CopySettings(c web.C, w http.ResponseWriter, r *http.Request) {
+	settings, err := ch.cb.CopySettings()
+	if err != nil {
+		logger.WithError(err).Error("Error listing copy settings")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, settings, http.StatusOK)
+}
+
+// SetCopySettings overrides the COPY settings of a table. Takes a JSON POST containing the
+// CompUpdate, StatUpdate, StatementTimeoutMs and DedupKey fields; an omitted field uses the default.
+func (ch *Handler) SetCopySettings(c web.C, w http.ResponseWriter, r *http.Request) {
+	var settings metadata.CopySettings
+	err := json.NewDecoder(r.Body).Decode(&settings)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	settings.Table = c.URLParams["id"]
+	settings.CompUpdate = strings.ToLower(settings.CompUpdate)
+	settings.StatUpdate = strings.ToLower(settings.StatUpdate)
+	if !redshift.ValidCompUpdate(settings.CompUpdate) || !redshift.ValidStatUpdate(settings.StatUpdate) {
+		respondWithJSONError(w, "CompUpdate must be on, off or preset, and StatUpdate on or off.", http.StatusBadRequest)
+		return
+	}
+	if settings.StatementTimeoutMs < 0 {
+		respondWithJSONError(w, "StatementTimeoutMs must not be negative.", http.StatusBadRequest)
+		return
+	}
+	settings.DedupKey = strings.Join(settings.DedupColumns(), ",")
+
+	err = ch.cb.SetCopySettings(settings)
+	if err != nil {
+		logger.WithError(err).WithField("table", settings.Table).Error("Error setting copy settings")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteCopySettings reverts a table to the default COPY settings.
+func (ch *Handler) DeleteCopySettings(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	err := ch.cb.DeleteCopySettings(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error deleting copy settings")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SamplingRules returns a JSON list of the per-table sampling rules.
+func (ch *Handler) SamplingRules(c web.C, w http.ResponseWriter, r *http.Request) {
+	rules, err := ch.cb.SamplingRules()
+	if err != nil {
+		logger.WithError(err).Error("Error listing sampling rules")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, rules, http.StatusOK)
+}
+
+// SetSamplingRule has the metadatastorers keep only a percent of a table's files. Takes a JSON
+// POST containing the KeepPercent, Reason and Requester fields.
+func (ch *Handler) SetSamplingRule(c web.C, w http.ResponseWriter, r *http.Request) {
+	var rule metadata.SamplingRule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	rule.Table = c.URLParams["id"]
+	if rule.KeepPercent < 0 || rule.KeepPercent > 100 || rule.Requester == "" {
+		respondWithJSONError(w, "KeepPercent must be between 0 and 100, and Requester set.", http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.SetSamplingRule(rule)
+	if err != nil {
+		logger.WithError(err).WithField("table", rule.Table).Error("Error setting sampling rule")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.WithField("table", rule.Table).WithField("keepPercent", rule.KeepPercent).
+		WithField("requester", rule.Requester).Info("Set sampling rule")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ComponentToggles returns a JSON list of the components that can be switched off, and whether they are.
+func (ch *Handler) ComponentToggles(c web.C, w http.ResponseWriter, r *http.Request) {
+	toggles, err := ch.cb.ComponentToggles()
+	if err != nil {
+		logger.WithError(err).Error("Error listing component toggles")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, toggles, http.StatusOK)
+}
+
+// SetComponentToggle switches the component off or on, or every component if it's "all", the kill
+// switch. Takes a JSON POST containing the Disabled, Reason and Requester fields.
+func (ch *Handler) SetComponentToggle(c web.C, w http.ResponseWriter, r *http.Request) {
+	var toggle metadata.ComponentToggle
+	err := json.NewDecoder(r.Body).Decode(&toggle)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	if toggle.Requester == "" {
+		respondWithJSONError(w, "Requester must be set.", http.StatusBadRequest)
+		return
+	}
+	components := metadata.Components
+	if component := metadata.Component(c.URLParams["component"]); component != "all" {
+		if !metadata.ValidComponent(component) {
+			respondWithJSONError(w, fmt.Sprintf("Unknown component %s.", component), http.StatusBadRequest)
+			return
+		}
+		components = []metadata.Component{component}
+	}
+
+	for _, component := range components {
+		toggle.Component = component
+		err = ch.cb.SetComponentToggle(toggle)
+		if err != nil {
+			logger.WithError(err).WithField("component", component).Error("Error switching component")
+			respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.WithField("component", component).WithField("disabled", toggle.Disabled).
+			WithField("requester", toggle.Requester).WithField("reason", toggle.Reason).Warn("Switched component")
+		ch.stats.SafeInc(fmt.Sprintf("toggle.%s.%t", component, toggle.Disabled), 1, 1.0)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSamplingRule stops sampling a table's files.
+func (ch *Handler) DeleteSamplingRule(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	err := ch.cb.DeleteSamplingRule(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error deleting sampling rule")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StragglerRules returns a JSON list of the per-table straggler rules.
+func (ch *Handler) StragglerRules(c web.C, w http.ResponseWriter, r *http.Request) {
+	rules, err := ch.cb.StragglerRules()
+	if err != nil {
+		logger.WithError(err).Error("Error listing straggler rules")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, rules, http.StatusOK)
+}
+
+// SetStragglerRule sets how a table's files of versions older than the table's, queued after it was
+// migrated, are handled. Takes a JSON POST containing the Policy, Reason and Requester fields.
+func (ch *Handler) SetStragglerRule(c web.C, w http.ResponseWriter, r *http.Request) {
+	var rule metadata.StragglerRule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	rule.Table = c.URLParams["id"]
+	if !rule.Policy.Valid() || rule.Requester == "" {
+		respondWithJSONError(w, "Policy must be load, dead_letter or stragglers, and Requester set.", http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.SetStragglerRule(rule)
+	if err != nil {
+		logger.WithError(err).WithField("table", rule.Table).Error("Error setting straggler rule")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.WithField("table", rule.Table).WithField("policy", rule.Policy).
+		WithField("requester", rule.Requester).Info("Set straggler rule")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteStragglerRule leaves a table's files of outdated versions queued.
+func (ch *Handler) DeleteStragglerRule(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	err := ch.cb.DeleteStragglerRule(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error deleting straggler rule")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CompressionRecommendations returns a JSON list of the latest column encodings recommended by
+// ANALYZE COMPRESSION.
+func (ch *Handler) CompressionRecommendations(c web.C, w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, ch.cb.CompressionRecommendations(), http.StatusOK)
+}
+
+// PlannedDeletions returns a JSON list of the latest planned deletion of expired rows from each
+// table with a retention class, and its outcome if it was carried out.
+func (ch *Handler) PlannedDeletions(c web.C, w http.ResponseWriter, r *http.Request) {
+	plans, err := ch.cb.PlannedDeletions()
+	if err == errNoRetention {
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, plans, http.StatusOK)
+}
+
+// SLOCompliance returns a JSON list of each table with a freshness SLO, whether it met the SLO
+// when last evaluated, and since when it's been breaching it if it isn't.
+func (ch *Handler) SLOCompliance(c web.C, w http.ResponseWriter, r *http.Request) {
+	compliance, err := ch.cb.SLOCompliance()
+	if err == errNoSLOs {
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, compliance, http.StatusOK)
+}
+
+// SetFreshnessSLO sets how long a table's files may wait to be loaded. Takes a JSON POST containing
+// the MaxAgeSeconds, Reason and Requester fields.
+func (ch *Handler) SetFreshnessSLO(c web.C, w http.ResponseWriter, r *http.Request) {
+	var freshnessSLO metadata.FreshnessSLO
+	err := json.NewDecoder(r.Body).Decode(&freshnessSLO)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	freshnessSLO.Table = c.URLParams["id"]
+	if freshnessSLO.MaxAgeSeconds <= 0 || freshnessSLO.Requester == "" {
+		respondWithJSONError(w, "MaxAgeSeconds must be positive, and Requester set.", http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.SetFreshnessSLO(freshnessSLO)
+	if err != nil {
+		logger.WithError(err).WithField("table", freshnessSLO.Table).Error("Error setting freshness SLO")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.WithField("table", freshnessSLO.Table).WithField("maxAgeSeconds", freshnessSLO.MaxAgeSeconds).
+		WithField("requester", freshnessSLO.Requester).Info("Set freshness SLO")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteFreshnessSLO removes a table's freshness SLO set through the control API.
+func (ch *Handler) DeleteFreshnessSLO(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	err := ch.cb.DeleteFreshnessSLO(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error deleting freshness SLO")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForceLoadSchedules returns a JSON list of each table force loaded on a schedule, when it's next
+// force loaded, and how its last run went.
+func (ch *Handler) ForceLoadSchedules(c web.C, w http.ResponseWriter, r *http.Request) {
+	statuses, err := ch.cb.ForceLoadSchedules()
+	if err == errNoSchedules {
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, statuses, http.StatusOK)
+}
+
+// SetForceLoadSchedule sets when a table is force loaded. Takes a JSON POST containing the
+// Schedule, a cron expression in UTC, and the Reason and Requester fields.
+func (ch *Handler) SetForceLoadSchedule(c web.C, w http.ResponseWriter, r *http.Request) {
+	var forceLoadSchedule metadata.ForceLoadSchedule
+	err := json.NewDecoder(r.Body).Decode(&forceLoadSchedule)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	forceLoadSchedule.Table = c.URLParams["id"]
+	if forceLoadSchedule.Requester == "" {
+		respondWithJSONError(w, "Requester must be set.", http.StatusBadRequest)
+		return
+	}
+	_, err = schedule.Parse(forceLoadSchedule.Schedule)
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = ch.cb.SetForceLoadSchedule(forceLoadSchedule)
+	if err != nil {
+		logger.WithError(err).WithField("table", forceLoadSchedule.Table).Error("Error setting force load schedule")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.WithField("table", forceLoadSchedule.Table).WithField("schedule", forceLoadSchedule.Schedule).
+		WithField("requester", forceLoadSchedule.Requester).Info("Set force load schedule")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteForceLoadSchedule removes a table's force load schedule set through the control API.
+func (ch *Handler) DeleteForceLoadSchedule(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	err := ch.cb.DeleteForceLoadSchedule(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error deleting force load schedule")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Query runs a read-only query of a Redshift system table, e.g. STV_RECENTS or STL_LOAD_ERRORS, so
+// operators can inspect the cluster without its credentials. Takes a JSON POST containing the Table,
+// and optionally the Columns to select, a Where map of columns to the values they must equal, an
+// OrderBy column with Desc, and a Limit. Responds with the JSON rows and columns.
+func (ch *Handler) Query(c web.C, w http.ResponseWriter, r *http.Request) {
+	var q redshift.Query
+	err := json.NewDecoder(r.Body).Decode(&q)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	if _, _, err = q.SQL(); err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	table, err := ch.cb.Query(q)
+	if err != nil {
+		logger.WithError(err).WithField("table", q.Table).Error("Error running ad-hoc query")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ch.stats.SafeInc("query."+strings.ToLower(q.Table), 1, 1.0)
+	respondWithJSON(w, struct {
+		Columns     []string
+		Rows        [][]interface{}
+		TimeTakenMs int64
+	}{table.Columns, table.Rows, int64(table.TimeTaken / time.Millisecond)}, http.StatusOK)
+}
+
+// SchemaDiff compares the table's live columns in Redshift with blueprint's schema, by default at
+// the table's current version or else at the version query parameter. Responds with a JSON object
+// of the table, version and the columns that differ, with their Expected and Live types.
+func (ch *Handler) SchemaDiff(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["table"]
+	version := -1
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		version, err = strconv.Atoi(v)
+		if err != nil || version < 0 {
+			respondWithJSONError(w, "version must be a non-negative integer.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	diff, err := ch.cb.SchemaDiff(table, version)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error diffing schema")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if diff.HasDrift() {
+		lib.TableInc(ch.stats, "schema_drift", table, 1)
+	}
+	respondWithJSON(w, diff, http.StatusOK)
+}
+
+// PausedTables returns a JSON list of the tables whose loads are paused.
+func (ch *Handler) PausedTables(c web.C, w http.ResponseWriter, r *http.Request) {
+	paused, err := ch.cb.PausedTables()
+	if err != nil {
+		logger.WithError(err).Error("Error listing paused tables")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, paused, http.StatusOK)
+}
+
+// Bulk applies operations to many tables at once, e.g. pausing every table during an incident.
+// Takes a JSON POST containing the Requester and a list of Operations, each with an Op of pause,
+// resume, force_load or refresh, a Tables glob and, for pauses, a Reason. Responds with 400 and
+// runs nothing if an operation is invalid or matches no tables, otherwise with a JSON list of the
+// result for each table of each operation.
+func (ch *Handler) Bulk(c web.C, w http.ResponseWriter, r *http.Request) {
+	var req BulkRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) == 0 || req.Requester == "" {
+		respondWithJSONError(w, "Operations and Requester are required.", http.StatusBadRequest)
+		return
+	}
+
+	results, err := ch.cb.Bulk(req)
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+			logger.WithField("op", result.Op).WithField("table", result.Table).
+				WithField("requester", req.Requester).WithField("error", result.Error).Error("Error in bulk operation")
+			continue
+		}
+		lib.TableInc(ch.stats, "bulk."+string(result.Op), result.Table, 1)
+	}
+	logger.WithField("requester", req.Requester).WithField("tables", len(results)).WithField("failed", failed).
+		Info("Ran bulk operations")
+	respondWithJSON(w, struct {
+		Failed  int
+		Results []BulkResult
+	}{failed, results}, http.StatusOK)
+}
+
+// Gaps returns a JSON object of the last check for processed files that were neither queued nor
+// diverted, with the missing files counted by table.
+func (ch *Handler) Gaps(c web.C, w http.ResponseWriter, r *http.Request) {
+	report, err := ch.cb.Gaps()
+	switch err {
+	case nil:
+		respondWithJSON(w, report, http.StatusOK)
+	case errNoGapCheck:
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+	case errNoGapReport:
+		respondWithJSONError(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ReloadConfig re-reads the config file and applies its tunables without a restart, responding with
+// the tunables in effect. Nothing is changed if the config is invalid.
+func (ch *Handler) ReloadConfig(c web.C, w http.ResponseWriter, r *http.Request) {
+	tunables, err := ch.cb.ReloadConfig()
+	if err != nil {
+		logger.WithError(err).Error("Error reloading config")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, tunables, http.StatusOK)
+}
+
+// MigratorState returns a JSON object of what the migrator is doing: whether it's offpeak, the tables
+// with newer versions queued as of its last poll, the migrations waiting for the processor, and the
+// last attempt at migrating each table with its outcome and error.
+func (ch *Handler) MigratorState(c web.C, w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, ch.cb.MigratorState(), http.StatusOK)
+}
+
+// MigratorWaits returns a JSON list of the tables waiting to be migrated, with the version, the wait
+// state and since when the table's been in it. With the min_age query parameter, e.g. 2h, only the
+// tables in their state for longer are listed.
+func (ch *Handler) MigratorWaits(c web.C, w http.ResponseWriter, r *http.Request) {
+	var minAge time.Duration
+	if age := r.URL.Query().Get("min_age"); age != "" {
+		var err error
+		minAge, err = time.ParseDuration(age)
+		if err != nil || minAge < 0 {
+			respondWithJSONError(w, "min_age must be a non-negative duration, e.g. 2h.", http.StatusBadRequest)
+			return
+		}
+	}
+	respondWithJSON(w, ch.cb.MigratorWaits(minAge), http.StatusOK)
+}
+
+// OffpeakReport is the response of GET /control/offpeak.
+type OffpeakReport struct {
+	Offpeak  bool
+	Schedule lib.OffpeakSchedule
+}
+
+// OffpeakSchedule returns whether it's offpeak, and the JSON offpeak schedule: the Default window, the
+// Weekdays with their own, and the Holidays that are offpeak all day.
+func (ch *Handler) OffpeakSchedule(c web.C, w http.ResponseWriter, r *http.Request) {
+	schedule := ch.cb.OffpeakSchedule()
+	respondWithJSON(w, OffpeakReport{Offpeak: schedule.Contains(time.Now()), Schedule: schedule}, http.StatusOK)
+}
+
+// SetOffpeakSchedule overrides the offpeak schedule until a config reload changes it. Takes a JSON POST
+// of the schedule, with the Default window, and optionally the Weekdays and Holidays.
+func (ch *Handler) SetOffpeakSchedule(c web.C, w http.ResponseWriter, r *http.Request) {
+	var schedule lib.OffpeakSchedule
+	err := json.NewDecoder(r.Body).Decode(&schedule)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	err = ch.cb.SetOffpeakSchedule(schedule)
+	if err != nil {
+		respondWithJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger.WithField("schedule", schedule).Info("Overrode offpeak schedule")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Versions returns the JSON list of Tables with their Cached version in the ingester and their Ace
+// version in Redshift, each with Drift set if they disagree, and the count of Drifted tables. A table
+// missing from either has no version there, and counts as drifted.
+func (ch *Handler) Versions(c web.C, w http.ResponseWriter, r *http.Request) {
+	report, err := ch.cb.Versions()
+	if err != nil {
+		logger.WithError(err).Error("Error comparing table versions")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, report, http.StatusOK)
+}
+
+// BlueprintMetadataUpdated reloads the Blueprint metadata right away, for Blueprint to call when it
+// publishes new metadata. On success, responds with 204 once the reload is scheduled.
+func (ch *Handler) BlueprintMetadataUpdated(c web.C, w http.ResponseWriter, r *http.Request) {
+	err := ch.cb.ReloadBlueprintMetadata()
+	if err == errNoBpMetadata {
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddAnnotation attaches a note to a table, or one of its loads. Takes a JSON POST containing the
+// Note, Author and optionally LoadUUID fields, and responds with the new annotation's ID.
+func (ch *Handler) AddAnnotation(c web.C, w http.ResponseWriter, r *http.Request) {
+	var annotation metadata.Annotation
+	err := json.NewDecoder(r.Body).Decode(&annotation)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	annotation.Table = c.URLParams["id"]
+	if len(annotation.Note) == 0 || len(annotation.Author) == 0 {
+		respondWithJSONError(w, "Note and Author must be non-empty.", http.StatusBadRequest)
+		return
+	}
+
+	id, err := ch.cb.AddAnnotation(annotation)
+	if err != nil {
+		logger.WithError(err).WithField("table", annotation.Table).Error("Error adding annotation")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, struct{ ID int64 }{id}, http.StatusCreated)
+}
+
+// Annotations returns a JSON list of the notes attached to a table and its loads.
+func (ch *Handler) Annotations(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	annotations, err := ch.cb.Annotations(table)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error listing annotations")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, annotations, http.StatusOK)
+}
+
+// DeleteAnnotation removes a note from a table. Responds 404 if the table has no note with the ID.
+func (ch *Handler) DeleteAnnotation(c web.C, w http.ResponseWriter, r *http.Request) {
+	table := c.URLParams["id"]
+	id, err := strconv.ParseInt(c.URLParams["annotation"], 10, 64)
+	if err != nil {
+		respondWithJSONError(w, "Annotation ID must be an integer.", http.StatusBadRequest)
+		return
+	}
+	deleted, err := ch.cb.DeleteAnnotation(table, id)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).WithField("annotation", id).Error("Error deleting annotation")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		respondWithJSONError(w, "Table has no annotation with that ID.", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MaintenanceWindows returns whether a maintenance window is in progress, and a JSON list of the
+// current and upcoming windows.
+func (ch *Handler) MaintenanceWindows(c web.C, w http.ResponseWriter, r *http.Request) {
+	inMaintenance, err := ch.cb.InMaintenance()
+	if err != nil {
+		logger.WithError(err).Error("Error checking for maintenance window")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	windows, err := ch.cb.MaintenanceWindows()
+	if err != nil {
+		logger.WithError(err).Error("Error listing maintenance windows")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, struct {
+		InMaintenance bool
+		Windows       []metadata.MaintenanceWindow
+	}{inMaintenance, windows}, http.StatusOK)
+}
+
+// AddMaintenanceWindow schedules a window during which no loads or migrations start. Takes a JSON
+// POST containing the Reason, Requester and optionally Start and End fields; Start defaults to now,
+// and a window without an End lasts until it's deleted. Responds with the new window's ID.
+func (ch *Handler) AddMaintenanceWindow(c web.C, w http.ResponseWriter, r *http.Request) {
+	var window metadata.MaintenanceWindow
+	err := json.NewDecoder(r.Body).Decode(&window)
+	if err != nil {
+		respondWithJSONError(w, "Problem decoding JSON POST data.", http.StatusBadRequest)
+		return
+	}
+	if len(window.Reason) == 0 || len(window.Requester) == 0 {
+		respondWithJSONError(w, "Reason and Requester must be non-empty.", http.StatusBadRequest)
+		return
+	}
+	if window.Start.IsZero() {
+		window.Start = time.Now().In(time.UTC)
+	}
+	if window.End != nil && !window.End.After(window.Start) {
+		respondWithJSONError(w, "End must be after Start.", http.StatusBadRequest)
+		return
+	}
+
+	id, err := ch.cb.AddMaintenanceWindow(window)
+	if err != nil {
+		logger.WithError(err).WithField("requester", window.Requester).Error("Error adding maintenance window")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.WithField("id", id).WithField("requester", window.Requester).WithField("reason", window.Reason).
+		WithField("start", window.Start).Info("Scheduled maintenance window")
+	ch.stats.SafeInc("maintenance_window.added", 1, 1.0)
+	respondWithJSON(w, struct{ ID int64 }{id}, http.StatusCreated)
+}
+
+// DeleteMaintenanceWindow removes a maintenance window, ending it if it's in progress.
+func (ch *Handler) DeleteMaintenanceWindow(c web.C, w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(c.URLParams["id"], 10, 64)
+	if err != nil {
+		respondWithJSONError(w, "Maintenance window ID must be an integer.", http.StatusBadRequest)
+		return
+	}
+	err = ch.cb.DeleteMaintenanceWindow(id)
+	if err != nil {
+		logger.WithError(err).WithField("id", id).Error("Error deleting maintenance window")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logger.WithField("id", id).Info("Deleted maintenance window")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// QueueStats returns a JSON list of queued TSV counts and ages per event, grouped by why they're
+// pending: in_queue, stale, or pending_migration.
+func (ch *Handler) QueueStats(c web.C, w http.ResponseWriter, r *http.Request) {
+	stats, err := ch.cb.QueueStats()
+	if err != nil {
+		logger.WithError(err).Error("Error getting queue stats")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, stats, http.StatusOK)
+}
+
+// InFlightLoads returns a JSON list of the loads currently being run.
+func (ch *Handler) InFlightLoads(c web.C, w http.ResponseWriter, r *http.Request) {
+	loads, err := ch.cb.InFlightLoads()
+	if err != nil {
+		logger.WithError(err).Error("Error getting in-flight loads")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, loads, http.StatusOK)
+}
+
+// LoadStatus returns the JSON state of a load: whether it's in flight, failed or committed, its
+// files, errors and manifest URL, and its transaction's status in Redshift. Responds with 404 if
+// there's no load with the UUID.
+func (ch *Handler) LoadStatus(c web.C, w http.ResponseWriter, r *http.Request) {
+	manifestUUID := c.URLParams["uuid"]
+	if uuid.Parse(manifestUUID) == nil {
+		respondWithJSONError(w, "uuid must be a UUID.", http.StatusBadRequest)
+		return
+	}
+	status, err := ch.cb.LoadStatus(manifestUUID)
+	switch err {
+	case nil:
+	case metadata.ErrUnknownLoad:
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	default:
+		logger.WithError(err).WithField("manifestUUID", manifestUUID).Error("Error getting load status")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, status, http.StatusOK)
+}
+
+// CancelLoad cancels the running COPY of an in-flight load, which marks the load for retry.
+// Responds with 204 once the COPY is canceled, 404 if the load isn't in flight and 409 if it
+// has no running COPY, e.g. because it's still uploading its manifest.
+func (ch *Handler) CancelLoad(c web.C, w http.ResponseWriter, r *http.Request) {
+	uuid := c.URLParams["uuid"]
+	err := ch.cb.CancelLoad(uuid)
+	switch err {
+	case nil:
+	case errLoadNotInFlight:
+		respondWithJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	case errNoRunningCopy:
+		respondWithJSONError(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		logger.WithError(err).WithField("manifestUUID", uuid).Error("Error canceling load")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ch.stats.SafeInc("cancel_load", 1, 1.0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FailedLoads returns a JSON list of the most recent failed loads waiting to be retried. The
+// number returned can be set with the limit query parameter.
+func (ch *Handler) FailedLoads(c web.C, w http.ResponseWriter, r *http.Request) {
+	limit := defaultFailedLoadsLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			respondWithJSONError(w, "limit must be a positive integer.", http.StatusBadRequest)
+			return
+		}
+	}
+	loads, err := ch.cb.FailedLoads(limit)
+	if err != nil {
+		logger.WithError(err).Error("Error getting failed loads")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, loads, http.StatusOK)
+}
+
+// LoadEvents returns a JSON list of the most recent load events: the state transitions of loads in
+// the workers, optionally filtered by the "uuid" of a load, the "worker" (a prefix, so a host selects
+// all of its workers) and "since", an RFC 3339 time. At most "limit" events are returned, default 100.
+func (ch *Handler) LoadEvents(c web.C, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := metadata.LoadEventFilter{LoadUUID: query.Get("uuid"), Worker: query.Get("worker"),
+		Limit: defaultLoadEventsLimit}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			respondWithJSONError(w, "limit must be a positive integer.", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if s := query.Get("since"); s != "" {
+		since, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondWithJSONError(w, "since must be an RFC 3339 time.", http.StatusBadRequest)
+			return
+		}
+		filter.Since = &since
+	}
+	events, err := ch.cb.LoadEvents(filter)
+	if err != nil {
+		logger.WithError(err).Error("Error getting load events")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, events, http.StatusOK)
+}
+
+// TableErrors returns a JSON list of the table's load failures over the last day, newest first,
+// with their classes, and the number of failures in each hour of the day by class. The trend's Spike
+// is set when the last hour's failures are well above the rest of the day's. The number of failures
+// listed can be set with the limit query parameter.
+func (ch *Handler) TableErrors(c web.C, w http.ResponseWriter, r *http.Request) {
+	limit := defaultFailedLoadsLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			respondWithJSONError(w, "limit must be a positive integer.", http.StatusBadRequest)
+			return
+		}
+	}
+	errs, err := ch.cb.TableErrors(c.URLParams["table"], limit)
+	if err != nil {
+		logger.WithError(err).WithField("table", c.URLParams["table"]).Error("Error getting table load errors")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, errs, http.StatusOK)
+}
+
+// LoadThroughputReport is the response of /control/stats/loads.
+type LoadThroughputReport struct {
+	Window string
+	Since  time.Time
+	Tables []metadata.TableThroughput
+}
+
+// LoadThroughput returns a JSON report of the loads completed into each table, their rows, bytes and
+// average COPY time, and the loads that failed, over the window query parameter (24h by default).
+func (ch *Handler) LoadThroughput(c web.C, w http.ResponseWriter, r *http.Request) {
+	window := defaultStatsWindow
+	if win := r.URL.Query().Get("window"); win != "" {
+		var err error
+		window, err = time.ParseDuration(win)
+		if err != nil || window <= 0 {
+			respondWithJSONError(w, "window must be a positive duration, e.g. 24h.", http.StatusBadRequest)
+			return
+		}
+	}
+	since := time.Now().In(time.UTC).Add(-window)
+	tables, err := ch.cb.LoadThroughput(since)
+	if err != nil {
+		logger.WithError(err).Error("Error getting load throughput")
+		respondWithJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, LoadThroughputReport{Window: window.String(), Since: since, Tables: tables}, http.StatusOK)
+}
+
+// Dashboard serves an HTML page showing the queue, in-flight and failed loads.
+func (ch *Handler) Dashboard(c web.C, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte(dashboardHTML))
+	if err != nil {
+		logger.WithError(err).Error("Error writing dashboard")
+	}
+}
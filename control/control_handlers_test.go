@@ -0,0 +1,265 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/migrator"
+	"github.com/twitchscience/rs_ingester/versions"
+	"github.com/zenazn/goji/web"
+)
+
+// annotationReader holds annotations by ID, with the table each is on.
+type annotationReader struct {
+	metadata.Reader
+	tables map[int64]string
+}
+
+func (r *annotationReader) DeleteAnnotation(table string, id int64) (bool, error) {
+	if r.tables[id] != table {
+		return false, nil
+	}
+	delete(r.tables, id)
+	return true, nil
+}
+
+func TestDeleteAnnotation(t *testing.T) {
+	reader := &annotationReader{tables: map[int64]string{1: "video", 2: "chat"}}
+	ch := NewControlHandler(&Backend{metaReader: reader}, monitoring.NewMockStatter())
+	call := func(table, annotation string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("DELETE", "/control/annotations/"+table+"/"+annotation, nil)
+		ch.DeleteAnnotation(web.C{URLParams: map[string]string{"id": table, "annotation": annotation}}, w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, call("video", "first"))
+	assert.Equal(t, http.StatusNotFound, call("video", "2"), "another table's annotation isn't deleted")
+	assert.Equal(t, "chat", reader.tables[2])
+	assert.Equal(t, http.StatusNoContent, call("video", "1"))
+	assert.NotContains(t, reader.tables, int64(1))
+	assert.Equal(t, http.StatusNotFound, call("video", "1"), "an annotation is only deleted once")
+}
+
+// pinReporter is a migrator that pins tables to versions no newer than 3.
+type pinReporter struct {
+	MigratorReporter
+	pins map[string]int
+}
+
+func (m *pinReporter) PinVersion(table string, version int) error {
+	if version > 3 {
+		return fmt.Errorf("table %s can only be pinned to versions 0 to 3, not %d", table, version)
+	}
+	m.pins[table] = version
+	return nil
+}
+
+func (m *pinReporter) UnpinVersion(table string) bool {
+	_, pinned := m.pins[table]
+	delete(m.pins, table)
+	return pinned
+}
+
+func (m *pinReporter) Pins() map[string]int { return m.pins }
+
+func TestPinVersion(t *testing.T) {
+	migrator := &pinReporter{pins: map[string]int{}}
+	ch := NewControlHandler(&Backend{migratorState: migrator}, monitoring.NewMockStatter())
+	pin := func(table, version string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/control/pin_version/"+table,
+			strings.NewReader(url.Values{"version": {version}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ch.PinVersion(web.C{URLParams: map[string]string{"id": table}}, w, r)
+		return w
+	}
+	unpin := func(table string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("DELETE", "/control/pin_version/"+table, nil)
+		ch.UnpinVersion(web.C{URLParams: map[string]string{"id": table}}, w, r)
+		return w.Code
+	}
+	pinned := func() map[string]int {
+		w := httptest.NewRecorder()
+		ch.PinnedVersions(web.C{}, w, httptest.NewRequest("GET", "/control/pin_version", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var pins map[string]int
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &pins))
+		return pins
+	}
+
+	assert.Equal(t, http.StatusBadRequest, pin("chat", "latest").Code)
+	w := pin("chat", "4")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "can only be pinned to versions 0 to 3")
+	assert.Empty(t, pinned())
+
+	assert.Equal(t, http.StatusNoContent, pin("chat", "2").Code)
+	assert.Equal(t, map[string]int{"chat": 2}, pinned())
+
+	assert.Equal(t, http.StatusNoContent, unpin("chat"))
+	assert.Equal(t, http.StatusNotFound, unpin("chat"), "a table is only unpinned once")
+	assert.Empty(t, pinned())
+}
+
+func TestDashboardAuth(t *testing.T) {
+	migrator := &pinReporter{pins: map[string]int{"chat": 2}}
+	router := NewControlRouter(NewControlHandler(&Backend{migratorState: migrator}, monitoring.NewMockStatter()),
+		AuthConfig{Token: "secret"})
+	get := func(path, authorization string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/control/dashboard", "")
+	assert.Equal(t, http.StatusOK, w.Code, "the page holds no data, so it's served without the token")
+	assert.Contains(t, w.Body.String(), `headers["Authorization"] = "Bearer " + token`)
+	assert.Equal(t, http.StatusUnauthorized, get("/control/pin_version", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/control/dashboard/", "").Code,
+		"only the page itself skips auth")
+	w = get("/control/pin_version", "Bearer secret")
+	assert.Equal(t, http.StatusOK, w.Code, "the page's requests are authenticated by the token it sends")
+	assert.JSONEq(t, `{"chat": 2}`, w.Body.String())
+}
+
+// bulkReader is a pauseReader that force loads tables, failing those in failForce.
+type bulkReader struct {
+	pauseReader
+	forced    []string
+	failForce map[string]bool
+}
+
+func (r *bulkReader) ForceLoad(table, requester string) error {
+	if r.failForce[table] {
+		return fmt.Errorf("table %s is quarantined", table)
+	}
+	r.forced = append(r.forced, table)
+	return nil
+}
+
+func TestBulk(t *testing.T) {
+	reader := &bulkReader{
+		pauseReader: pauseReader{paused: map[string]string{"clip": "backfill"}},
+		failForce:   map[string]bool{"chat_b": true},
+	}
+	refreshes := make(chan migrator.VersionRefresh, 1)
+	cb := &Backend{
+		metaReader:       reader,
+		versions:         versions.New(map[string]int{"chat_a": 1, "chat_b": 2, "video": 4}),
+		versionRefreshes: refreshes,
+		migratorTimeout:  time.Second,
+	}
+
+	_, err := cb.Bulk(BulkRequest{Operations: []BulkOperation{{Op: "drop", Tables: "*"}}})
+	assert.EqualError(t, err, `unknown operation "drop"`)
+	_, err = cb.Bulk(BulkRequest{Operations: []BulkOperation{
+		{Op: BulkPause, Tables: "chat_*"},
+		{Op: BulkResume, Tables: "stream_*"},
+	}})
+	assert.EqualError(t, err, `resume tables "stream_*" match no known table`)
+	assert.Equal(t, map[string]string{"clip": "backfill"}, reader.paused, "nothing runs if an operation is invalid")
+
+	results, err := cb.Bulk(BulkRequest{Requester: "oncall", Operations: []BulkOperation{
+		{Op: BulkPause, Tables: "chat_?", Reason: "incident"},
+		{Op: BulkResume, Tables: "clip"},
+		{Op: BulkForceLoad, Tables: "chat_*"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []BulkResult{
+		{Op: BulkPause, Table: "chat_a"},
+		{Op: BulkPause, Table: "chat_b"},
+		{Op: BulkResume, Table: "clip"},
+		{Op: BulkForceLoad, Table: "chat_a"},
+		{Op: BulkForceLoad, Table: "chat_b", Error: "Error executing force load: table chat_b is quarantined"},
+	}, results)
+	assert.Equal(t, map[string]string{"chat_a": "incident", "chat_b": "incident"}, reader.paused)
+	assert.Equal(t, []string{"chat_a"}, reader.forced, "a failure doesn't stop the other tables")
+
+	go func() {
+		refresh := <-refreshes
+		cached := 3
+		refresh.Response <- migrator.VersionRefreshResult{Changes: []migrator.VersionChange{
+			{Table: "video", Cached: &cached, Ace: 4},
+			{Table: "stream", Ace: 1},
+		}}
+	}()
+	results, err = cb.Bulk(BulkRequest{Operations: []BulkOperation{{Op: BulkRefresh, Tables: "*"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []BulkResult{
+		{Op: BulkRefresh, Table: "chat_a"},
+		{Op: BulkRefresh, Table: "chat_b"},
+		{Op: BulkRefresh, Table: "video", Detail: "version 3 corrected to 4"},
+	}, results, "versions are refreshed once, with the changes reported to the matched tables")
+}
+
+func TestBulkHandler(t *testing.T) {
+	reader := &bulkReader{pauseReader: pauseReader{paused: map[string]string{}}}
+	ch := NewControlHandler(&Backend{metaReader: reader, versions: versions.New(map[string]int{"chat": 1})},
+		monitoring.NewMockStatter())
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ch.Bulk(web.C{}, w, httptest.NewRequest("POST", "/control/bulk", strings.NewReader(body)))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"Operations": [`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"Operations": [{"Op": "pause", "Tables": "*"}]}`).Code,
+		"the requester is required")
+	w := post(`{"Requester": "oncall", "Operations": [{"Op": "pause", "Tables": "video"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "match no known table")
+
+	w = post(`{"Requester": "oncall", "Operations": [{"Op": "pause", "Tables": "*"}, ` +
+		`{"Op": "force_load", "Tables": "*"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"Failed": 0, "Results": [{"Op": "pause", "Table": "chat"}, `+
+		`{"Op": "force_load", "Table": "chat"}]}`, w.Body.String())
+	assert.Contains(t, reader.paused, "chat")
+	assert.Equal(t, []string{"chat"}, reader.forced)
+}
+
+// aceVersions is Ace with the tables' versions, or failing with err.
+type aceVersions struct {
+	versions map[string]int
+	err      error
+}
+
+func (a *aceVersions) TableVersions() (map[string]int, error) { return a.versions, a.err }
+
+func TestVersions(t *testing.T) {
+	ace := &aceVersions{versions: map[string]int{"chat": 3, "video": 2}}
+	ch := NewControlHandler(&Backend{versions: versions.New(map[string]int{"chat": 3, "video": 1, "clip": 1}),
+		aceVersions: ace}, monitoring.NewMockStatter())
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ch.Versions(web.C{}, w, httptest.NewRequest("GET", "/control/versions", nil))
+		return w
+	}
+
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"Drifted": 2, "Tables": [
+		{"Table": "chat", "Cached": 3, "Ace": 3, "Drift": false},
+		{"Table": "clip", "Cached": 1, "Drift": true},
+		{"Table": "video", "Cached": 1, "Ace": 2, "Drift": true}]}`, w.Body.String())
+
+	ace.err = fmt.Errorf("connection refused")
+	w = get()
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "getting table versions from ace: connection refused")
+}
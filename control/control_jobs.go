@@ -0,0 +1,114 @@
+package control
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+// JobState is the state of an asynchronous control request.
+type JobState string
+
+const (
+	// JobPending jobs have been queued but have not finished.
+	JobPending JobState = "pending"
+
+	// JobSucceeded jobs finished without error.
+	JobSucceeded JobState = "succeeded"
+
+	// JobFailed jobs finished with an error or ran past their deadline.
+	JobFailed JobState = "failed"
+)
+
+// finishedJobRetention is how long finished jobs are kept around for status requests.
+const finishedJobRetention = 24 * time.Hour
+
+// JobStatus describes an asynchronous control request, e.g. a version increment.
+type JobStatus struct {
+	ID        string
+	Kind      string
+	Table     string
+	State     JobState
+	Error     string `json:",omitempty"`
+	Detail    string `json:",omitempty"` // the job's progress or result, if it reports any
+	Submitted time.Time
+	Finished  *time.Time `json:",omitempty"`
+}
+
+// jobTracker keeps the status of asynchronous control requests in memory.
+type jobTracker struct {
+	lock sync.RWMutex
+	jobs map[string]*JobStatus
+}
+
+func newJobTracker() *jobTracker {
+	return &jobTracker{jobs: make(map[string]*JobStatus)}
+}
+
+// start registers a new pending job and returns its ID.
+func (t *jobTracker) start(kind string, table string) string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.pruneLocked(time.Now())
+	id := uuid.NewRandom().String()
+	t.jobs[id] = &JobStatus{
+		ID:        id,
+		Kind:      kind,
+		Table:     table,
+		State:     JobPending,
+		Submitted: time.Now().In(time.UTC),
+	}
+	return id
+}
+
+// finish marks the job as succeeded, or failed if err is non-nil.
+func (t *jobTracker) finish(id string, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	job, ok := t.jobs[id]
+	if !ok {
+		return
+	}
+	now := time.Now().In(time.UTC)
+	job.Finished = &now
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+		return
+	}
+	job.State = JobSucceeded
+}
+
+// setDetail updates the job's progress or result.
+func (t *jobTracker) setDetail(id string, detail string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if job, ok := t.jobs[id]; ok {
+		job.Detail = detail
+	}
+}
+
+// get returns a copy of the job's status.
+func (t *jobTracker) get(id string) (JobStatus, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	job, ok := t.jobs[id]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return *job, true
+}
+
+// pruneLocked drops finished jobs older than finishedJobRetention. Must hold t.lock.
+func (t *jobTracker) pruneLocked(now time.Time) {
+	for id, job := range t.jobs {
+		if job.Finished != nil && now.Sub(*job.Finished) > finishedJobRetention {
+			delete(t.jobs, id)
+		}
+	}
+}
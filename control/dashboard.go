@@ -0,0 +1,134 @@
+package control
+
+// dashboardHTML is a self-contained page that polls the control JSON endpoints, sending the control auth
+// token it's given as a bearer token.
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Ingester dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+th { background: #eee; }
+.error { color: #a00; }
+.note { color: #555; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>Ingester</h1>
+<p>Refreshes every 30 seconds. Last refresh: <span id="refreshed">never</span> <span id="status" class="error"></span></p>
+<form id="auth">
+<input type="password" id="token" placeholder="Control auth token" autocomplete="off">
+<button type="submit">Use token</button>
+</form>
+
+<h2>Queue</h2>
+<table id="queue"><thead><tr><th>Event</th><th>Pending</th><th>TSVs</th><th>Oldest</th></tr></thead><tbody></tbody></table>
+
+<h2>Pending migrations</h2>
+<table id="migrations"><thead><tr><th>Event</th><th>TSVs</th><th>Oldest</th></tr></thead><tbody></tbody></table>
+
+<h2>In-flight loads</h2>
+<table id="inflight"><thead><tr><th>Load</th><th>Table</th><th>Files</th><th>Retries</th></tr></thead><tbody></tbody></table>
+
+<h2>Recent failures</h2>
+<table id="failed"><thead><tr><th>Load</th><th>Table</th><th>Files</th><th>Retries</th><th>Retry at</th><th>Error</th><th>Notes</th></tr></thead><tbody></tbody></table>
+
+<script>
+// The token is kept for the browser tab only, and sent with every request.
+function getJSON(path) {
+  var headers = {};
+  var token = sessionStorage.getItem("controlToken");
+  if (token) { headers["Authorization"] = "Bearer " + token; }
+  return fetch(path, {credentials: "same-origin", headers: headers}).then(function(resp) {
+    if (resp.status === 401) { throw new Error(path + ": 401, set the control auth token"); }
+    if (!resp.ok) { throw new Error(path + ": " + resp.status); }
+    return resp.json();
+  });
+}
+
+document.getElementById("auth").addEventListener("submit", function(e) {
+  e.preventDefault();
+  var input = document.getElementById("token");
+  sessionStorage.setItem("controlToken", input.value);
+  input.value = "";
+  refresh();
+});
+
+function fill(id, rows) {
+  var body = document.querySelector("#" + id + " tbody");
+  body.innerHTML = "";
+  rows.forEach(function(cells) {
+    var tr = document.createElement("tr");
+    cells.forEach(function(cell) {
+      var td = document.createElement("td");
+      if (cell && cell.className) {
+        td.className = cell.className;
+        td.textContent = cell.text;
+      } else {
+        td.textContent = cell === undefined || cell === null ? "" : cell;
+      }
+      tr.appendChild(td);
+    });
+    body.appendChild(tr);
+  });
+}
+
+function age(ts) {
+  if (!ts || ts.indexOf("0001-") === 0) { return ""; }
+  var mins = Math.round((Date.now() - Date.parse(ts)) / 60000);
+  return mins < 120 ? mins + "m" : Math.round(mins / 60) + "h";
+}
+
+function notes(table) {
+  return getJSON("/control/annotations/" + encodeURIComponent(table)).then(function(annotations) {
+    return annotations.map(function(a) { return a.Note + " (" + a.Author + ")"; }).join("; ");
+  }).catch(function() { return ""; });
+}
+
+function refresh() {
+  var status = document.getElementById("status");
+  Promise.all([
+    getJSON("/control/queue"),
+    getJSON("/control/loads/in_flight"),
+    getJSON("/control/loads/failed")
+  ]).then(function(results) {
+    var queueRows = [], migrationRows = [];
+    (results[0] || []).forEach(function(group) {
+      group.Stats.forEach(function(s) {
+        if (group.Type === "pending_migration") {
+          migrationRows.push([s.Event, s.Count, age(s.MinTS)]);
+        } else {
+          queueRows.push([s.Event, group.Type, s.Count, age(s.MinTS)]);
+        }
+      });
+    });
+    queueRows.sort(function(a, b) { return b[2] - a[2]; });
+    fill("queue", queueRows);
+    fill("migrations", migrationRows);
+    fill("inflight", (results[1] || []).map(function(l) {
+      return [l.UUID, l.Table, l.Files, l.RetryCount];
+    }));
+    var failed = results[2] || [];
+    return Promise.all(failed.map(function(l) { return notes(l.Table); })).then(function(tableNotes) {
+      fill("failed", failed.map(function(l, i) {
+        return [l.UUID, l.Table, l.Files, l.RetryCount, l.RetryAt,
+          {className: "error", text: l.LastError}, {className: "note", text: tableNotes[i]}];
+      }));
+    });
+  }).then(function() {
+    document.getElementById("refreshed").textContent = new Date().toLocaleTimeString();
+    status.textContent = "";
+  }).catch(function(err) {
+    status.textContent = err.message;
+  });
+}
+
+refresh();
+setInterval(refresh, 30000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,53 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/backend"
+)
+
+// ExportRequest asks to export a table, or its rows in a time range, to the export prefix.
+type ExportRequest struct {
+	// From and To, if set, bound TimeColumn: rows from From up to but excluding To are exported
+	From, To *time.Time
+	// TimeColumn is the column From and To bound; "time" if empty
+	TimeColumn string
+	// TimeoutMs times out the UNLOAD if positive
+	TimeoutMs int
+	Requester string
+}
+
+// Export UNLOADs the table as Parquet files under the export prefix in the background, in a folder
+// named by the job's ID. Returns the ID of the job tracking it, whose detail once it succeeds is how
+// many rows it unloaded, and where to.
+func (cBackend *Backend) Export(table string, req ExportRequest) (string, error) {
+	if _, ok := cBackend.versions.Get(table); !ok {
+		return "", fmt.Errorf("unknown table %s", table)
+	}
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return "", errors.New("From must be before To")
+	}
+	id := cBackend.jobs.start("export", table)
+	logger.Go(func() {
+		fields := logger.WithField("jobID", id).WithField("table", table).WithField("requester", req.Requester)
+		stats, err := cBackend.exporter.Export(table, backend.ExportRequest{
+			From:       req.From,
+			To:         req.To,
+			TimeColumn: req.TimeColumn,
+			Name:       id,
+			TimeoutMs:  req.TimeoutMs,
+		})
+		if err != nil {
+			fields.WithError(err).Error("Error exporting table")
+		} else {
+			cBackend.jobs.setDetail(id, fmt.Sprintf("unloaded %d rows to %s", stats.RowsUnloaded, stats.URL))
+			fields.WithField("url", stats.URL).WithField("rows", stats.RowsUnloaded).
+				WithField("wlmWait", stats.WLMWait).WithField("duration", stats.Duration).Info("Exported table")
+		}
+		cBackend.jobs.finish(id, err)
+	})
+	return id, nil
+}
@@ -0,0 +1,316 @@
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	controlpb "github.com/twitchscience/rs_ingester/control/proto"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultWatchInterval is how often the streaming RPCs check for new load events and status changes
+	defaultWatchInterval = time.Second
+	// watchEventsLimit is the most load events read per check, which should cover a check's worth
+	watchEventsLimit = 1000
+)
+
+// GRPCServer serves the control operations of control/proto/control.proto on the control backend,
+// with the validation and errors of the JSON routes each mirrors.
+type GRPCServer struct {
+	controlpb.UnimplementedControlServer
+	cb    *Backend
+	stats monitoring.SafeStatter
+	// watchInterval is how often the streaming RPCs check for changes
+	watchInterval time.Duration
+}
+
+// NewGRPCServer instantiates the gRPC control service on the control backend.
+func NewGRPCServer(cb *Backend, stats monitoring.SafeStatter) *GRPCServer {
+	return &GRPCServer{cb: cb, stats: stats, watchInterval: defaultWatchInterval}
+}
+
+// NewGRPCService returns a gRPC server of the control service, over TLS if creds is non-nil, with the
+// control routes' auth. If its token is set, calls must have it as "authorization: Bearer <token>"
+// metadata. Calls can't be signed, so auth by HMAC keys alone is an error rather than leaving the
+// service open.
+func NewGRPCService(s *GRPCServer, auth AuthConfig, creds credentials.TransportCredentials) (*grpc.Server, error) {
+	token := auth.Token
+	if token == "" && len(auth.HMACKeys) > 0 {
+		return nil, errors.New("gRPC calls can't be signed by HMAC keys; a control auth token is required")
+	}
+	var opts []grpc.ServerOption
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(func(ctx context.Context, req interface{},
+			info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := checkToken(ctx, token, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}), grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+			handler grpc.StreamHandler) error {
+			if err := checkToken(ss.Context(), token, info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}))
+	}
+	server := grpc.NewServer(opts...)
+	controlpb.RegisterControlServer(server, s)
+	return server, nil
+}
+
+// checkToken returns an Unauthenticated error unless the call's authorization metadata is the token.
+func checkToken(ctx context.Context, token, method string) error {
+	md, _ := grpcmetadata.FromIncomingContext(ctx)
+	expected := []byte("Bearer " + token)
+	for _, given := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(given), expected) == 1 {
+			return nil
+		}
+	}
+	logger.WithField("method", method).Warn("Rejected gRPC call with bad authorization token")
+	return status.Error(codes.Unauthenticated, "bad authorization token")
+}
+
+// ForceLoad makes the table the highest priority to load next.
+func (s *GRPCServer) ForceLoad(ctx context.Context, req *controlpb.ForceLoadRequest) (*controlpb.ForceLoadResponse, error) {
+	if req.Table == "" {
+		return nil, status.Error(codes.InvalidArgument, "Table name empty.")
+	}
+	err := s.cb.ForceLoad(req.Table, req.Requester)
+	if err != nil {
+		logger.WithError(err).WithField("table", req.Table).
+			WithField("requester", req.Requester).Error("Error forcing load")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	lib.TableInc(s.stats, "force_load", req.Table, 1)
+	return &controlpb.ForceLoadResponse{}, nil
+}
+
+// GetTableStatus returns whether the table exists, its version and whether it's paused.
+func (s *GRPCServer) GetTableStatus(ctx context.Context, req *controlpb.TableStatusRequest) (*controlpb.TableStatus, error) {
+	if req.Table == "" {
+		return nil, status.Error(codes.InvalidArgument, "Table name empty.")
+	}
+	tableStatus, err := s.tableStatus(req.Table)
+	if err != nil {
+		logger.WithError(err).WithField("table", req.Table).Error("Error getting table status")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return tableStatus, nil
+}
+
+// tableStatus returns the table's status from the versions cache and the paused tables.
+func (s *GRPCServer) tableStatus(table string) (*controlpb.TableStatus, error) {
+	tableStatus := &controlpb.TableStatus{Table: table}
+	if version, exists := s.cb.versions.Get(table); exists {
+		tableStatus.Exists, tableStatus.Version = true, int32(version)
+	}
+	paused, err := s.cb.PausedTables()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paused {
+		if p.Table == table {
+			tableStatus.Paused, tableStatus.PauseReason = true, p.Reason
+			break
+		}
+	}
+	return tableStatus, nil
+}
+
+// PauseTable stops the table's loads from starting until it's resumed.
+func (s *GRPCServer) PauseTable(ctx context.Context, req *controlpb.PauseTableRequest) (*controlpb.PauseTableResponse, error) {
+	if req.Requester == "" {
+		return nil, status.Error(codes.InvalidArgument, "Requester is required.")
+	}
+	err := s.bulk(BulkOperation{Op: BulkPause, Tables: req.Table, Reason: req.Reason}, req.Requester)
+	if err != nil {
+		return nil, err
+	}
+	return &controlpb.PauseTableResponse{}, nil
+}
+
+// ResumeTable lets the paused table's loads start again.
+func (s *GRPCServer) ResumeTable(ctx context.Context, req *controlpb.ResumeTableRequest) (*controlpb.ResumeTableResponse, error) {
+	err := s.bulk(BulkOperation{Op: BulkResume, Tables: req.Table}, "grpc")
+	if err != nil {
+		return nil, err
+	}
+	return &controlpb.ResumeTableResponse{}, nil
+}
+
+// bulk runs a bulk operation of one table, returning an InvalidArgument error if the request is
+// invalid, as the bulk route's 400, or an Internal one if the operation fails.
+func (s *GRPCServer) bulk(op BulkOperation, requester string) error {
+	if op.Tables == "" || strings.ContainsAny(op.Tables, "*?") {
+		return status.Error(codes.InvalidArgument, "Table must be a table name.")
+	}
+	results, err := s.cb.Bulk(BulkRequest{Operations: []BulkOperation{op}, Requester: requester})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			logger.WithField("op", result.Op).WithField("table", result.Table).
+				WithField("requester", requester).WithField("error", result.Error).Error("Error in bulk operation")
+			return status.Error(codes.Internal, result.Error)
+		}
+		lib.TableInc(s.stats, "bulk."+string(result.Op), result.Table, 1)
+	}
+	return nil
+}
+
+// Migrate migrates the table to its next version right away, returning once the migration is done.
+func (s *GRPCServer) Migrate(ctx context.Context, req *controlpb.MigrateRequest) (*controlpb.MigrateResponse, error) {
+	err := s.cb.Migrate(req.Table, int(req.Version))
+	if err != nil {
+		logger.WithError(err).WithField("table", req.Table).WithField("version", req.Version).
+			Error("Error migrating table on request")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	lib.TableInc(s.stats, "migrate", req.Table, 1)
+	return &controlpb.MigrateResponse{}, nil
+}
+
+// GetLoadStatus returns the state of a load in ingesterdb and Redshift.
+func (s *GRPCServer) GetLoadStatus(ctx context.Context, req *controlpb.LoadStatusRequest) (*controlpb.LoadStatus, error) {
+	if uuid.Parse(req.Uuid) == nil {
+		return nil, status.Error(codes.InvalidArgument, "uuid must be a UUID.")
+	}
+	loadStatus, err := s.cb.LoadStatus(req.Uuid)
+	switch err {
+	case nil:
+	case metadata.ErrUnknownLoad:
+		return nil, status.Error(codes.NotFound, err.Error())
+	default:
+		logger.WithError(err).WithField("manifestUUID", req.Uuid).Error("Error getting load status")
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &controlpb.LoadStatus{
+		Uuid:           loadStatus.UUID,
+		Table:          loadStatus.Table,
+		State:          string(loadStatus.State),
+		FileCount:      int32(loadStatus.FileCount),
+		RetryCount:     int32(loadStatus.RetryCount),
+		RetryAt:        timestampOrNil(loadStatus.RetryAt),
+		LastError:      loadStatus.LastError,
+		RowsLoaded:     int64OrZero(loadStatus.RowsLoaded),
+		BytesScanned:   int64OrZero(loadStatus.BytesScanned),
+		LoadedAt:       timestampOrNil(loadStatus.LoadedAt),
+		ManifestUrl:    loadStatus.ManifestURL,
+		RedshiftStatus: string(loadStatus.RedshiftStatus),
+	}, nil
+}
+
+// WatchLoads streams the load events recorded from now on, of the request's table if it's set, until
+// the call ends.
+func (s *GRPCServer) WatchLoads(req *controlpb.WatchLoadsRequest, stream grpc.ServerStreamingServer[controlpb.LoadEvent]) error {
+	// Events are streamed in the order they were recorded, from after the latest one now
+	var lastID int64
+	latest, err := s.cb.LoadEvents(metadata.LoadEventFilter{Limit: 1})
+	if err != nil {
+		logger.WithError(err).Error("Error finding the latest load event to stream from")
+		return status.Error(codes.Internal, err.Error())
+	}
+	if len(latest) > 0 {
+		lastID = latest[0].ID
+	}
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+	events := []metadata.LoadEvent{}
+	for {
+		// A full page means more events are waiting, which are read without waiting for the next check
+		if len(events) < watchEventsLimit {
+			select {
+			case <-stream.Context().Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+		events, err = s.cb.LoadEvents(metadata.LoadEventFilter{AfterID: &lastID, Limit: watchEventsLimit})
+		if err != nil {
+			logger.WithError(err).Error("Error listing load events to stream")
+			return status.Error(codes.Internal, err.Error())
+		}
+		for _, event := range events {
+			lastID = event.ID
+			if req.Table != "" && event.Table != req.Table {
+				continue
+			}
+			err = stream.Send(&controlpb.LoadEvent{
+				Id:       event.ID,
+				Time:     timestamppb.New(event.Time),
+				LoadUuid: event.LoadUUID,
+				Table:    event.Table,
+				Worker:   event.Worker,
+				State:    string(event.State),
+				Detail:   event.Detail,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WatchTableStatus streams the table's status, at first and then whenever it changes, until the
+// call ends.
+func (s *GRPCServer) WatchTableStatus(req *controlpb.TableStatusRequest, stream grpc.ServerStreamingServer[controlpb.TableStatus]) error {
+	if req.Table == "" {
+		return status.Error(codes.InvalidArgument, "Table name empty.")
+	}
+	var last *controlpb.TableStatus
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+	for {
+		tableStatus, err := s.tableStatus(req.Table)
+		if err != nil {
+			logger.WithError(err).WithField("table", req.Table).Error("Error getting table status to stream")
+			return status.Error(codes.Internal, err.Error())
+		}
+		if last == nil || !proto.Equal(last, tableStatus) {
+			if err = stream.Send(tableStatus); err != nil {
+				return err
+			}
+			last = tableStatus
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func int64OrZero(i *int64) int64 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
@@ -0,0 +1,201 @@
+package control
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	controlpb "github.com/twitchscience/rs_ingester/control/proto"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/versions"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// pauseReader holds paused tables and the load events recorded so far.
+type pauseReader struct {
+	metadata.Reader
+	lock   sync.Mutex
+	paused map[string]string
+	events []metadata.LoadEvent
+}
+
+func (r *pauseReader) PausedTables() ([]metadata.PausedTable, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var paused []metadata.PausedTable
+	for table, reason := range r.paused {
+		paused = append(paused, metadata.PausedTable{Table: table, Reason: reason})
+	}
+	return paused, nil
+}
+
+func (r *pauseReader) PauseTable(paused metadata.PausedTable) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.paused[paused.Table] = paused.Reason
+	return nil
+}
+
+func (r *pauseReader) ResumeTable(table string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.paused, table)
+	return nil
+}
+
+func (r *pauseReader) LoadEvents(filter metadata.LoadEventFilter) ([]metadata.LoadEvent, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var events []metadata.LoadEvent
+	if filter.AfterID != nil {
+		for _, event := range r.events {
+			if event.ID > *filter.AfterID && len(events) < filter.Limit {
+				events = append(events, event)
+			}
+		}
+		return events, nil
+	}
+	for i := len(r.events) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		events = append(events, r.events[i])
+	}
+	return events, nil
+}
+
+func (r *pauseReader) record(event metadata.LoadEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	event.ID = int64(len(r.events) + 1)
+	r.events = append(r.events, event)
+}
+
+// dialControl serves the control service on an in-memory listener, returning a client of it.
+func dialControl(t *testing.T, cb *Backend, token string) controlpb.ControlClient {
+	s := NewGRPCServer(cb, monitoring.NewMockStatter())
+	s.watchInterval = 10 * time.Millisecond
+	server, err := NewGRPCService(s, AuthConfig{Token: token}, nil)
+	assert.NoError(t, err)
+	listener := bufconn.Listen(1 << 16)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return controlpb.NewControlClient(conn)
+}
+
+func TestGRPCTableStatus(t *testing.T) {
+	reader := &pauseReader{paused: map[string]string{}}
+	client := dialControl(t, &Backend{metaReader: reader, versions: versions.New(map[string]int{"video": 3})}, "")
+	ctx := context.Background()
+
+	tableStatus, err := client.GetTableStatus(ctx, &controlpb.TableStatusRequest{Table: "video"})
+	assert.NoError(t, err)
+	assert.True(t, tableStatus.Exists)
+	assert.Equal(t, int32(3), tableStatus.Version)
+	assert.False(t, tableStatus.Paused)
+
+	_, err = client.PauseTable(ctx, &controlpb.PauseTableRequest{Table: "video", Reason: "backfill"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "the requester is required")
+	_, err = client.PauseTable(ctx, &controlpb.PauseTableRequest{Table: "*", Requester: "me"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "only one table is paused")
+	_, err = client.PauseTable(ctx, &controlpb.PauseTableRequest{Table: "chat", Requester: "me"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "unknown tables aren't paused")
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch, err := client.WatchTableStatus(watchCtx, &controlpb.TableStatusRequest{Table: "video"})
+	assert.NoError(t, err)
+	first, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.False(t, first.Paused)
+
+	_, err = client.PauseTable(ctx, &controlpb.PauseTableRequest{Table: "video", Reason: "backfill", Requester: "me"})
+	assert.NoError(t, err)
+	paused, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.True(t, paused.Paused)
+	assert.Equal(t, "backfill", paused.PauseReason)
+
+	_, err = client.ResumeTable(ctx, &controlpb.ResumeTableRequest{Table: "video"})
+	assert.NoError(t, err)
+	resumed, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.False(t, resumed.Paused)
+}
+
+func TestGRPCWatchLoads(t *testing.T) {
+	reader := &pauseReader{paused: map[string]string{}}
+	client := dialControl(t, &Backend{metaReader: reader, versions: versions.New(nil)}, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader.record(metadata.LoadEvent{Time: time.Now().Add(-time.Minute), Table: "video", State: "assigned"})
+	watch, err := client.WatchLoads(ctx, &controlpb.WatchLoadsRequest{Table: "video"})
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	now := time.Now()
+	reader.record(metadata.LoadEvent{Time: now, Table: "chat", State: "assigned"})
+	reader.record(metadata.LoadEvent{Time: now, Table: "video", State: "admitted"})
+	reader.record(metadata.LoadEvent{Time: now, Table: "video", State: "succeeded"})
+	// Recorded after the others by a worker whose clock is behind
+	reader.record(metadata.LoadEvent{Time: now.Add(-time.Hour), Table: "video", State: "failed"})
+
+	admitted, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "admitted", admitted.State, "events before the call and of other tables aren't streamed")
+	succeeded, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "succeeded", succeeded.State)
+	failed, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "failed", failed.State, "events are streamed in the order they were recorded")
+
+	// A burst of more events than are read per check is streamed whole, in order
+	for i := 0; i < watchEventsLimit+10; i++ {
+		reader.record(metadata.LoadEvent{Time: now, Table: "video", State: "assigned"})
+	}
+	lastID := failed.Id
+	for i := 0; i < watchEventsLimit+10; i++ {
+		event, err := watch.Recv()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, lastID+1, event.Id)
+		lastID = event.Id
+	}
+}
+
+func TestGRPCToken(t *testing.T) {
+	reader := &pauseReader{paused: map[string]string{}}
+	client := dialControl(t, &Backend{metaReader: reader, versions: versions.New(nil)}, "secret")
+	req := &controlpb.TableStatusRequest{Table: "video"}
+
+	_, err := client.GetTableStatus(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	ctx := grpcmetadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	_, err = client.GetTableStatus(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	ctx = grpcmetadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	_, err = client.GetTableStatus(ctx, req)
+	assert.NoError(t, err)
+}
+
+func TestGRPCHMACOnly(t *testing.T) {
+	s := NewGRPCServer(&Backend{}, monitoring.NewMockStatter())
+	_, err := NewGRPCService(s, AuthConfig{HMACKeys: map[string][]byte{"blueprint": []byte("key")}}, nil)
+	assert.Error(t, err, "the service isn't left open when only HMAC keys protect the control routes")
+	_, err = NewGRPCService(s, AuthConfig{Token: "secret", HMACKeys: map[string][]byte{"blueprint": []byte("key")}}, nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,332 @@
+package control
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// inventoryChunkLines is the number of lines of an inventory data file whose files are queued in one
+// transaction. A file's chunks are the same every time, so a backfill resumes after the last chunk
+// it finished.
+const inventoryChunkLines = 1000
+
+// InventoryBackfillRequest asks to queue the processed files listed in an S3 Inventory report.
+type InventoryBackfillRequest struct {
+	// Manifest is the s3:// URL of the report's manifest.json
+	Manifest string
+	// Prefix, if set, only queues the files under this prefix of the inventoried bucket
+	Prefix string
+	// Table, if set, only queues the files of this table
+	Table     string
+	Requester string
+}
+
+// InventoryReport is how far backfills of an S3 Inventory report got.
+type InventoryReport struct {
+	Manifest string
+	// Running is whether a backfill of the report is running
+	Running bool
+	// DataFiles is the number of the report's data files, and DoneFiles those all queued
+	DataFiles int
+	DoneFiles int
+	Queued    int64
+	Duplicate int64
+	Skipped   int64
+	// Files are the data files backfills have started on
+	Files []metadata.InventoryFileProgress
+}
+
+// inventoryManifest is the manifest.json of an S3 Inventory report.
+type inventoryManifest struct {
+	SourceBucket string `json:"sourceBucket"`
+	// DestinationBucket is the ARN of the bucket the report's data files are in
+	DestinationBucket string `json:"destinationBucket"`
+	FileFormat        string `json:"fileFormat"`
+	// FileSchema is the comma-separated names of the data files' columns
+	FileSchema string `json:"fileSchema"`
+	Files      []struct {
+		Key string `json:"key"`
+	} `json:"files"`
+}
+
+// errInventoryRunning is returned when asked to backfill an inventory report already being backfilled.
+var errInventoryRunning = errors.New("a backfill of the inventory is already running")
+
+// inventoryColumns are the positions of the columns of an inventory's data files a backfill reads;
+// the optional ones are -1 if the report doesn't have them.
+type inventoryColumns struct {
+	count, bucket, key, isLatest, isDeleteMarker int
+}
+
+// splitS3URL returns the bucket and key of an s3:// URL.
+func splitS3URL(s3URL string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(s3URL, "s3://"), "/", 2)
+	if !strings.HasPrefix(s3URL, "s3://") || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q isn't an s3://<bucket>/<key> URL", s3URL)
+	}
+	return parts[0], parts[1], nil
+}
+
+// readInventoryManifest reads the report's manifest.json, which must be of CSV data files.
+func (cBackend *Backend) readInventoryManifest(manifestURL string) (*inventoryManifest, error) {
+	bucket, key, err := splitS3URL(manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	out, err := cBackend.s3.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("getting inventory manifest %s: %v", manifestURL, err)
+	}
+	defer func() { _ = out.Body.Close() }()
+	var manifest inventoryManifest
+	if err = json.NewDecoder(out.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("decoding inventory manifest %s: %v", manifestURL, err)
+	}
+	if !strings.EqualFold(manifest.FileFormat, "CSV") {
+		return nil, fmt.Errorf("inventory %s is %s; only CSV reports can be backfilled", manifestURL, manifest.FileFormat)
+	}
+	manifest.DestinationBucket = strings.TrimPrefix(manifest.DestinationBucket, "arn:aws:s3:::")
+	// The data files are always read in the same order, so the backfill's progress through them is too
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Key < manifest.Files[j].Key })
+	return &manifest, nil
+}
+
+// columns returns the positions of the data files' columns, which must include the key.
+func (m *inventoryManifest) columns() (inventoryColumns, error) {
+	cols := inventoryColumns{bucket: -1, key: -1, isLatest: -1, isDeleteMarker: -1}
+	names := strings.Split(m.FileSchema, ",")
+	cols.count = len(names)
+	for i, name := range names {
+		switch strings.TrimSpace(name) {
+		case "Bucket":
+			cols.bucket = i
+		case "Key":
+			cols.key = i
+		case "IsLatest":
+			cols.isLatest = i
+		case "IsDeleteMarker":
+			cols.isDeleteMarker = i
+		}
+	}
+	if cols.key < 0 {
+		return cols, fmt.Errorf("inventory schema %q has no Key", m.FileSchema)
+	}
+	return cols, nil
+}
+
+// InventoryBackfill queues the processed files an S3 Inventory report lists at low priority, in the
+// background, like Backfill. The report's data files are read in order, in chunks of a fixed number
+// of lines, recording each chunk once its files are queued, so a backfill of a report that failed or
+// was interrupted resumes where it stopped. Only one backfill of a report runs at once. Returns the ID
+// of the job tracking it, or errInventoryRunning.
+func (cBackend *Backend) InventoryBackfill(req InventoryBackfillRequest) (string, error) {
+	cBackend.inventoryLock.Lock()
+	defer cBackend.inventoryLock.Unlock()
+	if cBackend.inventoryRunning[req.Manifest] {
+		return "", errInventoryRunning
+	}
+	cBackend.inventoryRunning[req.Manifest] = true
+
+	id := cBackend.jobs.start("inventory_backfill", req.Table)
+	logger.Go(func() {
+		defer func() {
+			cBackend.inventoryLock.Lock()
+			delete(cBackend.inventoryRunning, req.Manifest)
+			cBackend.inventoryLock.Unlock()
+		}()
+		counts, err := cBackend.inventoryBackfill(id, req)
+		fields := logger.WithField("jobID", id).WithField("manifest", req.Manifest).WithField("prefix", req.Prefix).
+			WithField("requester", req.Requester).WithField("queued", counts.queued).
+			WithField("duplicate", counts.duplicate).WithField("skipped", counts.skipped)
+		if err != nil {
+			fields.WithError(err).Error("Error backfilling inventory")
+		} else {
+			fields.Info("Finished inventory backfill")
+		}
+		cBackend.jobs.finish(id, err)
+	})
+	return id, nil
+}
+
+func (cBackend *Backend) inventoryBackfill(id string, req InventoryBackfillRequest) (backfillCounts, error) {
+	var counts backfillCounts
+	manifest, err := cBackend.readInventoryManifest(req.Manifest)
+	if err != nil {
+		return counts, err
+	}
+	cols, err := manifest.columns()
+	if err != nil {
+		return counts, err
+	}
+	recorded, err := cBackend.metaReader.InventoryProgress(req.Manifest)
+	if err != nil {
+		return counts, err
+	}
+	done := make(map[string]metadata.InventoryFileProgress, len(recorded))
+	for _, file := range recorded {
+		done[file.DataFile] = file
+	}
+	for i, file := range manifest.Files {
+		progress, ok := done[file.Key]
+		if !ok {
+			progress = metadata.InventoryFileProgress{Manifest: req.Manifest, DataFile: file.Key}
+		}
+		if !progress.Done {
+			progress, err = cBackend.backfillDataFile(manifest, cols, req, progress, func(p metadata.InventoryFileProgress) {
+				cBackend.jobs.setDetail(id, fmt.Sprintf("data file %d of %d, line %d; %s", i+1, len(manifest.Files),
+					p.Lines, counts.add(p)))
+			})
+			if err != nil {
+				return counts.add(progress), err
+			}
+		}
+		counts = counts.add(progress)
+		cBackend.jobs.setDetail(id, fmt.Sprintf("%d of %d data files done; %s", i+1, len(manifest.Files), counts))
+	}
+	return counts, nil
+}
+
+// add returns the counts with the data file's.
+func (c backfillCounts) add(progress metadata.InventoryFileProgress) backfillCounts {
+	c.queued += int(progress.Queued)
+	c.duplicate += int(progress.Duplicate)
+	c.skipped += int(progress.Skipped)
+	return c
+}
+
+// backfillDataFile queues the files the inventory data file lists after the progress's lines, a chunk
+// at a time, recording the progress and calling chunkDone after each chunk. It returns the progress
+// made, which is done if it gets to the end of the file.
+func (cBackend *Backend) backfillDataFile(manifest *inventoryManifest, cols inventoryColumns,
+	req InventoryBackfillRequest, progress metadata.InventoryFileProgress,
+	chunkDone func(metadata.InventoryFileProgress)) (metadata.InventoryFileProgress, error) {
+	out, err := cBackend.s3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(manifest.DestinationBucket),
+		Key:    aws.String(progress.DataFile),
+	})
+	if err != nil {
+		return progress, fmt.Errorf("getting inventory data file %s: %v", progress.DataFile, err)
+	}
+	defer func() { _ = out.Body.Close() }()
+	gz, err := gzip.NewReader(out.Body)
+	if err != nil {
+		return progress, fmt.Errorf("decompressing inventory data file %s: %v", progress.DataFile, err)
+	}
+	reader := csv.NewReader(gz)
+	reader.FieldsPerRecord = cols.count
+
+	var line int64
+	var chunk []*metadata.Load
+	var skipped int64
+	for {
+		record, err := reader.Read()
+		if err != nil && err != io.EOF {
+			return progress, fmt.Errorf("reading line %d of inventory data file %s: %v", line+1, progress.DataFile, err)
+		}
+		if err == nil {
+			line++
+			if line <= progress.Lines {
+				continue
+			}
+			if load := cBackend.inventoryLoad(manifest, cols, req, record); load != nil {
+				chunk = append(chunk, load)
+			} else {
+				skipped++
+			}
+		}
+		// Lines before progress.Lines were skipped above, and it's a whole number of chunks
+		if err == io.EOF || line%inventoryChunkLines == 0 {
+			if len(chunk) > 0 {
+				duplicate, insertErr := cBackend.metaReader.InsertBackfillLoads(chunk)
+				if insertErr != nil {
+					return progress, fmt.Errorf("queuing lines %d-%d of inventory data file %s: %v",
+						progress.Lines+1, line, progress.DataFile, insertErr)
+				}
+				for _, dup := range duplicate {
+					if dup {
+						progress.Duplicate++
+					} else {
+						progress.Queued++
+					}
+				}
+			}
+			progress.Skipped += skipped
+			progress.Lines = line
+			progress.Done = err == io.EOF
+			if setErr := cBackend.metaReader.SetInventoryProgress(progress); setErr != nil {
+				return progress, setErr
+			}
+			chunkDone(progress)
+			chunk, skipped = nil, 0
+		}
+		if err == io.EOF {
+			return progress, nil
+		}
+	}
+}
+
+// inventoryLoad returns the load of the processed file the inventory's line lists, or nil if it's
+// not one the backfill queues: it's not a processed file of the request's table or a known one, or
+// it's not the object's current version.
+func (cBackend *Backend) inventoryLoad(manifest *inventoryManifest, cols inventoryColumns,
+	req InventoryBackfillRequest, record []string) *metadata.Load {
+	if (cols.isLatest >= 0 && record[cols.isLatest] == "false") ||
+		(cols.isDeleteMarker >= 0 && record[cols.isDeleteMarker] == "true") {
+		return nil
+	}
+	// Inventory reports URL-encode keys
+	key, err := url.QueryUnescape(record[cols.key])
+	if err != nil || !strings.HasPrefix(key, req.Prefix) || strings.HasSuffix(key, "/") {
+		return nil
+	}
+	table, version, ok := metadata.ParseProcessedKey(key)
+	if !ok || (req.Table != "" && table != req.Table) {
+		return nil
+	}
+	if _, known := cBackend.versions.Get(table); !known {
+		return nil
+	}
+	bucket := manifest.SourceBucket
+	if cols.bucket >= 0 {
+		bucket = record[cols.bucket]
+	}
+	return &metadata.Load{KeyName: bucket + "/" + key, TableName: table, TableVersion: version}
+}
+
+// InventoryBackfillReport returns how far backfills of the S3 Inventory report got.
+func (cBackend *Backend) InventoryBackfillReport(manifestURL string) (InventoryReport, error) {
+	report := InventoryReport{Manifest: manifestURL}
+	manifest, err := cBackend.readInventoryManifest(manifestURL)
+	if err != nil {
+		return report, err
+	}
+	report.DataFiles = len(manifest.Files)
+	report.Files, err = cBackend.metaReader.InventoryProgress(manifestURL)
+	if err != nil {
+		return report, err
+	}
+	for _, file := range report.Files {
+		if file.Done {
+			report.DoneFiles++
+		}
+		report.Queued += file.Queued
+		report.Duplicate += file.Duplicate
+		report.Skipped += file.Skipped
+	}
+	cBackend.inventoryLock.Lock()
+	report.Running = cBackend.inventoryRunning[manifestURL]
+	cBackend.inventoryLock.Unlock()
+	return report, nil
+}
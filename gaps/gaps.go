@@ -0,0 +1,270 @@
+package gaps
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/versions"
+)
+
+// maxReportedFiles bounds the missing files listed in a report; all of them are counted.
+const maxReportedFiles = 1000
+
+// Accounter tells which files were queued or diverted, and which are dropped by sampling.
+type Accounter interface {
+	AccountedKeys(keys []string) (map[string]bool, error)
+	SamplingRules() ([]metadata.SamplingRule, error)
+}
+
+// Config configures a Checker.
+type Config struct {
+	// Bucket is the bucket the processor writes its output to
+	Bucket string
+	// PrefixLayout is the prefix of an hour of the processor's output as a Go time layout, e.g.
+	// "2006-01-02/" for daily prefixes; each distinct prefix over the window is listed once
+	PrefixLayout string
+	// Lookback is how far back files are checked
+	Lookback time.Duration
+	// Settle is how long a file has to be queued before it's reported missing
+	Settle time.Duration
+	// Period is how often files are checked
+	Period time.Duration
+}
+
+// MissingFile is a processed file that was neither queued nor diverted.
+type MissingFile struct {
+	Key      string
+	Table    string
+	Version  int
+	Modified time.Time
+}
+
+// Report is the outcome of a check for files missing from ingesterdb.
+type Report struct {
+	Checked time.Time
+	// From and To bound when the files checked were written
+	From    time.Time
+	To      time.Time
+	Listed  int
+	Sampled int
+	// Missing counts the missing files by table
+	Missing map[string]int
+	// MissingFiles are the first of the missing files
+	MissingFiles []MissingFile
+	Error        string `json:",omitempty"`
+}
+
+// Checker periodically lists the processor's recent output and reports the files that never made it
+// into ingesterdb, e.g. because their SQS messages were lost. Files of tables the loader doesn't
+// know or doesn't load, and files dropped by sampling, aren't checked.
+type Checker struct {
+	s3        s3iface.S3API
+	accounter Accounter
+	versions  versions.Getter
+	tables    *metadata.TableFilter
+	stats     monitoring.SafeStatter
+	config    Config
+
+	report     *Report
+	reportLock sync.RWMutex
+	gapTables  map[string]bool
+	closer     chan bool
+	closed     chan bool
+}
+
+// New returns a Checker and starts its loop. tables limits the tables checked; nil checks all.
+func New(s3Client s3iface.S3API, accounter Accounter, tableVersions versions.Getter, tables *metadata.TableFilter,
+	stats monitoring.SafeStatter, config Config) *Checker {
+	c := &Checker{
+		s3:        s3Client,
+		accounter: accounter,
+		versions:  tableVersions,
+		tables:    tables,
+		stats:     stats,
+		config:    config,
+		gapTables: make(map[string]bool),
+		closer:    make(chan bool),
+		closed:    make(chan bool),
+	}
+	logger.Go(c.loop)
+	return c
+}
+
+func (c *Checker) loop() {
+	defer close(c.closed)
+	tick := time.NewTicker(c.config.Period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			c.run(time.Now().In(time.UTC))
+		case <-c.closer:
+			return
+		}
+	}
+}
+
+// run checks for missing files and records the report.
+func (c *Checker) run(now time.Time) {
+	report := c.check(now)
+	if report.Error != "" {
+		logger.WithField("error", report.Error).Error("Error checking for missing files")
+		c.stats.SafeInc("gaps.total.errors", 1, 1.0)
+	} else {
+		c.sendStats(report)
+	}
+	c.reportLock.Lock()
+	defer c.reportLock.Unlock()
+	c.report = report
+}
+
+// prefixes returns the prefixes to list for files written between from and to.
+func (c *Checker) prefixes(from, to time.Time) []string {
+	var prefixes []string
+	seen := make(map[string]bool)
+	for hour := from.Truncate(time.Hour); !hour.After(to); hour = hour.Add(time.Hour) {
+		prefix := hour.Format(c.config.PrefixLayout)
+		if !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// check lists the files written in the window and looks each page of them up in ingesterdb.
+func (c *Checker) check(now time.Time) *Report {
+	report := &Report{
+		Checked:      now,
+		From:         now.Add(-c.config.Lookback),
+		To:           now.Add(-c.config.Settle),
+		Missing:      make(map[string]int),
+		MissingFiles: []MissingFile{},
+	}
+	rules, err := c.accounter.SamplingRules()
+	if err != nil {
+		report.Error = fmt.Sprintf("reading sampling rules: %v", err)
+		return report
+	}
+	keepPercents := make(map[string]int, len(rules))
+	for _, rule := range rules {
+		keepPercents[rule.Table] = rule.KeepPercent
+	}
+
+	for _, prefix := range c.prefixes(report.From, report.To) {
+		var pageErr error
+		err = c.s3.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+			Bucket: aws.String(c.config.Bucket),
+			Prefix: aws.String(prefix),
+		}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			pageErr = c.checkPage(report, page.Contents, keepPercents)
+			return pageErr == nil
+		})
+		if err == nil {
+			err = pageErr
+		}
+		if err != nil {
+			report.Error = fmt.Sprintf("checking s3://%s/%s: %v", c.config.Bucket, prefix, err)
+			return report
+		}
+	}
+	return report
+}
+
+// checkPage adds the page's files written in the report's window to it.
+func (c *Checker) checkPage(report *Report, objects []*s3.Object, keepPercents map[string]int) error {
+	files := make(map[string]MissingFile)
+	var keys []string
+	for _, object := range objects {
+		modified := aws.TimeValue(object.LastModified)
+		if modified.Before(report.From) || modified.After(report.To) {
+			continue
+		}
+		table, version, ok := metadata.ParseProcessedKey(aws.StringValue(object.Key))
+		if !ok {
+			continue
+		}
+		if _, known := c.versions.Get(table); !known || !c.tables.Allows(table) {
+			continue
+		}
+		// Keys are stored with their bucket, as in the processor's messages
+		key := c.config.Bucket + "/" + aws.StringValue(object.Key)
+		report.Listed++
+		if keepPercent, sampled := keepPercents[table]; sampled && !metadata.KeepsFile(key, keepPercent) {
+			report.Sampled++
+			continue
+		}
+		files[key] = MissingFile{Key: key, Table: table, Version: version, Modified: modified}
+		keys = append(keys, key)
+	}
+	accounted, err := c.accounter.AccountedKeys(keys)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if accounted[key] {
+			continue
+		}
+		file := files[key]
+		report.Missing[file.Table]++
+		if len(report.MissingFiles) < maxReportedFiles {
+			report.MissingFiles = append(report.MissingFiles, file)
+		}
+	}
+	return nil
+}
+
+// sendStats sends the missing files of each table, zeroing the tables whose gaps are gone.
+func (c *Checker) sendStats(report *Report) {
+	total := 0
+	for table, missing := range report.Missing {
+		lib.TableGauge(c.stats, "gaps.missing_files", table, int64(missing))
+		total += missing
+	}
+	for table := range c.gapTables {
+		if report.Missing[table] == 0 {
+			lib.TableGauge(c.stats, "gaps.missing_files", table, 0)
+			delete(c.gapTables, table)
+		}
+	}
+	for table := range report.Missing {
+		c.gapTables[table] = true
+	}
+	c.stats.SafeGauge("gaps.total.missing_files", int64(total), 1.0)
+	c.stats.SafeGauge("gaps.total.listed_files", int64(report.Listed), 1.0)
+	if total == 0 {
+		return
+	}
+	tables := make([]string, 0, len(report.Missing))
+	for table := range report.Missing {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	logger.WithField("missing", total).WithField("tables", tables).WithField("from", report.From).
+		WithField("to", report.To).Error("Processed files are missing from ingesterdb")
+}
+
+// LastReport returns the report of the last check, or false if none has finished.
+func (c *Checker) LastReport() (Report, bool) {
+	c.reportLock.RLock()
+	defer c.reportLock.RUnlock()
+	if c.report == nil {
+		return Report{}, false
+	}
+	return *c.report, true
+}
+
+// Close stops the checker, waiting for the check in progress, if any.
+func (c *Checker) Close() {
+	close(c.closer)
+	<-c.closed
+}
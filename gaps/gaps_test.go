@@ -0,0 +1,91 @@
+package gaps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/versions"
+)
+
+type fakeS3 struct {
+	s3iface.S3API
+	objects map[string][]*s3.Object
+	listed  []string
+}
+
+func (f *fakeS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input,
+	fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	prefix := aws.StringValue(input.Prefix)
+	f.listed = append(f.listed, prefix)
+	fn(&s3.ListObjectsV2Output{Contents: f.objects[prefix]}, true)
+	return nil
+}
+
+type fakeAccounter struct {
+	accounted map[string]bool
+	rules     []metadata.SamplingRule
+}
+
+func (a *fakeAccounter) AccountedKeys(keys []string) (map[string]bool, error) {
+	accounted := make(map[string]bool)
+	for _, key := range keys {
+		accounted[key] = a.accounted[key]
+	}
+	return accounted, nil
+}
+
+func (a *fakeAccounter) SamplingRules() ([]metadata.SamplingRule, error) {
+	return a.rules, nil
+}
+
+func object(key string, modified time.Time) *s3.Object {
+	return &s3.Object{Key: aws.String(key), LastModified: aws.Time(modified)}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2017, 3, 8, 1, 30, 0, 0, time.UTC)
+	written := now.Add(-time.Hour)
+	fake := &fakeS3{objects: map[string][]*s3.Object{
+		"2017-03-08/": {
+			object("2017-03-08/video/v1/queued.gz", written),
+			object("2017-03-08/video/v1/lost.gz", written),
+			object("2017-03-08/video/v1/recent.gz", now.Add(-time.Minute)),
+			object("2017-03-08/unknown/v1/lost.gz", written),
+			object("2017-03-08/excluded/v1/lost.gz", written),
+			object("2017-03-08/sampled/v1/lost.gz", written),
+		},
+	}}
+	filter, err := metadata.NewTableFilter(metadata.TablePatterns{Exclude: []string{"excluded"}})
+	assert.NoError(t, err)
+	c := &Checker{
+		s3: fake,
+		accounter: &fakeAccounter{
+			accounted: map[string]bool{"processed/2017-03-08/video/v1/queued.gz": true},
+			rules:     []metadata.SamplingRule{{Table: "sampled", KeepPercent: 0}},
+		},
+		versions: versions.New(map[string]int{"video": 1, "excluded": 1, "sampled": 1}),
+		tables:   filter,
+		stats:    monitoring.NewMockStatter(),
+		config: Config{Bucket: "processed", PrefixLayout: "2006-01-02/", Lookback: 3 * time.Hour,
+			Settle: 30 * time.Minute},
+		gapTables: make(map[string]bool),
+	}
+
+	c.run(now)
+	report, ok := c.LastReport()
+	assert.True(t, ok)
+	assert.Equal(t, []string{"2017-03-07/", "2017-03-08/"}, fake.listed)
+	assert.Equal(t, "", report.Error)
+	assert.Equal(t, 3, report.Listed)
+	assert.Equal(t, 1, report.Sampled)
+	assert.Equal(t, map[string]int{"video": 1}, report.Missing)
+	assert.Equal(t, []MissingFile{{Key: "processed/2017-03-08/video/v1/lost.gz", Table: "video", Version: 1,
+		Modified: written}}, report.MissingFiles)
+	assert.True(t, c.gapTables["video"])
+}
@@ -10,22 +10,20 @@ import (
 )
 
 // NewHealthRouter initializes the healthcheck router
-func NewHealthRouter() http.Handler {
+func NewHealthRouter(hHandler *Handler) http.Handler {
 
 	health := web.New()
 
 	health.Use(middleware.EnvInit)
 	health.Use(middleware.RequestID)
 	health.Use(middleware.RealIP)
-	health.Use(lib.SimpleLogger)
+	health.Use(lib.AccessLogger)
 	health.Use(context.ClearHandler)
 
-	health.Get("/health", HealthCheck)
+	health.Get("/health", hHandler.Live)
+	health.Get("/health/live", hHandler.Live)
+	health.Get("/health/ready", hHandler.Ready)
+	health.Get("/health/deep", hHandler.Deep)
 
 	return health
 }
-
-// HealthCheck responds with the health of the ingester
-func HealthCheck(c web.C, w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-}
@@ -0,0 +1,291 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/zenazn/goji/web"
+)
+
+// Dependencies are what the readiness and deep checks inspect
+type Dependencies struct {
+	MetaReader metadata.Reader
+	AceBackend backend.Backend
+	// WorkersRunning returns whether all the load workers are running
+	WorkersRunning func() bool
+	// MigratorLastActive returns the last time the migrator loop made progress
+	MigratorLastActive func() time.Time
+	// MigratorLiveness, if set, returns an error if the migrator's polls stopped succeeding or a table
+	// has been waiting too long to be migrated
+	MigratorLiveness func() error
+	// MaxQueueAge is the oldest a queued TSV can be before the deep check fails
+	MaxQueueAge time.Duration
+	// MaxMigratorIdle is the longest the migrator can go without progress before the deep check fails
+	MaxMigratorIdle time.Duration
+	// MaxIngesterDBLatency is the longest pinging ingesterdb can take before it's degraded; 0 is no limit
+	MaxIngesterDBLatency time.Duration
+	// Blueprint, if set, is checked for being reachable by the deep check
+	Blueprint Pinger
+	// SQS and SQSQueue, if set, are the metadatastorer's queue, checked for being accessible by the deep check
+	SQS      sqsiface.SQSAPI
+	SQSQueue string
+	// S3 and ManifestBucket, if set, are the manifest bucket, checked for being writable by the deep check
+	S3             s3iface.S3API
+	ManifestBucket string
+	// CheckTimeout is the longest a check can take before it fails; 0 is no limit
+	CheckTimeout time.Duration
+}
+
+// Pinger is a dependency that can be checked for being reachable.
+type Pinger interface {
+	HealthCheck() error
+}
+
+// The states of a check, and of the ingester as a whole.
+const (
+	// StateOK is a check that passed
+	StateOK = "ok"
+	// StateDegraded is a failed check the ingester keeps loading through, e.g. blueprint being down
+	// only holds up migrations
+	StateDegraded = "degraded"
+	// StateCritical is a failed check that stops loads
+	StateCritical = "critical"
+)
+
+// healthCheckKey is the object written to the manifest bucket to check it's writable.
+const healthCheckKey = "healthcheck/rsloadmanager"
+
+// Handler is a handler for the health checks
+type Handler struct {
+	deps *Dependencies
+}
+
+// NewHealthHandler instantiates a handler for the health checks
+func NewHealthHandler(deps *Dependencies) *Handler {
+	return &Handler{deps}
+}
+
+// check is a named sub-check, with the state it puts the ingester in if it fails.
+type check struct {
+	name  string
+	state string
+	fn    func() error
+	// slow, if set, is how long the check can take before it's degraded
+	slow time.Duration
+}
+
+// CheckResult is the outcome of a sub-check.
+type CheckResult struct {
+	State     string
+	Error     string `json:",omitempty"`
+	LatencyMs int64
+}
+
+// Report is the outcome of a health check: the worst state of its sub-checks, and each one's result.
+type Report struct {
+	State  string
+	Checks map[string]CheckResult
+}
+
+// Live responds with 200 as long as the process is up to serve it.
+func (hh *Handler) Live(c web.C, w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Ready responds with 200 if ingesterdb and Redshift are reachable and the workers are running,
+// and 503 otherwise.
+func (hh *Handler) Ready(c web.C, w http.ResponseWriter, r *http.Request) {
+	hh.respondWithChecks(w, hh.readyChecks())
+}
+
+// Deep responds with 200 if the ingester is ready, the load queue isn't lagging, the migrator
+// isn't stuck and the manifest bucket is writable, and 503 otherwise. Blueprint, the SQS queue,
+// stuck migrations and a slow ingesterdb only degrade it.
+func (hh *Handler) Deep(c web.C, w http.ResponseWriter, r *http.Request) {
+	checks := append(hh.readyChecks(),
+		check{name: "queue_lag", state: StateCritical, fn: hh.checkQueueLag},
+		check{name: "migrator", state: StateCritical, fn: hh.checkMigrator},
+	)
+	if hh.deps.MigratorLiveness != nil {
+		checks = append(checks, check{name: "migrator_progress", state: StateDegraded, fn: hh.deps.MigratorLiveness})
+	}
+	if hh.deps.Blueprint != nil {
+		checks = append(checks, check{name: "blueprint", state: StateDegraded, fn: hh.deps.Blueprint.HealthCheck})
+	}
+	if hh.deps.SQS != nil && hh.deps.SQSQueue != "" {
+		checks = append(checks, check{name: "sqs", state: StateDegraded, fn: hh.checkSQS})
+	}
+	if hh.deps.S3 != nil && hh.deps.ManifestBucket != "" {
+		checks = append(checks, check{name: "manifest_bucket", state: StateCritical, fn: hh.checkManifestBucket})
+	}
+	hh.respondWithChecks(w, checks)
+}
+
+func (hh *Handler) readyChecks() []check {
+	return []check{
+		{name: "ingesterdb", state: StateCritical, fn: hh.deps.MetaReader.PingDB, slow: hh.deps.MaxIngesterDBLatency},
+		{name: "redshift", state: StateCritical, fn: hh.deps.AceBackend.HealthCheck},
+		{name: "workers", state: StateCritical, fn: hh.checkWorkers},
+	}
+}
+
+// checkSQS checks the queue exists and can be read.
+func (hh *Handler) checkSQS() error {
+	out, err := hh.deps.SQS.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(hh.deps.SQSQueue)})
+	if err != nil {
+		return fmt.Errorf("getting URL of queue %s: %v", hh.deps.SQSQueue, err)
+	}
+	_, err = hh.deps.SQS.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       out.QueueUrl,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		return fmt.Errorf("getting attributes of queue %s: %v", hh.deps.SQSQueue, err)
+	}
+	return nil
+}
+
+// checkManifestBucket checks manifests can be uploaded, by overwriting a small object in the bucket.
+func (hh *Handler) checkManifestBucket() error {
+	_, err := hh.deps.S3.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(hh.deps.ManifestBucket),
+		Key:    aws.String(healthCheckKey),
+		Body:   strings.NewReader(time.Now().UTC().Format(time.RFC3339)),
+	})
+	if err != nil {
+		return fmt.Errorf("writing %s to %s: %v", healthCheckKey, hh.deps.ManifestBucket, err)
+	}
+	return nil
+}
+
+func (hh *Handler) checkWorkers() error {
+	if !hh.deps.WorkersRunning() {
+		return fmt.Errorf("not all load workers are running")
+	}
+	return nil
+}
+
+func (hh *Handler) checkQueueLag() error {
+	// loads are paused during maintenance windows, so the queue is expected to back up
+	inMaintenance, err := hh.deps.MetaReader.InMaintenance()
+	if err != nil {
+		return fmt.Errorf("checking for maintenance window: %v", err)
+	}
+	if inMaintenance {
+		return nil
+	}
+	allStats, err := hh.deps.MetaReader.StatsForPendingLoads()
+	if err != nil {
+		return fmt.Errorf("getting pending load stats: %v", err)
+	}
+	for _, pendingLoadStats := range allStats {
+		if pendingLoadStats.Type != metadata.PendingInQueue {
+			continue
+		}
+		for _, eventStats := range pendingLoadStats.Stats {
+			if !eventStats.MinTS.IsZero() && time.Since(eventStats.MinTS) > hh.deps.MaxQueueAge {
+				return fmt.Errorf("TSVs for %s have been queued since %v", eventStats.Event, eventStats.MinTS)
+			}
+		}
+	}
+	return nil
+}
+
+func (hh *Handler) checkMigrator() error {
+	lastActive := hh.deps.MigratorLastActive()
+	if time.Since(lastActive) > hh.deps.MaxMigratorIdle {
+		return fmt.Errorf("migrator hasn't made progress since %v", lastActive)
+	}
+	return nil
+}
+
+// run runs the check, failing it if it takes longer than timeout.
+func (c check) run(timeout time.Duration) CheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	logger.Go(func() { done <- c.fn() })
+	var err error
+	if timeout > 0 {
+		select {
+		case err = <-done:
+		case <-time.After(timeout):
+			err = fmt.Errorf("timed out after %v", timeout)
+		}
+	} else {
+		err = <-done
+	}
+	latency := time.Since(start)
+	result := CheckResult{State: StateOK, LatencyMs: int64(latency / time.Millisecond)}
+	switch {
+	case err != nil:
+		result.State, result.Error = c.state, err.Error()
+	case c.slow > 0 && latency > c.slow:
+		result.State, result.Error = StateDegraded, fmt.Sprintf("took %v, longer than %v", latency, c.slow)
+	}
+	return result
+}
+
+// worse returns the worse of two states.
+func worse(a, b string) string {
+	rank := map[string]int{StateOK: 0, StateDegraded: 1, StateCritical: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// respondWithChecks runs the checks concurrently and responds with a JSON Report, with 503 if any
+// critical check failed.
+func (hh *Handler) respondWithChecks(w http.ResponseWriter, checks []check) {
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		i, c := i, c
+		logger.Go(func() {
+			defer wg.Done()
+			results[i] = c.run(hh.deps.CheckTimeout)
+		})
+	}
+	wg.Wait()
+
+	report := Report{State: StateOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		result := results[i]
+		if result.State != StateOK {
+			logger.WithField("check", c.name).WithField("state", result.State).
+				WithField("error", result.Error).Warn("Health check failed")
+		}
+		report.Checks[c.name] = result
+		report.State = worse(report.State, result.State)
+	}
+	status := http.StatusOK
+	if report.State == StateCritical {
+		status = http.StatusServiceUnavailable
+	}
+
+	js, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(js)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,160 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/zenazn/goji/web"
+)
+
+// fakeReader is ingesterdb taking delay to ping, with TSVs queued since queuedSince.
+type fakeReader struct {
+	metadata.Reader
+	delay       time.Duration
+	queuedSince time.Time
+}
+
+func (r *fakeReader) PingDB() error {
+	time.Sleep(r.delay)
+	return nil
+}
+
+func (r *fakeReader) InMaintenance() (bool, error) { return false, nil }
+
+func (r *fakeReader) StatsForPendingLoads() ([]*metadata.PendingLoadStats, error) {
+	return []*metadata.PendingLoadStats{{
+		Type:  metadata.PendingInQueue,
+		Stats: []*metadata.EventStats{{Event: "chat", Count: 1, MinTS: r.queuedSince}},
+	}}, nil
+}
+
+type fakeAce struct {
+	backend.Backend
+}
+
+func (a *fakeAce) HealthCheck() error { return nil }
+
+// fakePinger is a dependency failing with err.
+type fakePinger struct {
+	err error
+}
+
+func (p fakePinger) HealthCheck() error { return p.err }
+
+// fakeSQS has the queues named.
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	queues map[string]bool
+}
+
+func (s *fakeSQS) GetQueueUrl(input *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	if !s.queues[*input.QueueName] {
+		return nil, errors.New("AWS.SimpleQueueService.NonExistentQueue")
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs/" + *input.QueueName)}, nil
+}
+
+func (s *fakeSQS) GetQueueAttributes(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{}, nil
+}
+
+// fakeS3 fails writes with err, recording the keys written.
+type fakeS3 struct {
+	s3iface.S3API
+	err     error
+	written []string
+}
+
+func (s *fakeS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	s.written = append(s.written, *input.Bucket+"/"+*input.Key)
+	return &s3.PutObjectOutput{}, s.err
+}
+
+func testDependencies() *Dependencies {
+	return &Dependencies{
+		MetaReader:         &fakeReader{queuedSince: time.Now()},
+		AceBackend:         &fakeAce{},
+		WorkersRunning:     func() bool { return true },
+		MigratorLastActive: time.Now,
+		MaxQueueAge:        time.Hour,
+		MaxMigratorIdle:    time.Hour,
+		Blueprint:          fakePinger{},
+		SQS:                &fakeSQS{queues: map[string]bool{"spade-compacter": true}},
+		SQSQueue:           "spade-compacter",
+		S3:                 &fakeS3{},
+		ManifestBucket:     "manifests",
+	}
+}
+
+func deepReport(t *testing.T, deps *Dependencies) (int, Report) {
+	w := httptest.NewRecorder()
+	NewHealthHandler(deps).Deep(web.C{}, w, httptest.NewRequest("GET", "/health/deep", nil))
+	var report Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestDeep(t *testing.T) {
+	deps := testDependencies()
+	code, report := deepReport(t, deps)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StateOK, report.State)
+	for _, name := range []string{"ingesterdb", "redshift", "workers", "queue_lag", "migrator", "blueprint",
+		"sqs", "manifest_bucket"} {
+		assert.Equal(t, StateOK, report.Checks[name].State, name)
+	}
+	assert.NotContains(t, report.Checks, "migrator_progress", "the check is only run if configured")
+	assert.Equal(t, []string{"manifests/" + healthCheckKey}, deps.S3.(*fakeS3).written)
+}
+
+func TestDeepDegraded(t *testing.T) {
+	deps := testDependencies()
+	deps.Blueprint = fakePinger{errors.New("connection refused")}
+	deps.SQSQueue = "missing"
+	deps.MetaReader = &fakeReader{delay: 20 * time.Millisecond, queuedSince: time.Now()}
+	deps.MaxIngesterDBLatency = time.Millisecond
+	code, report := deepReport(t, deps)
+	assert.Equal(t, http.StatusOK, code, "degraded dependencies don't stop loads")
+	assert.Equal(t, StateDegraded, report.State)
+	assert.Equal(t, StateDegraded, report.Checks["blueprint"].State)
+	assert.Equal(t, "connection refused", report.Checks["blueprint"].Error)
+	assert.Equal(t, StateDegraded, report.Checks["sqs"].State)
+	assert.Contains(t, report.Checks["sqs"].Error, "missing")
+	assert.Equal(t, StateDegraded, report.Checks["ingesterdb"].State, "a slow ingesterdb is degraded")
+	assert.True(t, report.Checks["ingesterdb"].LatencyMs >= 20)
+}
+
+func TestDeepCritical(t *testing.T) {
+	deps := testDependencies()
+	deps.Blueprint = fakePinger{errors.New("connection refused")}
+	deps.S3 = &fakeS3{err: errors.New("AccessDenied")}
+	deps.MetaReader = &fakeReader{queuedSince: time.Now().Add(-2 * time.Hour)}
+	code, report := deepReport(t, deps)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StateCritical, report.State, "the worst check's state is the ingester's")
+	assert.Equal(t, StateDegraded, report.Checks["blueprint"].State)
+	assert.Equal(t, StateCritical, report.Checks["manifest_bucket"].State)
+	assert.Equal(t, StateCritical, report.Checks["queue_lag"].State)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := check{name: "slow", state: StateCritical, fn: func() error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+	result := c.run(10 * time.Millisecond)
+	assert.Equal(t, StateCritical, result.State)
+	assert.Equal(t, "timed out after 10ms", result.Error)
+}
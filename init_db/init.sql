@@ -15,7 +15,52 @@ CREATE TABLE IF NOT EXISTS tsv (
     keyname         VARCHAR,                        -- the s3 key of the TSV
     tableversion    INT,                            -- the schema version for the table batch
     ts              TIMESTAMP,                      -- the time the SQS message was recieved
-    manifest_uuid   UUID REFERENCES manifest(uuid)  -- if present, this TSV is in a manifest
+    manifest_uuid   UUID REFERENCES manifest(uuid), -- if present, this TSV is in a manifest
+    format          VARCHAR NOT NULL DEFAULT 'tsv', -- the format of the file: tsv or json
+    compression     VARCHAR NOT NULL DEFAULT 'gzip', -- the file's compression: gzip, bzip2, zstd or none
+    backfill        BOOLEAN NOT NULL DEFAULT FALSE, -- queued by a backfill, so loaded after other files
+    row_count       BIGINT,                         -- rows in the file, if the processor advertised them
+    trace_parent    VARCHAR                         -- W3C traceparent of the span that received the file, if traced
+);
+
+-- Added after the tsv table was first created
+ALTER TABLE tsv ADD COLUMN IF NOT EXISTS format VARCHAR NOT NULL DEFAULT 'tsv';
+ALTER TABLE tsv ADD COLUMN IF NOT EXISTS backfill BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE tsv ADD COLUMN IF NOT EXISTS row_count BIGINT;
+ALTER TABLE tsv ADD COLUMN IF NOT EXISTS compression VARCHAR NOT NULL DEFAULT 'gzip';
+ALTER TABLE tsv ADD COLUMN IF NOT EXISTS trace_parent VARCHAR;
+
+-- S3 keys of every TSV queued, so redelivered SQS messages aren't queued twice
+CREATE TABLE IF NOT EXISTS tsv_seen (
+    keyname         VARCHAR PRIMARY KEY,            -- the s3 key of the TSV
+    ts              TIMESTAMP                       -- when the key was first queued
+);
+CREATE INDEX IF NOT EXISTS tsv_seen_ts ON tsv_seen (ts);
+
+-- S3 keys of the files of paused, quarantined and dropped events sent to the dead-letter queue instead of queued,
+-- and of straggling files dead-lettered by their table's straggler_rule
+CREATE TABLE IF NOT EXISTS tsv_diverted (
+    keyname         VARCHAR PRIMARY KEY,            -- the s3 key of the TSV
+    tablename       VARCHAR NOT NULL,               -- the table of the TSV
+    load_status     VARCHAR NOT NULL,               -- the event's blueprint load status: paused or quarantined, or dropped or straggler
+    ts              TIMESTAMP NOT NULL              -- when the file was diverted
+);
+CREATE INDEX IF NOT EXISTS tsv_diverted_ts ON tsv_diverted (ts);
+
+-- Tables torn down by the migrator after their event was dropped; their files aren't loaded
+CREATE TABLE IF NOT EXISTS dropped_table (
+    tablename       VARCHAR PRIMARY KEY,            -- the dropped table
+    version         INT NOT NULL,                   -- the version that dropped the event
+    snapshot        VARCHAR,                        -- the s3 prefix the table's rows were unloaded to, if any
+    dropped         TIMESTAMP NOT NULL              -- when the table was dropped
+);
+
+-- Tables renamed after their event was renamed; files queued under the old name are moved to the new one
+CREATE TABLE IF NOT EXISTS renamed_table (
+    tablename       VARCHAR PRIMARY KEY,            -- the table's old name
+    new_name        VARCHAR NOT NULL,               -- the table's new name
+    version         INT NOT NULL,                   -- the version of the new name the table was renamed at
+    renamed         TIMESTAMP NOT NULL              -- when the table was renamed
 );
 
 -- Requested/executed force loads
@@ -24,10 +69,237 @@ CREATE TABLE IF NOT EXISTS force_load (
     tablename       VARCHAR,                        -- the table name we're loading into
     ts              TIMESTAMP,                      -- the time the force load was requested
     requester       VARCHAR,                        -- username who requested the force load
-    started         TIMESTAMP                       -- time when the force load was started (null if unstarted)
+    started         TIMESTAMP,                      -- time when the force load was started (null if unstarted)
+    merged          INT NOT NULL DEFAULT 0,         -- later requests for the table merged into this one before it started
+    last_requested  TIMESTAMP                       -- when the last of the merged requests was made
 );
 
+-- Added after the force_load table was first created
+ALTER TABLE force_load ADD COLUMN IF NOT EXISTS merged INT NOT NULL DEFAULT 0;
+ALTER TABLE force_load ADD COLUMN IF NOT EXISTS last_requested TIMESTAMP;
+-- A table has at most one pending force load, which later requests are merged into; redundant pending
+-- requests from before are merged into their table's oldest first
+UPDATE force_load SET merged = merged + dup.n, last_requested = dup.latest
+    FROM (SELECT MIN(id) AS id, COUNT(*) - 1 AS n, MAX(ts) AS latest FROM force_load
+          WHERE started IS NULL GROUP BY tablename HAVING COUNT(*) > 1) dup
+    WHERE force_load.id = dup.id;
+DELETE FROM force_load USING force_load oldest
+    WHERE force_load.started IS NULL AND oldest.started IS NULL
+    AND oldest.tablename = force_load.tablename AND oldest.id < force_load.id;
+CREATE UNIQUE INDEX IF NOT EXISTS force_load_pending ON force_load (tablename) WHERE started IS NULL;
+
 CREATE TABLE IF NOT EXISTS last_load (
     tablename VARCHAR PRIMARY KEY,  -- the logs table we are tracking last loaded time on
     last_loaded TIMESTAMP           -- the last loaded time for that table in UTC
 );
+
+-- Per-table overrides of the global load triggers
+CREATE TABLE IF NOT EXISTS load_trigger (
+    tablename           VARCHAR PRIMARY KEY,    -- the table whose triggers are overridden
+    count_trigger       INT,                    -- queued tsvs before a load; NULL for global default, 0 to load on age alone
+    age_trigger_seconds INT,                    -- max age of queued tsvs before a load; NULL for global default
+    delay_seconds       INT                     -- how long tsvs wait after they're queued before they're loaded; NULL for none
+);
+
+-- Added after the load_trigger table was first created
+ALTER TABLE load_trigger ADD COLUMN IF NOT EXISTS delay_seconds INT;
+
+-- Per-table overrides of COPY options
+CREATE TABLE IF NOT EXISTS copy_settings (
+    tablename       VARCHAR PRIMARY KEY,    -- the table whose COPYs are tuned
+    compupdate      VARCHAR,                -- COMPUPDATE option: on, off or preset; NULL for the default (on)
+    statupdate      VARCHAR,                -- STATUPDATE option: on or off; NULL for redshift's default
+    statement_timeout_ms INT,               -- statement_timeout of the COPYs; NULL for the loader's default
+    dedup_key       VARCHAR                 -- comma-separated primary key columns loads are deduplicated on; NULL to load all rows
+);
+
+-- Added after the copy_settings table was first created
+ALTER TABLE copy_settings ADD COLUMN IF NOT EXISTS statement_timeout_ms INT;
+ALTER TABLE copy_settings ADD COLUMN IF NOT EXISTS dedup_key VARCHAR;
+
+-- Per-table sampling of the files the metadatastorer queues
+CREATE TABLE IF NOT EXISTS sampling_rule (
+    tablename       VARCHAR PRIMARY KEY,    -- the table whose files are sampled
+    keep_percent    INT NOT NULL,           -- percent of the table's files queued, 0-100; the rest are dropped
+    reason          VARCHAR,                -- why the table is sampled
+    requester       VARCHAR,                -- who set the rule
+    updated         TIMESTAMP NOT NULL      -- when the rule was set, in UTC
+);
+
+-- Per-table handling of files of a version older than their table's, queued after it was migrated;
+-- without a rule they stay queued
+CREATE TABLE IF NOT EXISTS straggler_rule (
+    tablename       VARCHAR PRIMARY KEY,    -- the table whose straggling files are handled
+    policy          VARCHAR NOT NULL,       -- load, dead_letter or stragglers
+    reason          VARCHAR,                -- why the table has the rule
+    requester       VARCHAR,                -- who set the rule
+    updated         TIMESTAMP NOT NULL      -- when the rule was set, in UTC
+);
+
+-- Per-table freshness SLOs, evaluated by the loader
+CREATE TABLE IF NOT EXISTS freshness_slo (
+    tablename       VARCHAR PRIMARY KEY,    -- the table whose freshness is tracked
+    max_age_seconds INT NOT NULL,           -- how long the table's files may wait to be loaded
+    reason          VARCHAR,                -- why the table has the SLO
+    requester       VARCHAR,                -- who set the SLO
+    updated         TIMESTAMP NOT NULL      -- when the SLO was set, in UTC
+);
+
+-- Per-table force load schedules, run by the loader
+CREATE TABLE IF NOT EXISTS force_load_schedule (
+    tablename       VARCHAR PRIMARY KEY,    -- the table force loaded
+    schedule        VARCHAR NOT NULL,       -- when to force load it, as a cron expression in UTC
+    reason          VARCHAR,                -- why the table is force loaded on a schedule
+    requester       VARCHAR,                -- who set the schedule
+    updated         TIMESTAMP NOT NULL      -- when the schedule was set, in UTC
+);
+
+-- Tables whose loads are paused through the control API
+CREATE TABLE IF NOT EXISTS paused_table (
+    tablename       VARCHAR PRIMARY KEY,    -- the table not loaded while paused
+    reason          VARCHAR,                -- why the table is paused
+    requester       VARCHAR,                -- who paused it
+    paused          TIMESTAMP NOT NULL      -- when it was paused, in UTC
+);
+
+-- Versions of tables whose files load into <table>_v<version> until the table is migrated to them
+CREATE TABLE IF NOT EXISTS versioned_table (
+    tablename       VARCHAR NOT NULL,       -- the table being migrated
+    tableversion    INT NOT NULL,           -- the version loaded into <tablename>_v<tableversion>
+    created         TIMESTAMP NOT NULL,     -- when the versioned table was created, in UTC
+    PRIMARY KEY (tablename, tableversion)
+);
+
+-- Operator notes on tables and loads
+CREATE TABLE IF NOT EXISTS annotation (
+    id              BIGSERIAL PRIMARY KEY,  -- a unique ID for this annotation
+    tablename       VARCHAR,                -- the table the annotation is about
+    manifest_uuid   UUID,                   -- if present, the load the annotation is about
+    note            VARCHAR,                -- free-text note
+    author          VARCHAR,                -- who wrote the note
+    ts              TIMESTAMP               -- when the note was written
+);
+
+-- Finished loads, for capacity planning
+CREATE TABLE IF NOT EXISTS load_history (
+    uuid            UUID PRIMARY KEY,   -- uuid of the load's manifest
+    tablename       VARCHAR,            -- the table loaded into
+    files           INT,                -- number of files in the manifest
+    rows_loaded     BIGINT,             -- rows loaded, per pg_last_copy_count(); NULL if unknown
+    bytes_scanned   BIGINT,             -- bytes read from S3, per STL_S3CLIENT; NULL if unknown
+    loaded_at       TIMESTAMP,          -- when the load was marked done, in UTC
+    simulated       BOOLEAN NOT NULL DEFAULT FALSE, -- whether the COPY was skipped by a -dryRun loader
+    copy_ms         BIGINT              -- how long the load's COPYs took in milliseconds; NULL if unknown
+);
+CREATE INDEX IF NOT EXISTS load_history_loaded_at ON load_history (loaded_at);
+
+-- Added after the load_history table was first created
+ALTER TABLE load_history ADD COLUMN IF NOT EXISTS simulated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE load_history ADD COLUMN IF NOT EXISTS copy_ms BIGINT;
+
+-- Every error of a load, oldest first; manifest.last_error only has the latest
+CREATE TABLE IF NOT EXISTS load_error (
+    id              BIGSERIAL PRIMARY KEY,  -- a unique ID for this error
+    uuid            UUID NOT NULL,          -- uuid of the load's manifest
+    ts              TIMESTAMP NOT NULL,     -- when the load failed, in UTC
+    error           VARCHAR,                -- the load's error
+    tablename       VARCHAR                 -- the table loaded into; NULL for errors recorded before it was added
+);
+CREATE INDEX IF NOT EXISTS load_error_uuid ON load_error (uuid);
+CREATE INDEX IF NOT EXISTS load_error_ts ON load_error (ts);
+ALTER TABLE load_error ADD COLUMN IF NOT EXISTS tablename VARCHAR;
+-- the error's class, e.g. serialization; NULL for errors recorded before it was added, or not classified
+ALTER TABLE load_error ADD COLUMN IF NOT EXISTS class VARCHAR;
+CREATE INDEX IF NOT EXISTS load_error_tablename_ts ON load_error (tablename, ts);
+
+-- Chunks of loads COPYed one manifest per transaction, each committed as it finishes
+CREATE TABLE IF NOT EXISTS load_chunk (
+    id              BIGSERIAL PRIMARY KEY,  -- a unique ID for this chunk
+    uuid            UUID NOT NULL,          -- uuid of the load's manifest
+    tablename       VARCHAR NOT NULL,       -- the table loaded into
+    files           INT NOT NULL,           -- number of files in the chunk
+    rows_loaded     BIGINT,                 -- rows loaded, per pg_last_copy_count()
+    bytes_scanned   BIGINT,                 -- bytes read from S3, per STL_S3CLIENT
+    copy_ms         BIGINT,                 -- how long the chunk's COPY took in milliseconds
+    loaded_at       TIMESTAMP NOT NULL      -- when the chunk was committed, in UTC
+);
+CREATE INDEX IF NOT EXISTS load_chunk_uuid ON load_chunk (uuid);
+
+-- Loads whose rows loaded didn't match the rows expected, e.g. rows silently dropped by the COPY
+CREATE TABLE IF NOT EXISTS load_row_mismatch (
+    uuid            UUID PRIMARY KEY,   -- uuid of the load's manifest
+    tablename       VARCHAR,            -- the table loaded into
+    expected_rows   BIGINT,             -- rows advertised by the processor; NULL if not all files had counts
+    lines_scanned   BIGINT,             -- lines read by the COPY, per STL_LOAD_COMMITS
+    rows_loaded     BIGINT,             -- rows loaded, per pg_last_copy_count()
+    detected_at     TIMESTAMP           -- when the load was marked done, in UTC
+);
+
+-- Maintenance windows, during which no loads or migrations start
+CREATE TABLE IF NOT EXISTS maintenance_window (
+    id              BIGSERIAL PRIMARY KEY,  -- a unique ID for this window
+    starts_at       TIMESTAMP NOT NULL,     -- when the window starts, in UTC
+    ends_at         TIMESTAMP,              -- when the window ends, in UTC; NULL until it's removed
+    reason          VARCHAR,                -- why loads are paused, e.g. a cluster resize
+    requester       VARCHAR                 -- who asked for the window
+);
+
+-- Components of the ingester switched off at runtime, e.g. during a cluster incident
+CREATE TABLE IF NOT EXISTS component_toggle (
+    component       VARCHAR PRIMARY KEY,    -- the component: loader, migrator or metadatastorer
+    disabled        BOOLEAN NOT NULL,       -- whether the component is switched off
+    reason          VARCHAR,                -- why it was switched
+    requester       VARCHAR,                -- who switched it
+    updated         TIMESTAMP NOT NULL      -- when it was switched, in UTC
+);
+
+-- Control requests that changed state, and who made them
+CREATE TABLE IF NOT EXISTS control_audit (
+    id              BIGSERIAL PRIMARY KEY,  -- a unique ID for this entry
+    ts              TIMESTAMP NOT NULL,     -- when the request was handled, in UTC
+    request_id      VARCHAR,                -- the request's ID, as logged and returned in X-Request-Id
+    caller          VARCHAR NOT NULL,       -- who made the request: cert:<name>, hmac:<key>, token or anonymous
+    method          VARCHAR NOT NULL,       -- the request's HTTP method
+    path            VARCHAR NOT NULL,       -- the request's path and query
+    params          VARCHAR,                -- the request's body, truncated
+    status          INT NOT NULL,           -- the response's HTTP status
+    error           VARCHAR                 -- the error response, truncated; NULL on success
+);
+CREATE INDEX IF NOT EXISTS control_audit_ts ON control_audit (ts);
+
+-- Loads' state transitions in the workers, for reconstructing what each worker was doing after a crash
+CREATE TABLE IF NOT EXISTS load_event (
+    id              BIGSERIAL PRIMARY KEY,  -- a unique ID for this event
+    ts              TIMESTAMP NOT NULL,     -- when the load entered the state, in UTC
+    uuid            UUID NOT NULL,          -- the load's manifest uuid
+    tablename       VARCHAR NOT NULL,       -- the table loaded
+    worker          VARCHAR NOT NULL,       -- the worker: <host>/<number in the pool>/<goroutine ID>
+    state           VARCHAR NOT NULL,       -- assigned, admitted, succeeded or failed
+    detail          VARCHAR                 -- the error of a failed load, and whether it's retried
+);
+CREATE INDEX IF NOT EXISTS load_event_uuid ON load_event (uuid);
+CREATE INDEX IF NOT EXISTS load_event_ts ON load_event (ts);
+
+-- Version increments requested through the control API, kept until the migrator acknowledges them
+CREATE TABLE IF NOT EXISTS version_increment (
+    id              UUID PRIMARY KEY,       -- the ID of the request, returned as its job ID
+    tablename       VARCHAR NOT NULL,       -- the table whose version is incremented
+    version         INT NOT NULL,           -- the version the table is set to
+    requested       TIMESTAMP NOT NULL,     -- when the increment was requested, in UTC
+    finished        TIMESTAMP,              -- when the migrator acknowledged it, in UTC; NULL while pending
+    error           VARCHAR                 -- why the increment failed; NULL if it succeeded or is pending
+);
+CREATE INDEX IF NOT EXISTS version_increment_pending ON version_increment (requested) WHERE finished IS NULL;
+
+-- How far backfills of S3 Inventory reports got through each report's data files, to resume from
+CREATE TABLE IF NOT EXISTS inventory_backfill (
+    manifest        VARCHAR NOT NULL,       -- the s3:// URL of the report's manifest.json
+    data_file       VARCHAR NOT NULL,       -- the key of the data file in the report's bucket
+    lines           BIGINT NOT NULL,        -- the data file's lines done, a whole number of chunks unless done
+    done            BOOLEAN NOT NULL,       -- whether all the data file's lines are done
+    queued          BIGINT NOT NULL,        -- files queued from the data file's lines done
+    duplicate       BIGINT NOT NULL,        -- files already queued
+    skipped         BIGINT NOT NULL,        -- lines that aren't processed files of known tables
+    updated         TIMESTAMP NOT NULL,     -- when the progress was last recorded, in UTC
+    PRIMARY KEY (manifest, data_file)
+);
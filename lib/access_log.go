@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/middleware"
+	"github.com/zenazn/goji/web/mutil"
+)
+
+// RequestIDHeader is the response header carrying the request ID logged for the request
+const RequestIDHeader = "X-Request-Id"
+
+// AccessLogger is a middleware logging one structured entry per request, with its request ID,
+// latency and caller. It returns the request ID in the RequestIDHeader, so it must come after
+// middleware.RequestID.
+func AccessLogger(c *web.C, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := middleware.GetReqID(*c)
+		if reqID != "" {
+			w.Header().Set(RequestIDHeader, reqID)
+		}
+		lw := mutil.WrapWriter(w)
+
+		start := time.Now()
+		h.ServeHTTP(lw, r)
+		if lw.Status() == 0 {
+			lw.WriteHeader(http.StatusOK)
+		}
+
+		fields := map[string]interface{}{
+			"request_method": r.Method,
+			"url":            r.URL.String(),
+			"remote_address": r.RemoteAddr,
+			"caller":         Caller(r),
+			"status":         lw.Status(),
+			"bytes":          lw.BytesWritten(),
+			"latency_ms":     float64(time.Since(start)) / float64(time.Millisecond),
+		}
+		if reqID != "" {
+			fields["request_id"] = reqID
+		}
+		logger.WithFields(fields).Info("Handled request")
+	})
+}
+
+// Caller identifies who made the request: the common name of a verified client certificate,
+// the name of the HMAC key it was signed with, "token" for a bearer token, or else "anonymous".
+func Caller(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	authorization := r.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, HMACScheme+" ") {
+		name, _ := hmacCredentials(r)
+		return "hmac:" + name
+	}
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return "token"
+	}
+	return "anonymous"
+}
@@ -0,0 +1,200 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// TokenAuth returns a middleware that rejects requests without an
+// "Authorization: Bearer <token>" header matching the given token.
+func TokenAuth(token string) func(http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := []byte(r.Header.Get("Authorization"))
+			if subtle.ConstantTimeCompare(given, expected) != 1 {
+				logger.WithField("url", r.URL.String()).WithField("remote_address", r.RemoteAddr).
+					Warn("Rejected request with bad authorization token")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HMACScheme is the Authorization scheme of requests signed with an HMAC key, sent as
+// "Authorization: HMAC-SHA256 <key name>:<hex signature>" along with a TimestampHeader.
+const HMACScheme = "HMAC-SHA256"
+
+// TimestampHeader is the header of a signed request's time, in Unix seconds.
+const TimestampHeader = "X-Timestamp"
+
+// maxSignatureSkew is how far a signed request's timestamp may be from now, limiting replays.
+const maxSignatureSkew = 5 * time.Minute
+
+// Sign returns the hex HMAC-SHA256 signature of a request with the key: of its method, its
+// URL's path and query, its timestamp and its body, separated by newlines.
+func Sign(key []byte, method, requestURI, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s\n", method, requestURI, timestamp)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HMACKeysFromEnv reads the HMAC keys from the environment variables they're named by.
+func HMACKeysFromEnv(envs map[string]string) (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(envs))
+	for name, env := range envs {
+		key := os.Getenv(env)
+		if key == "" {
+			return nil, fmt.Errorf("%s, holding HMAC key %s, is empty", env, name)
+		}
+		keys[name] = []byte(key)
+	}
+	return keys, nil
+}
+
+// ControlAuth returns a middleware that rejects requests without either an
+// "Authorization: Bearer <token>" header matching the token, if set, or a signature by one of
+// the named HMAC keys.
+func ControlAuth(token string, hmacKeys map[string][]byte) func(http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization := r.Header.Get("Authorization")
+			var err error
+			switch {
+			case strings.HasPrefix(authorization, HMACScheme+" ") && len(hmacKeys) > 0:
+				err = checkSignature(r, hmacKeys)
+			case token != "" && subtle.ConstantTimeCompare([]byte(authorization), expected) == 1:
+			default:
+				err = fmt.Errorf("bad or missing authorization")
+			}
+			if err != nil {
+				logger.WithError(err).WithField("url", r.URL.String()).WithField("remote_address", r.RemoteAddr).
+					Warn("Rejected unauthenticated request")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkSignature checks the request was signed by one of the keys within maxSignatureSkew. The
+// body is read to check it, and replaced for the handler.
+func checkSignature(r *http.Request, keys map[string][]byte) error {
+	name, signature := hmacCredentials(r)
+	key, ok := keys[name]
+	if !ok {
+		return fmt.Errorf("unknown HMAC key %q", name)
+	}
+	timestamp := r.Header.Get(TimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad %s %q", TimestampHeader, timestamp)
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); skew > maxSignatureSkew || skew < -maxSignatureSkew {
+		return fmt.Errorf("%s is %v off", TimestampHeader, skew)
+	}
+	var body []byte
+	if r.Body != nil {
+		body, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return fmt.Errorf("reading body: %v", err)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	expected := Sign(key, r.Method, r.URL.RequestURI(), timestamp, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return fmt.Errorf("bad signature by HMAC key %q", name)
+	}
+	return nil
+}
+
+// hmacCredentials returns the key name and signature of a signed request's Authorization header.
+func hmacCredentials(r *http.Request) (name, signature string) {
+	credentials := strings.TrimPrefix(r.Header.Get("Authorization"), HMACScheme+" ")
+	if i := strings.LastIndex(credentials, ":"); i >= 0 {
+		return credentials[:i], credentials[i+1:]
+	}
+	return credentials, ""
+}
+
+// RequireClientCert returns a middleware that rejects requests which did not present a
+// client certificate verified against the server's client CAs.
+func RequireClientCert(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			logger.WithField("url", r.URL.String()).WithField("remote_address", r.RemoteAddr).
+				Warn("Rejected request without a verified client certificate")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// TLSConfig configures TLS for the HTTP servers
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// Enabled returns whether a certificate and key were configured.
+func (c *TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// ServerConfig returns the TLS config of a server with the certificate and key. When a client CA is
+// configured, client certificates are verified if given.
+func (c *TLSConfig) ServerConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// ListenAndServe serves h on addr, over TLS if c is enabled. When a client CA is configured,
+// client certificates are verified if given; use RequireClientCert to require them on a route.
+func (c *TLSConfig) ListenAndServe(addr string, h http.Handler) error {
+	if !c.Enabled() {
+		return http.ListenAndServe(addr, h)
+	}
+	tlsConfig, err := c.ServerConfig()
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: h, TLSConfig: tlsConfig}
+	return server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlAuth(t *testing.T) {
+	key := []byte("secret")
+	var handled string
+	h := ControlAuth("token", map[string][]byte{"blueprint": key})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled = Caller(r)
+		}))
+	call := func(authorization, timestamp, body string) int {
+		handled = ""
+		r := httptest.NewRequest("POST", "/control/force_load?x=1", strings.NewReader(body))
+		r.Header.Set("Authorization", authorization)
+		r.Header.Set(TimestampHeader, timestamp)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	body := `{"Table":"t"}`
+	signature := Sign(key, "POST", "/control/force_load?x=1", now, []byte(body))
+	assert.Equal(t, http.StatusOK, call(HMACScheme+" blueprint:"+signature, now, body))
+	assert.Equal(t, "hmac:blueprint", handled)
+
+	assert.Equal(t, http.StatusUnauthorized, call(HMACScheme+" blueprint:"+signature, now, `{"Table":"u"}`),
+		"the body is signed")
+	assert.Equal(t, http.StatusUnauthorized, call(HMACScheme+" other:"+signature, now, body))
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	assert.Equal(t, http.StatusUnauthorized,
+		call(HMACScheme+" blueprint:"+Sign(key, "POST", "/control/force_load?x=1", old, []byte(body)), old, body),
+		"stale signatures are replays")
+
+	assert.Equal(t, http.StatusOK, call("Bearer token", "", body))
+	assert.Equal(t, "token", handled)
+	assert.Equal(t, http.StatusUnauthorized, call("Bearer wrong", "", body))
+	assert.Equal(t, http.StatusUnauthorized, call("", "", body))
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// holidayLayout is the layout of holidays' dates.
+const holidayLayout = "2006-01-02"
+
+// OffpeakWindow is a day's offpeak hours: DurationHours hours from StartHour, in UTC. A window may run
+// past midnight into the next day.
+type OffpeakWindow struct {
+	StartHour     int `json:"startHour"`
+	DurationHours int `json:"durationHours"`
+}
+
+// Validate returns an error if the window's hours are out of range.
+func (w OffpeakWindow) Validate() error {
+	switch {
+	case w.StartHour < 0 || w.StartHour > 23:
+		return fmt.Errorf("startHour must be between 0 and 23")
+	case w.DurationHours < 0 || w.DurationHours > 24:
+		return fmt.Errorf("durationHours must be between 0 and 24")
+	}
+	return nil
+}
+
+// contains returns whether the hour of the window's day is within it, or, with nextDay set, whether
+// the hour of the next day is.
+func (w OffpeakWindow) contains(hour int, nextDay bool) bool {
+	if nextDay {
+		return hour < w.StartHour+w.DurationHours-24
+	}
+	return w.StartHour <= hour && hour < w.StartHour+w.DurationHours
+}
+
+// OffpeakSchedule is when it's offpeak, so slow table changes run: each day's window is Default, unless
+// its day of the week has its own in Weekdays, and Holidays are offpeak all day.
+type OffpeakSchedule struct {
+	Default OffpeakWindow `json:"default"`
+	// Weekdays are the windows of days of the week, by their lowercase English names, e.g. "saturday"
+	Weekdays map[string]OffpeakWindow `json:"weekdays,omitempty"`
+	// Holidays are the dates, as YYYY-MM-DD in UTC, that are offpeak all day
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// Validate returns an error if any window is out of range, a weekday isn't one, or a holiday isn't a date.
+func (s OffpeakSchedule) Validate() error {
+	if err := s.Default.Validate(); err != nil {
+		return err
+	}
+	for day, window := range s.Weekdays {
+		if !isWeekday(day) {
+			return fmt.Errorf("%q isn't a day of the week", day)
+		}
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("%s: %v", day, err)
+		}
+	}
+	for _, holiday := range s.Holidays {
+		if _, err := time.Parse(holidayLayout, holiday); err != nil {
+			return fmt.Errorf("holiday %q isn't a YYYY-MM-DD date", holiday)
+		}
+	}
+	return nil
+}
+
+// Window returns the offpeak window of the day of the week.
+func (s OffpeakSchedule) Window(day time.Weekday) OffpeakWindow {
+	if window, ok := s.Weekdays[strings.ToLower(day.String())]; ok {
+		return window
+	}
+	return s.Default
+}
+
+// IsHoliday returns whether the day of t, in UTC, is a holiday.
+func (s OffpeakSchedule) IsHoliday(t time.Time) bool {
+	date := t.UTC().Format(holidayLayout)
+	for _, holiday := range s.Holidays {
+		if holiday == date {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns whether t is offpeak: on a holiday, or within its day's window or the previous day's,
+// if that runs past midnight.
+func (s OffpeakSchedule) Contains(t time.Time) bool {
+	t = t.UTC()
+	if s.IsHoliday(t) {
+		return true
+	}
+	return s.Window(t.Weekday()).contains(t.Hour(), false) ||
+		s.Window((t.Weekday()+6)%7).contains(t.Hour(), true)
+}
+
+// isWeekday returns whether name is the lowercase English name of a day of the week.
+func isWeekday(name string) bool {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.ToLower(day.String()) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffpeakScheduleContains(t *testing.T) {
+	schedule := OffpeakSchedule{
+		Default: OffpeakWindow{StartHour: 22, DurationHours: 4},
+		Weekdays: map[string]OffpeakWindow{
+			"saturday": {StartHour: 10, DurationHours: 12},
+			"sunday":   {StartHour: 0, DurationHours: 0},
+		},
+		Holidays: []string{"2017-12-25"},
+	}
+	// 2017-03-15 is a Wednesday
+	at := func(day, hour int) time.Time { return time.Date(2017, 3, day, hour, 30, 0, 0, time.UTC) }
+	assert.False(t, schedule.Contains(at(15, 21)))
+	assert.True(t, schedule.Contains(at(15, 22)))
+	assert.True(t, schedule.Contains(at(16, 1)), "the window runs past midnight")
+	assert.False(t, schedule.Contains(at(16, 2)))
+
+	assert.True(t, schedule.Contains(at(17, 23)), "Friday's window runs into Saturday")
+	assert.True(t, schedule.Contains(at(18, 1)))
+	assert.False(t, schedule.Contains(at(18, 2)))
+	assert.True(t, schedule.Contains(at(18, 12)), "Saturday has its own window")
+	assert.False(t, schedule.Contains(at(18, 23)))
+	assert.False(t, schedule.Contains(at(19, 23)), "Sunday has no window")
+
+	assert.True(t, schedule.Contains(time.Date(2017, 12, 25, 15, 0, 0, 0, time.UTC)), "holidays are offpeak all day")
+	assert.True(t, schedule.Contains(time.Date(2017, 12, 25, 10, 0, 0, 0, time.FixedZone("PST", -8*3600))),
+		"holidays are in UTC")
+}
+
+func TestOffpeakScheduleValidate(t *testing.T) {
+	schedule := OffpeakSchedule{
+		Default:  OffpeakWindow{StartHour: 3, DurationHours: 8},
+		Weekdays: map[string]OffpeakWindow{"sunday": {StartHour: 0, DurationHours: 24}},
+		Holidays: []string{"2017-12-25"},
+	}
+	assert.NoError(t, schedule.Validate())
+
+	invalid := schedule
+	invalid.Weekdays = map[string]OffpeakWindow{"Sunday": {}}
+	assert.EqualError(t, invalid.Validate(), `"Sunday" isn't a day of the week`)
+	invalid = schedule
+	invalid.Weekdays = map[string]OffpeakWindow{"sunday": {StartHour: 24}}
+	assert.EqualError(t, invalid.Validate(), "sunday: startHour must be between 0 and 23")
+	invalid = schedule
+	invalid.Holidays = []string{"12/25/2017"}
+	assert.Error(t, invalid.Validate())
+}
@@ -0,0 +1,338 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cactus/go-statsd-client/statsd"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+// Stats backends InitStats can send to.
+const (
+	// StatsdBackend is plain statsd, which has no tags; per-table stats have the table in their name.
+	StatsdBackend = "statsd"
+	// DogStatsdBackend is statsd with DogStatsD-style tags; per-table stats are tagged with the table.
+	DogStatsdBackend = "dogstatsd"
+)
+
+// MetricKind is the type of a metric.
+type MetricKind string
+
+// Metric kinds, by their statsd type.
+const (
+	Count  MetricKind = "c"
+	Gauge  MetricKind = "g"
+	Timing MetricKind = "ms"
+)
+
+// Metric is a value sent to an Emitter.
+type Metric struct {
+	Kind MetricKind
+	// Stat is the metric's prefixed name
+	Stat string
+	// Value is the count, the gauge, or the timing in milliseconds
+	Value float64
+	// Tags are "key:value" tags, including the global ones
+	Tags []string
+}
+
+// Emitter sends metrics to a backend. Implement it to plug in a backend other than statsd.
+type Emitter interface {
+	Emit(metric Metric) error
+	Close() error
+}
+
+// Statter is a monitoring.SafeStatter that can be closed to flush it.
+type Statter interface {
+	monitoring.SafeStatter
+	Close() error
+}
+
+// TaggedStatter is a Statter that also sends tagged metrics.
+type TaggedStatter interface {
+	Statter
+	IncTagged(stat string, value int64, tags ...string)
+	GaugeTagged(stat string, value int64, tags ...string)
+	TimingTagged(stat string, delta time.Duration, tags ...string)
+}
+
+// StatsNaming is how InitStats namespaces stats by where they come from.
+type StatsNaming struct {
+	// Environment and Cluster, e.g. production and science, namespace plain statsd stats as
+	// <prefix>.<environment>.<cluster>.<stat>, and tag the stats of backends with tags with
+	// environment:<environment> and cluster:<cluster>. Empty ones are left out.
+	Environment string
+	Cluster     string
+	// Legacy also sends plain statsd stats under their names from before they were namespaced and had
+	// their tables sanitized, for dashboards and alerts yet to move to the new names
+	Legacy bool
+}
+
+// tags returns the naming's environment and cluster tags.
+func (n StatsNaming) tags() []string {
+	var tags []string
+	if n.Environment != "" {
+		tags = append(tags, "environment:"+n.Environment)
+	}
+	if n.Cluster != "" {
+		tags = append(tags, "cluster:"+n.Cluster)
+	}
+	return tags
+}
+
+// namespace returns the namespace plain statsd stats are sent under, e.g. "production.science.".
+func (n StatsNaming) namespace() string {
+	var namespace string
+	for _, part := range []string{n.Environment, n.Cluster} {
+		if part != "" {
+			namespace += SanitizeMetricName(part) + "."
+		}
+	}
+	return namespace
+}
+
+// InitStats returns a statter for the backend, sending to addr with stats prefixed by prefix and
+// namespaced by naming. The global "key:value" tags, e.g. the cluster and environment, are added to
+// every stat of backends with tags.
+func InitStats(backend, addr, prefix string, globalTags []string, naming StatsNaming) (Statter, error) {
+	switch backend {
+	case StatsdBackend, "":
+		stats, err := monitoring.NewStatter(addr, prefix)
+		if err != nil {
+			return nil, err
+		}
+		return &statsdStatter{LoggingStatter: stats, namespace: naming.namespace(), legacy: naming.Legacy}, nil
+	case DogStatsdBackend:
+		emitter, err := NewDogStatsdEmitter(addr)
+		if err != nil {
+			return nil, err
+		}
+		return NewTaggedStatter(emitter, prefix, append(globalTags, naming.tags()...)), nil
+	default:
+		return nil, fmt.Errorf("unknown stats backend %q", backend)
+	}
+}
+
+// SanitizeMetricName returns name as one part of a dot-separated stat name: its characters other
+// than letters, digits and underscores, e.g. the dots and dashes of some table names, are replaced
+// by underscores.
+func SanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// ParseTags splits comma separated "key:value" tags.
+func ParseTags(tags string) []string {
+	var parsed []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			parsed = append(parsed, tag)
+		}
+	}
+	return parsed
+}
+
+type taggedStatter struct {
+	emitter    Emitter
+	prefix     string
+	globalTags []string
+}
+
+// NewTaggedStatter returns a TaggedStatter sending to the emitter. Untagged stats get only the
+// global tags.
+func NewTaggedStatter(emitter Emitter, prefix string, globalTags []string) TaggedStatter {
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &taggedStatter{emitter: emitter, prefix: prefix, globalTags: globalTags}
+}
+
+func (s *taggedStatter) emit(kind MetricKind, stat string, value float64, tags []string) {
+	allTags := make([]string, 0, len(s.globalTags)+len(tags))
+	allTags = append(append(allTags, s.globalTags...), tags...)
+	err := s.emitter.Emit(Metric{Kind: kind, Stat: s.prefix + stat, Value: value, Tags: allTags})
+	if err != nil {
+		logger.WithError(err).Errorf("Error sending stat %s", stat)
+	}
+}
+
+func (s *taggedStatter) SafeInc(stat string, value int64, rate float32) {
+	s.emit(Count, stat, float64(value), nil)
+}
+
+func (s *taggedStatter) SafeGauge(stat string, value int64, rate float32) {
+	s.emit(Gauge, stat, float64(value), nil)
+}
+
+func (s *taggedStatter) SafeTimingDuration(stat string, delta time.Duration, rate float32) {
+	s.emit(Timing, stat, milliseconds(delta), nil)
+}
+
+func (s *taggedStatter) IncTagged(stat string, value int64, tags ...string) {
+	s.emit(Count, stat, float64(value), tags)
+}
+
+func (s *taggedStatter) GaugeTagged(stat string, value int64, tags ...string) {
+	s.emit(Gauge, stat, float64(value), tags)
+}
+
+func (s *taggedStatter) TimingTagged(stat string, delta time.Duration, tags ...string) {
+	s.emit(Timing, stat, milliseconds(delta), tags)
+}
+
+func (s *taggedStatter) Close() error {
+	return s.emitter.Close()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// statsdStatter is a plain statsd statter that namespaces its stats, and can also send them under
+// their legacy names.
+type statsdStatter struct {
+	*monitoring.LoggingStatter
+	namespace string
+	legacy    bool
+}
+
+// send sends a stat with fn under its namespaced name, and under legacyStat too if the statter sends
+// legacy names and it differs.
+func (s *statsdStatter) send(stat, legacyStat string, fn func(stats monitoring.SafeStatter, stat string)) {
+	fn(s.LoggingStatter, s.namespace+stat)
+	if s.legacy && s.namespace+stat != legacyStat {
+		fn(s.LoggingStatter, legacyStat)
+	}
+}
+
+func (s *statsdStatter) SafeInc(stat string, value int64, rate float32) {
+	s.send(stat, stat, func(stats monitoring.SafeStatter, stat string) { stats.SafeInc(stat, value, rate) })
+}
+
+func (s *statsdStatter) SafeGauge(stat string, value int64, rate float32) {
+	s.send(stat, stat, func(stats monitoring.SafeStatter, stat string) { stats.SafeGauge(stat, value, rate) })
+}
+
+func (s *statsdStatter) SafeTimingDuration(stat string, delta time.Duration, rate float32) {
+	s.send(stat, stat, func(stats monitoring.SafeStatter, stat string) {
+		stats.SafeTimingDuration(stat, delta, rate)
+	})
+}
+
+type dogStatsdEmitter struct {
+	sender statsd.Sender
+}
+
+// NewDogStatsdEmitter returns an Emitter sending to a DogStatsD agent at addr over UDP.
+func NewDogStatsdEmitter(addr string) (Emitter, error) {
+	sender, err := statsd.NewSimpleSender(addr)
+	if err != nil {
+		return nil, fmt.Errorf("creating dogstatsd sender: %v", err)
+	}
+	return &dogStatsdEmitter{sender: sender}, nil
+}
+
+// Emit sends the metric as "stat:value|kind|#tag,tag".
+func (e *dogStatsdEmitter) Emit(metric Metric) error {
+	_, err := e.sender.Send([]byte(formatDogStatsd(metric)))
+	return err
+}
+
+func (e *dogStatsdEmitter) Close() error {
+	return e.sender.Close()
+}
+
+func formatDogStatsd(metric Metric) string {
+	line := fmt.Sprintf("%s:%s|%s", metric.Stat, strconv.FormatFloat(metric.Value, 'f', -1, 64), metric.Kind)
+	if len(metric.Tags) > 0 {
+		line += "|#" + strings.Join(metric.Tags, ",")
+	}
+	return line
+}
+
+// TableTag tags a stat with the table it's about.
+func TableTag(table string) string {
+	return "table:" + table
+}
+
+// tableStat is the name of a per-table stat for statters without tags: the table follows the
+// stat's first part, e.g. tsv_files.<table>.queued for tsv_files.queued.
+func tableStat(stat, table string) string {
+	parts := strings.SplitN(stat, ".", 2)
+	if len(parts) == 1 {
+		return stat + "." + table
+	}
+	return parts[0] + "." + table + "." + parts[1]
+}
+
+// sendTableStat sends a per-table stat of a statter without tags with fn, under its name with the
+// table sanitized, plus suffix, a sanitized part following it. Statters sending legacy names also
+// send it with the table and suffix as they are.
+func sendTableStat(stats monitoring.SafeStatter, stat, table, suffix string,
+	fn func(stats monitoring.SafeStatter, stat string)) {
+	name := tableStat(stat, SanitizeMetricName(table))
+	legacyName := tableStat(stat, table)
+	if suffix != "" {
+		name += "." + SanitizeMetricName(suffix)
+		legacyName += "." + suffix
+	}
+	if s, ok := stats.(*statsdStatter); ok {
+		s.send(name, legacyName, fn)
+		return
+	}
+	fn(stats, name)
+}
+
+// TableInc counts a per-table stat, tagged with the table if the statter has tags.
+func TableInc(stats monitoring.SafeStatter, stat, table string, value int64) {
+	if tagged, ok := stats.(TaggedStatter); ok {
+		tagged.IncTagged(stat, value, TableTag(table))
+		return
+	}
+	sendTableStat(stats, stat, table, "", func(stats monitoring.SafeStatter, stat string) {
+		stats.SafeInc(stat, value, 1.0)
+	})
+}
+
+// TableGauge sets a per-table gauge, tagged with the table if the statter has tags.
+func TableGauge(stats monitoring.SafeStatter, stat, table string, value int64) {
+	if tagged, ok := stats.(TaggedStatter); ok {
+		tagged.GaugeTagged(stat, value, TableTag(table))
+		return
+	}
+	sendTableStat(stats, stat, table, "", func(stats monitoring.SafeStatter, stat string) {
+		stats.SafeGauge(stat, value, 1.0)
+	})
+}
+
+// TableTiming times a per-table stat, tagged with the table if the statter has tags.
+func TableTiming(stats monitoring.SafeStatter, stat, table string, delta time.Duration) {
+	if tagged, ok := stats.(TaggedStatter); ok {
+		tagged.TimingTagged(stat, delta, TableTag(table))
+		return
+	}
+	sendTableStat(stats, stat, table, "", func(stats monitoring.SafeStatter, stat string) {
+		stats.SafeTimingDuration(stat, delta, 1.0)
+	})
+}
+
+// TableLabeledGauge sets a per-table gauge of one value of a label, e.g. a state, tagged with the table
+// and "label:value" if the statter has tags, and otherwise with the value following the stat, e.g.
+// migrator.<table>.wait_state.locked for migrator.wait_state.
+func TableLabeledGauge(stats monitoring.SafeStatter, stat, table, label, value string, gauge int64) {
+	if tagged, ok := stats.(TaggedStatter); ok {
+		tagged.GaugeTagged(stat, gauge, TableTag(table), label+":"+value)
+		return
+	}
+	sendTableStat(stats, stat, table, value, func(stats monitoring.SafeStatter, stat string) {
+		stats.SafeGauge(stat, gauge, 1.0)
+	})
+}
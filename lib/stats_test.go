@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cactus/go-statsd-client/statsd"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+type recordingEmitter struct {
+	metrics []Metric
+}
+
+func (e *recordingEmitter) Emit(metric Metric) error {
+	e.metrics = append(e.metrics, metric)
+	return nil
+}
+
+func (e *recordingEmitter) Close() error {
+	return nil
+}
+
+type recordingSender struct {
+	sent []string
+}
+
+func (s *recordingSender) Send(data []byte) (int, error) {
+	s.sent = append(s.sent, string(data))
+	return len(data), nil
+}
+
+func (s *recordingSender) Close() error {
+	return nil
+}
+
+func TestTableStat(t *testing.T) {
+	assert.Equal(t, "tsv_files.events.loaded", tableStat("tsv_files.loaded", "events"))
+	assert.Equal(t, "manifest_load.events.stage.copy", tableStat("manifest_load.stage.copy", "events"))
+	assert.Equal(t, "force_load.events", tableStat("force_load", "events"))
+}
+
+func TestTaggedStatter(t *testing.T) {
+	emitter := &recordingEmitter{}
+	stats := NewTaggedStatter(emitter, "ingester", []string{"cluster:science"})
+	TableInc(stats, "tsv_files.loaded", "events", 3)
+	stats.SafeGauge("tsv_files.total.loaded", 5, 1.0)
+	TableTiming(stats, "manifest_load.stage.copy", "events", 1500*time.Microsecond)
+
+	assert.Equal(t, []Metric{
+		{Kind: Count, Stat: "ingester.tsv_files.loaded", Value: 3, Tags: []string{"cluster:science", "table:events"}},
+		{Kind: Gauge, Stat: "ingester.tsv_files.total.loaded", Value: 5, Tags: []string{"cluster:science"}},
+		{Kind: Timing, Stat: "ingester.manifest_load.stage.copy", Value: 1.5, Tags: []string{"cluster:science", "table:events"}},
+	}, emitter.metrics)
+	assert.Equal(t, "ingester.tsv_files.loaded:3|c|#cluster:science,table:events", formatDogStatsd(emitter.metrics[0]))
+	assert.Equal(t, "x:1.5|ms", formatDogStatsd(Metric{Kind: Timing, Stat: "x", Value: 1.5}))
+}
+
+func TestTableLabeledGauge(t *testing.T) {
+	emitter := &recordingEmitter{}
+	stats := NewTaggedStatter(emitter, "ingester", nil)
+	TableLabeledGauge(stats, "migrator.wait_state", "events", "state", "locked", 1)
+	assert.Equal(t, []Metric{
+		{Kind: Gauge, Stat: "ingester.migrator.wait_state", Value: 1, Tags: []string{"table:events", "state:locked"}},
+	}, emitter.metrics)
+}
+
+func TestParseTags(t *testing.T) {
+	assert.Equal(t, []string{"cluster:science", "environment:production"}, ParseTags(" cluster:science, environment:production,"))
+	assert.Nil(t, ParseTags(""))
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	assert.Equal(t, "events", SanitizeMetricName("events"))
+	assert.Equal(t, "client_events_v2_beta", SanitizeMetricName("client-events.v2 beta"))
+}
+
+func TestNamespacedStatter(t *testing.T) {
+	sender := &recordingSender{}
+	client, err := statsd.NewClientWithSender(sender, "ingester")
+	assert.NoError(t, err)
+	naming := StatsNaming{Environment: "production", Cluster: "science"}
+	assert.Equal(t, []string{"environment:production", "cluster:science"}, naming.tags())
+	stats := &statsdStatter{LoggingStatter: &monitoring.LoggingStatter{Statter: client}, namespace: naming.namespace()}
+
+	TableInc(stats, "tsv_files.loaded", "client-events", 3)
+	assert.Equal(t, []string{"ingester.production.science.tsv_files.client_events.loaded:3|c"}, sender.sent)
+
+	sender.sent = nil
+	stats.legacy = true
+	TableLabeledGauge(stats, "migrator.wait_state", "client-events", "state", "locked", 1)
+	stats.SafeGauge("tsv_files.total.loaded", 5, 1.0)
+	assert.Equal(t, []string{
+		"ingester.production.science.migrator.client_events.wait_state.locked:1|g",
+		"ingester.migrator.client-events.wait_state.locked:1|g",
+		"ingester.production.science.tsv_files.total.loaded:5|g",
+		"ingester.tsv_files.total.loaded:5|g",
+	}, sender.sent)
+}
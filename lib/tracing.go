@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the ingester's and metadatastorer's spans.
+const tracerName = "github.com/twitchscience/rs_ingester"
+
+// traceParentHeader is the W3C trace context header a span context is stored as.
+const traceParentHeader = "traceparent"
+
+// traceContext is the propagator spans' contexts are stored and restored with.
+var traceContext propagation.TraceContext
+
+// TracingConfig configures the OpenTelemetry spans exported over OTLP.
+type TracingConfig struct {
+	// Endpoint is the host:port of the OTLP gRPC collector; if empty, spans aren't recorded
+	Endpoint string
+	// Insecure sends spans to the collector without TLS, e.g. to a local agent
+	Insecure bool
+	// Service is the service.name of the spans
+	Service string
+}
+
+// InitTracing sets the global tracer provider to export spans to the configured collector, returning
+// a function that flushes the spans not yet exported and stops the exporter. Without an endpoint the
+// provider is left as a no-op, and so is the function.
+func InitTracing(config TracingConfig) (func(context.Context) error, error) {
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.Service)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(traceContext)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the load path's spans, from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, or "" if it has none that's recorded.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+	return carrier.Get(traceParentHeader)
+}
+
+// SpanContextOf returns the span context of a W3C traceparent, which is invalid if the traceparent is.
+func SpanContextOf(traceParent string) trace.SpanContext {
+	ctx := traceContext.Extract(context.Background(), propagation.MapCarrier{traceParentHeader: traceParent})
+	return trace.SpanContextFromContext(ctx)
+}
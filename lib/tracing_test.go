@@ -0,0 +1,29 @@
+package lib
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceParent(t *testing.T) {
+	shutdown, err := InitTracing(TracingConfig{})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()), "tracing without an endpoint has nothing to flush")
+
+	ctx, span := Tracer().Start(context.Background(), "untraced")
+	assert.Equal(t, "", TraceParent(ctx), "spans of the no-op provider have no traceparent")
+	span.End()
+
+	ctx, span = sdktrace.NewTracerProvider().Tracer(tracerName).Start(context.Background(), "traced")
+	defer span.End()
+	traceParent := TraceParent(ctx)
+	assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, traceParent)
+	assert.Equal(t, span.SpanContext().TraceID(), SpanContextOf(traceParent).TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), SpanContextOf(traceParent).SpanID())
+
+	assert.False(t, SpanContextOf("").IsValid())
+	assert.False(t, SpanContextOf("00-not-a-trace-01").IsValid())
+}
@@ -0,0 +1,50 @@
+package lib
+
+import "fmt"
+
+// Tunables are the loader settings that can be changed without a restart, by editing the
+// "tunables" section of the config and reloading it. Each overrides the flag of the same name, and
+// a tunable missing from the config takes its flag's value.
+type Tunables struct {
+	LoadCountTrigger     int    `json:"loadCountTrigger"`
+	LoadAgeSeconds       int    `json:"loadAgeSeconds"`
+	OffpeakStartHour     int    `json:"offpeakStartHour"`
+	OffpeakDurationHours int    `json:"offpeakDurationHours"`
+	Workers              int    `json:"n_workers"`
+	IncludeTables        string `json:"includeTables"`
+	ExcludeTables        string `json:"excludeTables"`
+	// OffpeakWeekdays are the offpeak windows of days of the week that differ from the one given by
+	// OffpeakStartHour and OffpeakDurationHours, and have no flag
+	OffpeakWeekdays map[string]OffpeakWindow `json:"offpeakWeekdays,omitempty"`
+	// OffpeakHolidays are dates that are offpeak all day, and have no flag
+	OffpeakHolidays []string `json:"offpeakHolidays,omitempty"`
+}
+
+// OffpeakSchedule returns the offpeak schedule the tunables give.
+func (t Tunables) OffpeakSchedule() OffpeakSchedule {
+	return OffpeakSchedule{
+		Default:  OffpeakWindow{StartHour: t.OffpeakStartHour, DurationHours: t.OffpeakDurationHours},
+		Weekdays: t.OffpeakWeekdays,
+		Holidays: t.OffpeakHolidays,
+	}
+}
+
+// Validate returns an error if any tunable is out of range.
+func (t Tunables) Validate() error {
+	switch {
+	case t.LoadCountTrigger < 0:
+		return fmt.Errorf("loadCountTrigger must not be negative")
+	case t.LoadAgeSeconds <= 0:
+		return fmt.Errorf("loadAgeSeconds must be positive")
+	case t.OffpeakStartHour < 0 || t.OffpeakStartHour > 23:
+		return fmt.Errorf("offpeakStartHour must be between 0 and 23")
+	case t.OffpeakDurationHours < 0 || t.OffpeakDurationHours > 24:
+		return fmt.Errorf("offpeakDurationHours must be between 0 and 24")
+	case t.Workers < 0:
+		return fmt.Errorf("n_workers must not be negative")
+	}
+	if err := t.OffpeakSchedule().Validate(); err != nil {
+		return fmt.Errorf("invalid offpeak schedule: %v", err)
+	}
+	return nil
+}
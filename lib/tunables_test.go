@@ -0,0 +1,22 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTunablesValidate(t *testing.T) {
+	tunables := Tunables{LoadCountTrigger: 5, LoadAgeSeconds: 1800, OffpeakStartHour: 3, OffpeakDurationHours: 8, Workers: 5}
+	assert.NoError(t, tunables.Validate())
+
+	invalid := tunables
+	invalid.LoadAgeSeconds = 0
+	assert.Error(t, invalid.Validate())
+	invalid = tunables
+	invalid.OffpeakStartHour = 24
+	assert.Error(t, invalid.Validate())
+	invalid = tunables
+	invalid.Workers = -1
+	assert.Error(t, invalid.Validate())
+}
@@ -0,0 +1,131 @@
+package loadclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// ChunkMode is how the manifests a load is split into are COPYed.
+type ChunkMode string
+
+// The chunk modes.
+const (
+	// ChunkTogether COPYs all of a load's manifests in one transaction, so they succeed or fail
+	// together; the default
+	ChunkTogether ChunkMode = "together"
+	// ChunkSequential COPYs each manifest in a transaction of its own, one after another
+	ChunkSequential ChunkMode = "sequential"
+	// ChunkParallel COPYs each manifest in a transaction of its own, several at once
+	ChunkParallel ChunkMode = "parallel"
+)
+
+// ChunkRecorder records the chunks of loads as they're committed.
+type ChunkRecorder interface {
+	// LoadChunkDone takes a committed chunk's keys out of its load, so a retry of the load only
+	// COPYs the chunks that failed
+	LoadChunkDone(manifestUUID string, tableName string, keys []string, stats *metadata.LoadStats) error
+}
+
+// chunked returns whether the load's manifests are COPYed as chunks, each committed on its own. Only
+// plain loads are; versioned and straggler loads stage their files, so are always COPYed together.
+func (rsl *RSLoader) chunked(manifest *metadata.LoadManifest, parts [][]manifestFile) bool {
+	mode := rsl.manifestConfig.ChunkMode
+	return (mode == ChunkSequential || mode == ChunkParallel) && rsl.chunks != nil && len(parts) > 1 &&
+		!manifest.Versioned && manifest.Straggler == "" && !rsl.dryRun
+}
+
+// chunkedCopy COPYs the load's manifests as chunks, recording each one as it's committed. If any
+// chunk fails, the load fails with the error of each failed chunk, classified by the first's; the
+// committed chunks are out of the load by then, so its retry only COPYs the rest. A chunk committed
+// but not recorded, e.g. because the metadata database is down, is COPYed again by the retry.
+func (rsl *RSLoader) chunkedCopy(ctx context.Context, manifest *metadata.LoadManifest, parts [][]manifestFile,
+	manifestURLs []string, opts redshift.CopyOptions) (*backend.CopyStats, LoadError) {
+	parallelism := 1
+	if rsl.manifestConfig.ChunkMode == ChunkParallel {
+		parallelism = rsl.manifestConfig.ChunkParallelism
+	}
+	var lock sync.Mutex
+	total := &backend.CopyStats{}
+	errs := make([]error, len(parts))
+	rsl.rsBackend.ChunkedManifestCopy(ctx, manifest.TableName, manifestURLs, opts, parallelism,
+		func(chunk int, stats *backend.CopyStats, err error) {
+			if err == nil {
+				err = rsl.chunks.LoadChunkDone(manifest.UUID, manifest.TableName, chunkKeys(parts[chunk]),
+					&metadata.LoadStats{
+						RowsLoaded:   stats.RowsLoaded,
+						BytesScanned: stats.BytesScanned,
+						LinesScanned: stats.LinesScanned,
+						CopyDuration: stats.CopyDuration,
+					})
+				if err != nil {
+					logger.WithError(err).WithField("table", manifest.TableName).WithField("loadUUID", manifest.UUID).
+						WithField("chunk", chunk).Error("Committed chunk of load couldn't be recorded; its retry will load it again")
+					err = fmt.Errorf("recording committed chunk: %v", err)
+				}
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs[chunk] = err
+				lib.TableInc(rsl.stats, "manifest_load.chunks.failed", manifest.TableName, 1)
+				return
+			}
+			lib.TableInc(rsl.stats, "manifest_load.chunks.committed", manifest.TableName, 1)
+			total.RowsLoaded += stats.RowsLoaded
+			total.BytesScanned += stats.BytesScanned
+			total.LinesScanned += stats.LinesScanned
+			total.LockWait = stats.LockWait
+			total.CopyDuration += stats.CopyDuration
+			total.CommitDuration += stats.CommitDuration
+			total.CommitQueueWait += stats.CommitQueueWait
+			total.CommitExecution += stats.CommitExecution
+		})
+
+	var first error
+	var failures []string
+	for chunk, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		}
+		failures = append(failures, fmt.Sprintf("chunk %d: %v", chunk+1, err))
+	}
+	if first == nil {
+		return total, nil
+	}
+	loadErr := newLoadError(first)
+	loadErr.msg = fmt.Sprintf("%d of %d chunks failed, the rest were committed: %s", len(failures), len(parts),
+		strings.Join(failures, "; "))
+	return nil, loadErr
+}
+
+// chunkKeys returns the keys of a chunk's files, those of the files compacted into its compacted objects
+// rather than the objects'.
+func chunkKeys(part []manifestFile) []string {
+	keys := make([]string, 0, len(part))
+	for _, f := range part {
+		if len(f.sources) > 0 {
+			keys = append(keys, f.sources...)
+			continue
+		}
+		keys = append(keys, f.key)
+	}
+	return keys
+}
+
+// chunkManifestName is the key of a load's i-th manifest when its manifests are COPYed as chunks.
+// They're all named apart from the manifest CheckLoad looks for, so a load with only some of its
+// chunks committed isn't taken for done.
+func chunkManifestName(uuid string, i int) string {
+	return fmt.Sprintf("%s-chunk-%d.json", uuid, i)
+}
@@ -0,0 +1,84 @@
+package loadclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// chunkBackend fails the COPY of the chunks in fail.
+type chunkBackend struct {
+	noopBackend
+	fail map[int]error
+}
+
+func (b chunkBackend) ChunkedManifestCopy(_ context.Context, _ string, manifestURLs []string, _ redshift.CopyOptions, _ int,
+	done func(int, *backend.CopyStats, error)) {
+	for i := range manifestURLs {
+		if err := b.fail[i]; err != nil {
+			done(i, nil, err)
+			continue
+		}
+		done(i, &backend.CopyStats{RowsLoaded: 10, BytesScanned: 100}, nil)
+	}
+}
+
+type fakeRecorder struct {
+	lock   sync.Mutex
+	chunks [][]string
+}
+
+func (r *fakeRecorder) LoadChunkDone(_, _ string, keys []string, _ *metadata.LoadStats) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.chunks = append(r.chunks, keys)
+	return nil
+}
+
+func TestChunked(t *testing.T) {
+	rsl := &RSLoader{manifestConfig: ManifestConfig{ChunkMode: ChunkSequential}, chunks: &fakeRecorder{}}
+	manifest := &metadata.LoadManifest{}
+	two := [][]manifestFile{{{key: "a"}}, {{key: "b"}}}
+	assert.True(t, rsl.chunked(manifest, two))
+	assert.False(t, rsl.chunked(manifest, two[:1]), "a single manifest isn't chunked")
+	assert.False(t, rsl.chunked(&metadata.LoadManifest{Versioned: true}, two))
+	assert.False(t, rsl.chunked(&metadata.LoadManifest{Straggler: metadata.StragglerTable}, two))
+	rsl.chunks = nil
+	assert.False(t, rsl.chunked(manifest, two), "chunks can't be recorded")
+	rsl.manifestConfig.ChunkMode = ChunkTogether
+	assert.False(t, rsl.chunked(manifest, two))
+}
+
+func TestChunkedCopy(t *testing.T) {
+	parts := [][]manifestFile{{{key: "a"}, {key: "b"}}, {{key: "c"}}, {{key: "d"}}}
+	urls := []string{"s3://m/0", "s3://m/1", "s3://m/2"}
+	manifest := &metadata.LoadManifest{UUID: "uuid", TableName: "table"}
+
+	recorder := &fakeRecorder{}
+	rsl := &RSLoader{rsBackend: chunkBackend{}, stats: monitoring.NewMockStatter(), chunks: recorder}
+	stats, err := rsl.chunkedCopy(context.Background(), manifest, parts, urls, redshift.CopyOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(30), stats.RowsLoaded)
+	assert.Equal(t, int64(300), stats.BytesScanned)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}, {"d"}}, recorder.chunks)
+
+	recorder = &fakeRecorder{}
+	rsl.chunks = recorder
+	rsl.rsBackend = chunkBackend{fail: map[int]error{
+		1: errors.New("connection reset by peer"),
+		2: errors.New("Check 'stl_load_errors' system table for details"),
+	}}
+	stats, err = rsl.chunkedCopy(context.Background(), manifest, parts, urls, redshift.CopyOptions{})
+	assert.Nil(t, stats)
+	assert.EqualError(t, err, "2 of 3 chunks failed, the rest were committed: chunk 2: connection reset by peer; "+
+		"chunk 3: Check 'stl_load_errors' system table for details")
+	assert.Equal(t, ErrorConnection, err.Class(), "classified by the first failed chunk")
+	assert.Equal(t, [][]string{{"a", "b"}}, recorder.chunks, "only the committed chunk is recorded")
+}
@@ -0,0 +1,191 @@
+package loadclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// compactedPrefix is where in the manifest bucket loads' compacted objects go.
+const compactedPrefix = "compacted/"
+
+// compacting returns whether the load's small files are compacted before the COPY: compaction is
+// enabled and the files aren't client-side encrypted, since envelopes can't be concatenated.
+func (c ManifestConfig) compacting(encryption *encryptionRule) bool {
+	return c.CompactFileBytes > 0 && !encryption.clientSide()
+}
+
+// compactGroups groups the sorted files into the objects they're compacted into. A small file, of at
+// most CompactFileBytes, is grouped with the small files after it up to CompactTargetBytes, and with
+// SplitByDay only with those of the same day; every other file is a group of its own, placed before
+// the small files queued with it. Files are only grouped if the load has at least CompactMinFiles
+// small ones.
+func (c ManifestConfig) compactGroups(files []manifestFile) [][]manifestFile {
+	small := 0
+	for _, f := range files {
+		if f.size <= c.CompactFileBytes {
+			small++
+		}
+	}
+	groups := make([][]manifestFile, 0, len(files))
+	if small < c.CompactMinFiles || small < 2 {
+		for _, f := range files {
+			groups = append(groups, []manifestFile{f})
+		}
+		return groups
+	}
+	var group []manifestFile
+	var groupBytes int64
+	for _, f := range files {
+		if f.size > c.CompactFileBytes {
+			groups = append(groups, []manifestFile{f})
+			continue
+		}
+		if len(group) > 0 && ((c.CompactTargetBytes > 0 && groupBytes+f.size > c.CompactTargetBytes) ||
+			(c.SplitByDay && !group[0].day.Equal(f.day))) {
+			groups = append(groups, group)
+			group, groupBytes = nil, 0
+		}
+		group = append(group, f)
+		groupBytes += f.size
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	// Keep the groups sorted by their first files, for the manifests to be split by day
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i][0].before(groups[j][0]) })
+	return groups
+}
+
+// compactedObject is an object the sources were concatenated into, in the order given.
+type compactedObject struct {
+	Key     string
+	Sources []string
+}
+
+// compactionProvenance records which of a load's files went into each of its compacted objects. It's
+// uploaded next to them, and outlives the tsv rows of the files.
+type compactionProvenance struct {
+	LoadUUID  string
+	Table     string
+	Compacted time.Time
+	Objects   []compactedObject
+}
+
+// compact concatenates the load's small files into fewer, larger objects in the location's bucket and
+// returns the files to COPY in their place, each compacted object standing for its sources. Gzip,
+// bzip2 and Zstandard files concatenate into valid files of the same compression, and as each of the
+// processor's files ends in a newline, their rows stay whole.
+func (rsl *RSLoader) compact(ctx context.Context, loc manifestLocation, manifest *metadata.LoadManifest,
+	files []manifestFile) ([]manifestFile, error) {
+	groups := rsl.manifestConfig.compactGroups(files)
+	if len(groups) == len(files) || loc.s3 == nil {
+		return files, nil
+	}
+	provenance := compactionProvenance{
+		LoadUUID:  manifest.UUID,
+		Table:     manifest.TableName,
+		Compacted: time.Now().In(time.UTC),
+	}
+	compacted := make([]manifestFile, 0, len(groups))
+	sources := 0
+	for _, group := range groups {
+		if len(group) == 1 {
+			compacted = append(compacted, group[0])
+			continue
+		}
+		name := fmt.Sprintf("%s%s/%d", compactedPrefix, manifest.UUID, len(provenance.Objects))
+		f, err := rsl.concatenate(ctx, loc, name, group)
+		if err != nil {
+			return nil, err
+		}
+		provenance.Objects = append(provenance.Objects, compactedObject{Key: f.key, Sources: f.sources})
+		sources += len(group)
+		compacted = append(compacted, f)
+	}
+
+	body, err := json.Marshal(provenance)
+	if err != nil {
+		return nil, fmt.Errorf("encoding compaction provenance: %v", err)
+	}
+	if err = rsl.uploadVerified(ctx, loc, compactedPrefix+manifest.UUID+"/provenance.json", body); err != nil {
+		return nil, err
+	}
+	lib.TableInc(rsl.stats, "manifest_load.compaction.sources", manifest.TableName, int64(sources))
+	lib.TableInc(rsl.stats, "manifest_load.compaction.objects", manifest.TableName, int64(len(provenance.Objects)))
+	logger.WithField("table", manifest.TableName).WithField("loadUUID", manifest.UUID).
+		WithField("files", len(files)).WithField("compactedInto", len(compacted)).
+		Info("Compacted small files of load")
+	return compacted, nil
+}
+
+// concatenate reads the group's files, headConcurrency at a time, and uploads them one after another
+// as the named object, until ctx is done.
+func (rsl *RSLoader) concatenate(ctx context.Context, loc manifestLocation, name string,
+	group []manifestFile) (manifestFile, error) {
+	bodies := make([][]byte, len(group))
+	var wg sync.WaitGroup
+	errs := make(chan error, len(group))
+	sem := make(chan struct{}, headConcurrency)
+	for i := range group {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			bucket, key := splitS3Key(group[i].key)
+			err := awaitContext(ctx, func() error {
+				out, err := loc.s3.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if cerr := out.Body.Close(); cerr != nil {
+						logger.WithError(cerr).WithField("key", group[i].key).Error("Error closing file read to compact")
+					}
+				}()
+				bodies[i], err = ioutil.ReadAll(out.Body)
+				return err
+			})
+			if err != nil {
+				errs <- fmt.Errorf("reading %s to compact: %v", group[i].key, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return manifestFile{}, err
+	}
+
+	f := manifestFile{key: loc.bucket + "/" + name, queued: group[0].queued, day: group[0].day}
+	var body []byte
+	counted := true
+	var rows int64
+	for i, source := range group {
+		body = append(body, bodies[i]...)
+		f.sources = append(f.sources, source.key)
+		counted = counted && source.rows != nil
+		if source.rows != nil {
+			rows += *source.rows
+		}
+	}
+	f.size = int64(len(body))
+	if counted {
+		f.rows = &rows
+	}
+	if err := rsl.uploadVerified(ctx, loc, name, body); err != nil {
+		return manifestFile{}, err
+	}
+	return f, nil
+}
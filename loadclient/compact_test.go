@@ -0,0 +1,80 @@
+package loadclient
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+func TestCompactGroups(t *testing.T) {
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []manifestFile{
+		{key: "a", size: 10, queued: day, day: day},
+		{key: "b", size: 10, queued: day, day: day},
+		{key: "big", size: 1000, queued: day, day: day},
+		{key: "c", size: 10, queued: day, day: day},
+		{key: "d", size: 10, queued: day, day: day.Add(24 * time.Hour)},
+	}
+	keys := func(groups [][]manifestFile) [][]string {
+		var out [][]string
+		for _, group := range groups {
+			var groupKeys []string
+			for _, f := range group {
+				groupKeys = append(groupKeys, f.key)
+			}
+			out = append(out, groupKeys)
+		}
+		return out
+	}
+
+	c := ManifestConfig{CompactFileBytes: 100, CompactMinFiles: 2, CompactTargetBytes: 30}
+	assert.Equal(t, [][]string{{"big"}, {"a", "b", "c"}, {"d"}}, keys(c.compactGroups(files)))
+	c.SplitByDay = true
+	c.CompactTargetBytes = 100
+	assert.Equal(t, [][]string{{"big"}, {"a", "b", "c"}, {"d"}}, keys(c.compactGroups(files)))
+	c.SplitByDay = false
+	assert.Equal(t, [][]string{{"big"}, {"a", "b", "c", "d"}}, keys(c.compactGroups(files)))
+	c.CompactMinFiles = 5
+	assert.Len(t, c.compactGroups(files), len(files), "too few small files to compact")
+}
+
+func TestCompact(t *testing.T) {
+	fake := &flakyS3{objects: map[string][]byte{
+		"a":   []byte("1\ta\n"),
+		"b":   []byte("2\tb\n"),
+		"big": []byte("3\tbig\n"),
+	}}
+	rsl := &RSLoader{
+		stats:          monitoring.NewMockStatter(),
+		manifestConfig: ManifestConfig{CompactFileBytes: 4, CompactTargetBytes: 100},
+	}
+	loc := manifestLocation{bucket: "manifests", s3: fake, uploader: fake}
+	rows := int64(1)
+	queued := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []manifestFile{
+		{key: "bucket/a", size: 4, rows: &rows, queued: queued},
+		{key: "bucket/b", size: 4, rows: &rows, queued: queued.Add(time.Minute)},
+		{key: "bucket/big", size: 6, queued: queued.Add(2 * time.Minute)},
+	}
+	compacted, err := rsl.compact(context.Background(), loc, &metadata.LoadManifest{UUID: "uuid", TableName: "table"}, files)
+	assert.NoError(t, err)
+	if assert.Len(t, compacted, 2) {
+		assert.Equal(t, "manifests/compacted/uuid/0", compacted[0].key)
+		assert.Equal(t, []string{"bucket/a", "bucket/b"}, compacted[0].sources)
+		assert.Equal(t, int64(8), compacted[0].size)
+		assert.Equal(t, int64(2), *compacted[0].rows)
+		assert.Equal(t, "bucket/big", compacted[1].key)
+	}
+	assert.Equal(t, "1\ta\n2\tb\n", string(fake.objects["compacted/uuid/0"]))
+	assert.Equal(t, []string{"bucket/a", "bucket/b", "bucket/big"}, chunkKeys(compacted))
+
+	var provenance compactionProvenance
+	assert.NoError(t, json.Unmarshal(fake.objects["compacted/uuid/provenance.json"], &provenance))
+	assert.Equal(t, []compactedObject{{Key: "manifests/compacted/uuid/0", Sources: []string{"bucket/a", "bucket/b"}}},
+		provenance.Objects)
+}
@@ -0,0 +1,38 @@
+package loadclient
+
+import (
+	"context"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// dedupKey returns the columns the load is deduplicated on, or nil if it isn't. Versioned and straggler
+// loads stage their files their own way, and PII loads through their own staging table, so aren't
+// deduplicated.
+func (rsl *RSLoader) dedupKey(manifest *metadata.LoadManifest, pii backend.PIIColumns) []string {
+	key := manifest.CopySettings.DedupColumns()
+	if len(key) == 0 || manifest.Versioned || manifest.Straggler != "" {
+		return nil
+	}
+	if pii != nil {
+		lib.TableInc(rsl.stats, "manifest_load.dedup.skipped_pii", manifest.TableName, 1)
+		return nil
+	}
+	return key
+}
+
+// dedupCopy loads the files of a table with a dedup key, dropping the rows whose key is already loaded.
+func (rsl *RSLoader) dedupCopy(ctx context.Context, manifest *metadata.LoadManifest, key []string, manifestURLs []string,
+	opts redshift.CopyOptions) (*backend.CopyStats, error) {
+	lib.TableInc(rsl.stats, "manifest_load.dedup", manifest.TableName, 1)
+	stats, err := rsl.retrySerializable(ctx, manifest.TableName, func() (*backend.CopyStats, error) {
+		return rsl.rsBackend.DedupCopy(ctx, manifest.TableName, key, manifestURLs, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	lib.TableInc(rsl.stats, "manifest_load.dedup.duplicates", manifest.TableName, stats.DuplicatesDropped)
+	return stats, nil
+}
@@ -0,0 +1,118 @@
+package loadclient
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// EncryptionRule marks the files under a bucket and prefix as encrypted. Files encrypted with
+// SSE-KMS are decrypted by S3 for COPY, so only need the loader's role to be allowed to use the
+// key; files encrypted client-side are loaded with COPY's ENCRYPTED option and their master key.
+type EncryptionRule struct {
+	Bucket string `json:"bucket"`
+	Prefix string `json:"prefix"`
+	// MasterKeyEnv names the environment variable holding the base64 encoded AES-256 master key
+	// of client-side encrypted files; empty for SSE-KMS
+	MasterKeyEnv string `json:"masterKeyEnv"`
+}
+
+// Encryption finds the encryption rule of the files loaded. It's shared by all of the loaders.
+type Encryption struct {
+	rules []encryptionRule
+}
+
+type encryptionRule struct {
+	EncryptionRule
+	masterKey string
+}
+
+// clientSide returns whether the rule's files are encrypted client-side.
+func (r *encryptionRule) clientSide() bool {
+	return r != nil && r.masterKey != ""
+}
+
+// NewEncryption returns Encryption for the rules, reading their master keys from the environment.
+func NewEncryption(rules []EncryptionRule) (*Encryption, error) {
+	e := &Encryption{}
+	for _, rule := range rules {
+		if rule.Bucket == "" {
+			return nil, fmt.Errorf("encryption rule for prefix %q has no bucket", rule.Prefix)
+		}
+		r := encryptionRule{EncryptionRule: rule}
+		if rule.MasterKeyEnv != "" {
+			r.masterKey = os.Getenv(rule.MasterKeyEnv)
+			key, err := base64.StdEncoding.DecodeString(r.masterKey)
+			if err != nil || len(key) != 32 {
+				return nil, fmt.Errorf("%s must be a base64 encoded 256-bit key", rule.MasterKeyEnv)
+			}
+		}
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+// rule returns the rule with the longest prefix matching the S3 key name, or nil if none does.
+func (e *Encryption) rule(keyName string) *encryptionRule {
+	if e == nil {
+		return nil
+	}
+	bucket, key := splitS3Key(keyName)
+	var match *encryptionRule
+	for i, r := range e.rules {
+		if r.Bucket == bucket && strings.HasPrefix(key, r.Prefix) &&
+			(match == nil || len(r.Prefix) > len(match.Prefix)) {
+			match = &e.rules[i]
+		}
+	}
+	return match
+}
+
+// manifestRule returns the encryption rule of the manifest's files, which must all have the same one.
+func (e *Encryption) manifestRule(manifest *metadata.LoadManifest) (*encryptionRule, error) {
+	var rule *encryptionRule
+	for i, load := range manifest.Loads {
+		r := e.rule(load.KeyName)
+		if i > 0 && r != rule {
+			return nil, fmt.Errorf("load mixes files of different encryption rules: %s and %s",
+				manifest.Loads[0].KeyName, load.KeyName)
+		}
+		rule = r
+	}
+	return rule, nil
+}
+
+// checkDecryptable checks the loader can read an encrypted file before it's COPYed, since the COPY
+// uses the loader's credentials. For SSE-KMS, reading a byte needs permission to decrypt with the
+// file's key. Client-side encrypted files must have their envelope key in their metadata.
+func checkDecryptable(s3Client s3iface.S3API, rule *encryptionRule, keyName string) error {
+	bucket, key := splitS3Key(keyName)
+	if rule.clientSide() {
+		head, err := s3Client.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return fmt.Errorf("checking encryption of %s: %v", keyName, err)
+		}
+		for name := range head.Metadata {
+			if strings.EqualFold(name, "x-amz-key") || strings.EqualFold(name, "x-amz-key-v2") {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s has no client-side encryption envelope key", keyName)
+	}
+
+	out, err := s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String("bytes=0-0"),
+	})
+	if err != nil {
+		return fmt.Errorf("checking %s can be decrypted: %v", keyName, err)
+	}
+	return out.Body.Close()
+}
@@ -0,0 +1,44 @@
+package loadclient
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+func TestEncryptionRules(t *testing.T) {
+	require.NoError(t, os.Setenv("TEST_MASTER_KEY", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="))
+	defer func() { _ = os.Unsetenv("TEST_MASTER_KEY") }()
+	e, err := NewEncryption([]EncryptionRule{
+		{Bucket: "processed", Prefix: ""},
+		{Bucket: "processed", Prefix: "20180101/secret/", MasterKeyEnv: "TEST_MASTER_KEY"},
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, e.rule("other/20180101/secret/v1/a.gz"))
+	kms := e.rule("processed/20180101/public/v1/a.gz")
+	require.NotNil(t, kms)
+	assert.False(t, kms.clientSide())
+	clientSide := e.rule("processed/20180101/secret/v1/a.gz")
+	require.NotNil(t, clientSide)
+	assert.True(t, clientSide.clientSide())
+
+	rule, err := e.manifestRule(&metadata.LoadManifest{Loads: []metadata.Load{
+		{KeyName: "processed/20180101/secret/v1/a.gz"}, {KeyName: "processed/20180101/secret/v1/b.gz"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, clientSide, rule)
+	_, err = e.manifestRule(&metadata.LoadManifest{Loads: []metadata.Load{
+		{KeyName: "processed/20180101/secret/v1/a.gz"}, {KeyName: "processed/20180101/public/v1/b.gz"}}})
+	assert.Error(t, err, "files of different rules can't be loaded together")
+
+	var none *Encryption
+	rule, err = none.manifestRule(&metadata.LoadManifest{Loads: []metadata.Load{{KeyName: "processed/a.gz"}}})
+	assert.NoError(t, err)
+	assert.Nil(t, rule)
+
+	_, err = NewEncryption([]EncryptionRule{{Bucket: "processed", MasterKeyEnv: "TEST_MISSING_KEY"}})
+	assert.Error(t, err)
+}
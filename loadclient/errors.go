@@ -0,0 +1,167 @@
+package loadclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/lib/pq"
+)
+
+// ErrorClass is the kind of failure a load error is, which decides how it's handled.
+type ErrorClass string
+
+// The classes of load errors.
+const (
+	// ErrorCredentials is expired or invalid AWS credentials, or access denied to the data
+	ErrorCredentials ErrorClass = "credentials"
+	// ErrorMissingObject is a file, manifest or bucket missing from S3
+	ErrorMissingObject ErrorClass = "missing_object"
+	// ErrorSchemaMismatch is SQL that doesn't match the table's DDL, or data that doesn't fit it
+	ErrorSchemaMismatch ErrorClass = "schema_mismatch"
+	// ErrorSerialization is a transaction aborted by a concurrent one
+	ErrorSerialization ErrorClass = "serialization"
+	// ErrorDiskFull is the cluster running out of disk
+	ErrorDiskFull ErrorClass = "disk_full"
+	// ErrorConnection is the connection to Redshift or S3 being lost
+	ErrorConnection ErrorClass = "connection"
+	// ErrorTimeout is a stage of the load running past its timeout, or the load being canceled
+	ErrorTimeout ErrorClass = "timeout"
+	// ErrorUnknown is any other error
+	ErrorUnknown ErrorClass = "unknown"
+)
+
+// ErrorPolicy is how errors of a class are handled.
+type ErrorPolicy struct {
+	// Retryable is whether the load is retried; if not, it holds up its table until it's dealt with
+	Retryable bool
+	// RetryDelay is how long to wait before retrying; 0 is the metadata backend's default
+	RetryDelay time.Duration
+	// Alert is whether the error is logged as an error, so reported to Rollbar, rather than a warning
+	Alert bool
+}
+
+// ErrorPolicies are the policies of each class. Transient errors are retried soon without alerting;
+// errors that need someone to fix them alert, and are still retried in case they're fixed.
+var ErrorPolicies = map[ErrorClass]ErrorPolicy{
+	ErrorCredentials:    {Retryable: true, Alert: true},
+	ErrorMissingObject:  {Retryable: true, Alert: true},
+	ErrorSchemaMismatch: {Retryable: true, Alert: true},
+	ErrorSerialization:  {Retryable: true, RetryDelay: time.Minute},
+	ErrorDiskFull:       {Retryable: true, Alert: true},
+	ErrorConnection:     {Retryable: true, RetryDelay: time.Minute},
+	ErrorTimeout:        {Retryable: true, Alert: true},
+	ErrorUnknown:        {Retryable: true},
+}
+
+// Policy returns the policy of the class, or of ErrorUnknown if it has none.
+func (c ErrorClass) Policy() ErrorPolicy {
+	if policy, ok := ErrorPolicies[c]; ok {
+		return policy
+	}
+	return ErrorPolicies[ErrorUnknown]
+}
+
+// errorCodes classifies AWS error codes.
+var errorCodes = map[string]ErrorClass{
+	"ExpiredToken":          ErrorCredentials,
+	"ExpiredTokenException": ErrorCredentials,
+	"InvalidAccessKeyId":    ErrorCredentials,
+	"InvalidClientTokenId":  ErrorCredentials,
+	"SignatureDoesNotMatch": ErrorCredentials,
+	"AccessDenied":          ErrorCredentials,
+	"NoCredentialProviders": ErrorCredentials,
+	"NoSuchKey":             ErrorMissingObject,
+	"NoSuchBucket":          ErrorMissingObject,
+	"NotFound":              ErrorMissingObject,
+	"RequestError":          ErrorConnection,
+}
+
+// sqlStateClasses classifies postgres SQLSTATE classes, the first two characters of the code.
+var sqlStateClasses = map[string]ErrorClass{
+	"08": ErrorConnection,     // connection exception
+	"22": ErrorSchemaMismatch, // data exception
+	"23": ErrorSchemaMismatch, // integrity constraint violation
+	"40": ErrorSerialization,  // transaction rollback
+	"42": ErrorSchemaMismatch, // syntax error or access rule violation
+	"53": ErrorDiskFull,       // insufficient resources
+	"57": ErrorConnection,     // operator intervention, e.g. the server shutting down
+}
+
+// redshiftSerializationCode is the error code of Redshift's serializable isolation violations, which it
+// reports as the message of an internal error (SQLSTATE XX000) rather than as SQLSTATE 40001.
+const redshiftSerializationCode = "1023"
+
+// errorMessages classifies errors by their message, for errors wrapped into strings, like the
+// S3 errors Redshift reports for COPYs and the Data API's errors. Matched lowercased, in order.
+var errorMessages = []struct {
+	substring string
+	class     ErrorClass
+}{
+	{"expiredtoken", ErrorCredentials},
+	{"token has expired", ErrorCredentials},
+	{"access key id you provided does not exist", ErrorCredentials},
+	{"signaturedoesnotmatch", ErrorCredentials},
+	{"access denied", ErrorCredentials},
+	{"specified key does not exist", ErrorMissingObject},
+	{"specified bucket does not exist", ErrorMissingObject},
+	{"nosuchkey", ErrorMissingObject},
+	{"context deadline exceeded", ErrorTimeout},
+	{"context canceled", ErrorTimeout},
+	{"serializable isolation violation", ErrorSerialization},
+	{"error: " + redshiftSerializationCode, ErrorSerialization},
+	{"disk full", ErrorDiskFull},
+	{"check 'stl_load_errors'", ErrorSchemaMismatch},
+	{"does not exist", ErrorSchemaMismatch},
+	{"syntax error", ErrorSchemaMismatch},
+	{"connection reset", ErrorConnection},
+	{"connection refused", ErrorConnection},
+	{"broken pipe", ErrorConnection},
+	{"bad connection", ErrorConnection},
+	{"unexpected eof", ErrorConnection},
+	{"server closed the connection", ErrorConnection},
+}
+
+// Classify returns the class of a load error.
+func Classify(err error) ErrorClass {
+	// context.DeadlineExceeded is also a net.Error
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		return ErrorTimeout
+	}
+	switch e := err.(type) {
+	case nil:
+		return ErrorUnknown
+	case *pq.Error:
+		if strings.TrimSpace(e.Message) == redshiftSerializationCode {
+			return ErrorSerialization
+		}
+		if class, ok := sqlStateClasses[string(e.Code.Class())]; ok {
+			return class
+		}
+	case awserr.Error:
+		if class, ok := errorCodes[e.Code()]; ok {
+			return class
+		}
+		if e.OrigErr() != nil {
+			if class := Classify(e.OrigErr()); class != ErrorUnknown {
+				return class
+			}
+		}
+	case net.Error:
+		return ErrorConnection
+	}
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrorConnection
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range errorMessages {
+		if strings.Contains(msg, m.substring) {
+			return m.class
+		}
+	}
+	return ErrorUnknown
+}
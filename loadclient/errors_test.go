@@ -0,0 +1,60 @@
+package loadclient
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	for _, c := range []struct {
+		err   error
+		class ErrorClass
+	}{
+		{awserr.New("ExpiredToken", "The provided token has expired.", nil), ErrorCredentials},
+		{awserr.New("NoSuchKey", "The specified key does not exist.", nil), ErrorMissingObject},
+		{awserr.New("RequestError", "send request failed", errors.New("dial tcp: i/o timeout")), ErrorConnection},
+		{&pq.Error{Code: "42703", Message: `column "foo" does not exist`}, ErrorSchemaMismatch},
+		{&pq.Error{Code: "40001", Message: "could not serialize access"}, ErrorSerialization},
+		{&pq.Error{Code: "XX000", Message: "1023", Detail: "Serializable isolation violation on table"}, ErrorSerialization},
+		{errors.New("committing: ERROR: 1023"), ErrorSerialization},
+		{&pq.Error{Code: "53100", Message: "disk full"}, ErrorDiskFull},
+		{&pq.Error{Code: "57P01", Message: "terminating connection"}, ErrorConnection},
+		{driver.ErrBadConn, ErrorConnection},
+		{context.DeadlineExceeded, ErrorTimeout},
+		{fmt.Errorf("uploading m.json: %v", context.Canceled), ErrorTimeout},
+		{errors.New("Problem reading manifest file - S3ServiceException:The specified key does not exist.,Status 404"),
+			ErrorMissingObject},
+		{errors.New("S3ServiceException:The AWS Access Key Id you provided does not exist in our records.,Status 403"),
+			ErrorCredentials},
+		{fmt.Errorf("committing: %v", errors.New("Serializable isolation violation on table - 123")), ErrorSerialization},
+		{errors.New("Load into table 'foo' failed.  Check 'stl_load_errors' system table for details."),
+			ErrorSchemaMismatch},
+		{errors.New("Disk Full"), ErrorDiskFull},
+		{errors.New("read tcp: connection reset by peer"), ErrorConnection},
+		{errors.New("something else"), ErrorUnknown},
+	} {
+		assert.Equal(t, c.class, Classify(c.err), c.err.Error())
+	}
+}
+
+func TestNewLoadError(t *testing.T) {
+	err := newLoadError(&pq.Error{Code: "40001"})
+	assert.Equal(t, ErrorSerialization, err.Class())
+	assert.True(t, err.Retryable())
+	assert.Equal(t, time.Minute, err.RetryDelay())
+
+	err = newLoadError(errors.New("something else"))
+	assert.Equal(t, ErrorUnknown, err.Class())
+	assert.True(t, err.Retryable())
+	assert.Equal(t, time.Duration(0), err.RetryDelay())
+	assert.False(t, err.Class().Policy().Alert)
+	assert.Equal(t, ErrorPolicies[ErrorUnknown], ErrorClass("other").Policy())
+}
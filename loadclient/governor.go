@@ -0,0 +1,124 @@
+package loadclient
+
+import (
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// WLMChecker reports how busy the cluster's WLM queues are.
+type WLMChecker interface {
+	WLMState() (redshift.WLMState, error)
+}
+
+// GovernorConfig configures a Governor.
+type GovernorConfig struct {
+	// MaxCopies is the most loads COPYing at once; 0 for no limit beyond the workers
+	MaxCopies int
+	// WLMCheckPeriod is how often the WLM queues are checked while the cluster is saturated, and
+	// how long a check is reused for; 0 disables the checks
+	WLMCheckPeriod time.Duration
+}
+
+// Governor limits the loads COPYing at once: one per table, at most MaxCopies in total, and none
+// while the cluster's WLM queues are saturated. Loads wait for it before running their COPY
+// instead of queueing in Redshift, where they'd hold a connection and, for a table, its lock.
+type Governor struct {
+	checker WLMChecker
+	config  GovernorConfig
+	stats   monitoring.SafeStatter
+
+	lock    sync.Mutex
+	freed   *sync.Cond
+	copying map[string]bool
+	closed  bool
+
+	checkLock sync.Mutex
+	checked   time.Time
+	saturated bool
+}
+
+// NewGovernor returns a Governor checking the WLM queues with checker.
+func NewGovernor(checker WLMChecker, config GovernorConfig, stats monitoring.SafeStatter) *Governor {
+	g := &Governor{
+		checker: checker,
+		config:  config,
+		stats:   stats,
+		copying: make(map[string]bool),
+	}
+	g.freed = sync.NewCond(&g.lock)
+	return g
+}
+
+// Acquire blocks until a load into the table may COPY, then reserves its slot until Release.
+func (g *Governor) Acquire(table string) {
+	start := time.Now()
+	g.lock.Lock()
+	for !g.closed && (g.copying[table] || (g.config.MaxCopies > 0 && len(g.copying) >= g.config.MaxCopies)) {
+		g.freed.Wait()
+	}
+	g.copying[table] = true
+	g.lock.Unlock()
+
+	deferred := false
+	for g.clusterSaturated() {
+		if !deferred {
+			logger.WithField("table", table).Info("Redshift WLM queues are saturated; deferring load")
+			g.stats.SafeInc("copy_governor.deferred", 1, 1.0)
+			deferred = true
+		}
+		time.Sleep(g.config.WLMCheckPeriod)
+	}
+	g.stats.SafeTimingDuration("copy_governor.wait", time.Since(start), 1.0)
+}
+
+// Release frees the table's slot once its load is done.
+func (g *Governor) Release(table string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.copying, table)
+	g.freed.Broadcast()
+}
+
+// clusterSaturated returns whether the WLM queues are saturated, per a check at most
+// WLMCheckPeriod old. The cluster is assumed not to be if checking fails, so loads aren't held
+// up by the check itself.
+func (g *Governor) clusterSaturated() bool {
+	if g.config.WLMCheckPeriod <= 0 || g.isClosed() {
+		return false
+	}
+	g.checkLock.Lock()
+	defer g.checkLock.Unlock()
+	if time.Since(g.checked) < g.config.WLMCheckPeriod {
+		return g.saturated
+	}
+	state, err := g.checker.WLMState()
+	g.checked = time.Now()
+	if err != nil {
+		logger.WithError(err).Error("Error checking Redshift WLM queues")
+		g.stats.SafeInc("copy_governor.errors", 1, 1.0)
+		g.saturated = false
+		return false
+	}
+	g.saturated = state.Saturated()
+	g.stats.SafeGauge("copy_governor.wlm_running", int64(state.Running), 1.0)
+	g.stats.SafeGauge("copy_governor.wlm_queued", int64(state.Queued), 1.0)
+	return g.saturated
+}
+
+func (g *Governor) isClosed() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.closed
+}
+
+// Close stops holding loads back, so the workers can drain on shutdown.
+func (g *Governor) Close() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.closed = true
+	g.freed.Broadcast()
+}
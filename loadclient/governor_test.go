@@ -0,0 +1,57 @@
+package loadclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+type fakeWLM struct {
+	states chan redshift.WLMState
+}
+
+func (f *fakeWLM) WLMState() (redshift.WLMState, error) {
+	return <-f.states, nil
+}
+
+// acquired returns whether Acquire returns within a short time.
+func acquired(g *Governor, table string) bool {
+	done := make(chan bool)
+	go func() {
+		g.Acquire(table)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+func TestGovernorLimits(t *testing.T) {
+	g := NewGovernor(nil, GovernorConfig{MaxCopies: 2}, monitoring.NewMockStatter())
+	assert.True(t, acquired(g, "a"))
+	assert.False(t, acquired(g, "a"), "a table is loaded once at a time")
+	g.Release("a")
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, acquired(g, "b"))
+	assert.False(t, acquired(g, "c"), "at most MaxCopies load at once")
+	g.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, acquired(g, "d"), "a closed governor holds nothing back")
+}
+
+func TestGovernorDefersWhileSaturated(t *testing.T) {
+	wlm := &fakeWLM{states: make(chan redshift.WLMState, 2)}
+	wlm.states <- redshift.WLMState{Slots: 5, Running: 5}
+	wlm.states <- redshift.WLMState{Slots: 5, Running: 4}
+	g := NewGovernor(wlm, GovernorConfig{WLMCheckPeriod: 20 * time.Millisecond}, monitoring.NewMockStatter())
+	start := time.Now()
+	g.Acquire("a")
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+	assert.Empty(t, wlm.states)
+}
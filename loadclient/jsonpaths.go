@@ -0,0 +1,67 @@
+package loadclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/twitchscience/aws_utils/common"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// SchemaGetter returns the columns of a table at a version, e.g. from blueprint
+type SchemaGetter interface {
+	GetSchema(table string, version int) ([]scoop_protocol.ColumnDefinition, error)
+}
+
+type jsonPaths struct {
+	JSONPaths []string `json:"jsonpaths"`
+}
+
+// makeJSONPathsJSON maps each column, in table order, to the JSON key of its outbound name.
+func makeJSONPathsJSON(cols []scoop_protocol.ColumnDefinition) ([]byte, error) {
+	paths := jsonPaths{JSONPaths: make([]string, len(cols))}
+	for i, col := range cols {
+		key, err := json.Marshal(col.OutboundName)
+		if err != nil {
+			return nil, err
+		}
+		// JSON string escaping is a superset of what jsonpaths bracket notation needs.
+		paths.JSONPaths[i] = fmt.Sprintf("$[%s]", key)
+	}
+	return json.Marshal(paths)
+}
+
+// jsonPathsURL returns the URL of the jsonpaths file for the table and version, generating it
+// from the blueprint schema and uploading it to the location's bucket the first time it's needed there.
+func (rsl *RSLoader) jsonPathsURL(ctx context.Context, table string, version int, loc manifestLocation) (string, error) {
+	key := fmt.Sprintf("jsonpaths/%s/v%d.json", table, version)
+	rsl.jsonPathsLock.Lock()
+	defer rsl.jsonPathsLock.Unlock()
+	if url, ok := rsl.jsonPaths[loc.bucket+"/"+key]; ok {
+		return url, nil
+	}
+
+	if rsl.schemas == nil {
+		return "", fmt.Errorf("no schema source configured to generate jsonpaths for %s", table)
+	}
+	cols, err := rsl.schemas.GetSchema(table, version)
+	if err != nil {
+		return "", fmt.Errorf("getting schema for jsonpaths: %v", err)
+	}
+	if len(cols) == 0 {
+		return "", fmt.Errorf("no columns found for %s version %d", table, version)
+	}
+	body, err := makeJSONPathsJSON(cols)
+	if err != nil {
+		return "", err
+	}
+	err = rsl.uploadVerified(ctx, loc, key, body)
+	if err != nil {
+		return "", fmt.Errorf("uploading jsonpaths: %v", err)
+	}
+
+	url := common.NormalizeS3URL(loc.bucket + "/" + key)
+	rsl.jsonPaths[loc.bucket+"/"+key] = url
+	return url, nil
+}
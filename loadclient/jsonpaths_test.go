@@ -0,0 +1,17 @@
+package loadclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+func TestMakeJSONPathsJSON(t *testing.T) {
+	b, err := makeJSONPathsJSON([]scoop_protocol.ColumnDefinition{
+		{OutboundName: "time"},
+		{OutboundName: "it's"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"jsonpaths":["$[\"time\"]","$[\"it's\"]"]}`, string(b))
+}
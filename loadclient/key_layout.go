@@ -0,0 +1,116 @@
+package loadclient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// The placeholders of a manifest key layout.
+const (
+	placeholderEnv     = "{env}"
+	placeholderTable   = "{table}"
+	placeholderVersion = "{version}"
+	// placeholderDate is the day, in UTC, the load's oldest file was queued, as 2006-01-02, so a
+	// retried load's manifests overwrite its earlier ones
+	placeholderDate = "{date}"
+)
+
+var layoutPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// KeyLayout is a template of the prefix of a load's manifests' keys in the manifest bucket, e.g.
+// "manifests/{env}/{date}/{table}/v{version}/". The zero KeyLayout puts them at the top of the bucket.
+type KeyLayout struct {
+	template string
+	env      string
+}
+
+// NewKeyLayout returns the key layout of the template, with env as its {env}. The template must only
+// have known placeholders, and {env} only if env is set.
+func NewKeyLayout(template, env string) (KeyLayout, error) {
+	template = strings.TrimPrefix(template, "/")
+	if template != "" && !strings.HasSuffix(template, "/") {
+		template += "/"
+	}
+	for _, placeholder := range layoutPlaceholder.FindAllString(template, -1) {
+		switch placeholder {
+		case placeholderTable, placeholderVersion, placeholderDate:
+		case placeholderEnv:
+			if env == "" {
+				return KeyLayout{}, fmt.Errorf("manifest key layout %q has %s, but no environment is set",
+					template, placeholderEnv)
+			}
+		default:
+			return KeyLayout{}, fmt.Errorf("manifest key layout %q has unknown placeholder %s", template, placeholder)
+		}
+	}
+	return KeyLayout{template: template, env: env}, nil
+}
+
+// prefix returns the prefix of the load's manifests' keys.
+func (l KeyLayout) prefix(manifest *metadata.LoadManifest) string {
+	if l.template == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		placeholderEnv, l.env,
+		placeholderTable, manifest.TableName,
+		placeholderVersion, strconv.Itoa(manifest.Version),
+		placeholderDate, oldestQueued(manifest).UTC().Format("2006-01-02"),
+	).Replace(l.template)
+}
+
+// searchPrefix returns a LIKE pattern matching the prefix of any load's manifests' keys, for finding
+// a load's COPY by its UUID alone.
+func (l KeyLayout) searchPrefix() string {
+	if l.template == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		placeholderEnv, l.env,
+		placeholderTable, "%",
+		placeholderVersion, "%",
+		placeholderDate, "%",
+	).Replace(l.template)
+}
+
+// oldestQueued returns when the load's oldest file was queued, or now if that's unknown.
+func oldestQueued(manifest *metadata.LoadManifest) time.Time {
+	var oldest time.Time
+	for _, queued := range manifest.Queued {
+		if oldest.IsZero() || queued.Before(oldest) {
+			oldest = queued
+		}
+	}
+	if oldest.IsZero() {
+		return time.Now()
+	}
+	return oldest
+}
+
+// UploadEncryption is how objects uploaded to the manifest bucket are encrypted at rest.
+type UploadEncryption struct {
+	// ServerSideEncryption is "AES256" or "aws:kms"; empty leaves it to the bucket's default
+	ServerSideEncryption string
+	// KMSKeyID is the KMS key of "aws:kms" encryption; empty uses the account's default S3 key
+	KMSKeyID string
+}
+
+// Validate returns an error if the encryption isn't one S3 supports.
+func (e UploadEncryption) Validate() error {
+	switch e.ServerSideEncryption {
+	case "", s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms:
+	default:
+		return fmt.Errorf("unknown server-side encryption %q; use %s or %s", e.ServerSideEncryption,
+			s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms)
+	}
+	if e.KMSKeyID != "" && e.ServerSideEncryption != s3.ServerSideEncryptionAwsKms {
+		return fmt.Errorf("a KMS key needs %s server-side encryption", s3.ServerSideEncryptionAwsKms)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package loadclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+func TestKeyLayout(t *testing.T) {
+	manifest := &metadata.LoadManifest{
+		UUID:      "uuid",
+		TableName: "table",
+		Version:   3,
+		Queued: []time.Time{
+			time.Date(2018, 1, 2, 0, 30, 0, 0, time.UTC),
+			time.Date(2018, 1, 1, 23, 30, 0, 0, time.UTC),
+		},
+	}
+
+	layout, err := NewKeyLayout("manifests/{env}/{date}/{table}/v{version}", "production")
+	assert.NoError(t, err)
+	assert.Equal(t, "manifests/production/2018-01-01/table/v3/", layout.prefix(manifest),
+		"the date is the oldest file's")
+	assert.Equal(t, "manifests/production/%/%/v%/", layout.searchPrefix())
+	assert.Equal(t, "s3://bucket/manifests/production/2018-01-01/table/v3/uuid.json",
+		manifestURL("bucket", layout.prefix(manifest), manifest.UUID))
+
+	layout, err = NewKeyLayout("", "production")
+	assert.NoError(t, err)
+	assert.Equal(t, "", layout.prefix(manifest), "manifests are at the top of the bucket by default")
+	assert.Equal(t, "", layout.searchPrefix())
+
+	_, err = NewKeyLayout("manifests/{env}/", "")
+	assert.Error(t, err, "{env} needs an environment")
+	_, err = NewKeyLayout("manifests/{hour}/", "production")
+	assert.Error(t, err, "unknown placeholder")
+}
+
+func TestUploadEncryption(t *testing.T) {
+	assert.NoError(t, UploadEncryption{}.Validate())
+	assert.NoError(t, UploadEncryption{ServerSideEncryption: "AES256"}.Validate())
+	assert.NoError(t, UploadEncryption{ServerSideEncryption: "aws:kms", KMSKeyID: "alias/manifests"}.Validate())
+	assert.Error(t, UploadEncryption{ServerSideEncryption: "aes"}.Validate())
+	assert.Error(t, UploadEncryption{ServerSideEncryption: "AES256", KMSKeyID: "alias/manifests"}.Validate())
+}
@@ -1,6 +1,9 @@
 package loadclient
 
 import (
+	"context"
+	"time"
+
 	"github.com/twitchscience/rs_ingester/metadata"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
@@ -9,11 +12,16 @@ import (
 type LoadError interface {
 	error
 	Retryable() bool
+	// RetryDelay is how long to wait before retrying the load; 0 is the default
+	RetryDelay() time.Duration
+	Class() ErrorClass
 }
 
 // Loader interacts with scoop loads
 type Loader interface {
-	LoadManifest(manifest *metadata.LoadManifest) LoadError
+	// LoadManifest loads the manifest's files, giving up once ctx is done
+	LoadManifest(ctx context.Context, manifest *metadata.LoadManifest) (*metadata.LoadStats, LoadError)
 	CheckLoad(manifestUUID string) (scoop_protocol.LoadStatus, error)
+	ManifestURL(manifest *metadata.LoadManifest) (string, error)
 	HealthCheck() error
 }
@@ -1,8 +1,19 @@
 package loadclient
 
+import "time"
+
 type loadError struct {
 	msg         string
+	class       ErrorClass
 	isRetryable bool
+	retryDelay  time.Duration
+}
+
+// newLoadError classifies err, handling it according to its class's policy.
+func newLoadError(err error) *loadError {
+	class := Classify(err)
+	policy := class.Policy()
+	return &loadError{msg: err.Error(), class: class, isRetryable: policy.Retryable, retryDelay: policy.RetryDelay}
 }
 
 func (e loadError) Error() string {
@@ -13,6 +24,14 @@ func (e loadError) Retryable() bool {
 	return e.isRetryable
 }
 
+func (e loadError) RetryDelay() time.Duration {
+	return e.retryDelay
+}
+
+func (e loadError) Class() ErrorClass {
+	return e.class
+}
+
 type entry struct {
 	URL       string `json:"url"`
 	Mandatory bool   `json:"mandatory"`
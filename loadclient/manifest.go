@@ -0,0 +1,225 @@
+package loadclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// headConcurrency is how many files' sizes are looked up at once.
+const headConcurrency = 16
+
+// ManifestConfig bounds the manifests a load is split into, and says how they're COPYed. By default,
+// all of a load's manifests are COPYed in one transaction, so they succeed or fail together. Zero
+// values are unbounded.
+type ManifestConfig struct {
+	// MaxFiles is the most files in one manifest
+	MaxFiles int
+	// MaxBytes is the most bytes of files in one manifest. Files' sizes are looked up with a
+	// HEAD request each.
+	MaxBytes int64
+	// SplitByDay puts files of events of different days (in UTC), by the dates in their keys, in
+	// different manifests, so each COPY stays within a day of a time sort key
+	SplitByDay bool
+	// SkipEmptyFiles leaves files out of the COPY if the processor advertised no rows for them, or
+	// advertised no row count and they have at most EmptyFileBytes bytes, looked up with a HEAD request
+	// each. A load of only empty files is done without a COPY.
+	SkipEmptyFiles bool
+	// EmptyFileBytes is the size at or below which a file is empty, e.g. that of a header-only file
+	EmptyFileBytes int64
+	// ChunkMode is how a load's manifests are COPYed; with ChunkSequential or ChunkParallel, each is a
+	// chunk committed on its own, so a failure only holds up the chunks that failed. Empty is ChunkTogether.
+	ChunkMode ChunkMode
+	// ChunkParallelism is how many of a load's chunks are COPYed at once with ChunkParallel
+	ChunkParallelism int
+	// CompactFileBytes is the size at or below which a file is small. A load's small files are
+	// concatenated into fewer, larger objects before the COPY; 0 disables compaction
+	CompactFileBytes int64
+	// CompactMinFiles is the fewest small files a load must have to be compacted
+	CompactMinFiles int
+	// CompactTargetBytes is the most bytes of small files compacted into one object
+	CompactTargetBytes int64
+	// KeyLayout is where in the manifest bucket manifests go
+	KeyLayout KeyLayout
+	// UploadEncryption is how manifests, jsonpaths files and compacted objects are encrypted at rest
+	UploadEncryption UploadEncryption
+	// CheckMissing looks up each file with a HEAD request before the COPY, and leaves the files that
+	// no longer exist, e.g. deleted by a lifecycle policy, out of it. Without it, a missing file fails
+	// the load, at the COPY if sizes aren't looked up.
+	CheckMissing bool
+	// MaxMissingFraction is the largest fraction of a load's files that may be missing for the rest
+	// to be COPYed; a load with more missing fails
+	MaxMissingFraction float64
+}
+
+// sizesNeeded returns whether files' sizes must be looked up.
+func (c ManifestConfig) sizesNeeded() bool {
+	return c.MaxBytes > 0 || c.SkipEmptyFiles || c.CompactFileBytes > 0 || c.CheckMissing
+}
+
+type manifestFile struct {
+	key    string
+	queued time.Time
+	// day is the day, in UTC, of the file's events from the date in its key, or of when it was
+	// queued if its key has none
+	day  time.Time
+	size int64
+	// rows is the number of rows advertised for the file, if any
+	rows *int64
+	// sources are the keys of the files compacted into this one, if it's a compacted object
+	sources []string
+	// missing is whether S3 didn't find the file when its size was looked up
+	missing bool
+}
+
+// dropMissing returns the files that weren't missing when their sizes were looked up, and those that
+// were. It returns an error if any are missing and the config doesn't check for them, or if more than
+// MaxMissingFraction of the files are.
+func (c ManifestConfig) dropMissing(files []manifestFile) ([]manifestFile, []manifestFile, error) {
+	var kept, missing []manifestFile
+	for _, f := range files {
+		if f.missing {
+			missing = append(missing, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	if len(missing) == 0 {
+		return files, nil, nil
+	}
+	if !c.CheckMissing || float64(len(missing)) > c.MaxMissingFraction*float64(len(files)) {
+		return nil, missing, fmt.Errorf("%d of %d files not found in S3, e.g. %s", len(missing), len(files),
+			missing[0].key)
+	}
+	return kept, missing, nil
+}
+
+// dropEmpty returns the files that aren't empty, which must have their sizes, and how many were
+// dropped. A small file the processor advertised rows for isn't empty, so it's kept and its rows stay
+// expected.
+func (c ManifestConfig) dropEmpty(files []manifestFile) ([]manifestFile, int) {
+	if !c.SkipEmptyFiles {
+		return files, 0
+	}
+	kept := files[:0]
+	for _, f := range files {
+		if f.rows != nil && *f.rows > 0 {
+			kept = append(kept, f)
+			continue
+		}
+		if f.rows != nil || f.size <= c.EmptyFileBytes {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, len(files) - len(kept)
+}
+
+// manifestFiles returns the load's files sorted by the day of their events, then by when they were
+// queued.
+func manifestFiles(m *metadata.LoadManifest) []manifestFile {
+	files := make([]manifestFile, len(m.Loads))
+	for i, load := range m.Loads {
+		files[i].key = load.KeyName
+		files[i].rows = load.RowCount
+		if i < len(m.Queued) {
+			files[i].queued = m.Queued[i]
+		}
+		if day, ok := metadata.ProcessedKeyDate(load.KeyName); ok {
+			files[i].day = day
+		} else {
+			files[i].day = files[i].queued.UTC().Truncate(24 * time.Hour)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].before(files[j]) })
+	return files
+}
+
+// before returns whether f goes before g in a manifest.
+func (f manifestFile) before(g manifestFile) bool {
+	if !f.day.Equal(g.day) {
+		return f.day.Before(g.day)
+	}
+	return f.queued.Before(g.queued)
+}
+
+// split splits the sorted files into manifests within the config's bounds.
+func (c ManifestConfig) split(files []manifestFile) [][]manifestFile {
+	var parts [][]manifestFile
+	var part []manifestFile
+	var partBytes int64
+	for _, f := range files {
+		if len(part) > 0 && c.startsNewPart(part, partBytes, f) {
+			parts = append(parts, part)
+			part, partBytes = nil, 0
+		}
+		part = append(part, f)
+		partBytes += f.size
+	}
+	if len(part) > 0 {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func (c ManifestConfig) startsNewPart(part []manifestFile, partBytes int64, f manifestFile) bool {
+	switch {
+	case c.MaxFiles > 0 && len(part) >= c.MaxFiles:
+		return true
+	case c.MaxBytes > 0 && partBytes+f.size > c.MaxBytes:
+		return true
+	case c.SplitByDay && !part[0].day.Equal(f.day):
+		return true
+	}
+	return false
+}
+
+// lookUpSizes sets the size of each file from S3, marking those S3 doesn't find as missing. Lookups
+// still running when ctx is done are canceled.
+func lookUpSizes(ctx context.Context, s3Client s3iface.S3API, files []manifestFile) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(files))
+	sem := make(chan struct{}, headConcurrency)
+	for i := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(f *manifestFile) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			bucket, key := splitS3Key(f.key)
+			req, out := s3Client.HeadObjectRequest(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+			req.HTTPRequest = req.HTTPRequest.WithContext(ctx)
+			err := req.Send()
+			if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "NotFound" || aerr.Code() == "NoSuchKey") {
+				f.missing = true
+				return
+			}
+			if err != nil {
+				errs <- fmt.Errorf("getting size of %s: %v", f.key, err)
+				return
+			}
+			f.size = aws.Int64Value(out.ContentLength)
+		}(&files[i])
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// splitS3Key splits a key name of the form [s3://]bucket/key.
+func splitS3Key(keyName string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(keyName, "s3://"), "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
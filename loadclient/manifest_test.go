@@ -0,0 +1,94 @@
+package loadclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+func TestSplitManifest(t *testing.T) {
+	day := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &metadata.LoadManifest{
+		Loads: []metadata.Load{{KeyName: "d"}, {KeyName: "a"}, {KeyName: "c"}, {KeyName: "b"}},
+		Queued: []time.Time{
+			day.Add(25 * time.Hour), day.Add(time.Hour), day.Add(23 * time.Hour), day.Add(2 * time.Hour),
+		},
+	}
+	files := manifestFiles(m)
+	keys := func(parts [][]manifestFile) [][]string {
+		var out [][]string
+		for _, part := range parts {
+			var partKeys []string
+			for _, f := range part {
+				partKeys = append(partKeys, f.key)
+			}
+			out = append(out, partKeys)
+		}
+		return out
+	}
+
+	assert.Equal(t, [][]string{{"a", "b", "c", "d"}}, keys(ManifestConfig{}.split(files)))
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"d"}}, keys(ManifestConfig{SplitByDay: true}.split(files)))
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}, {"d"}},
+		keys(ManifestConfig{SplitByDay: true, MaxFiles: 2}.split(files)))
+
+	for i := range files {
+		files[i].size = 10
+	}
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"d"}}, keys(ManifestConfig{MaxBytes: 30}.split(files)))
+
+	// Files are ordered and split by the dates of their events in their keys, not when they were queued
+	m = &metadata.LoadManifest{
+		Loads: []metadata.Load{
+			{KeyName: "b/20170102/t/v1/late"}, {KeyName: "b/2017-01-01/t/v1/backfill"}, {KeyName: "b/20170102/t/v1/early"},
+		},
+		Queued: []time.Time{day.Add(49 * time.Hour), day.Add(50 * time.Hour), day.Add(48 * time.Hour)},
+	}
+	files = manifestFiles(m)
+	assert.Equal(t, [][]string{{"b/2017-01-01/t/v1/backfill"}, {"b/20170102/t/v1/early", "b/20170102/t/v1/late"}},
+		keys(ManifestConfig{SplitByDay: true}.split(files)))
+}
+
+func TestDropEmptyFiles(t *testing.T) {
+	none, some := int64(0), int64(10)
+	files := []manifestFile{
+		{key: "zero_bytes", size: 0},
+		{key: "header_only", size: 30},
+		{key: "no_rows", size: 100, rows: &none},
+		{key: "rows", size: 100, rows: &some},
+		{key: "uncounted", size: 100},
+		{key: "small_rows", size: 20, rows: &some},
+	}
+
+	kept, dropped := ManifestConfig{}.dropEmpty(append([]manifestFile{}, files...))
+	assert.Equal(t, 0, dropped)
+	assert.Len(t, kept, 6)
+
+	kept, dropped = ManifestConfig{SkipEmptyFiles: true, EmptyFileBytes: 30}.dropEmpty(files)
+	assert.Equal(t, 3, dropped)
+	assert.Equal(t, []string{"rows", "uncounted", "small_rows"}, []string{kept[0].key, kept[1].key, kept[2].key},
+		"small files advertising rows aren't empty")
+}
+
+func TestDropMissingFiles(t *testing.T) {
+	files := []manifestFile{{key: "a"}, {key: "b", missing: true}, {key: "c"}, {key: "d"}}
+
+	kept, missing, err := ManifestConfig{}.dropMissing(files[:1])
+	assert.NoError(t, err)
+	assert.Len(t, kept, 1)
+	assert.Empty(t, missing)
+
+	_, missing, err = ManifestConfig{}.dropMissing(files)
+	assert.Error(t, err)
+	assert.Len(t, missing, 1)
+
+	_, _, err = ManifestConfig{CheckMissing: true, MaxMissingFraction: 0.2}.dropMissing(files)
+	assert.Error(t, err)
+
+	kept, missing, err = ManifestConfig{CheckMissing: true, MaxMissingFraction: 0.25}.dropMissing(files)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "d"}, []string{kept[0].key, kept[1].key, kept[2].key})
+	assert.Equal(t, "b", missing[0].key)
+}
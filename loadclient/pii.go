@@ -0,0 +1,57 @@
+package loadclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
+)
+
+// PIISource returns the policies of a table's PII columns by column, e.g. from blueprint's metadata.
+type PIISource interface {
+	PIIColumns(table string) map[string]string
+}
+
+// piiColumns returns the policies of the load's PII columns, or nil if the load has none. Versioned and
+// straggler loads stage their files their own way, which can't transform PII columns, so those of a
+// table with PII columns fail rather than load the raw values.
+func (rsl *RSLoader) piiColumns(manifest *metadata.LoadManifest) (backend.PIIColumns, LoadError) {
+	if rsl.pii == nil {
+		return nil, nil
+	}
+	policies := rsl.pii.PIIColumns(manifest.TableName)
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	if manifest.Versioned || manifest.Straggler != "" {
+		lib.TableInc(rsl.stats, "manifest_load.pii.unsupported", manifest.TableName, 1)
+		return nil, &loadError{msg: fmt.Sprintf("%s has PII columns, which versioned and straggler loads can't transform",
+			manifest.TableName), class: ErrorUnknown, isRetryable: false}
+	}
+	pii, err := backend.ParsePIIColumns(policies)
+	if err != nil {
+		// Loading the raw values would leak them, and retrying won't fix the metadata
+		lib.TableInc(rsl.stats, "manifest_load.pii.invalid", manifest.TableName, 1)
+		return nil, &loadError{msg: err.Error(), class: ErrorUnknown, isRetryable: false}
+	}
+	return pii, nil
+}
+
+// piiCopy loads the files of a table with PII columns, transformed by their policies.
+func (rsl *RSLoader) piiCopy(ctx context.Context, manifest *metadata.LoadManifest, pii backend.PIIColumns,
+	manifestURLs []string, opts redshift.CopyOptions) (*backend.CopyStats, error) {
+	if rsl.schemas == nil {
+		return nil, fmt.Errorf("no schema source configured to load PII columns of %s", manifest.TableName)
+	}
+	cols, err := rsl.schemas.GetSchema(manifest.TableName, manifest.Version)
+	if err != nil {
+		return nil, fmt.Errorf("getting columns of version %d to load PII columns: %v", manifest.Version, err)
+	}
+	lib.TableInc(rsl.stats, "manifest_load.pii", manifest.TableName, 1)
+	return rsl.retrySerializable(ctx, manifest.TableName, func() (*backend.CopyStats, error) {
+		return rsl.rsBackend.PIICopy(ctx, manifest.TableName, cols, manifestURLs, opts, pii)
+	})
+}
@@ -0,0 +1,39 @@
+package loadclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+type piiSource map[string]map[string]string
+
+func (s piiSource) PIIColumns(table string) map[string]string {
+	return s[table]
+}
+
+func TestPIIColumns(t *testing.T) {
+	rsl := &RSLoader{stats: monitoring.NewMockStatter(),
+		pii: piiSource{"chat": {"login": "hash"}, "bad": {"login": "tokenize"}}}
+
+	pii, err := rsl.piiColumns(&metadata.LoadManifest{TableName: "chat"})
+	assert.Nil(t, err)
+	assert.Equal(t, backend.PIIColumns{"login": backend.PIIHash}, pii)
+	pii, err = rsl.piiColumns(&metadata.LoadManifest{TableName: "video", Versioned: true})
+	assert.Nil(t, err, "tables without PII columns load as usual")
+	assert.Nil(t, pii)
+
+	for _, manifest := range []*metadata.LoadManifest{
+		{TableName: "chat", Versioned: true},
+		{TableName: "chat", Straggler: metadata.StragglerTable},
+		{TableName: "bad"},
+	} {
+		_, err = rsl.piiColumns(manifest)
+		if assert.NotNil(t, err, "PII columns aren't loaded raw") {
+			assert.False(t, err.Retryable())
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package loadclient
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// maxReportedValue is the most of a bad value quoted in a pre-validation error.
+const maxReportedValue = 64
+
+// PreValidation checks the first rows of a large TSV load's first file fit the table's blueprint
+// schema before the COPY, so a load of files that don't fails in seconds, naming the row and column,
+// rather than after the COPY has run for an hour. The file is sampled with a ranged GET of its first
+// SampleBytes; the vendored SDK predates S3 Select. Loads are checked if they have at least MinFiles
+// files or MinRows advertised rows; each minimum is ignored if 0, and nothing is checked if both are.
+type PreValidation struct {
+	MinFiles int
+	MinRows  int64
+	// SampleRows is the most rows of the file checked
+	SampleRows int
+	// SampleBytes is how much of the file is read, before it's decompressed
+	SampleBytes int64
+}
+
+// applies returns whether the manifest's files are checked before they're COPYed. Files compressed
+// with Zstandard can't be decompressed by the loader, so aren't.
+func (p PreValidation) applies(manifest *metadata.LoadManifest) bool {
+	if p.SampleRows <= 0 || p.SampleBytes <= 0 || manifest.Format == metadata.LoadFormatJSON ||
+		len(manifest.Loads) == 0 || p.compression(manifest) == metadata.CompressionZstd {
+		return false
+	}
+	return (p.MinFiles > 0 && len(manifest.Loads) >= p.MinFiles) ||
+		(p.MinRows > 0 && manifest.ExpectedRows.Valid && manifest.ExpectedRows.Int64 >= p.MinRows)
+}
+
+// compression returns the compression of the manifest's first file.
+func (p PreValidation) compression(manifest *metadata.LoadManifest) metadata.Compression {
+	if manifest.Compression != "" {
+		return manifest.Compression
+	}
+	return metadata.CompressionForKey(manifest.Loads[0].KeyName)
+}
+
+// preValidate checks the first rows of the manifest's first file against the table's schema at the
+// files' version. It fails open: if the file can't be sampled or the schema looked up, the load goes
+// ahead and the COPY has the last word. Client-side encrypted files can't be read, so aren't checked.
+func (rsl *RSLoader) preValidate(s3Client s3iface.S3API, manifest *metadata.LoadManifest,
+	encryption *encryptionRule) LoadError {
+	if rsl.schemas == nil || encryption.clientSide() || !rsl.preValidation.applies(manifest) {
+		return nil
+	}
+	keyName := manifest.Loads[0].KeyName
+	entry := logger.WithField("table", manifest.TableName).WithField("loadUUID", manifest.UUID).
+		WithField("keyName", keyName)
+	cols, err := rsl.schemas.GetSchema(manifest.TableName, manifest.Version)
+	if err != nil {
+		entry.WithError(err).Warn("Skipping pre-validation of load whose schema couldn't be found")
+		return nil
+	}
+	start := time.Now()
+	records, err := sampleFile(s3Client, keyName, rsl.preValidation.compression(manifest),
+		rsl.preValidation.SampleBytes, rsl.preValidation.SampleRows)
+	if err != nil {
+		entry.WithError(err).Warn("Skipping pre-validation of load whose file couldn't be sampled")
+		return nil
+	}
+	rsl.stats.SafeTimingDuration("manifest_load.prevalidation", time.Since(start), 1.0)
+	lib.TableInc(rsl.stats, "manifest_load.prevalidated", manifest.TableName, 1)
+	if err = validateRecords(records, cols); err != nil {
+		lib.TableInc(rsl.stats, "manifest_load.prevalidation_failed", manifest.TableName, 1)
+		policy := ErrorSchemaMismatch.Policy()
+		return &loadError{msg: fmt.Sprintf("pre-validating %s against %s v%d: %v", keyName, manifest.TableName,
+			manifest.Version, err), class: ErrorSchemaMismatch, isRetryable: policy.Retryable,
+			retryDelay: policy.RetryDelay}
+	}
+	return nil
+}
+
+// sampleFile returns up to maxRows records from the first maxBytes of the file. The record cut off
+// at maxBytes is dropped, as is any after the file fails to decompress.
+func sampleFile(s3Client s3iface.S3API, keyName string, compression metadata.Compression, maxBytes int64,
+	maxRows int) ([][]string, error) {
+	bucket, key := splitS3Key(keyName)
+	out, err := s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", maxBytes-1)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", keyName, err)
+	}
+	defer func() { _ = out.Body.Close() }()
+	whole := aws.Int64Value(out.ContentLength) < maxBytes
+
+	var r io.Reader = out.Body
+	switch compression {
+	case metadata.CompressionGzip:
+		gz, err := gzip.NewReader(out.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing %s: %v", keyName, err)
+		}
+		r = gz
+	case metadata.CompressionBzip2:
+		r = bzip2.NewReader(out.Body)
+	}
+	return readRecords(r, maxRows, whole), nil
+}
+
+// readRecords reads up to max TSV records as COPY splits them with the REMOVEQUOTES and ESCAPE
+// options: fields are separated by tabs and records by newlines, except within quotes or after a
+// backslash, and quotes are removed. A record ended by the end of r rather than a newline is only
+// kept if complete is set, i.e. r is the whole file.
+func readRecords(r io.Reader, max int, complete bool) [][]string {
+	br := bufio.NewReader(r)
+	var records [][]string
+	var fields []string
+	var field strings.Builder
+	quoted, escaped, started := false, false, false
+	for len(records) < max {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			if err == io.EOF && complete && started {
+				records = append(records, append(fields, field.String()))
+			}
+			return records
+		}
+		started = true
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			field.WriteRune(c)
+		case c == '\t':
+			fields = append(fields, field.String())
+			field.Reset()
+		case c == '\n':
+			records = append(records, append(fields, field.String()))
+			fields, started = nil, false
+			field.Reset()
+		default:
+			field.WriteRune(c)
+		}
+	}
+	return records
+}
+
+// validateRecords returns an error naming the first field of the records that can't be loaded into
+// the columns, and how many of the records can't be, or nil if they all can. Records may have fewer
+// fields than there are columns, since COPY fills the rest with NULLs.
+func validateRecords(records [][]string, cols []scoop_protocol.ColumnDefinition) error {
+	types := make([]string, len(cols))
+	for i, col := range cols {
+		types[i] = backend.ExpectedType(col)
+	}
+	var first string
+	bad := 0
+	for line, record := range records {
+		problem := ""
+		if len(record) > len(cols) {
+			problem = fmt.Sprintf("row %d has %d fields, but the table has %d columns", line+1, len(record), len(cols))
+		}
+		for i := 0; i < len(record) && i < len(cols) && problem == ""; i++ {
+			if reason := checkField(record[i], types[i]); reason != "" {
+				value := record[i]
+				if len(value) > maxReportedValue {
+					value = value[:maxReportedValue] + "..."
+				}
+				problem = fmt.Sprintf("row %d, field %d: column %s (%s) can't hold %q: %s", line+1, i+1,
+					cols[i].OutboundName, types[i], value, reason)
+			}
+		}
+		if problem != "" {
+			if bad == 0 {
+				first = problem
+			}
+			bad++
+		}
+	}
+	if bad > 0 {
+		return fmt.Errorf("%s (%d of %d sampled rows are bad)", first, bad, len(records))
+	}
+	return nil
+}
+
+// checkField returns why COPY would reject the value for a column of the normalized Redshift type, or
+// "" if it wouldn't. Only the syntax of numbers, booleans and the dates of timestamps are checked; empty
+// values are loaded as NULLs and character values are truncated, so are always accepted.
+func checkField(value, colType string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	base := colType
+	if i := strings.Index(base, "("); i >= 0 {
+		base = base[:i]
+	}
+	var err error
+	switch base {
+	case "smallint":
+		_, err = strconv.ParseInt(value, 10, 16)
+	case "int":
+		_, err = strconv.ParseInt(value, 10, 32)
+	case "bigint":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "float4", "float8", "numeric":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		switch strings.ToLower(value) {
+		case "t", "true", "y", "yes", "1", "f", "false", "n", "no", "0":
+		default:
+			return "not a boolean"
+		}
+	case "date", "timestamp", "timestamptz":
+		if len(value) < len("2006-01-02") {
+			return "not a date"
+		}
+		_, err = time.Parse("2006-01-02", value[:len("2006-01-02")])
+	}
+	if numErr, ok := err.(*strconv.NumError); ok {
+		return numErr.Err.Error()
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
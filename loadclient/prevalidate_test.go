@@ -0,0 +1,66 @@
+package loadclient
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+func TestPreValidationApplies(t *testing.T) {
+	p := PreValidation{MinFiles: 2, MinRows: 1000, SampleRows: 10, SampleBytes: 1024}
+	small := &metadata.LoadManifest{Loads: []metadata.Load{{KeyName: "b/t/v1/a.gz"}}}
+	assert.False(t, p.applies(small))
+	small.ExpectedRows = sql.NullInt64{Int64: 1000, Valid: true}
+	assert.True(t, p.applies(small), "enough rows are advertised")
+
+	many := &metadata.LoadManifest{Loads: []metadata.Load{{KeyName: "b/t/v1/a.zst"}, {KeyName: "b/t/v1/b.zst"}}}
+	assert.False(t, p.applies(many), "zstd can't be sampled")
+	many.Compression = metadata.CompressionNone
+	assert.True(t, p.applies(many))
+	many.Format = metadata.LoadFormatJSON
+	assert.False(t, p.applies(many))
+
+	assert.False(t, PreValidation{SampleRows: 10, SampleBytes: 1024}.applies(small), "disabled without minimums")
+}
+
+func TestReadRecords(t *testing.T) {
+	tsv := "1\ta\\\tb\t\n" + // escaped tab
+		"\"2\"\t\"line\nbreak\"\n" + // newline within quotes
+		"\"3\"\t\"cut"
+	assert.Equal(t, [][]string{{"1", "a\tb", ""}, {"2", "line\nbreak"}},
+		readRecords(strings.NewReader(tsv), 10, false), "the record cut off is dropped")
+	assert.Equal(t, [][]string{{"1", "a\tb", ""}, {"2", "line\nbreak"}, {"3", "cut"}},
+		readRecords(strings.NewReader(tsv), 10, true))
+	assert.Equal(t, [][]string{{"1", "a\tb", ""}}, readRecords(strings.NewReader(tsv), 1, true))
+}
+
+func TestValidateRecords(t *testing.T) {
+	cols := []scoop_protocol.ColumnDefinition{
+		{OutboundName: "id", Transformer: "bigint"},
+		{OutboundName: "time", Transformer: "f@timestamp@unix"},
+		{OutboundName: "ok", Transformer: "bool"},
+		{OutboundName: "name", Transformer: "varchar", ColumnCreationOptions: "(8)"},
+	}
+	assert.NoError(t, validateRecords([][]string{
+		{"1", "2017-03-15 04:05:00", "true", "much longer than eight"},
+		{"2", "", "0"},
+		{" 3 "},
+	}, cols))
+
+	err := validateRecords([][]string{
+		{"1", "2017-03-15 04:05:00", "t", "x"},
+		{"1.5", "2017-03-15 04:05:00", "t", "x"},
+		{"2", "15/03/2017", "t", "x"},
+	}, cols)
+	assert.EqualError(t, err, `row 2, field 1: column id (bigint) can't hold "1.5": invalid syntax (2 of 3 sampled rows are bad)`)
+
+	err = validateRecords([][]string{{"1", "2017-03-15 04:05:00", "maybe"}}, cols)
+	assert.EqualError(t, err, `row 1, field 3: column ok (bool) can't hold "maybe": not a boolean (1 of 1 sampled rows are bad)`)
+
+	err = validateRecords([][]string{{"1", "", "", "", "extra"}}, cols)
+	assert.EqualError(t, err, "row 1 has 5 fields, but the table has 4 columns (1 of 1 sampled rows are bad)")
+}
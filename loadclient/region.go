@@ -0,0 +1,152 @@
+package loadclient
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// RegionConfig lets loads COPY data from buckets outside the cluster's region. COPY needs the
+// manifest and jsonpaths files in the same region as the data, so each region data comes from
+// needs a manifest bucket.
+type RegionConfig struct {
+	// ClusterRegion is the Redshift cluster's region; defaults to the AWS session's
+	ClusterRegion string `json:"clusterRegion"`
+	// ManifestBuckets maps a region to the bucket for manifests of data in that region
+	ManifestBuckets map[string]string `json:"manifestBuckets"`
+}
+
+// Regions finds the region of data buckets and hands out S3 clients for each region. It's shared
+// by all of the loaders.
+type Regions struct {
+	config    RegionConfig
+	session   client.ConfigProvider
+	lock      sync.Mutex
+	buckets   map[string]string
+	clients   map[string]s3iface.S3API
+	uploaders map[string]s3manageriface.UploaderAPI
+}
+
+// NewRegions returns Regions for the config, using session to create regional S3 clients.
+func NewRegions(config RegionConfig, session client.ConfigProvider) *Regions {
+	return &Regions{
+		config:    config,
+		session:   session,
+		buckets:   make(map[string]string),
+		clients:   make(map[string]s3iface.S3API),
+		uploaders: make(map[string]s3manageriface.UploaderAPI),
+	}
+}
+
+// enabled returns whether data may come from another region, so data buckets' regions are looked up.
+func (r *Regions) enabled() bool {
+	return r != nil && len(r.config.ManifestBuckets) > 0
+}
+
+// bucketRegion returns the region of the bucket, looking it up the first time.
+func (r *Regions) bucketRegion(bucket string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if region, ok := r.buckets[bucket]; ok {
+		return region, nil
+	}
+	out, err := r.client(r.config.ClusterRegion).GetBucketLocation(&s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return "", fmt.Errorf("getting region of bucket %s: %v", bucket, err)
+	}
+	region := normalizeBucketLocation(aws.StringValue(out.LocationConstraint))
+	r.buckets[bucket] = region
+	return region, nil
+}
+
+// normalizeBucketLocation maps GetBucketLocation's legacy location constraints to region names.
+func normalizeBucketLocation(location string) string {
+	switch location {
+	case "":
+		return "us-east-1"
+	case "EU":
+		return "eu-west-1"
+	}
+	return location
+}
+
+// client returns an S3 client for the region. r.lock must be held.
+func (r *Regions) client(region string) s3iface.S3API {
+	if c, ok := r.clients[region]; ok {
+		return c
+	}
+	c := s3.New(r.session, aws.NewConfig().WithRegion(region))
+	r.clients[region] = c
+	return c
+}
+
+// regionalClients returns an S3 client and uploader for the region.
+func (r *Regions) regionalClients(region string) (s3iface.S3API, s3manageriface.UploaderAPI) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	c := r.client(region)
+	u, ok := r.uploaders[region]
+	if !ok {
+		u = s3manager.NewUploaderWithClient(c)
+		r.uploaders[region] = u
+	}
+	return c, u
+}
+
+// manifestLocation is where a load's manifests and jsonpaths file go, and the S3 clients to use there.
+type manifestLocation struct {
+	bucket string
+	// region is the region to tell COPY the data is in, or empty for the cluster's region
+	region   string
+	s3       s3iface.S3API
+	uploader s3manageriface.UploaderAPI
+}
+
+// locate returns where the load's manifests go: the loader's bucket, unless the data is in another
+// region than the cluster.
+func (rsl *RSLoader) locate(manifest *metadata.LoadManifest) (manifestLocation, error) {
+	local := manifestLocation{bucket: rsl.bucket, s3: rsl.s3, uploader: rsl.s3Uploader}
+	if !rsl.regions.enabled() {
+		return local, nil
+	}
+
+	var region string
+	regions := make(map[string]bool)
+	for _, load := range manifest.Loads {
+		bucket, _ := splitS3Key(load.KeyName)
+		var err error
+		region, err = rsl.regions.bucketRegion(bucket)
+		if err != nil {
+			return manifestLocation{}, err
+		}
+		regions[region] = true
+	}
+	if len(regions) > 1 {
+		var names []string
+		for region := range regions {
+			names = append(names, region)
+		}
+		sort.Strings(names)
+		return manifestLocation{}, fmt.Errorf("load has files in several regions: %v", names)
+	}
+	if region == "" || region == rsl.regions.config.ClusterRegion {
+		return local, nil
+	}
+
+	bucket, ok := rsl.regions.config.ManifestBuckets[region]
+	if !ok {
+		return manifestLocation{}, fmt.Errorf("no manifest bucket configured for region %s", region)
+	}
+	s3Client, uploader := rsl.regions.regionalClients(region)
+	return manifestLocation{bucket: bucket, region: region, s3: s3Client, uploader: uploader}, nil
+}
@@ -1,63 +1,304 @@
 package loadclient
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"sync"
 
 	"github.com/twitchscience/aws_utils/common"
+	"github.com/twitchscience/aws_utils/logger"
 	"github.com/twitchscience/aws_utils/monitoring"
 	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/chaos"
 
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/twitchscience/rs_ingester/lib"
 	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
 
 //RSLoader contains the redshift backend, stats module, and s3 bucket for the loader
 type RSLoader struct {
-	rsBackend  backend.Backend
-	bucket     string
-	stats      monitoring.SafeStatter
-	s3Uploader s3manageriface.UploaderAPI
+	rsBackend      backend.Backend
+	bucket         string
+	stats          monitoring.SafeStatter
+	s3Uploader     s3manageriface.UploaderAPI
+	s3             s3iface.S3API
+	schemas        SchemaGetter
+	manifestConfig ManifestConfig
+	regions        *Regions
+	encryption     *Encryption
+	preValidation  PreValidation
+	chunks         ChunkRecorder
+	pii            PIISource
+	copyTimeoutMs  int
+	stageTimeouts  StageTimeouts
+	dryRun         bool
+	jsonPaths      map[string]string
+	jsonPathsLock  sync.Mutex
 }
 
-//NewRSLoader returns a RSLoader instance
-func NewRSLoader(s3Uploader s3manageriface.UploaderAPI, rsBackend backend.Backend, manifestBucket string, stats monitoring.SafeStatter) (Loader, error) {
+//NewRSLoader returns a RSLoader instance. schemas is used to generate jsonpaths files for JSON loads and
+//to stage files of outdated versions, and s3Client to look up file sizes if manifestConfig bounds manifests
+//by bytes. If regions is set, loads of data in other regions put their manifests in that region's bucket.
+//Files matching an encryption rule are checked to be decryptable before they're COPYed, and large loads'
+//first files are sampled and checked against their schema as preValidation says. If manifestConfig COPYs
+//loads in chunks, chunks records each one as it's committed; loads are COPYed whole if it's nil. Tables
+//with PII columns in pii are loaded through a staging table that transforms them; pii may be nil. COPYs
+//time out after copyTimeoutMs, unless the table's copy settings override it; 0 means no timeout. Loads
+//fail once a stage runs past its stageTimeouts. If dryRun is set, loads skip the COPY and are reported
+//as simulated.
+func NewRSLoader(s3Uploader s3manageriface.UploaderAPI, s3Client s3iface.S3API, rsBackend backend.Backend,
+	manifestBucket string, stats monitoring.SafeStatter, schemas SchemaGetter, manifestConfig ManifestConfig,
+	regions *Regions, encryption *Encryption, preValidation PreValidation, chunks ChunkRecorder, pii PIISource,
+	copyTimeoutMs int, stageTimeouts StageTimeouts, dryRun bool) (Loader, error) {
 	return &RSLoader{
-		rsBackend:  rsBackend,
-		bucket:     manifestBucket,
-		stats:      stats,
-		s3Uploader: s3Uploader}, nil
+		rsBackend:      rsBackend,
+		bucket:         manifestBucket,
+		stats:          stats,
+		s3Uploader:     s3Uploader,
+		s3:             s3Client,
+		schemas:        schemas,
+		manifestConfig: manifestConfig,
+		regions:        regions,
+		encryption:     encryption,
+		preValidation:  preValidation,
+		chunks:         chunks,
+		pii:            pii,
+		copyTimeoutMs:  copyTimeoutMs,
+		stageTimeouts:  stageTimeouts,
+		dryRun:         dryRun,
+		jsonPaths:      make(map[string]string)}, nil
 }
 
-//LoadManifest takes a load manifest object and uses the RSBackend to load the manifest into redshift
-func (rsl *RSLoader) LoadManifest(manifest *metadata.LoadManifest) LoadError {
+//LoadManifest takes a load manifest object and uses the RSBackend to load the manifest into redshift,
+//giving up once ctx is done or a stage runs past its timeout
+func (rsl *RSLoader) LoadManifest(ctx context.Context, manifest *metadata.LoadManifest) (*metadata.LoadStats,
+	LoadError) {
 	start := time.Now()
+	prepareCtx, cancelPrepare := stageContext(ctx, rsl.stageTimeouts.Prepare)
+	defer cancelPrepare()
 
-	manifestURL, err := rsl.CreateManifestInBucket(manifest)
+	loc, err := rsl.locate(manifest)
 	if err != nil {
-		return &loadError{msg: err.Error(), isRetryable: true}
+		return nil, newLoadError(err)
+	}
+	encryption, err := rsl.encryption.manifestRule(manifest)
+	if err != nil {
+		return nil, &loadError{msg: err.Error(), class: ErrorUnknown, isRetryable: false}
+	}
+	if encryption != nil {
+		// One file stands for the rest, which share its rule and so its key.
+		err = checkDecryptable(loc.s3, encryption, manifest.Loads[0].KeyName)
+		if err != nil {
+			lib.TableInc(rsl.stats, "manifest_load.undecryptable", manifest.TableName, 1)
+			return nil, newLoadError(err)
+		}
+	}
+	if loadErr := rsl.preValidate(loc.s3, manifest, encryption); loadErr != nil {
+		return nil, loadErr
+	}
+	pii, loadErr := rsl.piiColumns(manifest)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	files := manifestFiles(manifest)
+	if rsl.manifestConfig.sizesNeeded() {
+		if err = lookUpSizes(prepareCtx, loc.s3, files); err != nil {
+			return nil, newLoadError(err)
+		}
+	}
+	files, loadErr = rsl.dropMissing(manifest, files)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	files, empty := rsl.manifestConfig.dropEmpty(files)
+	if empty > 0 {
+		lib.TableInc(rsl.stats, "manifest_load.empty_files", manifest.TableName, int64(empty))
+	}
+	if len(files) == 0 {
+		logger.WithField("table", manifest.TableName).WithField("loadUUID", manifest.UUID).
+			WithField("files", empty).Info("Skipping COPY of load with only empty or missing files")
+		lib.TableInc(rsl.stats, "manifest_load.skipped_empty", manifest.TableName, 1)
+		return &metadata.LoadStats{ExpectedRows: manifest.ExpectedRows}, nil
+	}
+	if rsl.manifestConfig.compacting(encryption) {
+		files, err = rsl.compact(prepareCtx, loc, manifest, files)
+		if err != nil {
+			return nil, newLoadError(err)
+		}
+	}
+	parts := rsl.manifestConfig.split(files)
+	dedupKey := rsl.dedupKey(manifest, pii)
+	// PII and deduplicated loads go through a staging table, so are COPYed whole
+	chunked := pii == nil && dedupKey == nil && rsl.chunked(manifest, parts)
+	manifestURLs, err := rsl.createManifestsInBucket(prepareCtx, manifest, parts, loc, chunked)
+	if err != nil {
+		return nil, newLoadError(err)
 	}
 
-	err = rsl.rsBackend.ManifestCopy(&scoop_protocol.ManifestRowCopyRequest{
-		ManifestURL: manifestURL,
-		TableName:   manifest.TableName,
-	})
+	opts := redshift.CopyOptions{
+		CompUpdate:  manifest.CopySettings.CompUpdate,
+		StatUpdate:  manifest.CopySettings.StatUpdate,
+		Compression: string(manifest.Compression),
+		Region:      loc.region,
+
+		StatementTimeoutMs: rsl.copyTimeoutMs,
+	}
+	if manifest.CopySettings.StatementTimeoutMs > 0 {
+		opts.StatementTimeoutMs = manifest.CopySettings.StatementTimeoutMs
+	}
+	if encryption.clientSide() {
+		opts.MasterSymmetricKey = encryption.masterKey
+	}
+	if manifest.Format == metadata.LoadFormatJSON {
+		opts.JSONPathsURL, err = rsl.jsonPathsURL(prepareCtx, manifest.TableName, manifest.Version, loc)
+		if err != nil {
+			return nil, newLoadError(err)
+		}
+	}
+
+	uploaded := time.Since(start)
+	cancelPrepare()
+
+	if rsl.dryRun {
+		TraceStage(ctx, StageManifestUpload, start, uploaded)
+		return rsl.simulateLoad(manifest, uploaded)
+	}
+
+	if err = chaos.FailCopy(); err != nil {
+		return nil, newLoadError(err)
+	}
+	copyCtx, cancelCopy := stageContext(ctx, rsl.stageTimeouts.Copy)
+	defer cancelCopy()
+	opts = opts.WithinDeadline(copyCtx)
+
+	var copyStats *backend.CopyStats
+	if manifest.Versioned {
+		copyStats, err = rsl.retrySerializable(copyCtx, manifest.TableName, func() (*backend.CopyStats, error) {
+			return rsl.rsBackend.VersionedManifestCopy(copyCtx, manifest.TableName, manifest.Version, manifestURLs, opts)
+		})
+		lib.TableInc(rsl.stats, "manifest_load.versioned", manifest.TableName, 1)
+	} else if manifest.Straggler != "" {
+		copyStats, err = rsl.stragglerCopy(copyCtx, manifest, manifestURLs, opts)
+	} else if pii != nil {
+		copyStats, err = rsl.piiCopy(copyCtx, manifest, pii, manifestURLs, opts)
+	} else if dedupKey != nil {
+		copyStats, err = rsl.dedupCopy(copyCtx, manifest, dedupKey, manifestURLs, opts)
+	} else if chunked {
+		copyStats, loadErr = rsl.chunkedCopy(copyCtx, manifest, parts, manifestURLs, opts)
+		if loadErr != nil {
+			return nil, loadErr
+		}
+	} else {
+		copyStats, err = rsl.retrySerializable(copyCtx, manifest.TableName, func() (*backend.CopyStats, error) {
+			return rsl.rsBackend.ManifestCopy(copyCtx, manifest.TableName, manifestURLs, opts)
+		})
+	}
 	if err != nil {
-		return &loadError{msg: err.Error(), isRetryable: true}
+		return nil, newLoadError(err)
+	}
+
+	// The COPY's stages ran one after the other, ending with its commit just now
+	commitStart := time.Now().Add(-copyStats.CommitDuration)
+	copyStart := commitStart.Add(-copyStats.CopyDuration)
+	TraceStage(ctx, StageManifestUpload, start, uploaded)
+	TraceStage(ctx, StageLockWait, copyStart.Add(-copyStats.LockWait), copyStats.LockWait)
+	TraceStage(ctx, StageCopy, copyStart, copyStats.CopyDuration)
+	TraceStage(ctx, StageCommit, commitStart, copyStats.CommitDuration)
+
+	TimeStage(rsl.stats, manifest.TableName, StageManifestUpload, uploaded)
+	TimeStage(rsl.stats, manifest.TableName, StageLockWait, copyStats.LockWait)
+	TimeStage(rsl.stats, manifest.TableName, StageCopy, copyStats.CopyDuration)
+	TimeStage(rsl.stats, manifest.TableName, StageCommit, copyStats.CommitDuration)
+	if copyStats.CommitExecution > 0 {
+		TimeStage(rsl.stats, manifest.TableName, StageCommitQueue, copyStats.CommitQueueWait)
+		TimeStage(rsl.stats, manifest.TableName, StageCommitExecute, copyStats.CommitExecution)
 	}
 
 	rsl.stats.SafeTimingDuration(manifest.TableName, time.Since(start), 1.0)
-	return nil
+	return &metadata.LoadStats{
+		RowsLoaded:   copyStats.RowsLoaded,
+		BytesScanned: copyStats.BytesScanned,
+		ExpectedRows: manifest.ExpectedRows,
+		LinesScanned: copyStats.LinesScanned,
+		CopyDuration: copyStats.CopyDuration,
+	}, nil
+}
+
+//stragglerCopy loads the files of an outdated version of the table as its straggler policy says: into
+//the table itself, or into its stragglers table.
+func (rsl *RSLoader) stragglerCopy(ctx context.Context, manifest *metadata.LoadManifest, manifestURLs []string,
+	opts redshift.CopyOptions) (*backend.CopyStats, error) {
+	if rsl.schemas == nil {
+		return nil, fmt.Errorf("no schema source configured to load straggling files of %s", manifest.TableName)
+	}
+	cols, err := rsl.schemas.GetSchema(manifest.TableName, manifest.Version)
+	if err != nil {
+		return nil, fmt.Errorf("getting columns of straggling version %d: %v", manifest.Version, err)
+	}
+	into := manifest.TableName
+	if manifest.Straggler == metadata.StragglerTable {
+		into = backend.StragglersTableName(manifest.TableName)
+	}
+	lib.TableInc(rsl.stats, "manifest_load.straggler."+string(manifest.Straggler), manifest.TableName, 1)
+	return rsl.retrySerializable(ctx, manifest.TableName, func() (*backend.CopyStats, error) {
+		return rsl.rsBackend.StragglerCopy(ctx, manifest.TableName, into, cols, manifestURLs, opts)
+	})
+}
+
+//dropMissing leaves the files S3 didn't find out of the load, logging each, if few enough are missing.
+//The missing files' advertised rows are taken off the load's expected rows, as they won't be loaded.
+func (rsl *RSLoader) dropMissing(manifest *metadata.LoadManifest, files []manifestFile) ([]manifestFile, LoadError) {
+	kept, missing, err := rsl.manifestConfig.dropMissing(files)
+	if len(missing) > 0 {
+		lib.TableInc(rsl.stats, "manifest_load.missing_files", manifest.TableName, int64(len(missing)))
+	}
+	if err != nil {
+		policy := ErrorMissingObject.Policy()
+		return nil, &loadError{msg: err.Error(), class: ErrorMissingObject, isRetryable: policy.Retryable,
+			retryDelay: policy.RetryDelay}
+	}
+	for _, f := range missing {
+		logger.WithField("table", manifest.TableName).WithField("loadUUID", manifest.UUID).
+			WithField("key", f.key).Warn("Leaving file missing from S3 out of COPY")
+		if f.rows != nil && manifest.ExpectedRows.Valid {
+			manifest.ExpectedRows.Int64 -= *f.rows
+		}
+	}
+	return kept, nil
+}
+
+//simulateLoad stands in for the COPY of a dry run load, checking the load's status in Redshift the way
+//orphaned loads are checked. As the COPY never ran, Redshift shouldn't know of the load.
+func (rsl *RSLoader) simulateLoad(manifest *metadata.LoadManifest, uploaded time.Duration) (*metadata.LoadStats, LoadError) {
+	status, err := rsl.CheckLoad(manifest.UUID)
+	if err != nil {
+		return nil, newLoadError(fmt.Errorf("checking simulated load: %v", err))
+	}
+	if status != scoop_protocol.LoadNotFound {
+		logger.WithField("loadUUID", manifest.UUID).WithField("status", status).
+			Warn("Redshift has a status for a simulated load")
+	}
+	TimeStage(rsl.stats, manifest.TableName, StageManifestUpload, uploaded)
+	lib.TableInc(rsl.stats, "manifest_load.simulated", manifest.TableName, 1)
+	return &metadata.LoadStats{Simulated: true}, nil
 }
 
 //CheckLoad checks the status of a current manifest load into Redshift
 func (rsl *RSLoader) CheckLoad(manifestUUID string) (scoop_protocol.LoadStatus, error) {
-	url := manifestURL(rsl.bucket, manifestUUID)
+	bucket := rsl.bucket
+	if rsl.regions.enabled() {
+		// The manifest may be in any region's bucket; its UUID alone finds the COPY.
+		bucket = "%"
+	}
+	// The manifest's prefix depends on the load, but its UUID alone finds the COPY.
+	url := manifestURL(bucket, rsl.manifestConfig.KeyLayout.searchPrefix(), manifestUUID)
 
 	loadstatus, err := rsl.rsBackend.LoadCheck(&scoop_protocol.LoadCheckRequest{
 		ManifestURL: url,
@@ -69,37 +310,58 @@ func (rsl *RSLoader) CheckLoad(manifestUUID string) (scoop_protocol.LoadStatus,
 	return loadstatus.LoadStatus, nil
 }
 
-//HealthCheck Checks to see if the connection to Redshift is still healthy
-func (rsl *RSLoader) HealthCheck() error {
-	return rsl.rsBackend.HealthCheck()
-}
-
-//CreateManifestInBucket takes a load manifest, converts into json, and loads it into a provided s3 bucket
-func (rsl *RSLoader) CreateManifestInBucket(manifest *metadata.LoadManifest) (string, error) {
-	manifestJSON, err := makeManifestJSON(manifest)
+//ManifestURL returns the URL of the first of a load's manifests, the one CheckLoad looks for. With
+//regions, the bucket depends on the load's files, so it's empty once they're forgotten.
+func (rsl *RSLoader) ManifestURL(manifest *metadata.LoadManifest) (string, error) {
+	if rsl.regions.enabled() && len(manifest.Loads) == 0 {
+		return "", nil
+	}
+	loc, err := rsl.locate(manifest)
 	if err != nil {
 		return "", err
 	}
+	return manifestURL(loc.bucket, rsl.manifestConfig.KeyLayout.prefix(manifest), manifest.UUID), nil
+}
 
-	url := manifestURL(rsl.bucket, manifest.UUID)
-	_, err = rsl.s3Uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(rsl.bucket),
-		Key:    aws.String(manifest.UUID + ".json"),
-		Body:   bytes.NewReader(manifestJSON),
-	})
+//HealthCheck Checks to see if the connection to Redshift is still healthy
+func (rsl *RSLoader) HealthCheck() error {
+	return rsl.rsBackend.HealthCheck()
+}
 
-	if err != nil {
-		return "", err
+//createManifestsInBucket converts a load's manifests into json, and uploads them to the location's bucket
+//under the key layout's prefix, checking each one is there before the COPY. The first manifest's URL is
+//the one CheckLoad looks for, which works since all of the manifests are COPYed in one transaction,
+//unless they're chunked, when none of them has it.
+func (rsl *RSLoader) createManifestsInBucket(ctx context.Context, manifest *metadata.LoadManifest,
+	parts [][]manifestFile, loc manifestLocation, chunked bool) ([]string, error) {
+	prefix := rsl.manifestConfig.KeyLayout.prefix(manifest)
+	urls := make([]string, len(parts))
+	for i, part := range parts {
+		manifestJSON, err := makeManifestJSON(part)
+		if err != nil {
+			return nil, err
+		}
+		name := prefix + manifestName(manifest.UUID, i)
+		if chunked {
+			name = prefix + chunkManifestName(manifest.UUID, i)
+		}
+		if err = chaos.DelayUpload(ctx); err != nil {
+			return nil, err
+		}
+		err = rsl.uploadVerified(ctx, loc, name, manifestJSON)
+		if err != nil {
+			return nil, err
+		}
+		urls[i] = common.NormalizeS3URL(loc.bucket + "/" + name)
 	}
-
-	return url, err
+	return urls, nil
 }
 
-func makeManifestJSON(mani *metadata.LoadManifest) ([]byte, error) {
+func makeManifestJSON(files []manifestFile) ([]byte, error) {
 	m := manifest{}
-	for _, k := range mani.Loads {
+	for _, f := range files {
 		m.Entries = append(m.Entries,
-			entry{URL: common.NormalizeS3URL(k.KeyName),
+			entry{URL: common.NormalizeS3URL(f.key),
 				Mandatory: true},
 		)
 	}
@@ -107,6 +369,15 @@ func makeManifestJSON(mani *metadata.LoadManifest) ([]byte, error) {
 	return json.Marshal(m)
 }
 
-func manifestURL(bucketName, uuid string) string {
-	return common.NormalizeS3URL(bucketName + "/" + uuid + ".json")
+// manifestName is the key of a load's i-th manifest.
+func manifestName(uuid string, i int) string {
+	if i == 0 {
+		return uuid + ".json"
+	}
+	return fmt.Sprintf("%s-%d.json", uuid, i)
+}
+
+// manifestURL is the URL of the first of a load's manifests, under the prefix.
+func manifestURL(bucketName, prefix, uuid string) string {
+	return common.NormalizeS3URL(bucketName + "/" + prefix + manifestName(uuid, 0))
 }
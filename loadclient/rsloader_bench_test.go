@@ -0,0 +1,123 @@
+package loadclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+const benchManifestSize = 10000
+
+// discardUploader drains uploads without sending them anywhere.
+type discardUploader struct{}
+
+func (discardUploader) Upload(in *s3manager.UploadInput, _ ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	_, err := io.Copy(ioutil.Discard, in.Body)
+	return &s3manager.UploadOutput{}, err
+}
+
+// noopBackend pretends every COPY succeeds immediately.
+type noopBackend struct{}
+
+func (noopBackend) HealthCheck() error { return nil }
+func (noopBackend) LoadCheck(req *scoop_protocol.LoadCheckRequest) (*scoop_protocol.LoadCheckResponse, error) {
+	return &scoop_protocol.LoadCheckResponse{ManifestURL: req.ManifestURL, LoadStatus: scoop_protocol.LoadComplete}, nil
+}
+func (noopBackend) ManifestCopy(context.Context, string, []string, redshift.CopyOptions) (*backend.CopyStats, error) {
+	return &backend.CopyStats{}, nil
+}
+func (noopBackend) TableVersions() (map[string]int, error) { return nil, nil }
+func (noopBackend) ApplyOperations(string, []scoop_protocol.Operation, []scoop_protocol.ColumnDefinition, int, int) error {
+	return nil
+}
+func (noopBackend) CreateTable(string, []scoop_protocol.Operation, []scoop_protocol.ColumnDefinition, int) error {
+	return nil
+}
+func (noopBackend) TableExists(string) (bool, error)      { return true, nil }
+func (noopBackend) TableLocked(string) (bool, error)      { return false, nil }
+func (noopBackend) UnloadTable(string, string, int) error { return nil }
+func (noopBackend) DropTable(string) error                { return nil }
+func (noopBackend) RenameTable(string, string, int, []scoop_protocol.ColumnDefinition) error {
+	return nil
+}
+func (noopBackend) WaitUntilAvailable() {}
+func (noopBackend) TypeChangeProgress() []backend.TypeChangeProgress {
+	return nil
+}
+func (noopBackend) LiveColumns(string) ([]backend.LiveColumn, error) {
+	return nil, nil
+}
+func (noopBackend) CreateVersionedTable(string, int, []scoop_protocol.ColumnDefinition) error {
+	return nil
+}
+func (noopBackend) ChunkedManifestCopy(_ context.Context, _ string, manifestURLs []string, _ redshift.CopyOptions, _ int,
+	done func(int, *backend.CopyStats, error)) {
+	for i := range manifestURLs {
+		done(i, &backend.CopyStats{}, nil)
+	}
+}
+func (noopBackend) VersionedManifestCopy(context.Context, string, int, []string, redshift.CopyOptions) (*backend.CopyStats, error) {
+	return &backend.CopyStats{}, nil
+}
+func (noopBackend) UnionVersionedTables(string, []int) error     { return nil }
+func (noopBackend) MergeVersionedTable(string, int, []int) error { return nil }
+func (noopBackend) StragglerCopy(context.Context, string, string, []scoop_protocol.ColumnDefinition, []string,
+	redshift.CopyOptions) (*backend.CopyStats, error) {
+	return &backend.CopyStats{}, nil
+}
+
+func (noopBackend) PIICopy(context.Context, string, []scoop_protocol.ColumnDefinition, []string, redshift.CopyOptions,
+	backend.PIIColumns) (*backend.CopyStats, error) {
+	return &backend.CopyStats{}, nil
+}
+
+func (noopBackend) DedupCopy(context.Context, string, []string, []string, redshift.CopyOptions) (*backend.CopyStats, error) {
+	return &backend.CopyStats{}, nil
+}
+
+func benchManifest(n int) *metadata.LoadManifest {
+	m := &metadata.LoadManifest{TableName: "bench_table", UUID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}
+	for i := 0; i < n; i++ {
+		m.Loads = append(m.Loads, metadata.Load{
+			KeyName:   fmt.Sprintf("spade-compacter-bench/20170101/bench_table/v0/processor-%d.log.gz", i),
+			TableName: "bench_table",
+		})
+	}
+	return m
+}
+
+func BenchmarkMakeManifestJSON(b *testing.B) {
+	m := benchManifest(benchManifestSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := makeManifestJSON(manifestFiles(m)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadManifest(b *testing.B) {
+	m := benchManifest(benchManifestSize)
+	loader, err := NewRSLoader(discardUploader{}, nil, noopBackend{}, "bench-bucket", monitoring.NewMockStatter(), nil,
+		ManifestConfig{}, nil, nil, PreValidation{}, nil, nil, 0, StageTimeouts{}, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := loader.LoadManifest(context.Background(), m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
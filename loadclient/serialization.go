@@ -0,0 +1,48 @@
+package loadclient
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/lib"
+)
+
+// serializationAttempts is how many times a COPY aborted by a serializable isolation violation is run
+// before its load fails.
+const serializationAttempts = 3
+
+// serializationBackoff is how long to wait after the first aborted COPY; it doubles after each one, with
+// up to as much again of jitter so loads aborted by the same transaction don't collide again.
+var serializationBackoff = 2 * time.Second
+
+// retrySerializable runs the table's COPY, running it again right away, after a short backoff, if it's
+// aborted by a serializable isolation violation, e.g. with an ETL job writing the table at the same time.
+// The aborted transaction committed nothing, so it's safe to run again. Retries are counted in
+// manifest_load.serialization_retries, and COPYs still aborted after serializationAttempts in
+// manifest_load.serialization_exhausted; their load fails as ErrorSerialization.
+func (rsl *RSLoader) retrySerializable(ctx context.Context, table string,
+	copy func() (*backend.CopyStats, error)) (*backend.CopyStats, error) {
+	delay := serializationBackoff
+	var err error
+	for attempt := 1; attempt <= serializationAttempts; attempt++ {
+		if attempt > 1 {
+			logger.WithError(err).WithField("table", table).WithField("attempt", attempt).
+				Warn("Retrying COPY aborted by a serializable isolation violation")
+			lib.TableInc(rsl.stats, "manifest_load.serialization_retries", table, 1)
+			if err := sleepContext(ctx, delay+time.Duration(rand.Int63n(int64(delay)+1))); err != nil {
+				return nil, err
+			}
+			delay *= 2
+		}
+		var stats *backend.CopyStats
+		stats, err = copy()
+		if err == nil || Classify(err) != ErrorSerialization {
+			return stats, err
+		}
+	}
+	lib.TableInc(rsl.stats, "manifest_load.serialization_exhausted", table, 1)
+	return nil, err
+}
@@ -0,0 +1,59 @@
+package loadclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/backend"
+)
+
+func TestRetrySerializable(t *testing.T) {
+	defer func(backoff time.Duration) { serializationBackoff = backoff }(serializationBackoff)
+	serializationBackoff = 0
+	rsl := &RSLoader{stats: monitoring.NewMockStatter()}
+	violation := &pq.Error{Code: "XX000", Message: "1023", Detail: "Serializable isolation violation on table - 123"}
+
+	attempts := 0
+	stats, err := rsl.retrySerializable(context.Background(), "booking", func() (*backend.CopyStats, error) {
+		attempts++
+		if attempts < serializationAttempts {
+			return nil, violation
+		}
+		return &backend.CopyStats{RowsLoaded: 5}, nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(5), stats.RowsLoaded)
+	}
+	assert.Equal(t, serializationAttempts, attempts)
+
+	attempts = 0
+	_, err = rsl.retrySerializable(context.Background(), "booking", func() (*backend.CopyStats, error) {
+		attempts++
+		return nil, violation
+	})
+	assert.Equal(t, ErrorSerialization, Classify(err))
+	assert.Equal(t, serializationAttempts, attempts, "gives up after serializationAttempts")
+
+	attempts = 0
+	_, err = rsl.retrySerializable(context.Background(), "booking", func() (*backend.CopyStats, error) {
+		attempts++
+		return nil, errors.New("something else")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts, "other errors aren't retried")
+
+	attempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = rsl.retrySerializable(ctx, "booking", func() (*backend.CopyStats, error) {
+		attempts++
+		return nil, violation
+	})
+	assert.Equal(t, ErrorTimeout, Classify(err))
+	assert.Equal(t, 1, attempts, "canceled loads aren't retried")
+}
@@ -0,0 +1,111 @@
+package loadclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stages of a load, timed from when its first file was queued to when its COPY is committed.
+const (
+	// StageQueueWait is from when the load's oldest file was queued until a worker picked it up
+	StageQueueWait = "queue_wait"
+	// StageManifestUpload is writing the load's manifests and jsonpaths file to S3
+	StageManifestUpload = "manifest_upload"
+	// StageLockWait is waiting for other work on the table, e.g. a migration, to finish
+	StageLockWait = "lock_wait"
+	// StageCopy is running the COPY statements
+	StageCopy = "copy"
+	// StageCommit is committing the COPY
+	StageCommit = "commit"
+	// StageCommitQueue is the part of the commit spent waiting in Redshift's commit queue
+	StageCommitQueue = "commit_queue"
+	// StageCommitExecute is the part of the commit spent running once out of the queue
+	StageCommitExecute = "commit_execute"
+	// StageEndToEnd is from when the load's oldest file was queued until its COPY was committed
+	StageEndToEnd = "end_to_end"
+)
+
+// StageTimeouts bound how long a load's stages can take before it fails with ErrorTimeout, so a hung
+// S3 request or COPY doesn't hold up its worker forever. Zero values are unbounded.
+type StageTimeouts struct {
+	// Prepare bounds the S3 work before the COPY: looking up and compacting files, and uploading the
+	// manifests and jsonpaths file
+	Prepare time.Duration
+	// Copy bounds the COPY, from waiting for the table's lock to the commit. It also lowers the COPY's
+	// statement_timeout, so Redshift stops running a COPY the load has given up on
+	Copy time.Duration
+}
+
+// stageContext returns a context for a stage of a load that's done when ctx is, or once the timeout
+// passes if it's positive.
+func stageContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// sleepContext sleeps for d, returning ctx's error early if it's done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// awaitContext runs fn, returning ctx's error instead if it's done first, for S3 calls this SDK can't
+// cancel. fn is left to finish in the background, bounded by the HTTP client's own timeouts.
+func awaitContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TimeStage records how long a stage of a load into the table took, both for the table and in total.
+func TimeStage(stats monitoring.SafeStatter, table, stage string, d time.Duration) {
+	lib.TableTiming(stats, "manifest_load.stage."+stage, table, d)
+	stats.SafeTimingDuration("manifest_load.total.stage."+stage, d, 1.0)
+}
+
+// StartLoadSpan starts the span of a load, from start, which is the root of the load's own trace and
+// links to the spans the metadatastorer received each of its files in. The stages traced in the
+// returned context are its children.
+func StartLoadSpan(ctx context.Context, manifest *metadata.LoadManifest, start time.Time) (context.Context, trace.Span) {
+	var links []trace.Link
+	for _, load := range manifest.Loads {
+		if sc := lib.SpanContextOf(load.TraceParent); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+	return lib.Tracer().Start(ctx, "load", trace.WithNewRoot(), trace.WithTimestamp(start), trace.WithLinks(links...),
+		trace.WithAttributes(attribute.String("table", manifest.TableName), attribute.String("load_uuid", manifest.UUID),
+			attribute.Int("version", manifest.Version), attribute.Int("files", len(manifest.Loads))))
+}
+
+// TraceStage records a span of a stage of the load traced in ctx, which started at start and took d.
+// Stages are traced once they're over, from the durations they're timed with.
+func TraceStage(ctx context.Context, stage string, start time.Time, d time.Duration) {
+	_, span := lib.Tracer().Start(ctx, "load."+stage, trace.WithTimestamp(start))
+	span.End(trace.WithTimestamp(start.Add(d)))
+}
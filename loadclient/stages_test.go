@@ -0,0 +1,57 @@
+package loadclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoadSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	// The spans the metadatastorer received two of the files in; the third file wasn't traced
+	fileCtx, fileSpan := lib.Tracer().Start(context.Background(), "sqs.receive")
+	fileSpan.End()
+	otherCtx, otherSpan := lib.Tracer().Start(context.Background(), "sqs.receive")
+	otherSpan.End()
+	manifest := &metadata.LoadManifest{TableName: "video", UUID: "uuid", Version: 3, Loads: []metadata.Load{
+		{KeyName: "a.gz", TraceParent: lib.TraceParent(fileCtx)},
+		{KeyName: "b.gz", TraceParent: lib.TraceParent(otherCtx)},
+		{KeyName: "c.gz"},
+	}}
+
+	queued := time.Now().Add(-time.Hour)
+	ctx, span := StartLoadSpan(fileCtx, manifest, queued)
+	TraceStage(ctx, StageQueueWait, queued, 50*time.Minute)
+	span.End()
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 4) {
+		return
+	}
+	stage, load := spans[2], spans[3]
+	assert.Equal(t, "load", load.Name())
+	assert.False(t, load.Parent().IsValid(), "each load is the root of its own trace")
+	assert.NotEqual(t, fileSpan.SpanContext().TraceID(), load.SpanContext().TraceID())
+	assert.Equal(t, queued, load.StartTime())
+	if assert.Len(t, load.Links(), 2) {
+		assert.Equal(t, fileSpan.SpanContext().SpanID(), load.Links()[0].SpanContext.SpanID())
+		assert.Equal(t, otherSpan.SpanContext().SpanID(), load.Links()[1].SpanContext.SpanID())
+	}
+
+	assert.Equal(t, "load.queue_wait", stage.Name())
+	assert.Equal(t, trace.SpanContextFromContext(ctx).SpanID(), stage.Parent().SpanID())
+	assert.Equal(t, queued, stage.StartTime())
+	assert.Equal(t, queued.Add(50*time.Minute), stage.EndTime())
+}
@@ -0,0 +1,107 @@
+package loadclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// uploadAttempts is how many times a manifest or jsonpaths file is uploaded before the load fails.
+const uploadAttempts = 4
+
+// uploadBackoff is how long to wait after the first failed upload; it doubles after each one.
+var uploadBackoff = time.Second
+
+// uploadVerified uploads body to key in the location's bucket, encrypted as configured, and reads it
+// back, so the COPY doesn't fail to find it or read a corrupted copy. Failed uploads and checks are retried with
+// exponential backoff, until ctx is done. The check is skipped if the location has no S3 client.
+func (rsl *RSLoader) uploadVerified(ctx context.Context, loc manifestLocation, key string, body []byte) error {
+	sum := md5.Sum(body)
+	checksum := hex.EncodeToString(sum[:])
+	delay := uploadBackoff
+	var err error
+	for attempt := 1; attempt <= uploadAttempts; attempt++ {
+		if attempt > 1 {
+			logger.WithError(err).WithField("bucket", loc.bucket).WithField("key", key).
+				WithField("attempt", attempt).Warn("Retrying upload")
+			rsl.stats.SafeInc("upload.retry", 1, 1.0)
+			if serr := sleepContext(ctx, delay); serr != nil {
+				return fmt.Errorf("uploading %s: %v", key, serr)
+			}
+			delay *= 2
+		}
+		input := &s3manager.UploadInput{
+			Bucket:   aws.String(loc.bucket),
+			Key:      aws.String(key),
+			Body:     bytes.NewReader(body),
+			Metadata: map[string]*string{"md5": aws.String(checksum)},
+		}
+		if sse := rsl.manifestConfig.UploadEncryption; sse.ServerSideEncryption != "" {
+			input.ServerSideEncryption = aws.String(sse.ServerSideEncryption)
+			if sse.KMSKeyID != "" {
+				input.SSEKMSKeyId = aws.String(sse.KMSKeyID)
+			}
+		}
+		err = awaitContext(ctx, func() error {
+			_, uerr := loc.uploader.Upload(input)
+			return uerr
+		})
+		if err != nil {
+			err = fmt.Errorf("uploading %s: %v", key, err)
+			continue
+		}
+		if loc.s3 == nil {
+			return nil
+		}
+		err = awaitContext(ctx, func() error { return verifyUpload(loc, key, checksum, int64(len(body))) })
+		if err == nil {
+			return nil
+		}
+	}
+	rsl.stats.SafeInc("upload.failed", 1, 1.0)
+	return err
+}
+
+// verifyUpload checks the object's size and MD5. A single-part upload's ETag is its MD5 unless the
+// bucket encrypts it with KMS, in which case the object is read back to check it.
+func verifyUpload(loc manifestLocation, key string, checksum string, size int64) error {
+	head, err := loc.s3.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(loc.bucket), Key: aws.String(key)})
+	if err != nil {
+		return fmt.Errorf("checking upload of %s: %v", key, err)
+	}
+	if aws.Int64Value(head.ContentLength) != size {
+		return fmt.Errorf("uploaded %s has %d bytes, expected %d", key, aws.Int64Value(head.ContentLength), size)
+	}
+	if strings.Trim(aws.StringValue(head.ETag), `"`) == checksum {
+		return nil
+	}
+
+	out, err := loc.s3.GetObject(&s3.GetObjectInput{Bucket: aws.String(loc.bucket), Key: aws.String(key)})
+	if err != nil {
+		return fmt.Errorf("reading back %s: %v", key, err)
+	}
+	defer func() {
+		if cerr := out.Body.Close(); cerr != nil {
+			logger.WithError(cerr).WithField("key", key).Error("Error closing uploaded object")
+		}
+	}()
+	uploaded, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return fmt.Errorf("reading back %s: %v", key, err)
+	}
+	sum := md5.Sum(uploaded)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return fmt.Errorf("uploaded %s doesn't match its MD5 %s", key, checksum)
+	}
+	return nil
+}
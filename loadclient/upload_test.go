@@ -0,0 +1,86 @@
+package loadclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+// flakyS3 stores uploads after failing the first failures of them. If kms is set, ETags aren't
+// MD5s, and if corrupt is set, what's read back differs from what was uploaded.
+type flakyS3 struct {
+	s3iface.S3API
+	failures int
+	kms      bool
+	corrupt  bool
+	uploads  int
+	objects  map[string][]byte
+}
+
+func (f *flakyS3) Upload(in *s3manager.UploadInput, _ ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	f.uploads++
+	if f.uploads <= f.failures {
+		return nil, errors.New("connection reset")
+	}
+	body, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.StringValue(in.Key)] = body
+	return &s3manager.UploadOutput{}, nil
+}
+
+func (f *flakyS3) HeadObject(in *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	body := f.objects[aws.StringValue(in.Key)]
+	sum := md5.Sum(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	if f.kms {
+		etag = `"kms"`
+	}
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(body))), ETag: aws.String(etag)}, nil
+}
+
+func (f *flakyS3) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	body := append([]byte{}, f.objects[aws.StringValue(in.Key)]...)
+	if f.corrupt {
+		body[0] = '!'
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestUploadVerified(t *testing.T) {
+	uploadBackoff = 0
+	rsl := &RSLoader{stats: monitoring.NewMockStatter()}
+	body := []byte(`{"entries":[]}`)
+
+	fake := &flakyS3{failures: 2, objects: make(map[string][]byte)}
+	loc := manifestLocation{bucket: "bucket", s3: fake, uploader: fake}
+	assert.NoError(t, rsl.uploadVerified(context.Background(), loc, "m.json", body))
+	assert.Equal(t, 3, fake.uploads)
+	assert.Equal(t, body, fake.objects["m.json"])
+
+	fake = &flakyS3{kms: true, objects: make(map[string][]byte)}
+	loc = manifestLocation{bucket: "bucket", s3: fake, uploader: fake}
+	assert.NoError(t, rsl.uploadVerified(context.Background(), loc, "m.json", body))
+	assert.Equal(t, 1, fake.uploads)
+
+	fake = &flakyS3{kms: true, corrupt: true, objects: make(map[string][]byte)}
+	loc = manifestLocation{bucket: "bucket", s3: fake, uploader: fake}
+	assert.Error(t, rsl.uploadVerified(context.Background(), loc, "m.json", body))
+	assert.Equal(t, uploadAttempts, fake.uploads)
+
+	fake = &flakyS3{failures: uploadAttempts, objects: make(map[string][]byte)}
+	loc = manifestLocation{bucket: "bucket", uploader: fake}
+	assert.Error(t, rsl.uploadVerified(context.Background(), loc, "m.json", body))
+}
@@ -0,0 +1,80 @@
+/*
+Loadgen fills a test ingesterdb with fake TSV rows, as metadatastorer would, so that
+rsloadmanager's claiming and loading can be exercised at scale. Never point it at production.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+var (
+	pgConfig     metadata.PGConfig
+	numFiles     int
+	numTables    int
+	tableVersion int
+	keyPrefix    string
+	cpuProfile   string
+)
+
+func init() {
+	flag.StringVar(&pgConfig.DatabaseURL, "databaseURL", "", "Postgres-scheme url for the test ingesterdb")
+	flag.IntVar(&pgConfig.MaxConnections, "maxDBConnections", 5, "Max number of database connections to open")
+	flag.IntVar(&numFiles, "files", 10000, "Number of TSV rows to insert per table")
+	flag.IntVar(&numTables, "tables", 1, "Number of tables to spread TSV rows over, named loadgen_<n>")
+	flag.IntVar(&tableVersion, "tableVersion", 0, "Table version of the generated TSVs")
+	flag.StringVar(&keyPrefix, "keyPrefix", "loadgen", "S3 key prefix of the generated TSVs")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile to this file")
+}
+
+func main() {
+	flag.Parse()
+	logger.Init("info")
+	defer logger.LogPanic()
+
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error creating CPU profile")
+		}
+		if err = pprof.StartCPUProfile(f); err != nil {
+			logger.WithError(err).Fatal("Error starting CPU profile")
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	storer, err := metadata.NewPostgresStorer(&pgConfig)
+	if err != nil {
+		logger.WithError(err).Fatal("Error initializing PostgresStorer")
+	}
+	defer storer.Close()
+
+	start := time.Now()
+	for t := 0; t < numTables; t++ {
+		table := fmt.Sprintf("loadgen_%d", t)
+		for i := 0; i < numFiles; i++ {
+			err = storer.InsertLoad(&metadata.Load{
+				KeyName:      fmt.Sprintf("%s/%s/v%d/processor-%d.log.gz", keyPrefix, table, tableVersion, i),
+				TableName:    table,
+				TableVersion: tableVersion,
+			})
+			// Keys from an earlier run with the same prefix are still remembered
+			if err != nil && err != metadata.ErrDuplicateLoad {
+				logger.WithError(err).WithField("table", table).Fatal("Error inserting TSV")
+			}
+		}
+	}
+	elapsed := time.Since(start)
+	logger.WithField("files", numFiles*numTables).
+		WithField("elapsed", elapsed).
+		WithField("filesPerSecond", float64(numFiles*numTables)/elapsed.Seconds()).
+		Info("Inserted TSVs")
+	logger.Wait()
+}
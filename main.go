@@ -11,6 +11,9 @@ instance.
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -20,22 +23,40 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/twitchscience/aws_utils/logger"
 	"github.com/twitchscience/aws_utils/monitoring"
 	"github.com/twitchscience/rs_ingester/blueprint"
+	"github.com/twitchscience/rs_ingester/chaos"
 	"github.com/twitchscience/rs_ingester/control"
+	"github.com/twitchscience/rs_ingester/gaps"
 	"github.com/twitchscience/rs_ingester/migrator"
+	"github.com/twitchscience/rs_ingester/quality"
+	"github.com/twitchscience/rs_ingester/redshift"
+	"github.com/twitchscience/rs_ingester/retention"
+	"github.com/twitchscience/rs_ingester/schedule"
+	"github.com/twitchscience/rs_ingester/slo"
 	"github.com/twitchscience/rs_ingester/versions"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/twitchscience/rs_ingester/backend"
 	"github.com/twitchscience/rs_ingester/healthcheck"
+	"github.com/twitchscience/rs_ingester/lib"
 	"github.com/twitchscience/rs_ingester/loadclient"
 	"github.com/twitchscience/rs_ingester/metadata"
 	"github.com/twitchscience/rs_ingester/reporter"
@@ -48,100 +69,664 @@ const (
 var (
 	poolSize                  int
 	statsPrefix               string
+	statsBackend              string
+	statsTags                 string
+	statsNaming               lib.StatsNaming
 	manifestBucket            string
 	rollbarToken              string
 	rollbarEnvironment        string
 	blueprintHost             string
+	blueprintCacheTTL         time.Duration
 	pgConfig                  metadata.PGConfig
 	loadAgeSeconds            int
 	workerGroup               sync.WaitGroup
+	drainTimeout              time.Duration
+	inFlight                  = inFlightLoads{loads: make(map[string]string)}
 	waitProcessorPeriod       time.Duration
 	migratorPollPeriod        time.Duration
 	reporterPollPeriod        time.Duration
+	utilizationPeriod         time.Duration
+	errorTrendPeriod          time.Duration
+	includeTables             string
+	excludeTables             string
 	offpeakStartHour          int
 	offpeakDurationHours      int
 	onpeakMigrationTimeoutMs  int
 	offpeakMigrationTimeoutMs int
+	dropSnapshotPrefix        string
+	forceDrift                bool
+	versionedTables           bool
+	copyTimeoutMs             int
+	loadTimeout               time.Duration
+	loadEventRetention        time.Duration
+	stageTimeouts             loadclient.StageTimeouts
+	maxConcurrentMigrations   int
 	configFilename            string
+	controlMigratorTimeout    time.Duration
+	versionRefreshPeriod      time.Duration
+	dryRun                    bool
+	controlAddr               string
+	controlGRPCAddr           string
+	pprofAddr                 string
+	controlAuthToken          string
+	tlsConfig                 lib.TLSConfig
+	tracingConfig             lib.TracingConfig
+	maxQueueAge               time.Duration
+	maxMigratorIdle           time.Duration
+	migratorStuckThreshold    time.Duration
+	maxIngesterDBLatency      time.Duration
+	healthSQSQueue            string
+	healthCheckTimeout        time.Duration
+	runningWorkers            int32
+	busyWorkers               int32
+	breakerConfig             redshift.BreakerConfig
+	keepaliveConfig           redshift.KeepaliveConfig
+	targetSchema              string
+	redshiftTransport         string
+	bpConfigsBucket           string
+	bpMetadataConfigsKey      string
+	bpMetadataReloadFrequency time.Duration
+	compressionAnalysisPeriod time.Duration
+	compressionMinRows        int64
+	retentionConfig           retention.Config
+	sloConfig                 slo.Config
+	scheduleConfig            schedule.Config
+	gapConfig                 gaps.Config
+	manifestConfig            loadclient.ManifestConfig
+	manifestChunkMode         string
+	manifestKeyLayout         string
+	manifestEnvironment       string
+	copyRoleARN               string
+	copyRoleExternalID        string
+	copyRoleDuration          time.Duration
+	preValidation             loadclient.PreValidation
+	governorConfig            loadclient.GovernorConfig
+	qualityConfig             quality.Config
+	versionPushPeriod         time.Duration
 )
 
 type loadWorker struct {
 	MetadataBackend metadata.Backend
 	Loader          loadclient.Loader
+	AceBackend      backend.Backend
+	Governor        *loadclient.Governor
+	// Quality aggregates the loads' data-quality anomalies; if nil, they're logged one by one
+	Quality *quality.Reporter
+	// stop stops the worker once it's done with its current load, when the pool shrinks
+	stop chan struct{}
+	// number is the worker's place in the pool, and name identifies it in load events
+	number int
+	name   string
 }
 
 func (i *loadWorker) Work(stats monitoring.SafeStatter) {
+	atomic.AddInt32(&runningWorkers, 1)
+	defer atomic.AddInt32(&runningWorkers, -1)
 
+	i.name = workerName(i.number)
 	c := i.MetadataBackend.LoadReady()
-	for load := range c {
+	for {
+		load, ok := i.next(c)
+		if !ok {
+			break
+		}
+		if chaos.KillWorker() {
+			// The worker stops with the load still assigned to it, as if it crashed; the health check
+			// fails since fewer workers are running than the pool has
+			logger.WithField("loadUUID", load.UUID).WithField("worker", i.name).
+				Warn("Fault injection killed worker mid-load")
+			break
+		}
+		i.recordEvent(stats, load, metadata.EventAssigned, "")
+		// Hold the load while Redshift is down instead of failing it
+		i.AceBackend.WaitUntilAvailable()
+		// Defer the COPY while the table's being loaded or the cluster is saturated
+		i.Governor.Acquire(load.TableName)
+		i.recordEvent(stats, load, metadata.EventAdmitted, "")
+		oldestQueued := oldestQueueTime(load)
+		spanCtx, span := loadclient.StartLoadSpan(context.Background(), load, oldestQueued)
+		if !oldestQueued.IsZero() {
+			queueWait := time.Since(oldestQueued)
+			loadclient.TimeStage(stats, load.TableName, loadclient.StageQueueWait, queueWait)
+			loadclient.TraceStage(spanCtx, loadclient.StageQueueWait, oldestQueued, queueWait)
+		}
 		logfields := logger.WithField("loadUUID", load.UUID).
 			WithField("numFiles", len(load.Loads)).
 			WithField("table", load.TableName)
 		logfields.Info("Loading manifest into table")
-		err := i.Loader.LoadManifest(load)
+		atomic.AddInt32(&busyWorkers, 1)
+		inFlight.add(load)
+		ctx, cancel := loadContext(spanCtx)
+		loadStats, err := i.Loader.LoadManifest(ctx, load)
+		cancel()
+		inFlight.remove(load)
+		atomic.AddInt32(&busyWorkers, -1)
+		i.Governor.Release(load.TableName)
 		if err != nil {
+			class := err.Class()
+			logfields = logfields.WithField("annotations", i.annotationNotes(load)).
+				WithError(err).WithField("retryable", err.Retryable()).WithField("errorClass", class)
 			if err.Retryable() {
-				i.MetadataBackend.LoadError(load.UUID, err.Error())
-				logfields.WithError(err).WithField("retryable", err.Retryable()).
-					Warning("Error loading files into table.")
+				i.MetadataBackend.LoadError(load.UUID, err.Error(), string(class), err.RetryDelay())
+				i.recordEvent(stats, load, metadata.EventFailed, "retried: "+err.Error())
 			} else {
-				logfields.WithError(err).WithField("retryable", err.Retryable()).
-					Error("Error loading files into table.")
+				i.recordEvent(stats, load, metadata.EventFailed, "not retried: "+err.Error())
+			}
+			if !err.Retryable() || class.Policy().Alert {
+				logfields.Error("Error loading files into table.")
+			} else {
+				logfields.Warning("Error loading files into table.")
 			}
 			stats.SafeInc("manifest_load.failures", 1, 1.0)
+			stats.SafeInc("manifest_load.total.errors."+string(class), 1, 1.0)
+			lib.TableInc(stats, "manifest_load.errors."+string(class), load.TableName, 1)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, string(class))
+			span.End()
 			continue
 		}
-		logfields.Info("Loaded manifest into table")
-		i.MetadataBackend.LoadDone(load.UUID, load.TableName)
+		if !oldestQueued.IsZero() {
+			endToEnd := time.Since(oldestQueued)
+			loadclient.TimeStage(stats, load.TableName, loadclient.StageEndToEnd, endToEnd)
+			logfields = logfields.WithField("endToEnd", endToEnd)
+		}
+		if loadStats.Simulated {
+			logfields.Info("Simulated loading manifest into table")
+		} else {
+			logfields.WithField("rowsLoaded", loadStats.RowsLoaded).WithField("bytesScanned", loadStats.BytesScanned).
+				Info("Loaded manifest into table")
+		}
+		i.MetadataBackend.LoadDone(load.UUID, load.TableName, loadStats)
+		if loadStats.Simulated {
+			i.recordEvent(stats, load, metadata.EventSucceeded, "simulated")
+		} else {
+			i.recordEvent(stats, load, metadata.EventSucceeded, fmt.Sprintf("%d rows loaded", loadStats.RowsLoaded))
+		}
+		if loadStats.RowMismatch() {
+			if i.Quality != nil {
+				i.Quality.Report(quality.Anomaly{Table: load.TableName, Kind: quality.RowMismatch,
+					Count: rowsOff(loadStats), Detail: fmt.Sprintf("load %s loaded %d rows, of %d lines and %d expected rows",
+						load.UUID, loadStats.RowsLoaded, loadStats.LinesScanned, loadStats.ExpectedRows.Int64)})
+			} else {
+				logfields.WithField("rowsLoaded", loadStats.RowsLoaded).WithField("linesScanned", loadStats.LinesScanned).
+					WithField("expectedRows", loadStats.ExpectedRows.Int64).WithField("expectedRowsKnown", loadStats.ExpectedRows.Valid).
+					Warn("Rows loaded don't match the rows expected")
+			}
+			lib.TableInc(stats, "manifest_load.row_mismatch", load.TableName, 1)
+			stats.SafeInc("manifest_load.total.row_mismatch", 1, 1.0)
+		}
+		if i.Quality != nil && load.Straggler != "" && !loadStats.Simulated {
+			i.Quality.Report(quality.Anomaly{Table: load.TableName, Kind: quality.Straggler, Count: int64(len(load.Loads)),
+				Detail: fmt.Sprintf("load %s of version %d, as %s", load.UUID, load.Version, load.Straggler)})
+		}
 
 		stats.SafeInc("manifest_load.count", 1, 1.0)
-		statsdPattern := "tsv_files.%s.loaded"
-		stats.SafeInc(fmt.Sprintf(statsdPattern, load.TableName), int64(len(load.Loads)), 1.0)
-		stats.SafeInc(fmt.Sprintf(statsdPattern, "total"), int64(len(load.Loads)), 1.0)
+		lib.TableInc(stats, "manifest_load.rows_loaded", load.TableName, loadStats.RowsLoaded)
+		lib.TableInc(stats, "manifest_load.bytes_scanned", load.TableName, loadStats.BytesScanned)
+		lib.TableGauge(stats, "manifest_load.last_rows_loaded", load.TableName, loadStats.RowsLoaded)
+		lib.TableGauge(stats, "manifest_load.last_bytes_scanned", load.TableName, loadStats.BytesScanned)
+		lib.TableInc(stats, "tsv_files.loaded", load.TableName, int64(len(load.Loads)))
+		stats.SafeInc("tsv_files.total.loaded", int64(len(load.Loads)), 1.0)
+		span.SetAttributes(attribute.Int64("rows_loaded", loadStats.RowsLoaded))
+		span.End()
 	}
 	workerGroup.Done()
 }
 
-func startWorkers(s3Uploader s3manageriface.UploaderAPI, b metadata.Backend, stats monitoring.SafeStatter, aceBackend backend.Backend) ([]loadWorker, error) {
-	workers := make([]loadWorker, poolSize)
-	for i := 0; i < poolSize; i++ {
-		loadclient, err := loadclient.NewRSLoader(s3Uploader, aceBackend, manifestBucket, stats)
+// loadContext returns the context a worker loads a manifest with, from ctx, which times out after
+// loadTimeout if it's set.
+func loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if loadTimeout > 0 {
+		return context.WithTimeout(ctx, loadTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// recordEvent records the load's state transition in the worker. Failures to record are logged and
+// counted in load_events.record_errors, but don't hold up the load.
+func (i *loadWorker) recordEvent(stats monitoring.SafeStatter, load *metadata.LoadManifest,
+	state metadata.LoadEventState, detail string) {
+	err := i.MetadataBackend.RecordLoadEvent(metadata.LoadEvent{
+		Time:     time.Now().In(time.UTC),
+		LoadUUID: load.UUID,
+		Table:    load.TableName,
+		Worker:   i.name,
+		State:    state,
+		Detail:   detail,
+	})
+	if err != nil {
+		logger.WithError(err).WithField("loadUUID", load.UUID).WithField("state", state).
+			Error("Error recording load event")
+		stats.SafeInc("load_events.record_errors", 1, 1.0)
+	}
+}
+
+// workerName identifies the worker of the number running in the calling goroutine, as
+// <host>/<number>/<goroutine ID>.
+func workerName(number int) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d/%d", host, number, goroutineID())
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the "goroutine <id> [running]:"
+// line starting its stack trace, or 0 if it can't be.
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	fields := bytes.Fields(buf[:runtime.Stack(buf, false)])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// next returns the next load to run, or false once there are no more or the worker is stopped.
+func (i *loadWorker) next(c chan *metadata.LoadManifest) (*metadata.LoadManifest, bool) {
+	select {
+	case load, ok := <-c:
+		return load, ok
+	case <-i.stop:
+		return nil, false
+	}
+}
+
+// inFlightLoads tracks the manifests being loaded by the workers, so their COPYs can be canceled
+// on shutdown.
+type inFlightLoads struct {
+	lock  sync.Mutex
+	loads map[string]string // manifest UUID to table
+}
+
+func (f *inFlightLoads) add(load *metadata.LoadManifest) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.loads[load.UUID] = load.TableName
+}
+
+func (f *inFlightLoads) remove(load *metadata.LoadManifest) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.loads, load.UUID)
+}
+
+func (f *inFlightLoads) snapshot() map[string]string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	loads := make(map[string]string, len(f.loads))
+	for uuid, table := range f.loads {
+		loads[uuid] = table
+	}
+	return loads
+}
+
+// waitForWorkers waits up to timeout for the workers to finish, returning whether they did. A
+// timeout of 0 waits indefinitely.
+func waitForWorkers(timeout time.Duration) bool {
+	done := make(chan struct{})
+	logger.Go(func() {
+		workerGroup.Wait()
+		close(done)
+	})
+	if timeout == 0 {
+		<-done
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// serveGRPCControl serves the gRPC control service on controlGRPCAddr, over TLS with the control
+// endpoints' certificate if it's configured, authenticated like the control endpoints. A client CA
+// requires client certificates, as every gRPC call is a control call.
+func serveGRPCControl(controlBackend *control.Backend, auth control.AuthConfig, stats monitoring.SafeStatter) {
+	var creds credentials.TransportCredentials
+	if tlsConfig.Enabled() {
+		serverTLS, err := tlsConfig.ServerConfig()
+		if err != nil {
+			logger.WithError(err).Fatal("Error configuring TLS of gRPC control")
+		}
+		if tlsConfig.ClientCAFile != "" {
+			serverTLS.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		creds = credentials.NewTLS(serverTLS)
+	}
+	listener, err := net.Listen("tcp", controlGRPCAddr)
+	if err != nil {
+		logger.WithError(err).Fatal("Error listening for gRPC control")
+	}
+	server, err := control.NewGRPCService(control.NewGRPCServer(controlBackend, stats), auth, creds)
+	if err != nil {
+		logger.WithError(err).Fatal("Error configuring gRPC control")
+	}
+	logger.Go(func() {
+		logger.WithError(server.Serve(listener)).Fatal("Serving gRPC control failed")
+	})
+}
+
+// drainWorkers waits for the workers to finish their loads once no more are handed out. Loads still
+// running after drainTimeout have their COPYs canceled, so they fail and are marked for retry, and
+// are waited for again. A load that still hasn't finished keeps its claim, and on the next startup
+// its status is checked in Redshift like any orphaned load's.
+func drainWorkers(canceler control.LoadCanceler, stats monitoring.SafeStatter) {
+	if waitForWorkers(drainTimeout) {
+		return
+	}
+	for uuid, table := range inFlight.snapshot() {
+		fields := logger.WithField("loadUUID", uuid).WithField("table", table)
+		canceled, err := canceler.CancelLoad(uuid)
+		if err != nil {
+			fields.WithError(err).Error("Error canceling load on shutdown")
+			continue
+		}
+		fields.WithField("canceled", canceled).Warn("Canceled load still running on shutdown")
+		stats.SafeInc("manifest_load.canceled_on_shutdown", 1, 1.0)
+	}
+	if waitForWorkers(drainTimeout) {
+		return
+	}
+	for uuid, table := range inFlight.snapshot() {
+		logger.WithField("loadUUID", uuid).WithField("table", table).
+			Warn("Exiting with load unfinished; its status will be checked on startup")
+	}
+}
+
+// annotationNotes returns the operator notes on the load and its table, so that they travel
+// with alerts about it.
+func (i *loadWorker) annotationNotes(load *metadata.LoadManifest) []string {
+	annotations, err := i.MetadataBackend.Annotations(load.TableName)
+	if err != nil {
+		logger.WithError(err).WithField("table", load.TableName).Warn("Error fetching annotations")
+		return nil
+	}
+	var notes []string
+	for _, a := range annotations {
+		if a.LoadUUID == "" || a.LoadUUID == load.UUID {
+			notes = append(notes, fmt.Sprintf("%s (%s)", a.Note, a.Author))
+		}
+	}
+	return notes
+}
+
+// analyzeCompression runs ANALYZE COMPRESSION on the large tables every period.
+func analyzeCompression(aceBackend *backend.RedshiftBackend, period time.Duration, minRows int64) {
+	tick := time.NewTicker(period)
+	defer tick.Stop()
+	for range tick.C {
+		err := aceBackend.AnalyzeCompression(minRows)
+		if err != nil {
+			logger.WithError(err).Error("Error analyzing compression")
+		}
+	}
+}
+
+// pruneLoadEvents hourly deletes the load events older than maxAge.
+func pruneLoadEvents(b metadata.Backend, maxAge time.Duration) {
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
+	for range tick.C {
+		pruned, err := b.PruneLoadEvents(time.Now().In(time.UTC).Add(-maxAge))
+		if err != nil {
+			logger.WithError(err).Error("Error pruning load events")
+			continue
+		}
+		logger.WithField("pruned", pruned).Info("Pruned load events")
+	}
+}
+
+// rowsOff returns how many rows a load with a row mismatch loaded more or fewer than expected, or
+// than the lines it read if the rows expected aren't known.
+func rowsOff(loadStats *metadata.LoadStats) int64 {
+	expected := loadStats.LinesScanned
+	if loadStats.ExpectedRows.Valid {
+		expected = loadStats.ExpectedRows.Int64
+	}
+	if off := loadStats.RowsLoaded - expected; off > 0 {
+		return off
+	}
+	return expected - loadStats.RowsLoaded
+}
+
+// oldestQueueTime returns when the load's oldest file was queued, or the zero time if unknown.
+func oldestQueueTime(load *metadata.LoadManifest) time.Time {
+	var oldest time.Time
+	for _, queued := range load.Queued {
+		if oldest.IsZero() || queued.Before(oldest) {
+			oldest = queued
+		}
+	}
+	return oldest
+}
+
+// workerPool runs the load workers, and can be resized while they run. Workers removed from the
+// pool finish their current load first.
+type workerPool struct {
+	lock      sync.Mutex
+	stops     []chan struct{}
+	newWorker func(number int, stop chan struct{}) (*loadWorker, error)
+	stats     monitoring.SafeStatter
+}
+
+func newWorkerPool(s3Uploader s3manageriface.UploaderAPI, s3Client s3iface.S3API, b metadata.Backend,
+	stats monitoring.SafeStatter, aceBackend backend.Backend, schemas loadclient.SchemaGetter,
+	regions *loadclient.Regions, encryption *loadclient.Encryption, governor *loadclient.Governor,
+	qualityReporter *quality.Reporter, pii loadclient.PIISource) *workerPool {
+	return &workerPool{
+		newWorker: func(number int, stop chan struct{}) (*loadWorker, error) {
+			loadclient, err := loadclient.NewRSLoader(s3Uploader, s3Client, aceBackend, manifestBucket, stats, schemas,
+				manifestConfig, regions, encryption, preValidation, b, pii, copyTimeoutMs, stageTimeouts, dryRun)
+			if err != nil {
+				return nil, err
+			}
+			return &loadWorker{MetadataBackend: b, Loader: loadclient, AceBackend: aceBackend, Governor: governor,
+				Quality: qualityReporter, stop: stop, number: number}, nil
+		},
+		stats: stats,
+	}
+}
+
+// Resize starts or stops workers until there are size of them.
+func (p *workerPool) Resize(size int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for len(p.stops) < size {
+		stop := make(chan struct{})
+		worker, err := p.newWorker(len(p.stops), stop)
 		if err != nil {
-			return workers, err
+			return fmt.Errorf("starting load worker: %v", err)
 		}
-		workers[i] = loadWorker{MetadataBackend: b, Loader: loadclient}
+		p.stops = append(p.stops, stop)
 		workerGroup.Add(1)
-		index := i
 		logger.Go(func() {
-			workers[index].Work(stats)
+			worker.Work(p.stats)
 		})
 	}
-	return workers, nil
+	for len(p.stops) > size {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+	return nil
+}
+
+// Size returns how many workers the pool has, not counting stopped ones finishing their load. A nil
+// pool, of a loader without workers, has none.
+func (p *workerPool) Size() int {
+	if p == nil {
+		return 0
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.stops)
 }
 
 func init() {
 	flag.DurationVar(&migratorPollPeriod, "migratorPollPeriod", time.Minute, "the period betwen each poll the migrator does of ingesterdb for new versions to migrate to")
 	flag.DurationVar(&reporterPollPeriod, "reporterPollPeriod", time.Minute, "the period betwen each poll the reporter does of ingesterdb to query current stats")
+	flag.DurationVar(&utilizationPeriod, "utilizationPeriod", 10*time.Second, "the period between each report of Redshift connection pool and worker utilization; 0 to disable")
+	flag.DurationVar(&errorTrendPeriod, "errorTrendPeriod", time.Minute, "the period between each report of tables' load errors in the last hour and whether they spiked; 0 to disable")
 	flag.DurationVar(&waitProcessorPeriod, "waitProcessorPeriod", time.Minute*3, "the period we wait for processor to process all old version TSVs")
 	flag.StringVar(&statsPrefix, "statsPrefix", "ingester", "the prefix to statsd")
+	flag.StringVar(&statsBackend, "statsBackend", lib.StatsdBackend, "the stats backend, statsd or dogstatsd; dogstatsd tags per-table stats with the table instead of naming them after it")
+	flag.StringVar(&statsTags, "statsTags", "", "comma separated key:value tags added to every stat, e.g. cluster:science,environment:production; dogstatsd only")
+	flag.StringVar(&statsNaming.Environment, "statsEnvironment", "", "If set, environment plain statsd stats are namespaced under after the prefix, and dogstatsd stats tagged with, e.g. production")
+	flag.StringVar(&statsNaming.Cluster, "statsCluster", "", "If set, cluster plain statsd stats are namespaced under after the environment, and dogstatsd stats tagged with, e.g. science")
+	flag.BoolVar(&statsNaming.Legacy, "statsLegacyNames", false, "Also send plain statsd stats under their names without the namespace and with tables unsanitized, while dashboards move to the new names")
 	flag.StringVar(&pgConfig.DatabaseURL, "databaseURL", "", "Postgres-scheme url for the RDS instance")
 	flag.StringVar(&manifestBucket, "manifestBucket", "", "S3 bucket for manifests.")
 	flag.IntVar(&pgConfig.MaxConnections, "maxDBConnections", 5, "Number of database connections to open")
 	flag.IntVar(&pgConfig.LoadCountTrigger, "loadCountTrigger", 5, "Number of queued tsvs before a load into redshift is triggered")
+	flag.StringVar(&includeTables, "includeTables", "", "Comma separated glob patterns of the only tables to load; all tables if empty")
+	flag.StringVar(&excludeTables, "excludeTables", "", "Comma separated glob patterns of tables not to load")
+	flag.BoolVar(&pgConfig.OrderedLoads, "orderedLoads", false, "Load each table's files strictly in the order they were queued, one load per table at a time")
 	flag.IntVar(&loadAgeSeconds, "loadAgeSeconds", 1800, "Max age of tsvs in queue before a load into redshift is triggered")
 	flag.IntVar(&poolSize, "n_workers", 5, "Number of load workers and therefore redshift connections. Set to 0 to turn off ingests (COPYs).")
 	flag.StringVar(&blueprintHost, "blueprint_host", "", "Host name (and optionally :port) for communicating with blueprint")
+	flag.DurationVar(&blueprintCacheTTL, "blueprintCacheTTL", 5*time.Minute, "How long to use blueprint responses before revalidating them")
 	flag.StringVar(&rollbarToken, "rollbarToken", "", "Rollbar post_server_item token")
 	flag.StringVar(&rollbarEnvironment, "rollbarEnvironment", "", "Rollbar environment")
-	flag.IntVar(&offpeakStartHour, "offpeakStartHour", 3, "Hour that offpeak period starts and migrations can happen, in UTC")
+	flag.IntVar(&offpeakStartHour, "offpeakStartHour", 3, "Hour that offpeak period starts and migrations can happen, in UTC, on days of the week without their own offpeakWeekdays tunable")
 	flag.IntVar(&offpeakDurationHours, "offpeakDurationHours", 8, "Duration of the offpeak migration period, in hours")
 	flag.IntVar(&onpeakMigrationTimeoutMs, "onpeakMigrationTimeoutMs", 600000, "Timeout of a migration forced on-peak")
 	flag.IntVar(&offpeakMigrationTimeoutMs, "offpeakMigrationTimeoutMs", 10800000, "Timeout of a migration off-peak")
+	flag.IntVar(&copyTimeoutMs, "copyTimeoutMs", 0, "Timeout of a load's COPYs, unless overridden for the table; 0 for none")
+	flag.DurationVar(&loadTimeout, "loadTimeout", 0, "Longest a worker spends on one load before failing it with a timeout error; 0 for no limit")
+	flag.DurationVar(&loadEventRetention, "loadEventRetention", 7*24*time.Hour, "How long to keep loads' state transitions in load_event; 0 keeps them forever")
+	flag.DurationVar(&stageTimeouts.Prepare, "loadPrepareTimeout", 0, "Longest a load's S3 work before the COPY, e.g. uploading its manifests, can take; 0 for no limit")
+	flag.DurationVar(&stageTimeouts.Copy, "loadCopyTimeout", 0, "Longest a load's COPY can take, from waiting for the table's lock to the commit; also caps the COPY's statement_timeout. 0 for no limit")
+	flag.IntVar(&governorConfig.MaxCopies, "maxConcurrentCopies", 0, "Most loads COPYing at once across all tables; 0 for no limit beyond -n_workers")
+	flag.DurationVar(&governorConfig.WLMCheckPeriod, "wlmCheckPeriod", 0, "How often to check Redshift's WLM queues while deferring loads because they're saturated; 0 disables deferring")
+	flag.StringVar(&dropSnapshotPrefix, "dropSnapshotPrefix", "", "S3 URL the tables of dropped events are unloaded under before they're dropped, e.g. s3://bucket/dropped; empty drops them without a snapshot")
+	flag.BoolVar(&versionedTables, "versionedTables", false, "Load files of versions a table is waiting to be migrated to into <table>_v<version>, unioned into its view, instead of holding them")
+	flag.BoolVar(&forceDrift, "forceDrift", false, "Migrate tables even if their columns have drifted from blueprint's schema of their current version")
+	flag.IntVar(&maxConcurrentMigrations, "maxConcurrentMigrations", 1, "Most tables the migrator migrates at once, each with its own redshift connection")
 	flag.StringVar(&configFilename, "config", "", "JSON config filename")
+	flag.StringVar(&controlAddr, "controlAddr", "localhost:8080", "Address to serve health and control on")
+	flag.StringVar(&controlGRPCAddr, "controlGRPCAddr", "", "If set, address to serve the gRPC control service of control/proto/control.proto on")
+	flag.StringVar(&pprofAddr, "pprofAddr", ":7766", "Address to serve pprof on")
+	flag.StringVar(&controlAuthToken, "controlAuthToken", "", "If set, bearer token required to call control and pprof endpoints")
+	flag.StringVar(&tlsConfig.CertFile, "tlsCertFile", "", "TLS certificate file; serves health, control and pprof over TLS with -tlsKeyFile")
+	flag.StringVar(&tlsConfig.KeyFile, "tlsKeyFile", "", "TLS private key file")
+	flag.StringVar(&tlsConfig.ClientCAFile, "tlsClientCAFile", "", "If set with TLS, CA file used to require client certificates on control endpoints")
+	flag.StringVar(&tracingConfig.Endpoint, "otlpEndpoint", "", "If set, host:port of the OTLP gRPC collector the spans of each load, linked to those of its files, are exported to")
+	flag.BoolVar(&tracingConfig.Insecure, "otlpInsecure", false, "Export spans to -otlpEndpoint without TLS, e.g. to a local agent")
+	flag.StringVar(&tracingConfig.Service, "otlpServiceName", "rs_ingester", "The service.name of exported spans")
+	flag.DurationVar(&maxQueueAge, "maxQueueAge", 3*time.Hour, "Oldest a queued tsv can be before the deep health check fails")
+	flag.DurationVar(&maxMigratorIdle, "maxMigratorIdle", 4*time.Hour, "Longest the migrator can go without progress before the deep health check fails")
+	flag.DurationVar(&migratorStuckThreshold, "migratorStuckThreshold", 26*time.Hour, "Longest the migrator can go without a successful poll, or a table can wait to be migrated, before the deep health check is degraded")
+	flag.DurationVar(&maxIngesterDBLatency, "maxIngesterDBLatency", time.Second, "Longest pinging ingesterdb can take before the health checks report it degraded; 0 is no limit")
+	flag.StringVar(&healthSQSQueue, "healthSQSQueue", "", "If set, name of the metadatastorer's sqs queue, checked for being accessible by the deep health check")
+	flag.DurationVar(&healthCheckTimeout, "healthCheckTimeout", 10*time.Second, "Longest a health check's sub-check can take before it fails; 0 is no limit")
+	flag.IntVar(&breakerConfig.FailureThreshold, "redshiftBreakerThreshold", 5, "Consecutive Redshift connection failures before pausing loads; 0 disables")
+	flag.DurationVar(&breakerConfig.ProbePeriod, "redshiftBreakerProbePeriod", 30*time.Second, "How often to ping Redshift while loads are paused")
+	flag.DurationVar(&keepaliveConfig.Interval, "redshiftKeepalive", time.Minute, "How often to ping idle Redshift connections, reopening dead ones; 0 disables")
+	flag.DurationVar(&keepaliveConfig.MaxLifetime, "redshiftConnMaxLifetime", time.Hour, "How long a Redshift connection is used before it's recycled; 0 keeps connections open")
+	flag.IntVar(&manifestConfig.MaxFiles, "maxManifestFiles", 0, "Most files in one COPY manifest; larger loads are split into several manifests. 0 is unbounded")
+	flag.Int64Var(&manifestConfig.MaxBytes, "maxManifestBytes", 0, "Most bytes of files in one COPY manifest; costs an S3 HEAD per file. 0 is unbounded")
+	flag.BoolVar(&manifestConfig.SplitByDay, "splitManifestsByDay", false, "Split loads into one COPY manifest per day of the files' events, by the dates in their keys, to stay aligned with a time sort key")
+	flag.BoolVar(&manifestConfig.SkipEmptyFiles, "skipEmptyFiles", false, "Leave files without rows out of COPYs, marking loads of only such files done without a COPY; costs an S3 HEAD per file")
+	flag.Int64Var(&manifestConfig.EmptyFileBytes, "emptyFileBytes", 0, "With -skipEmptyFiles, the size at or below which a file is empty, e.g. that of a header-only file")
+	flag.BoolVar(&manifestConfig.CheckMissing, "checkMissingFiles", false, "Look up each file before COPYing it, leaving files missing from S3, e.g. deleted by a lifecycle policy, out of the COPY; costs an S3 HEAD per file")
+	flag.Float64Var(&manifestConfig.MaxMissingFraction, "maxMissingFileFraction", 0.1, "With -checkMissingFiles, the largest fraction of a load's files that may be missing for the rest to be COPYed; loads with more missing fail")
+	flag.StringVar(&manifestChunkMode, "manifestChunkMode", string(loadclient.ChunkTogether), "How a load split into several manifests is COPYed: together in one transaction, or each manifest committed as its own chunk, sequential or parallel")
+	flag.Int64Var(&manifestConfig.CompactFileBytes, "compactFileBytes", 0, "Size at or below which a file is small; loads' small files are concatenated into fewer, larger objects before the COPY, at the cost of an S3 HEAD per file. 0 disables")
+	flag.IntVar(&manifestConfig.CompactMinFiles, "compactMinFiles", 100, "With -compactFileBytes, the fewest small files a load must have to be compacted")
+	flag.Int64Var(&manifestConfig.CompactTargetBytes, "compactTargetBytes", 128<<20, "With -compactFileBytes, the most bytes of small files compacted into one object")
+	flag.IntVar(&manifestConfig.ChunkParallelism, "manifestChunkParallelism", 2, "With -manifestChunkMode=parallel, how many of a load's chunks are COPYed at once")
+	flag.StringVar(&manifestKeyLayout, "manifestKeyLayout", "", "Prefix of manifests' keys in the manifest bucket, with {env}, {table}, {version} and {date} (the day the load's oldest file was queued) placeholders, e.g. manifests/{env}/{date}/{table}/; empty puts them at the top of the bucket")
+	flag.StringVar(&manifestEnvironment, "manifestEnvironment", "", "The {env} of -manifestKeyLayout, e.g. production")
+	flag.StringVar(&manifestConfig.UploadEncryption.ServerSideEncryption, "manifestSSE", "", "Server-side encryption of manifests, jsonpaths files and compacted objects uploaded to the manifest bucket, AES256 or aws:kms; empty uses the bucket's default")
+	flag.StringVar(&manifestConfig.UploadEncryption.KMSKeyID, "manifestKMSKeyID", "", "With -manifestSSE=aws:kms, the KMS key to encrypt uploads with; empty uses the account's default S3 key")
+	flag.StringVar(&copyRoleARN, "copyRoleARN", "", "If set, ARN of a role, e.g. of the account owning the data bucket, to assume for the credentials COPYs and UNLOADs pass to Redshift instead of the instance's")
+	flag.StringVar(&copyRoleExternalID, "copyRoleExternalID", "", "With -copyRoleARN, the external ID the role's trust policy requires")
+	flag.DurationVar(&copyRoleDuration, "copyRoleDuration", time.Hour, "With -copyRoleARN, how long the assumed role's credentials last; they're refreshed once half of it is up")
+	flag.IntVar(&preValidation.MinFiles, "preValidateMinFiles", 0, "Fewest files a TSV load must have for its first file to be sampled and checked against the table's schema before the COPY; 0 disables")
+	flag.Int64Var(&preValidation.MinRows, "preValidateMinRows", 0, "Fewest advertised rows a TSV load must have for its first file to be sampled and checked against the table's schema before the COPY; 0 disables")
+	flag.IntVar(&preValidation.SampleRows, "preValidateSampleRows", 1000, "Most rows of a load's first file checked by pre-validation")
+	flag.Int64Var(&preValidation.SampleBytes, "preValidateSampleBytes", 1<<20, "Most bytes of a load's first file read by pre-validation, before decompression")
+	flag.DurationVar(&compressionAnalysisPeriod, "compressionAnalysisPeriod", 0, "How often to run ANALYZE COMPRESSION on large tables; 0 disables")
+	flag.Int64Var(&compressionMinRows, "compressionMinRows", 100000000, "Minimum rows for a table's compression to be analyzed")
+	flag.DurationVar(&retentionConfig.Period, "retentionPeriod", 0, "How often to plan deletions of rows past their table's retention, carried out offpeak; 0 disables")
+	flag.StringVar(&retentionConfig.TimeColumn, "retentionTimeColumn", "time", "Column whose age rows are expired by")
+	flag.IntVar(&retentionConfig.TimeoutMs, "retentionTimeoutMs", 10800000, "Timeout of a table's DELETE of expired rows; 0 for none")
+	flag.DurationVar(&qualityConfig.Period, "qualityReportPeriod", 0, "How often to report loads' data-quality anomalies to Rollbar, aggregated by table and kind; 0 logs each one instead")
+	flag.DurationVar(&qualityConfig.Quiet, "qualityQuietPeriod", time.Hour, "How long after a table's data-quality anomalies of a kind are reported its next ones are held")
+	flag.IntVar(&qualityConfig.MaxItems, "qualityMaxItems", 10, "Most data-quality items reported to Rollbar per -qualityReportPeriod; 0 is unlimited")
+	flag.DurationVar(&versionPushPeriod, "versionPushPeriod", 0, "How often to publish the tables' cached and Ace versions to blueprint; 0 disables")
+	flag.DurationVar(&sloConfig.Period, "sloEvaluationPeriod", 0, "How often to evaluate tables' freshness SLOs; 0 disables")
+	flag.DurationVar(&scheduleConfig.Period, "forceLoadSchedulePeriod", time.Minute, "How often to check tables' force load schedules; 0 disables")
+	flag.DurationVar(&gapConfig.Period, "gapCheckPeriod", 0, "How often to check the processor's recent output for files missing from ingesterdb; 0 disables")
+	flag.StringVar(&gapConfig.Bucket, "gapCheckBucket", "", "Bucket of the processor's output checked for missing files")
+	flag.StringVar(&gapConfig.PrefixLayout, "gapCheckPrefix", "2006-01-02/", "Prefix of an hour of the processor's output, as a Go time layout in UTC")
+	flag.DurationVar(&gapConfig.Lookback, "gapCheckLookback", 6*time.Hour, "How far back to check the processor's output for missing files")
+	flag.DurationVar(&gapConfig.Settle, "gapCheckSettle", 30*time.Minute, "How long a processed file has to be queued before it's reported missing")
+	flag.BoolVar(&retentionConfig.DryRun, "retentionDryRun", false, "Only plan deletions of expired rows, reporting them through /control/retention")
+	flag.StringVar(&redshiftTransport, "redshiftTransport", "", "If set, how to reach Redshift, overriding transport in the config: postgres, or dataapi for the Redshift Data API, e.g. for Redshift Serverless")
+	flag.StringVar(&targetSchema, "targetSchema", "", "If set, Redshift schema to load tables into, overriding physicalSchema in the config")
+	flag.StringVar(&bpConfigsBucket, "bpConfigsBucket", "", "The S3 bucket name where Blueprint configs are stored")
+	flag.StringVar(&bpMetadataConfigsKey, "bpMetadataConfigsKey", "", "If set, file name of the Blueprint event metadata configs on S3, used for per-table target_schema overrides and retention classes")
+	flag.DurationVar(&bpMetadataReloadFrequency, "bpMetadataReloadFrequency", 5*time.Minute, "How often to load Blueprint event metadata from S3")
+	flag.BoolVar(&dryRun, "dryRun", false, "Create manifests and check loads but skip their COPYs, recording the loads as simulated")
+	flag.DurationVar(&versionRefreshPeriod, "versionRefreshPeriod", time.Hour, "How often to re-read table versions from ace to catch out-of-band changes; 0 disables")
+	flag.DurationVar(&drainTimeout, "drainTimeout", 5*time.Minute, "How long to wait on shutdown for running loads before canceling their COPYs; 0 waits indefinitely")
+	flag.DurationVar(&controlMigratorTimeout, "controlMigratorTimeout", 30*time.Minute, "Deadline for control requests handed to the migrator")
 }
 
 type config struct {
-	Redshift backend.Config `json:"redshift"`
+	Redshift backend.Config          `json:"redshift"`
+	Regions  loadclient.RegionConfig `json:"regions"`
+	// Encryption marks the buckets and prefixes of encrypted files
+	Encryption []loadclient.EncryptionRule `json:"encryption"`
+	// ControlHMACKeys names the environment variables holding keys that may sign control requests,
+	// by the key names callers are identified by
+	ControlHMACKeys map[string]string `json:"controlHMACKeys"`
+	// RetentionClasses is the days of data kept by events of each blueprint retention_class
+	RetentionClasses map[string]int `json:"retentionClasses"`
+	// FreshnessSLOs is how many seconds each table's files may wait to be loaded, unless overridden
+	// through the control API
+	FreshnessSLOs map[string]int `json:"freshnessSLOs"`
+	// ForceLoadSchedules is when each table is force loaded, as a cron expression in UTC, unless
+	// overridden through the control API
+	ForceLoadSchedules map[string]string `json:"forceLoadSchedules"`
+	// Tunables override flags, and are reloaded on SIGHUP or /control/reload_config
+	Tunables json.RawMessage `json:"tunables"`
+}
+
+// tunables returns the config's tunables, taking the flags' values for those it doesn't set.
+func (c *config) tunables(flags lib.Tunables) (lib.Tunables, error) {
+	tunables := flags
+	if len(c.Tunables) > 0 {
+		err := json.Unmarshal(c.Tunables, &tunables)
+		if err != nil {
+			return flags, fmt.Errorf("parsing tunables: %v", err)
+		}
+	}
+	err := tunables.Validate()
+	if err != nil {
+		return flags, err
+	}
+	_, err = metadata.NewTableFilter(tablePatterns(tunables))
+	if err != nil {
+		return flags, err
+	}
+	return tunables, nil
+}
+
+// tablePatterns returns the patterns of the tables loaded given by the tunables.
+func tablePatterns(tunables lib.Tunables) metadata.TablePatterns {
+	return metadata.TablePatterns{
+		Include: metadata.ParseTablePatterns(tunables.IncludeTables),
+		Exclude: metadata.ParseTablePatterns(tunables.ExcludeTables),
+	}
 }
 
 func loadConfig(filename string) (*config, error) {
@@ -160,9 +745,8 @@ func loadConfig(filename string) (*config, error) {
 
 func main() {
 	flag.Parse()
-	pgConfig.LoadAgeTrigger = time.Second * time.Duration(loadAgeSeconds)
 
-	stats, err := monitoring.NewStatter(os.Getenv("STATSD_HOSTPORT"), statsPrefix)
+	stats, err := lib.InitStats(statsBackend, os.Getenv("STATSD_HOSTPORT"), statsPrefix, lib.ParseTags(statsTags), statsNaming)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to setup statter")
 	}
@@ -172,23 +756,120 @@ func main() {
 	logger.Info("starting")
 	defer logger.LogPanic()
 
+	shutdownTracing, err := lib.InitTracing(tracingConfig)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to setup tracing")
+	}
+
 	conf, err := loadConfig(configFilename)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed loading config")
 	}
+	flagTunables := lib.Tunables{
+		LoadCountTrigger:     pgConfig.LoadCountTrigger,
+		LoadAgeSeconds:       loadAgeSeconds,
+		OffpeakStartHour:     offpeakStartHour,
+		OffpeakDurationHours: offpeakDurationHours,
+		Workers:              poolSize,
+		IncludeTables:        includeTables,
+		ExcludeTables:        excludeTables,
+	}
+	tunables, err := conf.tunables(flagTunables)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid tunables in config")
+	}
+	pgConfig.LoadCountTrigger = tunables.LoadCountTrigger
+	pgConfig.LoadAgeTrigger = time.Second * time.Duration(tunables.LoadAgeSeconds)
+	offpeakSchedule := tunables.OffpeakSchedule()
+	poolSize = tunables.Workers
+
+	pgConfig.Tables, err = metadata.NewTableFilter(tablePatterns(tunables))
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to parse table filter")
+	}
+	manifestConfig.ChunkMode = loadclient.ChunkMode(manifestChunkMode)
+	switch manifestConfig.ChunkMode {
+	case loadclient.ChunkTogether, loadclient.ChunkSequential, loadclient.ChunkParallel:
+	default:
+		logger.WithField("manifestChunkMode", manifestChunkMode).Fatal("Unknown manifest chunk mode")
+	}
+	if manifestConfig.ChunkMode == loadclient.ChunkParallel && manifestConfig.ChunkParallelism < 1 {
+		logger.WithField("manifestChunkParallelism", manifestConfig.ChunkParallelism).
+			Fatal("Parallel manifest chunks need a parallelism of at least 1")
+	}
+
+	manifestConfig.KeyLayout, err = loadclient.NewKeyLayout(manifestKeyLayout, manifestEnvironment)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid -manifestKeyLayout")
+	}
+	if err = manifestConfig.UploadEncryption.Validate(); err != nil {
+		logger.WithError(err).Fatal("Invalid manifest upload encryption")
+	}
+
+	if manifestConfig.CompactFileBytes > 0 && manifestConfig.CompactTargetBytes < manifestConfig.CompactFileBytes {
+		logger.WithField("compactTargetBytes", manifestConfig.CompactTargetBytes).
+			Fatal("-compactTargetBytes must be at least -compactFileBytes")
+	}
 
 	session, err := session.NewSession()
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to setup aws session")
 	}
 
+	s3Client := s3.New(session)
+	if conf.Regions.ClusterRegion == "" {
+		conf.Regions.ClusterRegion = aws.StringValue(session.Config.Region)
+	}
+	regions := loadclient.NewRegions(conf.Regions, session)
+	encryption, err := loadclient.NewEncryption(conf.Encryption)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to configure encryption")
+	}
+	controlHMACKeys, err := lib.HMACKeysFromEnv(conf.ControlHMACKeys)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to read control HMAC keys")
+	}
+	if targetSchema != "" {
+		conf.Redshift.PhyiscalSchema = targetSchema
+	}
+	if redshiftTransport != "" {
+		conf.Redshift.Transport = redshiftTransport
+	}
+	var schemaOverrides backend.SchemaOverrides
+	var piiSource loadclient.PIISource
+	var bpMetadataLoader *blueprint.MetadataLoader
+	if bpMetadataConfigsKey != "" {
+		fetcher := blueprint.NewFetcher(bpConfigsBucket, bpMetadataConfigsKey, s3Client)
+		bpMetadataLoader, err = blueprint.NewMetadataLoader(fetcher, bpMetadataReloadFrequency, 2*time.Second, stats)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to setup Blueprint metadata loader")
+		}
+		logger.Go(bpMetadataLoader.Crank)
+		schemaOverrides = bpMetadataLoader
+		piiSource = bpMetadataLoader
+	}
+
 	s3Uploader := s3manager.NewUploader(session)
-	aceBackend, err := backend.BuildRedshiftBackend(session.Config.Credentials, poolSize+healthCheckPoolSize, &conf.Redshift)
+	copyCredentials := session.Config.Credentials
+	if copyRoleARN != "" {
+		copyCredentials = redshift.AssumeRoleCredentials(session, copyRoleARN, copyRoleExternalID, copyRoleDuration)
+		if _, err = copyCredentials.Get(); err != nil {
+			logger.WithError(err).WithField("role", copyRoleARN).Fatal("Failed to assume role for COPY credentials")
+		}
+	}
+	aceBackend, err := backend.BuildRedshiftBackend(copyCredentials, poolSize+healthCheckPoolSize+maxConcurrentMigrations,
+		&conf.Redshift, breakerConfig, keepaliveConfig, stats, schemaOverrides)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to setup redshift connection")
 	}
 
-	rsConnection, err := loadclient.NewRSLoader(s3Uploader, aceBackend, manifestBucket, stats)
+	blueprintClient := blueprint.New(blueprintHost, blueprintCacheTTL, stats)
+	if bpMetadataLoader != nil {
+		// Shard tables are migrated and loaded with their events' schemas
+		blueprintClient = blueprintClient.WithShards(bpMetadataLoader)
+	}
+	rsConnection, err := loadclient.NewRSLoader(s3Uploader, s3Client, aceBackend, manifestBucket, stats,
+		&blueprintClient, manifestConfig, regions, encryption, preValidation, nil, nil, copyTimeoutMs, stageTimeouts, dryRun)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to setup Redshift loading client for postgres")
 	}
@@ -200,19 +881,34 @@ func main() {
 	}
 	logger.Info("Got table versions from ace")
 	tableVersions := versions.New(initVersions)
+	var versionPusher *versions.Pusher
+	if versionPushPeriod > 0 {
+		versionPusher = versions.NewPusher(tableVersions, aceBackend, &blueprintClient, versionPushPeriod, stats)
+	}
 
 	var metaBackend metadata.Backend
+	var workers *workerPool
+	governor := loadclient.NewGovernor(aceBackend, governorConfig, stats)
+	var qualityReporter *quality.Reporter
+	if qualityConfig.Period > 0 {
+		qualityReporter = quality.New(quality.RollbarNotifier{}, stats, qualityConfig)
+	}
 
 	if poolSize > 0 {
-		metaBackend, err = metadata.NewPostgresLoader(&pgConfig, rsConnection, tableVersions)
+		metaBackend, err = metadata.NewPostgresLoader(&pgConfig, rsConnection, tableVersions, stats)
 		if err != nil {
 			logger.WithError(err).Fatal("Failed to setup postgres backend")
 		}
 
-		_, err = startWorkers(s3Uploader, metaBackend, stats, aceBackend)
+		workers = newWorkerPool(s3Uploader, s3Client, metaBackend, stats, aceBackend, &blueprintClient, regions,
+			encryption, governor, qualityReporter, piiSource)
+		err = workers.Resize(poolSize)
 		if err != nil {
 			logger.WithError(err).Fatal("Failed to start workers")
 		}
+		if loadEventRetention > 0 {
+			logger.Go(func() { pruneLoadEvents(metaBackend, loadEventRetention) })
+		}
 	}
 
 	metaReader, err := metadata.NewPostgresReader(&pgConfig, tableVersions)
@@ -221,29 +917,163 @@ func main() {
 	}
 
 	statsReporter := reporter.New(metaReader, stats, reporterPollPeriod)
-	blueprintClient := blueprint.New(blueprintHost)
-	versionIncrement := make(chan migrator.VersionIncrement)
+	var utilizationReporter *reporter.UtilizationReporter
+	if utilizationPeriod > 0 {
+		sources := reporter.UtilizationSources{
+			Redshift: aceBackend,
+			PoolSize: workers.Size,
+			BusyWorkers: func() int {
+				return int(atomic.LoadInt32(&busyWorkers))
+			},
+		}
+		if metaBackend != nil {
+			sources.WaitingLoads = metaBackend.WaitingLoads
+		}
+		utilizationReporter = reporter.NewUtilizationReporter(sources, stats, utilizationPeriod)
+	}
+	var errorTrendReporter *reporter.ErrorTrendReporter
+	if errorTrendPeriod > 0 {
+		errorTrendReporter = reporter.NewErrorTrendReporter(metaReader, stats, errorTrendPeriod)
+	}
+	// Buffered so a queued increment is noticed even while the migrator is busy
+	versionIncrement := make(chan bool, 1)
+	migrationRequests := make(chan migrator.MigrationRequest)
+	versionRefreshes := make(chan migrator.VersionRefresh)
+	var newTables <-chan string
+	tableListener, err := metadata.ListenForNewTables(pgConfig.DatabaseURL)
+	if err != nil {
+		logger.WithError(err).Error("Failed to listen for new tables; they'll be created on the migrator's poll")
+	} else {
+		newTables = tableListener.Tables()
+	}
 	migrator := migrator.New(aceBackend, metaReader, blueprintClient, tableVersions, migratorPollPeriod,
-		waitProcessorPeriod, offpeakStartHour, offpeakDurationHours, versionIncrement, onpeakMigrationTimeoutMs,
-		offpeakMigrationTimeoutMs)
+		waitProcessorPeriod, offpeakSchedule, versionIncrement, migrationRequests,
+		newTables, versionRefreshes, versionRefreshPeriod, onpeakMigrationTimeoutMs, offpeakMigrationTimeoutMs,
+		maxConcurrentMigrations, dropSnapshotPrefix, forceDrift, versionedTables)
+	logger.Go(func() { migrator.Watch(migratorStuckThreshold, time.Minute, stats) })
 
 	serveMux := http.NewServeMux()
-	serveMux.Handle("/health", healthcheck.NewHealthRouter())
+	healthRouter := healthcheck.NewHealthRouter(healthcheck.NewHealthHandler(&healthcheck.Dependencies{
+		MetaReader: metaReader,
+		AceBackend: aceBackend,
+		WorkersRunning: func() bool {
+			// Workers being removed from the pool run until they finish their load
+			return atomic.LoadInt32(&runningWorkers) >= int32(workers.Size())
+		},
+		MigratorLastActive:   migrator.LastActive,
+		MigratorLiveness:     func() error { return migrator.CheckLiveness(migratorStuckThreshold) },
+		MaxQueueAge:          maxQueueAge,
+		MaxMigratorIdle:      maxMigratorIdle,
+		MaxIngesterDBLatency: maxIngesterDBLatency,
+		Blueprint:            &blueprintClient,
+		SQS:                  sqs.New(session),
+		SQSQueue:             healthSQSQueue,
+		S3:                   s3Client,
+		ManifestBucket:       manifestBucket,
+		CheckTimeout:         healthCheckTimeout,
+	}))
+	serveMux.Handle("/health", healthRouter)
+	serveMux.Handle("/health/", healthRouter)
+
+	if compressionAnalysisPeriod > 0 {
+		logger.Go(func() { analyzeCompression(aceBackend, compressionAnalysisPeriod, compressionMinRows) })
+	}
+
+	var retentionManager *retention.Manager
+	var retentionReporter control.RetentionReporter
+	if retentionConfig.Period > 0 {
+		if bpMetadataLoader == nil {
+			logger.Fatal("-retentionPeriod requires -bpMetadataConfigsKey for tables' retention classes")
+		}
+		retentionConfig.ClassDays = conf.RetentionClasses
+		retentionManager = retention.New(aceBackend, bpMetadataLoader, migrator.IsOffPeakHours, stats, retentionConfig)
+		retentionReporter = retentionManager
+	}
+
+	var sloEvaluator *slo.Evaluator
+	var sloReporter control.SLOReporter
+	if sloConfig.Period > 0 {
+		sloConfig.Defaults = conf.FreshnessSLOs
+		sloEvaluator = slo.New(metaReader, stats, sloConfig)
+		sloReporter = sloEvaluator
+	}
+
+	var scheduler *schedule.Scheduler
+	var scheduleReporter control.ScheduleReporter
+	if scheduleConfig.Period > 0 {
+		scheduleConfig.Defaults = conf.ForceLoadSchedules
+		scheduler, err = schedule.New(metaReader, stats, scheduleConfig)
+		if err != nil {
+			logger.WithError(err).Fatal("Error parsing force load schedules")
+		}
+		scheduleReporter = scheduler
+	}
+
+	// A nil *MetadataLoader would make a non-nil Reloader
+	var bpMetadataReloader blueprint.Reloader
+	if bpMetadataLoader != nil {
+		bpMetadataReloader = bpMetadataLoader
+	}
+	var gapChecker *gaps.Checker
+	var gapReporter control.GapReporter
+	if gapConfig.Period > 0 {
+		if gapConfig.Bucket == "" {
+			logger.Fatal("-gapCheckPeriod requires -gapCheckBucket")
+		}
+		gapChecker = gaps.New(s3Client, metaReader, tableVersions, pgConfig.Tables, stats, gapConfig)
+		gapReporter = gapChecker
+	}
 
-	controlBackend := control.NewControlBackend(metaReader, metaBackend, tableVersions, versionIncrement)
+	reloader := &configReloader{
+		filename:    configFilename,
+		flags:       flagTunables,
+		applied:     tunables,
+		metaBackend: metaBackend,
+		workers:     workers,
+		aceBackend:  aceBackend,
+		migrator:    migrator,
+		stats:       stats,
+	}
+	controlBackend := control.NewControlBackend(metaReader, metaBackend, tableVersions, versionIncrement,
+		migrationRequests, versionRefreshes, aceBackend, retentionReporter, aceBackend, rsConnection, s3Client,
+		controlMigratorTimeout, migrator, bpMetadataReloader, sloReporter, reloader,
+		gapReporter, scheduleReporter, aceBackend, migrator, aceBackend, aceBackend)
 	controlHandler := control.NewControlHandler(controlBackend, stats)
-	serveMux.Handle("/control/", control.NewControlRouter(controlHandler))
+	controlAuth := control.AuthConfig{
+		Token:             controlAuthToken,
+		HMACKeys:          controlHMACKeys,
+		RequireClientCert: tlsConfig.Enabled() && tlsConfig.ClientCAFile != "",
+	}
+	serveMux.Handle("/control/", control.NewControlRouter(controlHandler, controlAuth))
 
 	logger.Go(func() {
-		logger.WithError(http.ListenAndServe(net.JoinHostPort("localhost", "8080"), serveMux)).
+		logger.WithError(tlsConfig.ListenAndServe(controlAddr, serveMux)).
 			Fatal("Serving health and control failed")
 	})
+	if controlGRPCAddr != "" {
+		serveGRPCControl(controlBackend, controlAuth, stats)
+	}
 
 	logger.Go(func() {
-		logger.WithError(http.ListenAndServe(":7766", http.DefaultServeMux)).
+		var pprofHandler http.Handler = http.DefaultServeMux
+		if controlAuthToken != "" {
+			pprofHandler = lib.TokenAuth(controlAuthToken)(pprofHandler)
+		}
+		logger.WithError(tlsConfig.ListenAndServe(pprofAddr, pprofHandler)).
 			Error("Serving pprof failed")
 	})
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	logger.Go(func() {
+		for range hup {
+			logger.Info("SIGHUP received -- reloading config")
+			if _, err := reloader.ReloadConfig(); err != nil {
+				logger.WithError(err).Error("Error reloading config")
+			}
+		}
+	})
+
 	wait := make(chan struct{})
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
@@ -251,17 +1081,51 @@ func main() {
 	logger.Go(func() {
 		<-sigc
 		logger.Info("Sigint received -- shutting down")
+		if tableListener != nil {
+			if err := tableListener.Close(); err != nil {
+				logger.WithError(err).Error("Error closing new table listener")
+			}
+		}
 		migrator.Close()
+		if retentionManager != nil {
+			retentionManager.Close()
+		}
+		if sloEvaluator != nil {
+			sloEvaluator.Close()
+		}
+		if scheduler != nil {
+			scheduler.Close()
+		}
+		if gapChecker != nil {
+			gapChecker.Close()
+		}
+		if versionPusher != nil {
+			versionPusher.Close()
+		}
 		statsReporter.Close()
+		if utilizationReporter != nil {
+			utilizationReporter.Close()
+		}
+		if errorTrendReporter != nil {
+			errorTrendReporter.Close()
+		}
 		if metaBackend != nil {
 			metaBackend.Close()
 		}
-		workerGroup.Wait()
+		governor.Close()
+		drainWorkers(aceBackend, stats)
+		if qualityReporter != nil {
+			qualityReporter.Close()
+		}
 		// Cause flush
 		err = stats.Close()
 		if err != nil {
 			logger.WithError(err).Error("Error closing statter")
 		}
+		err = shutdownTracing(context.Background())
+		if err != nil {
+			logger.WithError(err).Error("Error flushing spans")
+		}
 		logger.Info("Exiting main cleanly.")
 		logger.Wait()
 		close(wait)
@@ -1,19 +1,203 @@
 package metadata
 
 import (
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
-
-	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
 
 // Load represents a file that needs to be loaded
-type Load scoop_protocol.RowCopyRequest
+type Load struct {
+	KeyName      string
+	TableName    string
+	TableVersion int
+	// Compression is how the file is compressed; if empty, it's inferred from KeyName
+	Compression Compression
+	// Format is the file's format; if empty, it's inferred from KeyName
+	Format LoadFormat
+	// RowCount is the number of rows the processor advertised for the file, if it did; only set on
+	// the loads of a LoadManifest
+	RowCount *int64 `json:",omitempty"`
+	// TraceParent is the W3C traceparent of the span the metadatastorer received the file in, if it
+	// was traced; the load of the file links to it
+	TraceParent string `json:",omitempty"`
+}
+
+// processedKeyPattern matches the keys of processed files, like <date>/<table>/v<version>/<file>,
+// capturing the table and version.
+var processedKeyPattern = regexp.MustCompile(`(?:^|/)([^/]+)/v([0-9]+)/[^/]+$`)
+
+// processedKeyDatePattern matches the date the processor files events under in processed files' keys,
+// as 2006-01-02 or 20060102, before the table and version.
+var processedKeyDatePattern = regexp.MustCompile(`(?:^|/)([0-9]{4})-?([0-9]{2})-?([0-9]{2})/[^/]+/v[0-9]+/`)
+
+// ProcessedKeyDate returns the day, in UTC, of the events in a processed file from its S3 key.
+func ProcessedKeyDate(key string) (time.Time, bool) {
+	match := processedKeyDatePattern.FindStringSubmatch(key)
+	if match == nil {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("20060102", match[1]+match[2]+match[3])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// ParseProcessedKey returns the table and version of a processed file from its S3 key.
+func ParseProcessedKey(key string) (table string, version int, ok bool) {
+	match := processedKeyPattern.FindStringSubmatch(key)
+	if match == nil {
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return match[1], version, true
+}
+
+// LoadStats is the size of a finished load, as reported by Redshift
+type LoadStats struct {
+	RowsLoaded   int64
+	BytesScanned int64
+	// Simulated is set if the load's COPY was skipped in a dry run, so it has no size
+	Simulated bool
+	// ExpectedRows is the number of rows the processor advertised for the load's files, if it did for all of them
+	ExpectedRows sql.NullInt64
+	// LinesScanned is the number of lines the COPY read, per STL_LOAD_COMMITS
+	LinesScanned int64
+	// CopyDuration is how long the load's COPYs took, before they were committed
+	CopyDuration time.Duration
+}
+
+// RowMismatch returns whether the rows loaded differ from the rows advertised, or the lines read.
+func (s *LoadStats) RowMismatch() bool {
+	if s == nil || s.Simulated {
+		return false
+	}
+	if s.ExpectedRows.Valid && s.ExpectedRows.Int64 != s.RowsLoaded {
+		return true
+	}
+	return s.LinesScanned > 0 && s.LinesScanned != s.RowsLoaded
+}
+
+// LoadFormat is the format of the files in a load
+type LoadFormat string
+
+const (
+	// LoadFormatTSV files are tab-separated values; the default.
+	LoadFormatTSV LoadFormat = "tsv"
+
+	// LoadFormatJSON files are newline-delimited JSON objects.
+	LoadFormatJSON LoadFormat = "json"
+)
+
+// FormatForKey returns the format of a file from its S3 key.
+func FormatForKey(keyName string) LoadFormat {
+	key := keyName
+	for suffix := range compressionSuffixes {
+		key = strings.TrimSuffix(key, suffix)
+	}
+	if strings.HasSuffix(key, ".json") || strings.HasSuffix(key, ".ndjson") {
+		return LoadFormatJSON
+	}
+	return LoadFormatTSV
+}
+
+// ValidFormat returns whether f is a known format, or empty to infer it from the key
+func ValidFormat(f LoadFormat) bool {
+	switch f {
+	case "", LoadFormatTSV, LoadFormatJSON:
+		return true
+	}
+	return false
+}
+
+// Compression is how the files in a load are compressed
+type Compression string
+
+const (
+	// CompressionGzip files are gzipped; the default.
+	CompressionGzip Compression = "gzip"
+
+	// CompressionBzip2 files are compressed with bzip2.
+	CompressionBzip2 Compression = "bzip2"
+
+	// CompressionZstd files are compressed with Zstandard.
+	CompressionZstd Compression = "zstd"
+
+	// CompressionNone files are uncompressed.
+	CompressionNone Compression = "none"
+)
+
+var compressionSuffixes = map[string]Compression{
+	".gz":   CompressionGzip,
+	".bz2":  CompressionBzip2,
+	".zst":  CompressionZstd,
+	".zstd": CompressionZstd,
+}
+
+// ValidCompression returns whether c is a known compression, or empty to infer it from the key
+func ValidCompression(c Compression) bool {
+	switch c {
+	case "", CompressionGzip, CompressionBzip2, CompressionZstd, CompressionNone:
+		return true
+	}
+	return false
+}
+
+// CompressionForKey returns the compression of a file from its S3 key's suffix. Files without
+// a known suffix are taken to be gzipped, as all files were before other compressions were
+// supported; uncompressed files must say so in their SQS messages.
+func CompressionForKey(keyName string) Compression {
+	for suffix, compression := range compressionSuffixes {
+		if strings.HasSuffix(keyName, suffix) {
+			return compression
+		}
+	}
+	return CompressionGzip
+}
+
+// format returns the load's format, inferring it from its key if it isn't set.
+func (l *Load) format() LoadFormat {
+	if l.Format != "" {
+		return l.Format
+	}
+	return FormatForKey(l.KeyName)
+}
+
+// compression returns the load's compression, inferring it from its key if it isn't set.
+func (l *Load) compression() Compression {
+	if l.Compression != "" {
+		return l.Compression
+	}
+	return CompressionForKey(l.KeyName)
+}
 
 // LoadManifest represents a set of files that needs to be loaded
 type LoadManifest struct {
-	Loads     []Load
-	TableName string
-	UUID      string
+	Loads []Load
+	// Queued is when each of Loads was queued, in the same order
+	Queued       []time.Time
+	TableName    string
+	UUID         string
+	Version      int
+	Format       LoadFormat
+	Compression  Compression
+	CopySettings CopySettings
+	// ExpectedRows is the total rows advertised for Loads, valid only if they were for every file
+	ExpectedRows sql.NullInt64
+	// Versioned is set if the files are of a version the table hasn't been migrated to yet, so they're
+	// loaded into the table's versioned table
+	Versioned bool
+	// Straggler is the policy loading the files if they're of a version older than the table's, queued
+	// after it was migrated; empty otherwise
+	Straggler StragglerPolicy
 }
 
 // Reader specifies the interface for Backend read/write operations
@@ -21,9 +205,103 @@ type Reader interface {
 	Versions() (map[string]int, error)
 	PingDB() error
 	TSVVersionExists(table string, version int) (bool, error)
+	// ForceLoad requests a force load of the table, merged into its pending one if it has one
 	ForceLoad(table string, requester string) error
+	// PendingForceLoads returns the force loads that haven't started, with the requests merged into each
+	PendingForceLoads() ([]PendingForceLoad, error)
+	// CancelForceLoad cancels the table's pending force load, returning whether it had one
+	CancelForceLoad(table string) (bool, error)
 	StatsForPendingLoads() ([]*PendingLoadStats, error)
 	IsForceLoadRequested(table string) (bool, error)
+	LoadTriggers() ([]LoadTrigger, error)
+	SetLoadTrigger(trigger LoadTrigger) error
+	DeleteLoadTrigger(table string) error
+	CopySettings() ([]CopySettings, error)
+	SetCopySettings(settings CopySettings) error
+	DeleteCopySettings(table string) error
+	AddAnnotation(annotation Annotation) (int64, error)
+	Annotations(table string) ([]Annotation, error)
+	// DeleteAnnotation removes the table's annotation, returning whether it had one with the ID
+	DeleteAnnotation(table string, id int64) (bool, error)
+	InFlightLoads() ([]LoadSummary, error)
+	// LoadDetail returns the state of the load, or ErrUnknownLoad
+	LoadDetail(manifestUUID string) (*LoadDetail, error)
+	FailedLoads(limit int) ([]LoadSummary, error)
+	// LoadThroughput returns what was loaded into each table since the time, and how often loads failed
+	LoadThroughput(since time.Time) ([]TableThroughput, error)
+	// TableLoadErrors returns up to limit of the table's load failures since the time, newest first
+	TableLoadErrors(table string, since time.Time, limit int) ([]TableLoadError, error)
+	// LoadErrorCounts returns how many times loads of each table, or only of the table if it's not
+	// empty, failed with each class in each hour of the window before now
+	LoadErrorCounts(table string, now time.Time, window time.Duration) ([]LoadErrorCount, error)
+	MaintenanceWindows() ([]MaintenanceWindow, error)
+	AddMaintenanceWindow(window MaintenanceWindow) (int64, error)
+	DeleteMaintenanceWindow(id int64) error
+	InMaintenance() (bool, error)
+	ComponentToggles() ([]ComponentToggle, error)
+	SetComponentToggle(toggle ComponentToggle) error
+	// ComponentDisabled returns whether the component is switched off
+	ComponentDisabled(component Component) (bool, error)
+	// InsertBackfillLoad queues a file found by a backfill, returning ErrDuplicateLoad if it's been queued
+	InsertBackfillLoad(load *Load) error
+	// InsertBackfillLoads queues files found by a backfill in one transaction, returning which were duplicates
+	InsertBackfillLoads(loads []*Load) (duplicate []bool, err error)
+	// InventoryProgress returns how far backfills of the S3 Inventory report got through each of its data
+	// files, by data file
+	InventoryProgress(manifest string) ([]InventoryFileProgress, error)
+	SetInventoryProgress(progress InventoryFileProgress) error
+	AddAuditEntry(entry AuditEntry) error
+	AuditEntries(filter AuditFilter) ([]AuditEntry, error)
+	// LoadEvents returns the filter's load events, most recent first
+	LoadEvents(filter LoadEventFilter) ([]LoadEvent, error)
+	SamplingRules() ([]SamplingRule, error)
+	SetSamplingRule(rule SamplingRule) error
+	DeleteSamplingRule(table string) error
+	FreshnessSLOs() ([]FreshnessSLO, error)
+	SetFreshnessSLO(slo FreshnessSLO) error
+	DeleteFreshnessSLO(table string) error
+	ForceLoadSchedules() ([]ForceLoadSchedule, error)
+	SetForceLoadSchedule(schedule ForceLoadSchedule) error
+	DeleteForceLoadSchedule(table string) error
+	PausedTables() ([]PausedTable, error)
+	// PauseTable stops the table's queued and failed loads from starting until it's resumed
+	PauseTable(paused PausedTable) error
+	ResumeTable(table string) error
+	// VersionedTables returns the table versions loaded into versioned tables, in order
+	VersionedTables() ([]VersionedTable, error)
+	// AddVersionedTable routes the loads of the table's version into its versioned table
+	AddVersionedTable(table string, version int) error
+	RemoveVersionedTable(table string, version int) error
+	// VersionLoading returns whether any of the table's files of the version are being loaded
+	VersionLoading(table string, version int) (bool, error)
+	StragglerRules() ([]StragglerRule, error)
+	SetStragglerRule(rule StragglerRule) error
+	DeleteStragglerRule(table string) error
+	// DropTable records the table as dropped and dead-letters its queued files, returning how many,
+	// or ErrTableLoading if some of its files are being loaded
+	DropTable(dropped DroppedTable) (int64, error)
+	// DrainDroppedTable dead-letters the queued files of a dropped table up to the version, returning how many
+	DrainDroppedTable(table string, version int) (int64, error)
+	// ForgetDroppedTable deletes the record of the table's drop at the version
+	ForgetDroppedTable(table string, version int) error
+	DroppedTables() ([]DroppedTable, error)
+	// RenameTable records the table as renamed and moves its queued files to the new name, returning
+	// how many, or ErrTableLoading if some of its files are being loaded
+	RenameTable(renamed RenamedTable) (int64, error)
+	// MoveRenamedTable moves the files queued under a renamed table's old name to its new one,
+	// returning how many
+	MoveRenamedTable(renamed RenamedTable) (int64, error)
+	RenamedTables() ([]RenamedTable, error)
+	// AccountedKeys returns which of the S3 keys were queued or diverted, and not yet pruned
+	AccountedKeys(keys []string) (map[string]bool, error)
+	// QueueVersionIncrement stores a request to increment the table to the version until it's finished
+	QueueVersionIncrement(id string, table string, version int) error
+	// PendingVersionIncrements returns the unfinished version increments, oldest first
+	PendingVersionIncrements() ([]VersionIncrement, error)
+	// FinishVersionIncrement acknowledges a version increment, which failed if incrementErr isn't nil
+	FinishVersionIncrement(id string, incrementErr error) error
+	// VersionIncrement returns the version increment, or ErrUnknownVersionIncrement
+	VersionIncrement(id string) (*VersionIncrement, error)
 }
 
 // Backend specifies the interface for load state
@@ -31,18 +309,454 @@ type Backend interface {
 	Storer
 	Reader
 	LoadReady() chan *LoadManifest
-	LoadError(manifestUUID, loadError string)
-	LoadDone(manifestUUID string, tableName string)
+	// WaitingLoads returns how many claimed loads are waiting for a worker to take them from LoadReady
+	WaitingLoads() int
+	// TableFilter returns the filter of the tables loaded, or nil if every table is loaded
+	TableFilter() *TableFilter
+	// SetLoadTriggers replaces the global load triggers, used by tables without their own
+	SetLoadTriggers(count int, age time.Duration)
+	// LoadError marks a load for retry after retryDelay, or after -error_retry_delay if it's 0,
+	// recording its error and the error's class
+	LoadError(manifestUUID, loadError, class string, retryDelay time.Duration)
+	LoadDone(manifestUUID string, tableName string, stats *LoadStats)
+	// LoadChunkDone marks the keys of a load COPYed in chunks as loaded once their chunk is committed,
+	// taking them out of the load; LoadDone then sums the load's history from its chunks
+	LoadChunkDone(manifestUUID string, tableName string, keys []string, stats *LoadStats) error
+	// RecordLoadEvent records a load's state transition in a worker
+	RecordLoadEvent(event LoadEvent) error
+	// PruneLoadEvents deletes the load events recorded before olderThan, returning how many were deleted
+	PruneLoadEvents(olderThan time.Time) (int64, error)
 	GetLastLoads() map[string]time.Time
 }
 
+// ErrDuplicateLoad is returned by InsertLoad when the load's S3 key has already been queued
+var ErrDuplicateLoad = errors.New("load's key has already been queued")
+
+// QueuedLoad is a file to queue with InsertLoads, with the row count the processor advertised
+// for it, if any.
+type QueuedLoad struct {
+	Load     *Load
+	RowCount sql.NullInt64
+}
+
 // Storer specifies recording loads in the db
 type Storer interface {
 	InsertLoad(load *Load) error
+	// InsertCountedLoad is InsertLoad for a file whose row count was advertised by the processor
+	InsertCountedLoad(load *Load, rowCount int64) error
+	// InsertLoads queues the loads in one transaction, returning which were duplicates
+	InsertLoads(loads []QueuedLoad) (duplicate []bool, err error)
+	PruneSeenKeys(olderThan time.Time) (int64, error)
+	// RecordDivertedLoad records that the file was sent to the dead-letter queue instead of queued
+	RecordDivertedLoad(load *Load, loadStatus string) error
+	NotifyNewTable(table string) error
 	ListDistinctTables() ([]string, error)
+	// KeepPercents returns the percent of files kept by each table with a sampling rule
+	KeepPercents() (map[string]int, error)
+	// ComponentDisabled returns whether the component is switched off
+	ComponentDisabled(component Component) (bool, error)
 	Close()
 }
 
+// LoadTrigger overrides the global load triggers for a single table. A nil trigger falls back
+// to the global one, and a CountTrigger of 0 means the table is loaded on age alone. DelaySeconds, if
+// set, holds the table's files back from loads until they've been queued that long, e.g. so upstream
+// dedup jobs can finish with them; a force load takes them regardless.
+type LoadTrigger struct {
+	Table             string
+	CountTrigger      *int
+	AgeTriggerSeconds *int
+	DelaySeconds      *int `json:",omitempty"`
+}
+
+// CopySettings overrides the COMPUPDATE and STATUPDATE options and the statement timeout of COPYs
+// into a single table, and may deduplicate its loads. An empty setting uses the default.
+type CopySettings struct {
+	Table              string
+	CompUpdate         string `json:",omitempty"`
+	StatUpdate         string `json:",omitempty"`
+	StatementTimeoutMs int    `json:",omitempty"`
+	// DedupKey is the comma-separated columns of the table's primary key; if set, loads only insert the
+	// first of the rows with a key that isn't in the table yet
+	DedupKey string `json:",omitempty"`
+}
+
+// DedupColumns returns the columns of the dedup key, or nil if loads aren't deduplicated.
+func (s CopySettings) DedupColumns() []string {
+	var cols []string
+	for _, col := range strings.Split(s.DedupKey, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// SamplingRule has the metadatastorer keep only KeepPercent percent of a table's files, dropping
+// the rest before they're queued; 0 drops all of them.
+type SamplingRule struct {
+	Table       string
+	KeepPercent int
+	Reason      string
+	Requester   string
+	Updated     time.Time
+}
+
+// FreshnessSLO is how long a table's files may wait before they're loaded, e.g. 900 seconds for
+// a table that must be loaded within 15 minutes.
+type FreshnessSLO struct {
+	Table         string
+	MaxAgeSeconds int
+	Reason        string
+	Requester     string
+	Updated       time.Time
+}
+
+// Component is a part of the ingester that can be switched off at runtime, surviving restarts.
+type Component string
+
+// The components.
+const (
+	// ComponentLoader is the loader's workers, which finish their loads but claim no more
+	ComponentLoader Component = "loader"
+	// ComponentMigrator is the migrator, which changes no tables
+	ComponentMigrator Component = "migrator"
+	// ComponentMetadatastorer is the metadatastorer's inserts; its messages stay on the queue
+	ComponentMetadatastorer Component = "metadatastorer"
+)
+
+// Components are the components that can be switched off.
+var Components = []Component{ComponentLoader, ComponentMigrator, ComponentMetadatastorer}
+
+// ValidComponent returns whether c is a component that can be switched off.
+func ValidComponent(c Component) bool {
+	for _, component := range Components {
+		if c == component {
+			return true
+		}
+	}
+	return false
+}
+
+// ComponentToggle switches a component off, or back on.
+type ComponentToggle struct {
+	Component Component
+	Disabled  bool
+	Reason    string
+	Requester string
+	Updated   time.Time
+}
+
+// PendingForceLoad is a force load requested but not started yet. Later requests for its table, e.g. by
+// operators and the migrator both, are merged into it.
+type PendingForceLoad struct {
+	ID        int64
+	Table     string
+	Requester string
+	Requested time.Time
+	// Merged is how many later requests were merged into it, the last at LastRequested
+	Merged        int
+	LastRequested *time.Time `json:",omitempty"`
+}
+
+// ForceLoadSchedule is when a table is force loaded, as a cron expression in UTC, e.g. "45 5 * * *"
+// for a table that must be caught up before a 6am job.
+type ForceLoadSchedule struct {
+	Table     string
+	Schedule  string
+	Reason    string
+	Requester string
+	Updated   time.Time
+}
+
+// PausedTable is a table whose loads don't start until it's resumed, e.g. during an incident. Its
+// files are still queued.
+type PausedTable struct {
+	Table     string
+	Reason    string
+	Requester string
+	Paused    time.Time
+}
+
+// VersionedTable is a version of a table whose files are loaded into <table>_v<version> while the
+// table waits to be migrated to it, rather than waiting with them.
+type VersionedTable struct {
+	Table   string
+	Version int
+	Created time.Time
+}
+
+// StragglerPolicy is how files of a version older than their table's, queued after the table was
+// migrated, are handled. Without one they stay queued.
+type StragglerPolicy string
+
+const (
+	// StragglerLoad loads the files into the table, with the columns the table gained since null and
+	// the ones it lost dropped
+	StragglerLoad StragglerPolicy = "load"
+	// StragglerDeadLetter moves the files to tsv_diverted with the load status "straggler"
+	StragglerDeadLetter StragglerPolicy = "dead_letter"
+	// StragglerTable loads the files into <table>_stragglers, created with the columns of the first
+	// version loaded into it, which later versions are padded or truncated to like StragglerLoad
+	StragglerTable StragglerPolicy = "stragglers"
+)
+
+// Valid returns whether the policy is one of the known ones.
+func (p StragglerPolicy) Valid() bool {
+	return p == StragglerLoad || p == StragglerDeadLetter || p == StragglerTable
+}
+
+// Loads returns whether the policy has the files loaded into Redshift.
+func (p StragglerPolicy) Loads() bool {
+	return p == StragglerLoad || p == StragglerTable
+}
+
+// StragglerRule is the StragglerPolicy of a table's straggling files.
+type StragglerRule struct {
+	Table     string
+	Policy    StragglerPolicy
+	Reason    string
+	Requester string
+	Updated   time.Time
+}
+
+// KeepsFile returns whether a table sampled at keepPercent keeps the file. The choice is a hash of
+// the key, so a redelivered file is kept or dropped like the first time.
+func KeepsFile(keyName string, keepPercent int) bool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(keyName))
+	return int(h.Sum32()%100) < keepPercent
+}
+
+// Annotation is an operator's free-text note on a table, or on a specific load of it if
+// LoadUUID is set.
+type Annotation struct {
+	ID       int64
+	Table    string
+	LoadUUID string `json:",omitempty"`
+	Note     string
+	Author   string
+	Created  time.Time
+}
+
+// MaintenanceWindow is a period during which no loads or migrations start, e.g. for a cluster
+// resize. A window without an End lasts until it's deleted.
+type MaintenanceWindow struct {
+	ID        int64
+	Start     time.Time
+	End       *time.Time `json:",omitempty"`
+	Reason    string
+	Requester string
+}
+
+// InventoryFileProgress is how far backfills of an S3 Inventory report got through one of its data
+// files, which are queued in chunks of a fixed number of lines.
+type InventoryFileProgress struct {
+	// Manifest is the s3:// URL of the report's manifest.json
+	Manifest string
+	// DataFile is the key of the data file in the report's bucket
+	DataFile string
+	// Lines is the number of the file's lines whose files have been queued or skipped
+	Lines int64
+	// Done is whether all the file's lines have been
+	Done      bool
+	Queued    int64
+	Duplicate int64
+	Skipped   int64
+	Updated   time.Time
+}
+
+// AuditEntry records a control request that changed state.
+type AuditEntry struct {
+	ID        int64
+	Time      time.Time
+	RequestID string
+	Caller    string
+	Method    string
+	Path      string
+	Params    string `json:",omitempty"`
+	Status    int
+	Error     string `json:",omitempty"`
+}
+
+// AuditFilter selects the most recent audit entries, optionally of one caller or since a time.
+type AuditFilter struct {
+	Caller string
+	Since  *time.Time
+	Limit  int
+}
+
+// LoadEventState is a state a load passes through in a worker.
+type LoadEventState string
+
+// The states of a load in a worker.
+const (
+	// EventAssigned is a load a worker took from LoadReady
+	EventAssigned LoadEventState = "assigned"
+	// EventAdmitted is a load whose COPY started, once Redshift was available and the governor let it
+	EventAdmitted LoadEventState = "admitted"
+	// EventSucceeded is a load whose COPY was committed, or that needed none
+	EventSucceeded LoadEventState = "succeeded"
+	// EventFailed is a load that failed, to be retried or not as its detail says
+	EventFailed LoadEventState = "failed"
+)
+
+// LoadEvent records a load's state transition in a worker, so what each worker was doing can be
+// reconstructed after a crash. Worker is the worker's host, number in the pool and goroutine ID.
+type LoadEvent struct {
+	ID       int64
+	Time     time.Time
+	LoadUUID string
+	Table    string
+	Worker   string
+	State    LoadEventState
+	Detail   string `json:",omitempty"`
+}
+
+// LoadEventFilter selects the most recent load events, optionally of one load or worker, or since a
+// time. Workers match by prefix, so a host's events can be selected.
+type LoadEventFilter struct {
+	LoadUUID string
+	Worker   string
+	Since    *time.Time
+	// AfterID, if set, pages through the events recorded after the one with this ID, oldest first,
+	// instead of selecting the most recent
+	AfterID *int64
+	Limit   int
+}
+
+// LoadSummary describes a manifest that has been claimed for loading.
+type LoadSummary struct {
+	UUID       string
+	Table      string
+	Files      int
+	RetryCount int
+	LastError  string     `json:",omitempty"`
+	RetryAt    *time.Time `json:",omitempty"`
+}
+
+// TableThroughput is what was loaded into a table over a window, and how often its loads failed.
+type TableThroughput struct {
+	Table string
+	// Loads is the number of loads completed, not counting simulated ones
+	Loads int64
+	Files int64
+	Rows  int64
+	Bytes int64
+	// AvgCopyMs is the average time the loads' COPYs took, over the loads whose time is known
+	AvgCopyMs int64
+	// Failures is the number of times loads of the table failed
+	Failures int64
+}
+
+// TableLoadError is a failure of a load of a table.
+type TableLoadError struct {
+	UUID string
+	Time time.Time
+	// Class is the error's class, e.g. serialization; empty if it wasn't classified
+	Class string
+	Error string
+}
+
+// LoadErrorCount is how many times loads of a table failed with a class of error in an hour.
+type LoadErrorCount struct {
+	Table string
+	// Class is the errors' class; empty for errors that weren't classified
+	Class string
+	// HoursAgo is the hour the errors happened in, counting back from now: 0 is the last hour
+	HoursAgo int
+	Count    int64
+}
+
+// LoadState is where a load is in its lifecycle.
+type LoadState string
+
+const (
+	// LoadInFlight loads are claimed by a loader, waiting for or being run by a worker
+	LoadInFlight LoadState = "in_flight"
+	// LoadFailed loads failed and are waiting to be retried
+	LoadFailed LoadState = "failed"
+	// LoadCommitted loads are done and recorded in load_history
+	LoadCommitted LoadState = "committed"
+)
+
+// ErrUnknownLoad is returned by LoadDetail for a UUID that's neither a manifest nor in load_history
+var ErrUnknownLoad = errors.New("no load with this UUID")
+
+// DroppedLoadStatus is the load status files of dropped tables are recorded as diverted with.
+const DroppedLoadStatus = "dropped"
+
+// StragglerLoadStatus is the load status files dead-lettered by StragglerDeadLetter are recorded with.
+const StragglerLoadStatus = "straggler"
+
+// ErrTableLoading is returned by DropTable and RenameTable while some of the table's files are being loaded.
+var ErrTableLoading = errors.New("the table's files are being loaded")
+
+// DroppedTable is a table torn down after its event was dropped.
+type DroppedTable struct {
+	Table   string
+	Version int
+	// Snapshot is the S3 prefix the table's rows were unloaded to before it was dropped, if any
+	Snapshot string
+	Dropped  time.Time
+}
+
+// RenamedTable is a table renamed after its event was renamed.
+type RenamedTable struct {
+	Table   string
+	NewName string
+	// Version is the version of the new name the table was renamed at
+	Version int
+	Renamed time.Time
+}
+
+// ErrUnknownVersionIncrement is returned by VersionIncrement for an ID that was never queued
+var ErrUnknownVersionIncrement = errors.New("no version increment with this ID")
+
+// VersionIncrement is a request to increment a table's version without a migration. It's kept in
+// ingesterdb from when it's queued, so it survives restarts until the migrator finishes it.
+type VersionIncrement struct {
+	ID        string
+	Table     string
+	Version   int
+	Requested time.Time
+	// Finished is when the migrator acknowledged the increment; nil while it's pending
+	Finished *time.Time `json:",omitempty"`
+	Error    string     `json:",omitempty"`
+}
+
+// LoadDetail is the whole state of a load, for diagnosing it. Files are only known until the load
+// is committed, when they're deleted from tsv; RowsLoaded, BytesScanned and LoadedAt are only
+// known after.
+type LoadDetail struct {
+	UUID         string
+	Table        string
+	State        LoadState
+	Files        []LoadFile `json:",omitempty"`
+	FileCount    int
+	RetryCount   int
+	RetryAt      *time.Time  `json:",omitempty"`
+	LastError    string      `json:",omitempty"`
+	Errors       []LoadFault `json:",omitempty"`
+	RowsLoaded   *int64      `json:",omitempty"`
+	BytesScanned *int64      `json:",omitempty"`
+	LoadedAt     *time.Time  `json:",omitempty"`
+	Simulated    bool        `json:",omitempty"`
+}
+
+// LoadFile is a file of a load.
+type LoadFile struct {
+	KeyName  string
+	Queued   time.Time
+	RowCount *int64 `json:",omitempty"`
+}
+
+// LoadFault is one of the errors a load failed with.
+type LoadFault struct {
+	Time  time.Time
+	Error string
+}
+
 // EventStats defines a set of statistics recorded for a particular event.
 type EventStats struct {
 	Event string
@@ -0,0 +1,74 @@
+package metadata
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySuffixes(t *testing.T) {
+	assert.Equal(t, CompressionGzip, CompressionForKey("bucket/table/v1/file.log.gz"))
+	assert.Equal(t, CompressionBzip2, CompressionForKey("bucket/table/v1/file.json.bz2"))
+	assert.Equal(t, CompressionZstd, CompressionForKey("bucket/table/v1/file.ndjson.zst"))
+	assert.Equal(t, CompressionZstd, CompressionForKey("bucket/table/v1/file.zstd"))
+	assert.Equal(t, CompressionGzip, CompressionForKey("bucket/table/v1/file"), "gzip is the default")
+
+	assert.Equal(t, LoadFormatJSON, FormatForKey("bucket/table/v1/file.json.bz2"))
+	assert.Equal(t, LoadFormatJSON, FormatForKey("bucket/table/v1/file.ndjson.zst"))
+	assert.Equal(t, LoadFormatTSV, FormatForKey("bucket/table/v1/file.log.gz"))
+
+	load := Load{KeyName: "file.gz", Compression: CompressionNone}
+	assert.Equal(t, CompressionNone, load.compression(), "the message's compression wins")
+	assert.True(t, ValidCompression(""))
+	assert.False(t, ValidCompression("lzop"))
+
+	load = Load{KeyName: "file.gz", Format: LoadFormatJSON}
+	assert.Equal(t, LoadFormatJSON, load.format(), "the message's format wins")
+	assert.True(t, ValidFormat(""))
+	assert.False(t, ValidFormat("csv"))
+}
+
+func TestKeepsFile(t *testing.T) {
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("bucket/table/v1/file-%d.gz", i)
+		assert.Equal(t, KeepsFile(key, 10), KeepsFile(key, 10), "the choice is stable")
+		assert.True(t, KeepsFile(key, 100))
+		assert.False(t, KeepsFile(key, 0))
+		if KeepsFile(key, 10) {
+			kept++
+		}
+	}
+	assert.InDelta(t, 1000, kept, 200)
+}
+
+func TestParseProcessedKey(t *testing.T) {
+	table, version, ok := ParseProcessedKey("2017-03-08/minute_watched/v12/processor-1.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "minute_watched", table)
+	assert.Equal(t, 12, version)
+	_, _, ok = ParseProcessedKey("2017-03-08/minute_watched/processor-1.gz")
+	assert.False(t, ok)
+}
+
+func TestProcessedKeyDate(t *testing.T) {
+	day := time.Date(2017, 3, 8, 0, 0, 0, 0, time.UTC)
+	date, ok := ProcessedKeyDate("bucket/2017-03-08/minute_watched/v12/processor-1.gz")
+	assert.True(t, ok)
+	assert.Equal(t, day, date)
+	date, ok = ProcessedKeyDate("bucket/processed/20170308/minute_watched/v12/region=eu/processor-1.gz")
+	assert.True(t, ok)
+	assert.Equal(t, day, date)
+	_, ok = ProcessedKeyDate("bucket/minute_watched/v12/processor-1.gz")
+	assert.False(t, ok)
+	_, ok = ProcessedKeyDate("bucket/2017-13-08/minute_watched/v12/processor-1.gz")
+	assert.False(t, ok)
+}
+
+func TestDedupColumns(t *testing.T) {
+	assert.Equal(t, []string{"user_id", "session_id"}, CopySettings{DedupKey: " user_id, session_id,"}.DedupColumns())
+	assert.Nil(t, CopySettings{}.DedupColumns())
+	assert.Nil(t, CopySettings{DedupKey: " , "}.DedupColumns())
+}
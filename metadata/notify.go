@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// newTableChannel is the postgres NOTIFY channel announcing tables queued for the first time.
+const newTableChannel = "new_table"
+
+// NotifyNewTable tells any TableListener that the table has been queued for the first time.
+func (b *postgresBackend) NotifyNewTable(table string) error {
+	_, err := b.db.Exec("SELECT pg_notify($1, $2)", newTableChannel, table)
+	if err != nil {
+		return fmt.Errorf("notifying of new table %s: %v", table, err)
+	}
+	return nil
+}
+
+// TableListener receives the tables announced by NotifyNewTable. Announcements made while it's
+// disconnected are lost, so it only speeds up finding new tables; it can't replace polling.
+type TableListener struct {
+	listener *pq.Listener
+	tables   chan string
+	// done is closed by Close, so forward doesn't block sending a table no one will receive
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// ListenForNewTables opens a connection to the db listening for new tables.
+func ListenForNewTables(dbURL string) (*TableListener, error) {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.WithError(err).Warn("New table listener connection event")
+		}
+	})
+	err := listener.Listen(newTableChannel)
+	if err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("listening for new tables: %v", err)
+	}
+	l := newTableListener(listener)
+	logger.Go(func() { l.forward(listener.Notify) })
+	return l, nil
+}
+
+func newTableListener(listener *pq.Listener) *TableListener {
+	return &TableListener{listener: listener, tables: make(chan string), done: make(chan struct{})}
+}
+
+// forward sends the tables announced on notify to l.tables until notify is closed or l is.
+func (l *TableListener) forward(notify <-chan *pq.Notification) {
+	defer close(l.tables)
+	for n := range notify {
+		// A nil notification means the connection was re-established; some may have been missed.
+		if n == nil {
+			continue
+		}
+		select {
+		case l.tables <- n.Extra:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Tables returns the channel of newly queued tables, closed when the listener is.
+func (l *TableListener) Tables() <-chan string {
+	return l.tables
+}
+
+// Close stops listening.
+func (l *TableListener) Close() error {
+	l.stop()
+	return l.listener.Close()
+}
+
+func (l *TableListener) stop() {
+	l.doneOnce.Do(func() { close(l.done) })
+}
@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableListenerForward(t *testing.T) {
+	l := newTableListener(nil)
+	notify := make(chan *pq.Notification, 3)
+	notify <- &pq.Notification{Extra: "chat"}
+	notify <- nil
+	notify <- &pq.Notification{Extra: "video"}
+	close(notify)
+	go l.forward(notify)
+
+	var tables []string
+	for table := range l.Tables() {
+		tables = append(tables, table)
+	}
+	assert.Equal(t, []string{"chat", "video"}, tables, "reconnections are skipped, and the tables closed with notify")
+}
+
+func TestTableListenerStop(t *testing.T) {
+	l := newTableListener(nil)
+	notify := make(chan *pq.Notification, 1)
+	notify <- &pq.Notification{Extra: "chat"}
+	forwarded := make(chan struct{})
+	go func() {
+		l.forward(notify)
+		close(forwarded)
+	}()
+
+	// No one receives the table, and notify stays open
+	l.stop()
+	l.stop()
+	select {
+	case <-forwarded:
+	case <-time.After(time.Second):
+		t.Fatal("forward blocked sending a table after the listener was closed")
+	}
+	_, open := <-l.Tables()
+	assert.False(t, open)
+}
@@ -8,12 +8,16 @@ import (
 	"flag"
 	"fmt"
 	"math/rand"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/lib/pq" // To register "postgres" with database/sql
+	"github.com/lib/pq" // Also registers "postgres" with database/sql
 	"github.com/pborman/uuid"
 	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
 	"github.com/twitchscience/rs_ingester/versions"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
@@ -24,6 +28,12 @@ type PGConfig struct {
 	LoadAgeTrigger   time.Duration
 	LoadCountTrigger int
 	MaxConnections   int
+	// OrderedLoads loads each table's files strictly in the order they were queued: a table isn't
+	// loaded while it has a load in flight or waiting to be retried, and each load takes only the
+	// table's oldest files up to one of another version, format or compression.
+	OrderedLoads bool
+	// Tables limits the tables loaded, including failed loads retried; nil loads every table
+	Tables *TableFilter
 }
 
 type loadChecker interface {
@@ -33,30 +43,53 @@ type loadChecker interface {
 type loadableTable struct {
 	name        string
 	version     int
+	format      LoadFormat
+	compression Compression
 	forceLoadID *int
+	// beforeID, if valid, limits an ordered load to files queued before the one with this ID
+	beforeID sql.NullInt64
+	// queuedBy, if set, limits the load to files queued by then, holding back those still in the
+	// table's delay
+	queuedBy *time.Time
+}
+
+// tableVersion is a version of a table.
+type tableVersion struct {
+	table   string
+	version int
 }
 
 type postgresBackend struct {
 	db             *sql.DB
 	cfg            *PGConfig
 	loadChecker    loadChecker
+	stats          monitoring.SafeStatter
 	wait           chan struct{}
 	loadReady      chan *LoadManifest
 	gracefulClose  chan struct{}
 	versions       versions.Getter
 	lastLoaded     map[string]time.Time
 	lastLoadedLock sync.RWMutex
+	// triggerLock guards cfg's global load triggers, which can change while loading
+	triggerLock sync.RWMutex
+	// paused is whether loadReadyWorker last found a maintenance window in progress
+	paused bool
+	// disabled is whether loadReadyWorker last found the loader switched off
+	disabled bool
+	// waiting is how many loads are being handed to a worker, accessed atomically
+	waiting int32
 }
 
 var (
-	errorNoTsvs             = errors.New("No tsvs were found with that manifest id")
-	errorNoLoads            = errors.New("Found no loads to do")
-	tableToLoadSearchSize   = 50
-	maxLoadRetryCount       int
-	dbRetryCount            int
-	noWorkDelay             time.Duration
-	errorRetryDelay         time.Duration
-	failedLoadCheckInterval time.Duration
+	errorNoTsvs              = errors.New("No tsvs were found with that manifest id")
+	errorNoLoads             = errors.New("Found no loads to do")
+	tableToLoadSearchSize    = 50
+	maxLoadRetryCount        int
+	dbRetryCount             int
+	noWorkDelay              time.Duration
+	errorRetryDelay          time.Duration
+	failedLoadCheckInterval  time.Duration
+	reconcileRecheckInterval time.Duration
 )
 
 func init() {
@@ -65,6 +98,7 @@ func init() {
 	flag.IntVar(&dbRetryCount, "max_db_retry", 10, "Number of times to retry a transaction")
 	flag.DurationVar(&errorRetryDelay, "error_retry_delay", time.Minute*15, "Time to wait to retry a load that errors")
 	flag.DurationVar(&failedLoadCheckInterval, "failed_load_check_interval", time.Minute, "How often to check for failed loads")
+	flag.DurationVar(&reconcileRecheckInterval, "reconcile_recheck_interval", time.Minute, "How often to recheck orphaned loads whose status couldn't be told on startup")
 }
 
 // NewPostgresReader configures a new postgres backend for reading only
@@ -102,11 +136,14 @@ func NewPostgresStorer(cfg *PGConfig) (Storer, error) {
 
 // NewPostgresLoader configures a new postgres backend for loading (or storing)
 // At backend configuration, we set a max number of tsvs for a table
-// and max count of tsvs before a load is triggered.
-func NewPostgresLoader(cfg *PGConfig, lChecker loadChecker, versions versions.Getter) (Backend, error) {
+// and max count of tsvs before a load is triggered. Loads left in flight by a previous loader are
+// reconciled with Redshift before it returns.
+func NewPostgresLoader(cfg *PGConfig, lChecker loadChecker, versions versions.Getter,
+	stats monitoring.SafeStatter) (Backend, error) {
 	b := &postgresBackend{
 		cfg:           cfg,
 		loadChecker:   lChecker,
+		stats:         stats,
 		loadReady:     make(chan *LoadManifest),
 		wait:          make(chan struct{}),
 		gracefulClose: make(chan struct{}),
@@ -119,10 +156,14 @@ func NewPostgresLoader(cfg *PGConfig, lChecker loadChecker, versions versions.Ge
 	}
 
 	logger.Info("Checking orphaned loads in PostgresLoader startup")
-	err = b.checkOrphanedLoads()
+	unresolved, err := b.reconcileOrphanedLoads()
 	if err != nil {
 		return nil, fmt.Errorf("checking orphaned loads: %s", err)
 	}
+	if len(unresolved) > 0 {
+		logger.WithField("unresolved", len(unresolved)).Warn("Some orphaned loads couldn't be reconciled yet")
+		logger.Go(func() { b.recheckOrphanedLoads(unresolved) })
+	}
 	logger.Info("Done checking orphaned loads in PostgresLoader startup")
 
 	logger.Info("Pulling table last loaded times from DB")
@@ -138,7 +179,7 @@ func NewPostgresLoader(cfg *PGConfig, lChecker loadChecker, versions versions.Ge
 	return b, nil
 }
 
-//execFnInTransaction takes a closure function of a request and runs it on redshift in a transaction
+// execFnInTransaction takes a closure function of a request and runs it on redshift in a transaction
 func (b *postgresBackend) execFnInTransaction(work func(*sql.Tx) error) error {
 	tx, err := b.db.Begin()
 	if err != nil {
@@ -217,87 +258,426 @@ func (b *postgresBackend) getTableNameFromUUID(tx *sql.Tx, uuid string) (string,
 	return tableName, nil
 }
 
-func (b *postgresBackend) checkOrphanedLoads() error {
-	rows, err := b.db.Query(`
-		SELECT DISTINCT m.uuid, t.tablename
-		FROM manifest m JOIN tsv t
-			ON m.uuid = t.manifest_uuid
-		WHERE m.retry_ts IS NULL`)
+// InsertLoad queues the load, unless its key has been queued before, in which case it returns
+// ErrDuplicateLoad. Keys are remembered in tsv_seen until pruned, so SQS redeliveries are ignored
+// even after the load is done or the storer restarts.
+func (b *postgresBackend) InsertLoad(load *Load) error {
+	return b.insertLoad(load, false, sql.NullInt64{})
+}
+
+// InsertCountedLoad queues a file with the row count the processor advertised for it, to check
+// the rows loaded against.
+func (b *postgresBackend) InsertCountedLoad(load *Load, rowCount int64) error {
+	return b.insertLoad(load, false, sql.NullInt64{Int64: rowCount, Valid: true})
+}
+
+// InsertBackfillLoad queues a file found by a backfill. Tables with only backfilled files queued are
+// loaded after those with files queued otherwise.
+func (b *postgresBackend) InsertBackfillLoad(load *Load) error {
+	return b.insertLoad(load, true, sql.NullInt64{})
+}
+
+func (b *postgresBackend) insertLoad(load *Load, backfill bool, rowCount sql.NullInt64) error {
+	now := time.Now().In(time.UTC)
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec("INSERT INTO tsv_seen (keyname, ts) VALUES ($1, $2) ON CONFLICT (keyname) DO NOTHING",
+		load.KeyName, now)
+	if err != nil {
+		return rollbackAndError(tx, err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return rollbackAndError(tx, err)
+	}
+	if inserted == 0 {
+		return rollbackAndError(tx, ErrDuplicateLoad)
+	}
+	_, err = tx.Exec(
+		"INSERT INTO tsv (tablename, keyname, tableversion, ts, format, compression, backfill, row_count, trace_parent) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		load.TableName,
+		load.KeyName,
+		load.TableVersion,
+		now,
+		load.format(),
+		load.compression(),
+		backfill,
+		rowCount,
+		nullableString(load.TraceParent),
+	)
+	if err != nil {
+		return rollbackAndError(tx, err)
+	}
+	return tx.Commit()
+}
+
+// InsertLoads queues the loads with one multi-row INSERT into each of tsv_seen and tsv, returning
+// which loads' keys had been queued before, like ErrDuplicateLoad from InsertLoad. If it errors,
+// none of the loads are queued.
+func (b *postgresBackend) InsertLoads(loads []QueuedLoad) ([]bool, error) {
+	return b.insertLoads(loads, false)
+}
+
+// InsertBackfillLoads queues files found by a backfill like InsertLoads, at the low priority of
+// InsertBackfillLoad.
+func (b *postgresBackend) InsertBackfillLoads(loads []*Load) ([]bool, error) {
+	queued := make([]QueuedLoad, len(loads))
+	for i, load := range loads {
+		queued[i] = QueuedLoad{Load: load}
+	}
+	return b.insertLoads(queued, true)
+}
+
+func (b *postgresBackend) insertLoads(loads []QueuedLoad, backfill bool) ([]bool, error) {
+	duplicate := make([]bool, len(loads))
+	if len(loads) == 0 {
+		return duplicate, nil
+	}
+	now := time.Now().In(time.UTC)
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	var args []interface{}
+	for _, queued := range loads {
+		values = append(values, fmt.Sprintf("($%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, queued.Load.KeyName, now)
+	}
+	rows, err := tx.Query("INSERT INTO tsv_seen (keyname, ts) VALUES "+strings.Join(values, ", ")+
+		" ON CONFLICT (keyname) DO NOTHING RETURNING keyname", args...)
+	if err != nil {
+		return nil, rollbackAndError(tx, fmt.Errorf("inserting seen keys: %v", err))
+	}
+	unseen := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			_ = rows.Close()
+			return nil, rollbackAndError(tx, fmt.Errorf("scanning seen keys: %v", err))
+		}
+		unseen[key] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, rollbackAndError(tx, fmt.Errorf("reading seen keys: %v", err))
+	}
+
+	values, args = nil, nil
+	for i, queued := range loads {
+		load := queued.Load
+		// A key in the batch twice is only queued once.
+		if !unseen[load.KeyName] {
+			duplicate[i] = true
+			continue
+		}
+		delete(unseen, load.KeyName)
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9))
+		args = append(args, load.TableName, load.KeyName, load.TableVersion, now, load.format(),
+			load.compression(), backfill, queued.RowCount, nullableString(load.TraceParent))
+	}
+	if len(values) > 0 {
+		_, err = tx.Exec("INSERT INTO tsv (tablename, keyname, tableversion, ts, format, compression, backfill, row_count, trace_parent) VALUES "+
+			strings.Join(values, ", "), args...)
+		if err != nil {
+			return nil, rollbackAndError(tx, fmt.Errorf("inserting loads: %v", err))
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing loads: %v", err)
+	}
+	return duplicate, nil
+}
+
+// PruneSeenKeys forgets keys first seen or diverted before olderThan, returning how many were forgotten.
+func (b *postgresBackend) PruneSeenKeys(olderThan time.Time) (int64, error) {
+	res, err := b.db.Exec("DELETE FROM tsv_seen WHERE ts < $1", olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("pruning seen keys: %v", err)
+	}
+	pruned, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	res, err = b.db.Exec("DELETE FROM tsv_diverted WHERE ts < $1", olderThan)
+	if err != nil {
+		return pruned, fmt.Errorf("pruning diverted keys: %v", err)
+	}
+	diverted, err := res.RowsAffected()
+	return pruned + diverted, err
+}
+
+// RecordDivertedLoad records that the file was sent to the dead-letter queue, so it isn't reported
+// as a gap. A file diverted twice keeps its first record.
+func (b *postgresBackend) RecordDivertedLoad(load *Load, loadStatus string) error {
+	_, err := b.db.Exec(`INSERT INTO tsv_diverted (keyname, tablename, load_status, ts) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (keyname) DO NOTHING`, load.KeyName, load.TableName, loadStatus, time.Now().In(time.UTC))
 	if err != nil {
+		return fmt.Errorf("recording diverted load: %v", err)
+	}
+	return nil
+}
+
+// DropTable records the table as dropped, so its files aren't loaded, and dead-letters its queued files.
+func (b *postgresBackend) DropTable(dropped DroppedTable) (int64, error) {
+	var drained int64
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		var loading bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM tsv WHERE tablename = $1 AND manifest_uuid IS NOT NULL)",
+			dropped.Table).Scan(&loading)
+		if err != nil {
+			return err
+		}
+		if loading {
+			return ErrTableLoading
+		}
+		_, err = tx.Exec("DELETE FROM dropped_table WHERE tablename = $1", dropped.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO dropped_table (tablename, version, snapshot, dropped) VALUES ($1, $2, $3, $4)",
+			dropped.Table, dropped.Version, nullableString(dropped.Snapshot), time.Now().In(time.UTC))
+		if err != nil {
+			return err
+		}
+		drained, err = drainTable(tx, dropped.Table, dropped.Version)
 		return err
+	})
+	if err == ErrTableLoading {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("dropping table: %v", err)
+	}
+	return drained, nil
+}
+
+// DrainDroppedTable dead-letters the queued files of a dropped table, up to the version it was
+// dropped at, that came in after it was dropped.
+func (b *postgresBackend) DrainDroppedTable(table string, version int) (int64, error) {
+	var drained int64
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) (err error) {
+		drained, err = drainTable(tx, table, version)
+		return
+	})
+	if err != nil {
+		return 0, fmt.Errorf("draining dropped table: %v", err)
+	}
+	return drained, nil
+}
+
+// drainTable moves the table's unclaimed files up to the version from tsv to tsv_diverted, returning how many.
+func drainTable(tx *sql.Tx, table string, version int) (int64, error) {
+	_, err := tx.Exec(`INSERT INTO tsv_diverted (keyname, tablename, load_status, ts)
+		SELECT keyname, tablename, $2, $3 FROM tsv
+		WHERE tablename = $1 AND tableversion <= $4 AND manifest_uuid IS NULL
+		ON CONFLICT (keyname) DO NOTHING`, table, DroppedLoadStatus, time.Now().In(time.UTC), version)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec("DELETE FROM tsv WHERE tablename = $1 AND tableversion <= $2 AND manifest_uuid IS NULL",
+		table, version)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ForgetDroppedTable deletes the record of the table's drop at the version, once its event is back.
+func (b *postgresBackend) ForgetDroppedTable(table string, version int) error {
+	_, err := b.db.Exec("DELETE FROM dropped_table WHERE tablename = $1 AND version = $2", table, version)
+	if err != nil {
+		return fmt.Errorf("forgetting dropped table: %v", err)
 	}
+	return nil
+}
 
+// DroppedTables returns the tables torn down after their event was dropped.
+func (b *postgresBackend) DroppedTables() ([]DroppedTable, error) {
+	rows, err := b.db.Query("SELECT tablename, version, snapshot, dropped FROM dropped_table ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying dropped tables: %v", err)
+	}
 	defer func() {
 		err = rows.Close()
 		if err != nil {
-			logger.WithError(err).Error("Error closing rows for orphan load check")
+			logger.WithError(err).Error("Error closing rows for dropped tables")
 		}
 	}()
 
-	orphans := map[string]string{}
+	dropped := []DroppedTable{}
 	for rows.Next() {
-		var uuid string
-		var tablename string
-		err = rows.Scan(&uuid, &tablename)
+		var table DroppedTable
+		var snapshot sql.NullString
+		err = rows.Scan(&table.Table, &table.Version, &snapshot, &table.Dropped)
 		if err != nil {
-			return fmt.Errorf("querying for orphaned loads: %v", err)
+			return nil, fmt.Errorf("scanning dropped table row: %v", err)
 		}
-		orphans[uuid] = tablename
+		table.Snapshot = snapshot.String
+		dropped = append(dropped, table)
 	}
+	return dropped, nil
+}
 
-	for orphanUUID, tablename := range orphans {
-		loadStatus, err := b.loadChecker.CheckLoad(orphanUUID)
+// RenameTable records the table as renamed, so its files aren't loaded under its old name, and moves
+// its queued files and last load time to the new name.
+func (b *postgresBackend) RenameTable(renamed RenamedTable) (int64, error) {
+	var moved int64
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		var loading bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM tsv WHERE tablename = $1 AND manifest_uuid IS NOT NULL)",
+			renamed.Table).Scan(&loading)
 		if err != nil {
-			return fmt.Errorf("checking orphaned load status: %s", err)
+			return err
 		}
-
-		err = b.execFnInTransaction(func(tx *sql.Tx) error {
-			var innerErr error
-			switch loadStatus {
-			case scoop_protocol.LoadComplete:
-				// If completed succesfully, delete tsv rows
-				logger.WithField("orphanUUID", orphanUUID).Info("Orphaned load is complete, marking done")
-				innerErr = b.loadDoneHelper(tx, orphanUUID, tablename, time.Now().In(time.UTC))
-
-			case scoop_protocol.LoadNotFound, scoop_protocol.LoadFailed:
-				// If load failed, mark for retry
-				logger.WithField("orphanUUID", orphanUUID).Info("Orphaned load failed, marking for retry")
-				innerErr = b.loadErrorHelper(tx, orphanUUID, "Orphan load on startup")
-
-			default:
-				logger.WithField("orphanUUID", orphanUUID).WithField("loadStatus", loadStatus).Error(
-					"Got unexpected load status from orphan load check")
-				return fmt.Errorf("unexpected load status from orphan load check: %s", loadStatus)
-			}
-			return innerErr
-		})
+		if loading {
+			return ErrTableLoading
+		}
+		_, err = tx.Exec("DELETE FROM renamed_table WHERE tablename = $1", renamed.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO renamed_table (tablename, new_name, version, renamed) VALUES ($1, $2, $3, $4)",
+			renamed.Table, renamed.NewName, renamed.Version, time.Now().In(time.UTC))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			UPDATE last_load SET tablename = $2
+			WHERE tablename = $1 AND NOT EXISTS (SELECT 1 FROM last_load WHERE tablename = $2)`,
+			renamed.Table, renamed.NewName)
 		if err != nil {
 			return err
 		}
+		moved, err = moveTable(tx, renamed)
+		return err
+	})
+	if err == ErrTableLoading {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("renaming table: %v", err)
+	}
+
+	b.lastLoadedLock.Lock()
+	defer b.lastLoadedLock.Unlock()
+	if lastLoaded, ok := b.lastLoaded[renamed.Table]; ok {
+		if _, ok = b.lastLoaded[renamed.NewName]; !ok {
+			b.lastLoaded[renamed.NewName] = lastLoaded
+		}
+		delete(b.lastLoaded, renamed.Table)
 	}
+	return moved, nil
+}
 
-	return nil
+// MoveRenamedTable moves the files of a renamed table queued under its old name since it was renamed.
+func (b *postgresBackend) MoveRenamedTable(renamed RenamedTable) (int64, error) {
+	var moved int64
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) (err error) {
+		moved, err = moveTable(tx, renamed)
+		return
+	})
+	if err != nil {
+		return 0, fmt.Errorf("moving files of renamed table: %v", err)
+	}
+	return moved, nil
 }
 
-func (b *postgresBackend) InsertLoad(load *Load) error {
-	_, err := b.db.Exec(
-		"INSERT INTO tsv (tablename, keyname, tableversion, ts) VALUES ($1, $2, $3, $4)",
-		load.TableName,
-		load.KeyName,
-		load.TableVersion,
-		time.Now().In(time.UTC),
-	)
-	return err
+// moveTable moves the renamed table's unclaimed files to its new name at the version it was renamed at,
+// so they're loaded with the new name's columns, returning how many.
+func moveTable(tx *sql.Tx, renamed RenamedTable) (int64, error) {
+	res, err := tx.Exec(`UPDATE tsv SET tablename = $2, tableversion = $3
+		WHERE tablename = $1 AND manifest_uuid IS NULL`, renamed.Table, renamed.NewName, renamed.Version)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RenamedTables returns the tables renamed after their event was renamed.
+func (b *postgresBackend) RenamedTables() ([]RenamedTable, error) {
+	rows, err := b.db.Query("SELECT tablename, new_name, version, renamed FROM renamed_table ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying renamed tables: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for renamed tables")
+		}
+	}()
+
+	renamed := []RenamedTable{}
+	for rows.Next() {
+		var table RenamedTable
+		err = rows.Scan(&table.Table, &table.NewName, &table.Version, &table.Renamed)
+		if err != nil {
+			return nil, fmt.Errorf("scanning renamed table row: %v", err)
+		}
+		renamed = append(renamed, table)
+	}
+	return renamed, nil
+}
+
+// AccountedKeys returns which of the S3 keys were queued, whether or not they've been loaded since,
+// or diverted to the dead-letter queue.
+func (b *postgresBackend) AccountedKeys(keys []string) (map[string]bool, error) {
+	accounted := make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return accounted, nil
+	}
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = key
+	}
+	in := strings.Join(placeholders, ", ")
+	rows, err := b.db.Query("SELECT keyname FROM tsv_seen WHERE keyname IN ("+in+
+		") UNION SELECT keyname FROM tsv_diverted WHERE keyname IN ("+in+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying accounted keys: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for accounted keys")
+		}
+	}()
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, fmt.Errorf("scanning accounted key: %v", err)
+		}
+		accounted[key] = true
+	}
+	return accounted, rows.Err()
 }
 
 func (b *postgresBackend) LoadReady() chan *LoadManifest {
 	return b.loadReady
 }
 
-func (b *postgresBackend) LoadDone(manifestUUID string, tableName string) {
+// TableFilter returns the filter of the tables loaded, which may be nil.
+func (b *postgresBackend) TableFilter() *TableFilter {
+	return b.cfg.Tables
+}
+
+// WaitingLoads returns how many claimed loads are waiting for a worker. LoadReady is unbuffered, so
+// a load waits while all of the workers are busy.
+func (b *postgresBackend) WaitingLoads() int {
+	return int(atomic.LoadInt32(&b.waiting))
+}
+
+func (b *postgresBackend) LoadDone(manifestUUID string, tableName string, stats *LoadStats) {
 	doneTime := time.Now().In(time.UTC)
 	err := retryInTransaction(dbRetryCount, b.db, func(tx *sql.Tx) error {
-		return b.loadDoneHelper(tx, manifestUUID, tableName, doneTime)
+		return b.loadDoneHelper(tx, manifestUUID, tableName, doneTime, stats)
 	})
 	if err != nil {
 		logger.WithError(err).WithField("manifestUUID", manifestUUID).
@@ -306,9 +686,53 @@ func (b *postgresBackend) LoadDone(manifestUUID string, tableName string) {
 	}
 }
 
-func (b *postgresBackend) LoadError(manifestUUID string, loadError string) {
+// LoadChunkDone deletes the chunk's keys from the load and records the chunk, in one transaction.
+func (b *postgresBackend) LoadChunkDone(manifestUUID string, tableName string, keys []string, stats *LoadStats) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	doneTime := time.Now().In(time.UTC)
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, len(keys)+1)
+	args[0] = manifestUUID
+	for i, key := range keys {
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+		args[i+1] = key
+	}
+	return retryInTransaction(dbRetryCount, b.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec("DELETE FROM tsv WHERE manifest_uuid = $1 AND keyname IN ("+
+			strings.Join(placeholders, ", ")+")", args...)
+		if err != nil {
+			return fmt.Errorf("deleting chunk's files: %v", err)
+		}
+		files, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO load_chunk (uuid, tablename, files, rows_loaded, bytes_scanned, copy_ms, loaded_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			manifestUUID, tableName, files, stats.RowsLoaded, stats.BytesScanned,
+			int64(stats.CopyDuration/time.Millisecond), doneTime)
+		if err != nil {
+			return fmt.Errorf("recording chunk: %v", err)
+		}
+		_, err = tx.Exec("DELETE FROM last_load WHERE tablename = $1", tableName)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO last_load (tablename, last_loaded) VALUES ($1, $2)", tableName, doneTime)
+		if err != nil {
+			return err
+		}
+		b.updateLastLoad(tableName, doneTime)
+		return nil
+	})
+}
+
+func (b *postgresBackend) LoadError(manifestUUID, loadError, class string, retryDelay time.Duration) {
 	err := retryInTransaction(dbRetryCount, b.db, func(tx *sql.Tx) error {
-		return b.loadErrorHelper(tx, manifestUUID, loadError)
+		return b.loadErrorHelper(tx, manifestUUID, loadError, class, retryDelay)
 	})
 	if err != nil {
 		logger.WithError(err).WithField("manifestUUID", manifestUUID).
@@ -351,12 +775,54 @@ func (b *postgresBackend) Close() {
 	}
 }
 
-// Non-committing load done helper function
-func (b *postgresBackend) loadDoneHelper(tx *sql.Tx, manifestUUID string, tableName string, doneTime time.Time) error {
-	_, err := tx.Exec("DELETE FROM tsv WHERE manifest_uuid = $1", manifestUUID)
+// Non-committing load done helper function. stats is nil if the load's size isn't known, e.g.
+// for orphaned loads found to have completed.
+func (b *postgresBackend) loadDoneHelper(tx *sql.Tx, manifestUUID string, tableName string, doneTime time.Time,
+	stats *LoadStats) error {
+	res, err := tx.Exec("DELETE FROM tsv WHERE manifest_uuid = $1", manifestUUID)
+	if err != nil {
+		return err
+	}
+	files, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	var rowsLoaded, bytesScanned, copyMs sql.NullInt64
+	simulated := stats != nil && stats.Simulated
+	if stats != nil && !simulated {
+		rowsLoaded = sql.NullInt64{Int64: stats.RowsLoaded, Valid: true}
+		bytesScanned = sql.NullInt64{Int64: stats.BytesScanned, Valid: true}
+		copyMs = sql.NullInt64{Int64: int64(stats.CopyDuration / time.Millisecond), Valid: true}
+	}
+	// A load COPYed in chunks has already had its files deleted, chunk by chunk, over all its attempts
+	var chunks, chunkFiles int64
+	var chunkRows, chunkBytes, chunkMs sql.NullInt64
+	err = tx.QueryRow(`SELECT COUNT(*), COALESCE(SUM(files), 0), SUM(rows_loaded), SUM(bytes_scanned), SUM(copy_ms)
+		FROM load_chunk WHERE uuid = $1`, manifestUUID).Scan(&chunks, &chunkFiles, &chunkRows, &chunkBytes, &chunkMs)
+	if err != nil {
+		return fmt.Errorf("summing load's chunks: %v", err)
+	}
+	if chunks > 0 {
+		files += chunkFiles
+		rowsLoaded, bytesScanned, copyMs = chunkRows, chunkBytes, chunkMs
+	}
+	_, err = tx.Exec(`
+		INSERT INTO load_history (uuid, tablename, files, rows_loaded, bytes_scanned, loaded_at, simulated, copy_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		manifestUUID, tableName, files, rowsLoaded, bytesScanned, doneTime, simulated, copyMs)
 	if err != nil {
 		return err
 	}
+	if stats.RowMismatch() {
+		_, err = tx.Exec(`
+			INSERT INTO load_row_mismatch (uuid, tablename, expected_rows, lines_scanned, rows_loaded, detected_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			manifestUUID, tableName, stats.ExpectedRows, stats.LinesScanned, stats.RowsLoaded, doneTime)
+		if err != nil {
+			return fmt.Errorf("recording row mismatch: %v", err)
+		}
+	}
 
 	_, err = tx.Exec("DELETE FROM manifest WHERE uuid = $1", manifestUUID)
 	if err != nil {
@@ -390,11 +856,23 @@ func (b *postgresBackend) updateLastLoad(table string, llTime time.Time) {
 	b.lastLoaded[table] = llTime
 }
 
-func (b *postgresBackend) loadErrorHelper(tx *sql.Tx, manifestUUID, loadError string) error {
+func (b *postgresBackend) loadErrorHelper(tx *sql.Tx, manifestUUID, loadError, class string,
+	retryDelay time.Duration) error {
+	now := time.Now().In(time.UTC)
+	if retryDelay <= 0 {
+		retryDelay = errorRetryDelay
+	}
 	_, err := tx.Exec("UPDATE manifest SET retry_ts = $1, last_error = $2 WHERE uuid = $3",
-		time.Now().In(time.UTC).Add(errorRetryDelay),
+		now.Add(retryDelay),
 		loadError,
 		manifestUUID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO load_error (uuid, ts, error, tablename, class)
+		VALUES ($1, $2, $3, (SELECT tablename FROM tsv WHERE manifest_uuid = $1 LIMIT 1), $4)`,
+		manifestUUID, now, loadError, nullableString(class))
 	return err
 }
 
@@ -402,8 +880,15 @@ func (b *postgresBackend) loadReadyWorker() {
 	logger.Info("Starting loadReadyWorker.")
 	defer logger.Info("loadReadyWorker stopped.")
 
-	var lastFailedLoadCheck time.Time
+	var lastFailedLoadCheck, lastStragglerCheck time.Time
 	for {
+		if b.inMaintenanceWindow() || b.loaderDisabled() {
+			if b.sleepUnlessClosed(noWorkDelay) {
+				return
+			}
+			continue
+		}
+
 		var failed *LoadManifest
 
 		if time.Now().In(time.UTC).Sub(lastFailedLoadCheck) > failedLoadCheckInterval {
@@ -417,7 +902,7 @@ func (b *postgresBackend) loadReadyWorker() {
 			})
 			if err == nil {
 				if failed != nil {
-					b.loadReady <- failed
+					b.handOff(failed)
 					continue
 				}
 				lastFailedLoadCheck = time.Now().In(time.UTC)
@@ -426,6 +911,13 @@ func (b *postgresBackend) loadReadyWorker() {
 			}
 		}
 
+		if time.Now().In(time.UTC).Sub(lastStragglerCheck) > failedLoadCheckInterval {
+			if err := b.deadLetterStragglers(); err != nil {
+				logger.WithError(err).Error("Error dead-lettering straggling files")
+			}
+			lastStragglerCheck = time.Now().In(time.UTC)
+		}
+
 		var manifest *LoadManifest
 
 		err := retrying(dbRetryCount, func() error {
@@ -439,20 +931,73 @@ func (b *postgresBackend) loadReadyWorker() {
 
 		sleepDelay := noWorkDelay
 		if manifest != nil {
-			b.loadReady <- manifest
+			b.handOff(manifest)
 			sleepDelay = time.Millisecond * 10
 		}
 
-		select {
-		case <-time.After(sleepDelay):
-		case <-b.wait:
-			close(b.loadReady)
-			close(b.gracefulClose)
+		if b.sleepUnlessClosed(sleepDelay) {
 			return
 		}
 	}
 }
 
+// handOff sends the load to a worker, counting it as waiting until one takes it.
+func (b *postgresBackend) handOff(manifest *LoadManifest) {
+	atomic.AddInt32(&b.waiting, 1)
+	defer atomic.AddInt32(&b.waiting, -1)
+	b.loadReady <- manifest
+}
+
+// sleepUnlessClosed waits for the delay, returning true instead if the backend is closed first.
+func (b *postgresBackend) sleepUnlessClosed(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return false
+	case <-b.wait:
+		close(b.loadReady)
+		close(b.gracefulClose)
+		return true
+	}
+}
+
+// inMaintenanceWindow returns whether loads are paused for maintenance, logging when they pause
+// and resume. Loads aren't paused if the windows can't be checked.
+func (b *postgresBackend) inMaintenanceWindow() bool {
+	paused, err := b.InMaintenance()
+	if err != nil {
+		logger.WithError(err).Error("Error checking for maintenance window")
+		return false
+	}
+	if paused != b.paused {
+		if paused {
+			logger.Info("Pausing loads for maintenance window")
+		} else {
+			logger.Info("Resuming loads after maintenance window")
+		}
+		b.paused = paused
+	}
+	return paused
+}
+
+// loaderDisabled returns whether the loader is switched off, logging when it's switched off and on.
+// Loads aren't held up if the toggle can't be checked.
+func (b *postgresBackend) loaderDisabled() bool {
+	disabled, err := b.ComponentDisabled(ComponentLoader)
+	if err != nil {
+		logger.WithError(err).Error("Error checking loader toggle")
+		return false
+	}
+	if disabled != b.disabled {
+		if disabled {
+			logger.Info("Pausing loads; the loader is switched off")
+		} else {
+			logger.Info("Resuming loads; the loader is switched back on")
+		}
+		b.disabled = disabled
+	}
+	return disabled
+}
+
 // Check for failed loads, marking them as done if they actually succeeded. If retriable, returns
 // them to be added to the load queue
 func (b *postgresBackend) fetchFailedLoad() (*LoadManifest, error) {
@@ -463,7 +1008,7 @@ func (b *postgresBackend) fetchFailedLoad() (*LoadManifest, error) {
 		var status scoop_protocol.LoadStatus
 		err = b.execFnInTransaction(func(tx *sql.Tx) error {
 			var innerErr error
-			loadUUID, lastError, innerErr = failedLoadMetadata(tx)
+			loadUUID, lastError, innerErr = failedLoadMetadata(tx, b.cfg.Tables)
 			if loadUUID == "" || innerErr != nil { // no more failed loads or an error
 				return innerErr
 			}
@@ -484,7 +1029,7 @@ func (b *postgresBackend) fetchFailedLoad() (*LoadManifest, error) {
 				return fmt.Errorf("retrieving table name: %s", innerErr)
 			}
 			doneTime := time.Now().In(time.UTC)
-			innerErr = b.loadDoneHelper(tx, loadUUID, tableName, doneTime)
+			innerErr = b.loadDoneHelper(tx, loadUUID, tableName, doneTime, nil)
 			if innerErr != nil {
 				return fmt.Errorf("load done helper: %s", innerErr)
 			}
@@ -510,13 +1055,20 @@ func (b *postgresBackend) fetchFailedLoad() (*LoadManifest, error) {
 	err := b.execFnInTransaction(func(tx *sql.Tx) error {
 		var innerErr error
 		tsv, innerErr = getLoadManifest(tx, loadUUID)
-		return innerErr
+		if innerErr != nil {
+			return innerErr
+		}
+		if innerErr = b.routeVersioned(tx, tsv); innerErr != nil {
+			return innerErr
+		}
+		return b.routeStraggler(tx, tsv)
 	})
 	return tsv, err
 }
 
-func failedLoadMetadata(tx *sql.Tx) (loadUUID string, lastError string, err error) {
+func failedLoadMetadata(tx *sql.Tx, tables *TableFilter) (loadUUID string, lastError string, err error) {
 	now := time.Now().In(time.UTC)
+	include, exclude := tables.regexps()
 	rows, err := tx.Query(`
 		UPDATE manifest
 		SET retry_ts = null, retry_count = retry_count + 1
@@ -524,11 +1076,19 @@ func failedLoadMetadata(tx *sql.Tx) (loadUUID string, lastError string, err erro
 			SELECT uuid
 			FROM manifest
 			WHERE retry_ts IS NOT NULL AND retry_ts < $1 AND retry_count < $2
+			AND (($3 = '' AND $4 = '') OR EXISTS (
+				SELECT 1 FROM tsv
+				WHERE tsv.manifest_uuid = manifest.uuid
+				AND ($3 = '' OR tsv.tablename ~ $3)
+				AND NOT ($4 <> '' AND tsv.tablename ~ $4)))
+			AND NOT EXISTS (
+				SELECT 1 FROM tsv JOIN paused_table ON tsv.tablename = paused_table.tablename
+				WHERE tsv.manifest_uuid = manifest.uuid)
 			ORDER BY retry_ts ASC
 			LIMIT 1
 		)
 		RETURNING uuid, last_error
-		`, now, maxLoadRetryCount)
+		`, now, maxLoadRetryCount, include, exclude)
 
 	if err != nil {
 		logger.WithError(err).Error("Error querying for failed loads")
@@ -579,13 +1139,42 @@ func (b *postgresBackend) PingDB() error {
 	return nil
 }
 
+// SetLoadTriggers replaces the global load triggers, used by tables without their own.
+func (b *postgresBackend) SetLoadTriggers(count int, age time.Duration) {
+	b.triggerLock.Lock()
+	defer b.triggerLock.Unlock()
+	b.cfg.LoadCountTrigger = count
+	b.cfg.LoadAgeTrigger = age
+}
+
+// loadTriggers returns the global load triggers.
+func (b *postgresBackend) loadTriggers() (int, time.Duration) {
+	b.triggerLock.RLock()
+	defer b.triggerLock.RUnlock()
+	return b.cfg.LoadCountTrigger, b.cfg.LoadAgeTrigger
+}
+
 func (b *postgresBackend) findTableVersionToLoad(tx *sql.Tx) (*loadableTable, error) {
-	rows, err := tx.Query(`
-		SELECT tablename, tableversion, force_load_id FROM
+	include, exclude := b.cfg.Tables.regexps()
+	countTrigger, ageTrigger := b.loadTriggers()
+	versioned, err := versionedTables(tx)
+	if err != nil {
+		return nil, err
+	}
+	stragglers, err := stragglerPolicies(tx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(time.UTC)
+	rows, err := tx.Query(`
+		SELECT a.tablename, tableversion, format, compression, force_load_id, load_trigger.delay_seconds FROM
 			(SELECT tsv.tablename,
 				tableversion,
+				format,
+				compression,
 				min(tsv.ts) AS oldest,
 				unstarted_force_load.id AS force_load_id,
+				bool_and(tsv.backfill) AS backfill_only,
 				count(*) AS cnt
 			FROM tsv LEFT JOIN (
 				SELECT id, tablename
@@ -593,14 +1182,38 @@ func (b *postgresBackend) findTableVersionToLoad(tx *sql.Tx) (*loadableTable, er
 				WHERE force_load.started IS NULL
 			) AS unstarted_force_load
 			ON tsv.tablename=unstarted_force_load.tablename
+			LEFT JOIN load_trigger delay ON tsv.tablename = delay.tablename
 			WHERE manifest_uuid IS NULL
-			GROUP BY tsv.tablename, tableversion, force_load_id) a
-		WHERE (cnt > $1 OR oldest < $2 OR force_load_id IS NOT NULL)
-		ORDER BY force_load_id ASC, oldest ASC
-		LIMIT $3`,
-		b.cfg.LoadCountTrigger,
-		time.Now().In(time.UTC).Add(-b.cfg.LoadAgeTrigger),
+			AND (unstarted_force_load.id IS NOT NULL
+				OR tsv.ts <= $2::timestamp - COALESCE(delay.delay_seconds, 0) * INTERVAL '1 second')
+			GROUP BY tsv.tablename, tableversion, format, compression, force_load_id) a
+		LEFT JOIN load_trigger ON a.tablename = load_trigger.tablename
+		WHERE (
+			CASE
+				WHEN load_trigger.count_trigger IS NULL THEN cnt > $1
+				WHEN load_trigger.count_trigger > 0 THEN cnt > load_trigger.count_trigger
+				ELSE FALSE
+			END
+			OR oldest < $2::timestamp - COALESCE(load_trigger.age_trigger_seconds, $3) * INTERVAL '1 second'
+			OR force_load_id IS NOT NULL)
+		AND NOT ($5 AND EXISTS (
+			SELECT 1 FROM tsv claimed
+			WHERE claimed.tablename = a.tablename AND claimed.manifest_uuid IS NOT NULL))
+		AND NOT EXISTS (SELECT 1 FROM dropped_table
+			WHERE dropped_table.tablename = a.tablename AND a.tableversion <= dropped_table.version)
+		AND NOT EXISTS (SELECT 1 FROM renamed_table WHERE renamed_table.tablename = a.tablename)
+		AND NOT EXISTS (SELECT 1 FROM paused_table WHERE paused_table.tablename = a.tablename)
+		AND ($6 = '' OR a.tablename ~ $6)
+		AND NOT ($7 <> '' AND a.tablename ~ $7)
+		ORDER BY force_load_id ASC, backfill_only ASC, oldest ASC
+		LIMIT $4`,
+		countTrigger,
+		now,
+		int(ageTrigger/time.Second),
 		tableToLoadSearchSize,
+		b.cfg.OrderedLoads,
+		include,
+		exclude,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("Error finding potential tables to load: %v", err)
@@ -614,22 +1227,39 @@ func (b *postgresBackend) findTableVersionToLoad(tx *sql.Tx) (*loadableTable, er
 	}()
 
 	var tableToLoad loadableTable
+	var candidates []loadableTable
 	found := false
 	for rows.Next() && !found {
-		if err = rows.Scan(&tableToLoad.name, &tableToLoad.version, &tableToLoad.forceLoadID); err != nil {
+		var delay sql.NullInt64
+		if err = rows.Scan(&tableToLoad.name, &tableToLoad.version, &tableToLoad.format, &tableToLoad.compression,
+			&tableToLoad.forceLoadID, &delay); err != nil {
 			return nil, fmt.Errorf("Error parsing rows when looking for potential tables to load: %v", err)
 		}
+		tableToLoad.queuedBy = delayedCutoff(now, delay, tableToLoad.forceLoadID != nil)
+		if b.cfg.OrderedLoads {
+			candidates = append(candidates, tableToLoad)
+			continue
+		}
 		currentVersion, exists := b.versions.Get(tableToLoad.name)
-		if exists && tableToLoad.version < currentVersion {
+		policy := stragglers[tableToLoad.name]
+		// Files dead-lettered by their policy are left for deadLetterStragglers
+		if exists && tableToLoad.version < currentVersion && policy == "" {
 			logger.WithField("table", tableToLoad.name).
 				WithField("outdatedVersion", tableToLoad.version).
 				WithField("currentVersion", currentVersion).
 				Error("Found a TSV with an outdated version")
 		}
-		if exists && tableToLoad.version == currentVersion {
+		if exists && loadableVersion(tableToLoad.name, tableToLoad.version, currentVersion, versioned, policy) {
 			found = true
 		}
 	}
+	if b.cfg.OrderedLoads {
+		// The candidates' runs are looked up with the same connection, so the rows must be closed.
+		if err = rows.Close(); err != nil {
+			return nil, fmt.Errorf("closing potential tables to load: %v", err)
+		}
+		return b.findOrderedRun(tx, candidates, versioned, stragglers)
+	}
 	if !found {
 		logger.Info("Found no loads to do")
 		return nil, errorNoLoads
@@ -637,6 +1267,197 @@ func (b *postgresBackend) findTableVersionToLoad(tx *sql.Tx) (*loadableTable, er
 	return &tableToLoad, nil
 }
 
+// delayedCutoff returns when files must have been queued by to be loaded now by a table with the
+// delay, or nil if all its files can be: it has no delay, or is being force loaded.
+func delayedCutoff(now time.Time, delaySeconds sql.NullInt64, forced bool) *time.Time {
+	if forced || !delaySeconds.Valid || delaySeconds.Int64 <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-time.Duration(delaySeconds.Int64) * time.Second)
+	return &cutoff
+}
+
+// loadableVersion returns whether files of the table's version can be loaded: they're of its current
+// version, of a later one loaded into a versioned table, or of an earlier one the table's straggler
+// policy loads.
+func loadableVersion(table string, version, currentVersion int, versioned map[tableVersion]bool,
+	policy StragglerPolicy) bool {
+	switch {
+	case version > currentVersion:
+		return versioned[tableVersion{table, version}]
+	case version < currentVersion:
+		return policy.Loads()
+	}
+	return true
+}
+
+// versionedTables returns the table versions whose files are loaded into versioned tables.
+func versionedTables(tx *sql.Tx) (map[tableVersion]bool, error) {
+	rows, err := tx.Query("SELECT tablename, tableversion FROM versioned_table")
+	if err != nil {
+		return nil, fmt.Errorf("finding versioned tables: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for versioned tables")
+		}
+	}()
+	versioned := make(map[tableVersion]bool)
+	for rows.Next() {
+		var tv tableVersion
+		if err = rows.Scan(&tv.table, &tv.version); err != nil {
+			return nil, fmt.Errorf("scanning versioned table row: %v", err)
+		}
+		versioned[tv] = true
+	}
+	return versioned, rows.Err()
+}
+
+// routeVersioned marks the manifest Versioned if its files are of a version its table hasn't been
+// migrated to yet but that is loaded into a versioned table. It's decided each time the load is
+// fetched, so a retried load goes to the table itself once the versioned table is merged into it.
+func (b *postgresBackend) routeVersioned(tx *sql.Tx, manifest *LoadManifest) error {
+	manifest.Versioned = false
+	currentVersion, exists := b.versions.Get(manifest.TableName)
+	if !exists || manifest.Version <= currentVersion {
+		return nil
+	}
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM versioned_table WHERE tablename = $1 AND tableversion = $2)`,
+		manifest.TableName, manifest.Version).Scan(&manifest.Versioned)
+	if err != nil {
+		return fmt.Errorf("checking for versioned table: %v", err)
+	}
+	return nil
+}
+
+// stragglerPolicies returns the straggler policy of each table with a straggler rule.
+func stragglerPolicies(tx *sql.Tx) (map[string]StragglerPolicy, error) {
+	rows, err := tx.Query("SELECT tablename, policy FROM straggler_rule")
+	if err != nil {
+		return nil, fmt.Errorf("finding straggler rules: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for straggler rules")
+		}
+	}()
+	policies := make(map[string]StragglerPolicy)
+	for rows.Next() {
+		var table string
+		var policy StragglerPolicy
+		if err = rows.Scan(&table, &policy); err != nil {
+			return nil, fmt.Errorf("scanning straggler rule row: %v", err)
+		}
+		policies[table] = policy
+	}
+	return policies, rows.Err()
+}
+
+// routeStraggler sets the manifest's Straggler policy if its files are of a version older than its
+// table's and the table's straggler rule loads them. Like routeVersioned, it's decided each time the
+// load is fetched.
+func (b *postgresBackend) routeStraggler(tx *sql.Tx, manifest *LoadManifest) error {
+	manifest.Straggler = ""
+	currentVersion, exists := b.versions.Get(manifest.TableName)
+	if !exists || manifest.Version >= currentVersion {
+		return nil
+	}
+	var policy StragglerPolicy
+	err := tx.QueryRow("SELECT policy FROM straggler_rule WHERE tablename = $1", manifest.TableName).Scan(&policy)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("finding straggler rule: %v", err)
+	}
+	if policy.Loads() {
+		manifest.Straggler = policy
+	}
+	return nil
+}
+
+// deadLetterStragglers moves the unclaimed files of versions older than their table's, of tables whose
+// straggler rule dead-letters them, from tsv to tsv_diverted.
+func (b *postgresBackend) deadLetterStragglers() error {
+	rows, err := b.db.Query(`SELECT DISTINCT tsv.tablename, tsv.tableversion FROM tsv
+		JOIN straggler_rule ON tsv.tablename = straggler_rule.tablename
+		WHERE tsv.manifest_uuid IS NULL AND straggler_rule.policy = $1`, string(StragglerDeadLetter))
+	if err != nil {
+		return fmt.Errorf("finding straggling files: %v", err)
+	}
+	var straggling []tableVersion
+	for rows.Next() {
+		var tv tableVersion
+		if err = rows.Scan(&tv.table, &tv.version); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scanning straggling files row: %v", err)
+		}
+		currentVersion, exists := b.versions.Get(tv.table)
+		if exists && tv.version < currentVersion && b.cfg.Tables.Allows(tv.table) {
+			straggling = append(straggling, tv)
+		}
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+
+	for _, tv := range straggling {
+		var diverted int64
+		err = retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`INSERT INTO tsv_diverted (keyname, tablename, load_status, ts)
+				SELECT keyname, tablename, $3, $4 FROM tsv
+				WHERE tablename = $1 AND tableversion = $2 AND manifest_uuid IS NULL
+				ON CONFLICT (keyname) DO NOTHING`, tv.table, tv.version, StragglerLoadStatus, time.Now().In(time.UTC))
+			if err != nil {
+				return err
+			}
+			res, err := tx.Exec("DELETE FROM tsv WHERE tablename = $1 AND tableversion = $2 AND manifest_uuid IS NULL",
+				tv.table, tv.version)
+			if err != nil {
+				return err
+			}
+			diverted, err = res.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("dead-lettering straggling files of %s: %v", tv.table, err)
+		}
+		logger.WithField("table", tv.table).WithField("version", tv.version).WithField("files", diverted).
+			Warn("Dead-lettered straggling files of an outdated version")
+	}
+	return nil
+}
+
+// findOrderedRun returns the first candidate table whose oldest queued files are loadable, narrowed to
+// the run of those files before any of another version, format or compression.
+func (b *postgresBackend) findOrderedRun(tx *sql.Tx, candidates []loadableTable,
+	versioned map[tableVersion]bool, stragglers map[string]StragglerPolicy) (*loadableTable, error) {
+	for _, candidate := range candidates {
+		table := candidate
+		err := tx.QueryRow(`SELECT tableversion, format, compression FROM tsv
+			WHERE tablename = $1 AND manifest_uuid IS NULL ORDER BY id LIMIT 1`, table.name).
+			Scan(&table.version, &table.format, &table.compression)
+		if err != nil {
+			return nil, fmt.Errorf("finding oldest queued file of %s: %v", table.name, err)
+		}
+		currentVersion, exists := b.versions.Get(table.name)
+		if !exists || !loadableVersion(table.name, table.version, currentVersion, versioned, stragglers[table.name]) {
+			logger.WithField("table", table.name).WithField("oldestVersion", table.version).
+				WithField("currentVersion", currentVersion).
+				Info("Not loading table; its oldest queued file isn't of a loadable version")
+			continue
+		}
+		err = tx.QueryRow(`SELECT MIN(id) FROM tsv
+			WHERE tablename = $1 AND manifest_uuid IS NULL AND (tableversion <> $2 OR format <> $3 OR compression <> $4)`,
+			table.name, table.version, table.format, table.compression).Scan(&table.beforeID)
+		if err != nil {
+			return nil, fmt.Errorf("finding end of ordered run of %s: %v", table.name, err)
+		}
+		return &table, nil
+	}
+	logger.Info("Found no loads to do")
+	return nil, errorNoLoads
+}
+
 // fetchLoad returns the next load to do, or nil if there is no load available.
 func (b *postgresBackend) fetchLoad() (*LoadManifest, error) {
 	tx, err := b.db.Begin()
@@ -671,11 +1492,19 @@ func (b *postgresBackend) fetchLoad() (*LoadManifest, error) {
 		`UPDATE tsv SET manifest_uuid = $1
          WHERE tablename = $2
          AND tableversion = $3
+         AND format = $4
+         AND compression = $5
          AND manifest_uuid IS NULL
+         AND ($6::BIGINT IS NULL OR id < $6)
+         AND ($7::TIMESTAMP IS NULL OR ts <= $7)
         `,
 		manifestUUID,
 		tableToLoad.name,
 		tableToLoad.version,
+		tableToLoad.format,
+		tableToLoad.compression,
+		tableToLoad.beforeID,
+		tableToLoad.queuedBy,
 	)
 
 	if err != nil {
@@ -702,6 +1531,12 @@ func (b *postgresBackend) fetchLoad() (*LoadManifest, error) {
 		}
 		return nil, rollbackAndError(tx, err)
 	}
+	if err = b.routeVersioned(tx, tsv); err != nil {
+		return nil, rollbackAndError(tx, err)
+	}
+	if err = b.routeStraggler(tx, tsv); err != nil {
+		return nil, rollbackAndError(tx, err)
+	}
 
 	return tsv, tx.Commit()
 }
@@ -710,7 +1545,7 @@ func getLoadManifest(tx *sql.Tx, manifestUUID string) (*LoadManifest, error) {
 	var manifest LoadManifest
 	manifest.UUID = manifestUUID
 
-	rows, err := tx.Query("SELECT keyname, tablename FROM tsv WHERE manifest_uuid = $1", manifestUUID)
+	rows, err := tx.Query("SELECT keyname, tablename, tableversion, format, compression, ts, row_count, trace_parent FROM tsv WHERE manifest_uuid = $1 ORDER BY id", manifestUUID)
 	if err != nil {
 		return nil, err
 	}
@@ -721,26 +1556,65 @@ func getLoadManifest(tx *sql.Tx, manifestUUID string) (*LoadManifest, error) {
 			logger.WithError(err).Error("Error closing rows for load manifest")
 		}
 	}()
+	counted := true
 	for rows.Next() {
 		var load Load
-		err := rows.Scan(&load.KeyName, &load.TableName)
+		var queued time.Time
+		var rowCount sql.NullInt64
+		var traceParent sql.NullString
+		err := rows.Scan(&load.KeyName, &load.TableName, &load.TableVersion, &manifest.Format, &load.Compression, &queued,
+			&rowCount, &traceParent)
 		if err != nil {
 			logger.WithError(err).Error("Scan threw an error")
 			return nil, err
 		}
+		counted = counted && rowCount.Valid
+		manifest.ExpectedRows.Int64 += rowCount.Int64
+		if rowCount.Valid {
+			load.RowCount = &rowCount.Int64
+		}
+		load.TraceParent = traceParent.String
 
 		manifest.Loads = append(manifest.Loads, load)
+		manifest.Queued = append(manifest.Queued, queued)
 	}
 
 	if len(manifest.Loads) == 0 {
 		return nil, errorNoTsvs
 	}
+	manifest.ExpectedRows.Valid = counted
 
 	manifest.TableName = manifest.Loads[0].TableName
+	manifest.Version = manifest.Loads[0].TableVersion
+	manifest.Compression = manifest.Loads[0].Compression
+	manifest.CopySettings, err = getCopySettings(tx, manifest.TableName)
+	if err != nil {
+		return nil, err
+	}
 
 	return &manifest, nil
 }
 
+// getCopySettings returns the table's COPY settings, which are empty if it has no overrides.
+func getCopySettings(tx *sql.Tx, table string) (CopySettings, error) {
+	settings := CopySettings{Table: table}
+	var compUpdate, statUpdate, dedupKey sql.NullString
+	var timeoutMs sql.NullInt64
+	err := tx.QueryRow(`SELECT compupdate, statupdate, statement_timeout_ms, dedup_key FROM copy_settings
+		WHERE tablename = $1`, table).Scan(&compUpdate, &statUpdate, &timeoutMs, &dedupKey)
+	switch {
+	case err == sql.ErrNoRows:
+		return settings, nil
+	case err != nil:
+		return settings, fmt.Errorf("querying copy settings: %v", err)
+	}
+	settings.CompUpdate = compUpdate.String
+	settings.StatUpdate = statUpdate.String
+	settings.StatementTimeoutMs = int(timeoutMs.Int64)
+	settings.DedupKey = dedupKey.String
+	return settings, nil
+}
+
 func retrying(retryCount int, f func() error) (err error) {
 	for ; retryCount > 0; retryCount-- {
 		err = f()
@@ -811,29 +1685,60 @@ func (b *postgresBackend) TSVVersionExists(table string, version int) (bool, err
 	return exists, nil
 }
 
+// ForceLoad requests a force load of the table. The force_load_pending index allows a table one pending
+// force load, so a request for a table that has one is merged into it.
 func (b *postgresBackend) ForceLoad(table string, requester string) error {
-	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
-		row := tx.QueryRow(
-			"SELECT EXISTS(SELECT 1 FROM force_load WHERE tablename = $1 AND started IS NULL)",
-			table)
-		var exists bool
-		err := row.Scan(&exists)
-		if err != nil {
-			return fmt.Errorf("Got error checking for existing force load: %v", err)
+	_, err := b.db.Exec(
+		`INSERT INTO force_load (tablename, requester, ts) VALUES ($1, $2, NOW())
+		ON CONFLICT (tablename) WHERE started IS NULL
+		DO UPDATE SET merged = force_load.merged + 1, last_requested = NOW()`,
+		table, requester,
+	)
+	if err != nil {
+		return fmt.Errorf("forcing load: %v", err)
+	}
+	return nil
+}
+
+// PendingForceLoads returns the force loads that haven't started, oldest first.
+func (b *postgresBackend) PendingForceLoads() ([]PendingForceLoad, error) {
+	rows, err := b.db.Query(
+		`SELECT id, tablename, COALESCE(requester, ''), ts, merged, last_requested FROM force_load
+		WHERE started IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying pending force loads: %v", err)
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil {
+			logger.WithError(cerr).Error("Error closing pending force load rows")
 		}
-		if exists {
-			return nil
+	}()
+	pending := []PendingForceLoad{}
+	for rows.Next() {
+		var p PendingForceLoad
+		var lastRequested pq.NullTime
+		if err = rows.Scan(&p.ID, &p.Table, &p.Requester, &p.Requested, &p.Merged, &lastRequested); err != nil {
+			return nil, fmt.Errorf("scanning pending force load: %v", err)
 		}
-		_, err = tx.Exec(
-			"INSERT INTO force_load (tablename, requester, ts) VALUES ($1, $2, NOW())",
-			table, requester,
-		)
-		return err
-	})
+		if lastRequested.Valid {
+			p.LastRequested = &lastRequested.Time
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// CancelForceLoad deletes the table's pending force load, returning whether it had one.
+func (b *postgresBackend) CancelForceLoad(table string) (bool, error) {
+	result, err := b.db.Exec("DELETE FROM force_load WHERE tablename = $1 AND started IS NULL", table)
 	if err != nil {
-		return fmt.Errorf("forcing load: %v", err)
+		return false, fmt.Errorf("canceling force load: %v", err)
 	}
-	return err
+	canceled, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("canceling force load: %v", err)
+	}
+	return canceled > 0, nil
 }
 
 func (b *postgresBackend) IsForceLoadRequested(table string) (bool, error) {
@@ -847,119 +1752,1346 @@ func (b *postgresBackend) IsForceLoadRequested(table string) (bool, error) {
 	return requested, nil
 }
 
-func findOrCreateStat(loadStats *PendingLoadStats, event string) *EventStats {
-	for _, s := range loadStats.Stats {
-		if s.Event == event {
-			return s
+// LoadTriggers returns all per-table load trigger overrides.
+func (b *postgresBackend) LoadTriggers() ([]LoadTrigger, error) {
+	rows, err := b.db.Query(
+		"SELECT tablename, count_trigger, age_trigger_seconds, delay_seconds FROM load_trigger ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying load triggers: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for load triggers")
+		}
+	}()
+
+	triggers := []LoadTrigger{}
+	for rows.Next() {
+		var trigger LoadTrigger
+		var countTrigger, ageTrigger, delay sql.NullInt64
+		err = rows.Scan(&trigger.Table, &countTrigger, &ageTrigger, &delay)
+		if err != nil {
+			return nil, fmt.Errorf("scanning load trigger row: %v", err)
+		}
+		if countTrigger.Valid {
+			c := int(countTrigger.Int64)
+			trigger.CountTrigger = &c
 		}
+		if ageTrigger.Valid {
+			a := int(ageTrigger.Int64)
+			trigger.AgeTriggerSeconds = &a
+		}
+		if delay.Valid {
+			d := int(delay.Int64)
+			trigger.DelaySeconds = &d
+		}
+		triggers = append(triggers, trigger)
 	}
-	eventStats := &EventStats{Event: event}
-	loadStats.Stats = append(loadStats.Stats, eventStats)
-	return eventStats
+	return triggers, nil
 }
 
-func updateStats(loadStats *PendingLoadStats, event string, cnt int64, minTS time.Time) {
-	if cnt == 0 {
-		return
+// SetLoadTrigger creates or replaces the load trigger override for a table.
+func (b *postgresBackend) SetLoadTrigger(trigger LoadTrigger) error {
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM load_trigger WHERE tablename = $1", trigger.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO load_trigger (tablename, count_trigger, age_trigger_seconds, delay_seconds) VALUES ($1, $2, $3, $4)",
+			trigger.Table, nullableInt(trigger.CountTrigger), nullableInt(trigger.AgeTriggerSeconds),
+			nullableInt(trigger.DelaySeconds))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("setting load trigger: %v", err)
 	}
-	eventStats := findOrCreateStat(loadStats, event)
-	eventStats.Count += cnt
-	if eventStats.MinTS.IsZero() || eventStats.MinTS.After(minTS) {
-		eventStats.MinTS = minTS
+	return nil
+}
+
+// DeleteLoadTrigger removes the load trigger override for a table, reverting it to the global triggers.
+func (b *postgresBackend) DeleteLoadTrigger(table string) error {
+	_, err := b.db.Exec("DELETE FROM load_trigger WHERE tablename = $1", table)
+	if err != nil {
+		return fmt.Errorf("deleting load trigger: %v", err)
 	}
+	return nil
 }
 
-// StatsForPendingLoads returns aggregates stats for each type of pending load classification.
-func (b *postgresBackend) StatsForPendingLoads() ([]*PendingLoadStats, error) {
-	rows, err := b.db.Query(`
-		SELECT
-			tablename,
-			tableversion,
-			is_stale,
-			min(ts),
-			count(*)
-		FROM (
-			SELECT
-				tablename,
-				tableversion,
-				retry_count IS NOT NULL AND retry_count >= $1 AS is_stale,
-				ts
-			FROM tsv
-			LEFT JOIN manifest
-				ON manifest_uuid=uuid
-		) tbl
-		GROUP BY 1, 2, 3
-	`, maxLoadRetryCount)
+// CopySettings returns the per-table COPY setting overrides.
+func (b *postgresBackend) CopySettings() ([]CopySettings, error) {
+	rows, err := b.db.Query(
+		"SELECT tablename, compupdate, statupdate, statement_timeout_ms, dedup_key FROM copy_settings ORDER BY tablename")
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %v", err)
+		return nil, fmt.Errorf("querying copy settings: %v", err)
 	}
 	defer func() {
 		err = rows.Close()
 		if err != nil {
-			logger.WithError(err).Error("error closing rows")
+			logger.WithError(err).Error("Error closing rows for copy settings")
 		}
 	}()
 
-	// Since we don't store the current event versions in the db, we'll take the returned groups
-	// and identify which ones are pending migration in the logic below
-	var table string
-	var version int64
-	var isStale bool
-	var minTS time.Time
-	var cnt int64
-	inQueueStats := &PendingLoadStats{Type: PendingInQueue, Stats: []*EventStats{}}
-	staleStats := &PendingLoadStats{Type: PendingStale, Stats: []*EventStats{}}
-	pendingMigrationStats := &PendingLoadStats{Type: PendingMigration, Stats: []*EventStats{}}
+	allSettings := []CopySettings{}
 	for rows.Next() {
-		err = rows.Scan(&table, &version, &isStale, &minTS, &cnt)
+		var settings CopySettings
+		var compUpdate, statUpdate, dedupKey sql.NullString
+		var timeoutMs sql.NullInt64
+		err = rows.Scan(&settings.Table, &compUpdate, &statUpdate, &timeoutMs, &dedupKey)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning event rows: %v", err)
+			return nil, fmt.Errorf("scanning copy settings row: %v", err)
 		}
-		if isStale {
-			updateStats(staleStats, table, cnt, minTS)
-		} else {
-			currentVersion, ok := b.versions.Get(table)
-			if ok && int64(currentVersion) < version {
-				updateStats(pendingMigrationStats, table, cnt, minTS)
-			} else {
-				updateStats(inQueueStats, table, cnt, minTS)
-			}
+		settings.CompUpdate = compUpdate.String
+		settings.StatUpdate = statUpdate.String
+		settings.StatementTimeoutMs = int(timeoutMs.Int64)
+		settings.DedupKey = dedupKey.String
+		allSettings = append(allSettings, settings)
+	}
+	return allSettings, nil
+}
+
+// SetCopySettings creates or replaces the COPY setting overrides for a table.
+func (b *postgresBackend) SetCopySettings(settings CopySettings) error {
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM copy_settings WHERE tablename = $1", settings.Table)
+		if err != nil {
+			return err
+		}
+		var timeoutMs sql.NullInt64
+		if settings.StatementTimeoutMs > 0 {
+			timeoutMs = sql.NullInt64{Int64: int64(settings.StatementTimeoutMs), Valid: true}
 		}
+		_, err = tx.Exec(`INSERT INTO copy_settings (tablename, compupdate, statupdate, statement_timeout_ms, dedup_key)
+			VALUES ($1, $2, $3, $4, $5)`,
+			settings.Table, nullableString(settings.CompUpdate), nullableString(settings.StatUpdate), timeoutMs,
+			nullableString(settings.DedupKey))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("setting copy settings: %v", err)
 	}
-	pendingLoadStats := []*PendingLoadStats{inQueueStats, staleStats, pendingMigrationStats}
-	return pendingLoadStats, nil
+	return nil
 }
 
-// ListDistinctTables returns all the tables in tsv
-func (b *postgresBackend) ListDistinctTables() ([]string, error) {
-	rows, err := b.db.Query("SELECT DISTINCT tablename FROM tsv")
+// DeleteCopySettings removes the COPY setting overrides for a table, reverting it to the defaults.
+func (b *postgresBackend) DeleteCopySettings(table string) error {
+	_, err := b.db.Exec("DELETE FROM copy_settings WHERE tablename = $1", table)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %v", err)
+		return fmt.Errorf("deleting copy settings: %v", err)
+	}
+	return nil
+}
+
+// SamplingRules returns the per-table sampling rules.
+func (b *postgresBackend) SamplingRules() ([]SamplingRule, error) {
+	rows, err := b.db.Query(
+		"SELECT tablename, keep_percent, reason, requester, updated FROM sampling_rule ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying sampling rules: %v", err)
 	}
 	defer func() {
 		err = rows.Close()
 		if err != nil {
-			logger.WithError(err).Error("error closing rows")
+			logger.WithError(err).Error("Error closing rows for sampling rules")
 		}
 	}()
 
-	var table string
-	var distinctTables []string
-
+	rules := []SamplingRule{}
 	for rows.Next() {
-		err = rows.Scan(&table)
+		var rule SamplingRule
+		var reason, requester sql.NullString
+		err = rows.Scan(&rule.Table, &rule.KeepPercent, &reason, &requester, &rule.Updated)
 		if err != nil {
-			return nil, fmt.Errorf("error scanning event rows: %v", err)
+			return nil, fmt.Errorf("scanning sampling rule row: %v", err)
 		}
-		distinctTables = append(distinctTables, table)
+		rule.Reason = reason.String
+		rule.Requester = requester.String
+		rules = append(rules, rule)
 	}
-	return distinctTables, nil
+	return rules, nil
 }
 
-// GetLastLoad returns all known last load times for all tables
-func (b *postgresBackend) GetLastLoads() map[string]time.Time {
-	b.lastLoadedLock.RLock()
-	defer b.lastLoadedLock.RUnlock()
+// KeepPercents returns the percent of files kept by each table with a sampling rule.
+func (b *postgresBackend) KeepPercents() (map[string]int, error) {
+	rules, err := b.SamplingRules()
+	if err != nil {
+		return nil, err
+	}
+	percents := make(map[string]int, len(rules))
+	for _, rule := range rules {
+		percents[rule.Table] = rule.KeepPercent
+	}
+	return percents, nil
+}
 
-	return b.lastLoaded
+// SetSamplingRule creates or replaces the sampling rule for a table.
+func (b *postgresBackend) SetSamplingRule(rule SamplingRule) error {
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM sampling_rule WHERE tablename = $1", rule.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO sampling_rule (tablename, keep_percent, reason, requester, updated)
+			VALUES ($1, $2, $3, $4, $5)`, rule.Table, rule.KeepPercent, nullableString(rule.Reason),
+			nullableString(rule.Requester), time.Now().In(time.UTC))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("setting sampling rule: %v", err)
+	}
+	return nil
+}
+
+// DeleteSamplingRule removes the sampling rule for a table, so all its files are queued.
+func (b *postgresBackend) DeleteSamplingRule(table string) error {
+	_, err := b.db.Exec("DELETE FROM sampling_rule WHERE tablename = $1", table)
+	if err != nil {
+		return fmt.Errorf("deleting sampling rule: %v", err)
+	}
+	return nil
+}
+
+// StragglerRules returns the per-table straggler rules.
+func (b *postgresBackend) StragglerRules() ([]StragglerRule, error) {
+	rows, err := b.db.Query(
+		"SELECT tablename, policy, reason, requester, updated FROM straggler_rule ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying straggler rules: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for straggler rules")
+		}
+	}()
+
+	rules := []StragglerRule{}
+	for rows.Next() {
+		var rule StragglerRule
+		var reason, requester sql.NullString
+		err = rows.Scan(&rule.Table, &rule.Policy, &reason, &requester, &rule.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("scanning straggler rule row: %v", err)
+		}
+		rule.Reason = reason.String
+		rule.Requester = requester.String
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// SetStragglerRule creates or replaces the straggler rule for a table.
+func (b *postgresBackend) SetStragglerRule(rule StragglerRule) error {
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM straggler_rule WHERE tablename = $1", rule.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO straggler_rule (tablename, policy, reason, requester, updated)
+			VALUES ($1, $2, $3, $4, $5)`, rule.Table, string(rule.Policy), nullableString(rule.Reason),
+			nullableString(rule.Requester), time.Now().In(time.UTC))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("setting straggler rule: %v", err)
+	}
+	return nil
+}
+
+// DeleteStragglerRule removes the straggler rule for a table, so its straggling files stay queued.
+func (b *postgresBackend) DeleteStragglerRule(table string) error {
+	_, err := b.db.Exec("DELETE FROM straggler_rule WHERE tablename = $1", table)
+	if err != nil {
+		return fmt.Errorf("deleting straggler rule: %v", err)
+	}
+	return nil
+}
+
+// FreshnessSLOs returns the per-table freshness SLOs.
+func (b *postgresBackend) FreshnessSLOs() ([]FreshnessSLO, error) {
+	rows, err := b.db.Query(
+		"SELECT tablename, max_age_seconds, reason, requester, updated FROM freshness_slo ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying freshness SLOs: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for freshness SLOs")
+		}
+	}()
+
+	slos := []FreshnessSLO{}
+	for rows.Next() {
+		var slo FreshnessSLO
+		var reason, requester sql.NullString
+		err = rows.Scan(&slo.Table, &slo.MaxAgeSeconds, &reason, &requester, &slo.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("scanning freshness SLO row: %v", err)
+		}
+		slo.Reason = reason.String
+		slo.Requester = requester.String
+		slos = append(slos, slo)
+	}
+	return slos, nil
+}
+
+// SetFreshnessSLO creates or replaces the freshness SLO for a table.
+func (b *postgresBackend) SetFreshnessSLO(slo FreshnessSLO) error {
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM freshness_slo WHERE tablename = $1", slo.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO freshness_slo (tablename, max_age_seconds, reason, requester, updated)
+			VALUES ($1, $2, $3, $4, $5)`, slo.Table, slo.MaxAgeSeconds, nullableString(slo.Reason),
+			nullableString(slo.Requester), time.Now().In(time.UTC))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("setting freshness SLO: %v", err)
+	}
+	return nil
+}
+
+// DeleteFreshnessSLO removes the freshness SLO for a table, falling back to the config's, if any.
+func (b *postgresBackend) DeleteFreshnessSLO(table string) error {
+	_, err := b.db.Exec("DELETE FROM freshness_slo WHERE tablename = $1", table)
+	if err != nil {
+		return fmt.Errorf("deleting freshness SLO: %v", err)
+	}
+	return nil
+}
+
+// ForceLoadSchedules returns the per-table force load schedules.
+func (b *postgresBackend) ForceLoadSchedules() ([]ForceLoadSchedule, error) {
+	rows, err := b.db.Query(
+		"SELECT tablename, schedule, reason, requester, updated FROM force_load_schedule ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying force load schedules: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for force load schedules")
+		}
+	}()
+
+	schedules := []ForceLoadSchedule{}
+	for rows.Next() {
+		var schedule ForceLoadSchedule
+		var reason, requester sql.NullString
+		err = rows.Scan(&schedule.Table, &schedule.Schedule, &reason, &requester, &schedule.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("scanning force load schedule row: %v", err)
+		}
+		schedule.Reason = reason.String
+		schedule.Requester = requester.String
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// SetForceLoadSchedule creates or replaces the force load schedule for a table.
+func (b *postgresBackend) SetForceLoadSchedule(schedule ForceLoadSchedule) error {
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM force_load_schedule WHERE tablename = $1", schedule.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO force_load_schedule (tablename, schedule, reason, requester, updated)
+			VALUES ($1, $2, $3, $4, $5)`, schedule.Table, schedule.Schedule, nullableString(schedule.Reason),
+			nullableString(schedule.Requester), time.Now().In(time.UTC))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("setting force load schedule: %v", err)
+	}
+	return nil
+}
+
+// DeleteForceLoadSchedule removes the force load schedule for a table, falling back to the config's, if any.
+func (b *postgresBackend) DeleteForceLoadSchedule(table string) error {
+	_, err := b.db.Exec("DELETE FROM force_load_schedule WHERE tablename = $1", table)
+	if err != nil {
+		return fmt.Errorf("deleting force load schedule: %v", err)
+	}
+	return nil
+}
+
+// PausedTables returns the tables whose loads are paused.
+func (b *postgresBackend) PausedTables() ([]PausedTable, error) {
+	rows, err := b.db.Query("SELECT tablename, reason, requester, paused FROM paused_table ORDER BY tablename")
+	if err != nil {
+		return nil, fmt.Errorf("querying paused tables: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for paused tables")
+		}
+	}()
+
+	paused := []PausedTable{}
+	for rows.Next() {
+		var table PausedTable
+		var reason, requester sql.NullString
+		err = rows.Scan(&table.Table, &reason, &requester, &table.Paused)
+		if err != nil {
+			return nil, fmt.Errorf("scanning paused table row: %v", err)
+		}
+		table.Reason = reason.String
+		table.Requester = requester.String
+		paused = append(paused, table)
+	}
+	return paused, nil
+}
+
+// PauseTable pauses a table's loads, replacing the reason and requester if it's already paused.
+func (b *postgresBackend) PauseTable(paused PausedTable) error {
+	err := retryInTransaction(1, b.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM paused_table WHERE tablename = $1", paused.Table)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO paused_table (tablename, reason, requester, paused)
+			VALUES ($1, $2, $3, $4)`, paused.Table, nullableString(paused.Reason),
+			nullableString(paused.Requester), time.Now().In(time.UTC))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("pausing table: %v", err)
+	}
+	return nil
+}
+
+// ResumeTable lets a paused table's loads start again.
+func (b *postgresBackend) ResumeTable(table string) error {
+	_, err := b.db.Exec("DELETE FROM paused_table WHERE tablename = $1", table)
+	if err != nil {
+		return fmt.Errorf("resuming table: %v", err)
+	}
+	return nil
+}
+
+// VersionedTables returns the table versions loaded into versioned tables, by table and version.
+func (b *postgresBackend) VersionedTables() ([]VersionedTable, error) {
+	rows, err := b.db.Query(`SELECT tablename, tableversion, created FROM versioned_table
+		ORDER BY tablename, tableversion`)
+	if err != nil {
+		return nil, fmt.Errorf("querying versioned tables: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for versioned tables")
+		}
+	}()
+
+	versioned := []VersionedTable{}
+	for rows.Next() {
+		var table VersionedTable
+		err = rows.Scan(&table.Table, &table.Version, &table.Created)
+		if err != nil {
+			return nil, fmt.Errorf("scanning versioned table row: %v", err)
+		}
+		versioned = append(versioned, table)
+	}
+	return versioned, nil
+}
+
+// AddVersionedTable routes the loads of the table's version into its versioned table, which must
+// already exist. Adding a version that's already routed does nothing.
+func (b *postgresBackend) AddVersionedTable(table string, version int) error {
+	_, err := b.db.Exec(`INSERT INTO versioned_table (tablename, tableversion, created)
+		SELECT $1, $2, $3 WHERE NOT EXISTS (
+			SELECT 1 FROM versioned_table WHERE tablename = $1 AND tableversion = $2)`,
+		table, version, time.Now().In(time.UTC))
+	if err != nil {
+		return fmt.Errorf("adding versioned table: %v", err)
+	}
+	return nil
+}
+
+// RemoveVersionedTable stops routing the loads of the table's version into its versioned table.
+func (b *postgresBackend) RemoveVersionedTable(table string, version int) error {
+	_, err := b.db.Exec("DELETE FROM versioned_table WHERE tablename = $1 AND tableversion = $2", table, version)
+	if err != nil {
+		return fmt.Errorf("removing versioned table: %v", err)
+	}
+	return nil
+}
+
+// VersionLoading returns whether any of the table's files of the version are claimed by a load.
+func (b *postgresBackend) VersionLoading(table string, version int) (bool, error) {
+	var loading bool
+	err := b.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM tsv
+		WHERE tablename = $1 AND tableversion = $2 AND manifest_uuid IS NOT NULL)`, table, version).Scan(&loading)
+	if err != nil {
+		return false, fmt.Errorf("checking for loads of version: %v", err)
+	}
+	return loading, nil
+}
+
+// QueueVersionIncrement stores a request to increment the table to the version.
+func (b *postgresBackend) QueueVersionIncrement(id string, table string, version int) error {
+	_, err := b.db.Exec(`INSERT INTO version_increment (id, tablename, version, requested)
+		VALUES ($1, $2, $3, $4)`, id, table, version, time.Now().In(time.UTC))
+	if err != nil {
+		return fmt.Errorf("queuing version increment: %v", err)
+	}
+	return nil
+}
+
+// PendingVersionIncrements returns the version increments the migrator hasn't finished, oldest first.
+func (b *postgresBackend) PendingVersionIncrements() ([]VersionIncrement, error) {
+	rows, err := b.db.Query(`SELECT id, tablename, version, requested FROM version_increment
+		WHERE finished IS NULL ORDER BY requested`)
+	if err != nil {
+		return nil, fmt.Errorf("querying pending version increments: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for pending version increments")
+		}
+	}()
+
+	increments := []VersionIncrement{}
+	for rows.Next() {
+		var increment VersionIncrement
+		err = rows.Scan(&increment.ID, &increment.Table, &increment.Version, &increment.Requested)
+		if err != nil {
+			return nil, fmt.Errorf("scanning version increment row: %v", err)
+		}
+		increments = append(increments, increment)
+	}
+	return increments, nil
+}
+
+// FinishVersionIncrement marks a pending version increment finished, recording incrementErr if it failed.
+func (b *postgresBackend) FinishVersionIncrement(id string, incrementErr error) error {
+	var errorMessage sql.NullString
+	if incrementErr != nil {
+		errorMessage = sql.NullString{String: incrementErr.Error(), Valid: true}
+	}
+	_, err := b.db.Exec("UPDATE version_increment SET finished = $1, error = $2 WHERE id = $3 AND finished IS NULL",
+		time.Now().In(time.UTC), errorMessage, id)
+	if err != nil {
+		return fmt.Errorf("finishing version increment: %v", err)
+	}
+	return nil
+}
+
+// VersionIncrement returns the version increment with the ID, or ErrUnknownVersionIncrement.
+func (b *postgresBackend) VersionIncrement(id string) (*VersionIncrement, error) {
+	increment := &VersionIncrement{ID: id}
+	var finished pq.NullTime
+	var errorMessage sql.NullString
+	err := b.db.QueryRow("SELECT tablename, version, requested, finished, error FROM version_increment WHERE id = $1", id).
+		Scan(&increment.Table, &increment.Version, &increment.Requested, &finished, &errorMessage)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, ErrUnknownVersionIncrement
+	case err != nil:
+		return nil, fmt.Errorf("querying version increment: %v", err)
+	}
+	if finished.Valid {
+		increment.Finished = &finished.Time
+	}
+	increment.Error = errorMessage.String
+	return increment, nil
+}
+
+// AddAnnotation stores an operator annotation and returns its ID.
+func (b *postgresBackend) AddAnnotation(annotation Annotation) (int64, error) {
+	var loadUUID *string
+	if annotation.LoadUUID != "" {
+		loadUUID = &annotation.LoadUUID
+	}
+	var id int64
+	err := b.db.QueryRow(
+		`INSERT INTO annotation (tablename, manifest_uuid, note, author, ts)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		annotation.Table, loadUUID, annotation.Note, annotation.Author, time.Now().In(time.UTC),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("inserting annotation: %v", err)
+	}
+	return id, nil
+}
+
+// Annotations returns the annotations on a table and its loads, oldest first.
+func (b *postgresBackend) Annotations(table string) ([]Annotation, error) {
+	rows, err := b.db.Query(
+		`SELECT id, tablename, manifest_uuid, note, author, ts
+		FROM annotation WHERE tablename = $1 ORDER BY ts ASC`, table)
+	if err != nil {
+		return nil, fmt.Errorf("querying annotations: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for annotations")
+		}
+	}()
+
+	annotations := []Annotation{}
+	for rows.Next() {
+		var annotation Annotation
+		var loadUUID sql.NullString
+		err = rows.Scan(&annotation.ID, &annotation.Table, &loadUUID, &annotation.Note,
+			&annotation.Author, &annotation.Created)
+		if err != nil {
+			return nil, fmt.Errorf("scanning annotation row: %v", err)
+		}
+		annotation.LoadUUID = loadUUID.String
+		annotations = append(annotations, annotation)
+	}
+	return annotations, nil
+}
+
+// DeleteAnnotation removes the table's annotation with the ID, returning whether there was one.
+func (b *postgresBackend) DeleteAnnotation(table string, id int64) (bool, error) {
+	result, err := b.db.Exec("DELETE FROM annotation WHERE id = $1 AND tablename = $2", id, table)
+	if err != nil {
+		return false, fmt.Errorf("deleting annotation: %v", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("deleting annotation: %v", err)
+	}
+	return deleted > 0, nil
+}
+
+// MaintenanceWindows returns the current and upcoming maintenance windows, soonest first.
+func (b *postgresBackend) MaintenanceWindows() ([]MaintenanceWindow, error) {
+	rows, err := b.db.Query(
+		`SELECT id, starts_at, ends_at, reason, requester
+		FROM maintenance_window WHERE ends_at IS NULL OR ends_at > $1 ORDER BY starts_at ASC`,
+		time.Now().In(time.UTC))
+	if err != nil {
+		return nil, fmt.Errorf("querying maintenance windows: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for maintenance windows")
+		}
+	}()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var window MaintenanceWindow
+		var end pq.NullTime
+		err = rows.Scan(&window.ID, &window.Start, &end, &window.Reason, &window.Requester)
+		if err != nil {
+			return nil, fmt.Errorf("scanning maintenance window row: %v", err)
+		}
+		if end.Valid {
+			window.End = &end.Time
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// AddMaintenanceWindow stores a maintenance window and returns its ID.
+func (b *postgresBackend) AddMaintenanceWindow(window MaintenanceWindow) (int64, error) {
+	var id int64
+	err := b.db.QueryRow(
+		`INSERT INTO maintenance_window (starts_at, ends_at, reason, requester)
+		VALUES ($1, $2, $3, $4) RETURNING id`,
+		window.Start.In(time.UTC), nullableTime(window.End), window.Reason, window.Requester,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("inserting maintenance window: %v", err)
+	}
+	return id, nil
+}
+
+// DeleteMaintenanceWindow removes a maintenance window, ending it if it's in progress.
+func (b *postgresBackend) DeleteMaintenanceWindow(id int64) error {
+	_, err := b.db.Exec("DELETE FROM maintenance_window WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("deleting maintenance window: %v", err)
+	}
+	return nil
+}
+
+// AddAuditEntry records a control request.
+func (b *postgresBackend) AddAuditEntry(entry AuditEntry) error {
+	_, err := b.db.Exec(
+		`INSERT INTO control_audit (ts, request_id, caller, method, path, params, status, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		entry.Time.In(time.UTC), entry.RequestID, entry.Caller, entry.Method, entry.Path,
+		nullableString(entry.Params), entry.Status, nullableString(entry.Error))
+	if err != nil {
+		return fmt.Errorf("inserting audit entry: %v", err)
+	}
+	return nil
+}
+
+// AuditEntries returns the filter's audit entries, most recent first.
+func (b *postgresBackend) AuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	rows, err := b.db.Query(
+		`SELECT id, ts, request_id, caller, method, path, params, status, error
+		FROM control_audit
+		WHERE ($1 = '' OR caller = $1) AND ($2::TIMESTAMP IS NULL OR ts >= $2)
+		ORDER BY ts DESC, id DESC
+		LIMIT $3`, filter.Caller, nullableTime(filter.Since), filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying audit entries: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for audit entries")
+		}
+	}()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var requestID, params, errorText sql.NullString
+		err = rows.Scan(&entry.ID, &entry.Time, &requestID, &entry.Caller, &entry.Method, &entry.Path,
+			&params, &entry.Status, &errorText)
+		if err != nil {
+			return nil, fmt.Errorf("scanning audit entry: %v", err)
+		}
+		entry.RequestID, entry.Params, entry.Error = requestID.String, params.String, errorText.String
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit entries: %v", err)
+	}
+	return entries, nil
+}
+
+// RecordLoadEvent records a load's state transition in a worker.
+func (b *postgresBackend) RecordLoadEvent(event LoadEvent) error {
+	_, err := b.db.Exec(
+		`INSERT INTO load_event (ts, uuid, tablename, worker, state, detail)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		event.Time.In(time.UTC), event.LoadUUID, event.Table, event.Worker, string(event.State),
+		nullableString(event.Detail))
+	if err != nil {
+		return fmt.Errorf("inserting load event: %v", err)
+	}
+	return nil
+}
+
+// PruneLoadEvents deletes the load events recorded before olderThan, returning how many were deleted.
+func (b *postgresBackend) PruneLoadEvents(olderThan time.Time) (int64, error) {
+	res, err := b.db.Exec("DELETE FROM load_event WHERE ts < $1", olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("pruning load events: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+// LoadEvents returns the filter's load events, most recent first.
+func (b *postgresBackend) LoadEvents(filter LoadEventFilter) ([]LoadEvent, error) {
+	// Events are paged by their IDs, which are in the order they were recorded, as the workers'
+	// clocks may not be
+	order := "ts DESC, id DESC"
+	var afterID sql.NullInt64
+	if filter.AfterID != nil {
+		order, afterID = "id", sql.NullInt64{Int64: *filter.AfterID, Valid: true}
+	}
+	rows, err := b.db.Query(
+		`SELECT id, ts, uuid, tablename, worker, state, detail
+		FROM load_event
+		WHERE ($1 = '' OR uuid::VARCHAR = $1) AND ($2 = '' OR worker LIKE $2 || '%')
+			AND ($3::TIMESTAMP IS NULL OR ts >= $3) AND ($5::BIGINT IS NULL OR id > $5)
+		ORDER BY `+order+`
+		LIMIT $4`, filter.LoadUUID, filter.Worker, nullableTime(filter.Since), filter.Limit, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("querying load events: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for load events")
+		}
+	}()
+
+	events := []LoadEvent{}
+	for rows.Next() {
+		var event LoadEvent
+		var state string
+		var detail sql.NullString
+		err = rows.Scan(&event.ID, &event.Time, &event.LoadUUID, &event.Table, &event.Worker, &state, &detail)
+		if err != nil {
+			return nil, fmt.Errorf("scanning load event: %v", err)
+		}
+		event.State, event.Detail = LoadEventState(state), detail.String
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading load events: %v", err)
+	}
+	return events, nil
+}
+
+// InMaintenance returns whether a maintenance window is in progress.
+func (b *postgresBackend) InMaintenance() (bool, error) {
+	var inMaintenance bool
+	err := b.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM maintenance_window
+			WHERE starts_at <= $1 AND (ends_at IS NULL OR ends_at > $1))`,
+		time.Now().In(time.UTC)).Scan(&inMaintenance)
+	if err != nil {
+		return false, fmt.Errorf("checking for maintenance window: %v", err)
+	}
+	return inMaintenance, nil
+}
+
+// InFlightLoads returns the loads currently claimed by a worker.
+func (b *postgresBackend) InFlightLoads() ([]LoadSummary, error) {
+	return b.loadSummaries(`
+		SELECT m.uuid, t.tablename, count(*), m.retry_count, m.last_error, m.retry_ts
+		FROM manifest m JOIN tsv t
+			ON m.uuid = t.manifest_uuid
+		WHERE m.retry_ts IS NULL
+		GROUP BY 1, 2, 4, 5, 6
+		ORDER BY 2`)
+}
+
+// FailedLoads returns up to limit loads waiting to be retried after failing, most recent first.
+func (b *postgresBackend) FailedLoads(limit int) ([]LoadSummary, error) {
+	return b.loadSummaries(`
+		SELECT m.uuid, t.tablename, count(*), m.retry_count, m.last_error, m.retry_ts
+		FROM manifest m JOIN tsv t
+			ON m.uuid = t.manifest_uuid
+		WHERE m.retry_ts IS NOT NULL
+		GROUP BY 1, 2, 4, 5, 6
+		ORDER BY m.retry_ts DESC
+		LIMIT $1`, limit)
+}
+
+// LoadThroughput returns the loads completed into each table since the time, and the failures of
+// loads of each table, sorted by table. Simulated loads aren't counted.
+func (b *postgresBackend) LoadThroughput(since time.Time) ([]TableThroughput, error) {
+	byTable := make(map[string]*TableThroughput)
+	rows, err := b.db.Query(`
+		SELECT tablename, count(*), COALESCE(sum(files), 0), COALESCE(sum(rows_loaded), 0),
+			COALESCE(sum(bytes_scanned), 0), COALESCE(avg(copy_ms), 0)
+		FROM load_history
+		WHERE loaded_at >= $1 AND NOT simulated
+		GROUP BY 1`, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying load history: %v", err)
+	}
+	for rows.Next() {
+		var t TableThroughput
+		var avgCopyMs float64
+		err = rows.Scan(&t.Table, &t.Loads, &t.Files, &t.Rows, &t.Bytes, &avgCopyMs)
+		if err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scanning load history row: %v", err)
+		}
+		t.AvgCopyMs = int64(avgCopyMs)
+		byTable[t.Table] = &t
+	}
+	if err = rows.Close(); err != nil {
+		return nil, fmt.Errorf("closing load history rows: %v", err)
+	}
+
+	// Errors recorded before load_error had a tablename are attributed by the load's history
+	rows, err = b.db.Query(`
+		SELECT COALESCE(e.tablename, h.tablename), count(*)
+		FROM load_error e LEFT JOIN load_history h
+			ON e.uuid = h.uuid
+		WHERE e.ts >= $1 AND COALESCE(e.tablename, h.tablename) IS NOT NULL
+		GROUP BY 1`, since)
+	if err != nil {
+		return nil, fmt.Errorf("querying load errors: %v", err)
+	}
+	for rows.Next() {
+		var table string
+		var failures int64
+		if err = rows.Scan(&table, &failures); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scanning load error row: %v", err)
+		}
+		t, ok := byTable[table]
+		if !ok {
+			t = &TableThroughput{Table: table}
+			byTable[table] = t
+		}
+		t.Failures = failures
+	}
+	if err = rows.Close(); err != nil {
+		return nil, fmt.Errorf("closing load error rows: %v", err)
+	}
+
+	throughput := make([]TableThroughput, 0, len(byTable))
+	for _, t := range byTable {
+		throughput = append(throughput, *t)
+	}
+	sort.Slice(throughput, func(i, j int) bool { return throughput[i].Table < throughput[j].Table })
+	return throughput, nil
+}
+
+// TableLoadErrors returns up to limit of the table's load failures since the time, newest first.
+// Errors recorded before load_error had a tablename are attributed by the load's history.
+func (b *postgresBackend) TableLoadErrors(table string, since time.Time, limit int) ([]TableLoadError, error) {
+	rows, err := b.db.Query(`
+		SELECT e.uuid, e.ts, e.class, e.error
+		FROM load_error e LEFT JOIN load_history h
+			ON e.uuid = h.uuid
+		WHERE e.ts >= $1 AND COALESCE(e.tablename, h.tablename) = $2
+		ORDER BY e.ts DESC, e.id DESC
+		LIMIT $3`, since, table, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying load errors: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for table load errors")
+		}
+	}()
+	errs := []TableLoadError{}
+	for rows.Next() {
+		var loadError TableLoadError
+		var class, message sql.NullString
+		err = rows.Scan(&loadError.UUID, &loadError.Time, &class, &message)
+		if err != nil {
+			return nil, fmt.Errorf("scanning load error row: %v", err)
+		}
+		loadError.Class = class.String
+		loadError.Error = message.String
+		errs = append(errs, loadError)
+	}
+	return errs, nil
+}
+
+// LoadErrorCounts returns how many times loads of each table, or only of the table if it's not empty,
+// failed with each class in each hour of the window before now.
+func (b *postgresBackend) LoadErrorCounts(table string, now time.Time, window time.Duration) ([]LoadErrorCount, error) {
+	rows, err := b.db.Query(`
+		SELECT COALESCE(e.tablename, h.tablename), COALESCE(e.class, ''),
+			FLOOR(EXTRACT(EPOCH FROM ($1 - e.ts)) / 3600)::INT, count(*)
+		FROM load_error e LEFT JOIN load_history h
+			ON e.uuid = h.uuid
+		WHERE e.ts > $2 AND e.ts <= $1 AND COALESCE(e.tablename, h.tablename) IS NOT NULL
+			AND ($3 = '' OR COALESCE(e.tablename, h.tablename) = $3)
+		GROUP BY 1, 2, 3`, now, now.Add(-window), table)
+	if err != nil {
+		return nil, fmt.Errorf("querying load error counts: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for load error counts")
+		}
+	}()
+	var counts []LoadErrorCount
+	for rows.Next() {
+		var count LoadErrorCount
+		err = rows.Scan(&count.Table, &count.Class, &count.HoursAgo, &count.Count)
+		if err != nil {
+			return nil, fmt.Errorf("scanning load error count row: %v", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// LoadDetail returns the state of the load: in flight or failed while it has a manifest, and
+// committed once it's in load_history.
+func (b *postgresBackend) LoadDetail(manifestUUID string) (*LoadDetail, error) {
+	detail := &LoadDetail{UUID: manifestUUID}
+	var lastError sql.NullString
+	var retryAt pq.NullTime
+	err := b.db.QueryRow("SELECT retry_count, last_error, retry_ts FROM manifest WHERE uuid = $1", manifestUUID).
+		Scan(&detail.RetryCount, &lastError, &retryAt)
+	switch {
+	case err == sql.ErrNoRows:
+		err = b.committedLoad(detail)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("querying manifest: %v", err)
+	default:
+		detail.State = LoadInFlight
+		if retryAt.Valid {
+			detail.State = LoadFailed
+			detail.RetryAt = &retryAt.Time
+		}
+		detail.LastError = lastError.String
+		err = b.loadFiles(detail)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	detail.Errors, err = b.loadFaults(manifestUUID)
+	if err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// committedLoad fills in the detail of a load from load_history, returning ErrUnknownLoad if
+// it's not there.
+func (b *postgresBackend) committedLoad(detail *LoadDetail) error {
+	var rowsLoaded, bytesScanned sql.NullInt64
+	var loadedAt pq.NullTime
+	err := b.db.QueryRow(`
+		SELECT tablename, files, rows_loaded, bytes_scanned, loaded_at, simulated
+		FROM load_history WHERE uuid = $1`, detail.UUID).
+		Scan(&detail.Table, &detail.FileCount, &rowsLoaded, &bytesScanned, &loadedAt, &detail.Simulated)
+	switch {
+	case err == sql.ErrNoRows:
+		return ErrUnknownLoad
+	case err != nil:
+		return fmt.Errorf("querying load history: %v", err)
+	}
+	detail.State = LoadCommitted
+	if rowsLoaded.Valid {
+		detail.RowsLoaded = &rowsLoaded.Int64
+	}
+	if bytesScanned.Valid {
+		detail.BytesScanned = &bytesScanned.Int64
+	}
+	if loadedAt.Valid {
+		detail.LoadedAt = &loadedAt.Time
+	}
+	return nil
+}
+
+// loadFiles fills in the table and files of a load that has a manifest.
+func (b *postgresBackend) loadFiles(detail *LoadDetail) error {
+	rows, err := b.db.Query("SELECT tablename, keyname, ts, row_count FROM tsv WHERE manifest_uuid = $1 ORDER BY id",
+		detail.UUID)
+	if err != nil {
+		return fmt.Errorf("querying load files: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for load files")
+		}
+	}()
+	for rows.Next() {
+		var file LoadFile
+		var rowCount sql.NullInt64
+		err = rows.Scan(&detail.Table, &file.KeyName, &file.Queued, &rowCount)
+		if err != nil {
+			return fmt.Errorf("scanning load file row: %v", err)
+		}
+		if rowCount.Valid {
+			file.RowCount = &rowCount.Int64
+		}
+		detail.Files = append(detail.Files, file)
+	}
+	detail.FileCount = len(detail.Files)
+	return nil
+}
+
+// loadFaults returns the errors the load failed with, oldest first.
+func (b *postgresBackend) loadFaults(manifestUUID string) ([]LoadFault, error) {
+	rows, err := b.db.Query("SELECT ts, error FROM load_error WHERE uuid = $1 ORDER BY id", manifestUUID)
+	if err != nil {
+		return nil, fmt.Errorf("querying load errors: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for load errors")
+		}
+	}()
+	var faults []LoadFault
+	for rows.Next() {
+		var fault LoadFault
+		var loadError sql.NullString
+		err = rows.Scan(&fault.Time, &loadError)
+		if err != nil {
+			return nil, fmt.Errorf("scanning load error row: %v", err)
+		}
+		fault.Error = loadError.String
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+func (b *postgresBackend) loadSummaries(query string, args ...interface{}) ([]LoadSummary, error) {
+	rows, err := b.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying loads: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for load summaries")
+		}
+	}()
+
+	summaries := []LoadSummary{}
+	for rows.Next() {
+		var summary LoadSummary
+		var lastError sql.NullString
+		var retryAt pq.NullTime
+		err = rows.Scan(&summary.UUID, &summary.Table, &summary.Files, &summary.RetryCount, &lastError, &retryAt)
+		if err != nil {
+			return nil, fmt.Errorf("scanning load row: %v", err)
+		}
+		summary.LastError = lastError.String
+		if retryAt.Valid {
+			summary.RetryAt = &retryAt.Time
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func nullableInt(i *int) sql.NullInt64 {
+	if i == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*i), Valid: true}
+}
+
+func nullableTime(t *time.Time) pq.NullTime {
+	if t == nil {
+		return pq.NullTime{}
+	}
+	return pq.NullTime{Time: t.In(time.UTC), Valid: true}
+}
+
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func findOrCreateStat(loadStats *PendingLoadStats, event string) *EventStats {
+	for _, s := range loadStats.Stats {
+		if s.Event == event {
+			return s
+		}
+	}
+	eventStats := &EventStats{Event: event}
+	loadStats.Stats = append(loadStats.Stats, eventStats)
+	return eventStats
+}
+
+func updateStats(loadStats *PendingLoadStats, event string, cnt int64, minTS time.Time) {
+	if cnt == 0 {
+		return
+	}
+	eventStats := findOrCreateStat(loadStats, event)
+	eventStats.Count += cnt
+	if eventStats.MinTS.IsZero() || eventStats.MinTS.After(minTS) {
+		eventStats.MinTS = minTS
+	}
+}
+
+// StatsForPendingLoads returns aggregates stats for each type of pending load classification.
+func (b *postgresBackend) StatsForPendingLoads() ([]*PendingLoadStats, error) {
+	rows, err := b.db.Query(`
+		SELECT
+			tablename,
+			tableversion,
+			is_stale,
+			min(ts),
+			count(*)
+		FROM (
+			SELECT
+				tablename,
+				tableversion,
+				retry_count IS NOT NULL AND retry_count >= $1 AS is_stale,
+				ts
+			FROM tsv
+			LEFT JOIN manifest
+				ON manifest_uuid=uuid
+		) tbl
+		GROUP BY 1, 2, 3
+	`, maxLoadRetryCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("error closing rows")
+		}
+	}()
+
+	// Since we don't store the current event versions in the db, we'll take the returned groups
+	// and identify which ones are pending migration in the logic below
+	var table string
+	var version int64
+	var isStale bool
+	var minTS time.Time
+	var cnt int64
+	inQueueStats := &PendingLoadStats{Type: PendingInQueue, Stats: []*EventStats{}}
+	staleStats := &PendingLoadStats{Type: PendingStale, Stats: []*EventStats{}}
+	pendingMigrationStats := &PendingLoadStats{Type: PendingMigration, Stats: []*EventStats{}}
+	for rows.Next() {
+		err = rows.Scan(&table, &version, &isStale, &minTS, &cnt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning event rows: %v", err)
+		}
+		if isStale {
+			updateStats(staleStats, table, cnt, minTS)
+		} else {
+			currentVersion, ok := b.versions.Get(table)
+			if ok && int64(currentVersion) < version {
+				updateStats(pendingMigrationStats, table, cnt, minTS)
+			} else {
+				updateStats(inQueueStats, table, cnt, minTS)
+			}
+		}
+	}
+	pendingLoadStats := []*PendingLoadStats{inQueueStats, staleStats, pendingMigrationStats}
+	return pendingLoadStats, nil
+}
+
+// ListDistinctTables returns all the tables in tsv
+func (b *postgresBackend) ListDistinctTables() ([]string, error) {
+	rows, err := b.db.Query("SELECT DISTINCT tablename FROM tsv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("error closing rows")
+		}
+	}()
+
+	var table string
+	var distinctTables []string
+
+	for rows.Next() {
+		err = rows.Scan(&table)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning event rows: %v", err)
+		}
+		distinctTables = append(distinctTables, table)
+	}
+	return distinctTables, nil
+}
+
+// GetLastLoad returns all known last load times for all tables
+func (b *postgresBackend) GetLastLoads() map[string]time.Time {
+	b.lastLoadedLock.RLock()
+	defer b.lastLoadedLock.RUnlock()
+
+	return b.lastLoaded
+}
+
+// ComponentToggles returns every component, whether it's switched off, and who last switched it.
+// Components never switched are on.
+func (b *postgresBackend) ComponentToggles() ([]ComponentToggle, error) {
+	rows, err := b.db.Query("SELECT component, disabled, reason, requester, updated FROM component_toggle")
+	if err != nil {
+		return nil, fmt.Errorf("querying component toggles: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for component toggles")
+		}
+	}()
+
+	switched := make(map[Component]ComponentToggle)
+	for rows.Next() {
+		var toggle ComponentToggle
+		var reason, requester sql.NullString
+		err = rows.Scan(&toggle.Component, &toggle.Disabled, &reason, &requester, &toggle.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("scanning component toggle: %v", err)
+		}
+		toggle.Reason, toggle.Requester = reason.String, requester.String
+		switched[toggle.Component] = toggle
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading component toggles: %v", err)
+	}
+	toggles := make([]ComponentToggle, 0, len(Components))
+	for _, component := range Components {
+		toggle, ok := switched[component]
+		if !ok {
+			toggle = ComponentToggle{Component: component}
+		}
+		toggles = append(toggles, toggle)
+	}
+	return toggles, nil
+}
+
+// InventoryProgress returns how far backfills of the S3 Inventory report got through each of its data
+// files, by data file. Files no backfill has started on aren't listed.
+func (b *postgresBackend) InventoryProgress(manifest string) ([]InventoryFileProgress, error) {
+	rows, err := b.db.Query(`SELECT data_file, lines, done, queued, duplicate, skipped, updated
+		FROM inventory_backfill WHERE manifest = $1 ORDER BY data_file`, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("querying inventory progress: %v", err)
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for inventory progress")
+		}
+	}()
+
+	progress := []InventoryFileProgress{}
+	for rows.Next() {
+		file := InventoryFileProgress{Manifest: manifest}
+		err = rows.Scan(&file.DataFile, &file.Lines, &file.Done, &file.Queued, &file.Duplicate, &file.Skipped,
+			&file.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("scanning inventory progress: %v", err)
+		}
+		progress = append(progress, file)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading inventory progress: %v", err)
+	}
+	return progress, nil
+}
+
+// SetInventoryProgress records how far a backfill of an S3 Inventory report got through one of its
+// data files.
+func (b *postgresBackend) SetInventoryProgress(progress InventoryFileProgress) error {
+	_, err := b.db.Exec(`INSERT INTO inventory_backfill
+		(manifest, data_file, lines, done, queued, duplicate, skipped, updated) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (manifest, data_file) DO UPDATE SET lines = EXCLUDED.lines, done = EXCLUDED.done,
+			queued = EXCLUDED.queued, duplicate = EXCLUDED.duplicate, skipped = EXCLUDED.skipped,
+			updated = EXCLUDED.updated`,
+		progress.Manifest, progress.DataFile, progress.Lines, progress.Done, progress.Queued, progress.Duplicate,
+		progress.Skipped, time.Now().In(time.UTC))
+	if err != nil {
+		return fmt.Errorf("setting inventory progress: %v", err)
+	}
+	return nil
+}
+
+// SetComponentToggle switches the component off or on.
+func (b *postgresBackend) SetComponentToggle(toggle ComponentToggle) error {
+	_, err := b.db.Exec(`INSERT INTO component_toggle (component, disabled, reason, requester, updated)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (component) DO UPDATE SET disabled = EXCLUDED.disabled, reason = EXCLUDED.reason,
+			requester = EXCLUDED.requester, updated = EXCLUDED.updated`,
+		string(toggle.Component), toggle.Disabled, nullableString(toggle.Reason), nullableString(toggle.Requester),
+		time.Now().In(time.UTC))
+	if err != nil {
+		return fmt.Errorf("setting component toggle: %v", err)
+	}
+	return nil
+}
+
+// ComponentDisabled returns whether the component is switched off.
+func (b *postgresBackend) ComponentDisabled(component Component) (bool, error) {
+	var disabled bool
+	err := b.db.QueryRow("SELECT EXISTS (SELECT 1 FROM component_toggle WHERE component = $1 AND disabled)",
+		string(component)).Scan(&disabled)
+	if err != nil {
+		return false, fmt.Errorf("checking component toggle: %v", err)
+	}
+	return disabled, nil
 }
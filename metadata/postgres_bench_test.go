@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+const benchClaimSize = 10000
+
+// BenchmarkGetLoadManifest measures reading a claimed manifest's TSVs back out of ingesterdb.
+func BenchmarkGetLoadManifest(b *testing.B) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		rows := sqlmock.NewRows([]string{"keyname", "tablename", "tableversion", "format", "compression", "ts", "row_count", "trace_parent"})
+		queued := time.Now()
+		for j := 0; j < benchClaimSize; j++ {
+			rows.AddRow(fmt.Sprintf("bench/v0/processor-%d.log.gz", j), "bench_table", 0, "tsv", "gzip", queued, nil, nil)
+		}
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT keyname, tablename, tableversion, format, compression, ts, row_count, trace_parent FROM tsv").WithArgs("uuid").WillReturnRows(rows)
+		mock.ExpectQuery("SELECT compupdate, statupdate, statement_timeout_ms, dedup_key FROM copy_settings").
+			WithArgs("bench_table").
+			WillReturnRows(sqlmock.NewRows([]string{"compupdate", "statupdate", "statement_timeout_ms", "dedup_key"}))
+		mock.ExpectRollback()
+		tx, err := db.Begin()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+
+		manifest, err := getLoadManifest(tx, "uuid")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(manifest.Loads) != benchClaimSize {
+			b.Fatalf("expected %d loads, got %d", benchClaimSize, len(manifest.Loads))
+		}
+
+		b.StopTimer()
+		_ = tx.Rollback()
+		b.StartTimer()
+	}
+}
@@ -1,24 +1,24 @@
 package metadata
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
-func TestForceLoadExists(t *testing.T) {
+func TestForceLoad(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err, "error opening a stub database connection")
 	defer func() { _ = db.Close() }()
 
-	mock.ExpectBegin()
-	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec("LOCK TABLE .*force_load").WillReturnResult(sqlmock.NewResult(1, 1))
-	existRows := sqlmock.NewRows([]string{"exists"}).AddRow(true)
-	mock.ExpectQuery("SELECT EXISTS").WithArgs("table").WillReturnRows(existRows)
-	mock.ExpectCommit()
+	// A request for a table with a pending force load is merged into it by the same statement
+	mock.ExpectExec("INSERT INTO force_load .* ON CONFLICT \\(tablename\\) WHERE started IS NULL DO UPDATE SET merged").
+		WithArgs("table", "dwe").WillReturnResult(sqlmock.NewResult(1, 1))
 
 	backend := postgresBackend{db: db}
 	err = backend.ForceLoad("table", "dwe")
@@ -29,24 +29,581 @@ func TestForceLoadExists(t *testing.T) {
 	assert.Nil(t, err, "mock expectations error")
 }
 
-func TestForceLoadNotExisting(t *testing.T) {
+func TestPendingForceLoads(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err, "error opening a stub database connection")
 	defer func() { _ = db.Close() }()
 
+	requested := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	merged := requested.Add(time.Minute)
+	mock.ExpectQuery("SELECT id, tablename, .* FROM force_load WHERE started IS NULL").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "tablename", "requester", "ts", "merged", "last_requested"}).
+			AddRow(3, "booking", "dwe", requested, 2, merged).
+			AddRow(4, "search", "migrator", requested, 0, nil))
+	mock.ExpectExec("DELETE FROM force_load WHERE tablename = \\$1 AND started IS NULL").WithArgs("booking").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM force_load").WithArgs("booking").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	backend := postgresBackend{db: db}
+	pending, err := backend.PendingForceLoads()
+	assert.NoError(t, err)
+	assert.Equal(t, []PendingForceLoad{
+		{ID: 3, Table: "booking", Requester: "dwe", Requested: requested, Merged: 2, LastRequested: &merged},
+		{ID: 4, Table: "search", Requester: "migrator", Requested: requested},
+	}, pending)
+
+	canceled, err := backend.CancelForceLoad("booking")
+	assert.NoError(t, err)
+	assert.True(t, canceled)
+	canceled, err = backend.CancelForceLoad("booking")
+	assert.NoError(t, err)
+	assert.False(t, canceled, "nothing left to cancel")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetLoadTrigger(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	countTrigger, delay := 50, 1800
 	mock.ExpectBegin()
 	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec("LOCK TABLE .*force_load").WillReturnResult(sqlmock.NewResult(1, 1))
-	existRows := sqlmock.NewRows([]string{"exists"}).AddRow(false)
-	mock.ExpectQuery("SELECT EXISTS").WithArgs("table").WillReturnRows(existRows)
-	mock.ExpectExec("INSERT INTO force_load").WithArgs("table", "dwe").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM load_trigger").WithArgs("table").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO load_trigger").WithArgs("table", int64(50), nil, int64(1800)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	backend := postgresBackend{db: db}
-	err = backend.ForceLoad("table", "dwe")
-	assert.Nil(t, err, "force load error")
+	err = backend.SetLoadTrigger(LoadTrigger{Table: "table", CountTrigger: &countTrigger, DelaySeconds: &delay})
+	assert.Nil(t, err, "set load trigger error")
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestDelayedCutoff(t *testing.T) {
+	now := time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC)
+	assert.Nil(t, delayedCutoff(now, sql.NullInt64{}, false), "no delay")
+	assert.Nil(t, delayedCutoff(now, sql.NullInt64{Int64: 0, Valid: true}, false), "zero delay")
+	assert.Nil(t, delayedCutoff(now, sql.NullInt64{Int64: 1800, Valid: true}, true), "force loads aren't delayed")
+	if cutoff := delayedCutoff(now, sql.NullInt64{Int64: 1800, Valid: true}, false); assert.NotNil(t, cutoff) {
+		assert.Equal(t, now.Add(-30*time.Minute), *cutoff)
+	}
+}
+
+func TestInsertDuplicateLoad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tsv_seen").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	backend := postgresBackend{db: db}
+	err = backend.InsertLoad(&Load{KeyName: "key.gz", TableName: "table"})
+	assert.Equal(t, ErrDuplicateLoad, err, "insert load error")
 
-	// we make sure that all expectations were met
 	err = mock.ExpectationsWereMet()
 	assert.Nil(t, err, "mock expectations error")
 }
+
+func TestInsertLoads(t *testing.T) {
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO tsv_seen \(keyname, ts\) VALUES \(\$1, \$2\), \(\$3, \$4\), \(\$5, \$6\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"keyname"}).AddRow("a.gz"))
+	mock.ExpectExec(`INSERT INTO tsv .* VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9\)$`).
+		WithArgs("table", "a.gz", 2, sqlmock.AnyArg(), "tsv", "gzip", false, int64(10), traceParent).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	backend := postgresBackend{db: db}
+	duplicate, err := backend.InsertLoads([]QueuedLoad{
+		{Load: &Load{KeyName: "a.gz", TableName: "table", TableVersion: 2, TraceParent: traceParent},
+			RowCount: sql.NullInt64{Int64: 10, Valid: true}},
+		{Load: &Load{KeyName: "seen.gz", TableName: "table", TableVersion: 2}},
+		{Load: &Load{KeyName: "a.gz", TableName: "table", TableVersion: 2}},
+	})
+	assert.Nil(t, err, "insert loads error")
+	assert.Equal(t, []bool{false, true, true}, duplicate)
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestInMaintenanceWindow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery("SELECT EXISTS .*maintenance_window").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS .*maintenance_window").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	backend := postgresBackend{db: db}
+	assert.True(t, backend.inMaintenanceWindow())
+	assert.True(t, backend.paused)
+	assert.False(t, backend.inMaintenanceWindow())
+	assert.False(t, backend.paused)
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestRowMismatch(t *testing.T) {
+	var none *LoadStats
+	assert.False(t, none.RowMismatch())
+	assert.False(t, (&LoadStats{RowsLoaded: 10}).RowMismatch(), "nothing to compare against")
+	assert.False(t, (&LoadStats{RowsLoaded: 10, LinesScanned: 10,
+		ExpectedRows: sql.NullInt64{Int64: 10, Valid: true}}).RowMismatch())
+	assert.True(t, (&LoadStats{RowsLoaded: 9, LinesScanned: 10}).RowMismatch())
+	assert.True(t, (&LoadStats{RowsLoaded: 10, LinesScanned: 10,
+		ExpectedRows: sql.NullInt64{Int64: 12, Valid: true}}).RowMismatch())
+	assert.False(t, (&LoadStats{Simulated: true, ExpectedRows: sql.NullInt64{Int64: 12, Valid: true}}).RowMismatch())
+}
+
+func TestLoadDetail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	retryAt := time.Date(2017, 3, 15, 4, 5, 0, 0, time.UTC)
+	failedAt := retryAt.Add(-errorRetryDelay)
+	mock.ExpectQuery("SELECT retry_count, last_error, retry_ts FROM manifest").WithArgs("uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"retry_count", "last_error", "retry_ts"}).AddRow(2, "timed out", retryAt))
+	mock.ExpectQuery("SELECT tablename, keyname, ts, row_count FROM tsv").WithArgs("uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "keyname", "ts", "row_count"}).
+			AddRow("table", "bucket/table/v1/a.gz", failedAt, 10).
+			AddRow("table", "bucket/table/v1/b.gz", failedAt, nil))
+	mock.ExpectQuery("SELECT ts, error FROM load_error").WithArgs("uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"ts", "error"}).AddRow(failedAt, "timed out"))
+
+	backend := postgresBackend{db: db}
+	detail, err := backend.LoadDetail("uuid")
+	assert.Nil(t, err)
+	assert.Equal(t, LoadFailed, detail.State)
+	assert.Equal(t, "table", detail.Table)
+	assert.Equal(t, 2, detail.FileCount)
+	assert.Equal(t, int64(10), *detail.Files[0].RowCount)
+	assert.Nil(t, detail.Files[1].RowCount)
+	assert.Equal(t, []LoadFault{{Time: failedAt, Error: "timed out"}}, detail.Errors)
+
+	mock.ExpectQuery("SELECT retry_count, last_error, retry_ts FROM manifest").WithArgs("gone").
+		WillReturnRows(sqlmock.NewRows([]string{"retry_count", "last_error", "retry_ts"}))
+	mock.ExpectQuery("SELECT tablename, files, rows_loaded, bytes_scanned, loaded_at, simulated FROM load_history").
+		WithArgs("gone").
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "files", "rows_loaded", "bytes_scanned", "loaded_at", "simulated"}))
+	_, err = backend.LoadDetail("gone")
+	assert.Equal(t, ErrUnknownLoad, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestLoadThroughput(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	since := time.Date(2017, 3, 15, 4, 5, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT tablename, count.* FROM load_history").WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "count", "files", "rows", "bytes", "avg"}).
+			AddRow("b", 2, 5, 100, 2048, 1500.5).
+			AddRow("a", 1, 1, 10, 512, 0))
+	mock.ExpectQuery("SELECT COALESCE.* FROM load_error").WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "count"}).
+			AddRow("b", 3).
+			AddRow("c", 1))
+
+	backend := postgresBackend{db: db}
+	throughput, err := backend.LoadThroughput(since)
+	assert.Nil(t, err)
+	assert.Equal(t, []TableThroughput{
+		{Table: "a", Loads: 1, Files: 1, Rows: 10, Bytes: 512},
+		{Table: "b", Loads: 2, Files: 5, Rows: 100, Bytes: 2048, AvgCopyMs: 1500, Failures: 3},
+		{Table: "c", Failures: 1},
+	}, throughput)
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestLoadErrorCounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	now := time.Date(2017, 3, 15, 4, 5, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COALESCE.* FROM load_error").WithArgs(now, now.Add(-24*time.Hour), "table").
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "class", "hours_ago", "count"}).
+			AddRow("table", "connection", 0, 3).
+			AddRow("table", "", 7, 1))
+
+	backend := postgresBackend{db: db}
+	counts, err := backend.LoadErrorCounts("table", now, 24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, []LoadErrorCount{
+		{Table: "table", Class: "connection", HoursAgo: 0, Count: 3},
+		{Table: "table", HoursAgo: 7, Count: 1},
+	}, counts)
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestVersionIncrement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	requested := time.Date(2017, 3, 15, 4, 5, 0, 0, time.UTC)
+	finished := requested.Add(time.Second)
+	mock.ExpectExec("UPDATE version_increment SET finished").WithArgs(sqlmock.AnyArg(), "table exists", "id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT tablename, version, requested, finished, error FROM version_increment").WithArgs("id").
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "version", "requested", "finished", "error"}).
+			AddRow("table", 3, requested, finished, "table exists"))
+	mock.ExpectQuery("SELECT tablename, version, requested, finished, error FROM version_increment").WithArgs("gone").
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "version", "requested", "finished", "error"}))
+
+	backend := postgresBackend{db: db}
+	err = backend.FinishVersionIncrement("id", errors.New("table exists"))
+	assert.Nil(t, err)
+	increment, err := backend.VersionIncrement("id")
+	assert.Nil(t, err)
+	assert.Equal(t, &VersionIncrement{ID: "id", Table: "table", Version: 3, Requested: requested, Finished: &finished,
+		Error: "table exists"}, increment)
+	_, err = backend.VersionIncrement("gone")
+	assert.Equal(t, ErrUnknownVersionIncrement, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestDropTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+	backend := postgresBackend{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("gone").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	_, err = backend.DropTable(DroppedTable{Table: "gone", Version: 3})
+	assert.Equal(t, ErrTableLoading, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("gone").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("DELETE FROM dropped_table").WithArgs("gone").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO dropped_table").WithArgs("gone", 3, "s3://b/gone/", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO tsv_diverted").WithArgs("gone", DroppedLoadStatus, sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec("DELETE FROM tsv WHERE tablename").WithArgs("gone", 3).WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
+	drained, err := backend.DropTable(DroppedTable{Table: "gone", Version: 3, Snapshot: "s3://b/gone/"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), drained)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForgetDroppedTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+	backend := postgresBackend{db: db}
+
+	mock.ExpectExec("DELETE FROM dropped_table").WithArgs("gone", 3).WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.NoError(t, backend.ForgetDroppedTable("gone", 3))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRenameTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+	backend := postgresBackend{db: db, lastLoaded: map[string]time.Time{"old": time.Unix(1, 0)}}
+	renamed := RenamedTable{Table: "old", NewName: "new", Version: 4}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	_, err = backend.RenameTable(renamed)
+	assert.Equal(t, ErrTableLoading, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("old").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("DELETE FROM renamed_table").WithArgs("old").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO renamed_table").WithArgs("old", "new", 4, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE last_load").WithArgs("old", "new").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE tsv SET tablename = \$2, tableversion = \$3`).WithArgs("old", "new", 4).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	moved, err := backend.RenameTable(renamed)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), moved)
+	assert.Equal(t, map[string]time.Time{"new": time.Unix(1, 0)}, backend.lastLoaded)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveRenamedTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+	backend := postgresBackend{db: db}
+
+	// Files queued under the old name since the rename are moved at the version the new name was renamed at
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE tsv SET tablename = \$2, tableversion = \$3\s+WHERE tablename = \$1 AND manifest_uuid IS NULL`).
+		WithArgs("old", "new", 4).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	moved, err := backend.MoveRenamedTable(RenamedTable{Table: "old", NewName: "new", Version: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), moved)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPauseTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM paused_table").WithArgs("table").WillReturnResult(sqlmock.NewResult(1, 0))
+	mock.ExpectExec("INSERT INTO paused_table").WithArgs("table", "incident", "dwe", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM paused_table").WithArgs("table").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	backend := postgresBackend{db: db}
+	err = backend.PauseTable(PausedTable{Table: "table", Reason: "incident", Requester: "dwe"})
+	assert.Nil(t, err, "pause table error")
+	err = backend.ResumeTable("table")
+	assert.Nil(t, err, "resume table error")
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestLoadableVersion(t *testing.T) {
+	versioned := map[tableVersion]bool{{"table", 4}: true}
+	assert.True(t, loadableVersion("table", 3, 3, versioned, ""), "current version")
+	assert.True(t, loadableVersion("table", 4, 3, versioned, ""), "versioned version")
+	assert.False(t, loadableVersion("table", 5, 3, versioned, ""), "unversioned later version")
+	assert.False(t, loadableVersion("table", 2, 3, versioned, ""), "earlier version")
+	assert.False(t, loadableVersion("other", 4, 3, versioned, ""), "other table's version")
+	assert.True(t, loadableVersion("table", 2, 3, versioned, StragglerLoad), "earlier version loaded")
+	assert.True(t, loadableVersion("table", 2, 3, versioned, StragglerTable), "earlier version into stragglers")
+	assert.False(t, loadableVersion("table", 2, 3, versioned, StragglerDeadLetter), "earlier version dead-lettered")
+	assert.False(t, loadableVersion("table", 5, 3, versioned, StragglerLoad), "policy doesn't load later versions")
+}
+
+func TestVersionedTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectExec("INSERT INTO versioned_table").WithArgs("table", 4, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("table", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("DELETE FROM versioned_table").WithArgs("table", 4).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	backend := postgresBackend{db: db}
+	err = backend.AddVersionedTable("table", 4)
+	assert.Nil(t, err, "add versioned table error")
+	loading, err := backend.VersionLoading("table", 4)
+	assert.Nil(t, err, "version loading error")
+	assert.True(t, loading)
+	err = backend.RemoveVersionedTable("table", 4)
+	assert.Nil(t, err, "remove versioned table error")
+
+	err = mock.ExpectationsWereMet()
+	assert.Nil(t, err, "mock expectations error")
+}
+
+func TestLoadChunkDone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+	backend := postgresBackend{db: db, lastLoaded: map[string]time.Time{}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM tsv WHERE manifest_uuid = \$1 AND keyname IN \(\$2, \$3\)`).
+		WithArgs("uuid", "b/t/a.gz", "b/t/b.gz").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO load_chunk").
+		WithArgs("uuid", "table", 2, 100, 2048, 1500, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM last_load").WithArgs("table").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO last_load").WithArgs("table", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err = backend.LoadChunkDone("uuid", "table", []string{"b/t/a.gz", "b/t/b.gz"},
+		&LoadStats{RowsLoaded: 100, BytesScanned: 2048, CopyDuration: 1500 * time.Millisecond})
+	assert.NoError(t, err)
+	assert.Contains(t, backend.lastLoaded, "table")
+
+	assert.NoError(t, backend.LoadChunkDone("uuid", "table", nil, &LoadStats{}), "an empty chunk is a no-op")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadDone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+	backend := postgresBackend{db: db, lastLoaded: map[string]time.Time{}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`^DELETE FROM tsv WHERE manifest_uuid = \$1$`).WithArgs("uuid").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery("FROM load_chunk WHERE uuid").WithArgs("uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "files", "rows", "bytes", "ms"}).AddRow(0, 0, nil, nil, nil))
+	mock.ExpectExec("INSERT INTO load_history").
+		WithArgs("uuid", "table", 3, 100, 2048, sqlmock.AnyArg(), false, 1500).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM manifest WHERE uuid").WithArgs("uuid").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM last_load").WithArgs("table").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO last_load").WithArgs("table", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	backend.LoadDone("uuid", "table", &LoadStats{RowsLoaded: 100, BytesScanned: 2048, CopyDuration: 1500 * time.Millisecond})
+	assert.Contains(t, backend.lastLoaded, "table", "the table's last load is updated once the load is done")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordLoadEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	now := time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec("INSERT INTO load_event").
+		WithArgs(now, "uuid", "table", "host/0/42", "failed", "retried: timeout").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO load_event").
+		WithArgs(now, "uuid", "table", "host/0/42", "assigned", nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	backend := postgresBackend{db: db}
+	assert.NoError(t, backend.RecordLoadEvent(LoadEvent{Time: now, LoadUUID: "uuid", Table: "table",
+		Worker: "host/0/42", State: EventFailed, Detail: "retried: timeout"}))
+	assert.NoError(t, backend.RecordLoadEvent(LoadEvent{Time: now, LoadUUID: "uuid", Table: "table",
+		Worker: "host/0/42", State: EventAssigned}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	now := time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-time.Hour)
+	columns := []string{"id", "ts", "uuid", "tablename", "worker", "state", "detail"}
+	mock.ExpectQuery("FROM load_event .* ORDER BY ts DESC, id DESC").
+		WithArgs("", "host/", since, 2, nil).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(8, now, "uuid", "table", "host/0/42", "failed", "retried: timeout").
+			AddRow(7, now.Add(-time.Minute), "uuid", "table", "host/0/42", "assigned", nil))
+	afterID := int64(7)
+	mock.ExpectQuery("FROM load_event .* ORDER BY id").
+		WithArgs("uuid", "", nil, 100, afterID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(8, now, "uuid", "table", "host/0/42", "failed", "retried: timeout"))
+
+	backend := postgresBackend{db: db}
+	events, err := backend.LoadEvents(LoadEventFilter{Worker: "host/", Since: &since, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []LoadEvent{
+		{ID: 8, Time: now, LoadUUID: "uuid", Table: "table", Worker: "host/0/42", State: EventFailed,
+			Detail: "retried: timeout"},
+		{ID: 7, Time: now.Add(-time.Minute), LoadUUID: "uuid", Table: "table", Worker: "host/0/42",
+			State: EventAssigned},
+	}, events, "the most recent events come first")
+
+	events, err = backend.LoadEvents(LoadEventFilter{LoadUUID: "uuid", AfterID: &afterID, Limit: 100})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1, "paging returns the events recorded after the ID, oldest first") {
+		assert.Equal(t, int64(8), events[0].ID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPruneLoadEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	cutoff := time.Date(2017, 3, 8, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM load_event WHERE ts").WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 42))
+
+	backend := postgresBackend{db: db}
+	pruned, err := backend.PruneLoadEvents(cutoff)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), pruned)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestComponentToggles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err, "error opening a stub database connection")
+	defer func() { _ = db.Close() }()
+
+	updated := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT component, disabled, reason, requester, updated FROM component_toggle").WillReturnRows(
+		sqlmock.NewRows([]string{"component", "disabled", "reason", "requester", "updated"}).
+			AddRow("migrator", true, "cluster incident", "dwe", updated))
+	mock.ExpectExec("INSERT INTO component_toggle .* ON CONFLICT \\(component\\) DO UPDATE").
+		WithArgs("loader", true, nil, "dwe", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS .*component_toggle").WithArgs("loader").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	backend := postgresBackend{db: db}
+	toggles, err := backend.ComponentToggles()
+	assert.NoError(t, err)
+	assert.Equal(t, []ComponentToggle{
+		{Component: ComponentLoader},
+		{Component: ComponentMigrator, Disabled: true, Reason: "cluster incident", Requester: "dwe", Updated: updated},
+		{Component: ComponentMetadatastorer},
+	}, toggles, "components never switched are on")
+
+	assert.NoError(t, backend.SetComponentToggle(ComponentToggle{Component: ComponentLoader, Disabled: true,
+		Requester: "dwe"}))
+	disabled, err := backend.ComponentDisabled(ComponentLoader)
+	assert.NoError(t, err)
+	assert.True(t, disabled)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
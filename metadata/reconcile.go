@@ -0,0 +1,133 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// orphanedLoadError is the error orphaned loads that didn't commit are marked for retry with.
+const orphanedLoadError = "Orphan load on startup"
+
+// reconcileOrphanedLoads settles the loads left in flight by a previous loader, e.g. one that
+// crashed: each load's COPY transaction is looked up in Redshift, and the load is marked done if
+// it committed or for retry otherwise. Loads whose status can't be told yet, because checking it
+// failed or the transaction is still in progress, are returned to be rechecked.
+func (b *postgresBackend) reconcileOrphanedLoads() (map[string]string, error) {
+	start := time.Now()
+	orphans, err := b.orphanedLoads()
+	if err != nil {
+		return nil, err
+	}
+	b.stats.SafeGauge("reconcile.orphans", int64(len(orphans)), 1.0)
+
+	unresolved := make(map[string]string)
+	for orphanUUID, tablename := range orphans {
+		resolved, err := b.reconcileLoad(orphanUUID, tablename)
+		if err != nil {
+			logger.WithError(err).WithField("orphanUUID", orphanUUID).Error("Error reconciling orphaned load")
+			b.stats.SafeInc("reconcile.errors", 1, 1.0)
+		}
+		if !resolved {
+			unresolved[orphanUUID] = tablename
+		}
+	}
+	b.stats.SafeGauge("reconcile.unresolved", int64(len(unresolved)), 1.0)
+	b.stats.SafeTimingDuration("reconcile.duration", time.Since(start), 1.0)
+	return unresolved, nil
+}
+
+// orphanedLoads returns the table of every load in flight, by manifest UUID.
+func (b *postgresBackend) orphanedLoads() (map[string]string, error) {
+	rows, err := b.db.Query(`
+		SELECT DISTINCT m.uuid, t.tablename
+		FROM manifest m JOIN tsv t
+			ON m.uuid = t.manifest_uuid
+		WHERE m.retry_ts IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			logger.WithError(err).Error("Error closing rows for orphan load check")
+		}
+	}()
+
+	orphans := map[string]string{}
+	for rows.Next() {
+		var uuid string
+		var tablename string
+		err = rows.Scan(&uuid, &tablename)
+		if err != nil {
+			return nil, fmt.Errorf("querying for orphaned loads: %v", err)
+		}
+		orphans[uuid] = tablename
+	}
+	return orphans, nil
+}
+
+// reconcileLoad marks an orphaned load done or for retry per its status in Redshift, returning
+// whether it did.
+func (b *postgresBackend) reconcileLoad(orphanUUID, tablename string) (bool, error) {
+	loadStatus, err := b.loadChecker.CheckLoad(orphanUUID)
+	if err != nil {
+		return false, fmt.Errorf("checking orphaned load status: %v", err)
+	}
+
+	switch loadStatus {
+	case scoop_protocol.LoadComplete:
+		logger.WithField("orphanUUID", orphanUUID).Info("Orphaned load is complete, marking done")
+		err = retryInTransaction(dbRetryCount, b.db, func(tx *sql.Tx) error {
+			return b.loadDoneHelper(tx, orphanUUID, tablename, time.Now().In(time.UTC), nil)
+		})
+		if err != nil {
+			return false, fmt.Errorf("marking orphaned load done: %v", err)
+		}
+		b.stats.SafeInc("reconcile.completed", 1, 1.0)
+	case scoop_protocol.LoadNotFound, scoop_protocol.LoadFailed:
+		logger.WithField("orphanUUID", orphanUUID).WithField("loadStatus", loadStatus).
+			Info("Orphaned load failed, marking for retry")
+		err = retryInTransaction(dbRetryCount, b.db, func(tx *sql.Tx) error {
+			return b.loadErrorHelper(tx, orphanUUID, orphanedLoadError, "", 0)
+		})
+		if err != nil {
+			return false, fmt.Errorf("marking orphaned load for retry: %v", err)
+		}
+		b.stats.SafeInc("reconcile.retried", 1, 1.0)
+	default:
+		logger.WithField("orphanUUID", orphanUUID).WithField("loadStatus", loadStatus).
+			Warn("Orphaned load's transaction hasn't finished; checking it again later")
+		return false, nil
+	}
+	return true, nil
+}
+
+// recheckOrphanedLoads reconciles the loads reconcileOrphanedLoads couldn't every
+// reconcileRecheckInterval, until they're all settled or the backend is closed. They stay in
+// flight until then, so they aren't retried.
+func (b *postgresBackend) recheckOrphanedLoads(unresolved map[string]string) {
+	for len(unresolved) > 0 {
+		select {
+		case <-time.After(reconcileRecheckInterval):
+		case <-b.wait:
+			return
+		}
+		for orphanUUID, tablename := range unresolved {
+			resolved, err := b.reconcileLoad(orphanUUID, tablename)
+			if err != nil {
+				logger.WithError(err).WithField("orphanUUID", orphanUUID).Error("Error reconciling orphaned load")
+				b.stats.SafeInc("reconcile.errors", 1, 1.0)
+			}
+			if resolved {
+				delete(unresolved, orphanUUID)
+			}
+		}
+		b.stats.SafeGauge("reconcile.unresolved", int64(len(unresolved)), 1.0)
+	}
+	logger.Info("Reconciled all orphaned loads")
+}
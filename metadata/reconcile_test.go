@@ -0,0 +1,90 @@
+package metadata
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// statusChecker is Redshift with the statuses of loads' COPYs, failing to check the others.
+type statusChecker map[string]scoop_protocol.LoadStatus
+
+func (c statusChecker) CheckLoad(manifestUUID string) (scoop_protocol.LoadStatus, error) {
+	status, ok := c[manifestUUID]
+	if !ok {
+		return "", errors.New("connection refused")
+	}
+	return status, nil
+}
+
+// expectIsolatedTransaction expects retryInTransaction to begin a serializable transaction.
+func expectIsolatedTransaction(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL SERIALIZABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("LOCK TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestReconcileOrphanedLoads(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	b := &postgresBackend{
+		db:         db,
+		stats:      monitoring.NewMockStatter(),
+		lastLoaded: make(map[string]time.Time),
+		loadChecker: statusChecker{
+			"committed": scoop_protocol.LoadComplete,
+			"running":   scoop_protocol.LoadInProgress,
+		},
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM manifest m JOIN tsv t")).WillReturnRows(
+		sqlmock.NewRows([]string{"uuid", "tablename"}).
+			AddRow("committed", "chat").AddRow("running", "video").AddRow("unchecked", "game"))
+	// Only the committed load is settled, by marking it done
+	expectIsolatedTransaction(mock)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM tsv WHERE manifest_uuid = $1")).WithArgs("committed").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery("FROM load_chunk").WithArgs("committed").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "files", "rows", "bytes", "ms"}).AddRow(0, 0, nil, nil, nil))
+	mock.ExpectExec("INSERT INTO load_history").WithArgs("committed", "chat", 3, nil, nil, sqlmock.AnyArg(), false, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM manifest WHERE uuid = $1")).WithArgs("committed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM last_load").WithArgs("chat").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO last_load").WithArgs("chat", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	unresolved, err := b.reconcileOrphanedLoads()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"running": "video", "unchecked": "game"}, unresolved,
+		"loads whose transactions are running or couldn't be checked are rechecked")
+	assert.Contains(t, b.lastLoaded, "chat")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReconcileFailedLoad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	b := &postgresBackend{db: db, stats: monitoring.NewMockStatter(),
+		loadChecker: statusChecker{"failed": scoop_protocol.LoadFailed}}
+
+	expectIsolatedTransaction(mock)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE manifest SET retry_ts = $1, last_error = $2 WHERE uuid = $3")).
+		WithArgs(sqlmock.AnyArg(), orphanedLoadError, "failed").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO load_error").WithArgs("failed", sqlmock.AnyArg(), orphanedLoadError, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resolved, err := b.reconcileLoad("failed", "chat")
+	assert.NoError(t, err)
+	assert.True(t, resolved, "a load that didn't commit is marked for retry")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,150 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// TableFilter picks the tables an ingester handles by glob patterns, where `*` matches any run
+// of characters and `?` any one character. A table is handled if it matches an include pattern,
+// or there are none, and doesn't match an exclude pattern. Its patterns can be changed at runtime.
+type TableFilter struct {
+	lock     sync.RWMutex
+	initial  TablePatterns
+	patterns TablePatterns
+	include  string
+	exclude  string
+	includeR *regexp.Regexp
+	excludeR *regexp.Regexp
+}
+
+// TablePatterns are the include and exclude patterns of a TableFilter
+type TablePatterns struct {
+	Include []string
+	Exclude []string
+}
+
+// ParseTablePatterns splits a comma separated list of patterns, as given in a flag.
+func ParseTablePatterns(list string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// NewTableFilter returns a TableFilter with the given patterns.
+func NewTableFilter(patterns TablePatterns) (*TableFilter, error) {
+	f := &TableFilter{initial: patterns}
+	return f, f.Set(patterns)
+}
+
+// Set replaces the filter's patterns.
+func (f *TableFilter) Set(patterns TablePatterns) error {
+	include, err := globsToRegexp(patterns.Include)
+	if err != nil {
+		return fmt.Errorf("parsing include patterns: %v", err)
+	}
+	exclude, err := globsToRegexp(patterns.Exclude)
+	if err != nil {
+		return fmt.Errorf("parsing exclude patterns: %v", err)
+	}
+	var includeR, excludeR *regexp.Regexp
+	if include != "" {
+		includeR = regexp.MustCompile(include)
+	}
+	if exclude != "" {
+		excludeR = regexp.MustCompile(exclude)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.patterns = patterns
+	f.include, f.exclude = include, exclude
+	f.includeR, f.excludeR = includeR, excludeR
+	return nil
+}
+
+// Reset restores the patterns the filter was created with, or last given to SetDefault.
+func (f *TableFilter) Reset() {
+	f.lock.RLock()
+	initial := f.initial
+	f.lock.RUnlock()
+	// The initial patterns were parsed when they were set.
+	_ = f.Set(initial)
+}
+
+// SetDefault replaces the filter's patterns and the ones Reset restores, e.g. when they're reloaded
+// from the config.
+func (f *TableFilter) SetDefault(patterns TablePatterns) error {
+	err := f.Set(patterns)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.initial = patterns
+	return nil
+}
+
+// Patterns returns the filter's patterns.
+func (f *TableFilter) Patterns() TablePatterns {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.patterns
+}
+
+// Allows returns whether the table is handled. A nil filter allows every table.
+func (f *TableFilter) Allows(table string) bool {
+	if f == nil {
+		return true
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if f.includeR != nil && !f.includeR.MatchString(table) {
+		return false
+	}
+	return f.excludeR == nil || !f.excludeR.MatchString(table)
+}
+
+// regexps returns the include and exclude patterns as regular expressions for postgres' `~`
+// operator, each empty if there are no such patterns.
+func (f *TableFilter) regexps() (include, exclude string) {
+	if f == nil {
+		return "", ""
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.include, f.exclude
+}
+
+// globsToRegexp returns an anchored regular expression matching any of the globs, understood by
+// both Go and postgres, or an empty string if there are no globs.
+func globsToRegexp(globs []string) (string, error) {
+	var alternatives []string
+	for _, glob := range globs {
+		if glob == "" {
+			return "", fmt.Errorf("empty pattern")
+		}
+		var re strings.Builder
+		for _, c := range glob {
+			switch c {
+			case '*':
+				re.WriteString(".*")
+			case '?':
+				re.WriteString(".")
+			default:
+				re.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		alternatives = append(alternatives, re.String())
+	}
+	if len(alternatives) == 0 {
+		return "", nil
+	}
+	return "^(" + strings.Join(alternatives, "|") + ")$", nil
+}
@@ -0,0 +1,46 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableFilter(t *testing.T) {
+	var none *TableFilter
+	assert.True(t, none.Allows("anything"))
+
+	f, err := NewTableFilter(TablePatterns{
+		Include: ParseTablePatterns("video_*, chat_?"),
+		Exclude: ParseTablePatterns("video_play"),
+	})
+	require.NoError(t, err)
+	assert.True(t, f.Allows("video_ad"))
+	assert.True(t, f.Allows("chat_x"))
+	assert.False(t, f.Allows("chat_xy"), "? matches one character")
+	assert.False(t, f.Allows("video_play"), "excluded")
+	assert.False(t, f.Allows("minute_watched"), "not included")
+	assert.False(t, f.Allows("videoXad"), "_ is literal")
+
+	include, exclude := f.regexps()
+	assert.Equal(t, `^(video_.*|chat_.)$`, include)
+	assert.Equal(t, `^(video_play)$`, exclude)
+
+	require.NoError(t, f.Set(TablePatterns{Exclude: []string{"a.b"}}))
+	assert.True(t, f.Allows("minute_watched"))
+	assert.False(t, f.Allows("a.b"))
+	assert.True(t, f.Allows("axb"), ". is literal")
+	assert.Error(t, f.Set(TablePatterns{Include: []string{""}}))
+
+	f.Reset()
+	assert.Equal(t, TablePatterns{Include: []string{"video_*", "chat_?"}, Exclude: []string{"video_play"}}, f.Patterns())
+	assert.False(t, f.Allows("minute_watched"))
+
+	// Reloaded patterns are also the ones Reset restores
+	require.NoError(t, f.SetDefault(TablePatterns{Include: []string{"minute_*"}}))
+	require.NoError(t, f.Set(TablePatterns{}))
+	f.Reset()
+	assert.True(t, f.Allows("minute_watched"))
+	assert.False(t, f.Allows("video_ad"))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/blueprint"
+)
+
+// tableCache is the set of tables files have been stored for, shared by the listeners. A file of a
+// table not in it has blueprint metadata reloaded first and the loader told of the new table.
+type tableCache struct {
+	lock   sync.RWMutex
+	tables map[string]bool
+}
+
+func newTableCache(tables []string) *tableCache {
+	c := &tableCache{tables: make(map[string]bool, len(tables))}
+	for _, table := range tables {
+		c.tables[table] = true
+	}
+	return c
+}
+
+// Has returns whether the table is cached.
+func (c *tableCache) Has(table string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.tables[table]
+}
+
+// Add caches the table.
+func (c *tableCache) Add(table string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tables[table] = true
+}
+
+// List returns the cached tables, sorted.
+func (c *tableCache) List() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	tables := make([]string, 0, len(c.tables))
+	for table := range c.tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// listenerStatus tracks the health of one SQS listener.
+type listenerStatus struct {
+	lock   sync.Mutex
+	report ListenerReport
+}
+
+// ListenerReport is the health of one SQS listener in /control/status.
+type ListenerReport struct {
+	Queue string
+	// Listening is false once the listener stops, e.g. because its queue couldn't be found
+	Listening bool
+	Handled   int64
+	Failed    int64
+	// LastMessage is when the listener last handled a message
+	LastMessage *time.Time `json:",omitempty"`
+	LastError   string     `json:",omitempty"`
+}
+
+func newListenerStatus(queue string) *listenerStatus {
+	return &listenerStatus{report: ListenerReport{Queue: queue}}
+}
+
+// setListening records whether the listener is listening.
+func (s *listenerStatus) setListening(listening bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.report.Listening = listening
+}
+
+// handled records the result of handling a message.
+func (s *listenerStatus) handled(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	s.report.LastMessage = &now
+	s.report.Handled++
+	if err != nil {
+		s.report.Failed++
+		s.report.LastError = err.Error()
+	}
+}
+
+func (s *listenerStatus) get() ListenerReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.report
+}
+
+// MetadataReport is the blueprint metadata in /control/status and /control/reload_metadata.
+type MetadataReport struct {
+	Events   int
+	LoadedAt time.Time
+}
+
+// StatusReport is the response of /control/status.
+type StatusReport struct {
+	Metadata  MetadataReport
+	Tables    []string
+	Listeners []ListenerReport
+}
+
+// controlServer serves the metadatastorer's /control/status and /control/reload_metadata.
+type controlServer struct {
+	loader    *blueprint.MetadataLoader
+	tables    *tableCache
+	listeners []*listenerStatus
+}
+
+func (c *controlServer) metadata() MetadataReport {
+	return MetadataReport{
+		Events:   len(c.loader.GetAllMetadata().Metadata),
+		LoadedAt: c.loader.LoadedAt(),
+	}
+}
+
+// status responds with the loaded blueprint metadata, the table cache and the listeners' health.
+func (c *controlServer) status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	report := StatusReport{
+		Metadata:  c.metadata(),
+		Tables:    c.tables.List(),
+		Listeners: make([]ListenerReport, len(c.listeners)),
+	}
+	for i, listener := range c.listeners {
+		report.Listeners[i] = listener.get()
+	}
+	respondWithJSON(w, report)
+}
+
+// reloadMetadata reloads blueprint metadata right away and responds with what was loaded.
+func (c *controlServer) reloadMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	logger.WithField("remote_address", r.RemoteAddr).Info("Reloading Blueprint metadata on request")
+	if err := c.loader.ForceReload(); err != nil {
+		http.Error(w, "Error reloading Blueprint metadata: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondWithJSON(w, c.metadata())
+}
+
+func respondWithJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.WithError(err).Error("Error writing control response")
+	}
+}
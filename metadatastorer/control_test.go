@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/blueprint"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// configFetcher is blueprint serving the configs in turn, then failing.
+type configFetcher struct {
+	configs []scoop_protocol.EventMetadataConfig
+}
+
+func (f *configFetcher) Fetch() (io.ReadCloser, error) {
+	if len(f.configs) == 0 {
+		return nil, errors.New("connection refused")
+	}
+	b, err := json.Marshal(f.configs[0].Metadata)
+	f.configs = f.configs[1:]
+	return ioutil.NopCloser(bytes.NewReader(b)), err
+}
+
+func eventMetadata(events ...string) scoop_protocol.EventMetadataConfig {
+	config := scoop_protocol.EventMetadataConfig{Metadata: make(map[string]map[string]scoop_protocol.EventMetadataRow)}
+	for _, event := range events {
+		config.Metadata[event] = map[string]scoop_protocol.EventMetadataRow{
+			"comment": {MetadataValue: event},
+		}
+	}
+	return config
+}
+
+func newTestControlServer(t *testing.T, configs ...scoop_protocol.EventMetadataConfig) *controlServer {
+	loader, err := blueprint.NewMetadataLoader(&configFetcher{configs: configs}, time.Hour, time.Microsecond,
+		monitoring.NewMockStatter())
+	assert.NoError(t, err)
+	return &controlServer{
+		loader:    loader,
+		tables:    newTableCache([]string{"video", "chat"}),
+		listeners: []*listenerStatus{newListenerStatus("spade-compacter"), newListenerStatus("spade-edge.fifo")},
+	}
+}
+
+func TestTableCache(t *testing.T) {
+	c := newTableCache([]string{"video", "chat"})
+	assert.True(t, c.Has("chat"))
+	assert.False(t, c.Has("clip"))
+	c.Add("clip")
+	assert.True(t, c.Has("clip"))
+	assert.Equal(t, []string{"chat", "clip", "video"}, c.List())
+}
+
+func TestListenerStatus(t *testing.T) {
+	s := newListenerStatus("spade-compacter")
+	s.setListening(true)
+	s.handled(nil)
+	report := s.get()
+	assert.True(t, report.Listening)
+	assert.Equal(t, int64(1), report.Handled)
+	assert.NotNil(t, report.LastMessage)
+	assert.Empty(t, report.LastError)
+
+	s.handled(errors.New("unknown table"))
+	s.setListening(false)
+	report = s.get()
+	assert.False(t, report.Listening)
+	assert.Equal(t, int64(2), report.Handled)
+	assert.Equal(t, int64(1), report.Failed)
+	assert.Equal(t, "unknown table", report.LastError)
+}
+
+func TestControlStatus(t *testing.T) {
+	c := newTestControlServer(t, eventMetadata("chat", "video"))
+	c.listeners[0].setListening(true)
+	c.listeners[0].handled(nil)
+
+	w := httptest.NewRecorder()
+	c.status(w, httptest.NewRequest("GET", "/control/status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report StatusReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.Metadata.Events)
+	assert.False(t, report.Metadata.LoadedAt.IsZero())
+	assert.Equal(t, []string{"chat", "video"}, report.Tables)
+	if assert.Len(t, report.Listeners, 2) {
+		assert.Equal(t, "spade-compacter", report.Listeners[0].Queue)
+		assert.True(t, report.Listeners[0].Listening)
+		assert.Equal(t, int64(1), report.Listeners[0].Handled)
+		assert.Equal(t, "spade-edge.fifo", report.Listeners[1].Queue)
+		assert.False(t, report.Listeners[1].Listening)
+	}
+
+	w = httptest.NewRecorder()
+	c.status(w, httptest.NewRequest("POST", "/control/status", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestControlReloadMetadata(t *testing.T) {
+	c := newTestControlServer(t, eventMetadata("chat"), eventMetadata("chat", "video", "clip"))
+
+	w := httptest.NewRecorder()
+	c.reloadMetadata(w, httptest.NewRequest("GET", "/control/reload_metadata", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Len(t, c.loader.GetAllMetadata().Metadata, 1, "metadata is only reloaded on POST")
+
+	w = httptest.NewRecorder()
+	c.reloadMetadata(w, httptest.NewRequest("POST", "/control/reload_metadata", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report MetadataReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 3, report.Events)
+
+	w = httptest.NewRecorder()
+	c.reloadMetadata(w, httptest.NewRequest("POST", "/control/reload_metadata", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Error reloading Blueprint metadata")
+	assert.Len(t, c.loader.GetAllMetadata().Metadata, 3, "a failed reload keeps the metadata")
+}
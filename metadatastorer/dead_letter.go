@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/blueprint"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// loadStatusAttribute is the message attribute holding why a message was sent to the dead-letter queue.
+const loadStatusAttribute = "LoadStatus"
+
+// loadFiltered is the load status of the files of tables this metadatastorer's table filter excludes,
+// which are dead-lettered to be redriven to the queue of the metadatastorer that handles them.
+const loadFiltered blueprint.LoadStatus = "filtered"
+
+// queueURL returns the URL of the named queue.
+func queueURL(client sqsiface.SQSAPI, name string) (string, error) {
+	out, err := client.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if err != nil {
+		return "", fmt.Errorf("getting URL of queue %s: %v", name, err)
+	}
+	return aws.StringValue(out.QueueUrl), nil
+}
+
+// divert sends the message of a paused, quarantined or filtered out event's file to the dead-letter
+// queue instead of storing it, recording the file as diverted. Without a dead-letter queue, it errors so the
+// message stays on the queue.
+func (i *rdsPipeHandler) divert(msg *sqs.Message, load *metadata.Load, status blueprint.LoadStatus) error {
+	table := load.TableName
+	if i.DeadLetterQueueURL == "" {
+		return fmt.Errorf("table %s has load status %s and there's no dead-letter queue", table, status)
+	}
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(i.DeadLetterQueueURL),
+		MessageBody: msg.Body,
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			loadStatusAttribute: {DataType: aws.String("String"), StringValue: aws.String(string(status))},
+		},
+	}
+	if attr, ok := msg.MessageAttributes[schemaVersionAttribute]; ok {
+		// The body is only readable with its schema
+		input.MessageAttributes[schemaVersionAttribute] = attr
+	}
+	if i.FIFO {
+		// A FIFO queue's dead-letter queue is also FIFO.
+		input.MessageGroupId = aws.String(table)
+		input.MessageDeduplicationId = msg.MessageId
+	}
+	_, err := i.SQS.SendMessage(input)
+	if err != nil {
+		return fmt.Errorf("sending message to dead-letter queue: %v", err)
+	}
+	logger.WithField("table", table).WithField("loadStatus", status).WithField("messageID", msg.MessageId).
+		Info("Diverted message to dead-letter queue")
+	// The message is already diverted, so failing to record it only makes the file look like a gap
+	err = i.MetadataStorer.RecordDivertedLoad(load, string(status))
+	if err != nil {
+		logger.WithError(err).WithField("keyName", load.KeyName).Warn("Error recording diverted file")
+	}
+	lib.TableInc(i.Statter, "tsv_files.diverted."+string(status), table, 1)
+	i.Statter.SafeInc(fmt.Sprintf("tsv_files.total.diverted.%s", status), 1, 1.0)
+	return nil
+}
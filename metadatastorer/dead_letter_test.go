@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// deadLetterQueue records the messages sent to it.
+type deadLetterQueue struct {
+	sqsiface.SQSAPI
+	sent []*sqs.SendMessageInput
+}
+
+func (q *deadLetterQueue) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	q.sent = append(q.sent, input)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+// divertStorer records the files recorded as diverted, with their load status.
+type divertStorer struct {
+	metadata.Storer
+	diverted map[string]string
+}
+
+func (s *divertStorer) RecordDivertedLoad(load *metadata.Load, loadStatus string) error {
+	s.diverted[load.KeyName] = loadStatus
+	return nil
+}
+
+func TestHandleFilteredTable(t *testing.T) {
+	filter, err := metadata.NewTableFilter(metadata.TablePatterns{Exclude: []string{"minute_*"}})
+	assert.NoError(t, err)
+	queue := &deadLetterQueue{}
+	storer := &divertStorer{diverted: map[string]string{}}
+	handler := &rdsPipeHandler{
+		MetadataStorer: storer,
+		Signer:         &scoop_protocol.FakeScoopSigner{},
+		Statter:        monitoring.NewMockStatter(),
+		TableFilter:    filter,
+		SQS:            queue,
+	}
+	msg := &sqs.Message{MessageId: aws.String("id"), Body: aws.String(
+		`{"KeyName": "processed/2017-03-08/minute_watched/v12/a.gz", "TableName": "minute_watched", "TableVersion": 12}`)}
+
+	// Without a dead-letter queue, the message is left on the queue rather than deleted with its file
+	assert.Error(t, handler.handle(context.Background(), msg))
+	assert.Empty(t, queue.sent)
+
+	handler.DeadLetterQueueURL = "https://sqs/dead-letter"
+	assert.NoError(t, handler.handle(context.Background(), msg))
+	if assert.Len(t, queue.sent, 1) {
+		assert.Equal(t, "https://sqs/dead-letter", aws.StringValue(queue.sent[0].QueueUrl))
+		assert.Equal(t, msg.Body, queue.sent[0].MessageBody)
+		assert.Equal(t, "filtered", aws.StringValue(queue.sent[0].MessageAttributes[loadStatusAttribute].StringValue))
+	}
+	assert.Equal(t, map[string]string{"processed/2017-03-08/minute_watched/v12/a.gz": "filtered"}, storer.diverted)
+}
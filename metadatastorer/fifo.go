@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// messageGroupIDAttribute is the FIFO queue attribute grouping messages delivered in order.
+const messageGroupIDAttribute = "MessageGroupId"
+
+// fifoSQS asks for each received message's group ID, so it can be checked against the message's
+// table. SQS delivers a group's messages one at a time, in order, and since a message is only
+// deleted once its file is stored, each table's files are stored in the order they were sent.
+type fifoSQS struct {
+	sqsiface.SQSAPI
+}
+
+func (f fifoSQS) ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	input.AttributeNames = append(input.AttributeNames, aws.String(messageGroupIDAttribute))
+	return f.SQSAPI.ReceiveMessage(input)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// insertRequest is a load waiting in a batch, and where to send the result of its insert.
+type insertRequest struct {
+	load   metadata.QueuedLoad
+	result chan error
+}
+
+// insertBatcher queues the loads of all the listeners' messages in batches, each with one
+// transaction, so RDS isn't written to for every message. A batch is inserted once it has
+// maxSize loads or its first load has waited flushInterval. Insert blocks until its load's batch
+// is inserted, so a message is still only deleted once its file is queued; batches can only grow
+// as large as the number of listeners.
+type insertBatcher struct {
+	storer        metadata.Storer
+	stats         monitoring.SafeStatter
+	maxSize       int
+	flushInterval time.Duration
+	requests      chan insertRequest
+	closer        chan bool
+	closed        chan bool
+}
+
+func newInsertBatcher(storer metadata.Storer, stats monitoring.SafeStatter, maxSize int,
+	flushInterval time.Duration) *insertBatcher {
+	b := &insertBatcher{
+		storer:        storer,
+		stats:         stats,
+		maxSize:       maxSize,
+		flushInterval: flushInterval,
+		requests:      make(chan insertRequest),
+		closer:        make(chan bool),
+		closed:        make(chan bool),
+	}
+	logger.Go(b.run)
+	return b
+}
+
+// Insert queues the load in the next batch, returning ErrDuplicateLoad if its key has been queued.
+func (b *insertBatcher) Insert(load *metadata.Load, rowCount *int64) error {
+	req := insertRequest{load: metadata.QueuedLoad{Load: load}, result: make(chan error, 1)}
+	if rowCount != nil {
+		req.load.RowCount = sql.NullInt64{Int64: *rowCount, Valid: true}
+	}
+	b.requests <- req
+	return <-req.result
+}
+
+func (b *insertBatcher) run() {
+	defer close(b.closed)
+	var batch []insertRequest
+	var flush <-chan time.Time
+	for {
+		select {
+		case req := <-b.requests:
+			batch = append(batch, req)
+			if len(batch) == 1 {
+				flush = time.After(b.flushInterval)
+			}
+			if len(batch) < b.maxSize {
+				continue
+			}
+		case <-flush:
+		case <-b.closer:
+			b.insert(batch)
+			return
+		}
+		b.insert(batch)
+		batch, flush = nil, nil
+	}
+}
+
+// insert inserts the batch and sends each load its result.
+func (b *insertBatcher) insert(batch []insertRequest) {
+	if len(batch) == 0 {
+		return
+	}
+	loads := make([]metadata.QueuedLoad, len(batch))
+	for i, req := range batch {
+		loads[i] = req.load
+	}
+	start := time.Now()
+	duplicate, err := b.storer.InsertLoads(loads)
+	b.stats.SafeTimingDuration("tsv_files.batch.insert", time.Since(start), 1.0)
+	b.stats.SafeGauge("tsv_files.batch.size", int64(len(batch)), 1.0)
+	for i, req := range batch {
+		switch {
+		case err != nil:
+			req.result <- err
+		case duplicate[i]:
+			req.result <- metadata.ErrDuplicateLoad
+		default:
+			req.result <- nil
+		}
+	}
+}
+
+// Close inserts the batch in progress and stops the batcher. The listeners must be closed first.
+func (b *insertBatcher) Close() {
+	close(b.closer)
+	<-b.closed
+}
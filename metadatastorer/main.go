@@ -5,13 +5,13 @@ them into a Postgres instance.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -25,8 +25,13 @@ import (
 	"github.com/twitchscience/aws_utils/logger"
 	"github.com/twitchscience/aws_utils/monitoring"
 	"github.com/twitchscience/rs_ingester/blueprint"
+	"github.com/twitchscience/rs_ingester/chaos"
+	"github.com/twitchscience/rs_ingester/lib"
 	"github.com/twitchscience/rs_ingester/metadata"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -34,6 +39,9 @@ var (
 	sqsPollWait               time.Duration
 	sqsQueueName              string
 	statsPrefix               string
+	statsBackend              string
+	statsTags                 string
+	statsNaming               lib.StatsNaming
 	listenerCount             int
 	rollbarToken              string
 	rollbarEnvironment        string
@@ -41,6 +49,23 @@ var (
 	bpMetadataConfigsKey      string
 	bpMetadataReloadFrequency time.Duration
 	bpMetadataRetryDelay      time.Duration
+	pprofAddr                 string
+	controlAddr               string
+	controlAuthToken          string
+	bpTopicArn                string
+	dedupRetention            time.Duration
+	fifoQueue                 bool
+	includeTables             string
+	excludeTables             string
+	tableFilter               *metadata.TableFilter
+	deadLetterQueueName       string
+	deadLetterQueueURL        string
+	insertBatchSize           int
+	insertFlushInterval       time.Duration
+	batcher                   *insertBatcher
+	samplingRefreshPeriod     time.Duration
+	toggleRefreshPeriod       time.Duration
+	tracingConfig             lib.TracingConfig
 )
 
 type rdsPipeHandler struct {
@@ -48,22 +73,56 @@ type rdsPipeHandler struct {
 	Signer           scoop_protocol.ScoopSigner
 	Statter          monitoring.SafeStatter
 	BpMetadataLoader *blueprint.MetadataLoader
-	Tables           map[string]bool
+	Tables           *tableCache
+	// Status records the health of the handler's listener
+	Status *listenerStatus
+	// FIFO checks that messages are grouped by table
+	FIFO bool
+	// TableFilter dead-letters the files of tables not handled by this metadatastorer
+	TableFilter *metadata.TableFilter
+	// Batcher, if set, queues files in batches instead of one at a time
+	Batcher *insertBatcher
+	// SQS sends the files of paused and quarantined events to DeadLetterQueueURL
+	SQS                sqsiface.SQSAPI
+	DeadLetterQueueURL string
+	// Sampler drops a share of the files of sampled tables
+	Sampler *sampler
 }
 
 func init() {
 	flag.StringVar(&pgConfig.DatabaseURL, "databaseURL", "", "Postgres-scheme url for the RDS instance")
 	flag.StringVar(&statsPrefix, "statsPrefix", "metadatastorer", "the prefix to statsd")
+	flag.StringVar(&statsBackend, "statsBackend", lib.StatsdBackend, "the stats backend, statsd or dogstatsd; dogstatsd tags per-table stats with the table instead of naming them after it")
+	flag.StringVar(&statsTags, "statsTags", "", "comma separated key:value tags added to every stat, e.g. cluster:science,environment:production; dogstatsd only")
+	flag.StringVar(&statsNaming.Environment, "statsEnvironment", "", "If set, environment plain statsd stats are namespaced under after the prefix, and dogstatsd stats tagged with, e.g. production")
+	flag.StringVar(&statsNaming.Cluster, "statsCluster", "", "If set, cluster plain statsd stats are namespaced under after the environment, and dogstatsd stats tagged with, e.g. science")
+	flag.BoolVar(&statsNaming.Legacy, "statsLegacyNames", false, "Also send plain statsd stats under their names without the namespace and with tables unsanitized, while dashboards move to the new names")
 	flag.IntVar(&pgConfig.MaxConnections, "maxDBConnections", 5, "Max number of database connections to open")
 	flag.DurationVar(&sqsPollWait, "sqsPollWait", time.Second*30, "Number of seconds to wait between polling SQS")
-	flag.StringVar(&sqsQueueName, "sqsQueueName", "", "Name of sqs queue to list for events on")
-	flag.IntVar(&listenerCount, "listenerCount", 1, "Number of sqs listeners to run")
+	flag.StringVar(&sqsQueueName, "sqsQueueName", "", "Comma separated names of the sqs queues to listen for events on; a name ending in * is a prefix matching every queue starting with it")
+	flag.IntVar(&listenerCount, "listenerCount", 1, "Number of sqs listeners to run per queue")
 	flag.StringVar(&rollbarToken, "rollbarToken", "", "Rollbar post_server_item token")
 	flag.StringVar(&rollbarEnvironment, "rollbarEnvironment", "", "Rollbar environment")
 	flag.StringVar(&bpConfigsBucket, "bpConfigsBucket", "", "The S3 bucket name where Blueprint configs are stored")
 	flag.StringVar(&bpMetadataConfigsKey, "bpMetadataConfigsKey", "", "The file name of the Blueprint event metadata configs on S3")
 	flag.DurationVar(&bpMetadataReloadFrequency, "bpMetadataReloadFrequency", 5*time.Minute, "How often to load Blueprint event metadata from S3")
 	flag.DurationVar(&bpMetadataRetryDelay, "bpMetadataRetryDelay", 2*time.Second, "How long to sleep if there's an error loading Blueprint event metadata from S3")
+	flag.StringVar(&pprofAddr, "pprofAddr", ":7767", "Address to serve pprof on")
+	flag.StringVar(&controlAddr, "controlAddr", "", "If set, address to serve /control/bp_metadata_updated, /control/reload_metadata and /control/status on")
+	flag.StringVar(&controlAuthToken, "controlAuthToken", "", "If set, bearer token required to call the control endpoints; SNS can't send one")
+	flag.StringVar(&bpTopicArn, "bpTopicArn", "", "If set, the only SNS topic whose Blueprint metadata notifications are accepted")
+	flag.BoolVar(&fifoQueue, "fifo", false, "The queue is a FIFO queue grouping messages by table name, so each table's files are queued in the order they were sent")
+	flag.StringVar(&includeTables, "includeTables", "", "Comma separated glob patterns of the only tables to store files of; all tables if empty")
+	flag.StringVar(&excludeTables, "excludeTables", "", "Comma separated glob patterns of tables whose files are dropped")
+	flag.StringVar(&deadLetterQueueName, "deadLetterQueueName", "", "Name of the sqs queue the files of paused, quarantined and filtered out events are sent to; if empty, they're left on the queue")
+	flag.IntVar(&insertBatchSize, "insertBatchSize", 1, "Most files queued in one ingesterdb transaction; batches are only as big as the number of listeners")
+	flag.DurationVar(&insertFlushInterval, "insertFlushInterval", 100*time.Millisecond, "Longest a file waits for its batch to fill before being queued")
+	flag.DurationVar(&samplingRefreshPeriod, "samplingRefreshPeriod", time.Minute, "How often to re-read the tables' sampling rules")
+	flag.DurationVar(&toggleRefreshPeriod, "toggleRefreshPeriod", 10*time.Second, "How often to check whether the metadatastorer is switched off")
+	flag.StringVar(&tracingConfig.Endpoint, "otlpEndpoint", "", "If set, host:port of the OTLP gRPC collector the spans of each message, which loads link to, are exported to")
+	flag.BoolVar(&tracingConfig.Insecure, "otlpInsecure", false, "Export spans to -otlpEndpoint without TLS, e.g. to a local agent")
+	flag.StringVar(&tracingConfig.Service, "otlpServiceName", "metadatastorer", "The service.name of exported spans")
+	flag.DurationVar(&dedupRetention, "dedupRetention", 14*24*time.Hour, "How long to remember queued S3 keys to drop redelivered SQS messages; at least the queue's retention period")
 }
 
 func main() {
@@ -72,16 +131,29 @@ func main() {
 	logger.InitWithRollbar("info", rollbarToken, rollbarEnvironment)
 	defer logger.LogPanic()
 
-	stats, err := monitoring.NewStatter(os.Getenv("STATSD_HOSTPORT"), statsPrefix)
+	stats, err := lib.InitStats(statsBackend, os.Getenv("STATSD_HOSTPORT"), statsPrefix, lib.ParseTags(statsTags), statsNaming)
 	if err != nil {
 		logger.WithError(err).Fatal("Error initializing stats")
 	}
 
+	shutdownTracing, err := lib.InitTracing(tracingConfig)
+	if err != nil {
+		logger.WithError(err).Fatal("Error initializing tracing")
+	}
+
 	logger.Go(func() {
-		logger.WithError(http.ListenAndServe(":7767", http.DefaultServeMux)).
+		logger.WithError(http.ListenAndServe(pprofAddr, http.DefaultServeMux)).
 			Error("Serving pprof failed")
 	})
 
+	tableFilter, err = metadata.NewTableFilter(metadata.TablePatterns{
+		Include: metadata.ParseTablePatterns(includeTables),
+		Exclude: metadata.ParseTablePatterns(excludeTables),
+	})
+	if err != nil {
+		logger.WithError(err).Fatal("Error parsing table filter")
+	}
+
 	postgresBackend, err := metadata.NewPostgresStorer(&pgConfig)
 	if err != nil {
 		logger.WithError(err).Fatal("Error initializing PostgresStorer")
@@ -100,14 +172,72 @@ func main() {
 	logger.Go(bpMetadataLoader.Crank)
 
 	// in cases we get a temporary influx of traffic, want to be resilient.
-	sqs := sqs.New(session, aws.NewConfig().WithMaxRetries(10))
+	var sqs sqsiface.SQSAPI = attributeSQS{sqs.New(session, aws.NewConfig().WithMaxRetries(10))}
+	if fifoQueue {
+		sqs = fifoSQS{sqs}
+	}
+
+	if deadLetterQueueName != "" {
+		deadLetterQueueURL, err = queueURL(sqs, deadLetterQueueName)
+		if err != nil {
+			logger.WithError(err).Fatal("Error finding dead-letter queue")
+		}
+	}
 
-	// Make a deduplication filter for the SQSListeners
+	// Make a deduplication filter for the SQSListeners. It's only a cheap first pass; InsertLoad
+	// drops any duplicate keys it misses.
 	filter := listener.NewDedupSQSFilter(1000, time.Hour)
+	pruneCloser := make(chan bool)
+	logger.Go(func() { pruneSeenKeys(postgresBackend, pruneCloser) })
+
+	if insertBatchSize > 1 {
+		batcher = newInsertBatcher(postgresBackend, stats, insertBatchSize, insertFlushInterval)
+	}
+
+	fileSampler := newSampler(postgresBackend, samplingRefreshPeriod)
+	insertToggle := newToggle(postgresBackend, toggleRefreshPeriod)
 
-	listeners := make([]*listener.SQSListener, listenerCount)
-	for i := 0; i < listenerCount; i++ {
-		listeners[i] = startWorker(sqs, sqsQueueName, stats, postgresBackend, filter, bpMetadataLoader)
+	queues, err := resolveQueues(sqs, sqsQueueName)
+	if err != nil {
+		logger.WithError(err).Fatal("Error finding queues to listen on")
+	}
+	tables, err := postgresBackend.ListDistinctTables()
+	if err != nil {
+		logger.WithError(err).Error("Error listing distinct tables from tsv")
+	}
+	tableCache := newTableCache(tables)
+
+	var listeners []*listener.SQSListener
+	var statuses []*listenerStatus
+	for _, queue := range queues {
+		// Each queue's stats are kept apart, unless it's the only one
+		var queueStats monitoring.SafeStatter = stats
+		if len(queues) > 1 {
+			queueStats = newQueueStatter(stats, queue)
+		}
+		logger.WithField("queue", queue).WithField("listeners", listenerCount).Info("Listening on queue")
+		for i := 0; i < listenerCount; i++ {
+			status := newListenerStatus(queue)
+			listeners = append(listeners, startWorker(sqs, queue, queueStats, postgresBackend, filter,
+				bpMetadataLoader, fileSampler, insertToggle, tableCache, status))
+			statuses = append(statuses, status)
+		}
+	}
+
+	if controlAddr != "" {
+		auth := func(h http.Handler) http.Handler { return h }
+		if controlAuthToken != "" {
+			auth = lib.TokenAuth(controlAuthToken)
+		}
+		control := &controlServer{loader: bpMetadataLoader, tables: tableCache, listeners: statuses}
+		controlMux := http.NewServeMux()
+		controlMux.Handle("/control/bp_metadata_updated", auth(blueprint.NotificationHandler(bpMetadataLoader, bpTopicArn)))
+		controlMux.Handle("/control/reload_metadata", auth(http.HandlerFunc(control.reloadMetadata)))
+		controlMux.Handle("/control/status", auth(http.HandlerFunc(control.status)))
+		logger.Go(func() {
+			logger.WithError(http.ListenAndServe(controlAddr, controlMux)).
+				Error("Serving control failed")
+		})
 	}
 
 	wait := make(chan struct{})
@@ -118,10 +248,13 @@ func main() {
 		<-sigc
 		logger.Info("Sigint received -- shutting down")
 		bpMetadataLoader.Close()
+		fileSampler.Close()
+		insertToggle.Close()
+		close(pruneCloser)
 		// Cause flush
 		var wg sync.WaitGroup
-		wg.Add(listenerCount)
-		for i := 0; i < listenerCount; i++ {
+		wg.Add(len(listeners))
+		for i := range listeners {
 			index := i
 			logger.Go(func() {
 				defer wg.Done()
@@ -129,6 +262,12 @@ func main() {
 			})
 		}
 		wg.Wait()
+		if batcher != nil {
+			batcher.Close()
+		}
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.WithError(err).Error("Error flushing spans")
+		}
 		logger.Info("Exiting main cleanly.")
 		logger.Wait()
 		close(wait)
@@ -137,44 +276,129 @@ func main() {
 	<-wait
 }
 
-func startWorker(sqs sqsiface.SQSAPI, queue string, stats monitoring.SafeStatter, b metadata.Storer, f listener.SQSFilter, metadataLoader *blueprint.MetadataLoader) *listener.SQSListener {
-	tables, err := b.ListDistinctTables()
-	if err != nil {
-		logger.WithError(err).Error("Error listing distinct tables from tsv")
-	}
-
-	var tablesMap = make(map[string]bool)
-	for _, table := range tables {
-		tablesMap[table] = true
+// pruneSeenKeys hourly forgets queued keys older than dedupRetention, until closer is closed.
+func pruneSeenKeys(b metadata.Storer, closer chan bool) {
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			pruned, err := b.PruneSeenKeys(time.Now().In(time.UTC).Add(-dedupRetention))
+			if err != nil {
+				logger.WithError(err).Error("Error pruning seen keys")
+				continue
+			}
+			logger.WithField("pruned", pruned).Info("Pruned seen keys")
+		case <-closer:
+			return
+		}
 	}
+}
 
+func startWorker(sqs sqsiface.SQSAPI, queue string, stats monitoring.SafeStatter, b metadata.Storer, f listener.SQSFilter,
+	metadataLoader *blueprint.MetadataLoader, s *sampler, t *toggle, tables *tableCache,
+	status *listenerStatus) *listener.SQSListener {
 	ret := listener.BuildSQSListener(
 		&rdsPipeHandler{
-			MetadataStorer:   b,
-			Signer:           scoop_protocol.GetScoopSigner(),
-			Statter:          stats,
-			Tables:           tablesMap,
-			BpMetadataLoader: metadataLoader,
+			MetadataStorer:     b,
+			Signer:             scoop_protocol.GetScoopSigner(),
+			Statter:            stats,
+			Tables:             tables,
+			Status:             status,
+			BpMetadataLoader:   metadataLoader,
+			FIFO:               fifoQueue,
+			TableFilter:        tableFilter,
+			SQS:                sqs,
+			DeadLetterQueueURL: deadLetterQueueURL,
+			Batcher:            batcher,
+			Sampler:            s,
 		},
 		sqsPollWait,
-		sqs,
+		// Paused while the metadatastorer is switched off
+		&pausedSQS{SQSAPI: sqs, toggle: t, stats: stats},
 		f)
-	logger.Go(func() { ret.Listen(queue) })
+	status.setListening(true)
+	logger.Go(func() {
+		ret.Listen(queue)
+		status.setListening(false)
+	})
 	return ret
 }
 
+// route queues the file of a sharded event under the table of its shard, returning whether it did.
+// Files whose key has no shard are queued under the event's own table.
+func (i *rdsPipeHandler) route(load *metadata.Load) bool {
+	if i.BpMetadataLoader.ShardColumn(load.TableName) == "" {
+		return false
+	}
+	shard, ok := i.BpMetadataLoader.ShardTable(load.TableName, load.KeyName)
+	if !ok {
+		lib.TableInc(i.Statter, "tsv_files.shard_unmapped", load.TableName, 1)
+		return false
+	}
+	lib.TableInc(i.Statter, "tsv_files.sharded", load.TableName, 1)
+	load.TableName = shard
+	return true
+}
+
+// Handle stores the file of the message, recording the result in the listener's status. Each message
+// is handled in the root span of its own trace, which the load of its file links to.
 func (i *rdsPipeHandler) Handle(msg *sqs.Message) error {
+	ctx, span := lib.Tracer().Start(context.Background(), "sqs.receive", trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("message_id", aws.StringValue(msg.MessageId))))
+	err := i.handle(ctx, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "message not handled")
+	}
+	span.End()
+	i.Status.handled(err)
+	return err
+}
+
+func (i *rdsPipeHandler) handle(ctx context.Context, msg *sqs.Message) error {
 	logger.WithField("body", msg.Body).WithField("messageID", msg.MessageId).Info("Received message")
+	if chaos.DropMessage() {
+		// Deleting the message loses its file, as if SQS never delivered it
+		logger.WithField("messageID", msg.MessageId).Warn("Fault injection dropped message")
+		return nil
+	}
 
-	req, err := i.Signer.GetRowCopyRequest(strings.NewReader(aws.StringValue(msg.Body)))
+	file, err := i.decode(msg)
 	if err != nil {
+		i.Statter.SafeInc("tsv_files.total.undecodable", 1, 1.0)
 		return err
 	}
+	rowCount := file.RowCount
+	i.Statter.SafeInc("tsv_files.total.schema.v"+file.Schema, 1, 1.0)
+
+	load := metadata.Load{
+		KeyName:      file.KeyName,
+		TableName:    file.TableName,
+		TableVersion: file.TableVersion,
+		Compression:  file.Compression,
+		Format:       file.Format,
+	}
+
+	if i.FIFO {
+		// Files of a table split across groups may be stored out of order.
+		if group := aws.StringValue(msg.Attributes[messageGroupIDAttribute]); group != load.TableName {
+			logger.WithField("table", load.TableName).WithField("messageGroupID", group).
+				WithField("messageID", msg.MessageId).Warn("Message isn't grouped by its table")
+			lib.TableInc(i.Statter, "tsv_files.misgrouped", load.TableName, 1)
+		}
+	}
 
-	load := metadata.Load(*req)
+	if !i.TableFilter.Allows(load.TableName) {
+		// Acking the message would lose the file, which another metadatastorer handles
+		return i.divert(msg, &load, loadFiltered)
+	}
 
-	if _, found := i.Tables[load.TableName]; !found {
-		i.BpMetadataLoader.ForceReload()
+	knownTable := i.Tables.Has(load.TableName)
+	if !knownTable {
+		// Logged by ForceReload; the metadata it has may still know the table
+		_ = i.BpMetadataLoader.ForceReload()
 	}
 
 	if !i.BpMetadataLoader.TableExists(load.TableName) {
@@ -183,26 +407,79 @@ func (i *rdsPipeHandler) Handle(msg *sqs.Message) error {
 		return err
 	}
 
-	i.Tables[load.TableName] = true
+	i.Tables.Add(load.TableName)
 
 	if !i.BpMetadataLoader.LoadIntoAce(load.TableName) {
-		i.Statter.SafeInc(fmt.Sprintf("tsv_files.%s.skipped.ace", load.TableName), 1, 1.0)
+		lib.TableInc(i.Statter, "tsv_files.skipped.ace", load.TableName, 1)
 		i.Statter.SafeInc("tsv_files.total.skipped.ace", 1, 1.0)
 		return nil
 	}
 
-	eventPattern := "tsv_files.%s.received"
-	i.Statter.SafeInc(fmt.Sprintf(eventPattern, load.TableName), 1, 1.0)
-	i.Statter.SafeInc(fmt.Sprintf(eventPattern, "total"), 1, 1.0)
+	if status := i.BpMetadataLoader.LoadStatus(load.TableName); status.Diverted() {
+		return i.divert(msg, &load, status)
+	}
 
-	err = i.MetadataStorer.InsertLoad(&load)
+	if i.route(&load) && !i.Tables.Has(load.TableName) {
+		// The shard's first file; its table is new even if its event's isn't
+		knownTable = false
+		i.Tables.Add(load.TableName)
+	}
+
+	if !i.Sampler.Keeps(&load) {
+		lib.TableInc(i.Statter, "tsv_files.skipped.sampled", load.TableName, 1)
+		i.Statter.SafeInc("tsv_files.total.skipped.sampled", 1, 1.0)
+		if rowCount != nil {
+			lib.TableInc(i.Statter, "tsv_files.skipped.sampled_rows", load.TableName, *rowCount)
+		}
+		return nil
+	}
+
+	lib.TableInc(i.Statter, "tsv_files.received", load.TableName, 1)
+	i.Statter.SafeInc("tsv_files.total.received", 1, 1.0)
+	if file.ByteSize != nil {
+		lib.TableInc(i.Statter, "tsv_files.received_bytes", load.TableName, *file.ByteSize)
+	}
+	if file.EventTimeMax != nil {
+		// How far behind its events the file was announced
+		lib.TableTiming(i.Statter, "tsv_files.event_lag", load.TableName, time.Since(*file.EventTimeMax))
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("table", load.TableName),
+		attribute.String("key", load.KeyName))
+	load.TraceParent = lib.TraceParent(ctx)
+	_, insertSpan := lib.Tracer().Start(ctx, "metadata.insert")
+	insertStart := time.Now()
+	if i.Batcher != nil {
+		err = i.Batcher.Insert(&load, rowCount)
+	} else if rowCount != nil {
+		err = i.MetadataStorer.InsertCountedLoad(&load, *rowCount)
+	} else {
+		err = i.MetadataStorer.InsertLoad(&load)
+	}
+	lib.TableTiming(i.Statter, "tsv_files.insert", load.TableName, time.Since(insertStart))
+	insertSpan.End()
+	if err == metadata.ErrDuplicateLoad {
+		logger.WithField("keyName", load.KeyName).WithField("messageID", msg.MessageId).
+			Info("Dropping message for already queued key")
+		lib.TableInc(i.Statter, "tsv_files.duplicate", load.TableName, 1)
+		i.Statter.SafeInc("tsv_files.total.duplicate", 1, 1.0)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	eventPattern = "tsv_files.%s.queued"
-	i.Statter.SafeInc(fmt.Sprintf(eventPattern, load.TableName), 1, 1.0)
-	i.Statter.SafeInc(fmt.Sprintf(eventPattern, "total"), 1, 1.0)
+	lib.TableInc(i.Statter, "tsv_files.queued", load.TableName, 1)
+	i.Statter.SafeInc("tsv_files.total.queued", 1, 1.0)
+
+	// Have the loader create the table now instead of on its next migrator poll. Best effort,
+	// since the poll will still find it.
+	if !knownTable {
+		err = i.MetadataStorer.NotifyNewTable(load.TableName)
+		if err != nil {
+			logger.WithError(err).WithField("table", load.TableName).Warn("Error notifying of new table")
+		}
+	}
 
 	return nil
 }
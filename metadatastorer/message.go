@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// schemaVersionAttribute is the message attribute naming the schema of a message's body: "2" for the
+// base64 of a FileMessage in message.proto, and the legacy JSON RowCopyRequest if it's missing or "1".
+const schemaVersionAttribute = "SchemaVersion"
+
+// The message schema versions.
+const (
+	schemaV1 = "1"
+	schemaV2 = "2"
+)
+
+// attributeSQS asks for each received message's schema version.
+type attributeSQS struct {
+	sqsiface.SQSAPI
+}
+
+func (a attributeSQS) ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	input.MessageAttributeNames = append(input.MessageAttributeNames, aws.String(schemaVersionAttribute))
+	return a.SQSAPI.ReceiveMessage(input)
+}
+
+// fileMessage is a processed file announced by a message, in either schema.
+type fileMessage struct {
+	Schema       string
+	KeyName      string
+	TableName    string
+	TableVersion int
+	// RowCount is the number of rows in the file, if the processor counted them
+	RowCount *int64
+	// ByteSize is the size of the file, if the processor gave it; only in v2
+	ByteSize *int64
+	// Compression is the file's compression, if it can't be inferred from its key
+	Compression metadata.Compression
+	// Format is the file's format, if given; only in v2
+	Format metadata.LoadFormat
+	// EventTimeMin and EventTimeMax bound the file's event times, if given; only in v2
+	EventTimeMin, EventTimeMax *time.Time
+}
+
+// schemaVersion returns the schema version of the message's body.
+func schemaVersion(msg *sqs.Message) string {
+	if attr, ok := msg.MessageAttributes[schemaVersionAttribute]; ok && attr != nil {
+		return aws.StringValue(attr.StringValue)
+	}
+	return schemaV1
+}
+
+// decode returns the file the message announces, in the schema its attribute names.
+func (i *rdsPipeHandler) decode(msg *sqs.Message) (fileMessage, error) {
+	var file fileMessage
+	var err error
+	switch version := schemaVersion(msg); version {
+	case schemaV1:
+		file, err = i.decodeV1(aws.StringValue(msg.Body))
+	case schemaV2:
+		var body []byte
+		body, err = base64.StdEncoding.DecodeString(aws.StringValue(msg.Body))
+		if err != nil {
+			return file, fmt.Errorf("decoding base64 of v2 message: %v", err)
+		}
+		file, err = decodeV2(body)
+	default:
+		return file, fmt.Errorf("message has unknown schema version %q", version)
+	}
+	if err != nil {
+		return file, err
+	}
+	if !metadata.ValidCompression(file.Compression) {
+		return file, fmt.Errorf("message for %s has unknown compression %q", file.KeyName, file.Compression)
+	}
+	if !metadata.ValidFormat(file.Format) {
+		return file, fmt.Errorf("message for %s has unknown format %q", file.KeyName, file.Format)
+	}
+	return file, nil
+}
+
+// decodeV1 decodes a legacy JSON message: a RowCopyRequest, and the optional fields the processor
+// may put beside it.
+func (i *rdsPipeHandler) decodeV1(body string) (fileMessage, error) {
+	req, err := i.Signer.GetRowCopyRequest(strings.NewReader(body))
+	if err != nil {
+		return fileMessage{}, err
+	}
+	extra := advertisedFields(body)
+	return fileMessage{
+		Schema:       schemaV1,
+		KeyName:      req.KeyName,
+		TableName:    req.TableName,
+		TableVersion: req.TableVersion,
+		RowCount:     extra.RowCount,
+		Compression:  extra.Compression,
+	}, nil
+}
+
+// advertised are the optional fields the processor may put in a legacy message beside the
+// RowCopyRequest's.
+type advertised struct {
+	// RowCount is the number of rows in the file
+	RowCount *int64
+	// Compression is the file's compression, if it can't be inferred from its key
+	Compression metadata.Compression
+}
+
+// advertisedFields returns the optional fields of the message, which are empty if it has none.
+func advertisedFields(body string) advertised {
+	var extra advertised
+	if err := json.Unmarshal([]byte(body), &extra); err != nil {
+		return advertised{}
+	}
+	return extra
+}
+
+// The protobuf wire types FileMessage's fields may be encoded as; its own are all varints or
+// length-delimited, but unknown fields of newer processors may be of any.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// fileMessageWireTypes are the wire types of FileMessage's fields, by field number.
+var fileMessageWireTypes = map[uint64]uint64{
+	1: wireBytes,
+	2: wireBytes,
+	3: wireVarint,
+	4: wireVarint,
+	5: wireVarint,
+	6: wireBytes,
+	7: wireBytes,
+	8: wireVarint,
+	9: wireVarint,
+}
+
+var errTruncated = errors.New("v2 message is truncated")
+
+// decodeV2 decodes the protobuf encoding of a FileMessage, skipping fields it doesn't know.
+func decodeV2(b []byte) (fileMessage, error) {
+	file := fileMessage{Schema: schemaV2}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return file, errTruncated
+		}
+		b = b[n:]
+		field, wireType := tag>>3, tag&7
+		if want, known := fileMessageWireTypes[field]; known && wireType != want {
+			return file, fmt.Errorf("v2 message has field %d of wire type %d, not %d", field, wireType, want)
+		}
+		var value uint64
+		var bytes []byte
+		switch wireType {
+		case wireVarint:
+			value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return file, errTruncated
+			}
+			b = b[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return file, errTruncated
+			}
+			bytes, b = b[n:n+int(length)], b[n+int(length):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wireType == wireFixed32 {
+				size = 4
+			}
+			if len(b) < size {
+				return file, errTruncated
+			}
+			b = b[size:]
+			continue
+		default:
+			return file, fmt.Errorf("v2 message has field %d of unknown wire type %d", field, wireType)
+		}
+
+		switch field {
+		case 1:
+			file.KeyName = string(bytes)
+		case 2:
+			file.TableName = string(bytes)
+		case 3:
+			file.TableVersion = int(int64(value))
+		case 4:
+			rows := int64(value)
+			file.RowCount = &rows
+		case 5:
+			size := int64(value)
+			file.ByteSize = &size
+		case 6:
+			file.Compression = metadata.Compression(bytes)
+		case 7:
+			file.Format = metadata.LoadFormat(bytes)
+		case 8:
+			t := time.Unix(0, int64(value)*int64(time.Microsecond)).In(time.UTC)
+			file.EventTimeMin = &t
+		case 9:
+			t := time.Unix(0, int64(value)*int64(time.Microsecond)).In(time.UTC)
+			file.EventTimeMax = &t
+		}
+	}
+	if file.KeyName == "" || file.TableName == "" {
+		return file, errors.New("v2 message is missing its key or table")
+	}
+	return file, nil
+}
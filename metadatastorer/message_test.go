@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// FileMessages encoded by protoc --encode=rs_ingester.metadatastorer.FileMessage message.proto.
+const (
+	// key_name: "processed/2017-03-08/minute_watched/v12/a.gz" table_name: "minute_watched" table_version: 12
+	minimalV2 = "0a2c70726f6365737365642f323031372d30332d30382f6d696e7574655f776174636865642f7631322f612e677a" +
+		"120e6d696e7574655f77617463686564180c"
+	// key_name: "processed/2017-03-08/minute_watched/v12/a.ndjson.zst" table_name: "minute_watched"
+	// table_version: 12 row_count: 0 byte_size: 2048 compression: "zstd" format: "json"
+	// event_time_min_micros: 1488931200000000 event_time_max_micros: 1488934800000000
+	fullV2 = "0a3470726f6365737365642f323031372d30332d30382f6d696e7574655f776174636865642f7631322f612e6e646a" +
+		"736f6e2e7a7374120e6d696e7574655f77617463686564180c200028801032047a7374643a046a736f6e4080c095fa" +
+		"ccc5d202488088e4aedac5d202"
+	// key_name: "k" table_name: "t" table_version: -1
+	negativeVersionV2 = "0a016b12017418ffffffffffffffffff01"
+	// Unknown fields 100 to 103 of a newer processor, one of each wire type, encoded by hand
+	unknownFieldsV2 = "a00601" + "a9060102030405060708" + "b206026869" + "bd0601020304"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	assert.NoError(t, err)
+	return b
+}
+
+func TestDecodeV2(t *testing.T) {
+	minimal := fileMessage{Schema: schemaV2, KeyName: "processed/2017-03-08/minute_watched/v12/a.gz",
+		TableName: "minute_watched", TableVersion: 12}
+	rows, size := int64(0), int64(2048)
+	min := time.Date(2017, 3, 8, 0, 0, 0, 0, time.UTC)
+	max := min.Add(time.Hour)
+	full := fileMessage{Schema: schemaV2, KeyName: "processed/2017-03-08/minute_watched/v12/a.ndjson.zst",
+		TableName: "minute_watched", TableVersion: 12, RowCount: &rows, ByteSize: &size,
+		Compression: metadata.CompressionZstd, Format: metadata.LoadFormatJSON, EventTimeMin: &min, EventTimeMax: &max}
+
+	for _, tc := range []struct {
+		name string
+		hex  string
+		want fileMessage
+		err  string
+	}{
+		{name: "minimal", hex: minimalV2, want: minimal},
+		{name: "full", hex: fullV2, want: full},
+		{name: "negative version", hex: negativeVersionV2,
+			want: fileMessage{Schema: schemaV2, KeyName: "k", TableName: "t", TableVersion: -1}},
+		{name: "unknown fields after", hex: minimalV2 + unknownFieldsV2, want: minimal},
+		{name: "unknown fields before", hex: unknownFieldsV2 + minimalV2, want: minimal},
+		{name: "empty", hex: "", err: "v2 message is missing its key or table"},
+		{name: "missing key", hex: "120e6d696e7574655f77617463686564180c", err: "v2 message is missing its key or table"},
+		{name: "missing table", hex: "0a016b180c", err: "v2 message is missing its key or table"},
+		{name: "truncated string", hex: minimalV2[:20], err: "v2 message is truncated"},
+		{name: "truncated length", hex: "0a", err: "v2 message is truncated"},
+		{name: "truncated varint", hex: "0a016b120174188080", err: "v2 message is truncated"},
+		{name: "truncated tag", hex: "0a016b1201748080", err: "v2 message is truncated"},
+		{name: "truncated fixed64", hex: minimalV2 + "a906010203", err: "v2 message is truncated"},
+		{name: "truncated fixed32", hex: minimalV2 + "bd060102", err: "v2 message is truncated"},
+		{name: "key as varint", hex: "080112017418", err: "v2 message has field 1 of wire type 0, not 2"},
+		{name: "version as bytes", hex: "0a016b1201741a0131", err: "v2 message has field 3 of wire type 2, not 0"},
+		{name: "event time as fixed64", hex: minimalV2 + "410000000000000000",
+			err: "v2 message has field 8 of wire type 1, not 0"},
+		{name: "group", hex: minimalV2 + "a306", err: "v2 message has field 100 of unknown wire type 3"},
+	} {
+		file, err := decodeV2(mustDecodeHex(t, tc.hex))
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err, tc.name)
+			continue
+		}
+		if assert.NoError(t, err, tc.name) {
+			assert.Equal(t, tc.want, file, tc.name)
+		}
+	}
+}
+
+func TestDecodeSchemaVersion(t *testing.T) {
+	handler := &rdsPipeHandler{Signer: &scoop_protocol.FakeScoopSigner{}}
+	v1Body := `{"KeyName": "processed/2017-03-08/minute_watched/v12/a.gz", "TableName": "minute_watched", ` +
+		`"TableVersion": 12, "RowCount": 5}`
+	message := func(body string, version *string) *sqs.Message {
+		msg := &sqs.Message{Body: aws.String(body)}
+		if version != nil {
+			msg.MessageAttributes = map[string]*sqs.MessageAttributeValue{
+				schemaVersionAttribute: {DataType: aws.String("String"), StringValue: version},
+			}
+		}
+		return msg
+	}
+	v2 := func(hexBody string) string {
+		return base64.StdEncoding.EncodeToString(mustDecodeHex(t, hexBody))
+	}
+	// compression: "lzop" and format: "csv" added to the minimal message
+	badCompression := minimalV2 + "32046c7a6f70"
+	badFormat := minimalV2 + "3a03637376"
+
+	for _, tc := range []struct {
+		name    string
+		msg     *sqs.Message
+		schema  string
+		version int
+		err     string
+	}{
+		{name: "no attribute", msg: message(v1Body, nil), schema: schemaV1, version: 12},
+		{name: "v1", msg: message(v1Body, aws.String("1")), schema: schemaV1, version: 12},
+		{name: "v2", msg: message(v2(fullV2), aws.String("2")), schema: schemaV2, version: 12},
+		{name: "v1 body as v2", msg: message(v1Body, aws.String("2")), err: "decoding base64 of v2 message"},
+		{name: "v2 body as v1", msg: message(v2(fullV2), nil), err: "invalid character"},
+		{name: "unknown version", msg: message(v1Body, aws.String("3")),
+			err: `message has unknown schema version "3"`},
+		{name: "unknown compression", msg: message(v2(badCompression), aws.String("2")),
+			err: `has unknown compression "lzop"`},
+		{name: "unknown format", msg: message(v2(badFormat), aws.String("2")), err: `has unknown format "csv"`},
+	} {
+		file, err := handler.decode(tc.msg)
+		if tc.err != "" {
+			if assert.Error(t, err, tc.name) {
+				assert.Contains(t, err.Error(), tc.err, tc.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tc.name) {
+			assert.Equal(t, tc.schema, file.Schema, tc.name)
+			assert.Equal(t, "minute_watched", file.TableName, tc.name)
+			assert.Equal(t, tc.version, file.TableVersion, tc.name)
+		}
+	}
+
+	file, err := handler.decode(message(v1Body, nil))
+	assert.NoError(t, err)
+	if assert.NotNil(t, file.RowCount) {
+		assert.Equal(t, int64(5), *file.RowCount, "the processor's row count is kept beside the RowCopyRequest")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+)
+
+// resolveQueues returns the names of the queues to listen on, in order, from comma separated queue
+// names and name prefixes ending in `*`, which match every queue whose name starts with them.
+func resolveQueues(client sqsiface.SQSAPI, spec string) ([]string, error) {
+	seen := make(map[string]bool)
+	var queues []string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		names := []string{entry}
+		if strings.HasSuffix(entry, "*") {
+			prefix := strings.TrimSuffix(entry, "*")
+			out, err := client.ListQueues(&sqs.ListQueuesInput{QueueNamePrefix: aws.String(prefix)})
+			if err != nil {
+				return nil, fmt.Errorf("listing queues starting with %s: %v", prefix, err)
+			}
+			if len(out.QueueUrls) == 0 {
+				return nil, fmt.Errorf("no queue starts with %s", prefix)
+			}
+			names = names[:0]
+			for _, queueURL := range out.QueueUrls {
+				u, err := url.Parse(aws.StringValue(queueURL))
+				if err != nil {
+					return nil, fmt.Errorf("parsing queue URL %s: %v", aws.StringValue(queueURL), err)
+				}
+				names = append(names, path.Base(u.Path))
+			}
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				queues = append(queues, name)
+			}
+		}
+	}
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("no queue to listen on")
+	}
+	return queues, nil
+}
+
+// queueStat returns a queue's name as a stat name part, without the dots of FIFO queues' names.
+func queueStat(queue string) string {
+	return strings.Replace(queue, ".", "_", -1)
+}
+
+// newQueueStatter returns a statter for the stats of one queue's listeners: tagged with the queue if
+// stats has tags, and otherwise prefixed with queue.<queue>.
+func newQueueStatter(stats monitoring.SafeStatter, queue string) monitoring.SafeStatter {
+	if tagged, ok := stats.(lib.TaggedStatter); ok {
+		return &taggedQueueStatter{TaggedStatter: tagged, tag: "queue:" + queue}
+	}
+	return &queueStatter{stats: stats, prefix: "queue." + queueStat(queue) + "."}
+}
+
+type queueStatter struct {
+	stats  monitoring.SafeStatter
+	prefix string
+}
+
+func (s *queueStatter) SafeInc(stat string, value int64, rate float32) {
+	s.stats.SafeInc(s.prefix+stat, value, rate)
+}
+
+func (s *queueStatter) SafeGauge(stat string, value int64, rate float32) {
+	s.stats.SafeGauge(s.prefix+stat, value, rate)
+}
+
+func (s *queueStatter) SafeTimingDuration(stat string, delta time.Duration, rate float32) {
+	s.stats.SafeTimingDuration(s.prefix+stat, delta, rate)
+}
+
+type taggedQueueStatter struct {
+	lib.TaggedStatter
+	tag string
+}
+
+func (s *taggedQueueStatter) SafeInc(stat string, value int64, rate float32) {
+	s.TaggedStatter.IncTagged(stat, value, s.tag)
+}
+
+func (s *taggedQueueStatter) SafeGauge(stat string, value int64, rate float32) {
+	s.TaggedStatter.GaugeTagged(stat, value, s.tag)
+}
+
+func (s *taggedQueueStatter) SafeTimingDuration(stat string, delta time.Duration, rate float32) {
+	s.TaggedStatter.TimingTagged(stat, delta, s.tag)
+}
+
+func (s *taggedQueueStatter) IncTagged(stat string, value int64, tags ...string) {
+	s.TaggedStatter.IncTagged(stat, value, append(tags, s.tag)...)
+}
+
+func (s *taggedQueueStatter) GaugeTagged(stat string, value int64, tags ...string) {
+	s.TaggedStatter.GaugeTagged(stat, value, append(tags, s.tag)...)
+}
+
+func (s *taggedQueueStatter) TimingTagged(stat string, delta time.Duration, tags ...string) {
+	s.TaggedStatter.TimingTagged(stat, delta, append(tags, s.tag)...)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// listingQueues is SQS with the queues named, recording the prefixes listed.
+type listingQueues struct {
+	sqsiface.SQSAPI
+	names  []string
+	listed []string
+	err    error
+}
+
+func (q *listingQueues) ListQueues(input *sqs.ListQueuesInput) (*sqs.ListQueuesOutput, error) {
+	prefix := aws.StringValue(input.QueueNamePrefix)
+	q.listed = append(q.listed, prefix)
+	out := &sqs.ListQueuesOutput{}
+	for _, name := range q.names {
+		if strings.HasPrefix(name, prefix) {
+			out.QueueUrls = append(out.QueueUrls, aws.String("https://sqs.us-west-2.amazonaws.com/123456789012/"+name))
+		}
+	}
+	return out, q.err
+}
+
+func TestResolveQueues(t *testing.T) {
+	client := &listingQueues{names: []string{"spade-compacter-b", "spade-compacter-a", "spade-edge.fifo"}}
+	queues, err := resolveQueues(client, "spade-edge.fifo, spade-compacter-*,spade-compacter-a,")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spade-edge.fifo", "spade-compacter-a", "spade-compacter-b"}, queues,
+		"prefixes match in name order, and each queue is listened on once")
+	assert.Equal(t, []string{"spade-compacter-"}, client.listed, "only prefixes are listed")
+
+	queues, err = resolveQueues(client, "spade-compacter")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spade-compacter"}, queues, "names are used as given")
+
+	_, err = resolveQueues(client, "spade-other-*")
+	assert.EqualError(t, err, "no queue starts with spade-other-")
+
+	_, err = resolveQueues(client, " , ")
+	assert.EqualError(t, err, "no queue to listen on")
+
+	client.err = errors.New("AccessDenied")
+	_, err = resolveQueues(client, "spade-*")
+	assert.EqualError(t, err, "listing queues starting with spade-: AccessDenied")
+}
+
+func TestQueueStat(t *testing.T) {
+	assert.Equal(t, "spade-edge_fifo", queueStat("spade-edge.fifo"))
+}
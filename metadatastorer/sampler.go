@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// sampler drops a share of the files of tables with a sampling rule, set through the loader's
+// /control/sampling endpoint. The rules are re-read every refreshPeriod, so a change takes up to
+// that long to apply; if they can't be read, the last ones read are kept.
+type sampler struct {
+	storer        metadata.Storer
+	refreshPeriod time.Duration
+	keepPercents  map[string]int
+	lock          sync.RWMutex
+	closer        chan bool
+}
+
+func newSampler(storer metadata.Storer, refreshPeriod time.Duration) *sampler {
+	s := &sampler{
+		storer:        storer,
+		refreshPeriod: refreshPeriod,
+		closer:        make(chan bool),
+	}
+	s.refresh()
+	logger.Go(s.run)
+	return s
+}
+
+func (s *sampler) run() {
+	tick := time.NewTicker(s.refreshPeriod)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			s.refresh()
+		case <-s.closer:
+			return
+		}
+	}
+}
+
+func (s *sampler) refresh() {
+	keepPercents, err := s.storer.KeepPercents()
+	if err != nil {
+		logger.WithError(err).Error("Error reading sampling rules")
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keepPercents = keepPercents
+}
+
+// Keeps returns whether the table's file is queued, or dropped by its sampling rule.
+func (s *sampler) Keeps(load *metadata.Load) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	keepPercent, sampled := s.keepPercents[load.TableName]
+	return !sampled || metadata.KeepsFile(load.KeyName, keepPercent)
+}
+
+// Close stops refreshing the rules.
+func (s *sampler) Close() {
+	close(s.closer)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// toggle tracks whether the metadatastorer is switched off through the loader's /control/toggles
+// endpoint. It's re-read every refreshPeriod, so a switch takes up to that long to apply; if it can't
+// be read, the last state read is kept.
+type toggle struct {
+	storer        metadata.Storer
+	refreshPeriod time.Duration
+	disabled      bool
+	lock          sync.RWMutex
+	closer        chan bool
+}
+
+func newToggle(storer metadata.Storer, refreshPeriod time.Duration) *toggle {
+	t := &toggle{
+		storer:        storer,
+		refreshPeriod: refreshPeriod,
+		closer:        make(chan bool),
+	}
+	t.refresh()
+	logger.Go(t.run)
+	return t
+}
+
+func (t *toggle) run() {
+	tick := time.NewTicker(t.refreshPeriod)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			t.refresh()
+		case <-t.closer:
+			return
+		}
+	}
+}
+
+func (t *toggle) refresh() {
+	disabled, err := t.storer.ComponentDisabled(metadata.ComponentMetadatastorer)
+	if err != nil {
+		logger.WithError(err).Error("Error reading metadatastorer toggle")
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if disabled != t.disabled {
+		if disabled {
+			logger.Info("Metadatastorer switched off; pausing its listeners")
+		} else {
+			logger.Info("Metadatastorer switched back on")
+		}
+	}
+	t.disabled = disabled
+}
+
+// Disabled returns whether the metadatastorer is switched off.
+func (t *toggle) Disabled() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.disabled
+}
+
+// Close stops re-reading the toggle.
+func (t *toggle) Close() {
+	close(t.closer)
+}
+
+// pausedSQS is the SQS client of the listeners, which receives no messages while the metadatastorer is
+// switched off: the listeners poll it every --sqsPollWait as if the queue were empty, so messages stay on
+// the queue unreceived instead of being redelivered until they're dead-lettered.
+type pausedSQS struct {
+	sqsiface.SQSAPI
+	toggle *toggle
+	stats  monitoring.SafeStatter
+}
+
+func (s *pausedSQS) ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	if s.toggle.Disabled() {
+		s.stats.SafeInc("sqs.total.paused_polls", 1, 1.0)
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	return s.SQSAPI.ReceiveMessage(input)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// toggleStorer is ingesterdb with the metadatastorer switched off or on, or its toggle unreadable.
+type toggleStorer struct {
+	metadata.Storer
+	disabled bool
+	err      error
+}
+
+func (s *toggleStorer) ComponentDisabled(component metadata.Component) (bool, error) {
+	if component != metadata.ComponentMetadatastorer {
+		return false, errors.New("wrong component")
+	}
+	return s.disabled, s.err
+}
+
+// messageQueue always has a message to receive, counting how many were.
+type messageQueue struct {
+	sqsiface.SQSAPI
+	received int
+}
+
+func (q *messageQueue) ReceiveMessage(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	q.received++
+	return &sqs.ReceiveMessageOutput{Messages: []*sqs.Message{{Body: aws.String("file")}}}, nil
+}
+
+func TestToggle(t *testing.T) {
+	storer := &toggleStorer{}
+	toggle := newToggle(storer, time.Hour)
+	defer toggle.Close()
+	assert.False(t, toggle.Disabled())
+
+	storer.disabled = true
+	toggle.refresh()
+	assert.True(t, toggle.Disabled())
+
+	storer.disabled, storer.err = false, errors.New("connection refused")
+	toggle.refresh()
+	assert.True(t, toggle.Disabled(), "the last state read is kept while the toggle can't be read")
+
+	storer.err = nil
+	toggle.refresh()
+	assert.False(t, toggle.Disabled())
+}
+
+func TestPausedSQS(t *testing.T) {
+	storer := &toggleStorer{}
+	toggle := newToggle(storer, time.Hour)
+	defer toggle.Close()
+	queue := &messageQueue{}
+	client := &pausedSQS{SQSAPI: queue, toggle: toggle, stats: monitoring.NewMockStatter()}
+
+	out, err := client.ReceiveMessage(&sqs.ReceiveMessageInput{})
+	assert.NoError(t, err)
+	assert.Len(t, out.Messages, 1)
+
+	// Switched off, the listeners see an empty queue, and no message is received to be redelivered
+	storer.disabled = true
+	toggle.refresh()
+	out, err = client.ReceiveMessage(&sqs.ReceiveMessageInput{})
+	assert.NoError(t, err)
+	assert.Empty(t, out.Messages)
+	assert.Equal(t, 1, queue.received)
+
+	storer.disabled = false
+	toggle.refresh()
+	out, err = client.ReceiveMessage(&sqs.ReceiveMessageInput{})
+	assert.NoError(t, err)
+	assert.Len(t, out.Messages, 1)
+	assert.Equal(t, 2, queue.received)
+}
@@ -1,29 +1,60 @@
 package migrator
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/twitchscience/aws_utils/logger"
 	"github.com/twitchscience/rs_ingester/backend"
 	"github.com/twitchscience/rs_ingester/blueprint"
+	"github.com/twitchscience/rs_ingester/lib"
 	"github.com/twitchscience/rs_ingester/metadata"
 	"github.com/twitchscience/rs_ingester/versions"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
 )
 
+// errMaintenance is returned to control requests made during a maintenance window.
+var errMaintenance = errors.New("in a maintenance window; tables can't be changed")
+
+// errDisabled is returned to control requests made while the migrator is switched off.
+var errDisabled = errors.New("the migrator is switched off; tables can't be changed")
+
 type tableVersion struct {
 	table   string
 	version int
 }
 
-// VersionIncrement is used to send a request to increment a table's version w/o running a migration.
-type VersionIncrement struct {
+// MigrationRequest is used to send a request to migrate a table to the given version right away,
+// regardless of offpeak hours.
+type MigrationRequest struct {
 	Table    string
 	Version  int
 	Response chan error
 }
 
+// VersionRefresh is used to send a request to re-read the table versions from Ace.
+type VersionRefresh struct {
+	Response chan VersionRefreshResult
+}
+
+// VersionRefreshResult is the outcome of a VersionRefresh.
+type VersionRefreshResult struct {
+	Changes []VersionChange
+	Err     error
+}
+
+// VersionChange is a table whose cached version differed from Ace's, e.g. because it was
+// altered out-of-band. Cached is nil if the table wasn't cached.
+type VersionChange struct {
+	Table  string
+	Cached *int `json:",omitempty"`
+	Ace    int
+}
+
 // Migrator manages the migration of Ace as new versioned tsvs come in.
 type Migrator struct {
 	versions                  versions.GetterSetter
@@ -32,29 +63,62 @@ type Migrator struct {
 	bpClient                  blueprint.Client
 	closer                    chan bool
 	oldVersionWaitClose       chan bool
-	versionIncrement          chan VersionIncrement
+	versionIncrementsQueued   <-chan bool
+	migrationRequests         chan MigrationRequest
+	newTables                 <-chan string
+	versionRefreshes          chan VersionRefresh
+	versionRefreshPeriod      time.Duration
 	wg                        sync.WaitGroup
 	pollPeriod                time.Duration
 	waitProcessorPeriod       time.Duration
 	migrationStarted          map[tableVersion]time.Time
-	offpeakStartHour          int
-	offpeakDurationHours      int
+	migrationStartedLock      sync.Mutex
+	maxConcurrentMigrations   int
+	offpeak                   lib.OffpeakSchedule
+	offpeakLock               sync.RWMutex
 	onpeakMigrationTimeoutMs  int
 	offpeakMigrationTimeoutMs int
+	dropSnapshotPrefix        string
+	forceDrift                bool
+	versionedTables           bool
+	lastActive                time.Time
+	lastActiveLock            sync.RWMutex
+	lastPoll                  *time.Time
+	pendingTables             []string
+	attempts                  map[string]Attempt
+	waitingSince              map[string]time.Time
+	waits                     map[string]TableWait
+	lastProgress              map[string]time.Time
+	stateLock                 sync.Mutex
+	created                   time.Time
+	watchdogClose             chan bool
 }
 
-// New returns a new Migrator for migrating schemas
+// New returns a new Migrator for migrating schemas. The tables of dropped events are unloaded under
+// dropSnapshotPrefix, an S3 URL, before they're dropped, unless it's empty. Tables whose columns have
+// drifted from blueprint's schema aren't migrated unless forceDrift is set. With versionedTables set,
+// files of versions a table waits to be migrated to are loaded into versioned tables meanwhile.
 func New(aceBack backend.Backend,
 	metaBack metadata.Reader,
 	blueprintClient blueprint.Client,
 	versions versions.GetterSetter,
 	pollPeriod time.Duration,
 	waitProcessorPeriod time.Duration,
-	offpeakStartHour int,
-	offpeakDurationHours int,
-	versionIncrement chan VersionIncrement,
+	offpeak lib.OffpeakSchedule,
+	versionIncrementsQueued <-chan bool,
+	migrationRequests chan MigrationRequest,
+	newTables <-chan string,
+	versionRefreshes chan VersionRefresh,
+	versionRefreshPeriod time.Duration,
 	onpeakMigrationTimeoutMs int,
-	offpeakMigrationTimeoutMs int) *Migrator {
+	offpeakMigrationTimeoutMs int,
+	maxConcurrentMigrations int,
+	dropSnapshotPrefix string,
+	forceDrift bool,
+	versionedTables bool) *Migrator {
+	if maxConcurrentMigrations < 1 {
+		maxConcurrentMigrations = 1
+	}
 	m := Migrator{
 		versions:                  versions,
 		aceBackend:                aceBack,
@@ -62,14 +126,28 @@ func New(aceBack backend.Backend,
 		bpClient:                  blueprintClient,
 		closer:                    make(chan bool),
 		oldVersionWaitClose:       make(chan bool),
-		versionIncrement:          versionIncrement,
+		versionIncrementsQueued:   versionIncrementsQueued,
+		migrationRequests:         migrationRequests,
+		newTables:                 newTables,
+		versionRefreshes:          versionRefreshes,
+		versionRefreshPeriod:      versionRefreshPeriod,
 		pollPeriod:                pollPeriod,
 		waitProcessorPeriod:       waitProcessorPeriod,
 		migrationStarted:          make(map[tableVersion]time.Time),
-		offpeakStartHour:          offpeakStartHour,
-		offpeakDurationHours:      offpeakDurationHours,
+		maxConcurrentMigrations:   maxConcurrentMigrations,
+		offpeak:                   offpeak,
 		onpeakMigrationTimeoutMs:  onpeakMigrationTimeoutMs,
 		offpeakMigrationTimeoutMs: offpeakMigrationTimeoutMs,
+		dropSnapshotPrefix:        dropSnapshotPrefix,
+		forceDrift:                forceDrift,
+		versionedTables:           versionedTables,
+		lastActive:                time.Now(),
+		attempts:                  make(map[string]Attempt),
+		waitingSince:              make(map[string]time.Time),
+		waits:                     make(map[string]TableWait),
+		lastProgress:              make(map[string]time.Time),
+		created:                   time.Now(),
+		watchdogClose:             make(chan bool),
 	}
 
 	m.wg.Add(1)
@@ -87,9 +165,33 @@ func (m *Migrator) findTablesToMigrate() ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error finding versions from unloaded tsvs: %v", err)
 	}
+	dropped, err := m.handleDroppedTables(tsvVersions)
+	if err != nil {
+		return nil, err
+	}
+	renamed, err := m.handleRenamedTables(tsvVersions)
+	if err != nil {
+		return nil, err
+	}
+	if m.versionedTables {
+		// Files loaded into versioned tables are no longer queued, but their tables still need migrating
+		routed, err := m.metaBackend.VersionedTables()
+		if err != nil {
+			return nil, fmt.Errorf("listing versioned tables: %v", err)
+		}
+		for _, v := range routed {
+			if v.Version > tsvVersions[v.Table] {
+				tsvVersions[v.Table] = v.Version
+			}
+		}
+	}
+	m.alignShards(tsvVersions)
 	var tables []string
 	for tsvTable, tsvVersion := range tsvVersions {
-		aceVersion, existant := m.versions.Get(tsvTable)
+		if dropped[tsvTable] || renamed[tsvTable] {
+			continue
+		}
+		aceVersion, existant := m.versions.Unpinned(tsvTable)
 		if !existant || tsvVersion > aceVersion {
 			tables = append(tables, tsvTable)
 		}
@@ -97,6 +199,41 @@ func (m *Migrator) findTablesToMigrate() ([]string, error) {
 	return tables, nil
 }
 
+// alignShards raises the version queued for each created table of a sharded event to the newest any
+// of the event's tables is at or has queued, so the event's migrations are applied to every shard,
+// including those no files of the new version have come in for.
+func (m *Migrator) alignShards(tsvVersions map[string]int) {
+	var queued []string
+	for table := range tsvVersions {
+		queued = append(queued, table)
+	}
+	aligned := make(map[string]bool)
+	for _, table := range queued {
+		if aligned[table] {
+			continue
+		}
+		siblings := m.bpClient.ShardSiblings(table)
+		newest := -1
+		for _, sibling := range siblings {
+			aligned[sibling] = true
+			if version, ok := tsvVersions[sibling]; ok && version > newest {
+				newest = version
+			}
+			if version, ok := m.versions.Unpinned(sibling); ok && version > newest {
+				newest = version
+			}
+		}
+		for _, sibling := range siblings {
+			version, created := m.versions.Unpinned(sibling)
+			if created && version < newest && tsvVersions[sibling] < newest {
+				logger.WithField("table", sibling).WithField("version", newest).
+					Info("Migrating shard with the rest of its event")
+				tsvVersions[sibling] = newest
+			}
+		}
+	}
+}
+
 //isOldVersionCleared checks to see if there are any tsvs for the given table and
 //version still in queue to be loaded. If there are, it prioritizes those tsvs
 //to be loaded.
@@ -117,6 +254,18 @@ func (m *Migrator) migrate(table string, to int, isOffPeak bool) error {
 	if err != nil {
 		return err
 	}
+	if backend.HasDropEvent(ops) {
+		err = m.dropTable(table, to)
+		if err == metadata.ErrTableLoading {
+			logger.WithField("table", table).WithField("version", to).Info("Waiting for loads to finish before dropping table")
+			m.setWait(table, to, WaitLoads)
+			return nil
+		}
+		return err
+	}
+	if oldName, ok := backend.TableRename(ops); ok {
+		return m.renameTable(oldName, table, to, cols)
+	}
 	exists, err := m.aceBackend.TableExists(table)
 	if err != nil {
 		return err
@@ -127,15 +276,21 @@ func (m *Migrator) migrate(table string, to int, isOffPeak bool) error {
 			return err
 		}
 	} else {
+		// type changes rewrite the whole column and rebuilds the whole table, so only do them offpeak
+		if (backend.HasTypeChange(ops) || backend.HasRebuild(ops)) && !isOffPeak {
+			logger.WithField("table", table).WithField("version", to).
+				Infof("Not migrating column type change or rebuild; waiting until offpeak at %dh UTC", m.offpeakStart())
+			m.setWait(table, to, WaitOffpeak)
+			return nil
+		}
 		// to migrate, first we wait until processor finishes the old version...
-		timeMigrationStarted, started := m.migrationStarted[tableVersion{table, to}]
+		timeMigrationStarted, started := m.waitStarted(table, to)
 		if !started {
-			now := time.Now()
-			m.migrationStarted[tableVersion{table, to}] = now
 			logger.WithField("table", table).
 				WithField("version", to).
-				WithField("until", now.Add(m.waitProcessorPeriod)).
+				WithField("until", timeMigrationStarted.Add(m.waitProcessorPeriod)).
 				Info("Starting to wait for processor before migrating")
+			m.setWait(table, to, WaitProcessor)
 			return nil
 		}
 		// don't do anything if we haven't waited long enough for processor
@@ -144,6 +299,7 @@ func (m *Migrator) migrate(table string, to int, isOffPeak bool) error {
 				WithField("version", to).
 				WithField("until", timeMigrationStarted.Add(m.waitProcessorPeriod)).
 				Info("Waiting for processor before migrating")
+			m.setWait(table, to, WaitProcessor)
 			return nil
 		}
 
@@ -154,18 +310,14 @@ func (m *Migrator) migrate(table string, to int, isOffPeak bool) error {
 		}
 		if !cleared {
 			logger.WithField("table", table).WithField("version", to).Info("Waiting for old version to clear.")
+			m.setWait(table, to, WaitOldVersion)
 			return nil
 		}
 
 		// everything is ready, now actually do the migration
-		logger.WithField("table", table).WithField("version", to).Info("Beginning to migrate")
-		timeoutMs := m.onpeakMigrationTimeoutMs
-		if isOffPeak {
-			timeoutMs = m.offpeakMigrationTimeoutMs
-		}
-		err = m.aceBackend.ApplyOperations(table, ops, cols, to, timeoutMs)
+		err = m.applyOperations(table, to, ops, cols, isOffPeak)
 		if err != nil {
-			return fmt.Errorf("Error applying operations to %s: %v", table, err)
+			return err
 		}
 	}
 	m.versions.Set(table, to)
@@ -174,44 +326,493 @@ func (m *Migrator) migrate(table string, to int, isOffPeak bool) error {
 	return nil
 }
 
-func (m *Migrator) isOffPeakHours() bool {
-	currentHour := time.Now().Hour()
-	if m.offpeakStartHour+m.offpeakDurationHours <= 24 {
-		if (m.offpeakStartHour <= currentHour) &&
-			(currentHour < m.offpeakStartHour+m.offpeakDurationHours) {
-			return true
+// dropTable tears down the table of an event dropped at the version: its queued files are dead-lettered
+// and later ones aren't loaded, its rows are unloaded to S3 if there's a snapshot prefix, and it's
+// dropped and forgotten. It returns metadata.ErrTableLoading while some of its files are being loaded.
+func (m *Migrator) dropTable(table string, version int) error {
+	exists, err := m.aceBackend.TableExists(table)
+	if err != nil {
+		return err
+	}
+	dropped := metadata.DroppedTable{Table: table, Version: version}
+	if exists && m.dropSnapshotPrefix != "" {
+		dropped.Snapshot = fmt.Sprintf("%s/%s/v%d/%s/", strings.TrimSuffix(m.dropSnapshotPrefix, "/"), table, version,
+			time.Now().In(time.UTC).Format("20060102T150405Z"))
+	}
+	drained, err := m.metaBackend.DropTable(dropped)
+	if err != nil {
+		return err
+	}
+	logger.WithField("table", table).WithField("version", version).WithField("files", drained).
+		Info("Dead-lettered queued files of dropped table")
+	return m.finishDrop(dropped)
+}
+
+// finishDrop unloads the dropped table to its snapshot, if any, then drops it and forgets its version.
+// It's retried on the next poll if it fails, since the table stays cached.
+func (m *Migrator) finishDrop(dropped metadata.DroppedTable) error {
+	exists, err := m.aceBackend.TableExists(dropped.Table)
+	if err != nil {
+		return err
+	}
+	if exists && dropped.Snapshot != "" {
+		err = m.aceBackend.UnloadTable(dropped.Table, dropped.Snapshot, m.offpeakMigrationTimeoutMs)
+		if err != nil {
+			return fmt.Errorf("unloading snapshot of dropped table: %v", err)
 		}
-		return false
+		logger.WithField("table", dropped.Table).WithField("snapshot", dropped.Snapshot).Info("Unloaded snapshot of dropped table")
 	}
-	// if duration bleeds into the new day, check the two segments before and after midnight
-	if (m.offpeakStartHour <= currentHour) &&
-		(currentHour < 24) {
-		return true
+	err = m.aceBackend.DropTable(dropped.Table)
+	if err != nil {
+		return fmt.Errorf("dropping table: %v", err)
 	}
-	if (0 <= currentHour) &&
-		(currentHour < (m.offpeakStartHour+m.offpeakDurationHours)%24) {
-		return true
+	m.versions.Delete(dropped.Table)
+	logger.WithField("table", dropped.Table).WithField("version", dropped.Version).Info("Dropped table of dropped event")
+	return nil
+}
+
+// handleDroppedTables finishes the teardowns of dropped tables that failed partway, dead-letters
+// the files of dropped tables' versions queued since, and recreates the tables of dropped events
+// that files of later versions have come in for, returning the dropped tables, which aren't migrated.
+func (m *Migrator) handleDroppedTables(tsvVersions map[string]int) (map[string]bool, error) {
+	droppedTables, err := m.metaBackend.DroppedTables()
+	if err != nil {
+		return nil, fmt.Errorf("listing dropped tables: %v", err)
 	}
-	return false
+	dropped := make(map[string]bool, len(droppedTables))
+	for _, d := range droppedTables {
+		dropped[d.Table] = true
+		// A pinned table is left as it is, files and all, until it's unpinned
+		if _, pinned := m.pinned(d.Table); pinned {
+			logger.WithField("table", d.Table).Info("Not handling drop of pinned table")
+			continue
+		}
+		// a table cached past the dropped version was recreated, but its drop wasn't forgotten
+		version, cached := m.versions.Unpinned(d.Table)
+		tornDown := !cached || version > d.Version
+		if !tornDown {
+			err = m.finishDrop(d)
+			if err != nil {
+				logger.WithError(err).WithField("table", d.Table).Error("Error finishing dropping table")
+			}
+			tornDown = err == nil
+		}
+		queued, ok := tsvVersions[d.Table]
+		if !ok {
+			continue
+		}
+		drained, err := m.metaBackend.DrainDroppedTable(d.Table, d.Version)
+		if err != nil {
+			logger.WithError(err).WithField("table", d.Table).Error("Error dead-lettering files of dropped table")
+			continue
+		}
+		if drained > 0 {
+			logger.WithField("table", d.Table).WithField("files", drained).Warn("Dead-lettered files queued for dropped table")
+		}
+		if queued <= d.Version || !tornDown {
+			continue
+		}
+		recreated, err := m.recreateDroppedTable(d)
+		if err != nil {
+			logger.WithError(err).WithField("table", d.Table).Error("Error recreating table of dropped event")
+			continue
+		}
+		if recreated {
+			delete(dropped, d.Table)
+		}
+	}
+	return dropped, nil
+}
+
+// recreateDroppedTable recreates the table of a dropped event that blueprint has since brought back,
+// by a create or a cancelled drop, at the version after the drop, then forgets the drop so the table
+// is loaded and migrated again. It returns false, doing nothing, if the version drops the event again.
+func (m *Migrator) recreateDroppedTable(d metadata.DroppedTable) (bool, error) {
+	to := d.Version + 1
+	ops, cols, err := m.bpClient.GetMigration(d.Table, to)
+	if err != nil {
+		return false, err
+	}
+	if backend.HasDropEvent(ops) {
+		return false, nil
+	}
+	exists, err := m.aceBackend.TableExists(d.Table)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		err = m.aceBackend.CreateTable(d.Table, creationOperations(ops, cols), cols, to)
+		if err != nil {
+			return false, fmt.Errorf("creating table: %v", err)
+		}
+	}
+	m.versions.Set(d.Table, to)
+	err = m.metaBackend.ForgetDroppedTable(d.Table, d.Version)
+	if err != nil {
+		return false, err
+	}
+	logger.WithField("table", d.Table).WithField("version", to).Info("Recreated table of dropped event brought back")
+	return true, nil
+}
+
+// creationOperations returns the operations creating a table from scratch at a version: the
+// version's own if they only add columns, as when an event is created again, or else additions
+// of the version's columns, as when a drop is cancelled.
+func creationOperations(ops []scoop_protocol.Operation, cols []scoop_protocol.ColumnDefinition) []scoop_protocol.Operation {
+	additions := len(ops) > 0
+	for _, op := range ops {
+		if op.Action != scoop_protocol.ADD {
+			additions = false
+			break
+		}
+	}
+	if additions {
+		return ops
+	}
+	ops = make([]scoop_protocol.Operation, 0, len(cols))
+	for _, col := range cols {
+		ops = append(ops, scoop_protocol.NewAddOperation(col.OutboundName, col.InboundName,
+			col.Transformer, col.ColumnCreationOptions, col.SupportingColumns))
+	}
+	return ops
+}
+
+// renameTable renames the table of an event renamed at the version to its new name, once the processor
+// has had time to switch names and the files of the table's version are loaded: the files still queued
+// under the old name are moved to the new one, then the table is renamed in Redshift and the versions
+// cache. It waits, returning nil, while files of the table are being loaded.
+func (m *Migrator) renameTable(oldName, newName string, to int, cols []scoop_protocol.ColumnDefinition) error {
+	entry := logger.WithField("table", oldName).WithField("newName", newName).WithField("version", to)
+	current, cached := m.versions.Unpinned(oldName)
+	if !cached {
+		return fmt.Errorf("can't rename %s to %s: %s has no version", oldName, newName, oldName)
+	}
+	if pin, pinned := m.pinned(oldName); pinned {
+		entry.WithField("pinnedVersion", pin).Info("Not renaming; table is pinned")
+		m.setWait(newName, to, WaitPinned)
+		return nil
+	}
+	timeRenameStarted, started := m.waitStarted(newName, to)
+	if !started || time.Since(timeRenameStarted) < m.waitProcessorPeriod {
+		entry.WithField("until", timeRenameStarted.Add(m.waitProcessorPeriod)).
+			Info("Waiting for processor before renaming table")
+		m.setWait(newName, to, WaitProcessor)
+		return nil
+	}
+	cleared, err := m.isOldVersionCleared(oldName, current)
+	if err != nil {
+		return fmt.Errorf("Error waiting for old name to clear: %v", err)
+	}
+	if !cleared {
+		entry.Info("Waiting for files of old name to clear before renaming table")
+		m.setWait(newName, to, WaitOldVersion)
+		return nil
+	}
+
+	renamed := metadata.RenamedTable{Table: oldName, NewName: newName, Version: to}
+	moved, err := m.metaBackend.RenameTable(renamed)
+	if err == metadata.ErrTableLoading {
+		entry.Info("Waiting for loads to finish before renaming table")
+		m.setWait(newName, to, WaitLoads)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entry.WithField("files", moved).Info("Moved queued files of renamed table to its new name")
+	return m.finishRename(renamed, cols)
+}
+
+// finishRename renames the table in Redshift, unless it already was, then in the versions cache. It's
+// retried on the next poll if it fails, since the old name stays cached.
+func (m *Migrator) finishRename(renamed metadata.RenamedTable, cols []scoop_protocol.ColumnDefinition) error {
+	exists, err := m.aceBackend.TableExists(renamed.Table)
+	if err != nil {
+		return err
+	}
+	if exists {
+		err = m.aceBackend.RenameTable(renamed.Table, renamed.NewName, renamed.Version, cols)
+		if err != nil {
+			return fmt.Errorf("renaming table: %v", err)
+		}
+	}
+	m.versions.Rename(renamed.Table, renamed.NewName, renamed.Version)
+	logger.WithField("table", renamed.Table).WithField("newName", renamed.NewName).
+		WithField("version", renamed.Version).Info("Renamed table of renamed event")
+	return nil
+}
+
+// handleRenamedTables finishes the renames of tables that failed partway and moves the files queued
+// under renamed tables' old names since to their new ones, returning the old names, which aren't migrated.
+func (m *Migrator) handleRenamedTables(tsvVersions map[string]int) (map[string]bool, error) {
+	renamedTables, err := m.metaBackend.RenamedTables()
+	if err != nil {
+		return nil, fmt.Errorf("listing renamed tables: %v", err)
+	}
+	renamed := make(map[string]bool, len(renamedTables))
+	for _, r := range renamedTables {
+		renamed[r.Table] = true
+		// A pinned table is left as it is, files and all, until it's unpinned
+		if _, pinned := m.pinned(r.Table); pinned {
+			logger.WithField("table", r.Table).Info("Not handling rename of pinned table")
+			continue
+		}
+		if _, cached := m.versions.Unpinned(r.Table); cached {
+			cols, err := m.bpClient.GetSchema(r.NewName, r.Version)
+			if err == nil {
+				err = m.finishRename(r, cols)
+			}
+			if err != nil {
+				logger.WithError(err).WithField("table", r.Table).Error("Error finishing renaming table")
+			}
+		}
+		if _, queued := tsvVersions[r.Table]; !queued {
+			continue
+		}
+		moved, err := m.metaBackend.MoveRenamedTable(r)
+		if err != nil {
+			logger.WithError(err).WithField("table", r.Table).Error("Error moving files of renamed table")
+			continue
+		}
+		if moved > 0 {
+			logger.WithField("table", r.Table).WithField("newName", r.NewName).WithField("files", moved).
+				Warn("Moved files queued under the old name of renamed table")
+		}
+	}
+	return renamed, nil
+}
+
+// waitStarted returns when the migrator started waiting for the processor before migrating the
+// table to the version, and whether it already had; if it hadn't, it starts waiting now.
+func (m *Migrator) waitStarted(table string, to int) (time.Time, bool) {
+	m.migrationStartedLock.Lock()
+	defer m.migrationStartedLock.Unlock()
+	started, ok := m.migrationStarted[tableVersion{table, to}]
+	if !ok {
+		started = time.Now()
+		m.migrationStarted[tableVersion{table, to}] = started
+	}
+	return started, ok
+}
+
+func (m *Migrator) applyOperations(table string, to int, ops []scoop_protocol.Operation,
+	cols []scoop_protocol.ColumnDefinition, isOffPeak bool) error {
+	if !m.forceDrift {
+		diff, err := m.SchemaDiff(table, to-1)
+		if err != nil {
+			return fmt.Errorf("checking %s for schema drift: %v", table, err)
+		}
+		if diff.HasDrift() {
+			return fmt.Errorf("%s has drifted from blueprint's version %d schema in %d columns, e.g. %s; "+
+				"see /control/schema_diff/%s, or migrate with -forceDrift", table, to-1, len(diff.Drift),
+				diff.Drift[0].Column, table)
+		}
+	}
+	logger.WithField("table", table).WithField("version", to).Info("Beginning to migrate")
+	timeoutMs := m.onpeakMigrationTimeoutMs
+	if isOffPeak {
+		timeoutMs = m.offpeakMigrationTimeoutMs
+	}
+	err := m.aceBackend.ApplyOperations(table, ops, cols, to, timeoutMs)
+	if err != nil {
+		return fmt.Errorf("Error applying operations to %s: %v", table, err)
+	}
+	return nil
+}
+
+// SchemaDiff compares the table's live columns with blueprint's schema at the version, or at the
+// table's current version if the version is negative.
+func (m *Migrator) SchemaDiff(table string, version int) (backend.SchemaDiff, error) {
+	if version < 0 {
+		current, ok := m.versions.Unpinned(table)
+		if !ok {
+			return backend.SchemaDiff{}, fmt.Errorf("table %s has no version", table)
+		}
+		version = current
+	}
+	expected, err := m.bpClient.GetSchema(table, version)
+	if err != nil {
+		return backend.SchemaDiff{}, err
+	}
+	live, err := m.aceBackend.LiveColumns(table)
+	if err != nil {
+		return backend.SchemaDiff{}, fmt.Errorf("getting live columns: %v", err)
+	}
+	return backend.DiffSchema(table, version, expected, live), nil
+}
+
+// migrateNow migrates the table to the given version without waiting for offpeak hours or for
+// the processor. Unlike migrate, it fails rather than waits if the migration can't happen yet:
+// if the version isn't the table's next one, the table is locked, or older TSVs are still queued.
+func (m *Migrator) migrateNow(table string, to int) error {
+	currentVersion, exists := m.versions.Unpinned(table)
+	expected := 0
+	if exists {
+		expected = currentVersion + 1
+	}
+	if to != expected {
+		return fmt.Errorf("table %s can only be migrated to version %d, not %d", table, expected, to)
+	}
+	if pin, pinned := m.pinned(table); pinned {
+		return fmt.Errorf("table %s is pinned to version %d; unpin it first", table, pin)
+	}
+	ops, cols, err := m.bpClient.GetMigration(table, to)
+	if err != nil {
+		return err
+	}
+	if backend.HasDropEvent(ops) {
+		return m.dropTable(table, to)
+	}
+	tableExists, err := m.aceBackend.TableExists(table)
+	if err != nil {
+		return err
+	}
+	if !tableExists {
+		err = m.aceBackend.CreateTable(table, ops, cols, to)
+		if err != nil {
+			return err
+		}
+		m.versions.Set(table, to)
+		logger.WithField("table", table).WithField("version", to).Info("Created table on request")
+		return nil
+	}
+
+	locked, err := m.aceBackend.TableLocked(table)
+	if err != nil {
+		return fmt.Errorf("checking for table lock: %v", err)
+	}
+	if locked {
+		return fmt.Errorf("table %s is locked", table)
+	}
+	cleared, err := m.isOldVersionCleared(table, to-1)
+	if err != nil {
+		return fmt.Errorf("checking for old version TSVs: %v", err)
+	}
+	if !cleared {
+		return fmt.Errorf("TSVs of %s version %d are still queued; they've been force loaded, so retry once they're loaded",
+			table, to-1)
+	}
+	err = m.applyOperations(table, to, ops, cols, m.IsOffPeakHours())
+	if err != nil {
+		return err
+	}
+	m.versions.Set(table, to)
+	logger.WithField("table", table).WithField("version", to).Info("Migrated table successfully on request")
+	if m.versionedTables {
+		// The migration recreated the view without the versioned tables
+		m.routeVersionedTables()
+	}
+	return nil
+}
+
+// SetOffpeakSchedule changes the offpeak schedule, when slow table changes run.
+func (m *Migrator) SetOffpeakSchedule(schedule lib.OffpeakSchedule) {
+	m.offpeakLock.Lock()
+	defer m.offpeakLock.Unlock()
+	m.offpeak = schedule
+}
+
+// OffpeakSchedule returns the offpeak schedule.
+func (m *Migrator) OffpeakSchedule() lib.OffpeakSchedule {
+	m.offpeakLock.RLock()
+	defer m.offpeakLock.RUnlock()
+	return m.offpeak
 }
 
-func (m *Migrator) incrementVersion(verInc VersionIncrement) {
-	exists, err := m.aceBackend.TableExists(verInc.Table)
+// offpeakStart returns the hour, in UTC, today's offpeak window starts.
+func (m *Migrator) offpeakStart() int {
+	return m.OffpeakSchedule().Window(time.Now().UTC().Weekday()).StartHour
+}
+
+// IsOffPeakHours returns whether it's currently offpeak, when slow table changes run.
+func (m *Migrator) IsOffPeakHours() bool {
+	return m.OffpeakSchedule().Contains(time.Now())
+}
+
+// incrementVersion sets the table, which mustn't exist yet, to the increment's version. If the table
+// is already at the version, the increment was applied before it could be acknowledged, so it's done.
+func (m *Migrator) incrementVersion(increment metadata.VersionIncrement) error {
+	exists, err := m.aceBackend.TableExists(increment.Table)
 	switch {
 	case err != nil:
-		verInc.Response <- fmt.Errorf(
-			"error determining if table %s exists: %v", verInc.Table, err)
+		return fmt.Errorf("error determining if table %s exists: %v", increment.Table, err)
 	case exists:
-		verInc.Response <- fmt.Errorf(
-			"attempted to increment version of table that exists: %s", verInc.Table)
-	default:
-		err = m.aceBackend.ApplyOperations(verInc.Table, nil, nil, verInc.Version, m.offpeakMigrationTimeoutMs)
-		if err == nil {
-			logger.Infof("Incremented table %s to version %d",
-				verInc.Table, verInc.Version)
-			m.versions.Set(verInc.Table, verInc.Version)
+		if version, cached := m.versions.Unpinned(increment.Table); cached && version == increment.Version {
+			return nil
 		}
-		verInc.Response <- err
+		return fmt.Errorf("attempted to increment version of table that exists: %s", increment.Table)
+	}
+	err = m.aceBackend.ApplyOperations(increment.Table, nil, nil, increment.Version, m.offpeakMigrationTimeoutMs)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Incremented table %s to version %d", increment.Table, increment.Version)
+	m.versions.Set(increment.Table, increment.Version)
+	return nil
+}
+
+// processVersionIncrements applies the version increments queued in ingesterdb in order,
+// acknowledging each. An increment whose acknowledgement fails stays queued and is retried.
+func (m *Migrator) processVersionIncrements() {
+	increments, err := m.metaBackend.PendingVersionIncrements()
+	if err != nil {
+		logger.WithError(err).Error("Error listing pending version increments")
+		return
+	}
+	for _, increment := range increments {
+		if err = m.held(); err == nil {
+			err = m.incrementVersion(increment)
+		}
+		if err != nil {
+			logger.WithError(err).WithField("table", increment.Table).WithField("id", increment.ID).
+				Error("Error incrementing version")
+		}
+		ackErr := m.metaBackend.FinishVersionIncrement(increment.ID, err)
+		if ackErr != nil {
+			logger.WithError(ackErr).WithField("id", increment.ID).
+				Error("Error acknowledging version increment; it will be retried")
+		}
+	}
+}
+
+// refreshVersions re-reads the table versions from Ace, updating and returning the cached versions
+// that differ. Tables missing from Ace are left cached, since their creation may not be visible yet.
+func (m *Migrator) refreshVersions() ([]VersionChange, error) {
+	aceVersions, err := m.aceBackend.TableVersions()
+	if err != nil {
+		return nil, fmt.Errorf("getting table versions from ace: %v", err)
+	}
+	changes := []VersionChange{}
+	for table, version := range aceVersions {
+		cached, exists := m.versions.Unpinned(table)
+		if exists && cached == version {
+			continue
+		}
+		change := VersionChange{Table: table, Ace: version}
+		fields := logger.WithField("table", table).WithField("aceVersion", version)
+		if exists {
+			change.Cached = &cached
+			fields = fields.WithField("cachedVersion", cached)
+		}
+		fields.Warn("Cached table version differs from ace; updating cache")
+		m.versions.Set(table, version)
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Table < changes[j].Table })
+	return changes, nil
+}
+
+// createNewTable creates a table that has just been queued for the first time, without waiting
+// for the next poll.
+func (m *Migrator) createNewTable(table string) {
+	if _, exists := m.versions.Unpinned(table); exists {
+		return
+	}
+	logger.WithField("table", table).Info("Creating newly queued table")
+	err := m.migrate(table, 0, m.IsOffPeakHours())
+	m.recordAttempt(table, 0, false, err)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).Error("Error creating newly queued table")
 	}
 }
 
@@ -219,52 +820,183 @@ func (m *Migrator) findAndApplyMigrations() {
 	outdatedTables, err := m.findTablesToMigrate()
 	if err != nil {
 		logger.WithError(err).Error("Error finding migrations to apply")
+	} else {
+		m.recordPoll(outdatedTables)
 	}
 	if len(outdatedTables) == 0 {
 		logger.Infof("Migrator didn't find any tables to migrate.")
 	} else {
 		logger.WithField("numTables", len(outdatedTables)).Infof("Migrator found tables to migrate.")
 	}
+	// Each table is migrated by one goroutine, so its migrations stay in order, and its backend
+	// table lock keeps loads out while it's altered.
+	sem := make(chan struct{}, m.maxConcurrentMigrations)
+	var wg sync.WaitGroup
 	for _, table := range outdatedTables {
-		var newVersion int
-		currentVersion, exists := m.versions.Get(table)
-		if !exists { // table doesn't exist yet, create it by 'migrating' to version 0
-			newVersion = 0
+		table := table
+		sem <- struct{}{}
+		wg.Add(1)
+		logger.Go(func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			m.migrateOutdated(table)
+		})
+	}
+	wg.Wait()
+}
+
+// routeVersionedTables creates versioned tables for the versions tables are waiting to be migrated to,
+// and merges those of versions they've been migrated to.
+func (m *Migrator) routeVersionedTables() {
+	tsvVersions, err := m.metaBackend.Versions()
+	if err != nil {
+		logger.WithError(err).Error("Error finding versions of queued tsvs")
+		return
+	}
+	all, err := m.metaBackend.VersionedTables()
+	if err != nil {
+		logger.WithError(err).Error("Error listing versioned tables")
+		return
+	}
+	routed := make(map[string][]int)
+	for _, v := range all {
+		routed[v.Table] = append(routed[v.Table], v.Version)
+		if _, queued := tsvVersions[v.Table]; !queued {
+			tsvVersions[v.Table] = v.Version
+		}
+	}
+	for table, pending := range tsvVersions {
+		current, exists := m.versions.Unpinned(table)
+		if !exists || pending <= current && len(routed[table]) == 0 {
+			continue
+		}
+		// The versioned tables of a pinned table are left as they are until it's unpinned
+		if _, pinned := m.pinned(table); pinned {
+			continue
+		}
+		if err = m.routeVersions(table, current, pending, routed[table]); err != nil {
+			logger.WithError(err).WithField("table", table).Error("Error updating versioned tables")
+		}
+	}
+}
+
+// routeVersions has the table's files of the versions after current up to pending loaded into
+// versioned tables, unioned into its view, and merges the versioned tables of the routed versions
+// it's been migrated to once none of their loads are running.
+func (m *Migrator) routeVersions(table string, current, pending int, routed []int) error {
+	for version := current + 1; version <= pending; version++ {
+		if containsVersion(routed, version) {
+			continue
+		}
+		cols, err := m.bpClient.GetSchema(table, version)
+		if err != nil || len(cols) == 0 {
+			// e.g. the version drops the event; later versions wait for it like before
+			logger.WithError(err).WithField("table", table).WithField("version", version).
+				Warn("Not loading version into a versioned table; blueprint has no schema for it")
+			break
+		}
+		if err = m.aceBackend.CreateVersionedTable(table, version, cols); err != nil {
+			return err
+		}
+		if err = m.metaBackend.AddVersionedTable(table, version); err != nil {
+			return err
+		}
+		logger.WithField("table", table).WithField("version", version).
+			WithField("versionedTable", backend.VersionedTableName(table, version)).
+			Info("Loading version into versioned table until the table is migrated")
+		routed = append(routed, version)
+	}
+	if len(routed) == 0 {
+		return nil
+	}
+
+	var unioned, mergeable []int
+	for _, version := range routed {
+		if version > current {
+			unioned = append(unioned, version)
+			continue
+		}
+		loading, err := m.metaBackend.VersionLoading(table, version)
+		if err != nil {
+			return err
+		}
+		if loading {
+			unioned = append(unioned, version)
 		} else {
-			newVersion = currentVersion + 1
-		}
-
-		// We allow table creation no matter what.
-		// Migrate table only if A) currently offpeak hours OR B) force load on the table has been requested.
-		// We cannot on-peak migrate a table if it is locked
-		var forceLoadRequested, tableLocked bool
-		if newVersion > 0 {
-			if !m.isOffPeakHours() {
-				forceLoadRequested, err = m.metaBackend.IsForceLoadRequested(table)
-				if err != nil {
-					logger.WithError(err).WithField("table", table).WithField("version", newVersion).Error("Error checking for pending force load")
-					continue
-				}
-				if !forceLoadRequested {
-					logger.WithField("table", table).WithField("version", newVersion).Infof("Not migrating; waiting until offpeak at %dh UTC", m.offpeakStartHour)
-					continue
-				}
-
-				tableLocked, err = m.aceBackend.TableLocked(table)
-				if err != nil {
-					logger.WithError(err).WithField("table", table).Error("Error checking for table lock")
-					continue
-				}
-				if tableLocked {
-					logger.WithField("table", table).WithField("version", newVersion).Infof("Not migrating; on-peak and table is locked")
-					continue
-				}
-			}
+			mergeable = append(mergeable, version)
+		}
+	}
+	if len(mergeable) == 0 {
+		return m.aceBackend.UnionVersionedTables(table, unioned)
+	}
+	for _, version := range mergeable {
+		err := m.aceBackend.MergeVersionedTable(table, version, unioned)
+		if err != nil {
+			return err
+		}
+		if err = m.metaBackend.RemoveVersionedTable(table, version); err != nil {
+			return err
+		}
+		logger.WithField("table", table).WithField("version", version).Info("Merged versioned table into table")
+	}
+	return nil
+}
+
+func containsVersion(versions []int, version int) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
 		}
-		err = m.migrate(table, newVersion, m.isOffPeakHours())
+	}
+	return false
+}
+
+// migrateOutdated migrates the table to its next version, or creates it if it doesn't exist yet.
+func (m *Migrator) migrateOutdated(table string) {
+	var newVersion int
+	currentVersion, exists := m.versions.Unpinned(table)
+	if !exists { // table doesn't exist yet, create it by 'migrating' to version 0
+		newVersion = 0
+	} else {
+		newVersion = currentVersion + 1
+	}
+	if pin, pinned := m.pinned(table); pinned {
+		logger.WithField("table", table).WithField("version", newVersion).WithField("pinnedVersion", pin).
+			Info("Not migrating; table is pinned")
+		m.setWait(table, newVersion, WaitPinned)
+		return
+	}
+
+	// We allow table creation no matter what.
+	// Migrate table only if A) currently offpeak hours OR B) force load on the table has been requested.
+	// We cannot on-peak migrate a table if it is locked
+	if newVersion > 0 && !m.IsOffPeakHours() {
+		forceLoadRequested, err := m.metaBackend.IsForceLoadRequested(table)
 		if err != nil {
-			logger.WithError(err).WithField("table", table).WithField("version", newVersion).Error("Error migrating table")
+			logger.WithError(err).WithField("table", table).WithField("version", newVersion).Error("Error checking for pending force load")
+			return
 		}
+		if !forceLoadRequested {
+			logger.WithField("table", table).WithField("version", newVersion).Infof("Not migrating; waiting until offpeak at %dh UTC", m.offpeakStart())
+			m.setWait(table, newVersion, WaitOffpeak)
+			return
+		}
+
+		tableLocked, err := m.aceBackend.TableLocked(table)
+		if err != nil {
+			logger.WithError(err).WithField("table", table).Error("Error checking for table lock")
+			return
+		}
+		if tableLocked {
+			logger.WithField("table", table).WithField("version", newVersion).Infof("Not migrating; on-peak and table is locked")
+			m.setWait(table, newVersion, WaitLocked)
+			return
+		}
+	}
+	err := m.migrate(table, newVersion, m.IsOffPeakHours())
+	m.recordAttempt(table, newVersion, false, err)
+	if err != nil {
+		logger.WithError(err).WithField("table", table).WithField("version", newVersion).Error("Error migrating table")
 	}
 }
 
@@ -272,21 +1004,96 @@ func (m *Migrator) loop() {
 	logger.Info("Migrator started.")
 	defer logger.Info("Migrator stopped.")
 	tick := time.NewTicker(m.pollPeriod)
+	var refreshTick <-chan time.Time
+	if m.versionRefreshPeriod > 0 {
+		refreshTicker := time.NewTicker(m.versionRefreshPeriod)
+		defer refreshTicker.Stop()
+		refreshTick = refreshTicker.C
+	}
+	// Finish the increments left queued by the last run
+	m.processVersionIncrements()
 	for {
 		select {
-		case verInc := <-m.versionIncrement:
-			m.incrementVersion(verInc)
+		case <-m.versionIncrementsQueued:
+			m.processVersionIncrements()
+		case req := <-m.migrationRequests:
+			if err := m.held(); err != nil {
+				req.Response <- err
+				break
+			}
+			err := m.migrateNow(req.Table, req.Version)
+			m.recordAttempt(req.Table, req.Version, true, err)
+			req.Response <- err
+		case table, ok := <-m.newTables:
+			if !ok {
+				// stop selecting on the closed channel; polling still finds new tables
+				m.newTables = nil
+				continue
+			}
+			// polling finds the table once the window is over
+			if m.held() == nil {
+				m.createNewTable(table)
+			}
+		case req := <-m.versionRefreshes:
+			changes, err := m.refreshVersions()
+			req.Response <- VersionRefreshResult{Changes: changes, Err: err}
+		case <-refreshTick:
+			if _, err := m.refreshVersions(); err != nil {
+				logger.WithError(err).Error("Error refreshing table versions")
+			}
 		case <-tick.C:
+			// Increments queued while the migrator was down or missed are picked up by the poll
+			m.processVersionIncrements()
+			if err := m.held(); err != nil {
+				logger.WithError(err).Info("Not looking for migrations")
+				break
+			}
 			m.findAndApplyMigrations()
+			if m.versionedTables {
+				m.routeVersionedTables()
+			}
 		case <-m.closer:
 			return
 		}
+		m.markActive()
+	}
+}
+
+// held returns why the migrator can't change tables now: errMaintenance during a maintenance window,
+// or errDisabled if it's switched off. Migrations aren't held up if either can't be checked.
+func (m *Migrator) held() error {
+	inMaintenance, err := m.metaBackend.InMaintenance()
+	if err != nil {
+		logger.WithError(err).Error("Error checking for maintenance window")
+	} else if inMaintenance {
+		return errMaintenance
 	}
+	disabled, err := m.metaBackend.ComponentDisabled(metadata.ComponentMigrator)
+	if err != nil {
+		logger.WithError(err).Error("Error checking migrator toggle")
+	} else if disabled {
+		return errDisabled
+	}
+	return nil
+}
+
+func (m *Migrator) markActive() {
+	m.lastActiveLock.Lock()
+	defer m.lastActiveLock.Unlock()
+	m.lastActive = time.Now()
+}
+
+// LastActive returns when the migrator last finished handling a poll or request.
+func (m *Migrator) LastActive() time.Time {
+	m.lastActiveLock.RLock()
+	defer m.lastActiveLock.RUnlock()
+	return m.lastActive
 }
 
 // Close signals the migrator to stop looking for new migrations and waits until
 // it's finished any migrations.
 func (m *Migrator) Close() {
+	close(m.watchdogClose)
 	m.closer <- true
 	m.wg.Wait()
 }
@@ -0,0 +1,188 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/blueprint"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/versions"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// fakeReader is ingesterdb with the dropped and renamed tables given, recording which tables' files
+// were dead-lettered or moved.
+type fakeReader struct {
+	metadata.Reader
+	dropped     []metadata.DroppedTable
+	renamed     []metadata.RenamedTable
+	drained     []string
+	moved       []string
+	maintenance bool
+	disabled    bool
+	// err fails the maintenance and toggle checks
+	err error
+}
+
+func (r *fakeReader) InMaintenance() (bool, error) { return r.maintenance, r.err }
+
+func (r *fakeReader) ComponentDisabled(component metadata.Component) (bool, error) {
+	return r.disabled && component == metadata.ComponentMigrator, r.err
+}
+
+func (r *fakeReader) DroppedTables() ([]metadata.DroppedTable, error) { return r.dropped, nil }
+func (r *fakeReader) RenamedTables() ([]metadata.RenamedTable, error) { return r.renamed, nil }
+
+func (r *fakeReader) DrainDroppedTable(table string, version int) (int64, error) {
+	r.drained = append(r.drained, table)
+	return 1, nil
+}
+
+func (r *fakeReader) MoveRenamedTable(renamed metadata.RenamedTable) (int64, error) {
+	r.moved = append(r.moved, renamed.Table)
+	return 1, nil
+}
+
+// fakeAce is a Redshift whose tables all exist, recording which were dropped or renamed, and
+// copying the typeChanges' tables.
+type fakeAce struct {
+	backend.Backend
+	dropped     []string
+	renamed     []string
+	typeChanges []backend.TypeChangeProgress
+}
+
+func (a *fakeAce) TableExists(string) (bool, error) { return true, nil }
+
+func (a *fakeAce) TypeChangeProgress() []backend.TypeChangeProgress { return a.typeChanges }
+
+func (a *fakeAce) DropTable(table string) error {
+	a.dropped = append(a.dropped, table)
+	return nil
+}
+
+// newTestMigrator returns a Migrator of the tables' versions that isn't polling.
+func newTestMigrator(tables map[string]int, meta metadata.Reader, ace backend.Backend) *Migrator {
+	return &Migrator{
+		versions:         versions.New(tables),
+		aceBackend:       ace,
+		metaBackend:      meta,
+		migrationStarted: make(map[tableVersion]time.Time),
+		attempts:         make(map[string]Attempt),
+		waitingSince:     make(map[string]time.Time),
+		waits:            make(map[string]TableWait),
+		lastProgress:     make(map[string]time.Time),
+		created:          time.Now(),
+	}
+}
+
+func TestHeld(t *testing.T) {
+	meta := &fakeReader{}
+	m := newTestMigrator(nil, meta, &fakeAce{})
+	assert.NoError(t, m.held())
+
+	meta.disabled = true
+	assert.Equal(t, errDisabled, m.held())
+	meta.maintenance = true
+	assert.Equal(t, errMaintenance, m.held(), "a maintenance window is reported first")
+
+	meta.err = errors.New("connection refused")
+	assert.NoError(t, m.held(), "migrations aren't held up when the checks fail")
+}
+
+// routingReader is ingesterdb with TSVs queued at the versions, and the versioned tables, of which
+// the loading ones have loads running.
+type routingReader struct {
+	metadata.Reader
+	tsvVersions map[string]int
+	versioned   []metadata.VersionedTable
+	loading     map[string]bool
+	added       []string
+	removed     []string
+}
+
+func (r *routingReader) Versions() (map[string]int, error) { return r.tsvVersions, nil }
+
+func (r *routingReader) VersionedTables() ([]metadata.VersionedTable, error) { return r.versioned, nil }
+
+func (r *routingReader) AddVersionedTable(table string, version int) error {
+	r.added = append(r.added, backend.VersionedTableName(table, version))
+	return nil
+}
+
+func (r *routingReader) RemoveVersionedTable(table string, version int) error {
+	r.removed = append(r.removed, backend.VersionedTableName(table, version))
+	return nil
+}
+
+func (r *routingReader) VersionLoading(table string, version int) (bool, error) {
+	return r.loading[backend.VersionedTableName(table, version)], nil
+}
+
+// routingAce is a Redshift recording the versioned tables created, and the versions unioned into and
+// merged into each table.
+type routingAce struct {
+	backend.Backend
+	created []string
+	unioned map[string][]int
+	merged  []string
+}
+
+func (a *routingAce) CreateVersionedTable(table string, version int, cols []scoop_protocol.ColumnDefinition) error {
+	a.created = append(a.created, backend.VersionedTableName(table, version))
+	return nil
+}
+
+func (a *routingAce) UnionVersionedTables(table string, versions []int) error {
+	a.unioned[table] = versions
+	return nil
+}
+
+func (a *routingAce) MergeVersionedTable(table string, version int, unioned []int) error {
+	a.merged = append(a.merged, backend.VersionedTableName(table, version))
+	a.unioned[table] = unioned
+	return nil
+}
+
+func TestRouteVersionedTables(t *testing.T) {
+	// Blueprint has schemas up to version 3 of chat
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schema/chat" || r.URL.Query().Get("version") > "3" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, `[{"Columns": [{"OutboundName": "time", "Transformer": "f@timestamp@unix"}]}]`)
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	meta := &routingReader{
+		tsvVersions: map[string]int{"chat": 4, "video": 5, "game": 2, "new": 0, "pinned": 3},
+		versioned: []metadata.VersionedTable{
+			{Table: "video", Version: 3}, {Table: "video", Version: 4}, {Table: "video", Version: 5},
+			{Table: "pinned", Version: 2},
+		},
+		loading: map[string]bool{"video_v4": true},
+	}
+	ace := &routingAce{unioned: make(map[string][]int)}
+	m := newTestMigrator(map[string]int{"chat": 2, "video": 4, "game": 2, "pinned": 1}, nil, ace)
+	m.metaBackend = meta
+	m.bpClient = blueprint.New(u.Host, 0, monitoring.NewMockStatter())
+	assert.NoError(t, m.PinVersion("pinned", 1))
+
+	m.routeVersionedTables()
+	assert.Equal(t, []string{"chat_v3"}, ace.created, "versions blueprint has no schema for wait for the table")
+	assert.Equal(t, []string{"chat_v3"}, meta.added)
+	assert.Equal(t, []string{"video_v3"}, ace.merged, "versions the table's reached are merged once not loading")
+	assert.Equal(t, []string{"video_v3"}, meta.removed)
+	assert.Equal(t, map[string][]int{"chat": {3}, "video": {4, 5}}, ace.unioned,
+		"up-to-date, new and pinned tables are left alone")
+}
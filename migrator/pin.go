@@ -0,0 +1,45 @@
+package migrator
+
+import (
+	"fmt"
+
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// PinVersion pins the table to a version no newer than its own, as an emergency brake on a bad
+// migration: loads treat the table as being at the version, holding files of newer versions, and
+// the table isn't migrated until it's unpinned. Pins aren't persisted, so a restart drops them.
+func (m *Migrator) PinVersion(table string, version int) error {
+	current, exists := m.versions.Unpinned(table)
+	switch {
+	case !exists:
+		return fmt.Errorf("table %s has no version", table)
+	case version < 0 || version > current:
+		return fmt.Errorf("table %s can only be pinned to versions 0 to %d, not %d", table, current, version)
+	}
+	m.versions.Pin(table, version)
+	logger.WithField("table", table).WithField("version", version).WithField("currentVersion", current).
+		Warn("Pinned table version; migrations of it are blocked until it's unpinned")
+	return nil
+}
+
+// UnpinVersion removes the table's pin, returning whether it had one. The table's next migration
+// starts from its own version.
+func (m *Migrator) UnpinVersion(table string) bool {
+	version, pinned := m.versions.Unpin(table)
+	if pinned {
+		logger.WithField("table", table).WithField("version", version).Info("Unpinned table version")
+	}
+	return pinned
+}
+
+// Pins returns the version each pinned table is pinned to.
+func (m *Migrator) Pins() map[string]int {
+	return m.versions.Pins()
+}
+
+// pinned returns the version the table is pinned to, and whether it is.
+func (m *Migrator) pinned(table string) (int, bool) {
+	version, ok := m.versions.Pins()[table]
+	return version, ok
+}
@@ -0,0 +1,70 @@
+package migrator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+func TestPinVersion(t *testing.T) {
+	m := newTestMigrator(map[string]int{"chat": 3}, &fakeReader{}, &fakeAce{})
+	assert.EqualError(t, m.PinVersion("missing", 0), "table missing has no version")
+	assert.EqualError(t, m.PinVersion("chat", 4), "table chat can only be pinned to versions 0 to 3, not 4")
+	assert.EqualError(t, m.PinVersion("chat", -1), "table chat can only be pinned to versions 0 to 3, not -1")
+
+	assert.NoError(t, m.PinVersion("chat", 2))
+	assert.Equal(t, map[string]int{"chat": 2}, m.Pins())
+	version, _ := m.versions.Get("chat")
+	assert.Equal(t, 2, version, "loads see the pinned version")
+
+	assert.True(t, m.UnpinVersion("chat"))
+	assert.False(t, m.UnpinVersion("chat"))
+	assert.Empty(t, m.Pins())
+}
+
+func TestPinBlocksMigrations(t *testing.T) {
+	m := newTestMigrator(map[string]int{"chat": 3}, &fakeReader{}, &fakeAce{})
+	assert.NoError(t, m.PinVersion("chat", 3))
+
+	m.migrateOutdated("chat")
+	waits := m.Waits(0)
+	if assert.Len(t, waits, 1) {
+		assert.Equal(t, WaitPinned, waits[0].State)
+		assert.Equal(t, 4, waits[0].Version)
+	}
+	assert.Empty(t, m.attempts, "a pinned table isn't attempted")
+
+	assert.EqualError(t, m.migrateNow("chat", 4), "table chat is pinned to version 3; unpin it first")
+	assert.EqualError(t, m.migrateNow("chat", 5), "table chat can only be migrated to version 4, not 5")
+}
+
+func TestPinBlocksDropsAndRenames(t *testing.T) {
+	meta := &fakeReader{
+		dropped: []metadata.DroppedTable{{Table: "gone", Version: 3}},
+		renamed: []metadata.RenamedTable{{Table: "old_chat", NewName: "chat", Version: 2}},
+	}
+	ace := &fakeAce{}
+	m := newTestMigrator(map[string]int{"gone": 3, "old_chat": 1}, meta, ace)
+	assert.NoError(t, m.PinVersion("gone", 2))
+	assert.NoError(t, m.PinVersion("old_chat", 1))
+	queued := map[string]int{"gone": 4, "old_chat": 1}
+
+	dropped, err := m.handleDroppedTables(queued)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"gone": true}, dropped, "a pinned dropped table still isn't migrated")
+	renamed, err := m.handleRenamedTables(queued)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"old_chat": true}, renamed)
+
+	assert.Empty(t, ace.dropped)
+	assert.Empty(t, meta.drained)
+	assert.Empty(t, meta.moved)
+	assert.Equal(t, map[string]int{"gone": 2, "old_chat": 1}, m.Pins(), "the pins are kept")
+
+	// A rename of a pinned table waits for it to be unpinned
+	assert.NoError(t, m.renameTable("old_chat", "chat", 2, nil))
+	assert.Equal(t, []TableWait{{Table: "chat", Version: 2, State: WaitPinned, Since: m.waits["chat"].Since}}, m.Waits(0))
+	_, cached := m.versions.Get("chat")
+	assert.False(t, cached)
+}
@@ -0,0 +1,177 @@
+package migrator
+
+import (
+	"sort"
+	"time"
+
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/lib"
+)
+
+// Outcomes of a migration attempt.
+const (
+	// AttemptMigrated means the table was migrated, or created, to the version
+	AttemptMigrated = "migrated"
+	// AttemptWaiting means the migration is waiting for offpeak, the processor or old TSVs to clear
+	AttemptWaiting = "waiting"
+	// AttemptFailed means the migration failed with Error
+	AttemptFailed = "failed"
+)
+
+// State is what the migrator is doing, as reported by the control API.
+type State struct {
+	Offpeak bool
+	// OffpeakStartHour and OffpeakDurationHours are today's offpeak window, in UTC
+	OffpeakStartHour     int
+	OffpeakDurationHours int
+	OffpeakSchedule      lib.OffpeakSchedule
+	LastActive           time.Time
+	// LastPoll is when the migrator last looked for outdated tables; nil before its first poll
+	LastPoll       *time.Time `json:",omitempty"`
+	PendingTables  []PendingTable
+	ProcessorWaits []ProcessorWait
+	// Attempts are the last migration attempt of each table, polled or requested
+	Attempts []Attempt
+	// TypeChanges are the migrations copying their tables to change column types
+	TypeChanges []backend.TypeChangeProgress
+}
+
+// PendingTable is a table with queued TSVs of a newer version than its own, as of the last poll.
+// CurrentVersion is nil if the table doesn't exist yet.
+type PendingTable struct {
+	Table          string
+	CurrentVersion *int `json:",omitempty"`
+	// WaitingSince is the first poll that found the table outdated since it was last migrated; nil
+	// once it's migrated
+	WaitingSince *time.Time `json:",omitempty"`
+}
+
+// ProcessorWait is a migration waiting for the processor to finish the table's old version.
+type ProcessorWait struct {
+	Table   string
+	Version int
+	Started time.Time
+	Until   time.Time
+}
+
+// Attempt is an attempt to migrate a table to a version, and its outcome.
+type Attempt struct {
+	Table     string
+	Version   int
+	Requested bool
+	Attempted time.Time
+	Outcome   string
+	Error     string `json:",omitempty"`
+}
+
+// recordPoll records the tables the last poll found outdated.
+func (m *Migrator) recordPoll(tables []string) {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	now := time.Now()
+	m.lastPoll = &now
+	m.pendingTables = tables
+	pending := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		pending[table] = true
+		if _, waiting := m.waitingSince[table]; !waiting {
+			m.waitingSince[table] = now
+		}
+	}
+	for table := range m.waitingSince {
+		if !pending[table] {
+			delete(m.waitingSince, table)
+		}
+	}
+	for table := range m.waits {
+		if !pending[table] {
+			delete(m.waits, table)
+		}
+	}
+}
+
+// recordAttempt records an attempt to migrate the table to the version, which failed with err if
+// not nil, and otherwise succeeded if the table is now at the version.
+func (m *Migrator) recordAttempt(table string, to int, requested bool, err error) {
+	attempt := Attempt{Table: table, Version: to, Requested: requested, Attempted: time.Now(), Outcome: AttemptWaiting}
+	if err != nil {
+		attempt.Outcome = AttemptFailed
+		attempt.Error = err.Error()
+	} else if version, exists := m.versions.Unpinned(table); exists && version >= to {
+		attempt.Outcome = AttemptMigrated
+	}
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	m.attempts[table] = attempt
+	switch {
+	case attempt.Outcome == AttemptMigrated:
+		m.lastProgress[table] = attempt.Attempted
+		// The next poll starts the wait for the table's next version, if it has one
+		delete(m.waitingSince, table)
+		delete(m.waits, table)
+	case attempt.Outcome == AttemptFailed && !requested:
+		// Requested migrations fail rather than wait, so don't say why a polled one waits
+		if wait, ok := m.waits[table]; !ok || wait.State != WaitFailing || wait.Version != to {
+			m.waits[table] = TableWait{Table: table, Version: to, State: WaitFailing, Since: attempt.Attempted}
+		}
+	}
+}
+
+// State returns what the migrator is doing: the tables it's found outdated, the migrations
+// waiting for the processor, and the last attempt at migrating each table.
+func (m *Migrator) State() State {
+	schedule := m.OffpeakSchedule()
+	now := time.Now()
+	today := schedule.Window(now.UTC().Weekday())
+	state := State{
+		Offpeak:              schedule.Contains(now),
+		OffpeakStartHour:     today.StartHour,
+		OffpeakDurationHours: today.DurationHours,
+		OffpeakSchedule:      schedule,
+		LastActive:           m.LastActive(),
+		PendingTables:        []PendingTable{},
+		ProcessorWaits:       []ProcessorWait{},
+		Attempts:             []Attempt{},
+		TypeChanges:          m.aceBackend.TypeChangeProgress(),
+	}
+
+	m.stateLock.Lock()
+	state.LastPoll = m.lastPoll
+	for _, table := range m.pendingTables {
+		pending := PendingTable{Table: table}
+		if since, waiting := m.waitingSince[table]; waiting {
+			pending.WaitingSince = &since
+		}
+		if version, exists := m.versions.Unpinned(table); exists {
+			pending.CurrentVersion = &version
+		}
+		state.PendingTables = append(state.PendingTables, pending)
+	}
+	for _, attempt := range m.attempts {
+		state.Attempts = append(state.Attempts, attempt)
+	}
+	m.stateLock.Unlock()
+
+	// Waits for versions the table has since reached are over
+	m.migrationStartedLock.Lock()
+	for tv, started := range m.migrationStarted {
+		if version, exists := m.versions.Unpinned(tv.table); exists && version >= tv.version {
+			continue
+		}
+		state.ProcessorWaits = append(state.ProcessorWaits, ProcessorWait{
+			Table:   tv.table,
+			Version: tv.version,
+			Started: started,
+			Until:   started.Add(m.waitProcessorPeriod),
+		})
+	}
+	m.migrationStartedLock.Unlock()
+
+	sort.Slice(state.PendingTables, func(i, j int) bool { return state.PendingTables[i].Table < state.PendingTables[j].Table })
+	sort.Slice(state.ProcessorWaits, func(i, j int) bool {
+		return state.ProcessorWaits[i].Table < state.ProcessorWaits[j].Table
+	})
+	sort.Slice(state.Attempts, func(i, j int) bool { return state.Attempts[i].Table < state.Attempts[j].Table })
+	sort.Slice(state.TypeChanges, func(i, j int) bool { return state.TypeChanges[i].Table < state.TypeChanges[j].Table })
+	return state
+}
@@ -0,0 +1,100 @@
+package migrator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/rs_ingester/backend"
+)
+
+func TestRecordPoll(t *testing.T) {
+	m := newTestMigrator(nil, &fakeReader{}, &fakeAce{})
+	m.recordPoll([]string{"chat", "video"})
+	assert.NotNil(t, m.lastPoll)
+	assert.Equal(t, []string{"chat", "video"}, m.pendingTables)
+	chatSince := m.waitingSince["chat"]
+	assert.False(t, chatSince.IsZero())
+	assert.Contains(t, m.waitingSince, "video")
+	m.setWait("video", 2, WaitProcessor)
+
+	m.recordPoll([]string{"chat"})
+	assert.Equal(t, chatSince, m.waitingSince["chat"], "a table still pending keeps waiting since its first poll")
+	assert.NotContains(t, m.waitingSince, "video")
+	assert.NotContains(t, m.waits, "video", "a table no longer pending isn't waiting")
+}
+
+func TestRecordAttempt(t *testing.T) {
+	m := newTestMigrator(map[string]int{"chat": 3, "video": 1}, &fakeReader{}, &fakeAce{})
+	m.recordPoll([]string{"chat", "video", "clip"})
+
+	m.recordAttempt("video", 2, false, nil)
+	assert.Equal(t, AttemptWaiting, m.attempts["video"].Outcome)
+	assert.Contains(t, m.waitingSince, "video")
+
+	m.recordAttempt("clip", 1, false, errors.New("permission denied"))
+	assert.Equal(t, AttemptFailed, m.attempts["clip"].Outcome)
+	assert.Equal(t, "permission denied", m.attempts["clip"].Error)
+	wait := m.waits["clip"]
+	assert.Equal(t, TableWait{Table: "clip", Version: 1, State: WaitFailing, Since: m.attempts["clip"].Attempted}, wait)
+	m.recordAttempt("clip", 1, false, errors.New("permission denied"))
+	assert.Equal(t, wait.Since, m.waits["clip"].Since, "a table failing again has been failing since its first failure")
+
+	m.recordAttempt("video", 2, true, errors.New("permission denied"))
+	assert.NotContains(t, m.waits, "video", "a requested migration's failure isn't a wait")
+
+	m.setWait("chat", 3, WaitOffpeak)
+	m.recordAttempt("chat", 3, false, nil)
+	assert.Equal(t, AttemptMigrated, m.attempts["chat"].Outcome)
+	assert.Equal(t, m.attempts["chat"].Attempted, m.lastProgress["chat"])
+	assert.NotContains(t, m.waitingSince, "chat")
+	assert.NotContains(t, m.waits, "chat")
+}
+
+func TestState(t *testing.T) {
+	ace := &fakeAce{typeChanges: []backend.TypeChangeProgress{
+		{Table: "video", Columns: []string{"bitrate"}, BatchesDone: 1, BatchesTotal: 4},
+		{Table: "chat", Columns: []string{"message"}, BatchesTotal: 2},
+	}}
+	m := newTestMigrator(map[string]int{"chat": 3, "video": 1}, &fakeReader{}, ace)
+	m.waitProcessorPeriod = time.Hour
+	state := m.State()
+	assert.Nil(t, state.LastPoll)
+	assert.Equal(t, []PendingTable{}, state.PendingTables)
+	assert.Equal(t, []ProcessorWait{}, state.ProcessorWaits)
+	assert.Equal(t, []Attempt{}, state.Attempts)
+
+	m.recordPoll([]string{"video", "clip"})
+	m.recordAttempt("video", 2, false, nil)
+	m.recordAttempt("chat", 3, true, nil)
+	started := time.Now().Add(-time.Minute)
+	m.migrationStarted[tableVersion{"video", 2}] = started
+	m.migrationStarted[tableVersion{"chat", 3}] = started
+
+	state = m.State()
+	assert.Equal(t, m.lastPoll, state.LastPoll)
+	if assert.Len(t, state.PendingTables, 2) {
+		assert.Equal(t, "clip", state.PendingTables[0].Table)
+		assert.Nil(t, state.PendingTables[0].CurrentVersion, "clip doesn't exist yet")
+		assert.Equal(t, "video", state.PendingTables[1].Table)
+		if assert.NotNil(t, state.PendingTables[1].CurrentVersion) {
+			assert.Equal(t, 1, *state.PendingTables[1].CurrentVersion)
+		}
+		if assert.NotNil(t, state.PendingTables[1].WaitingSince) {
+			assert.Equal(t, m.waitingSince["video"], *state.PendingTables[1].WaitingSince)
+		}
+	}
+	assert.Equal(t, []ProcessorWait{{Table: "video", Version: 2, Started: started, Until: started.Add(time.Hour)}},
+		state.ProcessorWaits, "chat has reached version 3, so isn't waiting for the processor")
+	if assert.Len(t, state.Attempts, 2) {
+		assert.Equal(t, "chat", state.Attempts[0].Table)
+		assert.Equal(t, AttemptMigrated, state.Attempts[0].Outcome)
+		assert.Equal(t, "video", state.Attempts[1].Table)
+		assert.Equal(t, AttemptWaiting, state.Attempts[1].Outcome)
+	}
+	if assert.Len(t, state.TypeChanges, 2) {
+		assert.Equal(t, "chat", state.TypeChanges[0].Table)
+		assert.Equal(t, "video", state.TypeChanges[1].Table)
+	}
+}
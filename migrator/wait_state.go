@@ -0,0 +1,104 @@
+package migrator
+
+import (
+	"sort"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+)
+
+// WaitState is why the migrator hasn't migrated an outdated table yet.
+type WaitState string
+
+// The wait states of an outdated table. A table leaves its state when it's migrated, or when a poll
+// no longer finds it outdated.
+const (
+	// WaitOffpeak means the migration only runs offpeak: it's on-peak and no force load was requested,
+	// or the migration changes a column's type or rebuilds the table
+	WaitOffpeak WaitState = "waiting_for_offpeak"
+	// WaitLocked means it's on-peak and a load holds the table's lock
+	WaitLocked WaitState = "locked"
+	// WaitProcessor means the migrator is giving the processor time to finish the table's old version
+	WaitProcessor WaitState = "waiting_for_processor"
+	// WaitOldVersion means files of the table's old version are still queued; they've been force loaded
+	WaitOldVersion WaitState = "waiting_for_old_version"
+	// WaitLoads means the table is being dropped or renamed once its running loads finish
+	WaitLoads WaitState = "waiting_for_loads"
+	// WaitFailing means the last polled attempt at migrating the table failed
+	WaitFailing WaitState = "failing"
+	// WaitPinned means the table is pinned to a version, so isn't migrated until it's unpinned
+	WaitPinned WaitState = "pinned"
+)
+
+// WaitStates are all the wait states.
+var WaitStates = []WaitState{WaitOffpeak, WaitLocked, WaitProcessor, WaitOldVersion, WaitLoads, WaitFailing,
+	WaitPinned}
+
+// TableWait is the state an outdated table waits to be migrated to a version in, and since when.
+type TableWait struct {
+	Table   string
+	Version int
+	State   WaitState
+	Since   time.Time
+}
+
+// setWait records that the table waits in the state to be migrated to the version, keeping when it
+// entered the state if it was already in it.
+func (m *Migrator) setWait(table string, version int, state WaitState) {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	if wait, ok := m.waits[table]; ok && wait.State == state && wait.Version == version {
+		return
+	}
+	if wait, ok := m.waits[table]; ok {
+		logger.WithField("table", table).WithField("version", version).WithField("from", wait.State).
+			WithField("to", state).WithField("after", time.Since(wait.Since)).Info("Migration changed wait state")
+	}
+	m.waits[table] = TableWait{Table: table, Version: version, State: state, Since: time.Now()}
+}
+
+// Waits returns the tables waiting to be migrated that have been in their state for longer than
+// minAge, by table.
+func (m *Migrator) Waits(minAge time.Duration) []TableWait {
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	waits := []TableWait{}
+	for _, wait := range m.waits {
+		if time.Since(wait.Since) >= minAge {
+			waits = append(waits, wait)
+		}
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i].Table < waits[j].Table })
+	return waits
+}
+
+// gaugeWaits gauges each waiting table's state: 1 in migrator.wait_state labeled with the state, how
+// long it's been in it in migrator.wait_age, and the number of tables in each state in
+// migrator.total.wait_state.<state>. The tables reported last time that have changed state or stopped
+// waiting have their old state zeroed. It returns the waits it reported.
+func (m *Migrator) gaugeWaits(stats monitoring.SafeStatter, reported map[string]TableWait) map[string]TableWait {
+	waits := m.Waits(0)
+	current := make(map[string]TableWait, len(waits))
+	counts := make(map[WaitState]int64, len(WaitStates))
+	for _, wait := range waits {
+		current[wait.Table] = wait
+		counts[wait.State]++
+		lib.TableLabeledGauge(stats, "migrator.wait_state", wait.Table, "state", string(wait.State), 1)
+		lib.TableLabeledGauge(stats, "migrator.wait_age", wait.Table, "state", string(wait.State),
+			int64(time.Since(wait.Since)/time.Second))
+	}
+	// Gauges keep their last value, so states tables left are zeroed
+	for table, wait := range reported {
+		if now, ok := current[table]; ok && now.State == wait.State {
+			continue
+		}
+		lib.TableLabeledGauge(stats, "migrator.wait_state", table, "state", string(wait.State), 0)
+		lib.TableLabeledGauge(stats, "migrator.wait_age", table, "state", string(wait.State), 0)
+	}
+	for _, state := range WaitStates {
+		stats.SafeGauge("migrator.total.wait_state."+string(state), counts[state], 1.0)
+	}
+	return current
+}
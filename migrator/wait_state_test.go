@@ -0,0 +1,77 @@
+package migrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetWait(t *testing.T) {
+	m := newTestMigrator(nil, &fakeReader{}, &fakeAce{})
+	m.setWait("chat", 3, WaitOffpeak)
+	wait := m.waits["chat"]
+	assert.Equal(t, TableWait{Table: "chat", Version: 3, State: WaitOffpeak, Since: wait.Since}, wait)
+	since := time.Now().Add(-time.Hour)
+	wait.Since = since
+	m.waits["chat"] = wait
+
+	m.setWait("chat", 3, WaitOffpeak)
+	assert.Equal(t, since, m.waits["chat"].Since, "a table staying in its state keeps when it entered it")
+
+	m.setWait("chat", 4, WaitOffpeak)
+	assert.Equal(t, 4, m.waits["chat"].Version)
+	assert.True(t, m.waits["chat"].Since.After(since), "waiting for a new version starts a new wait")
+
+	m.waits["chat"] = TableWait{Table: "chat", Version: 4, State: WaitOffpeak, Since: since}
+	m.setWait("chat", 4, WaitLocked)
+	assert.Equal(t, WaitLocked, m.waits["chat"].State)
+	assert.True(t, m.waits["chat"].Since.After(since), "entering a new state starts a new wait")
+}
+
+func TestWaits(t *testing.T) {
+	m := newTestMigrator(nil, &fakeReader{}, &fakeAce{})
+	assert.Equal(t, []TableWait{}, m.Waits(0))
+
+	m.setWait("video", 2, WaitLoads)
+	m.setWait("chat", 3, WaitProcessor)
+	m.waits["clip"] = TableWait{Table: "clip", Version: 1, State: WaitFailing, Since: time.Now().Add(-time.Hour)}
+
+	waits := m.Waits(0)
+	if assert.Len(t, waits, 3) {
+		assert.Equal(t, "chat", waits[0].Table)
+		assert.Equal(t, "clip", waits[1].Table)
+		assert.Equal(t, "video", waits[2].Table)
+	}
+	assert.Equal(t, []TableWait{m.waits["clip"]}, m.Waits(time.Minute), "only waits older than the minimum age")
+}
+
+func TestGaugeWaits(t *testing.T) {
+	m := newTestMigrator(nil, &fakeReader{}, &fakeAce{})
+	stats := newGaugeStatter()
+	m.setWait("chat", 3, WaitOffpeak)
+	m.setWait("video", 2, WaitOffpeak)
+	m.waits["clip"] = TableWait{Table: "clip", Version: 1, State: WaitFailing, Since: time.Now().Add(-time.Hour)}
+
+	reported := m.gaugeWaits(stats, nil)
+	assert.Len(t, reported, 3)
+	assert.Equal(t, int64(1), stats.gauges["migrator.chat.wait_state.waiting_for_offpeak"])
+	assert.Equal(t, int64(1), stats.gauges["migrator.clip.wait_state.failing"])
+	assert.True(t, stats.gauges["migrator.clip.wait_age.failing"] >= 3600)
+	assert.Equal(t, int64(2), stats.gauges["migrator.total.wait_state.waiting_for_offpeak"])
+	assert.Equal(t, int64(1), stats.gauges["migrator.total.wait_state.failing"])
+	assert.Equal(t, int64(0), stats.gauges["migrator.total.wait_state.locked"], "every state's total is gauged")
+
+	// chat moves on to waiting for its lock, and clip stops waiting
+	m.setWait("chat", 3, WaitLocked)
+	delete(m.waits, "clip")
+	reported = m.gaugeWaits(stats, reported)
+	assert.Len(t, reported, 2)
+	assert.Equal(t, int64(0), stats.gauges["migrator.chat.wait_state.waiting_for_offpeak"])
+	assert.Equal(t, int64(1), stats.gauges["migrator.chat.wait_state.locked"])
+	assert.Equal(t, int64(1), stats.gauges["migrator.video.wait_state.waiting_for_offpeak"])
+	assert.Equal(t, int64(0), stats.gauges["migrator.clip.wait_state.failing"])
+	assert.Equal(t, int64(0), stats.gauges["migrator.clip.wait_age.failing"])
+	assert.Equal(t, int64(1), stats.gauges["migrator.total.wait_state.waiting_for_offpeak"])
+	assert.Equal(t, int64(0), stats.gauges["migrator.total.wait_state.failing"])
+}
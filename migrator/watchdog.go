@@ -0,0 +1,123 @@
+package migrator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+)
+
+// StuckTable is a table the migrator has found outdated for longer than the watchdog's threshold
+// without migrating it, e.g. because it's waiting on the processor, a lock or a failing migration.
+type StuckTable struct {
+	Table        string
+	WaitingSince time.Time
+	// LastProgress is when the table was last migrated, if it has been since the migrator started
+	LastProgress *time.Time `json:",omitempty"`
+	// LastAttempt is the last attempt at migrating the table, if any
+	LastAttempt *Attempt `json:",omitempty"`
+}
+
+// Liveness is whether the migrator is making progress: when it last polled for outdated tables, and
+// the tables stuck waiting to be migrated.
+type Liveness struct {
+	// LastPoll is when the last poll succeeded; nil before the first one
+	LastPoll *time.Time `json:",omitempty"`
+	Stuck    []StuckTable
+}
+
+// Liveness returns the migrator's progress, with the unpinned tables outdated for longer than threshold.
+func (m *Migrator) Liveness(threshold time.Duration) Liveness {
+	pins := m.versions.Pins()
+	m.stateLock.Lock()
+	defer m.stateLock.Unlock()
+	liveness := Liveness{LastPoll: m.lastPoll, Stuck: []StuckTable{}}
+	for table, since := range m.waitingSince {
+		// Pinned tables are held on purpose
+		if _, pinned := pins[table]; pinned || time.Since(since) <= threshold {
+			continue
+		}
+		stuck := StuckTable{Table: table, WaitingSince: since}
+		if progress, ok := m.lastProgress[table]; ok {
+			stuck.LastProgress = &progress
+		}
+		if attempt, ok := m.attempts[table]; ok {
+			stuck.LastAttempt = &attempt
+		}
+		liveness.Stuck = append(liveness.Stuck, stuck)
+	}
+	sort.Slice(liveness.Stuck, func(i, j int) bool { return liveness.Stuck[i].Table < liveness.Stuck[j].Table })
+	return liveness
+}
+
+// CheckLiveness returns an error if no poll has succeeded within threshold, or some table has been
+// outdated for longer than it.
+func (m *Migrator) CheckLiveness(threshold time.Duration) error {
+	liveness := m.Liveness(threshold)
+	lastPoll := m.created
+	if liveness.LastPoll != nil {
+		lastPoll = *liveness.LastPoll
+	}
+	if time.Since(lastPoll) > threshold {
+		return fmt.Errorf("migrator hasn't polled successfully since %v", lastPoll)
+	}
+	if len(liveness.Stuck) > 0 {
+		tables := make([]string, len(liveness.Stuck))
+		for i, stuck := range liveness.Stuck {
+			tables[i] = stuck.Table
+		}
+		return fmt.Errorf("migrations stuck for longer than %v: %s", threshold, strings.Join(tables, ", "))
+	}
+	return nil
+}
+
+// Watch gauges the migrator's liveness every interval until it's closed: how long since its last
+// successful poll in migrator.poll_age, how many tables have been outdated for longer than threshold
+// in migrator.stuck_tables, and how long each of those has waited in migrator.<table>.stuck_age, along
+// with the wait state of each outdated table (see gaugeWaits). Tables are logged when they get stuck.
+func (m *Migrator) Watch(threshold, interval time.Duration, stats monitoring.SafeStatter) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	logged := make(map[string]time.Time)
+	var waits map[string]TableWait
+	for {
+		select {
+		case <-tick.C:
+		case <-m.watchdogClose:
+			return
+		}
+		liveness := m.Liveness(threshold)
+		lastPoll := m.created
+		if liveness.LastPoll != nil {
+			lastPoll = *liveness.LastPoll
+		}
+		stats.SafeGauge("migrator.poll_age", int64(time.Since(lastPoll)/time.Second), 1.0)
+		stats.SafeGauge("migrator.stuck_tables", int64(len(liveness.Stuck)), 1.0)
+
+		stuck := make(map[string]time.Time, len(liveness.Stuck))
+		for _, table := range liveness.Stuck {
+			stuck[table.Table] = table.WaitingSince
+			lib.TableGauge(stats, "migrator.stuck_age", table.Table, int64(time.Since(table.WaitingSince)/time.Second))
+			if logged[table.Table] != table.WaitingSince {
+				entry := logger.WithField("table", table.Table).WithField("waitingSince", table.WaitingSince)
+				if table.LastAttempt != nil {
+					entry = entry.WithField("lastOutcome", table.LastAttempt.Outcome).
+						WithField("lastError", table.LastAttempt.Error)
+				}
+				entry.Warn("Migration of table is stuck")
+			}
+		}
+		// Gauges keep their last value, so unstuck tables are zeroed
+		for table := range logged {
+			if _, ok := stuck[table]; !ok {
+				lib.TableGauge(stats, "migrator.stuck_age", table, 0)
+			}
+		}
+		logged = stuck
+		waits = m.gaugeWaits(stats, waits)
+	}
+}
@@ -0,0 +1,126 @@
+package migrator
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+// gaugeStatter records the last value of each gauge.
+type gaugeStatter struct {
+	monitoring.SafeStatter
+	lock   sync.Mutex
+	gauges map[string]int64
+}
+
+func newGaugeStatter() *gaugeStatter {
+	return &gaugeStatter{SafeStatter: monitoring.NewMockStatter(), gauges: make(map[string]int64)}
+}
+
+func (s *gaugeStatter) SafeGauge(stat string, value int64, rate float32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gauges[stat] = value
+}
+
+func (s *gaugeStatter) gauge(stat string) (int64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	value, ok := s.gauges[stat]
+	return value, ok
+}
+
+// newStuckMigrator returns a migrator that last polled now, finding chat outdated for two hours after
+// a failed attempt, video for a minute and clip, which is pinned, for two hours.
+func newStuckMigrator() *Migrator {
+	m := newTestMigrator(map[string]int{"chat": 2, "video": 1, "clip": 1}, &fakeReader{}, &fakeAce{})
+	m.versions.Pin("clip", 1)
+	m.recordPoll([]string{"chat", "video", "clip"})
+	m.recordAttempt("chat", 3, false, errors.New("permission denied"))
+	m.waitingSince["chat"] = time.Now().Add(-2 * time.Hour)
+	m.waitingSince["video"] = time.Now().Add(-time.Minute)
+	m.waitingSince["clip"] = time.Now().Add(-2 * time.Hour)
+	return m
+}
+
+func TestLiveness(t *testing.T) {
+	m := newStuckMigrator()
+	m.lastProgress["chat"] = time.Now().Add(-3 * time.Hour)
+	liveness := m.Liveness(time.Hour)
+	assert.Equal(t, m.lastPoll, liveness.LastPoll)
+	if assert.Len(t, liveness.Stuck, 1, "video hasn't waited long, and clip is pinned") {
+		stuck := liveness.Stuck[0]
+		assert.Equal(t, "chat", stuck.Table)
+		assert.Equal(t, m.waitingSince["chat"], stuck.WaitingSince)
+		if assert.NotNil(t, stuck.LastProgress) {
+			assert.Equal(t, m.lastProgress["chat"], *stuck.LastProgress)
+		}
+		if assert.NotNil(t, stuck.LastAttempt) {
+			assert.Equal(t, AttemptFailed, stuck.LastAttempt.Outcome)
+		}
+	}
+
+	liveness = m.Liveness(30 * time.Second)
+	if assert.Len(t, liveness.Stuck, 2) {
+		assert.Equal(t, "chat", liveness.Stuck[0].Table)
+		assert.Equal(t, "video", liveness.Stuck[1].Table)
+		assert.Nil(t, liveness.Stuck[1].LastProgress)
+		assert.Nil(t, liveness.Stuck[1].LastAttempt)
+	}
+}
+
+func TestCheckLiveness(t *testing.T) {
+	m := newTestMigrator(nil, &fakeReader{}, &fakeAce{})
+	assert.NoError(t, m.CheckLiveness(time.Hour), "a new migrator has until the threshold to poll")
+	m.created = time.Now().Add(-2 * time.Hour)
+	assert.Contains(t, m.CheckLiveness(time.Hour).Error(), "migrator hasn't polled successfully since")
+
+	m = newStuckMigrator()
+	assert.EqualError(t, m.CheckLiveness(30*time.Second), "migrations stuck for longer than 30s: chat, video")
+	m.recordPoll(nil)
+	assert.NoError(t, m.CheckLiveness(30*time.Second))
+}
+
+func TestWatch(t *testing.T) {
+	m := newStuckMigrator()
+	m.watchdogClose = make(chan bool)
+	stats := newGaugeStatter()
+	done := make(chan struct{})
+	go func() {
+		m.Watch(time.Hour, time.Millisecond, stats)
+		close(done)
+	}()
+	waitForGauge := func(stat string, want int64) {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if value, ok := stats.gauge(stat); ok && value == want {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		value, _ := stats.gauge(stat)
+		assert.Equal(t, want, value, stat)
+	}
+	waitForGauge("migrator.stuck_tables", 1)
+	age, _ := stats.gauge("migrator.chat.stuck_age")
+	assert.True(t, age >= 7200, "chat has been stuck for two hours")
+	_, ok := stats.gauge("migrator.video.stuck_age")
+	assert.False(t, ok)
+	poll, _ := stats.gauge("migrator.poll_age")
+	assert.True(t, poll < 60)
+
+	m.recordPoll([]string{"video", "clip"})
+	waitForGauge("migrator.stuck_tables", 0)
+	waitForGauge("migrator.chat.stuck_age", 0)
+
+	close(m.watchdogClose)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch didn't return once the migrator was closed")
+	}
+}
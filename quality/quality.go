@@ -0,0 +1,209 @@
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stvp/rollbar"
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+)
+
+// Kind is the kind of a data-quality anomaly.
+type Kind string
+
+// The kinds of anomalies.
+const (
+	// RowMismatch is a load whose rows loaded didn't match the rows expected or the lines read; its
+	// count is the rows missing, or extra
+	RowMismatch Kind = "row_mismatch"
+	// Straggler is files of an outdated version of a table, loaded as its straggler rule says; its
+	// count is the files
+	Straggler Kind = "straggler"
+)
+
+// Anomaly is an occurrence of a data-quality problem in a table.
+type Anomaly struct {
+	Table string
+	Kind  Kind
+	// Count is how much the anomaly is, e.g. the rows missing from a load
+	Count int64
+	// Detail describes the occurrence, e.g. the load's UUID
+	Detail string
+}
+
+// Item is a table's anomalies of a kind aggregated since they were last reported.
+type Item struct {
+	Table       string
+	Kind        Kind
+	Occurrences int
+	Count       int64
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	// LastDetail is the detail of the latest occurrence
+	LastDetail string
+}
+
+// Title is the item's title, the same for every item of its table and kind.
+func (i Item) Title() string {
+	return fmt.Sprintf("Data quality: %s in %s", i.Kind, i.Table)
+}
+
+// Fingerprint groups the items of a table and kind into one Rollbar item, whatever their stack.
+func (i Item) Fingerprint() string {
+	return fmt.Sprintf("data_quality:%s:%s", i.Kind, i.Table)
+}
+
+// Notifier sends reported items on, e.g. to Rollbar.
+type Notifier interface {
+	Notify(item Item)
+}
+
+// RollbarNotifier sends items to Rollbar as warnings, with the token and environment the logger was
+// initialized with.
+type RollbarNotifier struct{}
+
+// Notify sends the item to Rollbar.
+func (RollbarNotifier) Notify(item Item) {
+	rollbar.Error(rollbar.WARN, errors.New(item.Title()),
+		&rollbar.Field{Name: "fingerprint", Data: item.Fingerprint()},
+		&rollbar.Field{Name: "custom", Data: map[string]interface{}{
+			"table":       item.Table,
+			"kind":        string(item.Kind),
+			"occurrences": item.Occurrences,
+			"count":       item.Count,
+			"first_seen":  item.FirstSeen,
+			"last_seen":   item.LastSeen,
+			"last_detail": item.LastDetail,
+		}})
+}
+
+// Config configures a Reporter.
+type Config struct {
+	// Period is how often aggregated anomalies are reported
+	Period time.Duration
+	// Quiet is how long after an item of a table and kind its next anomalies are held, so a table
+	// with an ongoing problem is reported once per Quiet rather than once per load
+	Quiet time.Duration
+	// MaxItems is the most items reported per period; the rest wait for the next. 0 is unlimited
+	MaxItems int
+}
+
+type key struct {
+	table string
+	kind  Kind
+}
+
+// Reporter aggregates data-quality anomalies by table and kind, and reports them to a Notifier every
+// period, deduplicated and rate limited, instead of each one being logged. Anomalies are counted in
+// quality.<table>.<kind> as they happen, and reported items in quality.total.reported.
+type Reporter struct {
+	notifier Notifier
+	stats    monitoring.SafeStatter
+	config   Config
+
+	lock         sync.Mutex
+	pending      map[key]*Item
+	lastReported map[key]time.Time
+	closer       chan bool
+	closed       chan bool
+}
+
+// New returns a Reporter and starts its loop.
+func New(notifier Notifier, stats monitoring.SafeStatter, config Config) *Reporter {
+	r := newReporter(notifier, stats, config)
+	logger.Go(r.loop)
+	return r
+}
+
+func newReporter(notifier Notifier, stats monitoring.SafeStatter, config Config) *Reporter {
+	return &Reporter{
+		notifier:     notifier,
+		stats:        stats,
+		config:       config,
+		pending:      make(map[key]*Item),
+		lastReported: make(map[key]time.Time),
+		closer:       make(chan bool),
+		closed:       make(chan bool),
+	}
+}
+
+func (r *Reporter) loop() {
+	defer close(r.closed)
+	tick := time.NewTicker(r.config.Period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			r.flush(time.Now().In(time.UTC))
+		case <-r.closer:
+			return
+		}
+	}
+}
+
+// Report adds the anomaly to its table and kind's next item.
+func (r *Reporter) Report(anomaly Anomaly) {
+	lib.TableInc(r.stats, "quality."+string(anomaly.Kind), anomaly.Table, 1)
+	now := time.Now().In(time.UTC)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	k := key{table: anomaly.Table, kind: anomaly.Kind}
+	item, ok := r.pending[k]
+	if !ok {
+		item = &Item{Table: anomaly.Table, Kind: anomaly.Kind, FirstSeen: now}
+		r.pending[k] = item
+	}
+	item.Occurrences++
+	item.Count += anomaly.Count
+	item.LastSeen = now
+	item.LastDetail = anomaly.Detail
+}
+
+// flush reports the pending items outside their quiet period, oldest first, up to MaxItems.
+func (r *Reporter) flush(now time.Time) {
+	r.lock.Lock()
+	var due []*Item
+	for k, item := range r.pending {
+		if last, ok := r.lastReported[k]; ok && now.Sub(last) < r.config.Quiet {
+			continue
+		}
+		due = append(due, item)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].FirstSeen.Equal(due[j].FirstSeen) {
+			return due[i].FirstSeen.Before(due[j].FirstSeen)
+		}
+		return due[i].Fingerprint() < due[j].Fingerprint()
+	})
+	if r.config.MaxItems > 0 && len(due) > r.config.MaxItems {
+		r.stats.SafeInc("quality.total.rate_limited", int64(len(due)-r.config.MaxItems), 1.0)
+		due = due[:r.config.MaxItems]
+	}
+	for _, item := range due {
+		k := key{table: item.Table, kind: item.Kind}
+		delete(r.pending, k)
+		r.lastReported[k] = now
+	}
+	r.lock.Unlock()
+
+	for _, item := range due {
+		r.notifier.Notify(*item)
+		logger.WithField("table", item.Table).WithField("kind", item.Kind).
+			WithField("occurrences", item.Occurrences).WithField("count", item.Count).
+			WithField("lastDetail", item.LastDetail).Info("Reported data-quality anomalies")
+	}
+	r.stats.SafeInc("quality.total.reported", int64(len(due)), 1.0)
+}
+
+// Close stops the reporter, reporting the pending items first regardless of their quiet periods.
+func (r *Reporter) Close() {
+	close(r.closer)
+	<-r.closed
+	r.config.Quiet, r.config.MaxItems = 0, 0
+	r.flush(time.Now().In(time.UTC))
+}
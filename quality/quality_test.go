@@ -0,0 +1,54 @@
+package quality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+type fakeNotifier struct {
+	items []Item
+}
+
+func (n *fakeNotifier) Notify(item Item) {
+	n.items = append(n.items, item)
+}
+
+func TestFlush(t *testing.T) {
+	notifier := &fakeNotifier{}
+	r := newReporter(notifier, monitoring.NewMockStatter(), Config{Quiet: time.Hour, MaxItems: 2})
+	r.Report(Anomaly{Table: "booking", Kind: RowMismatch, Count: 3, Detail: "load 1"})
+	r.Report(Anomaly{Table: "booking", Kind: RowMismatch, Count: 2, Detail: "load 2"})
+	r.Report(Anomaly{Table: "booking", Kind: Straggler, Count: 10})
+	r.Report(Anomaly{Table: "search", Kind: Straggler, Count: 4})
+
+	now := time.Now().In(time.UTC)
+	r.flush(now)
+	if assert.Len(t, notifier.items, 2, "rate limited") {
+		item := notifier.items[0]
+		assert.Equal(t, "booking", item.Table)
+		assert.Equal(t, RowMismatch, item.Kind)
+		assert.Equal(t, 2, item.Occurrences)
+		assert.Equal(t, int64(5), item.Count)
+		assert.Equal(t, "load 2", item.LastDetail)
+		assert.Equal(t, "data_quality:row_mismatch:booking", item.Fingerprint())
+		assert.Equal(t, Straggler, notifier.items[1].Kind)
+		assert.Equal(t, "booking", notifier.items[1].Table)
+	}
+
+	notifier.items = nil
+	r.Report(Anomaly{Table: "booking", Kind: RowMismatch, Count: 1})
+	r.flush(now.Add(time.Minute))
+	if assert.Len(t, notifier.items, 1, "the rate limited item is reported next, but not the quiet one") {
+		assert.Equal(t, Straggler, notifier.items[0].Kind)
+	}
+
+	notifier.items = nil
+	r.flush(now.Add(time.Hour))
+	if assert.Len(t, notifier.items, 1) {
+		assert.Equal(t, RowMismatch, notifier.items[0].Kind)
+		assert.Equal(t, 1, notifier.items[0].Occurrences, "reported anomalies aren't reported again")
+	}
+}
@@ -0,0 +1,135 @@
+package redshift
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+// ErrCircuitOpen is returned instead of running a request while Redshift is considered down.
+var ErrCircuitOpen = errors.New("redshift circuit breaker is open")
+
+// BreakerConfig configures when the circuit breaker opens and how it probes to close again.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive connection failures that opens the breaker.
+	// Zero disables the breaker.
+	FailureThreshold int
+	// ProbePeriod is how often Redshift is pinged while the breaker is open.
+	ProbePeriod time.Duration
+}
+
+// CircuitBreaker stops requests to Redshift after consecutive connection failures, and pings it
+// until it's reachable again.
+type CircuitBreaker struct {
+	cfg                 BreakerConfig
+	ping                func() error
+	stats               monitoring.SafeStatter
+	lock                sync.Mutex
+	consecutiveFailures int
+	// closed is closed while the breaker is closed; waiters block on it while open.
+	closed chan struct{}
+	open   bool
+}
+
+func newCircuitBreaker(cfg BreakerConfig, ping func() error, stats monitoring.SafeStatter) *CircuitBreaker {
+	closed := make(chan struct{})
+	close(closed)
+	return &CircuitBreaker{
+		cfg:    cfg,
+		ping:   ping,
+		stats:  stats,
+		closed: closed,
+	}
+}
+
+// Allow returns ErrCircuitOpen if requests shouldn't be sent to Redshift.
+func (b *CircuitBreaker) Allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.open {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// Wait blocks until the breaker is closed.
+func (b *CircuitBreaker) Wait() {
+	b.lock.Lock()
+	closed := b.closed
+	b.lock.Unlock()
+	<-closed
+}
+
+// Record counts the outcome of a request, opening the breaker if there have been too many
+// consecutive connection failures.
+func (b *CircuitBreaker) Record(err error) {
+	if b.cfg.FailureThreshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !isConnectionError(err) {
+		b.consecutiveFailures = 0
+		return
+	}
+	b.consecutiveFailures++
+	if b.open || b.consecutiveFailures < b.cfg.FailureThreshold {
+		return
+	}
+
+	b.open = true
+	b.closed = make(chan struct{})
+	logger.WithError(err).WithField("consecutiveFailures", b.consecutiveFailures).
+		Error("Redshift circuit breaker opened; pausing loads")
+	b.stats.SafeInc("redshift.circuit_breaker.opened", 1, 1.0)
+	b.stats.SafeGauge("redshift.circuit_breaker.open", 1, 1.0)
+	logger.Go(b.probe)
+}
+
+// probe pings Redshift until it responds, then closes the breaker.
+func (b *CircuitBreaker) probe() {
+	tick := time.NewTicker(b.cfg.ProbePeriod)
+	defer tick.Stop()
+	for range tick.C {
+		err := b.ping()
+		if err != nil {
+			logger.WithError(err).Warn("Redshift circuit breaker probe failed")
+			continue
+		}
+		b.lock.Lock()
+		b.open = false
+		b.consecutiveFailures = 0
+		close(b.closed)
+		b.lock.Unlock()
+		logger.Info("Redshift circuit breaker closed; resuming loads")
+		b.stats.SafeInc("redshift.circuit_breaker.closed", 1, 1.0)
+		b.stats.SafeGauge("redshift.circuit_breaker.open", 0, 1.0)
+		return
+	}
+}
+
+// isConnectionError returns whether err means Redshift couldn't be reached, as opposed to
+// a request failing on its own.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "driver: bad connection") ||
+		strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "connection reset by peer") ||
+		strings.Contains(msg, "broken pipe")
+}
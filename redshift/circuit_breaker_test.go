@@ -0,0 +1,40 @@
+package redshift
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+func TestCircuitBreakerOpensAndCloses(t *testing.T) {
+	pings := make(chan error, 2)
+	pings <- errors.New("still down")
+	pings <- nil
+	b := newCircuitBreaker(BreakerConfig{FailureThreshold: 2, ProbePeriod: time.Millisecond},
+		func() error { return <-pings }, monitoring.NewMockStatter())
+
+	b.Record(driver.ErrBadConn)
+	assert.Nil(t, b.Allow(), "breaker should stay closed under the threshold")
+	b.Record(errors.New("syntax error"))
+	b.Record(driver.ErrBadConn)
+	assert.Nil(t, b.Allow(), "non-connection errors should reset the failure count")
+
+	b.Record(driver.ErrBadConn)
+	assert.Equal(t, ErrCircuitOpen, b.Allow())
+
+	done := make(chan struct{})
+	go func() {
+		b.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("breaker didn't close after a successful probe")
+	}
+	assert.Nil(t, b.Allow())
+}
@@ -0,0 +1,29 @@
+package redshift
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+)
+
+// assumeRoleSessionName names the sessions of the roles COPYs assume, for the role's account's CloudTrail.
+const assumeRoleSessionName = "rs_ingester"
+
+// AssumeRoleCredentials returns credentials of the role, e.g. one of another account allowed to read its
+// buckets, assumed with the external ID if it's set, for COPYs to pass to Redshift. They last for the
+// duration, and are refreshed once half of it is up, so a COPY always starts with credentials valid
+// for at least the other half.
+func AssumeRoleCredentials(p client.ConfigProvider, roleARN, externalID string,
+	duration time.Duration) *credentials.Credentials {
+	return stscreds.NewCredentials(p, roleARN, func(provider *stscreds.AssumeRoleProvider) {
+		provider.RoleSessionName = assumeRoleSessionName
+		provider.Duration = duration
+		provider.ExpiryWindow = duration / 2
+		if externalID != "" {
+			provider.ExternalID = aws.String(externalID)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package redshift
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// dataAPIServiceName is the Redshift Data API's endpoint prefix and signing name.
+const dataAPIServiceName = "redshift-data"
+
+// dataAPIClient calls the Redshift Data API, which speaks the AWS JSON 1.1 protocol. The vendored
+// SDK predates the service, so this covers the few operations the dataAPIDriver needs.
+type dataAPIClient struct {
+	*client.Client
+}
+
+func newDataAPIClient(p client.ConfigProvider, cfgs ...*aws.Config) *dataAPIClient {
+	c := p.ClientConfig(dataAPIServiceName, cfgs...)
+	svc := &dataAPIClient{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   dataAPIServiceName,
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2019-12-20",
+				JSONVersion:   "1.1",
+				TargetPrefix:  "RedshiftData",
+			},
+			c.Handlers,
+		),
+	}
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(request.NamedHandler{Name: "redshift.dataAPIBuild", Fn: buildDataAPIRequest})
+	svc.Handlers.Unmarshal.PushBackNamed(request.NamedHandler{Name: "redshift.dataAPIUnmarshal", Fn: unmarshalDataAPIResponse})
+	svc.Handlers.UnmarshalError.PushBackNamed(request.NamedHandler{Name: "redshift.dataAPIUnmarshalError", Fn: unmarshalDataAPIError})
+	return svc
+}
+
+func buildDataAPIRequest(r *request.Request) {
+	body, err := json.Marshal(r.Params)
+	if err != nil {
+		r.Error = awserr.New("SerializationError", "failed encoding Data API request", err)
+		return
+	}
+	r.SetBufferBody(body)
+	r.HTTPRequest.Header.Set("X-Amz-Target", r.ClientInfo.TargetPrefix+"."+r.Operation.Name)
+	r.HTTPRequest.Header.Set("Content-Type", "application/x-amz-json-"+r.ClientInfo.JSONVersion)
+}
+
+func unmarshalDataAPIResponse(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+	err := json.NewDecoder(r.HTTPResponse.Body).Decode(r.Data)
+	if err != nil {
+		r.Error = awserr.New("SerializationError", "failed decoding Data API response", err)
+	}
+}
+
+func unmarshalDataAPIError(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+	body, err := ioutil.ReadAll(r.HTTPResponse.Body)
+	if err != nil {
+		r.Error = awserr.New("SerializationError", "failed reading Data API error", err)
+		return
+	}
+	var resp struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		r.Error = awserr.NewRequestFailure(awserr.New("SerializationError", string(body), err),
+			r.HTTPResponse.StatusCode, r.RequestID)
+		return
+	}
+	// The type may be namespaced, e.g. "com.amazonaws.redshiftdata#ValidationException"
+	code := resp.Type[strings.LastIndex(resp.Type, "#")+1:]
+	r.Error = awserr.NewRequestFailure(awserr.New(code, resp.Message, nil), r.HTTPResponse.StatusCode, r.RequestID)
+}
+
+// call calls the operation, canceling the request if ctx is done first.
+func (c *dataAPIClient) call(ctx context.Context, operation string, input, output interface{}) error {
+	op := &request.Operation{Name: operation, HTTPMethod: "POST", HTTPPath: "/"}
+	req := c.NewRequest(op, input, output)
+	req.HTTPRequest = req.HTTPRequest.WithContext(ctx)
+	return req.Send()
+}
+
+type dataAPIParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type executeStatementInput struct {
+	Sql                     string             `json:"Sql"`
+	Database                string             `json:"Database,omitempty"`
+	WorkgroupName           string             `json:"WorkgroupName,omitempty"`
+	ClusterIdentifier       string             `json:"ClusterIdentifier,omitempty"`
+	DbUser                  string             `json:"DbUser,omitempty"`
+	SecretArn               string             `json:"SecretArn,omitempty"`
+	Parameters              []dataAPIParameter `json:"Parameters,omitempty"`
+	SessionID               string             `json:"SessionId,omitempty"`
+	SessionKeepAliveSeconds int                `json:"SessionKeepAliveSeconds,omitempty"`
+}
+
+type executeStatementOutput struct {
+	ID        string `json:"Id"`
+	SessionID string `json:"SessionId"`
+}
+
+func (c *dataAPIClient) executeStatement(ctx context.Context, input *executeStatementInput) (*executeStatementOutput, error) {
+	output := &executeStatementOutput{}
+	return output, c.call(ctx, "ExecuteStatement", input, output)
+}
+
+// Statuses of a Data API statement that are final.
+const (
+	statementFinished = "FINISHED"
+	statementFailed   = "FAILED"
+	statementAborted  = "ABORTED"
+)
+
+type statementInput struct {
+	ID        string `json:"Id"`
+	NextToken string `json:"NextToken,omitempty"`
+}
+
+type describeStatementOutput struct {
+	Status       string
+	Error        string
+	HasResultSet bool
+	ResultRows   int64
+}
+
+func (c *dataAPIClient) describeStatement(ctx context.Context, id string) (*describeStatementOutput, error) {
+	output := &describeStatementOutput{}
+	return output, c.call(ctx, "DescribeStatement", &statementInput{ID: id}, output)
+}
+
+type cancelStatementOutput struct {
+	Status bool
+}
+
+func (c *dataAPIClient) cancelStatement(ctx context.Context, id string) error {
+	return c.call(ctx, "CancelStatement", &statementInput{ID: id}, &cancelStatementOutput{})
+}
+
+type dataAPIField struct {
+	IsNull       *bool    `json:"isNull"`
+	BooleanValue *bool    `json:"booleanValue"`
+	LongValue    *int64   `json:"longValue"`
+	DoubleValue  *float64 `json:"doubleValue"`
+	StringValue  *string  `json:"stringValue"`
+	BlobValue    []byte   `json:"blobValue"`
+}
+
+type dataAPIColumn struct {
+	Name     string `json:"name"`
+	TypeName string `json:"typeName"`
+}
+
+type getStatementResultOutput struct {
+	Records        [][]dataAPIField
+	ColumnMetadata []dataAPIColumn
+	NextToken      string
+}
+
+func (c *dataAPIClient) getStatementResult(ctx context.Context, id, nextToken string) (*getStatementResultOutput, error) {
+	output := &getStatementResultOutput{}
+	return output, c.call(ctx, "GetStatementResult", &statementInput{ID: id, NextToken: nextToken}, output)
+}
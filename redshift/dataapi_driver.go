@@ -0,0 +1,526 @@
+package redshift
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/twitchscience/aws_utils/logger"
+)
+
+// Transports Redshift can be reached over.
+const (
+	// PostgresTransport is a pool of persistent libpq connections
+	PostgresTransport = "postgres"
+	// DataAPITransport is the Redshift Data API over HTTPS, e.g. for Redshift Serverless
+	DataAPITransport = "dataapi"
+)
+
+// dataAPIDriverName is the database/sql driver name of the Data API transport.
+const dataAPIDriverName = "redshift-data"
+
+// defaultSessionKeepAlive is how long a connection's Data API session outlives its last statement.
+const defaultSessionKeepAlive = 3600
+
+// defaultStatementWait is how long, in seconds, a statement is waited for when its context has no
+// deadline. Redshift's statement_timeout normally ends it first.
+const defaultStatementWait = 24 * 3600
+
+// cancelStatementTimeout bounds the call canceling a statement whose context is done.
+const cancelStatementTimeout = 10 * time.Second
+
+// Bounds of the wait between checks of a running statement's status.
+const (
+	minStatementPoll = 10 * time.Millisecond
+	maxStatementPoll = time.Second
+)
+
+// timestampLayouts are the layouts the Data API returns timestamps in.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func init() {
+	sql.Register(dataAPIDriverName, &dataAPIDriver{clients: make(map[string]*dataAPIClient)})
+}
+
+// dataAPIConfig is a Data API DSN, a URL query like
+// "workgroup=ingest&database=dev&region=us-west-2". It names a Serverless workgroup, or a
+// provisioned cluster and db_user, and optionally a secret_arn to authenticate with, the
+// session_keep_alive in seconds, the statement_wait in seconds a statement without a context deadline
+// is waited for, and an endpoint overriding the region's.
+type dataAPIConfig struct {
+	Workgroup        string
+	Cluster          string
+	Database         string
+	DbUser           string
+	SecretArn        string
+	Region           string
+	Endpoint         string
+	SessionKeepAlive int
+	StatementWait    int
+}
+
+func parseDataAPIConfig(dsn string) (*dataAPIConfig, error) {
+	values, err := url.ParseQuery(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Data API DSN: %v", err)
+	}
+	config := &dataAPIConfig{
+		Workgroup:        values.Get("workgroup"),
+		Cluster:          values.Get("cluster"),
+		Database:         values.Get("database"),
+		DbUser:           values.Get("db_user"),
+		SecretArn:        values.Get("secret_arn"),
+		Region:           values.Get("region"),
+		Endpoint:         values.Get("endpoint"),
+		SessionKeepAlive: defaultSessionKeepAlive,
+		StatementWait:    defaultStatementWait,
+	}
+	if keepAlive := values.Get("session_keep_alive"); keepAlive != "" {
+		config.SessionKeepAlive, err = strconv.Atoi(keepAlive)
+		if err != nil {
+			return nil, fmt.Errorf("parsing session_keep_alive: %v", err)
+		}
+	}
+	if wait := values.Get("statement_wait"); wait != "" {
+		config.StatementWait, err = strconv.Atoi(wait)
+		if err != nil || config.StatementWait <= 0 {
+			return nil, fmt.Errorf("statement_wait must be a positive number of seconds, not %q", wait)
+		}
+	}
+	if (config.Workgroup == "") == (config.Cluster == "") {
+		return nil, errors.New("Data API DSN needs one of workgroup or cluster")
+	}
+	if config.Database == "" {
+		return nil, errors.New("Data API DSN needs a database")
+	}
+	return config, nil
+}
+
+// dataAPIDriver is a database/sql driver running statements through the Redshift Data API. Each
+// connection is a Data API session, so transactions and session state like pg_last_copy_id()
+// work as over libpq; the session is started by the connection's first statement and expires
+// SessionKeepAlive seconds after its last one.
+type dataAPIDriver struct {
+	lock    sync.Mutex
+	clients map[string]*dataAPIClient
+}
+
+func (d *dataAPIDriver) Open(dsn string) (driver.Conn, error) {
+	config, err := parseDataAPIConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	client, ok := d.clients[dsn]
+	if !ok {
+		awsConfig := aws.NewConfig()
+		if config.Region != "" {
+			awsConfig = awsConfig.WithRegion(config.Region)
+		}
+		if config.Endpoint != "" {
+			awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+		}
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("creating AWS session for the Data API: %v", err)
+		}
+		client = newDataAPIClient(sess)
+		d.clients[dsn] = client
+	}
+	return &dataAPIConn{client: client, config: config}, nil
+}
+
+type dataAPIConn struct {
+	client    *dataAPIClient
+	config    *dataAPIConfig
+	sessionID string
+	bad       bool
+}
+
+// run runs a statement in the connection's session, waiting for it to finish, or for ctx to be done, or
+// StatementWait if ctx has no deadline. A statement still running then is canceled.
+func (c *dataAPIConn) run(ctx context.Context, query string, args []driver.Value) (string, *describeStatementOutput, error) {
+	if c.bad {
+		return "", nil, driver.ErrBadConn
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(c.config.StatementWait)*time.Second)
+		defer cancel()
+	}
+	query, params, err := bindDataAPIParameters(query, args)
+	if err != nil {
+		return "", nil, err
+	}
+	input := &executeStatementInput{
+		Sql:                     query,
+		Parameters:              params,
+		SessionKeepAliveSeconds: c.config.SessionKeepAlive,
+	}
+	if c.sessionID != "" {
+		input.SessionID = c.sessionID
+	} else {
+		input.Database = c.config.Database
+		input.WorkgroupName = c.config.Workgroup
+		input.ClusterIdentifier = c.config.Cluster
+		input.DbUser = c.config.DbUser
+		input.SecretArn = c.config.SecretArn
+	}
+	executed, err := c.client.executeStatement(ctx, input)
+	if err != nil && ctx.Err() != nil {
+		// The statement may have started without its ID, so the session may be busy with it
+		c.bad = true
+		return "", nil, ctx.Err()
+	}
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && c.sessionID != "" &&
+			strings.Contains(strings.ToLower(aerr.Message()), "session") {
+			// The session expired or was closed, taking any transaction with it
+			c.bad = true
+			return "", nil, driver.ErrBadConn
+		}
+		return "", nil, fmt.Errorf("executing statement through the Data API: %v", err)
+	}
+	if executed.SessionID != "" {
+		c.sessionID = executed.SessionID
+	}
+
+	wait := minStatementPoll
+	for {
+		status, err := c.client.describeStatement(ctx, executed.ID)
+		if err != nil && ctx.Err() != nil {
+			return "", nil, c.cancel(executed.ID, ctx.Err())
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("describing Data API statement: %v", err)
+		}
+		switch status.Status {
+		case statementFinished:
+			return executed.ID, status, nil
+		case statementFailed, statementAborted:
+			return "", nil, fmt.Errorf("%s", status.Error)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", nil, c.cancel(executed.ID, ctx.Err())
+		case <-timer.C:
+		}
+		if wait *= 2; wait > maxStatementPoll {
+			wait = maxStatementPoll
+		}
+	}
+}
+
+// cancel cancels the running statement, returning err. The session may be left running it if it
+// can't be canceled, and a transaction it's in is aborted either way, so the connection isn't reused.
+func (c *dataAPIConn) cancel(id string, err error) error {
+	c.bad = true
+	ctx, cancel := context.WithTimeout(context.Background(), cancelStatementTimeout)
+	defer cancel()
+	if cerr := c.client.cancelStatement(ctx, id); cerr != nil {
+		logger.WithError(cerr).WithField("statement", id).Warn("Error canceling Data API statement")
+	}
+	return err
+}
+
+func (c *dataAPIConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return c.exec(context.Background(), query, args)
+}
+
+func (c *dataAPIConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.exec(ctx, query, namedValues(args))
+}
+
+func (c *dataAPIConn) exec(ctx context.Context, query string, args []driver.Value) (driver.Result, error) {
+	_, status, err := c.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(status.ResultRows), nil
+}
+
+func (c *dataAPIConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.query(context.Background(), query, args)
+}
+
+func (c *dataAPIConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.query(ctx, query, namedValues(args))
+}
+
+func (c *dataAPIConn) query(ctx context.Context, query string, args []driver.Value) (driver.Rows, error) {
+	id, status, err := c.run(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	rows := &dataAPIRows{ctx: ctx, client: c.client, id: id}
+	if !status.HasResultSet {
+		return rows, nil
+	}
+	return rows, rows.fetch("")
+}
+
+// namedValues returns the values of args, which are ordinal since the Data API's parameters are
+// bound from $n placeholders.
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+func (c *dataAPIConn) Ping(ctx context.Context) error {
+	_, _, err := c.run(ctx, "SELECT 1", nil)
+	return err
+}
+
+func (c *dataAPIConn) Prepare(query string) (driver.Stmt, error) {
+	return &dataAPIStmt{conn: c, query: query}, nil
+}
+
+func (c *dataAPIConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx begins a transaction with the default isolation level, Redshift's only one. Statements in
+// it are canceled when their own contexts are done; database/sql rolls it back when ctx is.
+func (c *dataAPIConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errors.New("Data API transactions only have the default isolation level and can't be read-only")
+	}
+	_, err := c.exec(ctx, "BEGIN", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &dataAPITx{conn: c}, nil
+}
+
+// Close leaves the session to expire; the Data API has no call to end one.
+func (c *dataAPIConn) Close() error {
+	return nil
+}
+
+type dataAPITx struct {
+	conn *dataAPIConn
+}
+
+func (t *dataAPITx) Commit() error {
+	_, err := t.conn.Exec("COMMIT", nil)
+	return err
+}
+
+func (t *dataAPITx) Rollback() error {
+	_, err := t.conn.Exec("ROLLBACK", nil)
+	return err
+}
+
+type dataAPIStmt struct {
+	conn  *dataAPIConn
+	query string
+}
+
+func (s *dataAPIStmt) Close() error  { return nil }
+func (s *dataAPIStmt) NumInput() int { return -1 }
+
+func (s *dataAPIStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.Exec(s.query, args)
+}
+
+func (s *dataAPIStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *dataAPIStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.Query(s.query, args)
+}
+
+func (s *dataAPIStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// dataAPIRows reads a statement's result a page at a time, with the context of its query.
+type dataAPIRows struct {
+	ctx       context.Context
+	client    *dataAPIClient
+	id        string
+	columns   []dataAPIColumn
+	records   [][]dataAPIField
+	nextToken string
+}
+
+func (r *dataAPIRows) fetch(nextToken string) error {
+	result, err := r.client.getStatementResult(r.ctx, r.id, nextToken)
+	if err != nil {
+		return fmt.Errorf("getting Data API statement result: %v", err)
+	}
+	if r.columns == nil {
+		r.columns = result.ColumnMetadata
+	}
+	r.records = result.Records
+	r.nextToken = result.NextToken
+	return nil
+}
+
+func (r *dataAPIRows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+func (r *dataAPIRows) Close() error {
+	r.records = nil
+	r.nextToken = ""
+	return nil
+}
+
+func (r *dataAPIRows) Next(dest []driver.Value) error {
+	for len(r.records) == 0 {
+		if r.nextToken == "" {
+			return io.EOF
+		}
+		if err := r.fetch(r.nextToken); err != nil {
+			return err
+		}
+	}
+	record := r.records[0]
+	r.records = r.records[1:]
+	for i := range dest {
+		if i >= len(record) {
+			dest[i] = nil
+			continue
+		}
+		value, err := dataAPIValue(record[i], r.columns[i].TypeName)
+		if err != nil {
+			return fmt.Errorf("reading column %s: %v", r.columns[i].Name, err)
+		}
+		dest[i] = value
+	}
+	return nil
+}
+
+// dataAPIValue converts a field to a driver value; timestamps, which the Data API returns as
+// strings, are parsed so they scan into time.Time.
+func dataAPIValue(field dataAPIField, typeName string) (driver.Value, error) {
+	switch {
+	case field.IsNull != nil && *field.IsNull:
+		return nil, nil
+	case field.BooleanValue != nil:
+		return *field.BooleanValue, nil
+	case field.LongValue != nil:
+		return *field.LongValue, nil
+	case field.DoubleValue != nil:
+		return *field.DoubleValue, nil
+	case field.BlobValue != nil:
+		return field.BlobValue, nil
+	case field.StringValue != nil:
+		switch typeName {
+		case "timestamp", "timestamptz", "date":
+			return parseDataAPITimestamp(*field.StringValue)
+		}
+		return *field.StringValue, nil
+	}
+	return nil, nil
+}
+
+func parseDataAPITimestamp(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timestampLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// bindDataAPIParameters rewrites the query's $n placeholders to the Data API's named :pn ones,
+// returning the parameters. The Data API has no null parameters, so nulls are inlined as NULL.
+// Placeholders in quoted strings and identifiers are left alone.
+func bindDataAPIParameters(query string, args []driver.Value) (string, []dataAPIParameter, error) {
+	if len(args) == 0 {
+		return query, nil, nil
+	}
+	var params []dataAPIParameter
+	bound := make(map[int]bool)
+	var out bytes.Buffer
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(query[i+1 : j])
+			if n < 1 || n > len(args) {
+				return "", nil, fmt.Errorf("query refers to $%d but has %d arguments", n, len(args))
+			}
+			i = j - 1
+			if args[n-1] == nil {
+				out.WriteString("NULL")
+				continue
+			}
+			name := "p" + strconv.Itoa(n)
+			out.WriteString(":" + name)
+			if !bound[n] {
+				value, err := dataAPIParameterValue(args[n-1])
+				if err != nil {
+					return "", nil, err
+				}
+				params = append(params, dataAPIParameter{Name: name, Value: value})
+				bound[n] = true
+			}
+			continue
+		}
+		out.WriteByte(ch)
+	}
+	return out.String(), params, nil
+}
+
+func dataAPIParameterValue(arg driver.Value) (string, error) {
+	switch v := arg.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05.999999"), nil
+	}
+	return "", fmt.Errorf("unsupported Data API parameter type %T", arg)
+}
@@ -0,0 +1,160 @@
+package redshift
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindDataAPIParameters(t *testing.T) {
+	query, params, err := bindDataAPIParameters(
+		"SELECT '$1', $1 FROM t WHERE a = $2 AND b = $1 AND c = $3",
+		[]driver.Value{int64(5), nil, time.Date(2017, 3, 8, 4, 0, 0, 0, time.UTC)})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT '$1', :p1 FROM t WHERE a = NULL AND b = :p1 AND c = :p3", query)
+	assert.Equal(t, []dataAPIParameter{{Name: "p1", Value: "5"}, {Name: "p3", Value: "2017-03-08 04:00:00"}}, params)
+
+	_, _, err = bindDataAPIParameters("SELECT $2", []driver.Value{int64(1)})
+	assert.Error(t, err)
+}
+
+func TestParseDataAPIConfig(t *testing.T) {
+	config, err := parseDataAPIConfig("workgroup=ingest&database=dev&region=us-west-2&session_keep_alive=60")
+	assert.NoError(t, err)
+	assert.Equal(t, &dataAPIConfig{Workgroup: "ingest", Database: "dev", Region: "us-west-2", SessionKeepAlive: 60,
+		StatementWait: defaultStatementWait}, config)
+	config, err = parseDataAPIConfig("workgroup=ingest&database=dev&statement_wait=300")
+	assert.NoError(t, err)
+	assert.Equal(t, 300, config.StatementWait)
+	_, err = parseDataAPIConfig("workgroup=ingest&database=dev&statement_wait=0")
+	assert.Error(t, err)
+
+	_, err = parseDataAPIConfig("workgroup=ingest&cluster=c&database=dev")
+	assert.Error(t, err)
+	_, err = parseDataAPIConfig("cluster=c")
+	assert.Error(t, err)
+}
+
+// fakeDataAPI runs every statement instantly, answering queries with one row, except statements
+// containing "slow", which run until they're canceled.
+type fakeDataAPI struct {
+	lock       sync.Mutex
+	statements []executeStatementInput
+	canceled   []string
+}
+
+func (f *fakeDataAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var response interface{}
+	switch r.Header.Get("X-Amz-Target") {
+	case "RedshiftData.ExecuteStatement":
+		var input executeStatementInput
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		f.statements = append(f.statements, input)
+		if strings.Contains(input.Sql, "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			response = map[string]string{"__type": "ValidationException", "message": "syntax error"}
+			break
+		}
+		response = executeStatementOutput{ID: "statement", SessionID: "session"}
+	case "RedshiftData.DescribeStatement":
+		last := f.statements[len(f.statements)-1].Sql
+		if strings.Contains(last, "slow") {
+			response = describeStatementOutput{Status: "STARTED"}
+			break
+		}
+		response = describeStatementOutput{
+			Status:       statementFinished,
+			HasResultSet: strings.HasPrefix(last, "SELECT"),
+			ResultRows:   2,
+		}
+	case "RedshiftData.CancelStatement":
+		var input statementInput
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		f.canceled = append(f.canceled, input.ID)
+		response = cancelStatementOutput{Status: true}
+	case "RedshiftData.GetStatementResult":
+		ts, n := "2017-03-08 04:00:00", int64(7)
+		response = getStatementResultOutput{
+			ColumnMetadata: []dataAPIColumn{{Name: "ts", TypeName: "timestamp"}, {Name: "n", TypeName: "int8"}},
+			Records:        [][]dataAPIField{{{StringValue: &ts}, {LongValue: &n}}},
+		}
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func TestDataAPIDriver(t *testing.T) {
+	_ = os.Setenv("AWS_ACCESS_KEY_ID", "id")
+	_ = os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	api := &fakeDataAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	db, err := sql.Open(dataAPIDriverName, "workgroup=ingest&database=dev&region=us-west-2&endpoint="+server.URL)
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	result, err := tx.Exec("DELETE FROM t WHERE ts < $1", time.Date(2017, 3, 8, 4, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	affected, _ := result.RowsAffected()
+	assert.Equal(t, int64(2), affected)
+	var ts time.Time
+	var n int
+	assert.NoError(t, tx.QueryRow("SELECT ts, n FROM t").Scan(&ts, &n))
+	assert.Equal(t, time.Date(2017, 3, 8, 4, 0, 0, 0, time.UTC), ts)
+	assert.Equal(t, 7, n)
+	assert.NoError(t, tx.Commit())
+	_, err = db.Exec("bad")
+	assert.EqualError(t, err, "executing statement through the Data API: ValidationException: syntax error\n\tstatus code: 400, request id: ")
+
+	if assert.Len(t, api.statements, 5) {
+		first := api.statements[0]
+		assert.Equal(t, "BEGIN", first.Sql)
+		assert.Equal(t, "ingest", first.WorkgroupName)
+		assert.Equal(t, "", first.SessionID)
+		assert.Equal(t, "DELETE FROM t WHERE ts < :p1", api.statements[1].Sql)
+		assert.Equal(t, []dataAPIParameter{{Name: "p1", Value: "2017-03-08 04:00:00"}}, api.statements[1].Parameters)
+		assert.Equal(t, "session", api.statements[1].SessionID)
+		assert.Equal(t, "", api.statements[1].WorkgroupName)
+		assert.Equal(t, "COMMIT", api.statements[3].Sql)
+	}
+}
+
+func TestDataAPICancel(t *testing.T) {
+	_ = os.Setenv("AWS_ACCESS_KEY_ID", "id")
+	_ = os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	api := &fakeDataAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	db, err := sql.Open(dataAPIDriverName, "workgroup=ingest&database=dev&region=us-west-2&statement_wait=1&endpoint="+server.URL)
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "SELECT slow()")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, err = db.Query("SELECT slow()")
+	assert.Equal(t, context.DeadlineExceeded, err, "statements without a deadline are waited for statement_wait")
+
+	_, err = db.Exec("DELETE FROM t")
+	assert.NoError(t, err, "the canceled statements' connections are replaced")
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	assert.Equal(t, []string{"statement", "statement"}, api.canceled)
+	if assert.Len(t, api.statements, 3) {
+		assert.Equal(t, "", api.statements[2].SessionID, "the replacement connection starts a new session")
+	}
+}
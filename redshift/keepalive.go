@@ -0,0 +1,91 @@
+package redshift
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+// KeepaliveConfig keeps pooled connections to Redshift from dying while idle, e.g. behind a NAT or
+// firewall that drops quiet sessions, so the next COPY doesn't fail on a dead connection.
+type KeepaliveConfig struct {
+	// Interval is how often idle connections are pinged; zero disables the pings
+	Interval time.Duration
+	// MaxLifetime is how long a connection is used before it's closed and reopened; zero keeps
+	// connections open indefinitely
+	MaxLifetime time.Duration
+}
+
+// keepalive pings the idle connections every interval, forever.
+func (rs *RSConnection) keepalive(interval time.Duration, stats monitoring.SafeStatter) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for range tick.C {
+		pinged, reopened := rs.pingIdle(interval)
+		if reopened > 0 {
+			logger.WithField("pinged", pinged).WithField("reopened", reopened).
+				Warn("Reopened dead idle Redshift connections")
+		}
+		stats.SafeInc("redshift.keepalive.pinged", int64(pinged), 1.0)
+		stats.SafeInc("redshift.keepalive.reopened", int64(reopened), 1.0)
+	}
+}
+
+// pingIdle pings each connection idle in the pool, closing the ones that fail and opening a connection
+// in place of each. The connections are held until all are pinged, so none is pinged twice. Returns
+// how many were pinged and how many reopened.
+func (rs *RSConnection) pingIdle(timeout time.Duration) (pinged, reopened int) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	idle := rs.Conn.Stats().Idle
+	conns := make([]*sql.Conn, 0, idle)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	dead := 0
+	for i := 0; i < idle; i++ {
+		conn, err := rs.Conn.Conn(ctx)
+		if err != nil {
+			logger.WithError(err).Warn("Error taking idle Redshift connection to ping")
+			break
+		}
+		conns = append(conns, conn)
+		pinged++
+		if err = ping(ctx, conn); err != nil {
+			logger.WithError(err).Info("Closing dead idle Redshift connection")
+			dead++
+			// The pool discards a connection whose Raw function returns ErrBadConn
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}
+
+	for i := 0; i < dead; i++ {
+		conn, err := rs.Conn.Conn(ctx)
+		if err != nil {
+			logger.WithError(err).Warn("Error reopening Redshift connection")
+			return pinged, reopened
+		}
+		conns = append(conns, conn)
+		if err = ping(ctx, conn); err != nil {
+			logger.WithError(err).Warn("Error pinging reopened Redshift connection")
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			return pinged, reopened
+		}
+		reopened++
+	}
+	return pinged, reopened
+}
+
+// ping runs a query on the connection. lib/pq's connections aren't driver.Pingers, so PingContext would
+// succeed without reaching Redshift.
+func ping(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT 1")
+	return err
+}
@@ -0,0 +1,90 @@
+package redshift
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pingDriver opens connections whose queries fail once they're marked dead. Like lib/pq's, they aren't
+// driver.Pingers.
+type pingDriver struct {
+	lock   sync.Mutex
+	opened int
+	closed int
+	conns  []*pingConn
+}
+
+type pingConn struct {
+	d       *pingDriver
+	dead    bool
+	queries []string
+}
+
+func (d *pingDriver) Open(string) (driver.Conn, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.opened++
+	c := &pingConn{d: d}
+	d.conns = append(d.conns, c)
+	return c, nil
+}
+
+func (c *pingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.queries = append(c.queries, query)
+	if c.dead {
+		return nil, errors.New("connection reset by peer")
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *pingConn) Close() error {
+	c.d.lock.Lock()
+	defer c.d.lock.Unlock()
+	c.d.closed++
+	return nil
+}
+
+func (c *pingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (c *pingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+func TestPingIdle(t *testing.T) {
+	d := &pingDriver{}
+	sql.Register("keepalive_test", d)
+	db, err := sql.Open("keepalive_test", "")
+	assert.Nil(t, err)
+	defer func() { _ = db.Close() }()
+	db.SetMaxIdleConns(5)
+
+	// Open three connections and leave them idle
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(context.Background())
+		assert.Nil(t, err)
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		assert.Nil(t, conn.Close())
+	}
+	d.conns[1].dead = true
+
+	rs := &RSConnection{Conn: db}
+	pinged, reopened := rs.pingIdle(time.Second)
+	assert.Equal(t, 3, pinged)
+	assert.Equal(t, 1, reopened)
+	assert.Equal(t, 4, d.opened)
+	assert.Equal(t, 1, d.closed, "only the dead connection is closed")
+	assert.Equal(t, 3, db.Stats().Idle)
+	assert.Equal(t, []string{"SELECT 1"}, d.conns[0].queries)
+	assert.Equal(t, []string{"SELECT 1"}, d.conns[3].queries, "the reopened connection is checked too")
+
+	pinged, reopened = rs.pingIdle(time.Second)
+	assert.Equal(t, 3, pinged)
+	assert.Equal(t, 0, reopened)
+}
@@ -0,0 +1,209 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueryTables are the system tables a Query may read.
+var QueryTables = map[string]bool{
+	"stv_recents":         true,
+	"stv_inflight":        true,
+	"stv_locks":           true,
+	"stv_wlm_query_state": true,
+	"stl_load_errors":     true,
+	"stl_load_commits":    true,
+	"stl_query":           true,
+	"svv_table_info":      true,
+}
+
+const (
+	// DefaultQueryLimit is how many rows a Query without a Limit returns.
+	DefaultQueryLimit = 100
+	// MaxQueryLimit is the most rows a Query returns.
+	MaxQueryLimit = 1000
+	// QueryTimeout is the statement_timeout of a Query.
+	QueryTimeout = 30 * time.Second
+)
+
+var identifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Query is a read-only SELECT from one of QueryTables, for inspecting the cluster without its
+// credentials.
+type Query struct {
+	Table string
+	// Columns are the columns selected; all of them if empty
+	Columns []string
+	// Where selects the rows whose columns equal the values
+	Where   map[string]string
+	OrderBy string
+	Desc    bool
+	Limit   int
+}
+
+// SQL validates the query and returns its statement and arguments. Only allowlisted tables and
+// plain column names are accepted, and values are passed as arguments, so the statement can only
+// read the table.
+func (q Query) SQL() (string, []interface{}, error) {
+	table := strings.ToLower(q.Table)
+	if !QueryTables[table] {
+		return "", nil, fmt.Errorf("table %q can't be queried", q.Table)
+	}
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		return "", nil, fmt.Errorf("limit must be between 0 and %d", MaxQueryLimit)
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultQueryLimit
+	}
+
+	columns := "*"
+	if len(q.Columns) > 0 {
+		for _, column := range q.Columns {
+			if !identifierPattern.MatchString(column) {
+				return "", nil, fmt.Errorf("invalid column %q", column)
+			}
+		}
+		columns = strings.Join(q.Columns, ", ")
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s", columns, table)
+
+	var where []string
+	for column := range q.Where {
+		where = append(where, column)
+	}
+	// Sorted so the arguments' order is stable
+	sort.Strings(where)
+	var args []interface{}
+	for i, column := range where {
+		if !identifierPattern.MatchString(column) {
+			return "", nil, fmt.Errorf("invalid column %q", column)
+		}
+		where[i] = fmt.Sprintf("%s = $%d", column, i+1)
+		args = append(args, q.Where[column])
+	}
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	if q.OrderBy != "" {
+		if !identifierPattern.MatchString(q.OrderBy) {
+			return "", nil, fmt.Errorf("invalid column %q", q.OrderBy)
+		}
+		stmt += " ORDER BY " + q.OrderBy
+		if q.Desc {
+			stmt += " DESC"
+		}
+	}
+	return stmt + fmt.Sprintf(" LIMIT %d", limit), args, nil
+}
+
+// QueryRequest is an RSRequest that runs a Query on one of an RSConnection's listeners and sends
+// its rows to Response, which should be buffered so the listener never blocks on it.
+type QueryRequest struct {
+	Query    Query
+	Start    time.Time
+	Response chan *Table
+}
+
+// GetExec returns the query's statement, or an empty string if it's invalid.
+func (r *QueryRequest) GetExec() string {
+	stmt, _, _ := r.Query.SQL()
+	return stmt
+}
+
+// GetStartTime returns when the query was requested.
+func (r *QueryRequest) GetStartTime() time.Time {
+	return r.Start
+}
+
+// GetCategory returns the category of the request.
+func (r *QueryRequest) GetCategory() string {
+	return "query"
+}
+
+// GetMessage returns a description of the query.
+func (r *QueryRequest) GetMessage() string {
+	return "query of " + r.Query.Table
+}
+
+// GetResult returns the RSResult of a query that returned i rows.
+func (r *QueryRequest) GetResult(i int, err error) *RSResult {
+	if err != nil {
+		return &RSResult{ResultMessage: err.Error(), Status: 500}
+	}
+	return &RSResult{ResultMessage: fmt.Sprintf("%d rows", i), Status: 200}
+}
+
+// RunQuery runs a Query in a transaction that's rolled back, with QueryTimeout as its
+// statement_timeout. Returns ErrCircuitOpen in the table without running it if redshift is
+// considered down.
+func (rs *RSConnection) RunQuery(q Query) *Table {
+	start := time.Now()
+	table := &Table{}
+	stmt, args, err := q.SQL()
+	if err != nil {
+		table.Err = err
+		return table
+	}
+	if err = rs.Breaker.Allow(); err != nil {
+		table.Err = err
+		return table
+	}
+	err = rs.runQuery(table, stmt, args)
+	rs.Breaker.Record(err)
+	table.Err = err
+	table.TimeTaken = time.Since(start)
+	return table
+}
+
+func (rs *RSConnection) runQuery(table *Table, stmt string, args []interface{}) error {
+	tx, err := rs.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	// Nothing is written, so there's nothing to commit
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout TO %d", QueryTimeout/time.Millisecond))
+	if err != nil {
+		return fmt.Errorf("setting statement timeout: %v", err)
+	}
+	rows, err := tx.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	return scanTable(table, rows)
+}
+
+// scanTable reads the rows into the table, with text as strings rather than bytes.
+func scanTable(table *Table, rows *sql.Rows) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	table.Columns = columns
+	table.Rows = [][]interface{}{}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range row {
+			pointers[i] = &row[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return err
+		}
+		for i, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[i] = strings.TrimRight(string(b), " ")
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return rows.Err()
+}
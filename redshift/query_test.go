@@ -0,0 +1,36 @@
+package redshift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuerySQL(t *testing.T) {
+	stmt, args, err := Query{Table: "STL_LOAD_ERRORS"}.SQL()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM stl_load_errors LIMIT 100", stmt)
+	assert.Empty(t, args)
+
+	stmt, args, err = Query{
+		Table:   "stv_recents",
+		Columns: []string{"pid", "query"},
+		Where:   map[string]string{"status": "Running", "user_name": "ingester"},
+		OrderBy: "starttime",
+		Desc:    true,
+		Limit:   10,
+	}.SQL()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"SELECT pid, query FROM stv_recents WHERE status = $1 AND user_name = $2 ORDER BY starttime DESC LIMIT 10", stmt)
+	assert.Equal(t, []interface{}{"Running", "ingester"}, args)
+
+	_, _, err = Query{Table: "logs.minute_watched"}.SQL()
+	assert.Error(t, err)
+	_, _, err = Query{Table: "stv_recents", Columns: []string{"1; DROP TABLE x"}}.SQL()
+	assert.Error(t, err)
+	_, _, err = Query{Table: "stv_recents", Where: map[string]string{"pid = pid OR 1": "1"}}.SQL()
+	assert.Error(t, err)
+	_, _, err = Query{Table: "stv_recents", Limit: MaxQueryLimit + 1}.SQL()
+	assert.Error(t, err)
+}
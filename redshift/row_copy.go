@@ -1,12 +1,15 @@
 package redshift
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/lib/pq"
 	"github.com/twitchscience/aws_utils/logger"
 	"github.com/twitchscience/scoop_protocol/scoop_protocol"
@@ -14,61 +17,284 @@ import (
 
 const (
 	// need to provide creds, and lib/pq barfs on paramater insertion in copy commands
-	copyCommand             = `COPY %s.%s FROM %s WITH CREDENTIALS '%s' %s`
+	copyCommand             = `COPY %s FROM %s WITH CREDENTIALS '%s' %s`
 	copyCommandSearch       = `COPY %% FROM '%s' %%`
 	credentialExpiryTimeout = 2 * time.Minute
 )
 
 var (
-	manifestImportOptions = strings.Join([]string{
+	manifestImportOptions = []string{
 		"removequotes",
 		"delimiter '\\t'",
-		"gzip",
+		compressionPlaceholder,
 		"escape",
 		"truncatecolumns",
 		"roundec",
 		"fillrecord",
-		"compupdate on",
 		"emptyasnull",
 		"acceptinvchars '?'",
 		"manifest",
-		"trimblanks;"},
-		" ",
-	)
+		"trimblanks",
+	}
+	jsonManifestImportOptions = []string{
+		compressionPlaceholder,
+		"truncatecolumns",
+		"roundec",
+		"emptyasnull",
+		"acceptinvchars '?'",
+		"manifest",
+		"trimblanks",
+	}
 	lastCredentialExpiry = time.Now()
+	credentialExpiryLock sync.Mutex
 )
 
+// compressionPlaceholder stands for the files' compression option among the import options
+const compressionPlaceholder = "<compression>"
+
+// defaultCompUpdate is the COMPUPDATE option used unless a table overrides it
+const defaultCompUpdate = "on"
+
+//CopyOptions are the per-load options of a manifest copy
+type CopyOptions struct {
+	// JSONPathsURL, if set, loads newline-delimited JSON files using the jsonpaths file at this URL
+	JSONPathsURL string
+	// CompUpdate is the COMPUPDATE option (on, off or preset); defaults to on
+	CompUpdate string
+	// StatUpdate is the STATUPDATE option (on or off); omitted if empty
+	StatUpdate string
+	// Compression is the files' compression option (gzip, bzip2 or zstd), or "none"; defaults to gzip
+	Compression string
+	// Region is the region of the data, manifest and jsonpaths files, if not the cluster's
+	Region string
+	// MasterSymmetricKey, if set, is the base64 encoded master key the files were encrypted with
+	// client-side, and they're loaded with the ENCRYPTED option
+	MasterSymmetricKey string
+	// StatementTimeoutMs, if positive, is the statement_timeout set in the COPYs' transaction
+	StatementTimeoutMs int
+}
+
+func (o CopyOptions) importOptions() string {
+	options := o.withCompression(manifestImportOptions)
+	if o.JSONPathsURL != "" {
+		options = fmt.Sprintf("FORMAT AS JSON %s %s", EscapePGString(o.JSONPathsURL),
+			o.withCompression(jsonManifestImportOptions))
+	}
+	compUpdate := o.CompUpdate
+	if compUpdate == "" {
+		compUpdate = defaultCompUpdate
+	}
+	options += " compupdate " + compUpdate
+	if o.StatUpdate != "" {
+		options += " statupdate " + o.StatUpdate
+	}
+	if o.Region != "" {
+		options += " region " + EscapePGString(o.Region)
+	}
+	if o.MasterSymmetricKey != "" {
+		options += " encrypted"
+	}
+	return options + ";"
+}
+
+// withCompression joins the import options with the compression option in its place.
+func (o CopyOptions) withCompression(options []string) string {
+	compression := o.Compression
+	if compression == "" {
+		compression = "gzip"
+	}
+	joined := make([]string, 0, len(options))
+	for _, option := range options {
+		if option == compressionPlaceholder {
+			if compression == "none" {
+				continue
+			}
+			option = compression
+		}
+		joined = append(joined, option)
+	}
+	return strings.Join(joined, " ")
+}
+
+// WithinDeadline returns the options with StatementTimeoutMs lowered to the time left before ctx's
+// deadline, if it has one, so Redshift cancels a COPY the caller has stopped waiting for. Drivers
+// that can't cancel a running statement leave that to the server.
+func (o CopyOptions) WithinDeadline(ctx context.Context) CopyOptions {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return o
+	}
+	left := int(time.Until(deadline) / time.Millisecond)
+	if left < 1 {
+		left = 1
+	}
+	if o.StatementTimeoutMs <= 0 || left < o.StatementTimeoutMs {
+		o.StatementTimeoutMs = left
+	}
+	return o
+}
+
+// ValidCompUpdate returns whether s is a valid COMPUPDATE option, or empty for the default
+func ValidCompUpdate(s string) bool {
+	return s == "" || s == "on" || s == "off" || s == "preset"
+}
+
+// ValidStatUpdate returns whether s is a valid STATUPDATE option, or empty for the default
+func ValidStatUpdate(s string) bool {
+	return s == "" || s == "on" || s == "off"
+}
+
 //ManifestRowCopyRequest is the redshift package's represntation of the manifest row copy object for a manifest row copy
 type ManifestRowCopyRequest struct {
-	BuiltOn     time.Time
+	BuiltOn time.Time
+	// Schema is the schema of the table COPYed into, or empty for a temporary table
 	Schema      string
 	Name        string
 	ManifestURL string
 	Credentials string
+	Options     CopyOptions
 }
 
 //TxExec runs the execution of the manifest row copy request in a transaction
 func (r ManifestRowCopyRequest) TxExec(t *sql.Tx) error {
+	return r.TxExecContext(context.Background(), t)
+}
+
+//TxExecContext is TxExec, giving up on the COPY once ctx is done
+func (r ManifestRowCopyRequest) TxExecContext(ctx context.Context, t *sql.Tx) error {
 	if strings.ContainsRune(r.ManifestURL, '\000') {
 		return fmt.Errorf("ManifestURL contains a null byte")
 	}
 	if strings.ContainsRune(r.Name, '\000') {
 		return fmt.Errorf("Name contains a null byte")
 	}
+	credentials := r.Credentials
+	if key := r.Options.MasterSymmetricKey; key != "" {
+		if strings.ContainsAny(key, "';\000") {
+			return fmt.Errorf("MasterSymmetricKey isn't base64")
+		}
+		credentials += ";master_symmetric_key=" + key
+	}
 
-	query := fmt.Sprintf(copyCommand, pq.QuoteIdentifier(r.Schema), pq.QuoteIdentifier(r.Name),
-		EscapePGString(r.ManifestURL), r.Credentials, manifestImportOptions)
+	target := pq.QuoteIdentifier(r.Name)
+	if r.Schema != "" {
+		target = pq.QuoteIdentifier(r.Schema) + "." + target
+	}
+	query := fmt.Sprintf(copyCommand, target, EscapePGString(r.ManifestURL), credentials, r.Options.importOptions())
 
-	_, err := t.Exec(query)
+	_, err := t.ExecContext(ctx, query)
 	return err
 }
 
-//CheckLoadStatus checks the status of a load into redshift
-func CheckLoadStatus(t *sql.Tx, manifestURL string) (scoop_protocol.LoadStatus, error) {
+//CopyResult is what redshift reports about a finished COPY
+type CopyResult struct {
+	QueryID    int64
+	RowsLoaded int64
+}
+
+//LastCopyResult returns the result of the last COPY run in the transaction's session
+func LastCopyResult(ctx context.Context, t *sql.Tx) (CopyResult, error) {
+	var result CopyResult
+	err := t.QueryRowContext(ctx, "SELECT pg_last_copy_id(), pg_last_copy_count()").Scan(&result.QueryID, &result.RowsLoaded)
+	return result, err
+}
+
+//CopyBytesScanned returns the bytes read from S3 by a committed COPY, from STL_S3CLIENT
+func CopyBytesScanned(db *sql.DB, queryID int64) (int64, error) {
+	var bytes int64
+	err := db.QueryRow("SELECT COALESCE(SUM(transfer_size), 0) FROM STL_S3CLIENT WHERE query = $1", queryID).Scan(&bytes)
+	return bytes, err
+}
+
+// CommitStats is how a transaction's commit went, from STL_COMMIT_STATS.
+type CommitStats struct {
+	// QueueWait is how long the commit waited in the cluster's commit queue behind other transactions'
+	QueueWait time.Duration
+	// Execution is how long the commit ran once it left the queue
+	Execution time.Duration
+}
+
+// newCommitStats returns the stats of a commit from the leader node's times. A commit that didn't
+// queue has no start of queueing, which Redshift records as 2000-01-01.
+func newCommitStats(startQueue, startWork, end time.Time) CommitStats {
+	var stats CommitStats
+	if startQueue.Year() > 2000 && startQueue.Before(startWork) {
+		stats.QueueWait = startWork.Sub(startQueue)
+	}
+	if startWork.Before(end) {
+		stats.Execution = end.Sub(startWork)
+	}
+	return stats
+}
+
+//CopyCommitStats returns the commit stats of the transaction of a committed COPY, from the leader
+//node's row in STL_COMMIT_STATS. Returns sql.ErrNoRows if Redshift hasn't recorded it yet.
+func CopyCommitStats(db *sql.DB, queryID int64) (CommitStats, error) {
+	var startQueue, startWork, end time.Time
+	err := db.QueryRow(`SELECT c.startqueue, c.startwork, c.endtime
+		FROM STL_COMMIT_STATS c JOIN STL_QUERY q ON q.xid = c.xid
+		WHERE q.query = $1 AND c.node = -1`, queryID).Scan(&startQueue, &startWork, &end)
+	if err != nil {
+		return CommitStats{}, err
+	}
+	return newCommitStats(startQueue, startWork, end), nil
+}
+
+//RunningCopies returns how many COPYs are running in the cluster, from STV_RECENTS
+func RunningCopies(db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT count(*) FROM STV_RECENTS WHERE query ILIKE 'copy %' AND status = 'Running'").Scan(&count)
+	return count, err
+}
+
+// WLMState is how busy the cluster's user WLM queues are
+type WLMState struct {
+	// Slots is the queues' total concurrency; 0 with automatic WLM, which sets no fixed slots
+	Slots   int
+	Running int
+	Queued  int
+}
+
+// Saturated returns whether a new query would wait for a WLM slot
+func (s WLMState) Saturated() bool {
+	return s.Queued > 0 || (s.Slots > 0 && s.Running >= s.Slots)
+}
+
+// QueryWLMState returns how busy the user WLM queues are, from STV_WLM_SERVICE_CLASS_CONFIG and STV_WLM_QUERY_STATE
+func QueryWLMState(db *sql.DB) (WLMState, error) {
+	var state WLMState
+	err := db.QueryRow(`SELECT COALESCE(SUM(num_query_tasks), 0) FROM STV_WLM_SERVICE_CLASS_CONFIG
+		WHERE service_class > 5 AND num_query_tasks > 0`).Scan(&state.Slots)
+	if err != nil {
+		return state, fmt.Errorf("querying WLM slots: %v", err)
+	}
+	err = db.QueryRow(`SELECT
+			COALESCE(SUM(CASE WHEN state = 'Running' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN state LIKE 'Queued%' THEN 1 ELSE 0 END), 0)
+		FROM STV_WLM_QUERY_STATE WHERE service_class > 5`).Scan(&state.Running, &state.Queued)
+	if err != nil {
+		return state, fmt.Errorf("querying WLM query state: %v", err)
+	}
+	return state, nil
+}
+
+//CopyLinesScanned returns the lines read from S3 by a committed COPY, from STL_LOAD_COMMITS
+func CopyLinesScanned(db *sql.DB, queryID int64) (int64, error) {
+	var lines int64
+	err := db.QueryRow("SELECT COALESCE(SUM(lines_scanned), 0) FROM STL_LOAD_COMMITS WHERE query = $1", queryID).Scan(&lines)
+	return lines, err
+}
+
+//CheckLoadStatus checks the status of a load into redshift. COPYs of the manifest into tables in
+//excludeSchema, if set, aren't the load's, e.g. the COPYs into canary copies of tables.
+func CheckLoadStatus(t *sql.Tx, manifestURL string, excludeSchema string) (scoop_protocol.LoadStatus, error) {
 	var count int
 	q := fmt.Sprintf(copyCommandSearch, manifestURL)
+	// Matches no COPY if there's no schema to exclude
+	excluded := "COPY " + pq.QuoteIdentifier(excludeSchema) + ".%"
 
-	err := t.QueryRow("SELECT count(*) FROM STV_RECENTS WHERE query ILIKE $1 AND status != 'Done'", q).Scan(&count)
+	err := t.QueryRow("SELECT count(*) FROM STV_RECENTS WHERE query ILIKE $1 AND query NOT ILIKE $2 AND status != 'Done'",
+		q, excluded).Scan(&count)
 	if err != nil {
 		return "", err
 	}
@@ -81,7 +307,8 @@ func CheckLoadStatus(t *sql.Tx, manifestURL string) (scoop_protocol.LoadStatus,
 	}
 
 	var aborted, xid int
-	err = t.QueryRow("SELECT xid, aborted FROM STL_QUERY WHERE querytxt ILIKE $1", q).Scan(&xid, &aborted)
+	err = t.QueryRow("SELECT xid, aborted FROM STL_QUERY WHERE querytxt ILIKE $1 AND querytxt NOT ILIKE $2",
+		q, excluded).Scan(&xid, &aborted)
 	switch {
 	case err == sql.ErrNoRows:
 		logger.WithField("manifestURL", manifestURL).Warning("CheckLoadStatus: Manifest copy does not have a transaction ID")
@@ -110,18 +337,23 @@ func CheckLoadStatus(t *sql.Tx, manifestURL string) (scoop_protocol.LoadStatus,
 	return scoop_protocol.LoadFailed, nil
 }
 
-//CopyCredentials refreshes the redshift aws auth token aggressively
+//CopyCredentials refreshes the redshift aws auth token aggressively, every credentialExpiryTimeout,
+//unless it's of an assumed role, which is refreshed before it expires
 func CopyCredentials(credentials *credentials.Credentials) (accessCreds string) {
-	// Agressively refresh the token
-	if time.Since(lastCredentialExpiry) > credentialExpiryTimeout {
-		credentials.Expire()
-	}
-
 	v, err := credentials.Get()
 	if err != nil {
 		logger.WithError(err).Error("Failed to retrieve credentials")
 		return ""
 	}
+	// Agressively refresh the token
+	if v.ProviderName != stscreds.ProviderName && credentialRefreshDue() {
+		credentials.Expire()
+		v, err = credentials.Get()
+		if err != nil {
+			logger.WithError(err).Error("Failed to retrieve credentials")
+			return ""
+		}
+	}
 
 	if len(v.SessionToken) == 0 {
 		accessCreds = fmt.Sprintf(
@@ -139,3 +371,45 @@ func CopyCredentials(credentials *credentials.Credentials) (accessCreds string)
 	}
 	return
 }
+
+//credentialRefreshDue returns whether the credentials were last refreshed over credentialExpiryTimeout
+//ago, recording that they're refreshed now if so, so only one of the COPYs starting at once refreshes them
+func credentialRefreshDue() bool {
+	credentialExpiryLock.Lock()
+	defer credentialExpiryLock.Unlock()
+	if time.Since(lastCredentialExpiry) <= credentialExpiryTimeout {
+		return false
+	}
+	lastCredentialExpiry = time.Now()
+	return true
+}
+
+//CancelCopies cancels the running COPYs of any of the load's manifests, returning how many it canceled.
+//The canceled COPYs fail in the session that ran them.
+func CancelCopies(t *sql.Tx, manifestUUID string) (int, error) {
+	q := fmt.Sprintf(copyCommandSearch, "s3://%/"+manifestUUID+"%.json")
+	rows, err := t.Query("SELECT pid FROM STV_RECENTS WHERE query ILIKE $1 AND status = 'Running'", q)
+	if err != nil {
+		return 0, fmt.Errorf("finding running copies: %v", err)
+	}
+	var pids []int
+	for rows.Next() {
+		var pid int
+		if err := rows.Scan(&pid); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("scanning running copies: %v", err)
+		}
+		pids = append(pids, pid)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("closing running copies: %v", err)
+	}
+
+	for _, pid := range pids {
+		if _, err := t.Exec("SELECT PG_CANCEL_BACKEND($1)", pid); err != nil {
+			return 0, fmt.Errorf("canceling copy in session %d: %v", pid, err)
+		}
+		logger.WithField("manifestUUID", manifestUUID).WithField("pid", pid).Info("Canceled running copy")
+	}
+	return len(pids), nil
+}
@@ -0,0 +1,98 @@
+package redshift
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportOptions(t *testing.T) {
+	opts := CopyOptions{}.importOptions()
+	assert.True(t, strings.HasSuffix(opts, "trimblanks compupdate on;"), opts)
+
+	opts = CopyOptions{CompUpdate: "off", StatUpdate: "off"}.importOptions()
+	assert.True(t, strings.HasSuffix(opts, "trimblanks compupdate off statupdate off;"), opts)
+
+	opts = CopyOptions{JSONPathsURL: "s3://bucket/jsonpaths/table/v1.json", CompUpdate: "preset"}.importOptions()
+	assert.True(t, strings.HasPrefix(opts, "FORMAT AS JSON 's3://bucket/jsonpaths/table/v1.json'"), opts)
+	assert.True(t, strings.HasSuffix(opts, "compupdate preset;"), opts)
+
+	opts = CopyOptions{Region: "eu-west-1"}.importOptions()
+	assert.True(t, strings.HasSuffix(opts, "compupdate on region 'eu-west-1';"), opts)
+
+	opts = CopyOptions{MasterSymmetricKey: "a2V5"}.importOptions()
+	assert.True(t, strings.HasSuffix(opts, "compupdate on encrypted;"), opts)
+	assert.NotContains(t, opts, "a2V5", "the key goes in the credentials")
+
+	opts = CopyOptions{}.importOptions()
+	assert.Contains(t, opts, "delimiter '\\t' gzip escape", "files are gzipped by default")
+	opts = CopyOptions{Compression: "zstd"}.importOptions()
+	assert.Contains(t, opts, "delimiter '\\t' zstd escape")
+	opts = CopyOptions{Compression: "none"}.importOptions()
+	assert.Contains(t, opts, "delimiter '\\t' escape")
+	opts = CopyOptions{JSONPathsURL: "s3://bucket/jsonpaths/table/v1.json", Compression: "bzip2"}.importOptions()
+	assert.True(t, strings.HasPrefix(opts, "FORMAT AS JSON 's3://bucket/jsonpaths/table/v1.json' bzip2 truncatecolumns"), opts)
+}
+
+func TestWLMSaturated(t *testing.T) {
+	assert.False(t, WLMState{Slots: 5, Running: 4}.Saturated())
+	assert.True(t, WLMState{Slots: 5, Running: 5}.Saturated())
+	assert.True(t, WLMState{Slots: 5, Running: 1, Queued: 1}.Saturated())
+	assert.False(t, WLMState{Running: 20}.Saturated())
+}
+
+func TestNewCommitStats(t *testing.T) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	stats := newCommitStats(start, start.Add(4*time.Second), start.Add(5*time.Second))
+	assert.Equal(t, CommitStats{QueueWait: 4 * time.Second, Execution: time.Second}, stats)
+
+	unqueued := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats = newCommitStats(unqueued, start, start.Add(time.Second))
+	assert.Equal(t, CommitStats{Execution: time.Second}, stats, "a commit that didn't queue didn't wait")
+}
+
+func TestWithinDeadline(t *testing.T) {
+	opts := CopyOptions{StatementTimeoutMs: 1000}
+	assert.Equal(t, 1000, opts.WithinDeadline(context.Background()).StatementTimeoutMs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	assert.Equal(t, 1000, opts.WithinDeadline(ctx).StatementTimeoutMs, "the timeout is already shorter")
+	left := CopyOptions{}.WithinDeadline(ctx).StatementTimeoutMs
+	assert.True(t, left > 0 && left <= 60000, left)
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	assert.Equal(t, 1, opts.WithinDeadline(expired).StatementTimeoutMs)
+}
+
+// countingProvider is a credentials provider counting its retrievals, whose credentials never expire.
+type countingProvider struct {
+	retrieved int
+}
+
+func (p *countingProvider) Retrieve() (credentials.Value, error) {
+	p.retrieved++
+	return credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret", ProviderName: "counting"}, nil
+}
+
+func (p *countingProvider) IsExpired() bool { return false }
+
+func TestCopyCredentials(t *testing.T) {
+	provider := &countingProvider{}
+	creds := credentials.NewCredentials(provider)
+	lastCredentialExpiry = time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "aws_access_key_id=id;aws_secret_access_key=secret", CopyCredentials(creds))
+	}
+	assert.Equal(t, 1, provider.retrieved, "credentials aren't refreshed by every COPY")
+
+	lastCredentialExpiry = time.Now().Add(-credentialExpiryTimeout - time.Second)
+	CopyCredentials(creds)
+	CopyCredentials(creds)
+	assert.Equal(t, 2, provider.retrieved, "credentials are refreshed once every credentialExpiryTimeout")
+}
@@ -1,6 +1,7 @@
 package redshift
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -8,6 +9,7 @@ import (
 
 	_ "github.com/lib/pq" //necessary for the postgres querys ran from funcs here
 	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
 )
 
 //Table is the internal representation of the the table in the rs_adaptor
@@ -31,6 +33,7 @@ type RSRequest interface {
 type RSConnection struct {
 	Conn            *sql.DB
 	InboundRequests chan RSRequest
+	Breaker         *CircuitBreaker
 }
 
 //RSResult represents the response from redshift after a query is run
@@ -49,9 +52,21 @@ func (r *RSResult) GetResultMessage() string {
 	return r.ResultMessage
 }
 
-//BuildRSConnection builds and returns a new connection to redshift
-func BuildRSConnection(pgConnect string, maxOpenConnections int) (*RSConnection, error) {
-	db, err := sql.Open("postgres", pgConnect)
+//BuildRSConnection builds and returns a new connection to redshift, guarded by a circuit breaker, whose
+//pooled connections are kept alive and recycled per keepaliveConfig.
+//transport is PostgresTransport, with pgConnect a postgres URL, or DataAPITransport, with pgConnect a
+//Data API DSN like "workgroup=ingest&database=dev&region=us-west-2".
+func BuildRSConnection(transport, pgConnect string, maxOpenConnections int, breakerConfig BreakerConfig,
+	keepaliveConfig KeepaliveConfig, stats monitoring.SafeStatter) (*RSConnection, error) {
+	driverName := "postgres"
+	switch transport {
+	case PostgresTransport, "":
+	case DataAPITransport:
+		driverName = dataAPIDriverName
+	default:
+		return nil, fmt.Errorf("unknown redshift transport %q", transport)
+	}
+	db, err := sql.Open(driverName, pgConnect)
 	if err != nil {
 		return nil, fmt.Errorf("Got err %v while connecting to db", err)
 	}
@@ -60,16 +75,31 @@ func BuildRSConnection(pgConnect string, maxOpenConnections int) (*RSConnection,
 		return nil, fmt.Errorf("Could not ping the db %v", err)
 	}
 	db.SetMaxOpenConns(maxOpenConnections)
-	return &RSConnection{
+	db.SetConnMaxLifetime(keepaliveConfig.MaxLifetime)
+	rs := &RSConnection{
 		Conn:            db,
 		InboundRequests: make(chan RSRequest, 10),
-	}, nil
+		Breaker:         newCircuitBreaker(breakerConfig, db.Ping, stats),
+	}
+	if keepaliveConfig.Interval > 0 {
+		logger.Go(func() { rs.keepalive(keepaliveConfig.Interval, stats) })
+	}
+	return rs, nil
+}
+
+// SetMaxConnections changes the most connections opened to redshift at once.
+func (rs *RSConnection) SetMaxConnections(maxOpenConnections int) {
+	rs.Conn.SetMaxOpenConns(maxOpenConnections)
 }
 
 //Listen continuously listens on inbound requests to exec on the RSconnection
 func (rs *RSConnection) Listen() {
 	for req := range rs.InboundRequests {
-		// only /query currently uses this
+		// only /control/query currently uses this
+		if q, ok := req.(*QueryRequest); ok {
+			q.Response <- rs.RunQuery(q.Query)
+			continue
+		}
 		_, _ = rs.ExecCommand(req)
 	}
 }
@@ -125,16 +155,31 @@ func (rs *RSConnection) ExecInTransaction(cmds ...RSRequest) error {
 	return tx.Commit()
 }
 
-//ExecFnInTransaction takes a closure function of a request and runs it on redshift in a transaction
-func (rs *RSConnection) ExecFnInTransaction(work func(*sql.Tx) error) error {
-	tx, err := rs.Conn.Begin()
+//ExecFnInTransaction takes a closure function of a request and runs it on redshift in a transaction.
+//Returns ErrCircuitOpen without running it if redshift is considered down.
+func (rs *RSConnection) ExecFnInTransaction(work func(*sql.Tx) error) (err error) {
+	return rs.ExecFnInTransactionContext(context.Background(), work)
+}
+
+//ExecFnInTransactionContext is ExecFnInTransaction in a transaction bound to ctx: it's rolled back if
+//ctx is done before it's committed, and statements run with the transaction's context fail once it is.
+func (rs *RSConnection) ExecFnInTransactionContext(ctx context.Context, work func(*sql.Tx) error) (err error) {
+	if err = rs.Breaker.Allow(); err != nil {
+		return err
+	}
+	defer func() { rs.Breaker.Record(err) }()
+	return rs.execFnInTransaction(ctx, work)
+}
+
+func (rs *RSConnection) execFnInTransaction(ctx context.Context, work func(*sql.Tx) error) error {
+	tx, err := rs.Conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	err = work(tx)
 	if err != nil {
 		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
+		if rollbackErr != nil && rollbackErr != sql.ErrTxDone {
 			if strings.Contains(err.Error(), "driver: bad connection") {
 				// Ace is down, just log a warning
 				logger.WithError(rollbackErr).Warning("Could not rollback successfully")
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/backend"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+	"github.com/twitchscience/rs_ingester/migrator"
+)
+
+// errNoWorkers is returned when reloading would start workers in a loader started without them.
+var errNoWorkers = errors.New("the loader was started with no workers; restart it to run loads")
+
+// configReloader re-reads the config file and applies the tunables that changed, so the loader
+// can be tuned without a restart dropping its in-flight loads. A reload applies all its changes or,
+// if the config is invalid, none of them.
+type configReloader struct {
+	lock        sync.Mutex
+	filename    string
+	flags       lib.Tunables
+	applied     lib.Tunables
+	metaBackend metadata.Backend
+	workers     *workerPool
+	aceBackend  *backend.RedshiftBackend
+	migrator    *migrator.Migrator
+	stats       monitoring.SafeStatter
+}
+
+// ReloadConfig re-reads the config file and applies its tunables, returning them.
+func (r *configReloader) ReloadConfig() (lib.Tunables, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	tunables, err := r.reload()
+	if err != nil {
+		r.stats.SafeInc("config.reload_errors", 1, 1.0)
+		return r.applied, err
+	}
+	r.stats.SafeInc("config.reloads", 1, 1.0)
+	return tunables, nil
+}
+
+func (r *configReloader) reload() (lib.Tunables, error) {
+	conf, err := loadConfig(r.filename)
+	if err != nil {
+		return r.applied, fmt.Errorf("loading config: %v", err)
+	}
+	tunables, err := conf.tunables(r.flags)
+	if err != nil {
+		return r.applied, fmt.Errorf("invalid tunables: %v", err)
+	}
+	if r.workers == nil && tunables.Workers > 0 {
+		return r.applied, errNoWorkers
+	}
+
+	fields := logger.WithField("config", r.filename)
+	if tunables.Workers != r.applied.Workers {
+		err = r.resizeWorkers(tunables.Workers)
+		if err != nil {
+			return r.applied, err
+		}
+		fields = fields.WithField("n_workers", tunables.Workers)
+	}
+	if r.metaBackend != nil && (tunables.LoadCountTrigger != r.applied.LoadCountTrigger ||
+		tunables.LoadAgeSeconds != r.applied.LoadAgeSeconds) {
+		r.metaBackend.SetLoadTriggers(tunables.LoadCountTrigger, time.Duration(tunables.LoadAgeSeconds)*time.Second)
+		fields = fields.WithField("loadCountTrigger", tunables.LoadCountTrigger).
+			WithField("loadAgeSeconds", tunables.LoadAgeSeconds)
+	}
+	if schedule := tunables.OffpeakSchedule(); !reflect.DeepEqual(schedule, r.applied.OffpeakSchedule()) {
+		r.migrator.SetOffpeakSchedule(schedule)
+		fields = fields.WithField("offpeakSchedule", schedule)
+	}
+	if r.metaBackend != nil && (tunables.IncludeTables != r.applied.IncludeTables ||
+		tunables.ExcludeTables != r.applied.ExcludeTables) {
+		// The patterns were checked by tunables
+		_ = r.metaBackend.TableFilter().SetDefault(tablePatterns(tunables))
+		fields = fields.WithField("includeTables", tunables.IncludeTables).
+			WithField("excludeTables", tunables.ExcludeTables)
+	}
+	r.applied = tunables
+	fields.Info("Reloaded config")
+	return tunables, nil
+}
+
+// resizeWorkers resizes the worker pool and the Redshift connection pool it uses. If some workers
+// fail to start, the ones that did are kept.
+func (r *configReloader) resizeWorkers(size int) error {
+	r.aceBackend.SetMaxConnections(size + healthCheckPoolSize + maxConcurrentMigrations)
+	err := r.workers.Resize(size)
+	if err != nil {
+		r.applied.Workers = r.workers.Size()
+		r.aceBackend.SetMaxConnections(r.applied.Workers + healthCheckPoolSize + maxConcurrentMigrations)
+		return err
+	}
+	return nil
+}
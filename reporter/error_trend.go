@@ -0,0 +1,151 @@
+package reporter
+
+import (
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// ErrorTrendWindow is how far back a table's error trend looks.
+const ErrorTrendWindow = 24 * time.Hour
+
+// A table's load errors spike when the last hour has at least spikeMinErrors of them and more than
+// spikeFactor times the table's mean per hour over the rest of the window.
+const (
+	spikeMinErrors = 5
+	spikeFactor    = 3
+)
+
+// unclassified is the class errors recorded without one are counted under.
+const unclassified = "unclassified"
+
+// ErrorTrend is how often loads of a table failed per hour over the trend window.
+type ErrorTrend struct {
+	// Hourly is the number of failures in each hour of the window, oldest first; the last is the last hour
+	Hourly []int64
+	// ByClass is the number of failures of each class over the window
+	ByClass map[string]int64
+	Total   int64
+	// Baseline is the mean failures per hour over the window before the last hour
+	Baseline float64
+	// Spike is whether the last hour's failures are well above the baseline
+	Spike bool
+}
+
+// NewErrorTrends returns the error trend of each table with failures in the counts, which are over
+// ErrorTrendWindow.
+func NewErrorTrends(counts []metadata.LoadErrorCount) map[string]*ErrorTrend {
+	hours := int(ErrorTrendWindow / time.Hour)
+	trends := make(map[string]*ErrorTrend)
+	for _, count := range counts {
+		if count.HoursAgo < 0 || count.HoursAgo >= hours {
+			continue
+		}
+		trend, ok := trends[count.Table]
+		if !ok {
+			trend = &ErrorTrend{Hourly: make([]int64, hours), ByClass: make(map[string]int64)}
+			trends[count.Table] = trend
+		}
+		class := count.Class
+		if class == "" {
+			class = unclassified
+		}
+		trend.Hourly[hours-1-count.HoursAgo] += count.Count
+		trend.ByClass[class] += count.Count
+		trend.Total += count.Count
+	}
+	for _, trend := range trends {
+		last := trend.Hourly[hours-1]
+		trend.Baseline = float64(trend.Total-last) / float64(hours-1)
+		trend.Spike = last >= spikeMinErrors && float64(last) > spikeFactor*trend.Baseline
+	}
+	return trends
+}
+
+// ErrorTrendReporter sends gauges of each table's load failures in the last hour, and whether they
+// spiked above the table's usual rate.
+type ErrorTrendReporter struct {
+	backend    metadata.Reader
+	stats      monitoring.SafeStatter
+	pollPeriod time.Duration
+	closer     chan bool
+	// reported are the trends of the tables with failures when last reported
+	reported map[string]*ErrorTrend
+}
+
+// NewErrorTrendReporter returns an ErrorTrendReporter that reads the error counts from backend with a
+// given interval.
+func NewErrorTrendReporter(backend metadata.Reader, stats monitoring.SafeStatter,
+	pollPeriod time.Duration) *ErrorTrendReporter {
+	r := &ErrorTrendReporter{
+		backend:    backend,
+		stats:      stats,
+		pollPeriod: pollPeriod,
+		closer:     make(chan bool),
+	}
+	logger.Go(r.reporterThread)
+	return r
+}
+
+func (r *ErrorTrendReporter) reporterThread() {
+	logger.Info("Error trend reporter started.")
+	defer logger.Info("Error trend reporter stopped.")
+	tick := time.NewTicker(r.pollPeriod)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if err := r.sendStats(time.Now().In(time.UTC)); err != nil {
+				logger.WithError(err).Error("Failed to report load error trends")
+			}
+		case <-r.closer:
+			return
+		}
+	}
+}
+
+func (r *ErrorTrendReporter) sendStats(now time.Time) error {
+	counts, err := r.backend.LoadErrorCounts("", now, ErrorTrendWindow)
+	if err != nil {
+		return err
+	}
+	trends := NewErrorTrends(counts)
+	var spiking int64
+	for table, trend := range trends {
+		lastHour := trend.Hourly[len(trend.Hourly)-1]
+		lib.TableGauge(r.stats, "load_errors.last_hour", table, lastHour)
+		if !trend.Spike {
+			if r.reported[table] != nil && r.reported[table].Spike {
+				lib.TableGauge(r.stats, "load_errors.spike", table, 0)
+			}
+			continue
+		}
+		spiking++
+		lib.TableGauge(r.stats, "load_errors.spike", table, 1)
+		if r.reported[table] == nil || !r.reported[table].Spike {
+			logger.WithField("table", table).WithField("lastHour", lastHour).
+				WithField("baseline", trend.Baseline).Warn("Load errors of table spiked")
+		}
+	}
+	// Clear the gauges of tables that stopped failing
+	for table, trend := range r.reported {
+		if trends[table] != nil {
+			continue
+		}
+		lib.TableGauge(r.stats, "load_errors.last_hour", table, 0)
+		if trend.Spike {
+			lib.TableGauge(r.stats, "load_errors.spike", table, 0)
+		}
+	}
+	r.reported = trends
+	r.stats.SafeGauge("load_errors.total.spiking", spiking, 1.0)
+	return nil
+}
+
+// Close is a blocking function that waits to cleanly shut down reporting.
+func (r *ErrorTrendReporter) Close() {
+	r.closer <- true
+}
@@ -0,0 +1,36 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+func TestNewErrorTrends(t *testing.T) {
+	trends := NewErrorTrends([]metadata.LoadErrorCount{
+		{Table: "spiking", Class: "connection", HoursAgo: 0, Count: 6},
+		{Table: "spiking", Class: "serialization", HoursAgo: 0, Count: 2},
+		{Table: "spiking", Class: "connection", HoursAgo: 5, Count: 23},
+		{Table: "steady", HoursAgo: 0, Count: 6},
+		{Table: "steady", Class: "disk_full", HoursAgo: 1, Count: 46},
+		{Table: "steady", HoursAgo: 24, Count: 100},
+	})
+	assert.Len(t, trends, 2)
+
+	spiking := trends["spiking"]
+	assert.Len(t, spiking.Hourly, 24)
+	assert.Equal(t, int64(8), spiking.Hourly[23])
+	assert.Equal(t, int64(23), spiking.Hourly[18])
+	assert.Equal(t, map[string]int64{"connection": 29, "serialization": 2}, spiking.ByClass)
+	assert.Equal(t, int64(31), spiking.Total)
+	assert.Equal(t, 1.0, spiking.Baseline)
+	assert.True(t, spiking.Spike)
+
+	steady := trends["steady"]
+	assert.Equal(t, map[string]int64{unclassified: 6, "disk_full": 46}, steady.ByClass,
+		"errors outside the window aren't counted")
+	assert.Equal(t, 2.0, steady.Baseline)
+	assert.False(t, steady.Spike, "6 errors aren't 3 times the baseline")
+}
@@ -30,12 +30,198 @@ func (m *MockReader) TSVVersionExists(table string, version int) (bool, error) {
 func (m *MockReader) ForceLoad(table string, requester string) error {
 	return nil
 }
+func (m *MockReader) PendingForceLoads() ([]metadata.PendingForceLoad, error) {
+	return nil, nil
+}
+func (m *MockReader) CancelForceLoad(table string) (bool, error) {
+	return false, nil
+}
+func (m *MockReader) ComponentToggles() ([]metadata.ComponentToggle, error) {
+	return nil, nil
+}
+func (m *MockReader) SetComponentToggle(toggle metadata.ComponentToggle) error {
+	return nil
+}
+func (m *MockReader) ComponentDisabled(component metadata.Component) (bool, error) {
+	return false, nil
+}
 func (m *MockReader) StatsForPendingLoads() ([]*metadata.PendingLoadStats, error) {
 	return m.pendingLoadsStats, nil
 }
 func (m *MockReader) IsForceLoadRequested(table string) (bool, error) {
 	return false, nil
 }
+func (m *MockReader) LoadTriggers() ([]metadata.LoadTrigger, error) {
+	return nil, nil
+}
+func (m *MockReader) SetLoadTrigger(trigger metadata.LoadTrigger) error {
+	return nil
+}
+func (m *MockReader) DeleteLoadTrigger(table string) error {
+	return nil
+}
+func (m *MockReader) CopySettings() ([]metadata.CopySettings, error) {
+	return nil, nil
+}
+func (m *MockReader) SetCopySettings(settings metadata.CopySettings) error {
+	return nil
+}
+func (m *MockReader) DeleteCopySettings(table string) error {
+	return nil
+}
+func (m *MockReader) AddAnnotation(annotation metadata.Annotation) (int64, error) {
+	return 0, nil
+}
+func (m *MockReader) Annotations(table string) ([]metadata.Annotation, error) {
+	return nil, nil
+}
+func (m *MockReader) DeleteAnnotation(table string, id int64) (bool, error) {
+	return false, nil
+}
+func (m *MockReader) InFlightLoads() ([]metadata.LoadSummary, error) {
+	return nil, nil
+}
+func (m *MockReader) FailedLoads(limit int) ([]metadata.LoadSummary, error) {
+	return nil, nil
+}
+func (m *MockReader) LoadThroughput(since time.Time) ([]metadata.TableThroughput, error) {
+	return nil, nil
+}
+func (m *MockReader) TableLoadErrors(table string, since time.Time, limit int) ([]metadata.TableLoadError, error) {
+	return nil, nil
+}
+func (m *MockReader) LoadErrorCounts(table string, now time.Time, window time.Duration) ([]metadata.LoadErrorCount, error) {
+	return nil, nil
+}
+func (m *MockReader) MaintenanceWindows() ([]metadata.MaintenanceWindow, error) {
+	return nil, nil
+}
+func (m *MockReader) AddMaintenanceWindow(window metadata.MaintenanceWindow) (int64, error) {
+	return 0, nil
+}
+func (m *MockReader) DeleteMaintenanceWindow(id int64) error {
+	return nil
+}
+func (m *MockReader) InsertBackfillLoad(load *metadata.Load) error {
+	return nil
+}
+func (m *MockReader) InsertBackfillLoads(loads []*metadata.Load) ([]bool, error) {
+	return make([]bool, len(loads)), nil
+}
+func (m *MockReader) InventoryProgress(manifest string) ([]metadata.InventoryFileProgress, error) {
+	return nil, nil
+}
+func (m *MockReader) SetInventoryProgress(progress metadata.InventoryFileProgress) error {
+	return nil
+}
+func (m *MockReader) AddAuditEntry(entry metadata.AuditEntry) error {
+	return nil
+}
+func (m *MockReader) AuditEntries(filter metadata.AuditFilter) ([]metadata.AuditEntry, error) {
+	return nil, nil
+}
+func (m *MockReader) LoadEvents(filter metadata.LoadEventFilter) ([]metadata.LoadEvent, error) {
+	return nil, nil
+}
+func (m *MockReader) LoadDetail(manifestUUID string) (*metadata.LoadDetail, error) {
+	return nil, metadata.ErrUnknownLoad
+}
+func (m *MockReader) SamplingRules() ([]metadata.SamplingRule, error) {
+	return nil, nil
+}
+func (m *MockReader) SetSamplingRule(rule metadata.SamplingRule) error {
+	return nil
+}
+func (m *MockReader) DeleteSamplingRule(table string) error {
+	return nil
+}
+func (m *MockReader) FreshnessSLOs() ([]metadata.FreshnessSLO, error) {
+	return nil, nil
+}
+func (m *MockReader) SetFreshnessSLO(slo metadata.FreshnessSLO) error {
+	return nil
+}
+func (m *MockReader) DeleteFreshnessSLO(table string) error {
+	return nil
+}
+func (m *MockReader) ForceLoadSchedules() ([]metadata.ForceLoadSchedule, error) {
+	return nil, nil
+}
+func (m *MockReader) SetForceLoadSchedule(schedule metadata.ForceLoadSchedule) error {
+	return nil
+}
+func (m *MockReader) DeleteForceLoadSchedule(table string) error {
+	return nil
+}
+func (m *MockReader) PausedTables() ([]metadata.PausedTable, error) {
+	return nil, nil
+}
+func (m *MockReader) PauseTable(paused metadata.PausedTable) error {
+	return nil
+}
+func (m *MockReader) ResumeTable(table string) error {
+	return nil
+}
+func (m *MockReader) VersionedTables() ([]metadata.VersionedTable, error) {
+	return nil, nil
+}
+func (m *MockReader) AddVersionedTable(table string, version int) error {
+	return nil
+}
+func (m *MockReader) RemoveVersionedTable(table string, version int) error {
+	return nil
+}
+func (m *MockReader) VersionLoading(table string, version int) (bool, error) {
+	return false, nil
+}
+func (m *MockReader) StragglerRules() ([]metadata.StragglerRule, error) {
+	return nil, nil
+}
+func (m *MockReader) SetStragglerRule(rule metadata.StragglerRule) error {
+	return nil
+}
+func (m *MockReader) DeleteStragglerRule(table string) error {
+	return nil
+}
+func (m *MockReader) DropTable(dropped metadata.DroppedTable) (int64, error) {
+	return 0, nil
+}
+func (m *MockReader) DrainDroppedTable(table string, version int) (int64, error) {
+	return 0, nil
+}
+func (m *MockReader) ForgetDroppedTable(table string, version int) error {
+	return nil
+}
+func (m *MockReader) DroppedTables() ([]metadata.DroppedTable, error) {
+	return nil, nil
+}
+func (m *MockReader) RenameTable(renamed metadata.RenamedTable) (int64, error) {
+	return 0, nil
+}
+func (m *MockReader) MoveRenamedTable(renamed metadata.RenamedTable) (int64, error) {
+	return 0, nil
+}
+func (m *MockReader) RenamedTables() ([]metadata.RenamedTable, error) {
+	return nil, nil
+}
+func (m *MockReader) QueueVersionIncrement(id string, table string, version int) error {
+	return nil
+}
+func (m *MockReader) PendingVersionIncrements() ([]metadata.VersionIncrement, error) {
+	return nil, nil
+}
+func (m *MockReader) FinishVersionIncrement(id string, incrementErr error) error {
+	return nil
+}
+func (m *MockReader) VersionIncrement(id string) (*metadata.VersionIncrement, error) {
+	return nil, metadata.ErrUnknownVersionIncrement
+}
+func (m *MockReader) AccountedKeys(keys []string) (map[string]bool, error) {
+	return nil, nil
+}
+func (m *MockReader) InMaintenance() (bool, error) {
+	return false, nil
+}
 
 type mockClock struct{}
 
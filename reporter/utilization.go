@@ -0,0 +1,94 @@
+package reporter
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+// RedshiftPool is the Redshift connection pool and cluster load a UtilizationReporter samples.
+type RedshiftPool interface {
+	PoolStats() sql.DBStats
+	RunningCopies() (int, error)
+}
+
+// UtilizationSources are what a UtilizationReporter samples.
+type UtilizationSources struct {
+	Redshift RedshiftPool
+	// PoolSize returns the number of load workers, which can change; nil without workers
+	PoolSize func() int
+	// BusyWorkers returns how many load workers are loading a manifest
+	BusyWorkers func() int
+	// WaitingLoads returns how many claimed loads are waiting for a free worker; nil without workers
+	WaitingLoads func() int
+}
+
+// UtilizationReporter sends gauges of how busy the Redshift connections and load workers are, to
+// help size the worker pool.
+type UtilizationReporter struct {
+	sources    UtilizationSources
+	stats      monitoring.SafeStatter
+	pollPeriod time.Duration
+	closer     chan bool
+}
+
+// NewUtilizationReporter returns a UtilizationReporter that samples the sources with a given interval.
+func NewUtilizationReporter(sources UtilizationSources, stats monitoring.SafeStatter,
+	pollPeriod time.Duration) *UtilizationReporter {
+	r := &UtilizationReporter{
+		sources:    sources,
+		stats:      stats,
+		pollPeriod: pollPeriod,
+		closer:     make(chan bool),
+	}
+	logger.Go(r.reporterThread)
+	return r
+}
+
+func (r *UtilizationReporter) reporterThread() {
+	logger.Info("Utilization reporter started.")
+	defer logger.Info("Utilization reporter stopped.")
+	tick := time.NewTicker(r.pollPeriod)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			r.sendStats()
+		case <-r.closer:
+			return
+		}
+	}
+}
+
+func (r *UtilizationReporter) sendStats() {
+	pool := r.sources.Redshift.PoolStats()
+	r.stats.SafeGauge("utilization.redshift.open_connections", int64(pool.OpenConnections), 1.0)
+	r.stats.SafeGauge("utilization.redshift.idle_connections", int64(pool.Idle), 1.0)
+	r.stats.SafeGauge("utilization.redshift.in_use_connections", int64(pool.InUse), 1.0)
+
+	var poolSize int
+	if r.sources.PoolSize != nil {
+		poolSize = r.sources.PoolSize()
+	}
+	r.stats.SafeGauge("utilization.workers.total", int64(poolSize), 1.0)
+	if r.sources.BusyWorkers != nil {
+		r.stats.SafeGauge("utilization.workers.busy", int64(r.sources.BusyWorkers()), 1.0)
+	}
+	if r.sources.WaitingLoads != nil {
+		r.stats.SafeGauge("utilization.queue.depth", int64(r.sources.WaitingLoads()), 1.0)
+	}
+
+	copies, err := r.sources.Redshift.RunningCopies()
+	if err != nil {
+		logger.WithError(err).Error("Failed to count running COPYs")
+		return
+	}
+	r.stats.SafeGauge("utilization.redshift.running_copies", int64(copies), 1.0)
+}
+
+// Close is a blocking function that waits to cleanly shut down reporting.
+func (r *UtilizationReporter) Close() {
+	r.closer <- true
+}
@@ -0,0 +1,70 @@
+package reporter
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/cactus/go-statsd-client/statsd"
+	"github.com/cactus/go-statsd-client/statsd/statsdtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+type mockPool struct {
+	stats     sql.DBStats
+	copies    int
+	copiesErr error
+}
+
+func (m *mockPool) PoolStats() sql.DBStats {
+	return m.stats
+}
+func (m *mockPool) RunningCopies() (int, error) {
+	return m.copies, m.copiesErr
+}
+
+func sentUtilization(t *testing.T, sources UtilizationSources) []string {
+	rs := new(statsdtest.RecordingSender)
+	statter, err := statsd.NewClientWithSender(rs, "t")
+	require.NoError(t, err)
+	r := &UtilizationReporter{sources: sources, stats: &monitoring.LoggingStatter{Statter: statter}}
+	r.sendStats()
+
+	var sent []string
+	for _, stat := range rs.GetSent() {
+		sent = append(sent, string(stat.Raw))
+	}
+	return sent
+}
+
+// TestSendUtilization checks the gauges sent for a sample
+func TestSendUtilization(t *testing.T) {
+	pool := &mockPool{stats: sql.DBStats{OpenConnections: 5, InUse: 3, Idle: 2}, copies: 4}
+	sent := sentUtilization(t, UtilizationSources{
+		Redshift:     pool,
+		PoolSize:     func() int { return 6 },
+		BusyWorkers:  func() int { return 3 },
+		WaitingLoads: func() int { return 1 },
+	})
+	assert.Equal(t, []string{
+		"t.utilization.redshift.open_connections:5|g",
+		"t.utilization.redshift.idle_connections:2|g",
+		"t.utilization.redshift.in_use_connections:3|g",
+		"t.utilization.workers.total:6|g",
+		"t.utilization.workers.busy:3|g",
+		"t.utilization.queue.depth:1|g",
+		"t.utilization.redshift.running_copies:4|g",
+	}, sent)
+
+	// Without workers, and with STV_RECENTS unavailable, the rest is still sent
+	pool.copiesErr = errors.New("connection refused")
+	sent = sentUtilization(t, UtilizationSources{Redshift: pool})
+	assert.Equal(t, []string{
+		"t.utilization.redshift.open_connections:5|g",
+		"t.utilization.redshift.idle_connections:2|g",
+		"t.utilization.redshift.in_use_connections:3|g",
+		"t.utilization.workers.total:0|g",
+	}, sent)
+}
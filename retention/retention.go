@@ -0,0 +1,211 @@
+package retention
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+// Expirer counts and deletes a table's rows older than a cutoff.
+type Expirer interface {
+	TableExists(table string) (bool, error)
+	ExpiredRows(table, column string, before time.Time) (int64, error)
+	DeleteExpiredRows(table, column string, before time.Time, timeoutMs int) (int64, error)
+}
+
+// ClassGetter gives events' retention classes from blueprint metadata.
+type ClassGetter interface {
+	GetAllMetadata() scoop_protocol.EventMetadataConfig
+	RetentionClass(eventName string) string
+}
+
+// Config configures a Manager.
+type Config struct {
+	// ClassDays is how many days of data events of each retention class keep. Events whose
+	// class isn't listed keep all their data.
+	ClassDays map[string]int
+	// TimeColumn is the column rows are expired by
+	TimeColumn string
+	// Period is how often deletions are planned, and carried out if it's offpeak
+	Period time.Duration
+	// TimeoutMs is the statement_timeout of each DELETE; 0 for none
+	TimeoutMs int
+	// DryRun only plans deletions, never carrying them out
+	DryRun bool
+}
+
+// Plan is a table's planned deletion, and its outcome if it was carried out.
+type Plan struct {
+	Table          string
+	RetentionClass string
+	RetentionDays  int
+	Cutoff         time.Time
+	ExpiredRows    int64
+	Planned        time.Time
+	DeletedRows    int64      `json:",omitempty"`
+	Deleted        *time.Time `json:",omitempty"`
+	Error          string     `json:",omitempty"`
+}
+
+// Manager periodically deletes rows older than their table's retention from Redshift. Deletions
+// are planned every period, but only carried out offpeak; a dry run only plans them.
+type Manager struct {
+	expirer Expirer
+	classes ClassGetter
+	offpeak func() bool
+	stats   monitoring.SafeStatter
+	config  Config
+
+	plans     []Plan
+	plansLock sync.RWMutex
+	closer    chan bool
+	closed    chan bool
+}
+
+// New returns a Manager and starts its loop. offpeak reports whether deletions may run now.
+func New(expirer Expirer, classes ClassGetter, offpeak func() bool, stats monitoring.SafeStatter,
+	config Config) *Manager {
+	m := &Manager{
+		expirer: expirer,
+		classes: classes,
+		offpeak: offpeak,
+		stats:   stats,
+		config:  config,
+		closer:  make(chan bool),
+		closed:  make(chan bool),
+	}
+	logger.Go(m.loop)
+	return m
+}
+
+func (m *Manager) loop() {
+	defer close(m.closed)
+	tick := time.NewTicker(m.config.Period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			m.run(time.Now().In(time.UTC))
+		case <-m.closer:
+			return
+		}
+	}
+}
+
+// run plans the deletions, and carries them out unless it's a dry run or onpeak.
+func (m *Manager) run(now time.Time) {
+	plans := m.plan(now)
+	m.setPlans(plans)
+	if m.config.DryRun || !m.offpeak() {
+		return
+	}
+	for i := range plans {
+		select {
+		case <-m.closer:
+			return
+		default:
+		}
+		if plans[i].Error != "" || plans[i].ExpiredRows == 0 {
+			continue
+		}
+		m.expire(&plans[i])
+		m.setPlans(plans)
+	}
+}
+
+// tables returns the retention class of each event with a known class, by table.
+func (m *Manager) tables() map[string]string {
+	tables := make(map[string]string)
+	for event := range m.classes.GetAllMetadata().Metadata {
+		class := m.classes.RetentionClass(event)
+		if _, ok := m.config.ClassDays[class]; ok {
+			tables[event] = class
+		}
+	}
+	return tables
+}
+
+// plan counts the expired rows of each table with a retention class.
+func (m *Manager) plan(now time.Time) []Plan {
+	classes := m.tables()
+	tables := make([]string, 0, len(classes))
+	for table := range classes {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	plans := []Plan{}
+	for _, table := range tables {
+		exists, err := m.expirer.TableExists(table)
+		if err != nil {
+			logger.WithError(err).WithField("table", table).Error("Error checking table exists for retention")
+			continue
+		}
+		if !exists {
+			continue
+		}
+		days := m.config.ClassDays[classes[table]]
+		plan := Plan{
+			Table:          table,
+			RetentionClass: classes[table],
+			RetentionDays:  days,
+			Cutoff:         now.AddDate(0, 0, -days),
+			Planned:        now,
+		}
+		plan.ExpiredRows, err = m.expirer.ExpiredRows(table, m.config.TimeColumn, plan.Cutoff)
+		if err != nil {
+			logger.WithError(err).WithField("table", table).Error("Error counting expired rows")
+			plan.Error = err.Error()
+		}
+		lib.TableGauge(m.stats, "retention.expired_rows", table, plan.ExpiredRows)
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// expire carries out the plan, recording its outcome in it.
+func (m *Manager) expire(plan *Plan) {
+	start := time.Now()
+	deleted, err := m.expirer.DeleteExpiredRows(plan.Table, m.config.TimeColumn, plan.Cutoff, m.config.TimeoutMs)
+	plan.DeletedRows = deleted
+	if err != nil {
+		logger.WithError(err).WithField("table", plan.Table).Error("Error deleting expired rows")
+		plan.Error = err.Error()
+		lib.TableInc(m.stats, "retention.error", plan.Table, 1)
+		return
+	}
+	finished := time.Now().In(time.UTC)
+	plan.Deleted = &finished
+	lib.TableInc(m.stats, "retention.deleted_rows", plan.Table, deleted)
+	lib.TableTiming(m.stats, "retention.delete", plan.Table, time.Since(start))
+	logger.WithField("table", plan.Table).WithField("deleted", deleted).WithField("cutoff", plan.Cutoff).
+		Info("Deleted expired rows")
+}
+
+func (m *Manager) setPlans(plans []Plan) {
+	copied := make([]Plan, len(plans))
+	copy(copied, plans)
+	m.plansLock.Lock()
+	defer m.plansLock.Unlock()
+	m.plans = copied
+}
+
+// PlannedDeletions returns the latest plan of each table with a retention class.
+func (m *Manager) PlannedDeletions() []Plan {
+	m.plansLock.RLock()
+	defer m.plansLock.RUnlock()
+	plans := make([]Plan, len(m.plans))
+	copy(plans, m.plans)
+	return plans
+}
+
+// Close stops the manager, waiting for the deletion in progress, if any.
+func (m *Manager) Close() {
+	close(m.closer)
+	<-m.closed
+}
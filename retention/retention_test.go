@@ -0,0 +1,90 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/scoop_protocol/scoop_protocol"
+)
+
+type fakeExpirer struct {
+	tables  map[string]int64
+	deleted map[string]time.Time
+}
+
+func (e *fakeExpirer) TableExists(table string) (bool, error) {
+	_, ok := e.tables[table]
+	return ok, nil
+}
+
+func (e *fakeExpirer) ExpiredRows(table, column string, before time.Time) (int64, error) {
+	return e.tables[table], nil
+}
+
+func (e *fakeExpirer) DeleteExpiredRows(table, column string, before time.Time, timeoutMs int) (int64, error) {
+	e.deleted[table] = before
+	return e.tables[table], nil
+}
+
+type fakeClasses map[string]string
+
+func (c fakeClasses) GetAllMetadata() scoop_protocol.EventMetadataConfig {
+	config := scoop_protocol.EventMetadataConfig{Metadata: make(map[string]map[string]scoop_protocol.EventMetadataRow)}
+	for event := range c {
+		config.Metadata[event] = nil
+	}
+	return config
+}
+
+func (c fakeClasses) RetentionClass(eventName string) string {
+	return c[eventName]
+}
+
+func newTestManager(expirer Expirer, offpeak bool, dryRun bool) *Manager {
+	return &Manager{
+		expirer: expirer,
+		classes: fakeClasses{"short": "short", "standard": "standard", "unknown": "forever", "missing": "short"},
+		offpeak: func() bool { return offpeak },
+		stats:   monitoring.NewMockStatter(),
+		config:  Config{ClassDays: map[string]int{"short": 7, "standard": 90}, TimeColumn: "time", DryRun: dryRun},
+		closer:  make(chan bool),
+	}
+}
+
+func TestPlan(t *testing.T) {
+	expirer := &fakeExpirer{
+		tables:  map[string]int64{"short": 10, "standard": 0, "unknown": 5},
+		deleted: make(map[string]time.Time),
+	}
+	now := time.Date(2017, 3, 15, 4, 0, 0, 0, time.UTC)
+	m := newTestManager(expirer, true, false)
+	m.run(now)
+
+	plans := m.PlannedDeletions()
+	if assert.Len(t, plans, 2) {
+		assert.Equal(t, "short", plans[0].Table)
+		assert.Equal(t, time.Date(2017, 3, 8, 4, 0, 0, 0, time.UTC), plans[0].Cutoff)
+		assert.Equal(t, int64(10), plans[0].DeletedRows)
+		assert.NotNil(t, plans[0].Deleted)
+		assert.Equal(t, "standard", plans[1].Table)
+		assert.Nil(t, plans[1].Deleted)
+	}
+	assert.Equal(t, map[string]time.Time{"short": plans[0].Cutoff}, expirer.deleted)
+}
+
+func TestPlanOnly(t *testing.T) {
+	for _, m := range []*Manager{
+		newTestManager(&fakeExpirer{tables: map[string]int64{"short": 10}, deleted: make(map[string]time.Time)}, false, false),
+		newTestManager(&fakeExpirer{tables: map[string]int64{"short": 10}, deleted: make(map[string]time.Time)}, true, true),
+	} {
+		m.run(time.Now())
+		plans := m.PlannedDeletions()
+		if assert.Len(t, plans, 1) {
+			assert.Equal(t, int64(10), plans[0].ExpiredRows)
+			assert.Nil(t, plans[0].Deleted)
+		}
+		assert.Empty(t, m.expirer.(*fakeExpirer).deleted)
+	}
+}
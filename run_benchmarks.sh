@@ -0,0 +1,20 @@
+#!/bin/bash --
+set -euo pipefail
+
+bench_path=bench_results
+mkdir -p ${bench_path}
+
+BENCHDIRS=$(go list ./... | grep -v /vendor/)
+
+for pkg in $BENCHDIRS; do
+  pkg_name=${pkg//\//_}
+  if ! go test -list 'Benchmark.*' ${pkg} | grep -q '^Benchmark'; then
+    continue
+  fi
+  echo "Benchmarking and profiling: ${pkg}"
+  go test -run=NONE -bench=. -benchmem \
+    -cpuprofile=${bench_path}/${pkg_name}.cpu.out \
+    -memprofile=${bench_path}/${pkg_name}.mem.out \
+    -o ${bench_path}/${pkg_name}.test \
+    ${pkg} | tee ${bench_path}/${pkg_name}.txt
+done
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is the range of one of a cron expression's fields.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Cron is a parsed cron expression: minute, hour, day of month, month and day of week, each `*`,
+// a number, a range `a-b`, a step `*/n` or `a-b/n`, or a list of those separated by commas. Days
+// of week are 0 to 7, both Sunday. As in cron, if both days are restricted, either matches.
+type Cron struct {
+	expr   string
+	sets   [5]uint64
+	anyDOM bool
+	anyDOW bool
+}
+
+// Parse parses a cron expression.
+func Parse(expr string) (*Cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q has %d fields, not %d", expr, len(parts), len(fields))
+	}
+	c := &Cron{expr: expr, anyDOM: parts[2] == "*", anyDOW: parts[4] == "*"}
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("parsing cron expression %q: %v", expr, err)
+		}
+		c.sets[i] = set
+	}
+	// Sunday is both 0 and 7.
+	if c.sets[4]&(1<<7) != 0 {
+		c.sets[4] |= 1
+	}
+	return c, nil
+}
+
+// parseField returns the set of values of a field as a bitset.
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(part, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rng = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s %q", f.name, item)
+			}
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s %q", f.name, item)
+			}
+		default:
+			v, err := parseValue(rng, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, f field) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s %q isn't a number from %d to %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the cron expression.
+func (c *Cron) String() string {
+	return c.expr
+}
+
+func (c *Cron) has(i, v int) bool {
+	return c.sets[i]&(1<<uint(v)) != 0
+}
+
+// matchesDay returns whether the cron runs on t's day.
+func (c *Cron) matchesDay(t time.Time) bool {
+	if !c.has(3, int(t.Month())) {
+		return false
+	}
+	dom, dow := c.has(2, t.Day()), c.has(4, int(t.Weekday()))
+	switch {
+	case c.anyDOM && c.anyDOW:
+		return true
+	case c.anyDOM:
+		return dow
+	case c.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Matches returns whether the cron runs in t's minute, in UTC.
+func (c *Cron) Matches(t time.Time) bool {
+	t = t.In(time.UTC)
+	return c.has(0, t.Minute()) && c.has(1, t.Hour()) && c.matchesDay(t)
+}
+
+// Next returns the first minute after t the cron runs in, or the zero time if it doesn't run in
+// the next five years, e.g. for February 30th.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.In(time.UTC).Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.has(1, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.has(0, t.Minute()) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,49 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "45 5 * * *", "0,30 */2 1-15 * 1-5", "5/15 0 * 12 7"} {
+		_, err := Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *",
+		"*/0 * * * *", "a * * * *", "* * * * 8"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2017, 3, 8, 4, 10, 30, 0, time.UTC)
+	for _, c := range []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2017, 3, 8, 4, 11, 0, 0, time.UTC)},
+		{"45 5 * * *", time.Date(2017, 3, 8, 5, 45, 0, 0, time.UTC)},
+		{"0 4 * * *", time.Date(2017, 3, 9, 4, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2017, 3, 8, 4, 15, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2017, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2017, 3, 12, 0, 0, 0, 0, time.UTC)},
+		// Either day matches when both are restricted
+		{"0 0 20 * 5", time.Date(2017, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		cron, err := Parse(c.expr)
+		if assert.NoError(t, err) {
+			assert.Equal(t, c.next, cron.Next(now), c.expr)
+			if !c.next.IsZero() {
+				assert.True(t, cron.Matches(c.next), c.expr)
+				assert.False(t, cron.Matches(c.next.Add(-time.Minute)) && c.expr != "* * * * *", c.expr)
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package schedule
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// Where a table's schedule is defined.
+const (
+	// SourceConfig is a schedule from the loader's config
+	SourceConfig = "config"
+	// SourceDB is a schedule set in ingesterdb, which overrides the config's
+	SourceDB = "db"
+)
+
+// Requester is the requester of scheduled force loads.
+const Requester = "scheduler"
+
+// maxCatchUp bounds how far back a late check looks for missed runs, e.g. after ingesterdb was down.
+const maxCatchUp = time.Hour
+
+// Source gives the schedules set in ingesterdb and force loads tables.
+type Source interface {
+	ForceLoadSchedules() ([]metadata.ForceLoadSchedule, error)
+	ForceLoad(table string, requester string) error
+}
+
+// Config configures a Scheduler.
+type Config struct {
+	// Defaults is the cron expression of each table's force loads, overridden by schedules in ingesterdb
+	Defaults map[string]string
+	// Period is how often the schedules are checked; runs between checks aren't missed
+	Period time.Duration
+}
+
+// Status is a table's force load schedule and its last run.
+type Status struct {
+	Table    string
+	Schedule string
+	Source   string
+	// Next is when the table is next force loaded
+	Next    *time.Time `json:",omitempty"`
+	LastRun *time.Time `json:",omitempty"`
+	// Error is why the last run failed, or why the schedule is invalid
+	Error string `json:",omitempty"`
+}
+
+// entry is a table's parsed schedule.
+type entry struct {
+	expr   string
+	cron   *Cron
+	source string
+	err    error
+}
+
+// Scheduler force loads tables on their schedules.
+type Scheduler struct {
+	source   Source
+	stats    monitoring.SafeStatter
+	defaults map[string]*Cron
+	period   time.Duration
+
+	lock     sync.RWMutex
+	checked  time.Time
+	entries  map[string]entry
+	lastRuns map[string]time.Time
+	errors   map[string]string
+	closer   chan bool
+	closed   chan bool
+}
+
+// New returns a Scheduler and starts its loop, or an error if a default schedule is invalid.
+func New(source Source, stats monitoring.SafeStatter, config Config) (*Scheduler, error) {
+	defaults := make(map[string]*Cron, len(config.Defaults))
+	for table, expr := range config.Defaults {
+		cron, err := Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		defaults[table] = cron
+	}
+	s := &Scheduler{
+		source:   source,
+		stats:    stats,
+		defaults: defaults,
+		period:   config.Period,
+		checked:  time.Now().In(time.UTC).Truncate(time.Minute),
+		entries:  make(map[string]entry),
+		lastRuns: make(map[string]time.Time),
+		errors:   make(map[string]string),
+		closer:   make(chan bool),
+		closed:   make(chan bool),
+	}
+	logger.Go(s.loop)
+	return s, nil
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.closed)
+	tick := time.NewTicker(s.period)
+	defer tick.Stop()
+	// Nothing's due yet, but this lists the schedules.
+	s.run(time.Now().In(time.UTC))
+	for {
+		select {
+		case <-tick.C:
+			s.run(time.Now().In(time.UTC))
+		case <-s.closer:
+			return
+		}
+	}
+}
+
+// schedules returns each table's schedule, with the ones set in ingesterdb overriding the config's.
+func (s *Scheduler) schedules() (map[string]entry, error) {
+	entries := make(map[string]entry, len(s.defaults))
+	for table, cron := range s.defaults {
+		entries[table] = entry{expr: cron.String(), cron: cron, source: SourceConfig}
+	}
+	dbSchedules, err := s.source.ForceLoadSchedules()
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range dbSchedules {
+		cron, err := Parse(schedule.Schedule)
+		entries[schedule.Table] = entry{expr: schedule.Schedule, cron: cron, source: SourceDB, err: err}
+	}
+	return entries, nil
+}
+
+// run force loads the tables whose schedules ran in a minute since the last check, up to now.
+func (s *Scheduler) run(now time.Time) {
+	entries, err := s.schedules()
+	if err != nil {
+		logger.WithError(err).Error("Error listing force load schedules")
+		s.stats.SafeInc("scheduled_force_load.total.errors", 1, 1.0)
+		return
+	}
+	to := now.Truncate(time.Minute)
+	s.lock.Lock()
+	from := s.checked
+	s.checked = to
+	s.entries = entries
+	s.lock.Unlock()
+	if to.Sub(from) > maxCatchUp {
+		logger.WithField("lastChecked", from).Warn("Force load schedules weren't checked for a while, skipping older runs")
+		from = to.Add(-maxCatchUp)
+	}
+
+	for table, e := range entries {
+		if e.err != nil {
+			logger.WithError(e.err).WithField("table", table).Warn("Skipping invalid force load schedule")
+			continue
+		}
+		if !ranBetween(e.cron, from, to) {
+			continue
+		}
+		logfields := logger.WithField("table", table).WithField("schedule", e.cron.String()).
+			WithField("source", e.source)
+		err := s.source.ForceLoad(table, Requester)
+		s.lock.Lock()
+		s.lastRuns[table] = now
+		if err != nil {
+			s.errors[table] = err.Error()
+		} else {
+			delete(s.errors, table)
+		}
+		s.lock.Unlock()
+		if err != nil {
+			logfields.WithError(err).Error("Error force loading table on schedule")
+			lib.TableInc(s.stats, "scheduled_force_load.errors", table, 1)
+			s.stats.SafeInc("scheduled_force_load.total.errors", 1, 1.0)
+			continue
+		}
+		logfields.Info("Force loading table on schedule")
+		lib.TableInc(s.stats, "scheduled_force_load.triggered", table, 1)
+		s.stats.SafeInc("scheduled_force_load.total.triggered", 1, 1.0)
+	}
+}
+
+// ranBetween returns whether the cron ran in a minute after from, up to and including to.
+func ranBetween(cron *Cron, from, to time.Time) bool {
+	next := cron.Next(from)
+	return !next.IsZero() && !next.After(to)
+}
+
+// Statuses returns every table's schedule as of the last check, with when it's next run.
+func (s *Scheduler) Statuses() []Status {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	statuses := make([]Status, 0, len(s.entries))
+	for table, e := range s.entries {
+		status := Status{Table: table, Schedule: e.expr, Source: e.source, Error: s.errors[table]}
+		if e.err != nil {
+			status.Error = e.err.Error()
+		} else if next := e.cron.Next(s.checked); !next.IsZero() {
+			status.Next = &next
+		}
+		if lastRun, ok := s.lastRuns[table]; ok {
+			status.LastRun = &lastRun
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Table < statuses[j].Table })
+	return statuses
+}
+
+// Close stops the Scheduler.
+func (s *Scheduler) Close() {
+	close(s.closer)
+	<-s.closed
+}
@@ -0,0 +1,85 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+type fakeSource struct {
+	schedules []metadata.ForceLoadSchedule
+	forced    []string
+	err       error
+}
+
+func (s *fakeSource) ForceLoadSchedules() ([]metadata.ForceLoadSchedule, error) {
+	return s.schedules, nil
+}
+
+func (s *fakeSource) ForceLoad(table string, requester string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.forced = append(s.forced, table+" by "+requester)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	start := time.Date(2017, 3, 8, 5, 40, 0, 0, time.UTC)
+	source := &fakeSource{schedules: []metadata.ForceLoadSchedule{
+		{Table: "search", Schedule: "50 5 * * *"},
+		{Table: "broken", Schedule: "soon"},
+	}}
+	s := &Scheduler{
+		source:   source,
+		stats:    monitoring.NewMockStatter(),
+		defaults: map[string]*Cron{},
+		checked:  start,
+		lastRuns: make(map[string]time.Time),
+		errors:   make(map[string]string),
+	}
+	for table, expr := range map[string]string{"booking": "45 5 * * *", "search": "0 0 * * *"} {
+		cron, err := Parse(expr)
+		assert.NoError(t, err)
+		s.defaults[table] = cron
+	}
+
+	s.run(start.Add(4*time.Minute + 59*time.Second))
+	assert.Empty(t, source.forced)
+
+	// A check late by a few minutes still runs the schedule once
+	s.run(start.Add(8 * time.Minute))
+	assert.Equal(t, []string{"booking by scheduler"}, source.forced)
+	s.run(start.Add(9 * time.Minute))
+	assert.Len(t, source.forced, 1)
+
+	// The db's schedule overrides the config's, and a failed force load is reported
+	source.err = errors.New("db down")
+	s.run(start.Add(10 * time.Minute))
+
+	statuses := s.Statuses()
+	if assert.Len(t, statuses, 3) {
+		assert.Equal(t, "broken", statuses[1].Table)
+		assert.NotEmpty(t, statuses[1].Error)
+		assert.Nil(t, statuses[1].Next)
+		assert.Equal(t, Status{Table: "booking", Schedule: "45 5 * * *", Source: SourceConfig,
+			Next: timePtr(time.Date(2017, 3, 9, 5, 45, 0, 0, time.UTC)), LastRun: timePtr(start.Add(8 * time.Minute))},
+			statuses[0])
+		assert.Equal(t, SourceDB, statuses[2].Source)
+		assert.Equal(t, "db down", statuses[2].Error)
+		assert.Equal(t, start.Add(10*time.Minute), *statuses[2].LastRun)
+	}
+}
+
+func TestNewInvalidDefault(t *testing.T) {
+	_, err := New(&fakeSource{}, monitoring.NewMockStatter(), Config{Defaults: map[string]string{"booking": "daily"}})
+	assert.Error(t, err)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
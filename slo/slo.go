@@ -0,0 +1,218 @@
+package slo
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/lib"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+// Where a table's SLO is defined.
+const (
+	// SourceConfig is an SLO from the loader's config
+	SourceConfig = "config"
+	// SourceDB is an SLO set in ingesterdb, which overrides the config's
+	SourceDB = "db"
+)
+
+// Source gives the SLOs set in ingesterdb and the files waiting to be loaded.
+type Source interface {
+	FreshnessSLOs() ([]metadata.FreshnessSLO, error)
+	StatsForPendingLoads() ([]*metadata.PendingLoadStats, error)
+}
+
+// Config configures an Evaluator.
+type Config struct {
+	// Defaults is the max age in seconds of each table's files, overridden by SLOs in ingesterdb
+	Defaults map[string]int
+	// Period is how often the SLOs are evaluated
+	Period time.Duration
+}
+
+// Compliance is whether a table met its freshness SLO when last evaluated. A table is compliant if
+// none of its files has waited longer than MaxAgeSeconds to be loaded.
+type Compliance struct {
+	Table         string
+	MaxAgeSeconds int
+	Source        string
+	PendingFiles  int64
+	// OldestPendingSeconds is how long the oldest file waiting to be loaded has waited
+	OldestPendingSeconds int64
+	Compliant            bool
+	// BreachingSince is when the table was first found in breach, if it still is
+	BreachingSince *time.Time `json:",omitempty"`
+	Evaluated      time.Time
+}
+
+// pending is the files of a table waiting to be loaded.
+type pending struct {
+	count  int64
+	oldest time.Time
+}
+
+// Evaluator periodically checks tables' freshness SLOs. It sends each table's oldest pending file
+// age as stats, and a breach is logged as an error and counted when a table starts breaching.
+type Evaluator struct {
+	source Source
+	stats  monitoring.SafeStatter
+	config Config
+
+	compliance     []Compliance
+	complianceLock sync.RWMutex
+	closer         chan bool
+	closed         chan bool
+}
+
+// New returns an Evaluator and starts its loop.
+func New(source Source, stats monitoring.SafeStatter, config Config) *Evaluator {
+	e := &Evaluator{
+		source: source,
+		stats:  stats,
+		config: config,
+		closer: make(chan bool),
+		closed: make(chan bool),
+	}
+	logger.Go(e.loop)
+	return e
+}
+
+func (e *Evaluator) loop() {
+	defer close(e.closed)
+	tick := time.NewTicker(e.config.Period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			e.evaluate(time.Now().In(time.UTC))
+		case <-e.closer:
+			return
+		}
+	}
+}
+
+// slos returns the max age in seconds of each table with an SLO, and where it's defined.
+func (e *Evaluator) slos() (map[string]int, map[string]string, error) {
+	maxAges := make(map[string]int, len(e.config.Defaults))
+	sources := make(map[string]string, len(e.config.Defaults))
+	for table, maxAge := range e.config.Defaults {
+		maxAges[table] = maxAge
+		sources[table] = SourceConfig
+	}
+	dbSLOs, err := e.source.FreshnessSLOs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, slo := range dbSLOs {
+		maxAges[slo.Table] = slo.MaxAgeSeconds
+		sources[slo.Table] = SourceDB
+	}
+	return maxAges, sources, nil
+}
+
+// pendingFiles returns the files waiting to be loaded by table, whether queued, stale or
+// waiting for a migration.
+func (e *Evaluator) pendingFiles() (map[string]pending, error) {
+	allStats, err := e.source.StatsForPendingLoads()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]pending)
+	for _, loadStats := range allStats {
+		for _, eventStats := range loadStats.Stats {
+			p := files[eventStats.Event]
+			if p.count == 0 || eventStats.MinTS.Before(p.oldest) {
+				p.oldest = eventStats.MinTS
+			}
+			p.count += eventStats.Count
+			files[eventStats.Event] = p
+		}
+	}
+	return files, nil
+}
+
+// evaluate checks every table's SLO, keeping the previous compliance if it can't.
+func (e *Evaluator) evaluate(now time.Time) {
+	maxAges, sources, err := e.slos()
+	if err != nil {
+		logger.WithError(err).Error("Error listing freshness SLOs")
+		e.stats.SafeInc("slo.total.errors", 1, 1)
+		return
+	}
+	files, err := e.pendingFiles()
+	if err != nil {
+		logger.WithError(err).Error("Error getting pending files for freshness SLOs")
+		e.stats.SafeInc("slo.total.errors", 1, 1)
+		return
+	}
+
+	previous := make(map[string]Compliance)
+	for _, c := range e.Compliance() {
+		previous[c.Table] = c
+	}
+	tables := make([]string, 0, len(maxAges))
+	for table := range maxAges {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	compliance := make([]Compliance, 0, len(tables))
+	breaching := 0
+	for _, table := range tables {
+		c := Compliance{
+			Table:         table,
+			MaxAgeSeconds: maxAges[table],
+			Source:        sources[table],
+			Compliant:     true,
+			Evaluated:     now,
+		}
+		if p, ok := files[table]; ok && p.count > 0 {
+			c.PendingFiles = p.count
+			c.OldestPendingSeconds = int64(now.Sub(p.oldest) / time.Second)
+		}
+		if c.OldestPendingSeconds > int64(c.MaxAgeSeconds) {
+			c.Compliant = false
+			breaching++
+			c.BreachingSince = previous[table].BreachingSince
+			if c.BreachingSince == nil {
+				since := now
+				c.BreachingSince = &since
+				lib.TableInc(e.stats, "slo.breach", table, 1)
+				logger.WithField("table", table).
+					WithField("maxAgeSeconds", c.MaxAgeSeconds).
+					WithField("oldestPendingSeconds", c.OldestPendingSeconds).
+					WithField("pendingFiles", c.PendingFiles).
+					Error("Freshness SLO breached")
+			}
+		} else if previous[table].BreachingSince != nil {
+			logger.WithField("table", table).
+				WithField("breachingSince", *previous[table].BreachingSince).
+				Info("Freshness SLO met again")
+		}
+		lib.TableGauge(e.stats, "slo.oldest_pending_seconds", table, c.OldestPendingSeconds)
+		compliance = append(compliance, c)
+	}
+	e.stats.SafeGauge("slo.total.breaching", int64(breaching), 1)
+
+	e.complianceLock.Lock()
+	defer e.complianceLock.Unlock()
+	e.compliance = compliance
+}
+
+// Compliance returns each table's compliance with its SLO as of the last evaluation.
+func (e *Evaluator) Compliance() []Compliance {
+	e.complianceLock.RLock()
+	defer e.complianceLock.RUnlock()
+	compliance := make([]Compliance, len(e.compliance))
+	copy(compliance, e.compliance)
+	return compliance
+}
+
+// Close stops the evaluator.
+func (e *Evaluator) Close() {
+	close(e.closer)
+	<-e.closed
+}
@@ -0,0 +1,66 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+	"github.com/twitchscience/rs_ingester/metadata"
+)
+
+type fakeSource struct {
+	slos    []metadata.FreshnessSLO
+	pending []*metadata.PendingLoadStats
+}
+
+func (s *fakeSource) FreshnessSLOs() ([]metadata.FreshnessSLO, error) {
+	return s.slos, nil
+}
+
+func (s *fakeSource) StatsForPendingLoads() ([]*metadata.PendingLoadStats, error) {
+	return s.pending, nil
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2017, 3, 8, 4, 0, 0, 0, time.UTC)
+	source := &fakeSource{
+		slos: []metadata.FreshnessSLO{{Table: "booking", MaxAgeSeconds: 900}},
+		pending: []*metadata.PendingLoadStats{
+			{Type: metadata.PendingInQueue, Stats: []*metadata.EventStats{
+				{Event: "booking", Count: 3, MinTS: now.Add(-10 * time.Minute)},
+				{Event: "search", Count: 1, MinTS: now.Add(-time.Minute)},
+			}},
+			{Type: metadata.PendingStale, Stats: []*metadata.EventStats{
+				{Event: "booking", Count: 1, MinTS: now.Add(-20 * time.Minute)},
+			}},
+		},
+	}
+	e := &Evaluator{
+		source: source,
+		stats:  monitoring.NewMockStatter(),
+		config: Config{Defaults: map[string]int{"booking": 3600, "search": 300, "idle": 60}},
+	}
+
+	e.evaluate(now)
+	compliance := e.Compliance()
+	if assert.Len(t, compliance, 3) {
+		assert.Equal(t, Compliance{Table: "idle", MaxAgeSeconds: 60, Source: SourceConfig, Compliant: true,
+			Evaluated: now}, compliance[1])
+		booking := compliance[0]
+		assert.Equal(t, SourceDB, booking.Source)
+		assert.Equal(t, int64(4), booking.PendingFiles)
+		assert.Equal(t, int64(1200), booking.OldestPendingSeconds)
+		assert.False(t, booking.Compliant)
+		assert.Equal(t, now, *booking.BreachingSince)
+		assert.True(t, compliance[2].Compliant)
+	}
+
+	// A continued breach keeps when it started, and a met SLO clears it
+	e.evaluate(now.Add(time.Minute))
+	assert.Equal(t, now, *e.Compliance()[0].BreachingSince)
+	source.pending = nil
+	e.evaluate(now.Add(2 * time.Minute))
+	assert.True(t, e.Compliance()[0].Compliant)
+	assert.Nil(t, e.Compliance()[0].BreachingSince)
+}
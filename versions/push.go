@@ -0,0 +1,123 @@
+package versions
+
+import (
+	"sort"
+	"time"
+
+	"github.com/twitchscience/aws_utils/logger"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+// TableVersion is a table's version in the cache and in Ace. Either is nil if the table isn't there;
+// Drift is set if they differ.
+type TableVersion struct {
+	Table  string
+	Cached *int `json:",omitempty"`
+	Ace    *int `json:",omitempty"`
+	Drift  bool
+}
+
+// Compare returns the tables of either map with their versions in each, sorted by table.
+func Compare(cached, ace map[string]int) []TableVersion {
+	tables := make(map[string]*TableVersion, len(cached))
+	for table, version := range cached {
+		version := version
+		tables[table] = &TableVersion{Table: table, Cached: &version}
+	}
+	for table, version := range ace {
+		version := version
+		tv, ok := tables[table]
+		if !ok {
+			tv = &TableVersion{Table: table}
+			tables[table] = tv
+		}
+		tv.Ace = &version
+	}
+	compared := make([]TableVersion, 0, len(tables))
+	for _, tv := range tables {
+		tv.Drift = tv.Cached == nil || tv.Ace == nil || *tv.Cached != *tv.Ace
+		compared = append(compared, *tv)
+	}
+	sort.Slice(compared, func(i, j int) bool { return compared[i].Table < compared[j].Table })
+	return compared
+}
+
+// AceSource reads the table versions from Ace.
+type AceSource interface {
+	TableVersions() (map[string]int, error)
+}
+
+// Publisher publishes the tables' versions, e.g. to blueprint.
+type Publisher interface {
+	PublishVersions(tables []TableVersion) error
+}
+
+// Pusher periodically compares the cached versions with Ace's and publishes them, gauging the tables
+// whose versions drifted in versions.drift. Failed comparisons and publishes are counted in
+// versions.push_errors, and the next period tries again.
+type Pusher struct {
+	cache     Getter
+	ace       AceSource
+	publisher Publisher
+	stats     monitoring.SafeStatter
+	period    time.Duration
+	closer    chan bool
+	closed    chan bool
+}
+
+// NewPusher returns a Pusher publishing every period, and starts its loop.
+func NewPusher(cache Getter, ace AceSource, publisher Publisher, period time.Duration,
+	stats monitoring.SafeStatter) *Pusher {
+	p := &Pusher{
+		cache:     cache,
+		ace:       ace,
+		publisher: publisher,
+		stats:     stats,
+		period:    period,
+		closer:    make(chan bool),
+		closed:    make(chan bool),
+	}
+	logger.Go(p.loop)
+	return p
+}
+
+func (p *Pusher) loop() {
+	defer close(p.closed)
+	tick := time.NewTicker(p.period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			p.push()
+		case <-p.closer:
+			return
+		}
+	}
+}
+
+func (p *Pusher) push() {
+	ace, err := p.ace.TableVersions()
+	if err != nil {
+		logger.WithError(err).Warn("Error getting table versions from ace to publish")
+		p.stats.SafeInc("versions.push_errors", 1, 1.0)
+		return
+	}
+	tables := Compare(p.cache.Snapshot(), ace)
+	drifted := 0
+	for _, tv := range tables {
+		if tv.Drift {
+			drifted++
+		}
+	}
+	p.stats.SafeGauge("versions.drift", int64(drifted), 1.0)
+	if err = p.publisher.PublishVersions(tables); err != nil {
+		logger.WithError(err).Warn("Error publishing table versions")
+		p.stats.SafeInc("versions.push_errors", 1, 1.0)
+	}
+}
+
+// Close stops the pusher.
+func (p *Pusher) Close() {
+	close(p.closer)
+	<-p.closed
+}
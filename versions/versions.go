@@ -5,23 +5,45 @@ import "sync"
 // Getter is an interface for reading table versions
 type Getter interface {
 	Get(string) (int, bool)
+	// Tables returns the names of every table with a version
+	Tables() []string
+	// Snapshot returns a copy of every table's version
+	Snapshot() map[string]int
 }
 
 // Setter is an interface for writing table versions
 type Setter interface {
 	Set(string, int)
+	Delete(string)
+	// Rename moves a table's version to its new name at the version, in one step, so the table is
+	// never seen under both names or neither
+	Rename(from, to string, version int)
+}
+
+// Pinner is an interface for pinning tables to versions, which readers see in place of their own
+type Pinner interface {
+	// Pin has the table read as being at the version until it's unpinned, whatever it's Set to
+	Pin(table string, version int)
+	// Unpin removes the table's pin, returning the version it was pinned to and whether it was
+	Unpin(table string) (int, bool)
+	// Pins returns a copy of every pinned table's version
+	Pins() map[string]int
+	// Unpinned returns the table's version ignoring its pin
+	Unpinned(table string) (int, bool)
 }
 
 // GetterSetter is an interface for both reading and writing table versions
 type GetterSetter interface {
 	Getter
 	Setter
+	Pinner
 }
 
 // New returns a new GetterSetter versions map from a given map
 func New(init map[string]int) GetterSetter {
 	return versions{
 		content: init,
+		pins:    make(map[string]int),
 		mutex:   &sync.RWMutex{},
 	}
 }
@@ -29,12 +51,17 @@ func New(init map[string]int) GetterSetter {
 type versions struct {
 	mutex   *sync.RWMutex
 	content map[string]int
+	// pins are the versions tables are pinned to, overriding content
+	pins map[string]int
 }
 
 func (v versions) Get(table string) (int, bool) {
 	v.mutex.RLock()
 	defer v.mutex.RUnlock()
 
+	if val, ok := v.pins[table]; ok {
+		return val, true
+	}
 	val, ok := v.content[table]
 	return val, ok
 }
@@ -45,3 +72,80 @@ func (v versions) Set(table string, val int) {
 
 	v.content[table] = val
 }
+
+func (v versions) Delete(table string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	delete(v.content, table)
+	delete(v.pins, table)
+}
+
+func (v versions) Rename(from, to string, version int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	delete(v.content, from)
+	delete(v.pins, from)
+	v.content[to] = version
+}
+
+func (v versions) Tables() []string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	tables := make([]string, 0, len(v.content))
+	for table := range v.content {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+func (v versions) Snapshot() map[string]int {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	snapshot := make(map[string]int, len(v.content))
+	for table, version := range v.content {
+		snapshot[table] = version
+	}
+	for table, version := range v.pins {
+		snapshot[table] = version
+	}
+	return snapshot
+}
+
+func (v versions) Pin(table string, version int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.pins[table] = version
+}
+
+func (v versions) Unpin(table string) (int, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	val, ok := v.pins[table]
+	delete(v.pins, table)
+	return val, ok
+}
+
+func (v versions) Pins() map[string]int {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	pins := make(map[string]int, len(v.pins))
+	for table, version := range v.pins {
+		pins[table] = version
+	}
+	return pins
+}
+
+func (v versions) Unpinned(table string) (int, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	val, ok := v.content[table]
+	return val, ok
+}
@@ -0,0 +1,156 @@
+package versions
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twitchscience/aws_utils/monitoring"
+)
+
+func TestPin(t *testing.T) {
+	v := New(map[string]int{"chat": 3, "follow": 1})
+	v.Pin("chat", 2)
+
+	version, ok := v.Get("chat")
+	assert.True(t, ok)
+	assert.Equal(t, 2, version, "readers see the pinned version")
+	version, ok = v.Unpinned("chat")
+	assert.True(t, ok)
+	assert.Equal(t, 3, version)
+	_, ok = v.Unpinned("missing")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]int{"chat": 2}, v.Pins())
+	assert.Equal(t, map[string]int{"chat": 2, "follow": 1}, v.Snapshot())
+
+	// Setting the table doesn't move its pin
+	v.Set("chat", 4)
+	version, _ = v.Get("chat")
+	assert.Equal(t, 2, version)
+	version, _ = v.Unpinned("chat")
+	assert.Equal(t, 4, version)
+
+	version, ok = v.Unpin("chat")
+	assert.True(t, ok)
+	assert.Equal(t, 2, version)
+	_, ok = v.Unpin("chat")
+	assert.False(t, ok)
+	version, _ = v.Get("chat")
+	assert.Equal(t, 4, version)
+	assert.Empty(t, v.Pins())
+}
+
+func TestPinsCopy(t *testing.T) {
+	v := New(map[string]int{"chat": 3})
+	v.Pin("chat", 3)
+	pins := v.Pins()
+	pins["chat"] = 0
+	version, _ := v.Get("chat")
+	assert.Equal(t, 3, version, "the pins returned are a copy")
+}
+
+func TestCompare(t *testing.T) {
+	one, two, three := 1, 2, 3
+	assert.Equal(t, []TableVersion{
+		{Table: "chat", Cached: &three, Ace: &three},
+		{Table: "clip", Ace: &one, Drift: true},
+		{Table: "follow", Cached: &one, Ace: &two, Drift: true},
+		{Table: "video", Cached: &two, Drift: true},
+	}, Compare(map[string]int{"chat": 3, "follow": 1, "video": 2}, map[string]int{"chat": 3, "follow": 2, "clip": 1}))
+	assert.Empty(t, Compare(nil, nil))
+}
+
+// fakeAce has the tables' versions, or fails with err.
+type fakeAce struct {
+	versions map[string]int
+	err      error
+}
+
+func (a *fakeAce) TableVersions() (map[string]int, error) { return a.versions, a.err }
+
+// fakePublisher records what's published, failing with err.
+type fakePublisher struct {
+	published chan []TableVersion
+	err       error
+}
+
+func (p *fakePublisher) PublishVersions(tables []TableVersion) error {
+	p.published <- tables
+	return p.err
+}
+
+// pushStatter records the stats of a push.
+type pushStatter struct {
+	monitoring.SafeStatter
+	lock   sync.Mutex
+	counts map[string]int64
+	gauges map[string]int64
+}
+
+func newPushStatter() *pushStatter {
+	return &pushStatter{SafeStatter: monitoring.NewMockStatter(), counts: map[string]int64{}, gauges: map[string]int64{}}
+}
+
+func (s *pushStatter) SafeInc(stat string, value int64, rate float32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.counts[stat] += value
+}
+
+func (s *pushStatter) SafeGauge(stat string, value int64, rate float32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gauges[stat] = value
+}
+
+func TestPush(t *testing.T) {
+	ace := &fakeAce{versions: map[string]int{"chat": 3, "follow": 2}}
+	publisher := &fakePublisher{published: make(chan []TableVersion, 1)}
+	stats := newPushStatter()
+	p := &Pusher{cache: New(map[string]int{"chat": 3, "follow": 1}), ace: ace, publisher: publisher, stats: stats}
+
+	p.push()
+	tables := <-publisher.published
+	assert.Len(t, tables, 2)
+	assert.True(t, tables[1].Drift)
+	assert.Equal(t, int64(1), stats.gauges["versions.drift"])
+	assert.Zero(t, stats.counts["versions.push_errors"])
+
+	publisher.err = errors.New("blueprint is down")
+	p.push()
+	<-publisher.published
+	assert.Equal(t, int64(1), stats.counts["versions.push_errors"])
+
+	ace.err = errors.New("connection refused")
+	p.push()
+	assert.Equal(t, int64(2), stats.counts["versions.push_errors"])
+	assert.Empty(t, publisher.published, "versions aren't published without Ace's")
+}
+
+func TestPusher(t *testing.T) {
+	publisher := &fakePublisher{published: make(chan []TableVersion, 1)}
+	p := NewPusher(New(map[string]int{"chat": 3}), &fakeAce{versions: map[string]int{"chat": 3}}, publisher,
+		time.Millisecond, monitoring.NewMockStatter())
+	select {
+	case tables := <-publisher.published:
+		assert.Equal(t, "chat", tables[0].Table)
+		assert.False(t, tables[0].Drift)
+	case <-time.After(time.Second):
+		t.Fatal("versions weren't published")
+	}
+	closed := make(chan bool)
+	go func() {
+		// Keep taking publishes so the loop isn't blocked on one when it's closed
+		for {
+			select {
+			case <-publisher.published:
+			case <-closed:
+				return
+			}
+		}
+	}()
+	p.Close()
+	close(closed)
+}